@@ -82,6 +82,11 @@ func (m *Metadata) IncrementResourceVersion() {
 	m.ResourceVersion++
 }
 
+// IncrementGeneration records a change of the spec of the object.
+func (m *Metadata) IncrementGeneration() {
+	m.Generation++
+}
+
 type Object interface {
 	GetID() string
 	GetAnnotations() map[string]string
@@ -9,6 +9,75 @@ const (
 	AnnotationsAnnotation = "libvirt-provider.ironcore.dev/annotations"
 )
 
+const (
+	// ExtraKernelCmdlineAnnotation is the IRI machine annotation holding additional kernel command line
+	// parameters that are appended to the image command line when booting via direct kernel boot.
+	ExtraKernelCmdlineAnnotation = "libvirt-provider.ironcore.dev/extra-kernel-cmdline"
+
+	// HostEventPolicyAnnotation is the IRI machine annotation selecting the HostEventPolicy of a machine.
+	HostEventPolicyAnnotation = "libvirt-provider.ironcore.dev/host-event-policy"
+
+	// SMBIOSAnnotation is the IRI machine annotation holding the SMBIOSSpec of a machine as JSON.
+	SMBIOSAnnotation = "libvirt-provider.ironcore.dev/smbios"
+
+	// BootOrderAnnotation is the IRI machine annotation holding the comma separated boot devices of a machine,
+	// e.g. volume:data,network:nic-1,rootfs.
+	BootOrderAnnotation = "libvirt-provider.ironcore.dev/boot-order"
+
+	// MediaAnnotation is the IRI machine annotation holding the MediaSpecs of a machine as JSON list.
+	MediaAnnotation = "libvirt-provider.ironcore.dev/media"
+
+	// RescueAnnotation is the IRI machine annotation booting a machine into a rescue image. Its value is the
+	// reference of the rescue image, empty selects the rescue image of the provider. Removing the annotation
+	// boots the machine normally again.
+	RescueAnnotation = "libvirt-provider.ironcore.dev/rescue"
+
+	// HardwareAnnotation is the IRI machine annotation holding the HardwareSpec of a machine as JSON.
+	HardwareAnnotation = "libvirt-provider.ironcore.dev/hardware"
+
+	// WindowsAnnotation is the IRI machine annotation holding the WindowsSpec of a machine as JSON, e.g. {} for
+	// the defaults.
+	WindowsAnnotation = "libvirt-provider.ironcore.dev/windows"
+
+	// RootFSModeAnnotation is the IRI machine annotation selecting the RootFSMode of a machine.
+	RootFSModeAnnotation = "libvirt-provider.ironcore.dev/rootfs-mode"
+)
+
+const (
+	// FailureReasonAnnotation is the IRI machine annotation reporting the MachineFailureReason of a Failed
+	// machine. It is set by the provider, as IRI has no failed machine state.
+	FailureReasonAnnotation = "libvirt-provider.ironcore.dev/failure-reason"
+
+	// FailureMessageAnnotation is the IRI machine annotation reporting the error the domain of a Failed machine
+	// can't be created with.
+	FailureMessageAnnotation = "libvirt-provider.ironcore.dev/failure-message"
+
+	// VolumeFailuresAnnotation is the IRI machine annotation reporting the DeviceFailure of the failed volumes of
+	// a machine as JSON object by volume name, as IRI has no failed volume state.
+	VolumeFailuresAnnotation = "libvirt-provider.ironcore.dev/volume-failures"
+
+	// NetworkInterfaceFailuresAnnotation is the IRI machine annotation reporting the DeviceFailure of the failed
+	// network interfaces of a machine as JSON object by network interface name.
+	NetworkInterfaceFailuresAnnotation = "libvirt-provider.ironcore.dev/network-interface-failures"
+)
+
+// StatusAnnotations are the IRI machine annotations set by the provider. They are ignored in annotation updates.
+var StatusAnnotations = []string{
+	FailureReasonAnnotation,
+	FailureMessageAnnotation,
+	VolumeFailuresAnnotation,
+	NetworkInterfaceFailuresAnnotation,
+}
+
+// DeviceFailure is the failure of a volume or network interface reported by the VolumeFailuresAnnotation and
+// NetworkInterfaceFailuresAnnotation.
+type DeviceFailure struct {
+	// Message is the error of the last attempt to attach or detach the device.
+	Message string `json:"message"`
+	// FailedAttempts is the number of consecutive failed attempts to attach or detach the device.
+	FailedAttempts int `json:"failedAttempts"`
+}
+
 const (
 	ManagerLabel = "libvirt-provider.ironcore.dev/manager"
 	ClassLabel   = "libvirt-provider.ironcore.dev/class"
@@ -0,0 +1,80 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package api
+
+import (
+	"time"
+)
+
+type MachineConditionType string
+
+const (
+	MachineConditionImageReady          MachineConditionType = "ImageReady"
+	MachineConditionVolumesAttached     MachineConditionType = "VolumesAttached"
+	MachineConditionNetworkReady        MachineConditionType = "NetworkReady"
+	MachineConditionGuestAgentConnected MachineConditionType = "GuestAgentConnected"
+	MachineConditionDomainSynced        MachineConditionType = "DomainSynced"
+	MachineConditionRescued             MachineConditionType = "Rescued"
+	MachineConditionDomainDrifted       MachineConditionType = "DomainDrifted"
+)
+
+type ConditionStatus string
+
+const (
+	ConditionTrue    ConditionStatus = "True"
+	ConditionFalse   ConditionStatus = "False"
+	ConditionUnknown ConditionStatus = "Unknown"
+)
+
+type MachineCondition struct {
+	Type               MachineConditionType `json:"type"`
+	Status             ConditionStatus      `json:"status"`
+	Reason             string               `json:"reason,omitempty"`
+	Message            string               `json:"message,omitempty"`
+	LastTransitionTime time.Time            `json:"lastTransitionTime"`
+}
+
+// GetMachineCondition returns the condition of the given type or nil if it isn't set.
+func GetMachineCondition(conditions []MachineCondition, conditionType MachineConditionType) *MachineCondition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
+
+// SetMachineCondition adds or updates the condition of the given type. The LastTransitionTime is only
+// updated if the status changes.
+func SetMachineCondition(conditions *[]MachineCondition, condition MachineCondition) {
+	existing := GetMachineCondition(*conditions, condition.Type)
+	if existing == nil {
+		if condition.LastTransitionTime.IsZero() {
+			condition.LastTransitionTime = time.Now()
+		}
+		*conditions = append(*conditions, condition)
+		return
+	}
+
+	if existing.Status != condition.Status {
+		existing.Status = condition.Status
+		existing.LastTransitionTime = condition.LastTransitionTime
+		if existing.LastTransitionTime.IsZero() {
+			existing.LastTransitionTime = time.Now()
+		}
+	}
+	existing.Reason = condition.Reason
+	existing.Message = condition.Message
+}
+
+// RemoveMachineCondition removes the condition of the given type.
+func RemoveMachineCondition(conditions *[]MachineCondition, conditionType MachineConditionType) {
+	res := (*conditions)[:0]
+	for _, condition := range *conditions {
+		if condition.Type != conditionType {
+			res = append(res, condition)
+		}
+	}
+	*conditions = res
+}
@@ -23,12 +23,239 @@ type MachineSpec struct {
 	Image    *string `json:"image"`
 	Ignition []byte  `json:"ignition"`
 
+	// RootFSMode defines how the root fs disk is derived from the root fs of the Image. Empty means Copy.
+	RootFSMode RootFSMode `json:"rootFSMode,omitempty"`
+
+	ExtraKernelCmdline []string `json:"extraKernelCmdline,omitempty"`
+
 	Volumes           []*VolumeSpec           `json:"volumes"`
 	NetworkInterfaces []*NetworkInterfaceSpec `json:"networkInterfaces"`
 
 	ShutdownAt time.Time `json:"shutdownAt,omitempty"`
 
 	GuestAgent GuestAgent `json:"guestAgent"`
+
+	HostEventPolicy HostEventPolicy `json:"hostEventPolicy,omitempty"`
+
+	SMBIOS *SMBIOSSpec `json:"smbios,omitempty"`
+
+	Hugepages *HugepagesSpec `json:"hugepages,omitempty"`
+
+	SGX *SGXSpec `json:"sgx,omitempty"`
+
+	PCI *PCISpec `json:"pci,omitempty"`
+
+	Queues *QueuesSpec `json:"queues,omitempty"`
+
+	Boot *BootSpec `json:"boot,omitempty"`
+
+	Media []*MediaSpec `json:"media,omitempty"`
+
+	Rescue *RescueSpec `json:"rescue,omitempty"`
+
+	PCIDevices []PCIDeviceClaim `json:"pciDevices,omitempty"`
+
+	Devices *DevicesSpec `json:"devices,omitempty"`
+
+	Hardware *HardwareSpec `json:"hardware,omitempty"`
+
+	Clock *ClockSpec `json:"clock,omitempty"`
+
+	Windows *WindowsSpec `json:"windows,omitempty"`
+
+	Cgroup *CgroupSpec `json:"cgroup,omitempty"`
+}
+
+// CgroupSpec partitions the host resources of the QEMU process of a machine, taken from the cgroup policy of its
+// class. The weights apply relative to the other processes of the partition, independent of the vCPU pinning.
+type CgroupSpec struct {
+	// Partition is the cgroup partition of the QEMU process, e.g. /machine/gold. The partition has to exist.
+	// Empty means the libvirt default partition /machine.
+	Partition string `json:"partition,omitempty"`
+	// CPUShares is the cpu weight of the QEMU process. Zero means the libvirt default.
+	CPUShares uint `json:"cpuShares,omitempty"`
+	// IOWeight is the block io weight of the QEMU process, between 100 and 1000. Zero means the libvirt default.
+	IOWeight uint `json:"ioWeight,omitempty"`
+	// MemorySoftLimitBytes is the memory the QEMU process is reclaimed down to under host memory pressure. Zero
+	// means no soft limit.
+	MemorySoftLimitBytes int64 `json:"memorySoftLimitBytes,omitempty"`
+}
+
+// WindowsSpec marks a machine as Windows guest. Its domain gets the Hyper-V enlightenments and its network
+// interfaces an emulated model Windows supports without virtio-win drivers.
+type WindowsSpec struct {
+	// NetworkInterfaceModel is the model of the network interfaces without explicit model. Empty means e1000e.
+	NetworkInterfaceModel string `json:"networkInterfaceModel,omitempty"`
+	// SATARootFS attaches the root fs disk to the SATA bus instead of virtio.
+	SATARootFS bool `json:"sataRootFS,omitempty"`
+}
+
+// ClockSpec defines the clock and timers of a machine, taken from the clock policy of its class. Nil means a utc
+// clock with the provider default timers.
+type ClockSpec struct {
+	// Offset is the base of the rtc: utc or localtime. Windows guests expect localtime. Empty means utc.
+	Offset string `json:"offset,omitempty"`
+	// KVMClock enables or disables the kvmclock paravirtual clock. Nil means the hypervisor default.
+	KVMClock *bool `json:"kvmClock,omitempty"`
+	// HPET enables or disables the HPET timer. Nil means enabled.
+	HPET *bool `json:"hpet,omitempty"`
+	// HypervClock adds the Hyper-V reference time counter, used by Windows guests.
+	HypervClock bool `json:"hypervClock,omitempty"`
+}
+
+// RootFSMode defines how the root fs disk of a machine is derived from the root fs of its image.
+type RootFSMode string
+
+const (
+	// RootFSModeCopy copies the root fs of the image per machine.
+	RootFSModeCopy RootFSMode = "Copy"
+	// RootFSModeShared attaches the cached root fs of the image read-only, shared by all machines of the image.
+	// It requires a direct kernel boot image.
+	RootFSModeShared RootFSMode = "Shared"
+	// RootFSModeOverlay creates a qcow2 overlay per machine, backed by the cached root fs of the image.
+	RootFSModeOverlay RootFSMode = "Overlay"
+)
+
+// HardwareSpec pins the virtual hardware of a machine, so long-lived guests keep a stable ABI across upgrades of
+// the provider and QEMU.
+type HardwareSpec struct {
+	// MachineType is the exact QEMU machine type, e.g. pc-q35-8.2. Empty means the machine type the domain was
+	// first started with, which defaults to the preferred machine type of the host.
+	MachineType string `json:"machineType,omitempty"`
+	// Loader is the path of the UEFI firmware image, e.g. an OVMF build. Empty means the firmware is selected by
+	// libvirt.
+	Loader string `json:"loader,omitempty"`
+	// NVRAMTemplate is the path of the template of the UEFI variable store matching the Loader.
+	NVRAMTemplate string `json:"nvramTemplate,omitempty"`
+}
+
+// DevicesSpec defines the auxiliary devices of a machine, taken from the device profile of its class. Nil means
+// the provider defaults, a virtio RNG only.
+type DevicesSpec struct {
+	// RNG is the virtio random number generator. Nil means no generator.
+	RNG *RNGSpec `json:"rng,omitempty"`
+	// MemBalloon adds a virtio memory balloon. Otherwise the domain has none.
+	MemBalloon bool `json:"memBalloon,omitempty"`
+	// Inputs are the input devices added to the default PS/2 devices.
+	Inputs []InputSpec `json:"inputs,omitempty"`
+	// Video is the model of the video device, e.g. virtio. Empty means no video device.
+	Video string `json:"video,omitempty"`
+}
+
+// RNGSpec limits the entropy a virtio random number generator passes to the guest.
+type RNGSpec struct {
+	// Bytes is the number of bytes passed to the guest per period.
+	Bytes uint `json:"bytes"`
+	// PeriodMillis is the period in milliseconds. Zero means one second.
+	PeriodMillis uint `json:"periodMillis,omitempty"`
+}
+
+// InputSpec defines an input device of a machine.
+type InputSpec struct {
+	// Type is the type of the device: tablet, keyboard or mouse.
+	Type string `json:"type"`
+	// Bus is the bus of the device: usb or virtio. Empty means usb.
+	Bus string `json:"bus,omitempty"`
+}
+
+// PCIDeviceClaim requests devices of a PCI device pool of the provider, passed through to the machine.
+type PCIDeviceClaim struct {
+	Pool  string `json:"pool"`
+	Count int    `json:"count"`
+}
+
+// RescueSpec boots a machine into a rescue image instead of its image. The root fs disk and the volumes of the
+// machine stay attached read-write, so they can be repaired.
+type RescueSpec struct {
+	// Image is the reference of the direct kernel boot rescue image. Empty means the rescue image of the provider.
+	Image string `json:"image,omitempty"`
+}
+
+// MediaSpec defines a cdrom drive of a machine. The drives of a domain are fixed until it is restarted, their
+// media is inserted and ejected while the domain runs.
+type MediaSpec struct {
+	Name string `json:"name"`
+	// Source is the inserted media. Nil means the drive is empty.
+	Source *MediaSource `json:"source,omitempty"`
+}
+
+// MediaSource is an ISO image. Exactly one of Image and Path is set.
+type MediaSource struct {
+	// Image is the reference of an OCI image whose root fs layer is the ISO image.
+	Image string `json:"image,omitempty"`
+	// Path is the path of the ISO image relative to the media directory of the provider.
+	Path string `json:"path,omitempty"`
+}
+
+// BootSpec defines the devices the firmware of a machine boots from. Direct kernel boot images ignore the boot
+// order, unless an Override is booted.
+type BootSpec struct {
+	// Devices are tried in order. Devices the domain lacks are skipped. Empty boots from the root fs disk.
+	Devices []BootDevice `json:"devices,omitempty"`
+	// Override is booted once at the next start of the domain, before the Devices.
+	Override *BootOverride `json:"override,omitempty"`
+}
+
+type BootDeviceType string
+
+const (
+	BootDeviceTypeRootFS           BootDeviceType = "RootFS"
+	BootDeviceTypeVolume           BootDeviceType = "Volume"
+	BootDeviceTypeNetworkInterface BootDeviceType = "NetworkInterface"
+	BootDeviceTypeMedia            BootDeviceType = "Media"
+)
+
+type BootDevice struct {
+	Type BootDeviceType `json:"type"`
+	// Name is the name of the volume, network interface or media drive.
+	Name string `json:"name,omitempty"`
+}
+
+// BootOverride is booted once instead of the regular boot devices, e.g. to repair a machine from a rescue image.
+// Exactly one of Device and ISO is set.
+type BootOverride struct {
+	Device *BootDevice `json:"device,omitempty"`
+	// ISO is the file name of an ISO image in the rescue ISO directory of the provider, attached as cdrom.
+	ISO string `json:"iso,omitempty"`
+}
+
+// QueuesSpec defines the number of queues of the virtio disks and network interfaces of a machine. Zero means
+// the number is derived from the vCPU count.
+type QueuesSpec struct {
+	// Disk is the number of queues of each virtio-blk disk.
+	Disk uint `json:"disk,omitempty"`
+	// NetworkInterface is the number of queues of each virtio-net network interface.
+	NetworkInterface uint `json:"networkInterface,omitempty"`
+}
+
+// PCISpec defines the PCIe controller layout of a machine, bounding the number of hotpluggable devices until
+// the domain is restarted.
+type PCISpec struct {
+	// RootPorts is the number of pcie-root-ports.
+	RootPorts int `json:"rootPorts"`
+	// SwitchDownstreamPorts is the number of downstream ports of a pcie-switch plugged into one of the root
+	// ports. Zero means no switch.
+	SwitchDownstreamPorts int `json:"switchDownstreamPorts,omitempty"`
+}
+
+// SGXSpec defines the SGX enclave page cache (EPC) of a machine.
+type SGXSpec struct {
+	// EPCBytes is the size of the EPC in bytes.
+	EPCBytes int64 `json:"epcBytes"`
+}
+
+// HugepagesSpec defines the hugepages backing the memory of a machine.
+type HugepagesSpec struct {
+	// PageSize is the size of the pages in bytes.
+	PageSize int64 `json:"pageSize"`
+}
+
+// SMBIOSSpec defines the SMBIOS identity presented to the guest. Empty fields default to values derived
+// from the machine ID.
+type SMBIOSSpec struct {
+	Serial     string   `json:"serial,omitempty"`
+	AssetTag   string   `json:"assetTag,omitempty"`
+	OEMStrings []string `json:"oemStrings,omitempty"`
 }
 
 type GuestAgent string
@@ -38,12 +265,121 @@ const (
 	GuestAgentQemu GuestAgent = "Qemu"
 )
 
+// HostEventPolicy defines how a machine is treated while the host reports a thermal or power condition.
+type HostEventPolicy string
+
+const (
+	HostEventPolicyNone     HostEventPolicy = "None"
+	HostEventPolicyPause    HostEventPolicy = "Pause"
+	HostEventPolicyThrottle HostEventPolicy = "Throttle"
+)
+
 type MachineStatus struct {
 	VolumeStatus           []VolumeStatus           `json:"volumeStatus"`
 	NetworkInterfaceStatus []NetworkInterfaceStatus `json:"networkInterfaceStatus"`
 	State                  MachineState             `json:"state"`
 	ImageRef               string                   `json:"imageRef"`
 	GuestAgentStatus       *GuestAgentStatus        `json:"guestAgentStatus,omitempty"`
+	Conditions             []MachineCondition       `json:"conditions,omitempty"`
+
+	// ImageDigest is the manifest digest of the cached image the domain uses the root fs, kernel and initramfs
+	// of. The image isn't evicted from the cache while the machine exists.
+	ImageDigest string `json:"imageDigest,omitempty"`
+
+	Shutdown *ShutdownStatus `json:"shutdown,omitempty"`
+
+	Rescue *RescueStatus `json:"rescue,omitempty"`
+
+	PCIDevices []PCIDeviceStatus `json:"pciDevices,omitempty"`
+
+	// MachineType is the QEMU machine type the domain was started with. Restarts of the domain keep it unless
+	// the host doesn't support it anymore.
+	MachineType string `json:"machineType,omitempty"`
+
+	// Placement is the placement of the domain on the host cpus and NUMA nodes, nil if the domain isn't pinned.
+	Placement *PlacementStatus `json:"placement,omitempty"`
+
+	// DirQuota is the quota of the machine directory, nil if machine directories aren't limited.
+	DirQuota *DirQuotaStatus `json:"dirQuota,omitempty"`
+
+	// FailureReason is the reason the domain of a Failed machine can't be created.
+	FailureReason MachineFailureReason `json:"failureReason,omitempty"`
+	// FailureMessage is the error the domain of a Failed machine can't be created with.
+	FailureMessage string `json:"failureMessage,omitempty"`
+}
+
+type MachineFailureReason string
+
+const (
+	// MachineFailureReasonInvalidSpec means the spec can't be realized, e.g. it's rejected by a plugin.
+	MachineFailureReasonInvalidSpec MachineFailureReason = "InvalidSpec"
+	// MachineFailureReasonUnschedulable means the host lacks resources of the machine, e.g. free PCI devices.
+	MachineFailureReasonUnschedulable MachineFailureReason = "Unschedulable"
+	// MachineFailureReasonLibvirtError means libvirt failed repeatedly to create the domain.
+	MachineFailureReasonLibvirtError MachineFailureReason = "LibvirtError"
+	// MachineFailureReasonImageError means the image failed repeatedly to be pulled or prepared.
+	MachineFailureReasonImageError MachineFailureReason = "ImageError"
+	// MachineFailureReasonReconcileError means the creation failed repeatedly for any other reason.
+	MachineFailureReasonReconcileError MachineFailureReason = "ReconcileError"
+)
+
+// DirQuotaStatus is the project quota limiting the disk space of the machine directory, its disks, logs and
+// other files.
+type DirQuotaStatus struct {
+	// ProjectID is the id of the file system project of the machine directory.
+	ProjectID uint32 `json:"projectID"`
+	// LimitBytes is the disk space the machine directory is limited to.
+	LimitBytes int64 `json:"limitBytes"`
+	// UsedBytes is the disk space used by the machine directory when the machine was reconciled last.
+	UsedBytes int64 `json:"usedBytes"`
+}
+
+// PlacementStatus is the pinning applied to the domain of a machine. The cpu and node sets are in the libvirt
+// list format, e.g. 0-3,8.
+type PlacementStatus struct {
+	// VCPUCPUSet are the host cpus the vCPUs are pinned to, empty if the vCPUs aren't pinned.
+	VCPUCPUSet string `json:"vcpuCPUSet,omitempty"`
+	// EmulatorCPUSet are the host cpus the emulator threads are pinned to.
+	EmulatorCPUSet string `json:"emulatorCPUSet,omitempty"`
+	// IOThreadCPUSet are the host cpus any of the iothreads is pinned to.
+	IOThreadCPUSet string `json:"ioThreadCPUSet,omitempty"`
+	// NUMANodes are the host NUMA nodes the memory of the domain is allocated from.
+	NUMANodes string `json:"numaNodes,omitempty"`
+	// HugepagesNUMANodes are the host NUMA nodes the hugepages backing the memory are allocated from, empty if
+	// the memory isn't backed by hugepages.
+	HugepagesNUMANodes string `json:"hugepagesNUMANodes,omitempty"`
+}
+
+// PCIDeviceStatus is a PCI device of the host claimed by a machine. The device stays claimed until the machine
+// is deleted.
+type PCIDeviceStatus struct {
+	Pool    string `json:"pool"`
+	Address string `json:"address"`
+}
+
+// RescueStatus is the rescue image the domain of a machine was started with. It is nil if the machine booted
+// normally.
+type RescueStatus struct {
+	Image     string    `json:"image"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+type ShutdownStage string
+
+const (
+	ShutdownStageACPI       ShutdownStage = "ACPI"
+	ShutdownStageGuestAgent ShutdownStage = "GuestAgent"
+	ShutdownStageDestroy    ShutdownStage = "Destroy"
+)
+
+// ShutdownStatus is the progress of the shutdown of a deleted or powered off machine. It is persisted so the
+// shutdown continues in the same stage after a restart of the provider.
+type ShutdownStatus struct {
+	Stage ShutdownStage `json:"stage"`
+	// StageStartedAt is the time the stage started, the stage times out relative to it.
+	StageStartedAt time.Time `json:"stageStartedAt"`
+	// RequestedAt is the time the shutdown was last requested from the domain in the stage.
+	RequestedAt time.Time `json:"requestedAt,omitempty"`
 }
 
 type MachineState string
@@ -54,6 +390,9 @@ const (
 	MachineStateSuspended   MachineState = "Suspended"
 	MachineStateTerminating MachineState = "Terminating"
 	MachineStateTerminated  MachineState = "Terminated"
+	// MachineStateFailed means the domain of the machine can't be created on this host. Changing the machine
+	// retries the creation.
+	MachineStateFailed MachineState = "Failed"
 )
 
 type PowerState int32
@@ -68,6 +407,21 @@ type VolumeSpec struct {
 	Device     string            `json:"device"`
 	EmptyDisk  *EmptyDiskSpec    `json:"emptyDisk,omitempty"`
 	Connection *VolumeConnection `json:"cephDisk,omitempty"`
+
+	// Driver optionally overrides the disk driver modes the volume plugin defaults to.
+	Driver *VolumeDriverSpec `json:"driver,omitempty"`
+
+	// Shareable allows the backing volume to be attached more than once, if all of its volumes are shareable. The
+	// guests have to coordinate their writes, e.g. with a clustered file system.
+	Shareable bool `json:"shareable,omitempty"`
+}
+
+// VolumeDriverSpec configures the libvirt disk driver of a volume.
+type VolumeDriverSpec struct {
+	// Cache is the cache mode, e.g. none or writeback.
+	Cache string `json:"cache,omitempty"`
+	// IO is the asynchronous IO mode: native, threads or io_uring.
+	IO string `json:"io,omitempty"`
 }
 
 type VolumeStatus struct {
@@ -75,6 +429,35 @@ type VolumeStatus struct {
 	Handle string      `json:"handle,omitempty"`
 	State  VolumeState `json:"state,omitempty"`
 	Size   int64       `json:"size,omitempty"`
+	// Message is the error of the last attempt to attach or detach the volume, set if State is Failed.
+	Message string `json:"message,omitempty"`
+	// FailedAttempts is the number of consecutive failed attempts to attach or detach the volume.
+	FailedAttempts int `json:"failedAttempts,omitempty"`
+
+	Migration *VolumeMigrationStatus `json:"migration,omitempty"`
+}
+
+type VolumeMigrationPhase string
+
+const (
+	VolumeMigrationPhaseCopying VolumeMigrationPhase = "Copying"
+	// VolumeMigrationPhasePivoted means the domain uses the volume of the target plugin and the volume of the
+	// source plugin is being deleted.
+	VolumeMigrationPhasePivoted VolumeMigrationPhase = "Pivoted"
+	VolumeMigrationPhaseFailed  VolumeMigrationPhase = "Failed"
+)
+
+// VolumeMigrationStatus is the progress of the live migration of an attached volume to another volume plugin.
+// The status is removed once the domain switched to the volume of the target plugin.
+type VolumeMigrationStatus struct {
+	SourcePlugin string               `json:"sourcePlugin"`
+	TargetPlugin string               `json:"targetPlugin"`
+	Phase        VolumeMigrationPhase `json:"phase"`
+	StartedAt    time.Time            `json:"startedAt"`
+	CopiedBytes  int64                `json:"copiedBytes,omitempty"`
+	TotalBytes   int64                `json:"totalBytes,omitempty"`
+	FailedAt     time.Time            `json:"failedAt,omitempty"`
+	Message      string               `json:"message,omitempty"`
 }
 
 type EmptyDiskSpec struct {
@@ -94,6 +477,8 @@ type VolumeState string
 const (
 	VolumeStatePending  VolumeState = "Pending"
 	VolumeStateAttached VolumeState = "Attached"
+	// VolumeStateFailed means the volume failed to be attached or detached. It is retried with backoff.
+	VolumeStateFailed VolumeState = "Failed"
 )
 
 type NetworkInterfaceSpec struct {
@@ -101,12 +486,47 @@ type NetworkInterfaceSpec struct {
 	NetworkId  string            `json:"networkId"`
 	Ips        []string          `json:"ips"`
 	Attributes map[string]string `json:"attributes"`
+
+	// Model is the model of the emulated network interface, e.g. e1000e for guests without virtio drivers. Empty
+	// leaves the model to the hypervisor.
+	Model string `json:"model,omitempty"`
+	// MACAddress is the MAC address of the emulated network interface. Empty generates a stable address from the
+	// machine id and network interface name.
+	MACAddress string `json:"macAddress,omitempty"`
 }
 
 type NetworkInterfaceStatus struct {
 	Name   string                `json:"name"`
 	Handle string                `json:"handle"`
 	State  NetworkInterfaceState `json:"state"`
+	// MACAddress is the MAC address of the emulated network interface.
+	MACAddress string `json:"macAddress,omitempty"`
+	// Message is the error of the last attempt to attach or detach the network interface, set if State is Failed.
+	Message string `json:"message,omitempty"`
+	// FailedAttempts is the number of consecutive failed attempts to attach or detach the network interface.
+	FailedAttempts int `json:"failedAttempts,omitempty"`
+	// IPs are the addresses of the network interface reported by the guest agent or the DHCP leases.
+	IPs []string `json:"ips,omitempty"`
+	// IPFamilies are the addresses the network interface is set up with per IP family, as reported by network
+	// interface plugins managing the addresses. Dual-stack network interfaces report both families.
+	IPFamilies []NetworkInterfaceIPFamilyStatus `json:"ipFamilies,omitempty"`
+}
+
+type IPFamily string
+
+const (
+	IPFamilyIPv4 IPFamily = "IPv4"
+	IPFamilyIPv6 IPFamily = "IPv6"
+)
+
+type NetworkInterfaceIPFamilyStatus struct {
+	Family IPFamily `json:"family"`
+	// IPs are the internal addresses of the network interface in the family.
+	IPs []string `json:"ips"`
+	// PublicIPs are the public addresses of the family routed to the network interface.
+	PublicIPs []string `json:"publicIPs,omitempty"`
+	// NATIPs are the addresses of the family the network interface is NATed to.
+	NATIPs []string `json:"natIPs,omitempty"`
 }
 
 type NetworkInterfaceState string
@@ -114,6 +534,9 @@ type NetworkInterfaceState string
 const (
 	NetworkInterfaceStatePending  NetworkInterfaceState = "Pending"
 	NetworkInterfaceStateAttached NetworkInterfaceState = "Attached"
+	// NetworkInterfaceStateFailed means the network interface failed to be attached or detached. It is retried
+	// with backoff.
+	NetworkInterfaceStateFailed NetworkInterfaceState = "Failed"
 )
 
 type GuestAgentStatus struct {
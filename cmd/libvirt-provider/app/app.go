@@ -4,6 +4,7 @@
 package app
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	goflag "flag"
@@ -13,38 +14,71 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/digitalocean/go-libvirt"
 	"github.com/go-logr/logr"
-	"github.com/ironcore-dev/ironcore-image/oci/remote"
 	"github.com/ironcore-dev/ironcore/broker/common"
 	commongrpc "github.com/ironcore-dev/ironcore/broker/common/grpc"
 	iri "github.com/ironcore-dev/ironcore/iri/apis/machine/v1alpha1"
 	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/admin"
+	"github.com/ironcore-dev/libvirt-provider/internal/cgrouppolicy"
+	"github.com/ironcore-dev/libvirt-provider/internal/clockpolicy"
 	"github.com/ironcore-dev/libvirt-provider/internal/console"
+	"github.com/ironcore-dev/libvirt-provider/internal/console/hub"
 	"github.com/ironcore-dev/libvirt-provider/internal/controllers"
+	"github.com/ironcore-dev/libvirt-provider/internal/deviceprofile"
 	"github.com/ironcore-dev/libvirt-provider/internal/event"
 	"github.com/ironcore-dev/libvirt-provider/internal/event/machineevent"
+	"github.com/ironcore-dev/libvirt-provider/internal/hardware"
 	"github.com/ironcore-dev/libvirt-provider/internal/healthcheck"
 	"github.com/ironcore-dev/libvirt-provider/internal/host"
+	"github.com/ironcore-dev/libvirt-provider/internal/hostevent"
+	"github.com/ironcore-dev/libvirt-provider/internal/hugepages"
+	"github.com/ironcore-dev/libvirt-provider/internal/iricompat"
+	"github.com/ironcore-dev/libvirt-provider/internal/journal"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/drift"
 	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/guest"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/pci"
 	libvirtutils "github.com/ironcore-dev/libvirt-provider/internal/libvirt/utils"
 	"github.com/ironcore-dev/libvirt-provider/internal/mcr"
 	"github.com/ironcore-dev/libvirt-provider/internal/networkinterfaceplugin"
 	"github.com/ironcore-dev/libvirt-provider/internal/oci"
+	"github.com/ironcore-dev/libvirt-provider/internal/pcidevice"
+	providernetworkinterface "github.com/ironcore-dev/libvirt-provider/internal/plugins/networkinterface"
 	volumeplugin "github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
 	"github.com/ironcore-dev/libvirt-provider/internal/plugins/volume/ceph"
 	"github.com/ironcore-dev/libvirt-provider/internal/plugins/volume/emptydisk"
+	"github.com/ironcore-dev/libvirt-provider/internal/plugins/volume/external"
+	"github.com/ironcore-dev/libvirt-provider/internal/plugins/volume/hostdevice"
+	"github.com/ironcore-dev/libvirt-provider/internal/plugins/volume/localimage"
+	"github.com/ironcore-dev/libvirt-provider/internal/plugins/volume/lvm"
+	"github.com/ironcore-dev/libvirt-provider/internal/providerinfo"
 	"github.com/ironcore-dev/libvirt-provider/internal/qcow2"
+	"github.com/ironcore-dev/libvirt-provider/internal/quota"
 	"github.com/ironcore-dev/libvirt-provider/internal/raw"
+	"github.com/ironcore-dev/libvirt-provider/internal/retrypolicy"
+	"github.com/ironcore-dev/libvirt-provider/internal/rootfs"
 	"github.com/ironcore-dev/libvirt-provider/internal/server"
+	"github.com/ironcore-dev/libvirt-provider/internal/sgx"
+	"github.com/ironcore-dev/libvirt-provider/internal/smbios"
+	"github.com/ironcore-dev/libvirt-provider/internal/store"
 	"github.com/ironcore-dev/libvirt-provider/internal/strategy"
+	"github.com/ironcore-dev/libvirt-provider/internal/supportbundle"
+	"github.com/ironcore-dev/libvirt-provider/pkg/pluginapi"
+	"github.com/opencontainers/go-digest"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/sets"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 )
@@ -53,6 +87,24 @@ var (
 	homeDir string
 )
 
+const (
+	emptyDiskPluginFile = "file"
+	emptyDiskPluginLVM  = "lvm"
+)
+
+const (
+	machineStoreBackendDir  = "dir"
+	machineStoreBackendBolt = "bolt"
+)
+
+// Readiness conditions of the provider, see --servers-health-check-address.
+const (
+	readyGuestCapabilities = "guest-capabilities"
+	readyPlugins           = "plugins"
+	readyMachineStore      = "machine-store"
+	readyGRPCServer        = "grpc-server"
+)
+
 func init() {
 	homeDir, _ = os.UserHomeDir()
 }
@@ -62,26 +114,159 @@ type Options struct {
 	StreamingAddress string
 	BaseURL          string
 
+	IRIRejectUnknownFields bool
+	IRIRateLimits          map[string]string
+	IRIMaxInFlightCreates  int
+
 	Servers ServersOptions
 
+	HealthCheckReconcilerStallThreshold time.Duration
+
 	RootDir string
 
+	MachineStoreBackend         string
+	MachineStoreCache           bool
+	MachineStoreWatchBufferSize int
+	ResyncIntervalMachineEvents time.Duration
+
 	PathSupportedMachineClasses string
+	PathSMBIOSClassDefaults     string
+	PathDeviceClassProfiles     string
+	PathClockClassPolicies      string
+	PathCgroupClassPolicies     string
+	PathHardwareClassDefaults   string
 	ResyncIntervalVolumeSize    time.Duration
+	VolumeResizeWorkers         int
+	VolumeResizeQueueSize       int
+
+	VolumePluginHealthCheckInterval time.Duration
+	VolumePluginHealthCheckTimeout  time.Duration
+
+	ExternalVolumePluginDiscoveryInterval time.Duration
+
+	EnableHugepages    bool
+	HugepageSize       string
+	HugepageClassSizes map[string]string
+
+	SGXEPCClassSizes map[string]string
+
+	PCILayout       string
+	PCIClassLayouts map[string]string
 
-	EnableHugepages bool
+	PCIDevicePools                map[string]string
+	PCIDeviceClassClaims          map[string]string
+	GPUDevicePool                 string
+	ResyncIntervalPCIDeviceHealth time.Duration
+
+	VirtioMaxQueues       uint
+	VirtioDiskClassQueues map[string]int
+	VirtioNICClassQueues  map[string]int
+
+	RescueISODir string
+	MediaDir     string
+	RescueImage  string
+
+	ConsoleHistoryKiB int
+	ConsoleMaxClients int
+
+	CPUPinning controllers.CPUPinningOptions
+
+	SystemReservedCPU              string
+	SystemReservedMemory           string
+	SystemReservedEphemeralStorage string
+
+	RootFSEphemeralStorage  string
+	EnforceEphemeralStorage bool
+
+	MachineDirQuotas        bool
+	MachineDirQuotaOverhead string
+
+	Overcommit mcr.OvercommitRatios
 
 	GuestAgent GuestAgentOption
 
 	Libvirt   LibvirtOptions
 	NicPlugin *networkinterfaceplugin.Options
 
+	NetworkInterfaceFilters bool
+
 	GCVMGracefulShutdownTimeout    time.Duration
+	GCVMGuestAgentShutdownTimeout  time.Duration
+	GCVMShutdownResendInterval     time.Duration
+	GCWorkers                      int
 	ResyncIntervalGarbageCollector time.Duration
+	TerminatingWarningThreshold    time.Duration
+	DeviceEventTimeout             time.Duration
+	DomainDriftPolicy              string
+	MaxConcurrentRootFSCreations   int
+	RootFSMode                     string
+	PathRetryPolicies              string
+	ReconcileTimeout               time.Duration
+	ShutdownDrainTimeout           time.Duration
+	LibvirtCallTimeout             time.Duration
+
+	ReconcileSummaryFormat string
 
 	MachineEventStore machineevent.EventStoreOptions
 
-	VolumeCachePolicy string
+	VolumeCachePolicy   string
+	QuiesceVolumeDetach bool
+	LiveVolumeMigration bool
+
+	EmptyDisk EmptyDiskOptions
+
+	MachineJournalMaxEntries int
+
+	HostEvent HostEventOptions
+
+	ImageCache ImageCacheOptions
+
+	Registry RegistryOptions
+
+	// Hooks allow replacing components when running the provider programmatically. They can't be set via flags.
+	Hooks Hooks
+}
+
+// Hooks replace components of the provider. Unset hooks fall back to the components configured by the options.
+type Hooks struct {
+	// DialLibvirt establishes the libvirt connection.
+	DialLibvirt func() (*libvirt.Libvirt, error)
+	// MachineStore replaces the file based machine store.
+	MachineStore store.Store[*api.Machine]
+	// VolumePlugins modifies the volume plugins to initialize.
+	VolumePlugins func(plugins []volumeplugin.Plugin) []volumeplugin.Plugin
+	// NetworkInterfacePlugin replaces the network interface plugins configured by the options.
+	NetworkInterfacePlugin providernetworkinterface.Plugin
+}
+
+type RegistryOptions struct {
+	ConfigPaths []string
+	Mirrors     []string
+	CAFiles     []string
+}
+
+type ImageCacheOptions struct {
+	MaxBytes         int64
+	MinFreeBytes     int64
+	EvictionInterval time.Duration
+
+	SignaturePublicKeys []string
+
+	MaxConcurrentPulls int
+}
+
+type HostEventOptions struct {
+	ConditionFile   string
+	PollInterval    time.Duration
+	DefaultPolicy   string
+	ThrottlePercent int
+}
+
+type EmptyDiskOptions struct {
+	Plugin string
+
+	LVMVolumeGroup string
+	LVMThinPool    string
 }
 
 type HTTPServerOptions struct {
@@ -90,8 +275,11 @@ type HTTPServerOptions struct {
 }
 
 type ServersOptions struct {
-	Metrics     HTTPServerOptions
-	HealthCheck HTTPServerOptions
+	Metrics       HTTPServerOptions
+	HealthCheck   HTTPServerOptions
+	SupportBundle HTTPServerOptions
+	Admin         HTTPServerOptions
+	ProviderInfo  HTTPServerOptions
 }
 
 type LibvirtOptions struct {
@@ -99,6 +287,14 @@ type LibvirtOptions struct {
 	Address string
 	URI     string
 
+	// Connections is the number of connections the machine reconciles call libvirt with. Events are always
+	// received on a dedicated connection.
+	Connections             int
+	ConnectionCheckInterval time.Duration
+	// ConnectionGracePeriod is the time less than a quorum of the pooled connections may be connected before the
+	// liveness check fails.
+	ConnectionGracePeriod time.Duration
+
 	PreferredDomainTypes  []string
 	PreferredMachineTypes []string
 
@@ -107,10 +303,27 @@ type LibvirtOptions struct {
 
 func (o *Options) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&o.Address, "address", "/var/run/iri-machinebroker.sock", "Address to listen on.")
+	fs.BoolVar(&o.IRIRejectUnknownFields, "iri-reject-unknown-fields", false, "Reject IRI requests containing fields unknown to the provider instead of logging and dropping them.")
+	fs.StringToStringVar(&o.IRIRateLimits, "iri-rate-limits", nil, "Rate limits of IRI methods in requests per second with an optional burst, e.g. CreateMachine=2:5,ListMachines=20. Requests exceeding them are rejected with ResourceExhausted.")
+	fs.IntVar(&o.IRIMaxInFlightCreates, "iri-max-inflight-creates", 0, "Maximum number of CreateMachine requests processed at once. Further requests are rejected with ResourceExhausted. 0 disables the limit.")
 	fs.StringVar(&o.RootDir, "libvirt-provider-dir", filepath.Join(homeDir, ".libvirt-provider"), "Path to the directory libvirt-provider manages its content at.")
+	fs.IntVar(&o.MachineStoreWatchBufferSize, "machine-store-watch-buffer-size", host.DefaultWatchBufferSize, "Number of machine store events buffered for the machine reconciler. Events exceeding it are dropped, counted by the libvirt_provider_store_watch_events_dropped_total metric and trigger a relist of all machines.")
+	fs.DurationVar(&o.ResyncIntervalMachineEvents, "machine-events-resync-interval", 1*time.Hour, "Interval to list all machines and enqueue them for reconciliation, independent of machine store events.")
+	fs.StringVar(&o.MachineStoreBackend, "machine-store-backend", machineStoreBackendDir, fmt.Sprintf("Backend persisting the machine store. %q stores a file per machine, %q a single bbolt database with transactional writes. Existing machines are moved with the store migrate command. Available: %v", machineStoreBackendDir, machineStoreBackendBolt, []string{machineStoreBackendDir, machineStoreBackendBolt}))
+	fs.BoolVar(&o.MachineStoreCache, "machine-store-cache", true, "Keep all machines of the machine store in memory, so reads and lists don't load them from the backend.")
 
 	fs.StringVar(&o.PathSupportedMachineClasses, "supported-machine-classes", o.PathSupportedMachineClasses, "File containing supported machine classes.")
+	fs.StringVar(&o.PathSMBIOSClassDefaults, "smbios-class-defaults", "", "File containing SMBIOS serial, asset tag and OEM string defaults per machine class name. Machines override them via annotation. If not set, serial and asset tag default to the machine ID.")
+	fs.StringVar(&o.PathDeviceClassProfiles, "device-class-profiles", "", "File containing the auxiliary devices per machine class name: rng rate, memory balloon, input devices and video model. Machines of other classes get a virtio rng only.")
+	fs.StringVar(&o.PathClockClassPolicies, "clock-class-policies", "", "File containing the clock policy per machine class name: rtc offset (utc or localtime), kvmclock, hpet and Hyper-V reference clock. Machines of other classes get a utc clock.")
+	fs.StringVar(&o.PathCgroupClassPolicies, "cgroup-class-policies", "", "File containing the cgroup partition of the QEMU processes per machine class name, with their cpu shares, io weight and memory soft limit. Machines of other classes run in the default libvirt partition.")
+	fs.StringVar(&o.PathHardwareClassDefaults, "hardware-class-defaults", "", "File containing the pinned machine type, firmware loader and nvram template per machine class name. Machines override them via annotation. If not set, domains keep the machine type they were first started with.")
 	fs.DurationVar(&o.ResyncIntervalVolumeSize, "volume-size-resync-interval", 1*time.Minute, "Interval to determine volume size changes.")
+	fs.IntVar(&o.VolumeResizeWorkers, "volume-resize-workers", controllers.DefaultResizeWorkers, "Number of workers resizing volumes. Resizes are processed with lower priority than machine reconciles.")
+	fs.IntVar(&o.VolumeResizeQueueSize, "volume-resize-queue-size", controllers.DefaultResizeQueueSize, "Maximum number of pending volume resizes. Further resizes are deferred to the next volume size resync.")
+	fs.DurationVar(&o.VolumePluginHealthCheckInterval, "volume-plugin-health-check-interval", 30*time.Second, "Interval to check the health of the volume plugins. Volumes of unhealthy plugins aren't attached until the plugin is healthy again.")
+	fs.DurationVar(&o.VolumePluginHealthCheckTimeout, "volume-plugin-health-check-timeout", 5*time.Second, "Timeout of a health check of a volume plugin.")
+	fs.DurationVar(&o.ExternalVolumePluginDiscoveryInterval, "external-volume-plugin-discovery-interval", 30*time.Second, "Interval to discover external volume plugins coming up after the start of the provider.")
 
 	fs.StringVar(&o.StreamingAddress, "streaming-address", ":20251", "Address to run the streaming server on")
 	fs.StringVar(&o.BaseURL, "base-url", "", "The base url to construct urls for streaming from. If empty it will be "+
@@ -119,16 +332,63 @@ func (o *Options) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&o.Servers.Metrics.Addr, "servers-metrics-address", "", "Address to listen on exposing of metrics. If address isn't set, server is disabled.")
 	fs.DurationVar(&o.Servers.Metrics.GracefulTimeout, "servers-metrics-gracefultimeout", 2*time.Second, "Graceful timeout for shutdown metrics server.")
 
-	fs.StringVar(&o.Servers.HealthCheck.Addr, "servers-health-check-address", ":8181", "Address to listen on health check liveness.")
+	fs.StringVar(&o.Servers.HealthCheck.Addr, "servers-health-check-address", ":8181", "Address to listen on health check liveness (/healthz) and readiness (/readyz).")
 	fs.DurationVar(&o.Servers.HealthCheck.GracefulTimeout, "servers-health-check-gracefultimeout", 2*time.Second, "Graceful timeout for shutdown health check server.")
+	fs.DurationVar(&o.HealthCheckReconcilerStallThreshold, "health-check-reconciler-stall-threshold", 5*time.Minute, "Time the machine reconciler may go without a successful reconcile while machines are pending before the readiness check fails.")
+
+	fs.StringVar(&o.Servers.SupportBundle.Addr, "servers-support-bundle-address", "", "Address to listen on serving machine support bundles. If address isn't set, server is disabled.")
+	fs.DurationVar(&o.Servers.SupportBundle.GracefulTimeout, "servers-support-bundle-gracefultimeout", 2*time.Second, "Graceful timeout for shutdown support bundle server.")
+
+	fs.StringVar(&o.Servers.Admin.Addr, "servers-admin-address", defaultAdminAddress(), "Address to listen on serving administrative actions like force finalizing machines or pre-pulling images, used by the maintenance commands. Unix sockets are addressed as unix:///path/to/socket. If address is set to empty, server is disabled.")
+	fs.DurationVar(&o.Servers.Admin.GracefulTimeout, "servers-admin-gracefultimeout", 2*time.Second, "Graceful timeout for shutdown admin server.")
+
+	fs.StringVar(&o.Servers.ProviderInfo.Addr, "servers-provider-info-address", "", "Address to listen on serving the provider info via gRPC, see the providerinfo package. Unix sockets are addressed as unix:///path/to/socket. If address isn't set, server is disabled.")
+	fs.DurationVar(&o.Servers.ProviderInfo.GracefulTimeout, "servers-provider-info-gracefultimeout", 2*time.Second, "Graceful timeout for shutdown provider info server.")
 
 	fs.BoolVar(&o.EnableHugepages, "enable-hugepages", false, "Enable using Hugepages.")
+	fs.StringVar(&o.HugepageSize, "hugepage-size", "2Mi", "Hugepage size of machines of classes not listed in --hugepage-class-sizes. Available: [2Mi 1Gi]")
+	fs.StringToStringVar(&o.HugepageClassSizes, "hugepage-class-sizes", nil, "Hugepage sizes per machine class name, e.g. x3-xlarge=1Gi.")
+	fs.StringToStringVar(&o.SGXEPCClassSizes, "sgx-epc-class-sizes", nil, "SGX enclave page cache sizes per machine class name, e.g. x3-xlarge-sgx=64Mi. Machines of other classes have no enclave page cache.")
+
+	fs.StringVar(&o.PCILayout, "pci-layout", strconv.Itoa(controllers.DefaultPCIRootPorts), "PCIe controller layout of new domains in the form <root-ports>[+<switch-downstream-ports>], e.g. 16+32 for 16 root ports, one of which hosts a pcie-switch with 32 downstream ports. Bounds the number of hotpluggable devices until a domain is restarted.")
+	fs.StringToStringVar(&o.PCIClassLayouts, "pci-class-layouts", nil, "PCIe controller layouts per machine class name, e.g. x3-xlarge-gpu=8+32. Machines of other classes use the --pci-layout.")
+	fs.StringToStringVar(&o.PCIDevicePools, "pci-device-pools", nil, "Pools of PCI devices passed through to machines, selected by address or vendor and device id separated by +, e.g. fpga=0000:3b:00.0+0000:3c:00.0,nvme=144d:a80a. The devices are bound to vfio-pci while they are claimed.")
+	fs.StringToStringVar(&o.PCIDeviceClassClaims, "pci-device-class-claims", nil, "PCI device pools claimed by the machines per machine class name with an optional count separated by +, e.g. x3-xlarge-fpga=fpga+nvme:2.")
+	fs.DurationVar(&o.ResyncIntervalPCIDeviceHealth, "pci-device-health-check-interval", 1*time.Minute, "Interval to check the health of the pci devices passed through to running machines: present on the bus, bound to vfio-pci and free of fatal pcie errors. Unhealthy devices aren't claimed and machines get an event. 0 disables the checks.")
+	fs.StringVar(&o.GPUDevicePool, "gpu-device-pool", "", fmt.Sprintf("PCI device pool providing the %s extended resource of machine classes. Machines of classes requesting gpus claim as many devices of the pool.", mcr.ResourceNvidiaGPU))
+	fs.UintVar(&o.VirtioMaxQueues, "virtio-max-queues", 0, "Maximum number of queues of virtio disks and network interfaces, derived from the vCPU count of the machine. Multi-queue network interfaces use the virtio model with vhost. 0 disables multi-queue.")
+	fs.StringToIntVar(&o.VirtioDiskClassQueues, "virtio-disk-class-queues", nil, "Number of queues of virtio disks per machine class name, e.g. x3-xlarge=8. Overrides the count derived via --virtio-max-queues.")
+	fs.StringToIntVar(&o.VirtioNICClassQueues, "virtio-nic-class-queues", nil, "Number of queues of virtio network interfaces per machine class name, e.g. x3-xlarge=8. Overrides the count derived via --virtio-max-queues.")
+
+	fs.StringVar(&o.RescueISODir, "rescue-iso-dir", "", "Directory of the rescue ISO images machines can boot once via a boot override. Empty disables ISO boot overrides.")
+	fs.StringVar(&o.RescueImage, "rescue-image", "", "Reference of the direct kernel boot image rescued machines boot unless they specify another one. Its root fs is attached with the serial of regular root fs disks, the root fs disk of the machine with the serial machinerootfs.")
+	fs.StringVar(&o.MediaDir, "media-dir", "", "Directory of the ISO images inserted into the media drives of machines by path. Empty disables media by path.")
+
+	fs.IntVar(&o.ConsoleHistoryKiB, "console-history-kib", hub.DefaultHistorySize/1024, "KiB of serial console output retained per machine and shown to newly attached console clients.")
+	fs.IntVar(&o.ConsoleMaxClients, "console-max-clients", hub.DefaultMaxClients, "Maximum number of clients attached to the serial console of a machine. The first client controls the console, the others view it read-only.")
+
+	fs.StringVar(&o.CPUPinning.EmulatorCPUSet, "emulator-cpuset", "", "Reserved host cpus to pin the emulator threads of machines to, e.g. 0-1. If not set, emulator threads aren't pinned.")
+	fs.UintVar(&o.CPUPinning.IOThreads, "iothreads", 0, "Number of iothreads of each machine, shared round-robin by its virtio disks. 0 disables iothreads.")
+	fs.StringVar(&o.CPUPinning.IOThreadCPUSet, "iothread-cpuset", "", "Reserved host cpus to pin the iothreads of machines to, e.g. 2-3. If not set, iothreads aren't pinned.")
+	fs.UintVar(&o.CPUPinning.DedicatedIOThreads, "dedicated-iothreads", 0, "Maximum number of iothreads of each machine dedicated to a single virtio volume, e.g. for IO heavy ceph volumes. Volumes exceeding it share the --iothreads. 0 disables dedicated iothreads.")
+	fs.StringVar(&o.SystemReservedCPU, "system-reserved-cpu", "", "Host cpus reserved for the hypervisor and system daemons, e.g. 2 or 1500m. They are excluded from the cpus available to machines.")
+	fs.StringVar(&o.SystemReservedMemory, "system-reserved-memory", "", "Host memory reserved for the hypervisor and system daemons, e.g. 4Gi. It is excluded from the memory available to machines not backed by hugepages.")
+	fs.StringVar(&o.SystemReservedEphemeralStorage, "system-reserved-ephemeral-storage", "", "Disk space of the machines directory file system kept free for the host, e.g. 20Gi. It is excluded from the ephemeral storage available to machines.")
+	fs.StringVar(&o.RootFSEphemeralStorage, "rootfs-ephemeral-storage", "", "Disk space accounted for the root fs of machines copying or overlaying the root fs of their image, e.g. 10Gi. Empty disks are accounted with their size.")
+	fs.BoolVar(&o.EnforceEphemeralStorage, "enforce-ephemeral-storage", false, "Reject machines and volumes whose empty disks and root fs exceed the ephemeral storage available with ResourceExhausted.")
+	fs.BoolVar(&o.MachineDirQuotas, "machine-dir-quotas", false, "Limit the disk space of each machine directory with a project quota to the ephemeral storage of the machine plus --machine-dir-quota-overhead. Requires --rootfs-ephemeral-storage and the machines directory to be on a XFS or ext4 file system with project quotas enabled.")
+	fs.StringVar(&o.MachineDirQuotaOverhead, "machine-dir-quota-overhead", "1Gi", "Disk space of each machine directory on top of its ephemeral storage, for logs, ignitions and other files.")
+	fs.Float64Var(&o.Overcommit.Cpu, "cpu-overcommit-ratio", 1, "Number of machine cpus per host cpu, e.g. 4 for 4:1, used when computing the available machine class quantities.")
+	fs.Float64Var(&o.Overcommit.Mem, "memory-overcommit-ratio", 1, "Number of bytes of machine memory per byte of host memory, used when computing the available machine class quantities. Hugepage backed memory is never overcommitted.")
 	fs.Var(&o.GuestAgent, "guest-agent-type", fmt.Sprintf("Guest agent implementation to use. Available: %v", guestAgentOptionAvailable()))
 
 	// LibvirtOptions
-	fs.StringVar(&o.Libvirt.Socket, "libvirt-socket", o.Libvirt.Socket, "Path to the libvirt socket to use.")
+	fs.StringVar(&o.Libvirt.Socket, "libvirt-socket", o.Libvirt.Socket, "Path to the libvirt socket to use. If neither socket nor address is set, the libvirt-sock of the system and session daemons is probed, the socket of the modular virtqemud only as a last resort.")
 	fs.StringVar(&o.Libvirt.Address, "libvirt-address", o.Libvirt.Address, "Address of a RPC libvirt socket to connect to.")
-	fs.StringVar(&o.Libvirt.URI, "libvirt-uri", o.Libvirt.URI, "URI to connect to inside the libvirt system.")
+	fs.StringVar(&o.Libvirt.URI, "libvirt-uri", o.Libvirt.URI, "URI to connect to inside the libvirt system. Remote hosts can be managed via qemu+ssh://user@host/system (SSH key auth, parameters keyfile, known_hosts, socket) or qemu+tls://host/system (client certificates, parameter pkipath) URIs. A remote host has to see the --libvirt-provider-dir at the same path, e.g. via a shared file system, as domains reference the disk, ignition and root fs files by their local paths.")
+	fs.IntVar(&o.Libvirt.Connections, "libvirt-connections", 1, "Number of libvirt connections the machine reconcile workers call libvirt with round-robin. Values above 1, or a --libvirt-call-timeout, dial a pool in addition to the connection receiving libvirt events.")
+	fs.DurationVar(&o.Libvirt.ConnectionCheckInterval, "libvirt-connection-check-interval", 10*time.Second, "Interval to check the pooled libvirt connections and redial disconnected ones.")
+	fs.DurationVar(&o.Libvirt.ConnectionGracePeriod, "libvirt-connection-grace-period", time.Minute, "Time less than a majority of the pooled libvirt connections may be connected before the liveness check fails.")
 
 	// Guest Capabilities
 	fs.StringSliceVar(&o.Libvirt.PreferredDomainTypes, "preferred-domain-types", []string{"kvm", "qemu"}, "Ordered list of preferred domain types to use.")
@@ -136,22 +396,64 @@ func (o *Options) AddFlags(fs *pflag.FlagSet) {
 
 	fs.StringVar(&o.Libvirt.Qcow2Type, "qcow2-type", qcow2.Default(), fmt.Sprintf("qcow2 implementation to use. Available: %v", qcow2.Available()))
 
-	fs.DurationVar(&o.GCVMGracefulShutdownTimeout, "gc-vm-graceful-shutdown-timeout", 5*time.Minute, "Duration to wait for the VM to gracefully shut down. If the VM does not shut down within this period, it will be forcibly destroyed by garbage collector.")
+	fs.DurationVar(&o.GCVMGracefulShutdownTimeout, "gc-vm-graceful-shutdown-timeout", 5*time.Minute, "Duration to wait for the VM to shut down after an ACPI shutdown request. If the VM does not shut down within this period, the shutdown escalates to the guest agent or the VM is forcibly destroyed by garbage collector.")
+	fs.DurationVar(&o.GCVMGuestAgentShutdownTimeout, "gc-vm-guest-agent-shutdown-timeout", 1*time.Minute, "Duration to wait for VMs with qemu guest agent to shut down after a guest agent shutdown request, following the ACPI shutdown. 0 skips the guest agent shutdown.")
+	fs.DurationVar(&o.GCVMShutdownResendInterval, "gc-vm-shutdown-resend-interval", 1*time.Minute, "Interval to repeat the shutdown request of a VM that hasn't shut down yet, in case the VM missed it. 0 sends the request once per shutdown stage.")
 	fs.DurationVar(&o.ResyncIntervalGarbageCollector, "gc-resync-interval", 1*time.Minute, "Interval for resynchronizing the garbage collector.")
+	fs.IntVar(&o.GCWorkers, "gc-workers", controllers.DefaultGCWorkers, "Number of workers processing machine deletions in parallel.")
+	fs.DurationVar(&o.TerminatingWarningThreshold, "terminating-warning-threshold", 30*time.Minute, "Duration after which a machine stuck in terminating is reported by an event. Machines can only be force finalized after this duration.")
+	fs.DurationVar(&o.DeviceEventTimeout, "device-event-timeout", controllers.DefaultDeviceEventTimeout, "Duration to wait for libvirt to confirm a device attachment or detachment by a device event. Unconfirmed detachments are retried after this duration, volumes and network interfaces are only released once their removal is confirmed.")
+	fs.StringVar(&o.DomainDriftPolicy, "domain-drift-policy", string(drift.PolicyReport), fmt.Sprintf("Policy for changes made to running domains outside the provider, e.g. devices attached via virsh or changed vcpus and memory. Report sets the DomainDrifted machine condition and emits an event, Revert additionally detaches the devices and restores the vcpus and memory. Available: %v", drift.Policies))
+	fs.IntVar(&o.MaxConcurrentRootFSCreations, "max-concurrent-rootfs-creations", 0, "Maximum number of root fs disks created from images at once. Machines exceeding it wait with the RootFSQueued condition reason. 0 disables the limit.")
+	fs.StringVar(&o.RootFSMode, "rootfs-mode", string(api.RootFSModeCopy), fmt.Sprintf("Root fs mode of machines not selecting one via annotation. Copy copies the cached image root fs per machine, Shared attaches the cached root fs read-only (direct kernel boot images only), Overlay creates a qcow2 overlay backed by it. Available: %v", rootfs.Modes))
+	fs.StringVar(&o.PathRetryPolicies, "reconcile-retry-policies", "", fmt.Sprintf("File containing the retry policy of failed machine reconciles per error class: base delay, max delay and max retries after which the machine is marked failed. Permanent errors are never retried. Available classes: %v", retrypolicy.Classes))
+	fs.DurationVar(&o.ReconcileTimeout, "reconcile-timeout", 10*time.Minute, "Maximum duration of a machine reconcile. Reconciles exceeding it fail with a Timeout error and are retried. 0 disables the limit.")
+	fs.DurationVar(&o.ShutdownDrainTimeout, "shutdown-drain-timeout", 1*time.Minute, "Maximum duration to finish in-flight machine reconciles, garbage collections, volume resizes and migrations on shutdown. Machines still queued are persisted and reconciled first after the next start. Work exceeding it is interrupted. 0 interrupts it right away.")
+	fs.DurationVar(&o.LibvirtCallTimeout, "libvirt-call-timeout", 2*time.Minute, "Maximum duration of libvirt calls creating, shutting down and destroying domains and attaching or detaching devices, so a hung libvirt daemon doesn't block the reconcile and garbage collection workers. Calls exceeding it abort their connection, hence they use a pool of --libvirt-connections connections separate from the connection receiving libvirt events. 0 disables the limit.")
+	fs.StringVar(&o.ReconcileSummaryFormat, "reconcile-summary-format", string(controllers.ReconcileSummaryFormatText), fmt.Sprintf("Format of the summary logged once per machine reconcile with its phase timings. Available: %v", []controllers.ReconcileSummaryFormat{controllers.ReconcileSummaryFormatText, controllers.ReconcileSummaryFormatJSON}))
 
 	// Machine event store options
 	fs.IntVar(&o.MachineEventStore.MachineEventMaxEvents, "machine-event-max-events", 100, "Maximum number of machine events that can be stored.")
 	fs.DurationVar(&o.MachineEventStore.MachineEventTTL, "machine-event-ttl", 5*time.Minute, "Time to live for machine events.")
 	fs.DurationVar(&o.MachineEventStore.MachineEventResyncInterval, "machine-event-resync-interval", 1*time.Minute, "Interval for resynchronizing the machine events.")
 
+	fs.IntVar(&o.MachineJournalMaxEntries, "machine-journal-max-entries", journal.DefaultMaxEntries, "Maximum number of operations kept in the journal of a machine.")
+
 	// Volume cache policy option
 	fs.StringVar(&o.VolumeCachePolicy, "volume-cache-policy", "none",
-		`Policy to use when creating a remote disk. (one of 'none', 'writeback', 'writethrough', 'directsync', 'unsafe').
+		`Default cache mode of ceph disks (one of 'none', 'writeback', 'writethrough', 'directsync', 'unsafe').
+Volumes override the cache and io modes of their plugin via the 'cache' and 'io' connection attributes.
 Note: The available options may depend on the hypervisor and libvirt version in use. 
 Please refer to the official documentation for more details: https://libvirt.org/formatdomain.html#hard-drives-floppy-disks-cdroms.`)
+	fs.BoolVar(&o.QuiesceVolumeDetach, "quiesce-volume-detach", false, "Quiesce volumes of running machines before detaching them because their volume plugin changed: wait for block jobs of the volume to complete and freeze the guest file systems via the qemu guest agent while detaching, at most for the device event timeout.")
+	fs.BoolVar(&o.LiveVolumeMigration, "live-volume-migration", false, "Copy the data of attached volumes whose volume plugin changed to the volume of the new plugin while the machine keeps running, e.g. from a local qcow2 file to ceph. The progress is reported in the volume status.")
+
+	// Empty disk options
+	fs.StringVar(&o.EmptyDisk.Plugin, "empty-disk-plugin", emptyDiskPluginFile, fmt.Sprintf("Volume plugin to provision empty disks with. Available: %v", []string{emptyDiskPluginFile, emptyDiskPluginLVM}))
+	fs.StringVar(&o.EmptyDisk.LVMVolumeGroup, "lvm-volume-group", "", "LVM volume group containing the thin pool for empty disks.")
+	fs.StringVar(&o.EmptyDisk.LVMThinPool, "lvm-thin-pool", "", "LVM thin pool to provision empty disks from.")
+
+	// Host event options
+	fs.StringVar(&o.HostEvent.ConditionFile, "host-event-condition-file", "", fmt.Sprintf("File reporting the host power / thermal condition. Available conditions: %v. If not set, host events are not handled.", []hostevent.Condition{hostevent.ConditionNormal, hostevent.ConditionThermal, hostevent.ConditionPower}))
+	fs.DurationVar(&o.HostEvent.PollInterval, "host-event-poll-interval", hostevent.DefaultPollInterval, "Interval to poll the host condition.")
+	fs.StringVar(&o.HostEvent.DefaultPolicy, "host-event-default-policy", string(api.HostEventPolicyNone), fmt.Sprintf("Policy for machines not specifying one via annotation. Available: %v", []api.HostEventPolicy{api.HostEventPolicyNone, api.HostEventPolicyPause, api.HostEventPolicyThrottle}))
+	fs.IntVar(&o.HostEvent.ThrottlePercent, "host-event-throttle-percent", hostevent.DefaultThrottlePercent, "Percentage of cpu bandwidth left to throttled machines.")
+
+	// Image cache options
+	fs.Int64Var(&o.ImageCache.MaxBytes, "image-cache-max-bytes", 0, "Maximum size of the image cache in bytes. Least recently used images not referenced by any machine are evicted above it. 0 disables the limit.")
+	fs.Int64Var(&o.ImageCache.MinFreeBytes, "image-cache-min-free-bytes", 0, "Minimum free space in bytes on the file system of the image cache. Least recently used images not referenced by any machine are evicted below it. 0 disables the limit.")
+	fs.DurationVar(&o.ImageCache.EvictionInterval, "image-cache-eviction-interval", oci.DefaultEvictionInterval, "Interval to check the image cache limits.")
+	fs.StringSliceVar(&o.ImageCache.SignaturePublicKeys, "image-signature-public-key", nil, "Paths to PEM encoded public keys to verify cosign signatures of images with before they are used. Unsigned images or images not signed by any of the keys are rejected. If not set, signatures are not verified.")
+	fs.IntVar(&o.ImageCache.MaxConcurrentPulls, "image-max-concurrent-pulls", 0, "Maximum number of images pulled at once. Further pulls are queued. 0 disables the limit.")
+
+	// Registry options
+	fs.StringSliceVar(&o.Registry.ConfigPaths, "registry-config", nil, "Paths to docker config.json files holding registry credentials. Credentials are selected by registry host. If not set, the default docker config is used.")
+	fs.StringArrayVar(&o.Registry.Mirrors, "registry-mirror", nil, "Mirrors for a registry in the form <registry>=<mirror>[,<mirror>...], tried in order before the registry itself. Mirrors may be prefixed with http:// or https:// and may have a path, /v2 is appended unless the path ends with it. Can be specified multiple times.")
+	fs.StringSliceVar(&o.Registry.CAFiles, "registry-ca-file", nil, "Paths to PEM encoded CA bundles trusted for registries in addition to the system roots.")
 
 	o.NicPlugin = networkinterfaceplugin.NewDefaultOptions()
 	o.NicPlugin.AddFlags(fs)
+	fs.BoolVar(&o.NetworkInterfaceFilters, "network-interface-filters", false, "Apply libvirt nwfilters (clean-traffic) to network interfaces of provider networks, preventing them from spoofing MAC and IPv4 addresses other than their own.")
 }
 
 func (o *Options) MarkFlagsRequired(cmd *cobra.Command) {
@@ -187,6 +489,8 @@ func Command() *cobra.Command {
 	opts.AddFlags(cmd.Flags())
 	opts.MarkFlagsRequired(cmd)
 
+	cmd.AddCommand(maintenanceCommands()...)
+
 	return cmd
 }
 
@@ -194,8 +498,15 @@ func Run(ctx context.Context, opts Options) error {
 	log := ctrl.LoggerFrom(ctx)
 	setupLog := log.WithName("setup")
 
+	dialLibvirt := opts.Hooks.DialLibvirt
+	if dialLibvirt == nil {
+		dialLibvirt = func() (*libvirt.Libvirt, error) {
+			return libvirtutils.GetLibvirt(opts.Libvirt.Socket, opts.Libvirt.Address, opts.Libvirt.URI)
+		}
+	}
+
 	// Setup Libvirt Client
-	libvirt, err := libvirtutils.GetLibvirt(opts.Libvirt.Socket, opts.Libvirt.Address, opts.Libvirt.URI)
+	libvirt, err := dialLibvirt()
 	if err != nil {
 		setupLog.Error(err, "failed to initialize libvirt")
 		return err
@@ -206,6 +517,59 @@ func Run(ctx context.Context, opts Options) error {
 		}
 	}()
 
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	g, ctx := errgroup.WithContext(ctx)
+
+	// Shutting down stops accepting new work once ctx is done. The machine reconciler, the libvirt connections it
+	// uses and the health check server keep running on runCtx until the in-flight reconciles are drained.
+	runCtx, stopRun := context.WithCancel(context.WithoutCancel(ctx))
+	defer stopRun()
+
+	var libvirtPool *libvirtutils.Pool
+	if opts.Libvirt.Connections > 1 || opts.LibvirtCallTimeout > 0 {
+		if opts.Libvirt.ConnectionCheckInterval <= 0 {
+			return fmt.Errorf("libvirt connection check interval must be positive")
+		}
+
+		setupLog.V(1).Info("Dialing libvirt connection pool", "Connections", opts.Libvirt.Connections)
+		libvirtPool, err = libvirtutils.NewPool(opts.Libvirt.Connections, dialLibvirt)
+		if err != nil {
+			setupLog.Error(err, "failed to initialize libvirt connection pool")
+			return err
+		}
+		defer func() {
+			if err := libvirtPool.Close(); err != nil {
+				setupLog.Error(err, "failed to close libvirt connection pool")
+			}
+		}()
+		g.Go(func() error {
+			libvirtPool.Run(runCtx, log.WithName("libvirt-pool"), opts.Libvirt.ConnectionCheckInterval)
+			return nil
+		})
+	}
+
+	// The health check server is started before setting up the provider, so orchestrators can tell a
+	// starting provider from a broken one. Probes are added as their components are set up.
+	healthCheck := &healthcheck.HealthCheck{
+		Libvirt: libvirt,
+		Log:     log.WithName("health-check"),
+	}
+	readiness := healthcheck.NewReadiness(log.WithName("readiness"),
+		readyGuestCapabilities,
+		readyPlugins,
+		readyMachineStore,
+		readyGRPCServer,
+	)
+	g.Go(func() error {
+		setupLog.Info("Starting health check server")
+		if err := runHealthCheckServer(runCtx, setupLog, healthCheck, readiness, opts.Servers.HealthCheck); err != nil {
+			setupLog.Error(err, "failed to start health check server")
+			return err
+		}
+		return nil
+	})
+
 	baseURL := opts.BaseURL
 	if baseURL == "" {
 		u := &url.URL{
@@ -221,13 +585,96 @@ func Run(ctx context.Context, opts Options) error {
 		return err
 	}
 
-	reg, err := remote.DockerRegistry(nil)
+	registryMirrors, err := oci.ParseMirrors(opts.Registry.Mirrors)
+	if err != nil {
+		setupLog.Error(err, "failed to parse registry mirrors")
+		return err
+	}
+
+	reg, err := oci.NewRegistry(oci.RegistryOptions{
+		ConfigPaths: opts.Registry.ConfigPaths,
+		Mirrors:     registryMirrors,
+		CAFiles:     opts.Registry.CAFiles,
+	})
 	if err != nil {
 		setupLog.Error(err, "failed to initialize registry")
 		return err
 	}
 
-	imgCache, err := oci.NewLocalCache(log, reg, providerHost.OCIStore())
+	eventStore := machineevent.NewEventStore(log, opts.MachineEventStore)
+
+	machineStore := opts.Hooks.MachineStore
+	if machineStore == nil {
+		setupLog.Info("Configuring machine store", "Backend", opts.MachineStoreBackend, "Directory", providerHost.StoreDir())
+		backend, closeBackend, err := openMachineStoreBackend(opts.MachineStoreBackend, providerHost)
+		if err != nil {
+			setupLog.Error(err, "failed to open machine store backend")
+			return err
+		}
+		defer func() {
+			if err := closeBackend(); err != nil {
+				setupLog.Error(err, "failed to close machine store backend")
+			}
+		}()
+		if err := checkMachineStoreMigrated(backend, providerHost); err != nil {
+			setupLog.Error(err, "failed to initialize machine store")
+			return err
+		}
+		if opts.MachineStoreCache {
+			if backend, err = host.NewCachingBackend(backend); err != nil {
+				setupLog.Error(err, "failed to load machine store into memory")
+				return err
+			}
+		}
+
+		machineStore, err = host.NewStore(host.Options[*api.Machine]{
+			NewFunc:         func() *api.Machine { return &api.Machine{} },
+			CreateStrategy:  strategy.MachineStrategy,
+			Backend:         backend,
+			WatchBufferSize: opts.MachineStoreWatchBufferSize,
+			QuarantineDir:   providerHost.MachineStoreQuarantineDir(),
+			Recover: func(id string) (*api.Machine, error) {
+				return controllers.RecoverMachine(libvirt, providerHost, id)
+			},
+			OnCorrupt: func(corruption host.Corruption[*api.Machine]) {
+				storeLog := log.WithName("machine-store").WithValues("machineID", corruption.ID, "QuarantineFile", corruption.QuarantineFile)
+				if corruption.RecoverErr != nil {
+					storeLog.Error(corruption.Err, "Quarantined corrupt machine, recovery failed", "RecoverError", corruption.RecoverErr.Error())
+					return
+				}
+				storeLog.Error(corruption.Err, "Quarantined corrupt machine, recovered it from its domain")
+				eventStore.Eventf(storeLog, corruption.Recovered.Metadata, corev1.EventTypeWarning, "RecoveredCorruptMachine", "Machine store object was corrupt and recovered from the domain: %s", corruption.Err)
+			},
+		})
+		if err != nil {
+			setupLog.Error(err, "failed to initialize machine store")
+			return err
+		}
+	}
+	readiness.SetReady(readyMachineStore, true)
+
+	var imgVerifier oci.SignatureVerifier
+	if len(opts.ImageCache.SignaturePublicKeys) > 0 {
+		imgVerifier, err = oci.NewCosignVerifier(opts.ImageCache.SignaturePublicKeys)
+		if err != nil {
+			setupLog.Error(err, "failed to initialize image signature verifier")
+			return err
+		}
+	}
+
+	imgCache, err := oci.NewLocalCache(log, reg, providerHost.OCIStore(), oci.LocalCacheOptions{
+		Eviction: oci.EvictionOptions{
+			MaxBytes:     opts.ImageCache.MaxBytes,
+			MinFreeBytes: opts.ImageCache.MinFreeBytes,
+			Interval:     opts.ImageCache.EvictionInterval,
+			Dir:          providerHost.ImagesDir(),
+			References: func(ctx context.Context) (oci.ImageReferences, error) {
+				return machineImageReferences(ctx, machineStore, opts.RescueImage)
+			},
+		},
+		Verifier:           imgVerifier,
+		MaxConcurrentPulls: opts.ImageCache.MaxConcurrentPulls,
+	})
 	if err != nil {
 		setupLog.Error(err, "failed to initialize oci manager")
 		return err
@@ -254,54 +701,235 @@ func Run(ctx context.Context, opts Options) error {
 		setupLog.Error(err, "failed to detect guest capabilities")
 		return err
 	}
+	readiness.SetReady(readyGuestCapabilities, true)
+
+	var emptyDiskPlugin volumeplugin.Plugin
+	switch opts.EmptyDisk.Plugin {
+	case emptyDiskPluginFile:
+		emptyDiskPlugin = emptydisk.NewPlugin(qcow2Inst, rawInst)
+	case emptyDiskPluginLVM:
+		emptyDiskPlugin = lvm.NewPlugin(opts.EmptyDisk.LVMVolumeGroup, opts.EmptyDisk.LVMThinPool)
+	default:
+		err := fmt.Errorf("unsupported empty disk plugin %q", opts.EmptyDisk.Plugin)
+		setupLog.Error(err, "failed to initialize empty disk plugin")
+		return err
+	}
+
+	plugins := []volumeplugin.Plugin{
+		ceph.NewPlugin(opts.VolumeCachePolicy),
+		emptyDiskPlugin,
+		localimage.NewPlugin(qcow2Inst, rawInst, imgCache),
+		hostdevice.NewPlugin(),
+	}
+	externalPluginDiscoverer := external.NewDiscoverer(log.WithName("external-volume-plugins"), providerHost.ExternalVolumePluginsDir())
+	externalPlugins, err := externalPluginDiscoverer.Discover(ctx)
+	if err != nil {
+		setupLog.Error(err, "failed to discover external volume plugins")
+		return err
+	}
+	plugins = append(plugins, externalPlugins...)
+	if opts.Hooks.VolumePlugins != nil {
+		plugins = opts.Hooks.VolumePlugins(plugins)
+	}
 
 	volumePlugins := volumeplugin.NewPluginManager()
-	if err := volumePlugins.InitPlugins(providerHost, []volumeplugin.Plugin{
-		ceph.NewPlugin(),
-		emptydisk.NewPlugin(qcow2Inst, rawInst),
-	}); err != nil {
+	if err := volumePlugins.InitPlugins(providerHost, plugins); err != nil {
 		setupLog.Error(err, "failed to initialize volume plugin manager")
 		return err
 	}
 
-	nicPlugin, nicPluginCleanup, err := opts.NicPlugin.NetworkInterfacePlugin()
-	if err != nil {
+	var nicPluginList []providernetworkinterface.Plugin
+	if opts.Hooks.NetworkInterfacePlugin != nil {
+		nicPluginList = []providernetworkinterface.Plugin{opts.Hooks.NetworkInterfacePlugin}
+	} else {
+		var nicPluginsCleanup func()
+		nicPluginList, nicPluginsCleanup, err = opts.NicPlugin.NetworkInterfacePlugins()
+		if err != nil {
+			setupLog.Error(err, "failed to initialize network plugin")
+			return err
+		}
+		defer nicPluginsCleanup()
+	}
+
+	setupLog.Info("Initializing network interface plugins")
+
+	nicPlugins := providernetworkinterface.NewPluginManager()
+	if err := nicPlugins.InitPlugins(providerHost, nicPluginList); err != nil {
 		setupLog.Error(err, "failed to initialize network plugin")
 		return err
 	}
-	if nicPluginCleanup != nil {
-		defer nicPluginCleanup()
+	readiness.SetReady(readyPlugins, true)
+
+	machineEvents, err := event.NewListWatchSource[*api.Machine](
+		machineStore.List,
+		machineStore.Watch,
+		event.ListWatchSourceOptions{
+			ResyncDuration: opts.ResyncIntervalMachineEvents,
+		},
+	)
+	if err != nil {
+		setupLog.Error(err, "failed to initialize machine events")
+		return err
 	}
 
-	setupLog.Info("Initializing network interface plugin")
+	machineJournal := journal.New(providerHost.MachineJournalFile, journal.Options{
+		MaxEntries: opts.MachineJournalMaxEntries,
+	})
 
-	if err := nicPlugin.Init(providerHost); err != nil {
-		setupLog.Error(err, "failed to initialize network plugin")
+	reconcileSummaryFormat, err := controllers.ParseReconcileSummaryFormat(opts.ReconcileSummaryFormat)
+	if err != nil {
+		setupLog.Error(err, "failed to parse reconcile summary format")
 		return err
 	}
 
-	setupLog.Info("Configuring machine store", "Directory", providerHost.MachineStoreDir())
-	machineStore, err := host.NewStore(host.Options[*api.Machine]{
-		NewFunc:        func() *api.Machine { return &api.Machine{} },
-		CreateStrategy: strategy.MachineStrategy,
-		Dir:            providerHost.MachineStoreDir(),
-	})
+	domainDriftPolicy, err := drift.ParsePolicy(opts.DomainDriftPolicy)
 	if err != nil {
-		setupLog.Error(err, "failed to initialize machine store")
+		setupLog.Error(err, "failed to parse domain drift policy")
 		return err
 	}
 
-	machineEvents, err := event.NewListWatchSource[*api.Machine](
-		machineStore.List,
-		machineStore.Watch,
-		event.ListWatchSourceOptions{},
+	var retryPolicies map[retrypolicy.Class]retrypolicy.Policy
+	if opts.PathRetryPolicies != "" {
+		setupLog.V(1).Info("Loading reconcile retry policies", "Path", opts.PathRetryPolicies)
+		retryPolicies, err = retrypolicy.LoadPolicies(opts.PathRetryPolicies)
+		if err != nil {
+			setupLog.Error(err, "failed to load reconcile retry policies")
+			return err
+		}
+	}
+
+	setupLog.V(1).Info("Loading machine classes", "Path", opts.PathSupportedMachineClasses)
+	classes, err := mcr.LoadMachineClassesFile(opts.PathSupportedMachineClasses)
+	if err != nil {
+		setupLog.Error(err, "failed to load machine classes")
+		return err
+	}
+
+	hugepageManager := hugepages.NewManager("")
+	hugepageSize, err := hugepages.ParseSize(opts.HugepageSize)
+	if err != nil {
+		setupLog.Error(err, "failed to parse hugepage size")
+		return err
+	}
+	hugepageClassSizes := make(map[string]int64, len(opts.HugepageClassSizes))
+	for class, size := range opts.HugepageClassSizes {
+		if hugepageClassSizes[class], err = hugepages.ParseSize(size); err != nil {
+			setupLog.Error(err, "failed to parse hugepage size", "MachineClass", class)
+			return err
+		}
+	}
+
+	sgxEPCClassSizes := make(map[string]int64, len(opts.SGXEPCClassSizes))
+	for class, size := range opts.SGXEPCClassSizes {
+		if sgxEPCClassSizes[class], err = sgx.ParseSize(size); err != nil {
+			setupLog.Error(err, "failed to parse sgx epc size", "MachineClass", class)
+			return err
+		}
+	}
+	for _, class := range classes {
+		if epc, ok := class.ExtendedResources[mcr.ResourceSGXEPC]; ok {
+			if _, ok := sgxEPCClassSizes[class.Name]; !ok {
+				sgxEPCClassSizes[class.Name] = epc.Value()
+			}
+		}
+	}
+	pciLayout, err := pci.ParseLayout(opts.PCILayout)
+	if err != nil {
+		setupLog.Error(err, "failed to parse pci layout")
+		return err
+	}
+	pciClassLayouts := make(map[string]pci.Layout, len(opts.PCIClassLayouts))
+	for class, layout := range opts.PCIClassLayouts {
+		if pciClassLayouts[class], err = pci.ParseLayout(layout); err != nil {
+			setupLog.Error(err, "failed to parse pci layout", "MachineClass", class)
+			return err
+		}
+	}
+
+	pciDevicePools, pciDeviceClassClaims, err := parsePCIDevices(opts.PCIDevicePools, opts.PCIDeviceClassClaims)
+	if err != nil {
+		setupLog.Error(err, "failed to parse pci devices")
+		return err
+	}
+	if err := addGPUClassClaims(classes, opts.GPUDevicePool, pciDevicePools, pciDeviceClassClaims); err != nil {
+		setupLog.Error(err, "failed to claim gpus of machine classes")
+		return err
+	}
+	var (
+		pciDevices         *pcidevice.Manager
+		pciDevicePoolSizes map[string]int
 	)
+	if len(pciDevicePools) > 0 {
+		pciDevices = pcidevice.NewManager("", pciDevicePools)
+		pciDevicePoolSizes = make(map[string]int, len(pciDevicePools))
+		for pool := range pciDevicePools {
+			devices, err := pciDevices.Devices(pool)
+			if err != nil {
+				setupLog.Error(err, "failed to discover pci devices", "Pool", pool)
+				return err
+			}
+			setupLog.Info("Discovered pci devices", "Pool", pool, "Devices", devices)
+			pciDevicePoolSizes[pool] = len(devices)
+		}
+	}
+
+	queueClassCounts, err := virtioQueueClassCounts(opts.VirtioDiskClassQueues, opts.VirtioNICClassQueues)
 	if err != nil {
-		setupLog.Error(err, "failed to initialize machine events")
+		setupLog.Error(err, "failed to parse virtio queue counts")
 		return err
 	}
 
-	eventStore := machineevent.NewEventStore(log, opts.MachineEventStore)
+	if opts.ConsoleHistoryKiB < 1 || opts.ConsoleMaxClients < 1 {
+		err := fmt.Errorf("console history size and maximum number of clients have to be positive")
+		setupLog.Error(err, "invalid console options")
+		return err
+	}
+
+	var sgxEPCBytes int64
+	if len(sgxEPCClassSizes) > 0 {
+		if sgxEPCBytes, err = sgx.HostEPCBytes(libvirt); err != nil {
+			setupLog.Error(err, "failed to get sgx epc of host")
+			return err
+		}
+		if sgxEPCBytes == 0 {
+			err := fmt.Errorf("host doesn't support sgx")
+			setupLog.Error(err, "sgx epc class sizes are configured")
+			return err
+		}
+		setupLog.Info("Using sgx enclave page cache of host", "Bytes", sgxEPCBytes)
+	}
+
+	ephemeralStorage, err := newEphemeralStorage(opts, providerHost, machineStore)
+	if err != nil {
+		setupLog.Error(err, "failed to configure ephemeral storage")
+		return err
+	}
+
+	var (
+		dirQuotas     *quota.Projects
+		dirQuotaBytes func(spec *api.MachineSpec) int64
+	)
+	if opts.MachineDirQuotas {
+		// Without it the quota lacks the root fs copied or overlaid into the machine directory.
+		if opts.RootFSEphemeralStorage == "" {
+			err := fmt.Errorf("--machine-dir-quotas requires --rootfs-ephemeral-storage")
+			setupLog.Error(err, "invalid machine directory quota configuration")
+			return err
+		}
+		overhead, err := resource.ParseQuantity(opts.MachineDirQuotaOverhead)
+		if err != nil {
+			setupLog.Error(err, "invalid machine directory quota overhead")
+			return err
+		}
+		if dirQuotas, err = quota.NewProjects(providerHost.MachinesDir()); err != nil {
+			setupLog.Error(err, "failed to set up machine directory quotas")
+			return err
+		}
+		dirQuotaBytes = func(spec *api.MachineSpec) int64 {
+			return ephemeralStorage.MachineBytes(spec) + overhead.Value()
+		}
+		setupLog.Info("Limiting machine directories with project quotas", "Overhead", overhead.String())
+	}
 
 	machineReconciler, err := controllers.NewMachineReconciler(
 		log.WithName("machine-reconciler"),
@@ -313,14 +941,43 @@ func Run(ctx context.Context, opts Options) error {
 			GuestCapabilities:              caps,
 			ImageCache:                     imgCache,
 			Raw:                            rawInst,
+			QCow2:                          qcow2Inst,
 			Host:                           providerHost,
 			VolumePluginManager:            volumePlugins,
-			NetworkInterfacePlugin:         nicPlugin,
+			NetworkInterfacePlugins:        nicPlugins,
 			ResyncIntervalVolumeSize:       opts.ResyncIntervalVolumeSize,
 			ResyncIntervalGarbageCollector: opts.ResyncIntervalGarbageCollector,
 			EnableHugepages:                opts.EnableHugepages,
+			Hugepages:                      hugepageManager,
 			GCVMGracefulShutdownTimeout:    opts.GCVMGracefulShutdownTimeout,
-			VolumeCachePolicy:              opts.VolumeCachePolicy,
+			GCVMGuestAgentShutdownTimeout:  opts.GCVMGuestAgentShutdownTimeout,
+			GCVMShutdownResendInterval:     opts.GCVMShutdownResendInterval,
+			GCWorkers:                      opts.GCWorkers,
+			QuiesceVolumeDetach:            opts.QuiesceVolumeDetach,
+			LiveVolumeMigration:            opts.LiveVolumeMigration,
+			Journal:                        machineJournal,
+			TerminatingWarningThreshold:    opts.TerminatingWarningThreshold,
+			DeviceEventTimeout:             opts.DeviceEventTimeout,
+			ReconcileSummaryFormat:         reconcileSummaryFormat,
+			ResizeWorkers:                  opts.VolumeResizeWorkers,
+			ResizeQueueSize:                opts.VolumeResizeQueueSize,
+			CPUPinning:                     opts.CPUPinning,
+			PCILayout:                      pciLayout,
+			VirtioMaxQueues:                opts.VirtioMaxQueues,
+			RescueISODir:                   opts.RescueISODir,
+			MediaDir:                       opts.MediaDir,
+			RescueImage:                    opts.RescueImage,
+			PCIDevices:                     pciDevices,
+			ResyncIntervalPCIDeviceHealth:  opts.ResyncIntervalPCIDeviceHealth,
+			DriftPolicy:                    domainDriftPolicy,
+			MaxConcurrentRootFSCreations:   opts.MaxConcurrentRootFSCreations,
+			NetworkInterfaceFilters:        opts.NetworkInterfaceFilters,
+			RetryPolicies:                  retryPolicies,
+			ReconcileTimeout:               opts.ReconcileTimeout,
+			LibvirtCallTimeout:             opts.LibvirtCallTimeout,
+			LibvirtPool:                    libvirtPool,
+			DirQuotas:                      dirQuotas,
+			DirQuotaBytes:                  dirQuotaBytes,
 		},
 	)
 	if err != nil {
@@ -328,19 +985,114 @@ func Run(ctx context.Context, opts Options) error {
 		return err
 	}
 
-	setupLog.V(1).Info("Loading machine classes", "Path", opts.PathSupportedMachineClasses)
-	classes, err := mcr.LoadMachineClassesFile(opts.PathSupportedMachineClasses)
+	extendedResourceSources := map[mcr.ResourceName]mcr.Source{
+		mcr.ResourceEphemeralStorage: ephemeralStorage,
+	}
+	var enforcedEphemeralStorage *mcr.EphemeralStorage
+	if opts.EnforceEphemeralStorage {
+		enforcedEphemeralStorage = ephemeralStorage
+	}
+	if sgxEPCBytes > 0 {
+		extendedResourceSources[mcr.ResourceSGXEPC] = mcr.BytesSource{
+			Bytes:       sgxEPCBytes,
+			Granularity: sgx.PageSize,
+			Allocated:   mcr.MachinesAllocated(machineStore.List, mcr.SGXEPCBytes),
+		}
+	}
+	if size, ok := pciDevicePoolSizes[opts.GPUDevicePool]; ok {
+		extendedResourceSources[mcr.ResourceNvidiaGPU] = mcr.CountSource{
+			Count: int64(size),
+			Allocated: mcr.MachinesAllocated(machineStore.List, func(spec *api.MachineSpec) int64 {
+				var count int64
+				for _, claim := range spec.PCIDevices {
+					if claim.Pool == opts.GPUDevicePool {
+						count += int64(claim.Count)
+					}
+				}
+				return count
+			}),
+		}
+	}
+	machineClasses, err := mcr.NewMachineClassRegistry(classes, extendedResourceSources)
 	if err != nil {
-		setupLog.Error(err, "failed to load machine classes")
+		setupLog.Error(err, "failed to initialize machine class registry")
 		return err
 	}
 
-	machineClasses, err := mcr.NewMachineClassRegistry(classes)
+	systemReserved, err := parseSystemReserved(opts.SystemReservedCPU, opts.SystemReservedMemory)
 	if err != nil {
-		setupLog.Error(err, "failed to initialize machine class registry")
+		setupLog.Error(err, "failed to parse system reserved resources")
 		return err
 	}
 
+	overcommit := opts.Overcommit
+	if err := overcommit.Validate(); err != nil {
+		setupLog.Error(err, "invalid overcommit ratios")
+		return err
+	}
+	if opts.EnableHugepages && overcommit.Mem != 1 {
+		setupLog.Info("Ignoring memory overcommit ratio, hugepage backed memory is never overcommitted", "MemoryOvercommitRatio", overcommit.Mem)
+		overcommit.Mem = 1
+	}
+	setupLog.Info("Using overcommit ratios", "CPU", overcommit.Cpu, "Memory", overcommit.Mem)
+	overcommit.Observe()
+
+	var smbiosClassDefaults map[string]api.SMBIOSSpec
+	if opts.PathSMBIOSClassDefaults != "" {
+		setupLog.V(1).Info("Loading smbios class defaults", "Path", opts.PathSMBIOSClassDefaults)
+		smbiosClassDefaults, err = smbios.LoadClassDefaults(opts.PathSMBIOSClassDefaults)
+		if err != nil {
+			setupLog.Error(err, "failed to load smbios class defaults")
+			return err
+		}
+	}
+
+	var deviceClassProfiles map[string]api.DevicesSpec
+	if opts.PathDeviceClassProfiles != "" {
+		setupLog.V(1).Info("Loading device class profiles", "Path", opts.PathDeviceClassProfiles)
+		deviceClassProfiles, err = deviceprofile.LoadClassProfiles(opts.PathDeviceClassProfiles)
+		if err != nil {
+			setupLog.Error(err, "failed to load device class profiles")
+			return err
+		}
+	}
+
+	var clockClassPolicies map[string]api.ClockSpec
+	if opts.PathClockClassPolicies != "" {
+		setupLog.V(1).Info("Loading clock class policies", "Path", opts.PathClockClassPolicies)
+		clockClassPolicies, err = clockpolicy.LoadClassPolicies(opts.PathClockClassPolicies)
+		if err != nil {
+			setupLog.Error(err, "failed to load clock class policies")
+			return err
+		}
+	}
+
+	var cgroupClassPolicies map[string]api.CgroupSpec
+	if opts.PathCgroupClassPolicies != "" {
+		setupLog.V(1).Info("Loading cgroup class policies", "Path", opts.PathCgroupClassPolicies)
+		cgroupClassPolicies, err = cgrouppolicy.LoadClassPolicies(opts.PathCgroupClassPolicies)
+		if err != nil {
+			setupLog.Error(err, "failed to load cgroup class policies")
+			return err
+		}
+	}
+
+	rootFSMode, err := rootfs.ParseMode(opts.RootFSMode)
+	if err != nil {
+		setupLog.Error(err, "failed to parse root fs mode")
+		return err
+	}
+
+	var hardwareClassDefaults map[string]api.HardwareSpec
+	if opts.PathHardwareClassDefaults != "" {
+		setupLog.V(1).Info("Loading hardware class defaults", "Path", opts.PathHardwareClassDefaults)
+		hardwareClassDefaults, err = hardware.LoadClassDefaults(opts.PathHardwareClassDefaults)
+		if err != nil {
+			setupLog.Error(err, "failed to load hardware class defaults")
+			return err
+		}
+	}
+
 	srv, err := server.New(server.Options{
 		BaseURL:         baseURL,
 		Libvirt:         libvirt,
@@ -348,26 +1100,148 @@ func Run(ctx context.Context, opts Options) error {
 		EventStore:      eventStore,
 		MachineClasses:  machineClasses,
 		VolumePlugins:   volumePlugins,
-		NetworkPlugins:  nicPlugin,
+		NetworkPlugins:  nicPlugins,
 		EnableHugepages: opts.EnableHugepages,
 		GuestAgent:      opts.GuestAgent.GetAPIGuestAgent(),
+
+		Hugepages:          hugepageManager,
+		HugepageSize:       hugepageSize,
+		HugepageClassSizes: hugepageClassSizes,
+
+		SMBIOSClassDefaults: smbiosClassDefaults,
+		DeviceClassProfiles: deviceClassProfiles,
+		ClockClassPolicies:  clockClassPolicies,
+		CgroupClassPolicies: cgroupClassPolicies,
+
+		HardwareClassDefaults: hardwareClassDefaults,
+
+		SystemReserved: systemReserved,
+		Overcommit:     overcommit,
+
+		EphemeralStorage: enforcedEphemeralStorage,
+
+		SGXEPCBytes:      sgxEPCBytes,
+		SGXEPCClassSizes: sgxEPCClassSizes,
+
+		PCIClassLayouts: pciClassLayouts,
+
+		PCIDevicePoolSizes:   pciDevicePoolSizes,
+		PCIDeviceClassClaims: pciDeviceClassClaims,
+		PCIDevices:           pciDevices,
+
+		QueueClassCounts: queueClassCounts,
+
+		RootFSMode: rootFSMode,
+
+		ConsoleHistorySize: opts.ConsoleHistoryKiB * 1024,
+		ConsoleMaxClients:  opts.ConsoleMaxClients,
 	})
 	if err != nil {
 		setupLog.Error(err, "failed to initialize server")
 		return err
 	}
 
-	healthCheck := healthcheck.HealthCheck{
-		Libvirt: libvirt,
-		Log:     log.WithName("health-check"),
+	// Failing dependencies aren't fixed by restarting the provider, so they are probed by the readiness check.
+	readiness.AddProbes(
+		healthcheck.ReadWriteProbe("machine-store", providerHost.StoreDir()),
+		healthcheck.DiskSpaceProbe("image-cache", providerHost.ImagesDir(), opts.ImageCache.MinFreeBytes),
+		healthcheck.Probe{
+			Name: "machine-reconciler",
+			Check: func(context.Context) error {
+				return machineReconciler.CheckProgress(opts.HealthCheckReconcilerStallThreshold)
+			},
+		},
+	)
+	for _, nicPlugin := range nicPlugins.Plugins() {
+		if checker, ok := nicPlugin.(healthcheck.Checker); ok {
+			readiness.AddProbes(healthcheck.CheckerProbe(nicPlugin.Name(), checker))
+		}
+	}
+	if libvirtPool != nil {
+		healthCheck.AddProbes(healthcheck.Probe{
+			Name: "libvirt-pool",
+			Check: func(context.Context) error {
+				return libvirtPool.Healthy(opts.Libvirt.ConnectionGracePeriod)
+			},
+		})
 	}
 
-	g, ctx := errgroup.WithContext(ctx)
+	var hostEventManager *hostevent.Manager
+	if opts.HostEvent.ConditionFile != "" {
+		defaultPolicy, err := hostevent.ParsePolicy(opts.HostEvent.DefaultPolicy)
+		if err != nil {
+			setupLog.Error(err, "failed to parse host event default policy")
+			return err
+		}
+
+		hostEventManager, err = hostevent.NewManager(
+			log.WithName("host-event-manager"),
+			libvirt,
+			machineStore,
+			providerHost,
+			eventStore,
+			hostevent.Options{
+				Source:          hostevent.NewFileSource(opts.HostEvent.ConditionFile),
+				PollInterval:    opts.HostEvent.PollInterval,
+				DefaultPolicy:   defaultPolicy,
+				ThrottlePercent: opts.HostEvent.ThrottlePercent,
+				Conn:            machineReconciler.MutatingConn,
+				CallTimeout:     opts.LibvirtCallTimeout,
+			},
+		)
+		if err != nil {
+			setupLog.Error(err, "failed to initialize host event manager")
+			return err
+		}
+	}
+
+	supportBundle := supportbundle.Handler{
+		Log:      log.WithName("support-bundle"),
+		Paths:    providerHost,
+		Machines: machineStore,
+		Journal:  machineJournal,
+		Events:   eventStore,
+	}
+
+	infoCollector := providerinfo.NewCollector(providerinfo.Options{
+		Libvirt:                 libvirt,
+		GuestCapabilities:       caps,
+		MachineStore:            machineStore,
+		VolumePlugins:           volumePlugins,
+		NetworkInterfacePlugins: nicPlugins,
+		ImageCache:              imgCache,
+		EnableHugepages:         opts.EnableHugepages,
+		SystemReserved:          systemReserved,
+		Overcommit:              overcommit,
+	})
+
+	adminHandler := admin.Handler{
+		Log:            log.WithName("admin"),
+		ForceFinalizer: machineReconciler,
+		ImagePrePuller: oci.NewPrePuller(imgCache),
+		Machines:       machineStore,
+		Journal:        machineJournal,
+		Events:         eventStore,
+		Info:           infoCollector,
+		StoreRebuilder: machineReconciler,
+	}
 
 	g.Go(func() error {
 		return runMetricsServer(ctx, setupLog, opts.Servers.Metrics)
 	})
 
+	g.Go(func() error {
+		mux := http.NewServeMux()
+		mux.Handle(fmt.Sprintf("GET /support-bundle/{%s}", supportbundle.MachineIDPathValue), supportBundle)
+		return runOptionalHTTPServer(ctx, setupLog, "support bundle", mux, opts.Servers.SupportBundle)
+	})
+
+	g.Go(func() error {
+		mux := http.NewServeMux()
+		adminHandler.Register(mux)
+		return runOptionalHTTPServer(ctx, setupLog, "admin", mux, opts.Servers.Admin)
+	})
+
 	g.Go(func() error {
 		setupLog.Info("Starting oci cache")
 		if err := imgCache.Start(ctx); err != nil {
@@ -379,13 +1253,43 @@ func Run(ctx context.Context, opts Options) error {
 
 	g.Go(func() error {
 		setupLog.Info("Starting machine reconciler")
-		if err := machineReconciler.Start(ctx); err != nil {
+		if err := machineReconciler.Start(runCtx); err != nil {
 			setupLog.Error(err, "failed to start machine reconciler")
 			return err
 		}
 		return nil
 	})
 
+	g.Go(func() error {
+		<-ctx.Done()
+		defer stopRun()
+
+		healthCheck.SetDraining(true)
+		drainCtx, cancel := context.WithTimeout(runCtx, opts.ShutdownDrainTimeout)
+		defer cancel()
+		setupLog.Info("Draining machine reconciler", "Timeout", opts.ShutdownDrainTimeout)
+		if err := machineReconciler.Drain(drainCtx); err != nil {
+			setupLog.Error(err, "failed to drain machine reconciler, interrupting the remaining reconciles")
+			return nil
+		}
+		setupLog.Info("Drained machine reconciler")
+		return nil
+	})
+
+	g.Go(func() error {
+		setupLog.Info("Starting volume plugin health checks")
+		volumePlugins.StartHealthChecks(ctx, log.WithName("volume-plugins"), opts.VolumePluginHealthCheckInterval, opts.VolumePluginHealthCheckTimeout)
+		return nil
+	})
+
+	g.Go(func() error {
+		setupLog.Info("Starting external volume plugin discovery")
+		externalPluginDiscoverer.Start(ctx, opts.ExternalVolumePluginDiscoveryInterval, func(plugin volumeplugin.Plugin) error {
+			return volumePlugins.InitPlugins(providerHost, []volumeplugin.Plugin{plugin})
+		})
+		return nil
+	})
+
 	g.Go(func() error {
 		setupLog.Info("Starting machine events")
 		if err := machineEvents.Start(ctx); err != nil {
@@ -395,15 +1299,27 @@ func Run(ctx context.Context, opts Options) error {
 		return nil
 	})
 
+	if hostEventManager != nil {
+		g.Go(func() error {
+			setupLog.Info("Starting host event manager")
+			hostEventManager.Start(ctx)
+			return nil
+		})
+	}
+
 	g.Go(func() error {
 		setupLog.Info("Starting machine events garbage collector")
 		eventStore.Start(ctx)
 		return nil
 	})
 
+	g.Go(func() error {
+		return runProviderInfoServer(ctx, setupLog, log, infoCollector, opts.Servers.ProviderInfo)
+	})
+
 	g.Go(func() error {
 		setupLog.Info("Starting grpc server")
-		if err := runGRPCServer(ctx, setupLog, log, srv, opts); err != nil {
+		if err := runGRPCServer(ctx, setupLog, log, srv, readiness, opts); err != nil {
 			setupLog.Error(err, "failed to start grpc server")
 			return err
 		}
@@ -419,32 +1335,101 @@ func Run(ctx context.Context, opts Options) error {
 		return nil
 	})
 
-	g.Go(func() error {
-		setupLog.Info("Starting health check server")
-		if err := runHealthCheckServer(ctx, setupLog, healthCheck, opts.Servers.HealthCheck); err != nil {
-			setupLog.Error(err, "failed to start health check server")
-			return err
+	return g.Wait()
+}
+
+// openMachineStoreBackend opens the machine store backend of the given kind. The returned func closes it.
+func openMachineStoreBackend(kind string, paths host.Paths) (host.Backend, func() error, error) {
+	switch kind {
+	case machineStoreBackendDir:
+		backend, err := host.NewDirBackend(paths.MachineStoreDir())
+		if err != nil {
+			return nil, nil, err
+		}
+		return backend, func() error { return nil }, nil
+	case machineStoreBackendBolt:
+		backend, err := host.NewBoltBackend(paths.MachineStoreDBFile())
+		if err != nil {
+			return nil, nil, err
 		}
+		return backend, backend.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported machine store backend %q", kind)
+	}
+}
+
+// checkMachineStoreMigrated refuses to use an empty database backend while the directory store still contains
+// machines, which would otherwise silently be unknown to the provider.
+func checkMachineStoreMigrated(backend host.Backend, paths host.Paths) error {
+	if _, ok := backend.(*host.DirBackend); ok {
 		return nil
-	})
+	}
 
-	return g.Wait()
+	ids, err := backend.IDs()
+	if err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		return nil
+	}
+
+	dirBackend, err := host.NewDirBackend(paths.MachineStoreDir())
+	if err != nil {
+		return err
+	}
+	dirIDs, err := dirBackend.IDs()
+	if err != nil {
+		return err
+	}
+	if len(dirIDs) > 0 {
+		return fmt.Errorf("machine store directory %s contains %d machines, migrate them with the store migrate command first", paths.MachineStoreDir(), len(dirIDs))
+	}
+	return nil
 }
 
-func runGRPCServer(ctx context.Context, setupLog logr.Logger, log logr.Logger, srv *server.Server, opts Options) error {
+func runGRPCServer(ctx context.Context, setupLog logr.Logger, log logr.Logger, srv *server.Server, readiness *healthcheck.Readiness, opts Options) error {
 	setupLog.V(1).Info("Cleaning up any previous socket")
 	if err := common.CleanupSocketIfExists(opts.Address); err != nil {
 		return fmt.Errorf("error cleaning up socket: %w", err)
 	}
 
+	rateLimits := make(map[string]server.RateLimit, len(opts.IRIRateLimits))
+	for method, limit := range opts.IRIRateLimits {
+		var err error
+		if rateLimits[method], err = server.ParseRateLimit(limit); err != nil {
+			return fmt.Errorf("error parsing rate limit of %s: %w", method, err)
+		}
+	}
+	if opts.IRIMaxInFlightCreates < 0 {
+		return fmt.Errorf("maximum number of in-flight creates must not be negative")
+	}
+	admission := server.NewAdmission(server.AdmissionOptions{
+		MethodRateLimits:   rateLimits,
+		MaxInFlightCreates: opts.IRIMaxInFlightCreates,
+	})
+
 	grpcSrv := grpc.NewServer(
+		grpc.ForceServerCodec(iricompat.NewCodec(log.WithName("iri-compat"), opts.IRIRejectUnknownFields)),
 		grpc.ChainUnaryInterceptor(
 			commongrpc.InjectLogger(log.WithName("iri-server")),
 			commongrpc.LogRequest,
+			admission.UnaryServerInterceptor,
 		),
 	)
 	iri.RegisterMachineRuntimeServer(grpcSrv, srv)
 
+	methods := sets.New[string]()
+	for _, info := range grpcSrv.GetServiceInfo() {
+		for _, method := range info.Methods {
+			methods.Insert(method.Name)
+		}
+	}
+	for method := range rateLimits {
+		if !methods.Has(method) {
+			return fmt.Errorf("rate limit of unknown method %s", method)
+		}
+	}
+
 	setupLog.V(1).Info("Start listening on unix socket", "Address", opts.Address)
 	l, err := net.Listen("unix", opts.Address)
 	if err != nil {
@@ -452,8 +1437,10 @@ func runGRPCServer(ctx context.Context, setupLog logr.Logger, log logr.Logger, s
 	}
 
 	setupLog.Info("Starting grpc server", "Address", l.Addr().String())
+	readiness.SetReady(readyGRPCServer, true)
 	go func() {
 		<-ctx.Done()
+		readiness.SetReady(readyGRPCServer, false)
 		setupLog.Info("Shutting down grpc server")
 		grpcSrv.GracefulStop()
 		setupLog.Info("Shut down grpc server")
@@ -464,6 +1451,58 @@ func runGRPCServer(ctx context.Context, setupLog logr.Logger, log logr.Logger, s
 	return nil
 }
 
+// runProviderInfoServer serves the provider info on a listener of its own, its messages are encoded as JSON, unlike
+// the proto messages of the IRI server.
+func runProviderInfoServer(ctx context.Context, setupLog logr.Logger, log logr.Logger, info providerinfo.InfoServer, opts HTTPServerOptions) error {
+	if opts.Addr == "" {
+		setupLog.Info("provider info server address isn't configured. Server is disabled.")
+		return nil
+	}
+
+	listener, err := admin.Listen(opts.Addr)
+	if err != nil {
+		return fmt.Errorf("error listening on provider info server address: %w", err)
+	}
+
+	grpcSrv := grpc.NewServer(
+		pluginapi.ServerOption(),
+		grpc.ChainUnaryInterceptor(
+			commongrpc.InjectLogger(log.WithName("provider-info-server")),
+			commongrpc.LogRequest,
+		),
+	)
+	providerinfo.RegisterInfoServer(grpcSrv, info)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-ctx.Done()
+		setupLog.Info("Shutting down server", "Server", "provider info")
+		stopped := make(chan struct{})
+		go func() {
+			grpcSrv.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+			setupLog.Info("Server is shutdown", "Server", "provider info")
+		case <-time.After(opts.GracefulTimeout):
+			grpcSrv.Stop()
+			setupLog.Info("Server wasn't shutdown gracefully in time, stopped it", "Server", "provider info")
+		}
+	}()
+
+	setupLog.V(1).Info("Starting server", "Server", "provider info", "Address", opts.Addr)
+	if err := grpcSrv.Serve(listener); err != nil {
+		return fmt.Errorf("error serving provider info server: %w", err)
+	}
+
+	wg.Wait()
+
+	return nil
+}
+
 func runStreamingServer(ctx context.Context, setupLog, log logr.Logger, srv *server.Server, opts Options) error {
 	httpHandler := console.NewHandler(srv, console.HandlerOptions{
 		Log: log.WithName("streaming-server"),
@@ -532,9 +1571,212 @@ func runMetricsServer(ctx context.Context, setupLog logr.Logger, opts HTTPServer
 	return nil
 }
 
-func runHealthCheckServer(ctx context.Context, setupLog logr.Logger, healthCheck healthcheck.HealthCheck, opts HTTPServerOptions) error {
+// machineImageReferences returns the image refs, including the images of inserted media and rescue images, and
+// pinned image digests of all machines, including machines being deleted since their domains may still use the
+// image. Rescued machines without an explicit rescue image use the defaultRescueImage.
+func machineImageReferences(ctx context.Context, machineStore store.Store[*api.Machine], defaultRescueImage string) (oci.ImageReferences, error) {
+	machines, err := machineStore.List(ctx)
+	if err != nil {
+		return oci.ImageReferences{}, err
+	}
+
+	res := oci.ImageReferences{
+		Refs:    make(map[string][]string),
+		Digests: make(map[digest.Digest][]string),
+	}
+	for _, machine := range machines {
+		if machine.Spec.Image != nil {
+			res.Refs[*machine.Spec.Image] = append(res.Refs[*machine.Spec.Image], machine.ID)
+		}
+		for _, media := range machine.Spec.Media {
+			if media.Source != nil && media.Source.Image != "" {
+				res.Refs[media.Source.Image] = append(res.Refs[media.Source.Image], machine.ID)
+			}
+		}
+		// The domain keeps using the rescue image it was started with until it is restarted into another one.
+		rescueRefs := sets.New[string]()
+		if machine.Spec.Rescue != nil {
+			rescueRefs.Insert(cmp.Or(machine.Spec.Rescue.Image, defaultRescueImage))
+		}
+		if machine.Status.Rescue != nil {
+			rescueRefs.Insert(machine.Status.Rescue.Image)
+		}
+		rescueRefs.Delete("")
+		for ref := range rescueRefs {
+			res.Refs[ref] = append(res.Refs[ref], machine.ID)
+		}
+		if dgst, err := digest.Parse(machine.Status.ImageDigest); err == nil {
+			res.Digests[dgst] = append(res.Digests[dgst], machine.ID)
+		}
+	}
+	return res, nil
+}
+
+// newEphemeralStorage returns the accounting of the disk space of the machines in the machines directory.
+func newEphemeralStorage(opts Options, paths host.Paths, machineStore store.Store[*api.Machine]) (*mcr.EphemeralStorage, error) {
+	storage := &mcr.EphemeralStorage{
+		Dir:          paths.MachinesDir(),
+		ListMachines: machineStore.List,
+	}
+	if opts.SystemReservedEphemeralStorage != "" {
+		quantity, err := resource.ParseQuantity(opts.SystemReservedEphemeralStorage)
+		if err != nil {
+			return nil, fmt.Errorf("invalid system reserved ephemeral storage: %w", err)
+		}
+		storage.Reserved = quantity.Value()
+	}
+	if opts.RootFSEphemeralStorage != "" {
+		quantity, err := resource.ParseQuantity(opts.RootFSEphemeralStorage)
+		if err != nil {
+			return nil, fmt.Errorf("invalid root fs ephemeral storage: %w", err)
+		}
+		storage.RootFSBytes = quantity.Value()
+	}
+	return storage, nil
+}
+
+// parseSystemReserved parses the host cpu and memory reserved for the hypervisor and system daemons. Empty values
+// reserve nothing.
+func parseSystemReserved(cpu, memory string) (*mcr.Host, error) {
+	cpuQuantity, err := parseSystemReservedQuantity("cpu", cpu)
+	if err != nil {
+		return nil, err
+	}
+	memoryQuantity, err := parseSystemReservedQuantity("memory", memory)
+	if err != nil {
+		return nil, err
+	}
+	return mcr.NewReserved(cpuQuantity, memoryQuantity), nil
+}
+
+func parseSystemReservedQuantity(name, value string) (*resource.Quantity, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid system reserved %s %q: %w", name, value, err)
+	}
+	if quantity.Sign() < 0 {
+		return nil, fmt.Errorf("system reserved %s %q must not be negative", name, value)
+	}
+	return &quantity, nil
+}
+
+// virtioQueueClassCounts merges the disk and network interface queue counts per machine class.
+func virtioQueueClassCounts(diskQueues, nicQueues map[string]int) (map[string]api.QueuesSpec, error) {
+	counts := make(map[string]api.QueuesSpec, len(diskQueues)+len(nicQueues))
+	for kind, classQueues := range map[string]map[string]int{"disk": diskQueues, "nic": nicQueues} {
+		for class, queues := range classQueues {
+			if queues < 1 {
+				return nil, fmt.Errorf("%s queues of machine class %s must be positive, got %d", kind, class, queues)
+			}
+
+			spec := counts[class]
+			if kind == "disk" {
+				spec.Disk = uint(queues)
+			} else {
+				spec.NetworkInterface = uint(queues)
+			}
+			counts[class] = spec
+		}
+	}
+	return counts, nil
+}
+
+// parsePCIDevices parses the selectors of the PCI device pools and the pools claimed by the machines of each class.
+func parsePCIDevices(pools, classClaims map[string]string) (map[string][]pcidevice.Selector, map[string][]api.PCIDeviceClaim, error) {
+	poolSelectors := make(map[string][]pcidevice.Selector, len(pools))
+	for pool, selectors := range pools {
+		var err error
+		if poolSelectors[pool], err = pcidevice.ParsePool(selectors); err != nil {
+			return nil, nil, fmt.Errorf("pool %s: %w", pool, err)
+		}
+	}
+
+	claims := make(map[string][]api.PCIDeviceClaim, len(classClaims))
+	for class, classClaim := range classClaims {
+		var err error
+		if claims[class], err = pcidevice.ParseClaims(classClaim); err != nil {
+			return nil, nil, fmt.Errorf("machine class %s: %w", class, err)
+		}
+		for _, claim := range claims[class] {
+			if _, ok := poolSelectors[claim.Pool]; !ok {
+				return nil, nil, fmt.Errorf("machine class %s: %w %s", class, pcidevice.ErrUnknownPool, claim.Pool)
+			}
+		}
+	}
+	return poolSelectors, claims, nil
+}
+
+// addGPUClassClaims claims the gpus requested as extended resource by machine classes from the gpu device pool,
+// unless the classes claim devices of the pool already.
+func addGPUClassClaims(classes []mcr.MachineClass, pool string, pools map[string][]pcidevice.Selector, claims map[string][]api.PCIDeviceClaim) error {
+	for _, class := range classes {
+		gpus, ok := class.ExtendedResources[mcr.ResourceNvidiaGPU]
+		if !ok {
+			continue
+		}
+		if _, ok := pools[pool]; !ok {
+			return fmt.Errorf("machine class %s requests %s, but the gpu device pool %q isn't configured", class.Name, mcr.ResourceNvidiaGPU, pool)
+		}
+		if slices.ContainsFunc(claims[class.Name], func(claim api.PCIDeviceClaim) bool { return claim.Pool == pool }) {
+			continue
+		}
+		claims[class.Name] = append(claims[class.Name], api.PCIDeviceClaim{Pool: pool, Count: int(gpus.Value())})
+	}
+	return nil
+}
+
+// runOptionalHTTPServer serves the handler on the address of the options until the context is done. The server is
+// disabled if no address is configured.
+func runOptionalHTTPServer(ctx context.Context, setupLog logr.Logger, name string, handler http.Handler, opts HTTPServerOptions) error {
+	if opts.Addr == "" {
+		setupLog.Info(fmt.Sprintf("%s server address isn't configured. Server is disabled.", name))
+		return nil
+	}
+
+	listener, err := admin.Listen(opts.Addr)
+	if err != nil {
+		return fmt.Errorf("error listening on %s server address: %w", name, err)
+	}
+
+	srv := http.Server{
+		Addr:    opts.Addr,
+		Handler: handler,
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-ctx.Done()
+		setupLog.Info("Shutting down server", "Server", name)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), opts.GracefulTimeout)
+		defer cancel()
+		locErr := srv.Shutdown(shutdownCtx)
+		if locErr != nil {
+			setupLog.Error(locErr, "server wasn't shutdown properly", "Server", name)
+		} else {
+			setupLog.Info("Server is shutdown", "Server", name)
+		}
+	}()
+
+	setupLog.V(1).Info("Starting server", "Server", name, "Address", opts.Addr)
+	if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("error listening / serving %s server: %w", name, err)
+	}
+
+	wg.Wait()
+
+	return nil
+}
+
+func runHealthCheckServer(ctx context.Context, setupLog logr.Logger, healthCheck *healthcheck.HealthCheck, readiness *healthcheck.Readiness, opts HTTPServerOptions) error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/healthz", healthCheck.HealthCheckHandler)
+	mux.HandleFunc("/readyz", readiness.ReadinessHandler)
 
 	srv := http.Server{
 		Addr:    opts.Addr,
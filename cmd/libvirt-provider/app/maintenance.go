@@ -0,0 +1,383 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/admin"
+	"github.com/ironcore-dev/libvirt-provider/internal/boot"
+	"github.com/ironcore-dev/libvirt-provider/internal/host"
+	"github.com/ironcore-dev/libvirt-provider/internal/media"
+	"github.com/spf13/cobra"
+)
+
+// defaultAdminAddress returns the address the admin server listens on and the maintenance commands talk to by
+// default, a unix socket in the default directory of the provider.
+func defaultAdminAddress() string {
+	return admin.UnixAddressPrefix + filepath.Join(homeDir, ".libvirt-provider", "admin.sock")
+}
+
+// maintenanceCommands returns the subcommands operating a running provider via its admin server, so operators
+// don't have to modify the machine store or the domains behind the provider's back.
+func maintenanceCommands() []*cobra.Command {
+	var address string
+	client := func() *admin.Client {
+		return admin.NewClient(address)
+	}
+
+	machinesCmd := &cobra.Command{
+		Use:   "machines",
+		Short: "Inspect and maintain the machines of a running provider.",
+	}
+	machinesCmd.AddCommand(
+		&cobra.Command{
+			Use:   "list",
+			Short: "List all machines.",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				cmd.SilenceUsage = true
+				res, err := client().ListMachines(cmd.Context())
+				if err != nil {
+					return err
+				}
+				return printMachines(cmd.OutOrStdout(), res.Machines)
+			},
+		},
+		&cobra.Command{
+			Use:   "inspect MACHINE_ID",
+			Short: "Show a machine together with its operation journal and recent events.",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				cmd.SilenceUsage = true
+				res, err := client().GetMachine(cmd.Context(), args[0])
+				if err != nil {
+					return err
+				}
+				return printJSON(cmd.OutOrStdout(), res)
+			},
+		},
+		&cobra.Command{
+			Use:   "force-finalize MACHINE_ID",
+			Short: "Force finalize a machine stuck in Terminating, reporting the resources left behind.",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				cmd.SilenceUsage = true
+				res, err := client().ForceFinalize(cmd.Context(), args[0])
+				if err != nil {
+					return err
+				}
+				return printJSON(cmd.OutOrStdout(), res)
+			},
+		},
+		&cobra.Command{
+			Use:   "force-delete MACHINE_ID",
+			Short: "Delete a machine and force finalize it right away, reporting the resources left behind.",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				cmd.SilenceUsage = true
+				res, err := client().ForceDelete(cmd.Context(), args[0])
+				if err != nil {
+					return err
+				}
+				return printJSON(cmd.OutOrStdout(), res)
+			},
+		},
+	)
+
+	var (
+		bootDevice string
+		bootISO    string
+		bootClear  bool
+	)
+	bootOverrideCmd := &cobra.Command{
+		Use:   "boot-override MACHINE_ID",
+		Short: "Boot a machine once from another device or a rescue ISO image at the next start of its domain.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if bootClear {
+				cmd.SilenceUsage = true
+				res, err := client().ClearBootOverride(cmd.Context(), args[0])
+				if err != nil {
+					return err
+				}
+				return printJSON(cmd.OutOrStdout(), res.Machine.Spec.Boot)
+			}
+
+			override := &api.BootOverride{ISO: bootISO}
+			if bootDevice != "" {
+				devices, err := boot.ParseOrder(bootDevice)
+				if err != nil {
+					return err
+				}
+				if len(devices) != 1 {
+					return fmt.Errorf("expected a single boot device, got %d", len(devices))
+				}
+				override.Device = &devices[0]
+			}
+			if err := boot.ValidateOverride(override); err != nil {
+				return err
+			}
+
+			cmd.SilenceUsage = true
+			res, err := client().SetBootOverride(cmd.Context(), args[0], override)
+			if err != nil {
+				return err
+			}
+			return printJSON(cmd.OutOrStdout(), res.Machine.Spec.Boot)
+		},
+	}
+	bootOverrideCmd.Flags().StringVar(&bootDevice, "device", "", "Device to boot once, one of rootfs, volume:<name> or network:<name>.")
+	bootOverrideCmd.Flags().StringVar(&bootISO, "iso", "", "File name of the rescue ISO image in the --rescue-iso-dir of the provider to boot once.")
+	bootOverrideCmd.Flags().BoolVar(&bootClear, "clear", false, "Drop the pending boot override of the machine.")
+	bootOverrideCmd.MarkFlagsMutuallyExclusive("device", "iso", "clear")
+	machinesCmd.AddCommand(bootOverrideCmd)
+
+	var (
+		mediaImage string
+		mediaPath  string
+	)
+	mediaInsertCmd := &cobra.Command{
+		Use:   "insert MACHINE_ID DRIVE",
+		Short: "Insert an ISO image into a media drive of a machine, changing the media of a running domain live.",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			source := &api.MediaSource{Image: mediaImage, Path: mediaPath}
+			if err := media.ValidateSource(source); err != nil {
+				return err
+			}
+
+			cmd.SilenceUsage = true
+			res, err := client().InsertMedia(cmd.Context(), args[0], args[1], source)
+			if err != nil {
+				return err
+			}
+			return printJSON(cmd.OutOrStdout(), res.Machine.Spec.Media)
+		},
+	}
+	mediaInsertCmd.Flags().StringVar(&mediaImage, "image", "", "Reference of the OCI image whose root fs layer is the ISO image.")
+	mediaInsertCmd.Flags().StringVar(&mediaPath, "path", "", "Path of the ISO image relative to the --media-dir of the provider.")
+	mediaInsertCmd.MarkFlagsMutuallyExclusive("image", "path")
+	mediaInsertCmd.MarkFlagsOneRequired("image", "path")
+
+	mediaCmd := &cobra.Command{
+		Use:   "media",
+		Short: "Insert and eject the media of the media drives of a machine.",
+	}
+	mediaCmd.AddCommand(
+		mediaInsertCmd,
+		&cobra.Command{
+			Use:   "eject MACHINE_ID DRIVE",
+			Short: "Eject the media of a media drive of a machine.",
+			Args:  cobra.ExactArgs(2),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				cmd.SilenceUsage = true
+				res, err := client().EjectMedia(cmd.Context(), args[0], args[1])
+				if err != nil {
+					return err
+				}
+				return printJSON(cmd.OutOrStdout(), res.Machine.Spec.Media)
+			},
+		},
+	)
+	machinesCmd.AddCommand(mediaCmd)
+
+	var (
+		rescueImage string
+		rescueExit  bool
+	)
+	rescueCmd := &cobra.Command{
+		Use:   "rescue MACHINE_ID",
+		Short: "Restart a machine into a rescue image with its disks attached, or out of it again.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			var (
+				res *admin.MachineResponse
+				err error
+			)
+			if rescueExit {
+				res, err = client().Unrescue(cmd.Context(), args[0])
+			} else {
+				res, err = client().Rescue(cmd.Context(), args[0], rescueImage)
+			}
+			if err != nil {
+				return err
+			}
+			return printJSON(cmd.OutOrStdout(), res.Machine.Spec.Rescue)
+		},
+	}
+	rescueCmd.Flags().StringVar(&rescueImage, "image", "", "Reference of the direct kernel boot rescue image. Defaults to the --rescue-image of the provider.")
+	rescueCmd.Flags().BoolVar(&rescueExit, "exit", false, "Restart the rescued machine to boot normally again.")
+	rescueCmd.MarkFlagsMutuallyExclusive("image", "exit")
+	machinesCmd.AddCommand(rescueCmd)
+
+	var machineID string
+	eventsListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the recent machine events.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			res, err := client().ListEvents(cmd.Context(), machineID)
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			_, _ = fmt.Fprintln(w, "TIME\tMACHINE\tTYPE\tREASON\tMESSAGE")
+			for _, event := range res.Events {
+				spec := event.GetSpec()
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+					time.Unix(spec.GetEventTime(), 0).Format(time.RFC3339),
+					spec.GetInvolvedObjectMeta().GetId(),
+					spec.GetType(),
+					spec.GetReason(),
+					spec.GetMessage(),
+				)
+			}
+			return w.Flush()
+		},
+	}
+	eventsListCmd.Flags().StringVar(&machineID, "machine", "", "Only list the events of the machine with the given id.")
+
+	eventsCmd := &cobra.Command{
+		Use:   "events",
+		Short: "Inspect the machine events of a running provider.",
+	}
+	eventsCmd.AddCommand(eventsListCmd)
+
+	storeCmd := &cobra.Command{
+		Use:   "store",
+		Short: "Inspect the machine store of a running provider.",
+	}
+	storeCmd.AddCommand(&cobra.Command{
+		Use:   "verify",
+		Short: "Verify that all machines of the machine store can be read back.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			res, err := client().VerifyStore(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			for _, problem := range res.Problems {
+				_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%s: %s\n", problem.ID, problem.Reason)
+			}
+			if len(res.Problems) > 0 {
+				return fmt.Errorf("machine store contains %d unreadable machines", len(res.Problems))
+			}
+			_, _ = fmt.Fprintln(cmd.OutOrStdout(), "machine store is consistent")
+			return nil
+		},
+	})
+	storeCmd.AddCommand(&cobra.Command{
+		Use:   "rebuild",
+		Short: "Add the machines missing in the machine store from the libvirt domains of the provider.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			res, err := client().RebuildStore(cmd.Context())
+			if err != nil {
+				return err
+			}
+			return printJSON(cmd.OutOrStdout(), res)
+		},
+	})
+
+	var (
+		migrateRootDir string
+		migrateFrom    string
+		migrateTo      string
+	)
+	storeMigrateCmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Migrate the machine store to another backend. The provider has to be stopped.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			paths, err := host.PathsAt(migrateRootDir)
+			if err != nil {
+				return err
+			}
+
+			from, closeFrom, err := openMachineStoreBackend(migrateFrom, paths)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = closeFrom() }()
+
+			to, closeTo, err := openMachineStoreBackend(migrateTo, paths)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = closeTo() }()
+
+			n, err := host.Migrate(from, to)
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "migrated %d machines from the %s to the %s backend\n", n, migrateFrom, migrateTo)
+			return nil
+		},
+	}
+	storeMigrateCmd.Flags().StringVar(&migrateRootDir, "libvirt-provider-dir", filepath.Join(homeDir, ".libvirt-provider"), "Path to the directory libvirt-provider manages its content at.")
+	storeMigrateCmd.Flags().StringVar(&migrateFrom, "from", machineStoreBackendDir, "Machine store backend to migrate from, see --machine-store-backend.")
+	storeMigrateCmd.Flags().StringVar(&migrateTo, "to", machineStoreBackendBolt, "Machine store backend to migrate to, see --machine-store-backend.")
+	storeCmd.AddCommand(storeMigrateCmd)
+
+	infoCmd := &cobra.Command{
+		Use:   "info",
+		Short: "Show the runtime information of a running provider.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			res, err := client().Info(cmd.Context())
+			if err != nil {
+				return err
+			}
+			return printJSON(cmd.OutOrStdout(), res)
+		},
+	}
+
+	cmds := []*cobra.Command{machinesCmd, eventsCmd, storeCmd, infoCmd}
+	for _, cmd := range cmds {
+		cmd.PersistentFlags().StringVar(&address, "admin-address", defaultAdminAddress(), "Address of the admin server of the provider, see --servers-admin-address.")
+	}
+	return cmds
+}
+
+func printMachines(out io.Writer, machines []*api.Machine) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tCLASS\tSTATE\tAGE\tFINALIZERS")
+	for _, machine := range machines {
+		class, _ := api.GetClassLabel(machine)
+		state := string(machine.Status.State)
+		if machine.DeletedAt != nil {
+			state += " (deleted)"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			machine.ID,
+			class,
+			state,
+			time.Since(machine.CreatedAt).Round(time.Second),
+			strings.Join(machine.Finalizers, ","),
+		)
+	}
+	return w.Flush()
+}
+
+func printJSON(out io.Writer, v any) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,450 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-logr/logr"
+	irievent "github.com/ironcore-dev/ironcore/iri/apis/event/v1alpha1"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/boot"
+	"github.com/ironcore-dev/libvirt-provider/internal/controllers"
+	"github.com/ironcore-dev/libvirt-provider/internal/event/machineevent"
+	"github.com/ironcore-dev/libvirt-provider/internal/journal"
+	"github.com/ironcore-dev/libvirt-provider/internal/media"
+	"github.com/ironcore-dev/libvirt-provider/internal/oci"
+	"github.com/ironcore-dev/libvirt-provider/internal/providerinfo"
+	"github.com/ironcore-dev/libvirt-provider/internal/store"
+)
+
+const (
+	MachineIDPathValue = "machineID"
+
+	// MediaDrivePathValue is the name of a media drive of the machine.
+	MediaDrivePathValue = "drive"
+
+	// ConfirmQueryParameter has to repeat the machine id to guard against accidental force finalization or
+	// deletion.
+	ConfirmQueryParameter = "confirm"
+
+	// ImageQueryParameter selects the images to report the pull status of. Can be repeated.
+	ImageQueryParameter = "image"
+
+	// MachineQueryParameter restricts the listed events to the ones of a machine.
+	MachineQueryParameter = "machine"
+)
+
+type ForceFinalizer interface {
+	ForceFinalize(ctx context.Context, machineID string) (*controllers.ForceFinalizeResult, error)
+	ForceDelete(ctx context.Context, machineID string) (*controllers.ForceFinalizeResult, error)
+}
+
+type ImagePrePuller interface {
+	PrePull(ctx context.Context, refs []string) []oci.ImageStatus
+	Status(ctx context.Context, refs []string) []oci.ImageStatus
+}
+
+type StoreRebuilder interface {
+	RebuildStore(ctx context.Context) (*controllers.RebuildResult, error)
+}
+
+type InfoCollector interface {
+	Collect(ctx context.Context) (*providerinfo.Info, error)
+}
+
+type Handler struct {
+	Log            logr.Logger
+	ForceFinalizer ForceFinalizer
+	ImagePrePuller ImagePrePuller
+
+	Machines store.Store[*api.Machine]
+	Journal  *journal.Journal
+	Events   machineevent.EventStore
+
+	Info InfoCollector
+
+	StoreRebuilder StoreRebuilder
+}
+
+// PrePullRequest is the body of an image pre-pull request.
+type PrePullRequest struct {
+	Images []string `json:"images"`
+}
+
+// ImagesResponse reports the pull status of images.
+type ImagesResponse struct {
+	Images []oci.ImageStatus `json:"images"`
+}
+
+// MachinesResponse lists the machines of the provider.
+type MachinesResponse struct {
+	Machines []*api.Machine `json:"machines"`
+}
+
+// MachineResponse describes a single machine together with its operation journal and recent events.
+type MachineResponse struct {
+	Machine *api.Machine      `json:"machine"`
+	Journal []journal.Entry   `json:"journal,omitempty"`
+	Events  []*irievent.Event `json:"events,omitempty"`
+}
+
+// EventsResponse lists the recent machine events.
+type EventsResponse struct {
+	Events []*irievent.Event `json:"events"`
+}
+
+// StoreVerifyResponse reports the machine store objects that can't be read back.
+type StoreVerifyResponse struct {
+	Problems []store.Problem `json:"problems"`
+}
+
+func (h Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc(fmt.Sprintf("POST /machines/{%s}/force-finalize", MachineIDPathValue), h.ForceFinalize)
+	mux.HandleFunc(fmt.Sprintf("POST /machines/{%s}/force-delete", MachineIDPathValue), h.ForceDelete)
+	if h.ImagePrePuller != nil {
+		mux.HandleFunc("POST /images/pull", h.PrePullImages)
+		mux.HandleFunc("GET /images/pull", h.ImagesStatus)
+	}
+	if h.Machines != nil {
+		mux.HandleFunc("GET /machines", h.ListMachines)
+		mux.HandleFunc(fmt.Sprintf("GET /machines/{%s}", MachineIDPathValue), h.GetMachine)
+		mux.HandleFunc(fmt.Sprintf("POST /machines/{%s}/boot-override", MachineIDPathValue), h.SetBootOverride)
+		mux.HandleFunc(fmt.Sprintf("DELETE /machines/{%s}/boot-override", MachineIDPathValue), h.ClearBootOverride)
+		mux.HandleFunc(fmt.Sprintf("PUT /machines/{%s}/media/{%s}", MachineIDPathValue, MediaDrivePathValue), h.InsertMedia)
+		mux.HandleFunc(fmt.Sprintf("DELETE /machines/{%s}/media/{%s}", MachineIDPathValue, MediaDrivePathValue), h.EjectMedia)
+		mux.HandleFunc(fmt.Sprintf("POST /machines/{%s}/rescue", MachineIDPathValue), h.Rescue)
+		mux.HandleFunc(fmt.Sprintf("DELETE /machines/{%s}/rescue", MachineIDPathValue), h.Unrescue)
+		mux.HandleFunc("GET /store/verify", h.VerifyStore)
+	}
+	if h.StoreRebuilder != nil {
+		mux.HandleFunc("POST /store/rebuild", h.RebuildStore)
+	}
+	if h.Events != nil {
+		mux.HandleFunc("GET /events", h.ListEvents)
+	}
+	if h.Info != nil {
+		mux.HandleFunc("GET /info", h.GetInfo)
+	}
+}
+
+// ForceFinalize force finalizes a machine stuck in Terminating and responds with what was left behind.
+func (h Handler) ForceFinalize(w http.ResponseWriter, r *http.Request) {
+	h.forceFinalize(w, r, "force finalize", h.ForceFinalizer.ForceFinalize)
+}
+
+// ForceDelete deletes a machine and force finalizes it right away and responds with what was left behind.
+func (h Handler) ForceDelete(w http.ResponseWriter, r *http.Request) {
+	h.forceFinalize(w, r, "force delete", h.ForceFinalizer.ForceDelete)
+}
+
+func (h Handler) forceFinalize(
+	w http.ResponseWriter,
+	r *http.Request,
+	action string,
+	finalize func(ctx context.Context, machineID string) (*controllers.ForceFinalizeResult, error),
+) {
+	machineID := r.PathValue(MachineIDPathValue)
+	log := h.Log.WithValues("MachineID", machineID)
+
+	if machineID == "" || r.URL.Query().Get(ConfirmQueryParameter) != machineID {
+		http.Error(w, fmt.Sprintf("query parameter %q has to match the machine id", ConfirmQueryParameter), http.StatusBadRequest)
+		return
+	}
+
+	result, err := finalize(r.Context(), machineID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			http.Error(w, fmt.Sprintf("machine %s not found", machineID), http.StatusNotFound)
+		case errors.Is(err, controllers.ErrMachineNotTerminating), errors.Is(err, controllers.ErrForceFinalizeTooEarly):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Error(err, fmt.Sprintf("failed to %s machine", action))
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Error(err, "failed to write response")
+	}
+}
+
+// PrePullImages starts pulling the requested images into the image cache and responds with their status.
+func (h Handler) PrePullImages(w http.ResponseWriter, r *http.Request) {
+	req := &PrePullRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(req.Images) == 0 {
+		http.Error(w, "no images specified", http.StatusBadRequest)
+		return
+	}
+
+	h.Log.V(1).Info("Pre-pulling images", "Images", req.Images)
+	h.writeImages(w, http.StatusAccepted, h.ImagePrePuller.PrePull(r.Context(), req.Images))
+}
+
+// ImagesStatus responds with the status of the requested or, if none are requested, all pre-pulled images.
+func (h Handler) ImagesStatus(w http.ResponseWriter, r *http.Request) {
+	h.writeImages(w, http.StatusOK, h.ImagePrePuller.Status(r.Context(), r.URL.Query()[ImageQueryParameter]))
+}
+
+func (h Handler) writeImages(w http.ResponseWriter, code int, images []oci.ImageStatus) {
+	h.writeJSON(w, code, ImagesResponse{Images: images})
+}
+
+// ListMachines responds with all machines of the machine store.
+func (h Handler) ListMachines(w http.ResponseWriter, r *http.Request) {
+	machines, err := h.Machines.List(r.Context())
+	if err != nil {
+		h.Log.Error(err, "failed to list machines")
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, MachinesResponse{Machines: machines})
+}
+
+// GetMachine responds with the machine, its operation journal and its recent events.
+func (h Handler) GetMachine(w http.ResponseWriter, r *http.Request) {
+	machineID := r.PathValue(MachineIDPathValue)
+	log := h.Log.WithValues("MachineID", machineID)
+
+	machine, err := h.Machines.Get(r.Context(), machineID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, fmt.Sprintf("machine %s not found", machineID), http.StatusNotFound)
+			return
+		}
+		log.Error(err, "failed to get machine")
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	res := MachineResponse{Machine: machine}
+	if h.Journal != nil {
+		if res.Journal, err = h.Journal.Read(machineID); err != nil {
+			log.Error(err, "failed to read journal")
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+	}
+	if h.Events != nil {
+		res.Events = h.listEvents(machineID)
+	}
+
+	h.writeJSON(w, http.StatusOK, res)
+}
+
+// SetBootOverride sets the device or rescue ISO image the machine boots once at the next start of its domain and
+// responds with the updated machine.
+func (h Handler) SetBootOverride(w http.ResponseWriter, r *http.Request) {
+	override := &api.BootOverride{}
+	if err := json.NewDecoder(r.Body).Decode(override); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := boot.ValidateOverride(override); err != nil {
+		http.Error(w, fmt.Sprintf("invalid boot override: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	h.updateMachine(w, r, func(machine *api.Machine) error {
+		setBootOverride(machine, override)
+		return nil
+	})
+}
+
+// ClearBootOverride drops the boot override of the machine and responds with the updated machine.
+func (h Handler) ClearBootOverride(w http.ResponseWriter, r *http.Request) {
+	h.updateMachine(w, r, func(machine *api.Machine) error {
+		setBootOverride(machine, nil)
+		return nil
+	})
+}
+
+func setBootOverride(machine *api.Machine, override *api.BootOverride) {
+	if machine.Spec.Boot == nil {
+		machine.Spec.Boot = &api.BootSpec{}
+	}
+	machine.Spec.Boot.Override = override
+}
+
+// InsertMedia inserts the media into the media drive of the machine and responds with the updated machine. The
+// media of a running domain is changed live.
+func (h Handler) InsertMedia(w http.ResponseWriter, r *http.Request) {
+	source := &api.MediaSource{}
+	if err := json.NewDecoder(r.Body).Decode(source); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := media.ValidateSource(source); err != nil {
+		http.Error(w, fmt.Sprintf("invalid media: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	h.updateMachine(w, r, func(machine *api.Machine) error {
+		return setMedia(machine, r.PathValue(MediaDrivePathValue), source)
+	})
+}
+
+// EjectMedia ejects the media of the media drive of the machine and responds with the updated machine.
+func (h Handler) EjectMedia(w http.ResponseWriter, r *http.Request) {
+	h.updateMachine(w, r, func(machine *api.Machine) error {
+		return setMedia(machine, r.PathValue(MediaDrivePathValue), nil)
+	})
+}
+
+func setMedia(machine *api.Machine, drive string, source *api.MediaSource) error {
+	spec, ok := media.Find(machine.Spec.Media, drive)
+	if !ok {
+		return fmt.Errorf("machine %s has no media drive %s", machine.ID, drive)
+	}
+	spec.Source = source
+	return nil
+}
+
+// Rescue restarts the machine into a rescue image and responds with the updated machine. An empty image selects
+// the rescue image of the provider.
+func (h Handler) Rescue(w http.ResponseWriter, r *http.Request) {
+	spec := &api.RescueSpec{}
+	if err := json.NewDecoder(r.Body).Decode(spec); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	h.updateMachine(w, r, func(machine *api.Machine) error {
+		machine.Spec.Rescue = spec
+		return nil
+	})
+}
+
+// Unrescue restarts the rescued machine to boot normally again and responds with the updated machine.
+func (h Handler) Unrescue(w http.ResponseWriter, r *http.Request) {
+	h.updateMachine(w, r, func(machine *api.Machine) error {
+		machine.Spec.Rescue = nil
+		return nil
+	})
+}
+
+// updateMachine applies the update to the machine and responds with the updated machine. Errors of the update
+// mean the machine lacks the updated item and are responded as not found.
+func (h Handler) updateMachine(w http.ResponseWriter, r *http.Request, update func(machine *api.Machine) error) {
+	machineID := r.PathValue(MachineIDPathValue)
+	log := h.Log.WithValues("MachineID", machineID)
+
+	machine, err := h.Machines.Get(r.Context(), machineID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, fmt.Sprintf("machine %s not found", machineID), http.StatusNotFound)
+			return
+		}
+		log.Error(err, "failed to get machine")
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if machine.DeletedAt != nil {
+		http.Error(w, fmt.Sprintf("machine %s is terminating", machineID), http.StatusConflict)
+		return
+	}
+
+	if err := update(machine); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	log.V(1).Info("Updating machine", "Path", r.URL.Path)
+	updated, err := h.Machines.Update(r.Context(), machine)
+	if err != nil {
+		if errors.Is(err, store.ErrResourceVersionNotLatest) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		log.Error(err, "failed to update machine")
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, MachineResponse{Machine: updated})
+}
+
+// ListEvents responds with the recent events of all machines or, if requested, of a single machine.
+func (h Handler) ListEvents(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, http.StatusOK, EventsResponse{Events: h.listEvents(r.URL.Query().Get(MachineQueryParameter))})
+}
+
+func (h Handler) listEvents(machineID string) []*irievent.Event {
+	events := h.Events.ListEvents()
+	if machineID == "" {
+		return events
+	}
+
+	var res []*irievent.Event
+	for _, event := range events {
+		if event.GetSpec().GetInvolvedObjectMeta().GetId() == machineID {
+			res = append(res, event)
+		}
+	}
+	return res
+}
+
+// VerifyStore checks that all objects of the machine store can be read back and responds with the ones that can't.
+func (h Handler) VerifyStore(w http.ResponseWriter, r *http.Request) {
+	verifier, ok := h.Machines.(store.Verifier)
+	if !ok {
+		http.Error(w, "machine store doesn't support verification", http.StatusNotImplemented)
+		return
+	}
+
+	problems, err := verifier.Verify(r.Context())
+	if err != nil {
+		h.Log.Error(err, "failed to verify machine store")
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, StoreVerifyResponse{Problems: problems})
+}
+
+// RebuildStore adds the machines missing in the machine store from the libvirt domains and responds with the result.
+func (h Handler) RebuildStore(w http.ResponseWriter, r *http.Request) {
+	h.Log.Info("Rebuilding machine store from domains")
+	res, err := h.StoreRebuilder.RebuildStore(r.Context())
+	if err != nil {
+		h.Log.Error(err, "failed to rebuild machine store")
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, res)
+}
+
+// GetInfo responds with the runtime information of the provider.
+func (h Handler) GetInfo(w http.ResponseWriter, r *http.Request) {
+	info, err := h.Info.Collect(r.Context())
+	if err != nil {
+		h.Log.Error(err, "failed to collect provider info")
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, info)
+}
+
+func (h Handler) writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		h.Log.Error(err, "failed to write response")
+	}
+}
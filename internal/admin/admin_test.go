@@ -0,0 +1,330 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package admin_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-logr/logr"
+	irievent "github.com/ironcore-dev/ironcore/iri/apis/event/v1alpha1"
+	irimeta "github.com/ironcore-dev/ironcore/iri/apis/meta/v1alpha1"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/admin"
+	"github.com/ironcore-dev/libvirt-provider/internal/controllers"
+	"github.com/ironcore-dev/libvirt-provider/internal/host"
+	"github.com/ironcore-dev/libvirt-provider/internal/oci"
+	"github.com/ironcore-dev/libvirt-provider/internal/providerinfo"
+	"github.com/ironcore-dev/libvirt-provider/internal/store"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type fakeForceFinalizer struct {
+	err     error
+	called  []string
+	deleted []string
+}
+
+func (f *fakeForceFinalizer) ForceFinalize(_ context.Context, machineID string) (*controllers.ForceFinalizeResult, error) {
+	f.called = append(f.called, machineID)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &controllers.ForceFinalizeResult{MachineID: machineID, LeftBehind: []string{"volume foo: boom"}}, nil
+}
+
+func (f *fakeForceFinalizer) ForceDelete(_ context.Context, machineID string) (*controllers.ForceFinalizeResult, error) {
+	f.deleted = append(f.deleted, machineID)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &controllers.ForceFinalizeResult{MachineID: machineID}, nil
+}
+
+type fakeImagePrePuller struct {
+	pulled []string
+}
+
+func (f *fakeImagePrePuller) PrePull(_ context.Context, refs []string) []oci.ImageStatus {
+	f.pulled = append(f.pulled, refs...)
+	return f.Status(context.Background(), refs)
+}
+
+func (f *fakeImagePrePuller) Status(_ context.Context, refs []string) []oci.ImageStatus {
+	if len(refs) == 0 {
+		refs = f.pulled
+	}
+	var res []oci.ImageStatus
+	for _, ref := range refs {
+		res = append(res, oci.ImageStatus{Ref: ref, State: oci.PullStatePulling})
+	}
+	return res
+}
+
+type fakeEventStore []*irievent.Event
+
+func (f fakeEventStore) ListEvents() []*irievent.Event {
+	return f
+}
+
+type fakeInfoCollector struct{}
+
+func (fakeInfoCollector) Collect(context.Context) (*providerinfo.Info, error) {
+	return &providerinfo.Info{
+		LibvirtVersion: "10.0.0",
+		Machines:       map[api.MachineState]int{api.MachineStateRunning: 1},
+	}, nil
+}
+
+type fakeStoreRebuilder struct{}
+
+func (fakeStoreRebuilder) RebuildStore(context.Context) (*controllers.RebuildResult, error) {
+	return &controllers.RebuildResult{
+		Rebuilt:  []string{"bar"},
+		Existing: []string{"foo"},
+	}, nil
+}
+
+func machineEvent(machineID, reason string) *irievent.Event {
+	return &irievent.Event{Spec: &irievent.EventSpec{
+		InvolvedObjectMeta: &irimeta.ObjectMetadata{Id: machineID},
+		Reason:             reason,
+	}}
+}
+
+var _ = Describe("Handler", func() {
+	var (
+		finalizer *fakeForceFinalizer
+		prePuller *fakeImagePrePuller
+		mux       *http.ServeMux
+	)
+
+	BeforeEach(func() {
+		finalizer = &fakeForceFinalizer{}
+		prePuller = &fakeImagePrePuller{}
+		mux = http.NewServeMux()
+		admin.Handler{Log: logr.Discard(), ForceFinalizer: finalizer, ImagePrePuller: prePuller}.Register(mux)
+	})
+
+	forceFinalize := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+		return rec
+	}
+
+	It("should reject requests without matching confirmation", func() {
+		rec := forceFinalize("/machines/foo/force-finalize?confirm=bar")
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+		Expect(finalizer.called).To(BeEmpty())
+	})
+
+	It("should force finalize and report leftovers", func() {
+		rec := forceFinalize("/machines/foo/force-finalize?confirm=foo")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(ContainSubstring("volume foo: boom"))
+		Expect(finalizer.called).To(ConsistOf("foo"))
+	})
+
+	It("should map errors to status codes", func() {
+		finalizer.err = store.ErrNotFound
+		Expect(forceFinalize("/machines/foo/force-finalize?confirm=foo").Code).To(Equal(http.StatusNotFound))
+
+		finalizer.err = fmt.Errorf("%w: terminating for 1s", controllers.ErrForceFinalizeTooEarly)
+		Expect(forceFinalize("/machines/foo/force-finalize?confirm=foo").Code).To(Equal(http.StatusConflict))
+	})
+
+	It("should force delete machines", func() {
+		Expect(forceFinalize("/machines/foo/force-delete?confirm=bar").Code).To(Equal(http.StatusBadRequest))
+		Expect(finalizer.deleted).To(BeEmpty())
+
+		rec := forceFinalize("/machines/foo/force-delete?confirm=foo")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(finalizer.deleted).To(ConsistOf("foo"))
+		Expect(finalizer.called).To(BeEmpty())
+	})
+
+	It("should pre-pull images and report their status", func() {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/images/pull", strings.NewReader(`{"images":["foo:v1","bar:v2"]}`)))
+		Expect(rec.Code).To(Equal(http.StatusAccepted))
+		Expect(prePuller.pulled).To(ConsistOf("foo:v1", "bar:v2"))
+
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/images/pull?image=foo:v1", nil))
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(ContainSubstring(`"ref":"foo:v1","state":"Pulling"`))
+		Expect(rec.Body.String()).NotTo(ContainSubstring("bar:v2"))
+	})
+
+	It("should reject pre-pull requests without images", func() {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/images/pull", strings.NewReader(`{}`)))
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	Context("maintenance", func() {
+		var (
+			dir    string
+			client *admin.Client
+		)
+
+		BeforeEach(func(ctx SpecContext) {
+			dir = GinkgoT().TempDir()
+			machineStore, err := host.NewStore[*api.Machine](host.Options[*api.Machine]{
+				Dir:     dir,
+				NewFunc: func() *api.Machine { return &api.Machine{} },
+			})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = machineStore.Create(ctx, &api.Machine{
+				Metadata: api.Metadata{ID: "foo"},
+				Spec:     api.MachineSpec{Media: []*api.MediaSpec{{Name: "installer"}}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			mux := http.NewServeMux()
+			admin.Handler{
+				Log:            logr.Discard(),
+				ForceFinalizer: finalizer,
+				Machines:       machineStore,
+				Events:         fakeEventStore{machineEvent("foo", "Created"), machineEvent("bar", "Created")},
+				Info:           fakeInfoCollector{},
+				StoreRebuilder: fakeStoreRebuilder{},
+			}.Register(mux)
+			srv := httptest.NewServer(mux)
+			DeferCleanup(srv.Close)
+			client = admin.NewClient(srv.Listener.Addr().String())
+		})
+
+		It("should list and inspect machines", func(ctx SpecContext) {
+			machines, err := client.ListMachines(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(machines.Machines).To(ConsistOf(HaveField("ID", "foo")))
+
+			machine, err := client.GetMachine(ctx, "foo")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(machine.Machine.ID).To(Equal("foo"))
+			Expect(machine.Events).To(HaveLen(1))
+
+			_, err = client.GetMachine(ctx, "bar")
+			Expect(err).To(MatchError(ContainSubstring("404")))
+		})
+
+		It("should list the events of a machine", func(ctx SpecContext) {
+			events, err := client.ListEvents(ctx, "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events.Events).To(HaveLen(2))
+
+			events, err = client.ListEvents(ctx, "bar")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events.Events).To(ConsistOf(HaveField("Spec.InvolvedObjectMeta.Id", "bar")))
+		})
+
+		It("should force delete machines", func(ctx SpecContext) {
+			res, err := client.ForceFinalize(ctx, "foo")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.LeftBehind).To(ConsistOf("volume foo: boom"))
+			Expect(finalizer.called).To(ConsistOf("foo"))
+		})
+
+		It("should report the provider info", func(ctx SpecContext) {
+			info, err := client.Info(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.LibvirtVersion).To(Equal("10.0.0"))
+			Expect(info.Machines).To(HaveKeyWithValue(api.MachineStateRunning, 1))
+		})
+
+		It("should verify the machine store", func(ctx SpecContext) {
+			res, err := client.VerifyStore(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.Problems).To(BeEmpty())
+
+			Expect(os.WriteFile(filepath.Join(dir, "corrupt"), []byte("not json"), 0666)).To(Succeed())
+			res, err = client.VerifyStore(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.Problems).To(ConsistOf(HaveField("ID", "corrupt")))
+		})
+
+		It("should set and clear boot overrides", func(ctx SpecContext) {
+			res, err := client.SetBootOverride(ctx, "foo", &api.BootOverride{ISO: "rescue.iso"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.Machine.Spec.Boot.Override).To(Equal(&api.BootOverride{ISO: "rescue.iso"}))
+
+			machine, err := client.GetMachine(ctx, "foo")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(machine.Machine.Spec.Boot.Override.ISO).To(Equal("rescue.iso"))
+
+			_, err = client.SetBootOverride(ctx, "foo", &api.BootOverride{ISO: "../rescue.iso"})
+			Expect(err).To(MatchError(ContainSubstring("400")))
+			_, err = client.SetBootOverride(ctx, "bar", &api.BootOverride{ISO: "rescue.iso"})
+			Expect(err).To(MatchError(ContainSubstring("404")))
+
+			res, err = client.ClearBootOverride(ctx, "foo")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.Machine.Spec.Boot.Override).To(BeNil())
+		})
+
+		It("should insert and eject media", func(ctx SpecContext) {
+			res, err := client.InsertMedia(ctx, "foo", "installer", &api.MediaSource{Path: "installer.iso"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.Machine.Spec.Media).To(ConsistOf(&api.MediaSpec{Name: "installer", Source: &api.MediaSource{Path: "installer.iso"}}))
+
+			_, err = client.InsertMedia(ctx, "foo", "installer", &api.MediaSource{Path: "/etc/passwd"})
+			Expect(err).To(MatchError(ContainSubstring("400")))
+			_, err = client.InsertMedia(ctx, "foo", "tools", &api.MediaSource{Path: "tools.iso"})
+			Expect(err).To(MatchError(ContainSubstring("404")))
+
+			res, err = client.EjectMedia(ctx, "foo", "installer")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.Machine.Spec.Media).To(ConsistOf(&api.MediaSpec{Name: "installer"}))
+		})
+
+		It("should rescue and unrescue machines", func(ctx SpecContext) {
+			res, err := client.Rescue(ctx, "foo", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.Machine.Spec.Rescue).To(Equal(&api.RescueSpec{}))
+
+			res, err = client.Rescue(ctx, "foo", "ghcr.io/foo/rescue:1.0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.Machine.Spec.Rescue).To(Equal(&api.RescueSpec{Image: "ghcr.io/foo/rescue:1.0"}))
+
+			res, err = client.Unrescue(ctx, "foo")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.Machine.Spec.Rescue).To(BeNil())
+
+			_, err = client.Rescue(ctx, "bar", "")
+			Expect(err).To(MatchError(ContainSubstring("404")))
+		})
+
+		It("should rebuild the machine store", func(ctx SpecContext) {
+			res, err := client.RebuildStore(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.Rebuilt).To(ConsistOf("bar"))
+			Expect(res.Existing).To(ConsistOf("foo"))
+		})
+	})
+
+	It("should serve on unix sockets", func(ctx SpecContext) {
+		address := admin.UnixAddressPrefix + filepath.Join(GinkgoT().TempDir(), "admin.sock")
+		listener, err := admin.Listen(address)
+		Expect(err).NotTo(HaveOccurred())
+		srv := &http.Server{Handler: mux}
+		go func() { _ = srv.Serve(listener) }()
+		DeferCleanup(srv.Close)
+
+		By("refusing to take over a socket in use")
+		_, err = admin.Listen(address)
+		Expect(err).To(MatchError(ContainSubstring("in use")))
+
+		By("talking to the handler via the socket")
+		res, err := admin.NewClient(address).ForceFinalize(ctx, "foo")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.MachineID).To(Equal("foo"))
+	})
+})
@@ -0,0 +1,253 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/controllers"
+	"github.com/ironcore-dev/libvirt-provider/internal/providerinfo"
+)
+
+// UnixAddressPrefix marks admin addresses referring to a unix socket, e.g. unix:///run/libvirt-provider/admin.sock.
+const UnixAddressPrefix = "unix://"
+
+// Listen listens on the admin address, which is either a unix socket prefixed with UnixAddressPrefix or a
+// tcp address. A stale unix socket of a previous run is removed.
+func Listen(address string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(address, UnixAddressPrefix)
+	if !ok {
+		return net.Listen("tcp", address)
+	}
+
+	if conn, err := net.Dial("unix", path); err == nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("admin socket %s is in use", path)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("error removing stale admin socket: %w", err)
+	}
+	return net.Listen("unix", path)
+}
+
+// Client talks to the admin server of a running provider.
+type Client struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewClient creates a client for the admin server listening on the given address, see Listen.
+func NewClient(address string) *Client {
+	path, ok := strings.CutPrefix(address, UnixAddressPrefix)
+	if !ok {
+		return &Client{
+			baseURL: "http://" + address,
+			client:  &http.Client{},
+		}
+	}
+
+	return &Client{
+		baseURL: "http://admin",
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, "unix", path)
+				},
+			},
+		},
+	}
+}
+
+// ListMachines lists all machines of the provider.
+func (c *Client) ListMachines(ctx context.Context) (*MachinesResponse, error) {
+	res := &MachinesResponse{}
+	if err := c.do(ctx, http.MethodGet, "/machines", nil, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetMachine returns the machine together with its operation journal and recent events.
+func (c *Client) GetMachine(ctx context.Context, machineID string) (*MachineResponse, error) {
+	res := &MachineResponse{}
+	if err := c.do(ctx, http.MethodGet, "/machines/"+url.PathEscape(machineID), nil, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ForceFinalize force finalizes a machine stuck in Terminating.
+func (c *Client) ForceFinalize(ctx context.Context, machineID string) (*controllers.ForceFinalizeResult, error) {
+	query := url.Values{ConfirmQueryParameter: []string{machineID}}
+	res := &controllers.ForceFinalizeResult{}
+	if err := c.do(ctx, http.MethodPost, "/machines/"+url.PathEscape(machineID)+"/force-finalize", query, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ForceDelete deletes a machine and force finalizes it right away.
+func (c *Client) ForceDelete(ctx context.Context, machineID string) (*controllers.ForceFinalizeResult, error) {
+	query := url.Values{ConfirmQueryParameter: []string{machineID}}
+	res := &controllers.ForceFinalizeResult{}
+	if err := c.do(ctx, http.MethodPost, "/machines/"+url.PathEscape(machineID)+"/force-delete", query, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// SetBootOverride sets the device or rescue ISO image the machine boots once at the next start of its domain.
+func (c *Client) SetBootOverride(ctx context.Context, machineID string, override *api.BootOverride) (*MachineResponse, error) {
+	res := &MachineResponse{}
+	if err := c.doBody(ctx, http.MethodPost, "/machines/"+url.PathEscape(machineID)+"/boot-override", nil, override, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ClearBootOverride drops the boot override of the machine.
+func (c *Client) ClearBootOverride(ctx context.Context, machineID string) (*MachineResponse, error) {
+	res := &MachineResponse{}
+	if err := c.do(ctx, http.MethodDelete, "/machines/"+url.PathEscape(machineID)+"/boot-override", nil, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// InsertMedia inserts the media into the media drive of the machine.
+func (c *Client) InsertMedia(ctx context.Context, machineID, drive string, source *api.MediaSource) (*MachineResponse, error) {
+	res := &MachineResponse{}
+	if err := c.doBody(ctx, http.MethodPut, mediaPath(machineID, drive), nil, source, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// EjectMedia ejects the media of the media drive of the machine.
+func (c *Client) EjectMedia(ctx context.Context, machineID, drive string) (*MachineResponse, error) {
+	res := &MachineResponse{}
+	if err := c.do(ctx, http.MethodDelete, mediaPath(machineID, drive), nil, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Rescue restarts the machine into the rescue image. An empty image selects the rescue image of the provider.
+func (c *Client) Rescue(ctx context.Context, machineID, image string) (*MachineResponse, error) {
+	res := &MachineResponse{}
+	if err := c.doBody(ctx, http.MethodPost, "/machines/"+url.PathEscape(machineID)+"/rescue", nil, &api.RescueSpec{Image: image}, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Unrescue restarts the rescued machine to boot normally again.
+func (c *Client) Unrescue(ctx context.Context, machineID string) (*MachineResponse, error) {
+	res := &MachineResponse{}
+	if err := c.do(ctx, http.MethodDelete, "/machines/"+url.PathEscape(machineID)+"/rescue", nil, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func mediaPath(machineID, drive string) string {
+	return "/machines/" + url.PathEscape(machineID) + "/media/" + url.PathEscape(drive)
+}
+
+// ListEvents lists the recent events of all machines or, if machineID is set, of a single machine.
+func (c *Client) ListEvents(ctx context.Context, machineID string) (*EventsResponse, error) {
+	query := url.Values{}
+	if machineID != "" {
+		query.Set(MachineQueryParameter, machineID)
+	}
+	res := &EventsResponse{}
+	if err := c.do(ctx, http.MethodGet, "/events", query, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// VerifyStore reports the machine store objects that can't be read back.
+func (c *Client) VerifyStore(ctx context.Context) (*StoreVerifyResponse, error) {
+	res := &StoreVerifyResponse{}
+	if err := c.do(ctx, http.MethodGet, "/store/verify", nil, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// RebuildStore adds the machines missing in the machine store from the libvirt domains.
+func (c *Client) RebuildStore(ctx context.Context) (*controllers.RebuildResult, error) {
+	res := &controllers.RebuildResult{}
+	if err := c.do(ctx, http.MethodPost, "/store/rebuild", nil, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Info returns the runtime information of the provider.
+func (c *Client) Info(ctx context.Context) (*providerinfo.Info, error) {
+	res := &providerinfo.Info{}
+	if err := c.do(ctx, http.MethodGet, "/info", nil, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, v any) error {
+	return c.doBody(ctx, method, path, query, nil, v)
+}
+
+// doBody sends the request with the body encoded as JSON, if set, and decodes the response into v.
+func (c *Client) doBody(ctx context.Context, method, path string, query url.Values, body, v any) error {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("error encoding request: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, reqBody)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error talking to admin server: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("admin server responded with %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+	return nil
+}
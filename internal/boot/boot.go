@@ -0,0 +1,188 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package boot renders the boot order of machines into their domains. The order is set on the devices, so it
+// spans specific disks and network interfaces instead of device classes.
+package boot
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/alias"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/device"
+	"libvirt.org/go/libvirtxml"
+)
+
+const (
+	rootFSToken            = "rootfs"
+	volumePrefix           = "volume:"
+	networkInterfacePrefix = "network:"
+	mediaPrefix            = "media:"
+)
+
+// ParseOrder parses the value of the api.BootOrderAnnotation. An empty value results in no devices.
+func ParseOrder(s string) ([]api.BootDevice, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var (
+		devices []api.BootDevice
+		seen    = make(map[api.BootDevice]bool)
+	)
+	for _, token := range strings.Split(s, ",") {
+		token = strings.TrimSpace(token)
+
+		var device api.BootDevice
+		switch {
+		case token == rootFSToken:
+			device = api.BootDevice{Type: api.BootDeviceTypeRootFS}
+		case strings.HasPrefix(token, volumePrefix):
+			device = api.BootDevice{Type: api.BootDeviceTypeVolume, Name: strings.TrimPrefix(token, volumePrefix)}
+		case strings.HasPrefix(token, networkInterfacePrefix):
+			device = api.BootDevice{Type: api.BootDeviceTypeNetworkInterface, Name: strings.TrimPrefix(token, networkInterfacePrefix)}
+		case strings.HasPrefix(token, mediaPrefix):
+			device = api.BootDevice{Type: api.BootDeviceTypeMedia, Name: strings.TrimPrefix(token, mediaPrefix)}
+		default:
+			return nil, fmt.Errorf("invalid boot device %q, expected %s, %s<name>, %s<name> or %s<name>", token, rootFSToken, volumePrefix, networkInterfacePrefix, mediaPrefix)
+		}
+
+		if err := ValidateDevice(device); err != nil {
+			return nil, err
+		}
+		if seen[device] {
+			return nil, fmt.Errorf("duplicate boot device %q", token)
+		}
+		seen[device] = true
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+// ValidateDevice checks that the boot device refers to a single device of a machine.
+func ValidateDevice(device api.BootDevice) error {
+	switch device.Type {
+	case api.BootDeviceTypeRootFS:
+		if device.Name != "" {
+			return fmt.Errorf("root fs boot device must not specify a name")
+		}
+	case api.BootDeviceTypeVolume, api.BootDeviceTypeNetworkInterface, api.BootDeviceTypeMedia:
+		if device.Name == "" {
+			return fmt.Errorf("%s boot device has to specify a name", device.Type)
+		}
+	default:
+		return fmt.Errorf("unsupported boot device type %q", device.Type)
+	}
+	return nil
+}
+
+// ValidateOverride checks that the override specifies either a valid device or the plain file name of an ISO
+// image.
+func ValidateOverride(override *api.BootOverride) error {
+	switch {
+	case override.Device != nil && override.ISO != "":
+		return fmt.Errorf("must not specify both device and iso")
+	case override.Device != nil:
+		return ValidateDevice(*override.Device)
+	case override.ISO != "":
+		if filepath.Base(override.ISO) != override.ISO || override.ISO == "." || override.ISO == ".." {
+			return fmt.Errorf("iso %q has to be a file name", override.ISO)
+		}
+		return nil
+	default:
+		return fmt.Errorf("must specify device or iso")
+	}
+}
+
+// Alias returns the alias of the domain device backing the boot device.
+func Alias(device api.BootDevice) string {
+	switch device.Type {
+	case api.BootDeviceTypeVolume:
+		return alias.Volume(device.Name)
+	case api.BootDeviceTypeNetworkInterface:
+		return alias.NetworkInterface(device.Name)
+	case api.BootDeviceTypeMedia:
+		return alias.Media(device.Name)
+	default:
+		return alias.RootFS
+	}
+}
+
+// RescueDisk returns the cdrom disk of the rescue ISO image file.
+func RescueDisk(file string) libvirtxml.DomainDisk {
+	return libvirtxml.DomainDisk{
+		Alias: &libvirtxml.DomainAlias{
+			Name: alias.Rescue,
+		},
+		Device: "cdrom",
+		Driver: &libvirtxml.DomainDiskDriver{
+			Name: "qemu",
+			Type: "raw",
+		},
+		Source: &libvirtxml.DomainDiskSource{
+			File: &libvirtxml.DomainDiskSourceFile{
+				File: file,
+			},
+		},
+		Target: &libvirtxml.DomainDiskTarget{
+			Dev: device.RescueTarget,
+			Bus: "sata",
+		},
+		ReadOnly: &libvirtxml.DomainDiskReadOnly{},
+	}
+}
+
+// SetOrder numbers the devices of the domain with the given aliases in order and removes any other boot
+// configuration of the domain. It returns the aliases the domain has no device for.
+func SetOrder(domain *libvirtxml.Domain, aliases []string) []string {
+	if domain.OS != nil {
+		domain.OS.BootDevices = nil
+	}
+	if domain.Devices == nil {
+		return aliases
+	}
+
+	boots := make(map[string]**libvirtxml.DomainDeviceBoot)
+	for i := range domain.Devices.Disks {
+		disk := &domain.Devices.Disks[i]
+		disk.Boot = nil
+		if disk.Alias != nil {
+			boots[disk.Alias.Name] = &disk.Boot
+		}
+	}
+	for i := range domain.Devices.Interfaces {
+		iface := &domain.Devices.Interfaces[i]
+		iface.Boot = nil
+		if iface.Alias != nil {
+			boots[iface.Alias.Name] = &iface.Boot
+		}
+	}
+	for i := range domain.Devices.Hostdevs {
+		hostdev := &domain.Devices.Hostdevs[i]
+		hostdev.Boot = nil
+		if hostdev.Alias != nil {
+			boots[hostdev.Alias.Name] = &hostdev.Boot
+		}
+	}
+
+	var (
+		missing []string
+		order   uint = 1
+	)
+	for _, name := range aliases {
+		boot, ok := boots[name]
+		if !ok || *boot != nil {
+			if !ok {
+				missing = append(missing, name)
+			}
+			continue
+		}
+
+		*boot = &libvirtxml.DomainDeviceBoot{Order: order}
+		order++
+	}
+	return missing
+}
@@ -0,0 +1,76 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package boot_test
+
+import (
+	"github.com/ironcore-dev/libvirt-provider/api"
+	. "github.com/ironcore-dev/libvirt-provider/internal/boot"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/alias"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"libvirt.org/go/libvirtxml"
+)
+
+var _ = Describe("Boot", func() {
+	Describe("ParseOrder", func() {
+		It("should return no devices for an empty value", func() {
+			Expect(ParseOrder("")).To(BeEmpty())
+		})
+
+		It("should parse the boot devices in order", func() {
+			Expect(ParseOrder("network:nic-1, volume:data,rootfs,media:installer")).To(Equal([]api.BootDevice{
+				{Type: api.BootDeviceTypeNetworkInterface, Name: "nic-1"},
+				{Type: api.BootDeviceTypeVolume, Name: "data"},
+				{Type: api.BootDeviceTypeRootFS},
+				{Type: api.BootDeviceTypeMedia, Name: "installer"},
+			}))
+		})
+
+		It("should reject invalid boot devices", func() {
+			_, err := ParseOrder("cdrom")
+			Expect(err).To(MatchError(ContainSubstring("invalid boot device")))
+			_, err = ParseOrder("volume:")
+			Expect(err).To(MatchError(ContainSubstring("has to specify a name")))
+			_, err = ParseOrder("rootfs,rootfs")
+			Expect(err).To(MatchError(ContainSubstring("duplicate")))
+		})
+	})
+
+	It("should validate overrides", func() {
+		Expect(ValidateOverride(&api.BootOverride{ISO: "rescue.iso"})).To(Succeed())
+		Expect(ValidateOverride(&api.BootOverride{Device: &api.BootDevice{Type: api.BootDeviceTypeRootFS}})).To(Succeed())
+		Expect(ValidateOverride(&api.BootOverride{})).NotTo(Succeed())
+		Expect(ValidateOverride(&api.BootOverride{ISO: "../rescue.iso"})).NotTo(Succeed())
+		Expect(ValidateOverride(&api.BootOverride{ISO: "rescue.iso", Device: &api.BootDevice{Type: api.BootDeviceTypeRootFS}})).NotTo(Succeed())
+	})
+
+	It("should set the boot order on the devices of the domain", func() {
+		domain := &libvirtxml.Domain{
+			OS: &libvirtxml.DomainOS{
+				BootDevices: []libvirtxml.DomainBootDevice{{Dev: "hd"}},
+			},
+			Devices: &libvirtxml.DomainDeviceList{
+				Disks: []libvirtxml.DomainDisk{
+					{Alias: &libvirtxml.DomainAlias{Name: alias.RootFS}, Boot: &libvirtxml.DomainDeviceBoot{Order: 1}},
+					{Alias: &libvirtxml.DomainAlias{Name: alias.Volume("data")}},
+				},
+				Interfaces: []libvirtxml.DomainInterface{
+					{Alias: &libvirtxml.DomainAlias{Name: alias.NetworkInterface("nic-1")}},
+				},
+			},
+		}
+
+		missing := SetOrder(domain, []string{
+			alias.NetworkInterface("nic-1"),
+			alias.Volume("missing"),
+			alias.Volume("data"),
+			alias.NetworkInterface("nic-1"),
+		})
+		Expect(missing).To(Equal([]string{alias.Volume("missing")}))
+		Expect(domain.OS.BootDevices).To(BeEmpty())
+		Expect(domain.Devices.Interfaces[0].Boot).To(Equal(&libvirtxml.DomainDeviceBoot{Order: 1}))
+		Expect(domain.Devices.Disks[1].Boot).To(Equal(&libvirtxml.DomainDeviceBoot{Order: 2}))
+		Expect(domain.Devices.Disks[0].Boot).To(BeNil())
+	})
+})
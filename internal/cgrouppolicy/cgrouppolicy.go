@@ -0,0 +1,90 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package cgrouppolicy places the QEMU processes of domains in the cgroup partition of the machine class and
+// weighs their cpu, memory and block io against the other processes of the partition.
+package cgrouppolicy
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"libvirt.org/go/libvirtxml"
+)
+
+const (
+	// MinIOWeight and MaxIOWeight bound the block io weight accepted by libvirt.
+	MinIOWeight = 100
+	MaxIOWeight = 1000
+)
+
+// Validate checks the partition and weights of the spec.
+func Validate(spec *api.CgroupSpec) error {
+	if spec == nil {
+		return nil
+	}
+
+	if spec.Partition != "" && (!path.IsAbs(spec.Partition) || path.Clean(spec.Partition) != spec.Partition || spec.Partition == "/") {
+		return fmt.Errorf("partition %q has to be a clean absolute path below the root, e.g. /machine/gold", spec.Partition)
+	}
+	if spec.IOWeight != 0 && (spec.IOWeight < MinIOWeight || spec.IOWeight > MaxIOWeight) {
+		return fmt.Errorf("io weight %d has to be between %d and %d", spec.IOWeight, MinIOWeight, MaxIOWeight)
+	}
+	if spec.MemorySoftLimitBytes < 0 {
+		return fmt.Errorf("memory soft limit has to be positive")
+	}
+	return nil
+}
+
+// LoadClassPolicies loads the cgroup policies per machine class name from a YAML or JSON file.
+func LoadClassPolicies(filename string) (map[string]api.CgroupSpec, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open cgroup class policies file (%s): %w", filename, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var policies map[string]api.CgroupSpec
+	if err := yaml.NewYAMLOrJSONDecoder(file, 4096).Decode(&policies); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal cgroup class policies: %w", err)
+	}
+
+	for class, spec := range policies {
+		if err := Validate(&spec); err != nil {
+			return nil, fmt.Errorf("invalid cgroup policy of class %s: %w", class, err)
+		}
+	}
+	return policies, nil
+}
+
+// SetCgroup sets the partition and the weights of the spec in the domain. A nil spec leaves the QEMU process
+// in the default partition of libvirt with the default weights.
+func SetCgroup(spec *api.CgroupSpec, domain *libvirtxml.Domain) {
+	if spec == nil {
+		return
+	}
+
+	if spec.Partition != "" {
+		domain.Resource = &libvirtxml.DomainResource{Partition: spec.Partition}
+	}
+	if spec.CPUShares > 0 {
+		if domain.CPUTune == nil {
+			domain.CPUTune = &libvirtxml.DomainCPUTune{}
+		}
+		domain.CPUTune.Shares = &libvirtxml.DomainCPUTuneShares{Value: spec.CPUShares}
+	}
+	if spec.IOWeight > 0 {
+		domain.BlockIOTune = &libvirtxml.DomainBlockIOTune{Weight: spec.IOWeight}
+	}
+	if spec.MemorySoftLimitBytes > 0 {
+		domain.MemoryTune = &libvirtxml.DomainMemoryTune{
+			SoftLimit: &libvirtxml.DomainMemoryTuneLimit{
+				Value: uint64(spec.MemorySoftLimitBytes) >> 10,
+				Unit:  "KiB",
+			},
+		}
+	}
+}
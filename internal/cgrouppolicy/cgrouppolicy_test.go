@@ -0,0 +1,80 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package cgrouppolicy_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	. "github.com/ironcore-dev/libvirt-provider/internal/cgrouppolicy"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"libvirt.org/go/libvirtxml"
+)
+
+var _ = Describe("CgroupPolicy", func() {
+	It("should validate the cgroup", func() {
+		Expect(Validate(nil)).To(Succeed())
+		Expect(Validate(&api.CgroupSpec{Partition: "/machine/gold", CPUShares: 2048, IOWeight: 500})).To(Succeed())
+
+		Expect(Validate(&api.CgroupSpec{Partition: "machine/gold"})).To(HaveOccurred())
+		Expect(Validate(&api.CgroupSpec{Partition: "/machine/../gold"})).To(HaveOccurred())
+		Expect(Validate(&api.CgroupSpec{Partition: "/"})).To(HaveOccurred())
+		Expect(Validate(&api.CgroupSpec{IOWeight: 50})).To(HaveOccurred())
+		Expect(Validate(&api.CgroupSpec{MemorySoftLimitBytes: -1})).To(HaveOccurred())
+	})
+
+	It("should load the class policies", func() {
+		filename := filepath.Join(GinkgoT().TempDir(), "policies.yaml")
+		Expect(os.WriteFile(filename, []byte(`x3-gold:
+  partition: /machine/gold
+  cpuShares: 2048
+  ioWeight: 800
+  memorySoftLimitBytes: 1073741824
+x3-small: {}
+`), 0644)).To(Succeed())
+
+		Expect(LoadClassPolicies(filename)).To(Equal(map[string]api.CgroupSpec{
+			"x3-gold": {
+				Partition:            "/machine/gold",
+				CPUShares:            2048,
+				IOWeight:             800,
+				MemorySoftLimitBytes: 1 << 30,
+			},
+			"x3-small": {},
+		}))
+
+		Expect(os.WriteFile(filename, []byte("x3-gold:\n  ioWeight: 5000\n"), 0644)).To(Succeed())
+		_, err := LoadClassPolicies(filename)
+		Expect(err).To(MatchError(ContainSubstring("invalid cgroup policy of class x3-gold")))
+	})
+
+	It("should set the partition and weights of the policy", func() {
+		domain := &libvirtxml.Domain{
+			CPUTune: &libvirtxml.DomainCPUTune{
+				EmulatorPin: &libvirtxml.DomainCPUTuneEmulatorPin{CPUSet: "0-1"},
+			},
+		}
+		SetCgroup(&api.CgroupSpec{
+			Partition:            "/machine/gold",
+			CPUShares:            2048,
+			IOWeight:             800,
+			MemorySoftLimitBytes: 1 << 30,
+		}, domain)
+
+		Expect(domain.Resource).To(Equal(&libvirtxml.DomainResource{Partition: "/machine/gold"}))
+		Expect(domain.CPUTune.EmulatorPin.CPUSet).To(Equal("0-1"))
+		Expect(domain.CPUTune.Shares).To(Equal(&libvirtxml.DomainCPUTuneShares{Value: 2048}))
+		Expect(domain.BlockIOTune).To(Equal(&libvirtxml.DomainBlockIOTune{Weight: 800}))
+		Expect(domain.MemoryTune.SoftLimit).To(Equal(&libvirtxml.DomainMemoryTuneLimit{Value: 1 << 20, Unit: "KiB"}))
+	})
+
+	It("should leave the domain of machines without policy unchanged", func() {
+		domain := &libvirtxml.Domain{}
+		SetCgroup(nil, domain)
+		SetCgroup(&api.CgroupSpec{}, domain)
+		Expect(domain).To(Equal(&libvirtxml.Domain{}))
+	})
+})
@@ -0,0 +1,112 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package clockpolicy renders the clock and timers of domains from the clock policy of the machine class, e.g.
+// a localtime rtc and the Hyper-V reference clock for Windows guests.
+package clockpolicy
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"libvirt.org/go/libvirtxml"
+)
+
+var offsets = []string{"utc", "localtime"}
+
+// Validate checks that the clock of the spec is supported.
+func Validate(spec *api.ClockSpec) error {
+	if spec == nil {
+		return nil
+	}
+
+	if spec.Offset != "" && !slices.Contains(offsets, spec.Offset) {
+		return fmt.Errorf("unsupported offset %q, supported: %v", spec.Offset, offsets)
+	}
+	return nil
+}
+
+// LoadClassPolicies loads the clock policies per machine class name from a YAML or JSON file.
+func LoadClassPolicies(filename string) (map[string]api.ClockSpec, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open clock class policies file (%s): %w", filename, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var policies map[string]api.ClockSpec
+	if err := yaml.NewYAMLOrJSONDecoder(file, 4096).Decode(&policies); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal clock class policies: %w", err)
+	}
+
+	for class, spec := range policies {
+		if err := Validate(&spec); err != nil {
+			return nil, fmt.Errorf("invalid clock policy of class %s: %w", class, err)
+		}
+	}
+	return policies, nil
+}
+
+func present(enabled bool) string {
+	if enabled {
+		return "yes"
+	}
+	return "no"
+}
+
+// Clock returns the clock of the domain for the spec. A nil spec returns a utc clock with catchup rtc and hpet
+// timers and a paravirtual tsc.
+func Clock(spec *api.ClockSpec) *libvirtxml.DomainClock {
+	if spec == nil {
+		spec = &api.ClockSpec{}
+	}
+
+	offset := spec.Offset
+	if offset == "" {
+		offset = "utc"
+	}
+	clock := &libvirtxml.DomainClock{
+		Offset: offset,
+		Timer: []libvirtxml.DomainTimer{
+			{
+				Name:       "rtc",
+				TickPolicy: "catchup",
+			},
+		},
+	}
+
+	if spec.HPET == nil || *spec.HPET {
+		clock.Timer = append(clock.Timer, libvirtxml.DomainTimer{
+			Name:       "hpet",
+			TickPolicy: "catchup",
+		})
+	} else {
+		clock.Timer = append(clock.Timer, libvirtxml.DomainTimer{
+			Name:    "hpet",
+			Present: present(false),
+		})
+	}
+
+	clock.Timer = append(clock.Timer, libvirtxml.DomainTimer{
+		Name:       "tsc",
+		Mode:       "paravirt",
+		TickPolicy: "catchup",
+	})
+
+	if spec.KVMClock != nil {
+		clock.Timer = append(clock.Timer, libvirtxml.DomainTimer{
+			Name:    "kvmclock",
+			Present: present(*spec.KVMClock),
+		})
+	}
+	if spec.HypervClock {
+		clock.Timer = append(clock.Timer, libvirtxml.DomainTimer{
+			Name:    "hypervclock",
+			Present: present(true),
+		})
+	}
+	return clock
+}
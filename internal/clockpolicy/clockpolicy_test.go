@@ -0,0 +1,79 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package clockpolicy_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	. "github.com/ironcore-dev/libvirt-provider/internal/clockpolicy"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/utils/ptr"
+	"libvirt.org/go/libvirtxml"
+)
+
+var _ = Describe("ClockPolicy", func() {
+	It("should validate the clock", func() {
+		Expect(Validate(nil)).To(Succeed())
+		Expect(Validate(&api.ClockSpec{Offset: "localtime", HypervClock: true})).To(Succeed())
+
+		Expect(Validate(&api.ClockSpec{Offset: "timezone"})).To(HaveOccurred())
+	})
+
+	It("should load the class policies", func() {
+		filename := filepath.Join(GinkgoT().TempDir(), "policies.yaml")
+		Expect(os.WriteFile(filename, []byte(`x3-windows:
+  offset: localtime
+  kvmClock: false
+  hpet: false
+  hypervClock: true
+x3-small: {}
+`), 0644)).To(Succeed())
+
+		Expect(LoadClassPolicies(filename)).To(Equal(map[string]api.ClockSpec{
+			"x3-windows": {
+				Offset:      "localtime",
+				KVMClock:    ptr.To(false),
+				HPET:        ptr.To(false),
+				HypervClock: true,
+			},
+			"x3-small": {},
+		}))
+
+		Expect(os.WriteFile(filename, []byte("x3-windows:\n  offset: variable\n"), 0644)).To(Succeed())
+		_, err := LoadClassPolicies(filename)
+		Expect(err).To(MatchError(ContainSubstring("invalid clock policy of class x3-windows")))
+	})
+
+	It("should return the default clock for machines without policy", func() {
+		Expect(Clock(nil)).To(Equal(&libvirtxml.DomainClock{
+			Offset: "utc",
+			Timer: []libvirtxml.DomainTimer{
+				{Name: "rtc", TickPolicy: "catchup"},
+				{Name: "hpet", TickPolicy: "catchup"},
+				{Name: "tsc", Mode: "paravirt", TickPolicy: "catchup"},
+			},
+		}))
+	})
+
+	It("should return the clock of the policy", func() {
+		Expect(Clock(&api.ClockSpec{
+			Offset:      "localtime",
+			KVMClock:    ptr.To(false),
+			HPET:        ptr.To(false),
+			HypervClock: true,
+		})).To(Equal(&libvirtxml.DomainClock{
+			Offset: "localtime",
+			Timer: []libvirtxml.DomainTimer{
+				{Name: "rtc", TickPolicy: "catchup"},
+				{Name: "hpet", Present: "no"},
+				{Name: "tsc", Mode: "paravirt", TickPolicy: "catchup"},
+				{Name: "kvmclock", Present: "no"},
+				{Name: "hypervclock", Present: "yes"},
+			},
+		}))
+	})
+})
@@ -0,0 +1,259 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package hub shares the serial console PTY of a machine between several clients. The console output is
+// retained, so clients attaching later see what happened before, e.g. the boot messages.
+package hub
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+const (
+	// DefaultHistorySize is the number of console output bytes retained per machine.
+	DefaultHistorySize = 64 * 1024
+	// DefaultMaxClients is the maximum number of clients attached to the console of a machine.
+	DefaultMaxClients = 8
+
+	// clientBufferSize is the number of console output chunks buffered per client. Clients falling further
+	// behind are detached, so they can't stall the other clients.
+	clientBufferSize = 256
+	readBufferSize   = 4096
+)
+
+var (
+	ErrTooManyClients = errors.New("too many clients attached to the console")
+	ErrReadOnly       = errors.New("console client is read-only")
+	ErrDetached       = errors.New("console client is detached")
+	ErrClosed         = errors.New("console is closed")
+)
+
+type Options struct {
+	// HistorySize is the number of console output bytes retained per machine.
+	HistorySize int
+	// MaxClients is the maximum number of clients attached to the console of a machine.
+	MaxClients int
+	// Open opens the PTY at the path. Defaults to opening the file read-write.
+	Open func(path string) (io.ReadWriteCloser, error)
+}
+
+func setOptionsDefaults(o *Options) {
+	if o.HistorySize == 0 {
+		o.HistorySize = DefaultHistorySize
+	}
+	if o.MaxClients == 0 {
+		o.MaxClients = DefaultMaxClients
+	}
+	if o.Open == nil {
+		o.Open = func(path string) (io.ReadWriteCloser, error) {
+			return os.OpenFile(path, os.O_RDWR, 0)
+		}
+	}
+}
+
+// Hub holds a session per machine console. A session reads the PTY from the first attach until the PTY is
+// closed, e.g. because the domain stopped, also while no client is attached.
+type Hub struct {
+	opts Options
+
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+func New(opts Options) *Hub {
+	setOptionsDefaults(&opts)
+	return &Hub{
+		opts:     opts,
+		sessions: make(map[string]*session),
+	}
+}
+
+// Attach attaches a client to the console of the machine at the PTY path and returns the retained console
+// output. The first client gets read-write control of the console, the others are read-only until it detaches.
+// Control then passes to the client attached the longest.
+func (h *Hub) Attach(machineID, path string) (*Client, []byte, error) {
+	h.mu.Lock()
+	s, ok := h.sessions[machineID]
+	if ok && s.path != path {
+		// The domain was restarted with another PTY.
+		s.close()
+		ok = false
+	}
+	if !ok {
+		pty, err := h.opts.Open(path)
+		if err != nil {
+			h.mu.Unlock()
+			return nil, nil, err
+		}
+
+		s = &session{
+			hub:       h,
+			machineID: machineID,
+			path:      path,
+			pty:       pty,
+			history:   NewRing(h.opts.HistorySize),
+			clients:   make(map[*Client]struct{}),
+		}
+		h.sessions[machineID] = s
+		go s.run()
+	}
+	h.mu.Unlock()
+
+	return s.attach()
+}
+
+func (h *Hub) remove(s *session) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.sessions[s.machineID] == s {
+		delete(h.sessions, s.machineID)
+	}
+}
+
+type session struct {
+	hub       *Hub
+	machineID string
+	path      string
+	pty       io.ReadWriteCloser
+
+	mu         sync.Mutex
+	history    *Ring
+	clients    map[*Client]struct{}
+	controller *Client
+	closed     bool
+	// nextSeq is the sequence number of the next attached client, it orders the clients by attach time.
+	nextSeq uint64
+}
+
+func (s *session) attach() (*Client, []byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, nil, ErrClosed
+	}
+	if len(s.clients) >= s.hub.opts.MaxClients {
+		return nil, nil, ErrTooManyClients
+	}
+
+	c := &Client{
+		session: s,
+		seq:     s.nextSeq,
+		out:     make(chan []byte, clientBufferSize),
+	}
+	s.nextSeq++
+	if s.controller == nil {
+		s.controller = c
+	}
+	s.clients[c] = struct{}{}
+	return c, s.history.Bytes(), nil
+}
+
+func (s *session) run() {
+	buf := make([]byte, readBufferSize)
+	for {
+		n, err := s.pty.Read(buf)
+		if n > 0 {
+			s.broadcast(buf[:n])
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	s.close()
+	s.hub.remove(s)
+}
+
+func (s *session) broadcast(data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, _ = s.history.Write(data)
+	for c := range s.clients {
+		select {
+		case c.out <- append([]byte(nil), data...):
+		default:
+			s.detachLocked(c)
+		}
+	}
+}
+
+func (s *session) detachLocked(c *Client) {
+	if _, ok := s.clients[c]; !ok {
+		return
+	}
+	delete(s.clients, c)
+	close(c.out)
+	if s.controller != c {
+		return
+	}
+
+	s.controller = nil
+	for other := range s.clients {
+		if s.controller == nil || other.seq < s.controller.seq {
+			s.controller = other
+		}
+	}
+}
+
+func (s *session) close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	for c := range s.clients {
+		s.detachLocked(c)
+	}
+	s.mu.Unlock()
+
+	_ = s.pty.Close()
+}
+
+// Client is attached to the console of a machine until it is closed, the console is closed or it falls behind
+// reading the console output.
+type Client struct {
+	session *session
+	seq     uint64
+	out     chan []byte
+}
+
+// ReadWrite reports whether the client controls the console. A read-only client gains control once the clients
+// attached before it detached.
+func (c *Client) ReadWrite() bool {
+	c.session.mu.Lock()
+	defer c.session.mu.Unlock()
+	return c.session.controller == c
+}
+
+// Output returns the console output. It is closed once the client is detached.
+func (c *Client) Output() <-chan []byte {
+	return c.out
+}
+
+// Write writes the input to the console. Only the client controlling the console may write.
+func (c *Client) Write(p []byte) (int, error) {
+	c.session.mu.Lock()
+	_, attached := c.session.clients[c]
+	controller := c.session.controller == c
+	c.session.mu.Unlock()
+	if !attached {
+		return 0, ErrDetached
+	}
+	if !controller {
+		return 0, ErrReadOnly
+	}
+	return c.session.pty.Write(p)
+}
+
+// Close detaches the client from the console. The console keeps retaining its output.
+func (c *Client) Close() {
+	c.session.mu.Lock()
+	defer c.session.mu.Unlock()
+	c.session.detachLocked(c)
+}
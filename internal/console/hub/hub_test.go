@@ -0,0 +1,127 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package hub_test
+
+import (
+	"io"
+
+	. "github.com/ironcore-dev/libvirt-provider/internal/console/hub"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fakePTY is the console side of a PTY, the guest writes to output and reads from input.
+type fakePTY struct {
+	io.Reader
+	io.Writer
+	closeFn func() error
+}
+
+func (p fakePTY) Close() error {
+	return p.closeFn()
+}
+
+var _ = Describe("Ring", func() {
+	It("should retain the last bytes", func() {
+		r := NewRing(4)
+		Expect(r.Bytes()).To(BeEmpty())
+
+		_, _ = r.Write([]byte("ab"))
+		Expect(r.Bytes()).To(Equal([]byte("ab")))
+		_, _ = r.Write([]byte("cd"))
+		Expect(r.Bytes()).To(Equal([]byte("abcd")))
+		_, _ = r.Write([]byte("ef"))
+		Expect(r.Bytes()).To(Equal([]byte("cdef")))
+		_, _ = r.Write([]byte("0123456"))
+		Expect(r.Bytes()).To(Equal([]byte("3456")))
+	})
+})
+
+var _ = Describe("Hub", func() {
+	var (
+		h           *Hub
+		guestOutput *io.PipeWriter
+		guestInput  *io.PipeReader
+		opened      int
+	)
+
+	BeforeEach(func() {
+		opened = 0
+		h = New(Options{
+			HistorySize: 16,
+			MaxClients:  2,
+			Open: func(string) (io.ReadWriteCloser, error) {
+				opened++
+				outR, outW := io.Pipe()
+				inR, inW := io.Pipe()
+				guestOutput, guestInput = outW, inR
+				return fakePTY{Reader: outR, Writer: inW, closeFn: func() error {
+					_ = outR.Close()
+					return inW.Close()
+				}}, nil
+			},
+		})
+	})
+
+	It("should share the console between a controller and viewers", func() {
+		controller, history, err := h.Attach("foo", "/dev/pts/1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(history).To(BeEmpty())
+		Expect(controller.ReadWrite()).To(BeTrue())
+
+		_, _ = guestOutput.Write([]byte("booting"))
+		Eventually(controller.Output()).Should(Receive(Equal([]byte("booting"))))
+
+		viewer, history, err := h.Attach("foo", "/dev/pts/1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(history).To(Equal([]byte("booting")))
+		Expect(viewer.ReadWrite()).To(BeFalse())
+		Expect(opened).To(Equal(1))
+
+		_, _, err = h.Attach("foo", "/dev/pts/1")
+		Expect(err).To(MatchError(ErrTooManyClients))
+
+		_, err = viewer.Write([]byte("ls"))
+		Expect(err).To(MatchError(ErrReadOnly))
+
+		go func() { _, _ = controller.Write([]byte("ls")) }()
+		buf := make([]byte, 2)
+		_, err = io.ReadFull(guestInput, buf)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(buf).To(Equal([]byte("ls")))
+
+		controller.Close()
+		Eventually(controller.Output()).Should(BeClosed())
+		_, err = controller.Write([]byte("ls"))
+		Expect(err).To(MatchError(ErrDetached))
+
+		By("passing the control to the viewer")
+		Expect(viewer.ReadWrite()).To(BeTrue())
+		go func() { _, _ = viewer.Write([]byte("id")) }()
+		_, err = io.ReadFull(guestInput, buf)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(buf).To(Equal([]byte("id")))
+
+		next, _, err := h.Attach("foo", "/dev/pts/1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(next.ReadWrite()).To(BeFalse())
+
+		viewer.Close()
+		Expect(next.ReadWrite()).To(BeTrue())
+	})
+
+	It("should detach the clients once the console is closed", func() {
+		client, _, err := h.Attach("foo", "/dev/pts/1")
+		Expect(err).NotTo(HaveOccurred())
+
+		_ = guestOutput.Close()
+		Eventually(client.Output()).Should(BeClosed())
+
+		Eventually(func() error {
+			_, _, err := h.Attach("foo", "/dev/pts/1")
+			return err
+		}).Should(Succeed())
+		Expect(opened).To(Equal(2))
+	})
+})
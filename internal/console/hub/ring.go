@@ -0,0 +1,48 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package hub
+
+// Ring retains the last bytes written to it. It is not safe for concurrent use.
+type Ring struct {
+	buf  []byte
+	pos  int
+	full bool
+}
+
+// NewRing returns a Ring retaining the last size bytes.
+func NewRing(size int) *Ring {
+	return &Ring{buf: make([]byte, size)}
+}
+
+// Write appends p, overwriting the oldest bytes once the ring is full. It never fails.
+func (r *Ring) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(r.buf) == 0 {
+		return n, nil
+	}
+	if len(p) >= len(r.buf) {
+		copy(r.buf, p[len(p)-len(r.buf):])
+		r.pos, r.full = 0, true
+		return n, nil
+	}
+
+	if r.pos+len(p) >= len(r.buf) {
+		r.full = true
+	}
+	c := copy(r.buf[r.pos:], p)
+	copy(r.buf, p[c:])
+	r.pos = (r.pos + len(p)) % len(r.buf)
+	return n, nil
+}
+
+// Bytes returns a copy of the retained bytes, oldest first.
+func (r *Ring) Bytes() []byte {
+	if !r.full {
+		return append([]byte(nil), r.buf[:r.pos]...)
+	}
+
+	data := make([]byte, 0, len(r.buf))
+	data = append(data, r.buf[r.pos:]...)
+	return append(data, r.buf[:r.pos]...)
+}
@@ -12,28 +12,52 @@ import (
 	"os"
 	"path/filepath"
 	"slices"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/digitalocean/go-libvirt"
 	"github.com/go-logr/logr"
 	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/cgrouppolicy"
+	"github.com/ironcore-dev/libvirt-provider/internal/clockpolicy"
+	"github.com/ironcore-dev/libvirt-provider/internal/deviceprofile"
 	"github.com/ironcore-dev/libvirt-provider/internal/event"
 	machineEvent "github.com/ironcore-dev/libvirt-provider/internal/event/machineevent"
 	providerhost "github.com/ironcore-dev/libvirt-provider/internal/host"
+	"github.com/ironcore-dev/libvirt-provider/internal/hostevent"
+	"github.com/ironcore-dev/libvirt-provider/internal/hugepages"
+	"github.com/ironcore-dev/libvirt-provider/internal/journal"
+	"github.com/ironcore-dev/libvirt-provider/internal/kernelcmdline"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/alias"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/call"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/device"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/drift"
 	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/guest"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/hotplug"
 	libvirtmeta "github.com/ironcore-dev/libvirt-provider/internal/libvirt/meta"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/pci"
 	libvirtutils "github.com/ironcore-dev/libvirt-provider/internal/libvirt/utils"
 	providerimage "github.com/ironcore-dev/libvirt-provider/internal/oci"
-	"github.com/ironcore-dev/libvirt-provider/internal/osutils"
+	"github.com/ironcore-dev/libvirt-provider/internal/pcidevice"
+	"github.com/ironcore-dev/libvirt-provider/internal/placement"
 	providernetworkinterface "github.com/ironcore-dev/libvirt-provider/internal/plugins/networkinterface"
 	providervolume "github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
+	"github.com/ironcore-dev/libvirt-provider/internal/qcow2"
+	"github.com/ironcore-dev/libvirt-provider/internal/quota"
 	"github.com/ironcore-dev/libvirt-provider/internal/raw"
+	"github.com/ironcore-dev/libvirt-provider/internal/retrypolicy"
+	"github.com/ironcore-dev/libvirt-provider/internal/sgx"
+	"github.com/ironcore-dev/libvirt-provider/internal/smbios"
 	"github.com/ironcore-dev/libvirt-provider/internal/store"
 	"github.com/ironcore-dev/libvirt-provider/internal/utils"
+	"github.com/ironcore-dev/libvirt-provider/internal/windows"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/keymutex"
 	"k8s.io/utils/ptr"
 	"libvirt.org/go/libvirtxml"
 )
@@ -41,9 +65,14 @@ import (
 const (
 	MachineFinalizer                = "machine"
 	filePerm                        = 0666
-	rootFSAlias                     = "ua-rootfs"
 	libvirtDomainXMLIgnitionKeyName = "opt/com.coreos/config"
-	networkInterfaceAliasPrefix     = "ua-networkinterface-"
+
+	// rootFSSerial is the serial of the root fs disk, images find their root fs by it.
+	rootFSSerial = "machineboot"
+
+	// DefaultPCIRootPorts is the number of root ports of new domains, bounding the number of hotpluggable devices
+	// until the domain is restarted.
+	DefaultPCIRootPorts = 30
 )
 
 var (
@@ -66,15 +95,90 @@ type MachineReconcilerOptions struct {
 	TCMallocLibPath                string
 	ImageCache                     providerimage.Cache
 	Raw                            raw.Raw
+	QCow2                          qcow2.QCow2
 	Host                           providerhost.Host
 	VolumePluginManager            *providervolume.PluginManager
-	NetworkInterfacePlugin         providernetworkinterface.Plugin
+	NetworkInterfacePlugins        *providernetworkinterface.PluginManager
 	VolumeEvents                   event.Source[*api.Machine]
 	ResyncIntervalVolumeSize       time.Duration
 	ResyncIntervalGarbageCollector time.Duration
 	EnableHugepages                bool
+	Hugepages                      *hugepages.Manager
 	GCVMGracefulShutdownTimeout    time.Duration
-	VolumeCachePolicy              string
+	GCVMGuestAgentShutdownTimeout  time.Duration
+	GCVMShutdownResendInterval     time.Duration
+	GCWorkers                      int
+	QuiesceVolumeDetach            bool
+	LiveVolumeMigration            bool
+	Journal                        *journal.Journal
+	TerminatingWarningThreshold    time.Duration
+	ReconcileSummaryFormat         ReconcileSummaryFormat
+	ResizeWorkers                  int
+	ResizeQueueSize                int
+	CPUPinning                     CPUPinningOptions
+
+	// PCILayout is the PCI controller layout of new domains of machines without PCI spec.
+	PCILayout pci.Layout
+
+	// DeviceEventTimeout is the time to wait for libvirt to confirm a device hotplug operation.
+	DeviceEventTimeout time.Duration
+
+	// VirtioMaxQueues bounds the number of queues of virtio disks and network interfaces derived from the vCPU
+	// count of machines without queues spec. 0 and 1 disable multi-queue for them.
+	VirtioMaxQueues uint
+
+	// RescueISODir is the directory holding the ISO images machines can boot once via a boot override.
+	RescueISODir string
+
+	// MediaDir is the directory holding the ISO images inserted into media drives by path.
+	MediaDir string
+
+	// RescueImage is the reference of the direct kernel boot image rescued machines boot by default.
+	RescueImage string
+
+	// PCIDevices manages the PCI devices passed through to machines. Nil if no PCI device pools are configured.
+	PCIDevices *pcidevice.Manager
+
+	// ResyncIntervalPCIDeviceHealth is the interval to check the health of the claimed PCI devices. Zero disables
+	// the checks.
+	ResyncIntervalPCIDeviceHealth time.Duration
+
+	// Placement places the PCI devices, hugepages and vCPUs of machines on the NUMA nodes of the host. Defaults
+	// to a placement.NUMACoordinator of the Hugepages and PCIDevices.
+	Placement placement.Coordinator
+
+	// DriftPolicy defines how changes made to running domains outside the provider are handled. Defaults to
+	// drift.PolicyReport.
+	DriftPolicy drift.Policy
+
+	// MaxConcurrentRootFSCreations bounds the number of root fs disks created from images at once. Machines
+	// exceeding it wait for a free slot. Zero means no limit.
+	MaxConcurrentRootFSCreations int
+
+	// RetryPolicies are the retry policies of failed reconciles per error class. Classes without policy use
+	// retrypolicy.DefaultPolicies.
+	RetryPolicies map[retrypolicy.Class]retrypolicy.Policy
+
+	// NetworkInterfaceFilters enables libvirt nwfilters preventing emulated network interfaces of provider
+	// networks from spoofing MAC and IP addresses other than their own.
+	NetworkInterfaceFilters bool
+
+	// ReconcileTimeout bounds the duration of a reconcile of a machine. Zero means no limit.
+	ReconcileTimeout time.Duration
+
+	// LibvirtPool is the pool of connections the reconciles call libvirt with. If nil, they share the
+	// connection of the reconciler, which always receives the libvirt events.
+	LibvirtPool *libvirtutils.Pool
+
+	// LibvirtCallTimeout bounds the duration of libvirt calls creating, shutting down and destroying domains and
+	// attaching and detaching devices, so a hung libvirt daemon doesn't block the workers. Zero means no limit.
+	// Requires LibvirtPool, the connection of the reconciler can't be aborted.
+	LibvirtCallTimeout time.Duration
+
+	// DirQuotas limits the disk space of the machine directories to DirQuotaBytes of their spec. Nil disables
+	// machine directory quotas.
+	DirQuotas     *quota.Projects
+	DirQuotaBytes func(spec *api.MachineSpec) int64
 }
 
 func NewMachineReconciler(
@@ -97,9 +201,55 @@ func NewMachineReconciler(
 		return nil, fmt.Errorf("must specify machine events")
 	}
 
+	if opts.Hugepages == nil {
+		opts.Hugepages = hugepages.NewManager("")
+	}
+	if opts.Placement == nil {
+		opts.Placement = placement.NewNUMACoordinator(opts.Hugepages, opts.PCIDevices)
+	}
+
+	if err := opts.CPUPinning.normalize(); err != nil {
+		return nil, err
+	}
+
+	if opts.PCILayout == (pci.Layout{}) {
+		opts.PCILayout = pci.Layout{RootPorts: DefaultPCIRootPorts}
+	}
+	if err := opts.PCILayout.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid pci layout: %w", err)
+	}
+
+	if opts.DeviceEventTimeout <= 0 {
+		opts.DeviceEventTimeout = DefaultDeviceEventTimeout
+	}
+
+	if opts.DriftPolicy == "" {
+		opts.DriftPolicy = drift.PolicyReport
+	}
+
+	if opts.DirQuotas != nil && opts.DirQuotaBytes == nil {
+		return nil, fmt.Errorf("must specify the machine directory quota bytes")
+	}
+
+	if opts.LibvirtCallTimeout > 0 && opts.LibvirtPool == nil {
+		return nil, fmt.Errorf("must specify the libvirt pool to bound libvirt calls")
+	}
+
+	if opts.GCWorkers <= 0 {
+		opts.GCWorkers = DefaultGCWorkers
+	}
+
+	if opts.ResizeWorkers <= 0 {
+		opts.ResizeWorkers = DefaultResizeWorkers
+	}
+	if opts.ResizeQueueSize <= 0 {
+		opts.ResizeQueueSize = DefaultResizeQueueSize
+	}
+
+	queue := workqueue.NewTypedRateLimitingQueue[string](workqueue.DefaultTypedControllerRateLimiter[string]())
 	return &MachineReconciler{
 		log:                            log,
-		queue:                          workqueue.NewTypedRateLimitingQueue[string](workqueue.DefaultTypedControllerRateLimiter[string]()),
+		queue:                          queue,
 		libvirt:                        libvirt,
 		machines:                       machines,
 		machineEvents:                  machineEvents,
@@ -109,13 +259,51 @@ func NewMachineReconciler(
 		host:                           opts.Host,
 		imageCache:                     opts.ImageCache,
 		raw:                            opts.Raw,
+		qcow2:                          opts.QCow2,
 		volumePluginManager:            opts.VolumePluginManager,
-		networkInterfacePlugin:         opts.NetworkInterfacePlugin,
+		networkInterfacePlugins:        opts.NetworkInterfacePlugins,
 		resyncIntervalVolumeSize:       opts.ResyncIntervalVolumeSize,
 		resyncIntervalGarbageCollector: opts.ResyncIntervalGarbageCollector,
 		enableHugepages:                opts.EnableHugepages,
+		hugepages:                      opts.Hugepages,
 		gcVMGracefulShutdownTimeout:    opts.GCVMGracefulShutdownTimeout,
-		volumeCachePolicy:              opts.VolumeCachePolicy,
+		gcVMGuestAgentShutdownTimeout:  opts.GCVMGuestAgentShutdownTimeout,
+		gcVMShutdownResendInterval:     opts.GCVMShutdownResendInterval,
+		quiesceVolumeDetach:            opts.QuiesceVolumeDetach,
+		quiesceTimeout:                 opts.DeviceEventTimeout,
+		liveVolumeMigration:            opts.LiveVolumeMigration,
+		migrationQueue:                 workqueue.NewTypedRateLimitingQueue[string](workqueue.DefaultTypedControllerRateLimiter[string]()),
+		machineLocks:                   keymutex.NewHashed(0),
+		finalizeLocks:                  keymutex.NewHashed(finalizeLockBuckets),
+		journal:                        opts.Journal,
+		terminatingWarningThreshold:    opts.TerminatingWarningThreshold,
+		reconcileSummaryFormat:         opts.ReconcileSummaryFormat,
+		stuckTerminating:               sets.New[string](),
+		deferred:                       sets.New[string](),
+		resizeQueue:                    workqueue.NewTypedRateLimitingQueue[resizeRequest](workqueue.DefaultTypedControllerRateLimiter[resizeRequest]()),
+		gcQueue:                        workqueue.NewTypedRateLimitingQueue[string](workqueue.DefaultTypedControllerRateLimiter[string]()),
+		gcWorkers:                      opts.GCWorkers,
+		resizeWorkers:                  opts.ResizeWorkers,
+		resizeQueueSize:                opts.ResizeQueueSize,
+		cpuPinning:                     opts.CPUPinning,
+		pciLayout:                      opts.PCILayout,
+		hotplug:                        hotplug.NewTracker(opts.DeviceEventTimeout),
+		virtioMaxQueues:                opts.VirtioMaxQueues,
+		rescueISODir:                   opts.RescueISODir,
+		mediaDir:                       opts.MediaDir,
+		rescueImage:                    opts.RescueImage,
+		pciDevices:                     opts.PCIDevices,
+		resyncIntervalPCIDeviceHealth:  opts.ResyncIntervalPCIDeviceHealth,
+		placement:                      opts.Placement,
+		driftPolicy:                    opts.DriftPolicy,
+		rootFSCreations:                newRootFSCreationQueue(opts.MaxConcurrentRootFSCreations, queue.Add),
+		networkInterfaceFilters:        opts.NetworkInterfaceFilters,
+		backoff:                        retrypolicy.NewBackoff(opts.RetryPolicies),
+		reconcileTimeout:               opts.ReconcileTimeout,
+		libvirtCallTimeout:             opts.LibvirtCallTimeout,
+		libvirtPool:                    opts.LibvirtPool,
+		dirQuotas:                      opts.DirQuotas,
+		dirQuotaBytes:                  opts.DirQuotaBytes,
 	}, nil
 }
 
@@ -129,11 +317,13 @@ type MachineReconciler struct {
 	host              providerhost.Host
 	imageCache        providerimage.Cache
 	raw               raw.Raw
+	qcow2             qcow2.QCow2
 
 	enableHugepages bool
+	hugepages       *hugepages.Manager
 
-	volumePluginManager    *providervolume.PluginManager
-	networkInterfacePlugin providernetworkinterface.Plugin
+	volumePluginManager     *providervolume.PluginManager
+	networkInterfacePlugins *providernetworkinterface.PluginManager
 
 	machines      store.Store[*api.Machine]
 	machineEvents event.Source[*api.Machine]
@@ -142,13 +332,169 @@ type MachineReconciler struct {
 	resyncIntervalVolumeSize time.Duration
 
 	gcVMGracefulShutdownTimeout    time.Duration
+	gcVMGuestAgentShutdownTimeout  time.Duration
+	gcVMShutdownResendInterval     time.Duration
 	resyncIntervalGarbageCollector time.Duration
 
-	volumeCachePolicy string
+	// quiesceVolumeDetach enables quiescing volumes before they are detached because their plugin changed.
+	quiesceVolumeDetach bool
+	// quiesceTimeout is the time a guest stays frozen while the detachments of its outdated volumes are pending.
+	quiesceTimeout time.Duration
+	// frozenGuests are the *frozenGuest of the machines whose outdated volumes are detached by machine id.
+	frozenGuests sync.Map
+	// liveVolumeMigration enables copying attached volumes whose plugin changed to the new plugin while the
+	// domain keeps running, instead of replacing them.
+	liveVolumeMigration bool
+	migrationQueue      workqueue.TypedRateLimitingInterface[string]
+
+	journal *journal.Journal
+	// unresolvedCreates are the ids of the machines whose domain creation was interrupted with unknown outcome.
+	// The creation is recorded as completed once a reconcile persisted the status of the domain.
+	unresolvedCreates sync.Map
+
+	terminatingWarningThreshold time.Duration
+	stuckTerminating            sets.Set[string]
+	// finalizeLocks serialize the forced finalization of a machine with the garbage collection of the machine,
+	// see lockFinalize.
+	finalizeLocks keymutex.KeyMutex
+
+	reconcileSummaryFormat ReconcileSummaryFormat
+
+	// resizeQueue holds volume resizes, processed by the resize workers besides the machine reconciles.
+	resizeQueue     workqueue.TypedRateLimitingInterface[resizeRequest]
+	resizeWorkers   int
+	resizeQueueSize int
+
+	// gcQueue holds the ids of the deleted machines, processed by gcWorkers in parallel.
+	gcQueue   workqueue.TypedRateLimitingInterface[string]
+	gcWorkers int
+
+	cpuPinning CPUPinningOptions
+
+	pciLayout pci.Layout
+
+	virtioMaxQueues uint
+
+	rescueISODir string
+	mediaDir     string
+	rescueImage  string
+
+	pciDevices                    *pcidevice.Manager
+	resyncIntervalPCIDeviceHealth time.Duration
+	placement                     placement.Coordinator
+
+	driftPolicy drift.Policy
+
+	// rootFSCreations bounds the concurrent root fs creations. It is nil if they are not bounded.
+	rootFSCreations *rootFSCreationQueue
+
+	networkInterfaceFilters bool
+
+	// backoff computes the delay of retries of failed reconciles per machine.
+	backoff *retrypolicy.Backoff
+
+	// failedStatusVersions are the resource versions of the statuses written by failed reconciles by machine id.
+	failedStatusVersions sync.Map
+	// specVersions are the specVersion of the machines seen by the store event handler by machine id.
+	specVersions sync.Map
+
+	reconcileTimeout   time.Duration
+	libvirtCallTimeout time.Duration
+
+	// libvirtPool is the pool of connections for calls of reconciles. It is nil if they use the connection of
+	// the event subscriptions.
+	libvirtPool *libvirtutils.Pool
+
+	// dirQuotas limits the machine directories to dirQuotaBytes. It is nil if they aren't limited.
+	dirQuotas     *quota.Projects
+	dirQuotaBytes func(spec *api.MachineSpec) int64
+
+	// reconciles are the running reconciles by machine id.
+	reconciles sync.Map
+
+	// machineLocks serialize the reconcile of a machine with the workers changing its domain, see lockMachine.
+	machineLocks keymutex.KeyMutex
+
+	// hotplug tracks the device operations on running domains until libvirt confirms them. It is nil if
+	// libvirt device events aren't available.
+	hotplug *hotplug.Tracker
+
+	// lastReconciled is the unix nano time the last machine was reconciled successfully, lastFailed the
+	// time a reconcile failed last.
+	lastReconciled atomic.Int64
+	lastFailed     atomic.Int64
+
+	// draining is set once Drain was called, inFlight counts the running reconciles, garbage collections, resizes
+	// and migrations. deferred are the machines taken from the queue while draining, which are persisted instead of
+	// being reconciled.
+	draining   atomic.Bool
+	inFlight   atomic.Int64
+	deferredMu sync.Mutex
+	deferred   sets.Set[string]
+}
+
+// conn returns the libvirt connection to call libvirt with, the next connection of the pool if configured.
+func (r *MachineReconciler) conn() *libvirt.Libvirt {
+	if r.libvirtPool == nil {
+		return r.libvirt
+	}
+	return r.libvirtPool.Get()
+}
+
+// mutatingConn returns the libvirt connection for mutating calls and the function aborting its calls on timeout.
+// Pooled connections are aborted and redialed by the pool. The connection of the event subscriptions isn't
+// aborted, as that would end the subscriptions, mutating calls on it wait for libvirt instead. Hence a pool is
+// required to bound the calls by LibvirtCallTimeout.
+func (r *MachineReconciler) mutatingConn() (*libvirt.Libvirt, func()) {
+	conn := r.conn()
+	if conn == r.libvirt {
+		return conn, nil
+	}
+	return conn, func() {
+		if err := libvirtutils.Abort(conn); err != nil {
+			r.log.Error(err, "failed to abort libvirt call")
+		}
+	}
+}
+
+// MutatingConn returns the libvirt connection for mutating calls of other components on the domains of the
+// machines and the function aborting its calls on timeout, see mutatingConn.
+func (r *MachineReconciler) MutatingConn() (*libvirt.Libvirt, func()) {
+	return r.mutatingConn()
+}
+
+// lockMachine locks the machine until the returned function is called. The queue doesn't reconcile a machine
+// concurrently, the lock keeps the workers from changing its domain during a reconcile and vice versa.
+func (r *MachineReconciler) lockMachine(id string) func() {
+	r.machineLocks.LockKey(id)
+	return func() {
+		_ = r.machineLocks.UnlockKey(id)
+	}
+}
+
+// finalizeLockBuckets is the number of finalization locks the machines are hashed to. Unlike the machine locks, it
+// doesn't depend on the number of CPUs, so the garbage collection workers don't serialize on small hosts.
+const finalizeLockBuckets = 256
+
+// lockFinalize locks the finalization of the machine until the returned function is called. Garbage collection
+// workers and forced finalizations of the same machine wait for each other, those of other machines only if they
+// are hashed to the same lock.
+func (r *MachineReconciler) lockFinalize(id string) func() {
+	r.finalizeLocks.LockKey(id)
+	return func() {
+		_ = r.finalizeLocks.UnlockKey(id)
+	}
+}
+
+// newDomainExecutor returns the executor of the device operations on the running domain of the machine.
+func (r *MachineReconciler) newDomainExecutor(ctx context.Context, machineID string) DomainExecutor {
+	conn, abort := r.mutatingConn()
+	return NewRunningDomainExecutor(ctx, conn, abort, r.libvirtCallTimeout, machineID, r.hotplug)
 }
 
 func (r *MachineReconciler) Start(ctx context.Context) error {
 	log := r.log
+	r.lastReconciled.Store(time.Now().UnixNano())
 
 	//todo make configurable
 	workerSize := 15
@@ -162,18 +508,27 @@ func (r *MachineReconciler) Start(ctx context.Context) error {
 			}
 
 			for _, machine := range machines {
-				if ptr.Deref(machine.Spec.Image, "") == evt.Ref {
-					r.Eventf(log, machine.Metadata, corev1.EventTypeNormal, "PulledImage", "Pulled image %s", *machine.Spec.Image)
-					log.V(1).Info("Image pulled: Requeue machines", "Image", evt.Ref, "Machine", machine.ID)
-					r.queue.Add(machine.ID)
+				if !r.usesImage(machine, evt.Ref) {
+					continue
 				}
+
+				switch {
+				case providerimage.IsSignatureVerificationError(evt.Err):
+					// Don't requeue, pulling again would be rejected as well.
+					r.Eventf(log, machine.Metadata, corev1.EventTypeWarning, "ImageSignatureVerificationFailed", "Rejected image %s: %s", evt.Ref, evt.Err)
+					continue
+				case evt.Err != nil:
+					r.Eventf(log, machine.Metadata, corev1.EventTypeWarning, "PullImageFailed", "Failed to pull image %s: %s", evt.Ref, evt.Err)
+				default:
+					r.Eventf(log, machine.Metadata, corev1.EventTypeNormal, "PulledImage", "Pulled image %s", evt.Ref)
+				}
+				log.V(1).Info("Image pull done: Requeue machines", "Image", evt.Ref, "Machine", machine.ID)
+				r.queue.Add(machine.ID)
 			}
 		},
 	})
 
-	imgEventReg, err := r.machineEvents.AddHandler(event.HandlerFunc[*api.Machine](func(evt event.Event[*api.Machine]) {
-		r.queue.Add(evt.Object.ID)
-	}))
+	imgEventReg, err := r.machineEvents.AddHandler(event.HandlerFunc[*api.Machine](r.enqueueMachineEvent))
 	if err != nil {
 		return err
 	}
@@ -183,7 +538,28 @@ func (r *MachineReconciler) Start(ctx context.Context) error {
 		}
 	}()
 
+	if r.pciDevices != nil {
+		if err := r.restorePCIDeviceClaims(ctx, log); err != nil {
+			return err
+		}
+	}
+
+	r.restoreQueue(log)
+	r.replayJournal(ctx, log.WithName("journal"))
+
 	var wg sync.WaitGroup
+	deviceEvents, err := r.subscribeDeviceEvents(ctx)
+	if err != nil {
+		log.Error(err, "failed to subscribe to libvirt device events, not tracking device operations")
+		r.hotplug = nil
+	} else {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.startEnqueueMachineByDeviceEvent(ctx, r.log.WithName("libvirt-device-event"), deviceEvents)
+		}()
+	}
+
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -202,11 +578,45 @@ func (r *MachineReconciler) Start(ctx context.Context) error {
 		r.startGarbageCollector(ctx, r.log.WithName("garbage-collector"))
 	}()
 
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r.startCheckPCIDeviceHealth(ctx, r.log.WithName("pci-device-health"))
+	}()
+
 	go func() {
 		<-ctx.Done()
 		r.queue.ShutDown()
+		r.resizeQueue.ShutDown()
+		r.gcQueue.ShutDown()
+		r.migrationQueue.ShutDown()
 	}()
 
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for r.processNextMigrationItem(ctx, r.log.WithName("volume-migration")) {
+		}
+	}()
+
+	for i := 0; i < r.gcWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r.processNextGCItem(ctx, r.log.WithName("garbage-collector")) {
+			}
+		}()
+	}
+
+	for i := 0; i < r.resizeWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r.processNextResizeItem(ctx, r.log.WithName("volume-resize")) {
+			}
+		}()
+	}
+
 	for i := 0; i < workerSize; i++ {
 		wg.Add(1)
 		go func() {
@@ -239,7 +649,8 @@ func (r *MachineReconciler) startCheckAndEnqueueVolumeResize(ctx context.Context
 				continue
 			}
 
-			var shouldEnqueue bool
+			r.migrateVolumeStatusHandles(machine)
+
 			for _, volume := range machine.Spec.Volumes {
 				plugin, err := r.volumePluginManager.FindPluginBySpec(volume)
 				if err != nil {
@@ -247,7 +658,7 @@ func (r *MachineReconciler) startCheckAndEnqueueVolumeResize(ctx context.Context
 					continue
 				}
 
-				volumeID, err := plugin.GetBackingVolumeID(volume)
+				volumeID, err := plugin.GetBackingVolumeID(volume, machine.ID)
 				if err != nil {
 					log.Error(err, "failed to get volume id", "machineID", machine.ID, "volumeName", volume.Name)
 					continue
@@ -262,14 +673,9 @@ func (r *MachineReconciler) startCheckAndEnqueueVolumeResize(ctx context.Context
 				if lastVolumeSize := getLastVolumeSize(machine, GetUniqueVolumeName(plugin.Name(), volumeID)); volumeSize != lastVolumeSize {
 					r.Eventf(log, machine.Metadata, corev1.EventTypeNormal, "SizeChangedVolume", "Volume size changed %s, lastVolumeSize: %d bytes, volumeSize: %d bytes", volume.Name, lastVolumeSize, volumeSize)
 					log.V(1).Info("Volume size changed", "volumeName", volume.Name, "volumeID", volumeID, "machineID", machine.ID, "lastSize", lastVolumeSize, "volumeSize", volumeSize)
-					shouldEnqueue = true
-					break
+					r.enqueueResize(log, resizeRequest{machineID: machine.ID, volumeName: volume.Name})
 				}
 			}
-
-			if shouldEnqueue {
-				r.queue.AddRateLimited(machine.ID)
-			}
 		}
 	}, r.resyncIntervalVolumeSize)
 }
@@ -319,15 +725,13 @@ func (r *MachineReconciler) startGarbageCollector(ctx context.Context, log logr.
 			return
 		}
 
+		r.updateTerminatingMachines(log, machines)
+
 		for _, machine := range machines {
-			if !slices.Contains(machine.Finalizers, MachineFinalizer) || machine.DeletedAt == nil {
+			if !isTerminating(machine) {
 				continue
 			}
-
-			logger := log.WithValues("machineID", machine.ID)
-			if err := r.processMachineDeletion(ctx, logger, machine); err != nil {
-				logger.Error(err, "failed to garbage collect machine")
-			}
+			r.gcQueue.Add(machine.ID)
 		}
 
 	}, r.resyncIntervalGarbageCollector)
@@ -343,7 +747,10 @@ func (r *MachineReconciler) processMachineDeletion(ctx context.Context, log logr
 	}
 	log.V(1).Info("Deleted machine")
 	machine.Status.State = api.MachineStateTerminated
-	machine, err = r.machines.Update(ctx, machine)
+	machine, err = store.RetryOnConflict(ctx, r.machines, machine, func(latest *api.Machine) error {
+		latest.Status.State = api.MachineStateTerminated
+		return nil
+	})
 	if err != nil {
 		return fmt.Errorf("failed to update machine state: %w", err)
 	}
@@ -358,13 +765,18 @@ func (r *MachineReconciler) processMachineDeletion(ctx context.Context, log logr
 	}
 	log.V(1).Info("Removed network interfaces")
 
+	if err := r.releasePCIDevices(log, machine); err != nil {
+		return fmt.Errorf("failed to release pci devices: %w", err)
+	}
+
 	if err := os.RemoveAll(r.host.MachineDir(machine.ID)); err != nil {
 		return fmt.Errorf("failed to remove machine directory: %w", err)
 	}
 	log.V(1).Info("Removed machine directory")
+	r.releaseDirQuota(log, machine)
 
 	machine.Finalizers = utils.DeleteSliceElement(machine.Finalizers, MachineFinalizer)
-	if _, err := r.machines.Update(ctx, machine); store.IgnoreErrNotFound(err) != nil {
+	if _, err := store.RetryOnConflict(ctx, r.machines, machine, removeFinalizer); store.IgnoreErrNotFound(err) != nil {
 		return fmt.Errorf("failed to update machine metadata: %w", err)
 	}
 	r.Eventf(log, machine.Metadata, corev1.EventTypeNormal, "CompletedDeletion", "Deletion completed")
@@ -373,38 +785,28 @@ func (r *MachineReconciler) processMachineDeletion(ctx context.Context, log logr
 	return nil
 }
 
-func (r *MachineReconciler) deleteMachine(ctx context.Context, log logr.Logger, machine *api.Machine) (bool, error) {
-	domain := libvirt.Domain{
-		UUID: libvirtutils.UUIDStringToBytes(machine.ID),
-	}
-
-	if machine.Spec.ShutdownAt.IsZero() {
-		machine.Status.State = api.MachineStateTerminating
-		machine.Spec.ShutdownAt = time.Now()
-		if _, err := r.machines.Update(ctx, machine); err != nil {
-			return false, fmt.Errorf("failed to update ShutdownAt and State: %w", err)
-		}
-		log.V(1).Info("Updated ShutdownAt and State", "ShutdownAt", machine.Spec.ShutdownAt, "State", machine.Status.State)
-	}
-
-	if time.Now().Before(machine.Spec.ShutdownAt.Add(r.gcVMGracefulShutdownTimeout)) {
-		// Due to heavy load, the AcpiPowerBtn signal might be missed by the VM.
-		// Hence, triggering the machine shutdown until VMGracefulShutdownTimeout is over to ensure its reception.
-		return r.shutdownMachine(log, machine, domain)
-	}
-
-	return false, r.destroyDomain(log, machine, domain)
-}
-
-func (r *MachineReconciler) destroyDomain(log logr.Logger, machine *api.Machine, domain libvirt.Domain) error {
+func (r *MachineReconciler) destroyDomain(ctx context.Context, log logr.Logger, machine *api.Machine, domain libvirt.Domain) error {
 	// DomainDestroyFlags is a blocking operation, and its synchronous nature may pose potential performance issues in the future.
 	// During test involving 26 empty disks, the function call took a maximum of 1 second to complete.
-	if err := r.libvirt.DomainDestroyFlags(domain, libvirt.DomainDestroyGraceful); err != nil {
+	r.beginOperation(log, machine.ID, journal.OperationDestroy, "")
+	conn, abort := r.mutatingConn()
+	if err := call.Mutate(ctx, r.libvirtCallTimeout, abort, func() error {
+		return conn.DomainDestroyFlags(domain, libvirt.DomainDestroyGraceful)
+	}); err != nil {
 		if libvirt.IsNotFound(err) {
+			r.recordOperation(log, machine.ID, journal.OperationDestroy, "", nil)
 			return nil
 		}
+		r.recordOperation(log, machine.ID, journal.OperationDestroy, "", err)
 		return fmt.Errorf("failed to initiate forceful shutdown: %w", err)
 	}
+	r.recordOperation(log, machine.ID, journal.OperationDestroy, "", nil)
+	r.hotplug.ForgetDomain(machine.ID)
+	r.frozenGuests.Delete(machine.ID)
+	if _, ok := r.unresolvedCreates.LoadAndDelete(machine.ID); ok {
+		// The domain existed, hence its interrupted creation took place.
+		r.recordOperation(log, machine.ID, journal.OperationCreate, "", nil)
+	}
 
 	r.Eventf(log, machine.Metadata, corev1.EventTypeWarning, "DestroyedDomain", "Domain Destroyed")
 
@@ -412,24 +814,6 @@ func (r *MachineReconciler) destroyDomain(log logr.Logger, machine *api.Machine,
 	return nil
 }
 
-func (r *MachineReconciler) shutdownMachine(log logr.Logger, machine *api.Machine, domain libvirt.Domain) (bool, error) {
-	log.V(1).Info("Triggering shutdown", "ShutdownAt", machine.Spec.ShutdownAt)
-	r.Eventf(log, machine.Metadata, corev1.EventTypeNormal, "TriggeringShutdown", "Shutdown Triggered")
-
-	shutdownMode := libvirt.DomainShutdownAcpiPowerBtn
-	if machine.Spec.GuestAgent == api.GuestAgentQemu {
-		shutdownMode = libvirt.DomainShutdownGuestAgent
-	}
-	if err := r.libvirt.DomainShutdownFlags(domain, shutdownMode); err != nil {
-		if libvirt.IsNotFound(err) {
-			return false, nil
-		}
-		return false, fmt.Errorf("failed to initiate shutdown: %w", err)
-	}
-
-	return true, nil
-}
-
 func (r *MachineReconciler) processNextWorkItem(ctx context.Context, log logr.Logger) bool {
 	id, shutdown := r.queue.Get()
 	if shutdown {
@@ -437,21 +821,62 @@ func (r *MachineReconciler) processNextWorkItem(ctx context.Context, log logr.Lo
 	}
 	defer r.queue.Done(id)
 
+	r.inFlight.Add(1)
+	defer r.inFlight.Add(-1)
+	if r.deferIfDraining(id) {
+		return true
+	}
+
 	log = log.WithValues("machineID", id)
 	ctx = logr.NewContext(ctx, log)
+	ctx, summary := newReconcileSummaryContext(ctx)
+	defer summary.log(log, r.reconcileSummaryFormat)
+	ctx, cancel := r.reconcileContext(ctx, id)
+	defer cancel()
+	defer r.lockMachine(id)()
 
 	if err := r.reconcileMachine(ctx, id); err != nil {
-		log.Error(err, "failed to reconcile machine")
-		r.queue.AddRateLimited(id)
+		if errors.Is(context.Cause(ctx), errMachineDeleted) {
+			// The deletion event requeued the machine, its next reconcile hands it to the garbage collector.
+			log.V(1).Info("Cancelled reconcile of deleted machine", "Error", err)
+			summary.setOutcome(reconcileOutcomeCancelled)
+			r.backoff.Forget(id)
+			r.queue.Forget(id)
+			return true
+		}
+		summary.setOutcome(reconcileOutcomeError)
+		if call.IsTimeout(err) {
+			summary.setOutcome(reconcileOutcomeTimeout)
+		}
+		r.retryReconcile(ctx, log, id, err)
+		r.lastFailed.Store(time.Now().UnixNano())
 		return true
 	}
 
+	r.backoff.Forget(id)
 	r.queue.Forget(id)
+	r.lastReconciled.Store(time.Now().UnixNano())
 	return true
 }
 
+// CheckProgress returns an error if machines are waiting to be reconciled or failing to reconcile, but no
+// machine was reconciled successfully within the threshold.
+func (r *MachineReconciler) CheckProgress(threshold time.Duration) error {
+	lastReconciled := r.lastReconciled.Load()
+	pending := r.queue.Len()
+	if pending == 0 && r.lastFailed.Load() <= lastReconciled {
+		return nil
+	}
+
+	if since := time.Since(time.Unix(0, lastReconciled)); since > threshold {
+		return fmt.Errorf("%d machines pending, last successful reconcile %s ago", pending, since.Round(time.Second))
+	}
+	return nil
+}
+
 func (r *MachineReconciler) reconcileMachine(ctx context.Context, id string) error {
 	log := logr.FromContextOrDiscard(ctx)
+	summary := reconcileSummaryFromContext(ctx)
 
 	log.V(2).Info("Getting machine from store", "id", id)
 	machine, err := r.machines.Get(ctx, id)
@@ -460,41 +885,107 @@ func (r *MachineReconciler) reconcileMachine(ctx context.Context, id string) err
 			return fmt.Errorf("failed to fetch machine from store: %w", err)
 		}
 
+		r.rootFSCreations.forget(id)
+		summary.setOutcome(reconcileOutcomeNotFound)
 		return nil
 	}
 
 	if machine.DeletedAt != nil {
+		r.rootFSCreations.forget(machine.ID)
+		// Deletions are processed by the garbage collector workers, don't wait for its next resync.
+		if isTerminating(machine) {
+			r.gcQueue.Add(machine.ID)
+		}
+		summary.setOutcome(reconcileOutcomeDeleting)
 		return nil
 	}
 
 	if !slices.Contains(machine.Finalizers, MachineFinalizer) {
 		machine.Finalizers = append(machine.Finalizers, MachineFinalizer)
-		if _, err := r.machines.Update(ctx, machine); err != nil {
+		if _, err := store.RetryOnConflict(ctx, r.machines, machine, func(latest *api.Machine) error {
+			if !slices.Contains(latest.Finalizers, MachineFinalizer) {
+				latest.Finalizers = append(latest.Finalizers, MachineFinalizer)
+			}
+			return nil
+		}); err != nil {
 			return fmt.Errorf("failed to set finalizers: %w", err)
 		}
+		summary.setOutcome(reconcileOutcomeFinalizerAdded)
 		return nil
 	}
 
-	log.V(1).Info("Making machine directories")
+	log.V(2).Info("Making machine directories")
+	done := summary.phase("dirs")
 	if err := providerhost.MakeMachineDirs(r.host, machine.ID); err != nil {
 		return fmt.Errorf("error making machine directories: %w", err)
 	}
-	log.V(1).Info("Successfully made machine directories")
+	if err := r.reconcileDirQuota(machine); err != nil {
+		return fmt.Errorf("error limiting machine directory: %w", err)
+	}
+	done()
+	log.V(2).Info("Successfully made machine directories")
 
-	log.V(1).Info("Reconciling domain")
+	log.V(2).Info("Reconciling domain")
+	if ptr.Deref(machine.Spec.Image, "") == "" {
+		setCondition(machine, api.MachineConditionImageReady, true, conditionReasonNoImage, "")
+	}
 	state, volumeStates, nicStates, err := r.reconcileDomain(ctx, log, machine)
 	if err != nil {
-		return providerimage.IgnoreImagePulling(err)
+		if errors.Is(err, providerimage.ErrImagePulling) {
+			setCondition(machine, api.MachineConditionDomainSynced, false, conditionReasonImagePulling, "")
+			r.updateConditions(ctx, log, machine.ID, machine.Status.Conditions)
+			summary.setOutcome(reconcileOutcomeImagePulling)
+			return nil
+		}
+		if errors.Is(err, errRootFSCreationQueued) {
+			setCondition(machine, api.MachineConditionDomainSynced, false, conditionReasonRootFSQueued, "")
+			r.updateConditions(ctx, log, machine.ID, machine.Status.Conditions)
+			summary.setOutcome(reconcileOutcomeRootFSQueued)
+			r.queue.AddAfter(machine.ID, rootFSCreationRetryDelay)
+			return nil
+		}
+		if volumePluginsUnhealthy(err) {
+			setErrorCondition(machine, api.MachineConditionDomainSynced, conditionReasonVolumePluginUnhealthy, err)
+			r.updateConditions(ctx, log, machine.ID, machine.Status.Conditions)
+			summary.setOutcome(reconcileOutcomeVolumePluginUnhealthy)
+			r.queue.AddAfter(machine.ID, volumePluginUnhealthyRetryDelay)
+			return nil
+		}
+		var attachDetachErr *attachDetachError
+		if errors.As(err, &attachDetachErr) {
+			r.setFailed(log, machine, attachDetachErr)
+		}
+		reason := conditionReasonReconcileFailed
+		if call.IsTimeout(err) {
+			reason = conditionReasonTimeout
+		}
+		setErrorCondition(machine, api.MachineConditionDomainSynced, reason, err)
+		r.updateFailedStatus(ctx, log, machine)
+		return err
 	}
-	log.V(1).Info("Reconciled domain")
+	log.V(2).Info("Reconciled domain")
 
 	machine.Status.VolumeStatus = volumeStates
 	machine.Status.NetworkInterfaceStatus = nicStates
 	machine.Status.State = state
-
-	if _, err = r.machines.Update(ctx, machine); err != nil {
+	machine.Status.FailureReason = ""
+	machine.Status.FailureMessage = ""
+
+	done = summary.phase("status")
+	// The status reflects the domain. If the machine was updated during the reconcile, only the status fields
+	// owned by the reconcile are replaced, its spec changes and the progress of the workers are kept.
+	if _, err = store.RetryOnConflict(ctx, r.machines, machine, func(latest *api.Machine) error {
+		setReconciledStatus(latest, machine.Status)
+		return nil
+	}); err != nil {
 		return fmt.Errorf("failed to update machine status: %w", err)
 	}
+	done()
+
+	_, unresolvedCreate := r.unresolvedCreates.LoadAndDelete(machine.ID)
+	if unresolvedCreate || (summary != nil && summary.outcome == reconcileOutcomeCreated) {
+		r.recordOperation(log, machine.ID, journal.OperationCreate, "", nil)
+	}
 
 	return nil
 }
@@ -504,27 +995,62 @@ func (r *MachineReconciler) reconcileDomain(
 	log logr.Logger,
 	machine *api.Machine,
 ) (api.MachineState, []api.VolumeStatus, []api.NetworkInterfaceStatus, error) {
-	log.V(1).Info("Looking up domain")
-	if _, err := r.libvirt.DomainLookupByUUID(libvirtutils.UUIDStringToBytes(machine.ID)); err != nil {
+	summary := reconcileSummaryFromContext(ctx)
+
+	log.V(2).Info("Looking up domain")
+	done := summary.phase("lookup")
+	domain, err := call.Value(ctx, r.libvirtCallTimeout, func() (libvirt.Domain, error) {
+		return r.conn().DomainLookupByUUID(libvirtutils.UUIDStringToBytes(machine.ID))
+	})
+	done()
+	exists := true
+	if err != nil {
 		if !libvirt.IsNotFound(err) {
 			return "", nil, nil, fmt.Errorf("error getting domain %s: %w", machine.ID, err)
 		}
+		exists = false
+	}
 
-		log.V(1).Info("Creating new domain")
+	if machine.Spec.Power == api.PowerStatePowerOff {
+		state, err := r.reconcilePowerOff(ctx, log, machine, domain, exists)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		summary.setOutcome(reconcileOutcomePoweredOff)
+		return state, machine.Status.VolumeStatus, machine.Status.NetworkInterfaceStatus, nil
+	}
+	// The shutdown of a machine powered on again before its domain went away is abandoned.
+	machine.Status.Shutdown = nil
+
+	if exists && r.rescueChanged(machine) {
+		if err := r.stopDomainForRescue(ctx, log, machine, domain); err != nil {
+			return "", nil, nil, err
+		}
+		exists = false
+	}
+
+	if !exists {
+		log.V(2).Info("Creating new domain")
+		done := summary.phase("create")
 		volumeStates, nicStates, err := r.createDomain(ctx, log, machine)
 		if err != nil {
 			return "", nil, nil, err
 		}
+		done()
 
-		log.V(1).Info("Created domain")
+		log.V(2).Info("Created domain")
+		summary.setOutcome(reconcileOutcomeCreated)
+		setCondition(machine, api.MachineConditionDomainSynced, true, conditionReasonCreated, "")
 		return api.MachineStatePending, volumeStates, nicStates, nil
 	}
 
-	log.V(1).Info("Updating existing domain")
+	log.V(2).Info("Updating existing domain")
 	volumeStates, nicStates, err := r.updateDomain(ctx, log, machine)
 	if err != nil {
 		return "", nil, nil, err
 	}
+	summary.setOutcome(reconcileOutcomeUpdated)
+	setCondition(machine, api.MachineConditionDomainSynced, true, conditionReasonUpdated, "")
 
 	state, err := r.getMachineState(machine.ID)
 	if err != nil {
@@ -544,33 +1070,93 @@ func (r *MachineReconciler) updateDomain(
 		return nil, nil, fmt.Errorf("error getting domain description: %w", err)
 	}
 
-	attacher, err := NewLibvirtVolumeAttacher(domainDesc, NewRunningDomainExecutor(r.libvirt, machine.ID), r.volumeCachePolicy)
+	attacher, err := NewLibvirtVolumeAttacher(domainDesc, r.newDomainExecutor(ctx, machine.ID), r.cpuPinning, r.virtioQueues(machine).disk)
 	if err != nil {
 		return nil, nil, fmt.Errorf("error construction volume attacher: %w", err)
 	}
 
+	summary := reconcileSummaryFromContext(ctx)
+
+	done := summary.phase("volumes")
 	volumeStates, err := r.attachDetachVolumes(ctx, log, machine, attacher)
 	if err != nil {
+		if errors.As(err, new(*attachDetachError)) {
+			// The volumes that didn't fail are reconciled, their states are reported along with the failed ones.
+			machine.Status.VolumeStatus = volumeStates
+		}
+		setErrorCondition(machine, api.MachineConditionVolumesAttached, conditionReasonFailed, err)
 		r.Eventf(log, machine.Metadata, corev1.EventTypeWarning, "AttchDetachVolume", "Volume attach/detach failed with error: %s", err)
 		return nil, nil, fmt.Errorf("[volumes] %w", err)
 	}
+	setVolumesAttachedCondition(machine, volumeStates)
+	done()
 
+	done = summary.phase("nics")
 	nicStates, err := r.attachDetachNetworkInterfaces(ctx, log, machine, domainDesc)
 	if err != nil {
+		setErrorCondition(machine, api.MachineConditionNetworkReady, conditionReasonFailed, err)
 		r.Eventf(log, machine.Metadata, corev1.EventTypeWarning, "AttchDetachNIC", "NIC attach/detach failed with error: %s", err)
 		return nil, nil, fmt.Errorf("[network interfaces] %w", err)
 	}
+	setNetworkReadyCondition(machine, nicStates)
+	r.setNetworkInterfaceIPs(log, machine, domainDesc, nicStates)
+	done()
+
+	done = summary.phase("media")
+	if err := r.reconcileMedia(ctx, log, machine, domainDesc); err != nil {
+		r.Eventf(log, machine.Metadata, corev1.EventTypeWarning, "ChangeMediaFailed", "Media insert/eject failed with error: %s", err)
+		return nil, nil, fmt.Errorf("[media] %w", err)
+	}
+	done()
+
+	setGuestAgentConnectedCondition(machine, domainDesc)
+	// Dedicated iothreads are pinned while volumes are attached, the placement is taken from the live domain.
+	machine.Status.Placement = placementStatus(domainDesc)
+	r.reconcileDrift(ctx, log, machine, domainDesc)
+
+	if err := r.refreshDomainMetadata(log, machine, domainDesc); err != nil {
+		// The metadata is only needed to recover the machine and for debugging, don't fail the reconcile.
+		log.Error(err, "failed to refresh domain metadata")
+	}
 
 	return volumeStates, nicStates, nil
 }
 
+func (r *MachineReconciler) beginOperation(log logr.Logger, machineID string, operation journal.Operation, target string) {
+	if err := r.journal.Begin(machineID, operation, target); err != nil {
+		log.Error(err, "failed to record operation intent in journal", "Operation", operation, "Target", target)
+	}
+}
+
+func (r *MachineReconciler) recordOperation(log logr.Logger, machineID string, operation journal.Operation, target string, opErr error) {
+	if err := r.journal.Record(machineID, operation, target, opErr); err != nil {
+		log.Error(err, "failed to record operation in journal", "Operation", operation, "Target", target)
+	}
+}
+
 func (r *MachineReconciler) getMachineState(machineID string) (api.MachineState, error) {
-	domainState, _, err := r.libvirt.DomainGetState(machineDomain(machineID), 0)
+	domainState, _, err := r.conn().DomainGetState(machineDomain(machineID), 0)
 	if err != nil {
 		return "", fmt.Errorf("error getting domain state: %w", err)
 	}
 
-	if machineState, ok := domainStateToMachineState[libvirt.DomainState(domainState)]; ok {
+	return domainMachineState(r.host, machineID, libvirt.DomainState(domainState))
+}
+
+// domainMachineState returns the machine state of a domain in the given state. Paused domains are only suspended
+// while paused due to a host condition, other pauses, e.g. during migrations or on i/o errors, are transient.
+func domainMachineState(paths providerhost.Paths, machineID string, domainState libvirt.DomainState) (api.MachineState, error) {
+	if domainState == libvirt.DomainPaused {
+		paused, err := hostevent.PausedByHostEvent(paths, machineID)
+		if err != nil {
+			return "", err
+		}
+		if paused {
+			return api.MachineStateSuspended, nil
+		}
+	}
+
+	if machineState, ok := domainStateToMachineState[domainState]; ok {
 		return machineState, nil
 	}
 	return api.MachineStatePending, nil
@@ -591,11 +1177,35 @@ func (r *MachineReconciler) createDomain(
 		return nil, nil, err
 	}
 
-	log.V(1).Info("Creating domain")
-	log.V(2).Info("Domain", "XML", domainXMLData)
-	if _, err := r.libvirt.DomainCreateXML(domainXMLData, libvirt.DomainNone); err != nil {
+	if err := os.WriteFile(r.host.MachineDomainXMLFile(machine.ID), []byte(domainXMLData), filePerm); err != nil {
+		log.V(1).Info("Failed to save rendered domain XML", "Error", err)
+	}
+
+	log.V(2).Info("Creating domain")
+	log.V(3).Info("Domain", "XML", domainXMLData)
+	// The create is completed once the status of the created domain is persisted.
+	r.beginOperation(log, machine.ID, journal.OperationCreate, "")
+	conn, abort := r.mutatingConn()
+	if _, err := call.MutateValue(ctx, r.libvirtCallTimeout, abort, func() (libvirt.Domain, error) {
+		return conn.DomainCreateXML(domainXMLData, libvirt.DomainNone)
+	}); err != nil {
+		if ctx.Err() != nil || call.IsTimeout(err) {
+			// The daemon may complete an aborted creation nonetheless. The next reconcile either finds the domain
+			// and completes the creation or begins it again.
+			r.unresolvedCreates.Store(machine.ID, struct{}{})
+			return nil, nil, err
+		}
+		r.recordOperation(log, machine.ID, journal.OperationCreate, "", err)
 		return nil, nil, err
 	}
+	r.consumeBootOverride(ctx, log, machine)
+	r.setRescueStatus(machine)
+	machine.Status.MachineType = domainXML.OS.Type.Machine
+	machine.Status.Placement = placementStatus(domainXML)
+
+	setVolumesAttachedCondition(machine, volumeStates)
+	setNetworkReadyCondition(machine, nicStates)
+	setGuestAgentConnectedCondition(machine, domainXML)
 
 	return volumeStates, nicStates, nil
 }
@@ -605,12 +1215,18 @@ func (r *MachineReconciler) domainFor(
 	log logr.Logger,
 	machine *api.Machine,
 ) (*libvirtxml.Domain, []api.VolumeStatus, []api.NetworkInterfaceStatus, error) {
-	architecture := "x86_64"  // TODO: Detect this from the image / machine specification.
-	osType := guest.OSTypeHVM // TODO: Make this configurable via machine class
-	domainSettings, err := r.guestCapabilities.SettingsFor(guest.Requests{
+	architecture := r.guestCapabilities.HostArchitecture() // TODO: Detect this from the image / machine specification.
+	osType := guest.OSTypeHVM                              // TODO: Make this configurable via machine class
+	guestRequests := guest.Requests{
 		Architecture: architecture,
 		OSType:       osType,
-	})
+	}
+	domainSettings, err := r.guestCapabilities.SettingsFor(guestRequests)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	machineType, err := r.machineTypeFor(log, machine, guestRequests, domainSettings.Machine)
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -633,7 +1249,7 @@ func (r *MachineReconciler) domainFor(
 			Type: &libvirtxml.DomainOSType{
 				Type:    string(osType),
 				Arch:    architecture,
-				Machine: domainSettings.Machine,
+				Machine: machineType,
 			},
 			BootDevices: []libvirtxml.DomainBootDevice{
 				{Dev: "hd"},
@@ -648,24 +1264,7 @@ func (r *MachineReconciler) domainFor(
 				},
 			},
 		},
-		Clock: &libvirtxml.DomainClock{
-			Offset: "utc",
-			Timer: []libvirtxml.DomainTimer{
-				{
-					Name:       "rtc",
-					TickPolicy: "catchup",
-				},
-				{
-					Name:       "hpet",
-					TickPolicy: "catchup",
-				},
-				{
-					Name:       "tsc",
-					Mode:       "paravirt",
-					TickPolicy: "catchup",
-				},
-			},
-		},
+		Clock: clockpolicy.Clock(machine.Spec.Clock),
 		Devices: &libvirtxml.DomainDeviceList{
 			Serials: []libvirtxml.DomainSerial{
 				{
@@ -686,17 +1285,6 @@ func (r *MachineReconciler) domainFor(
 			//	Model:  "i6300esb",
 			//	Action: "reset",
 			//},
-			RNGs: []libvirtxml.DomainRNG{
-				{
-					Model: "virtio",
-					Rate: &libvirtxml.DomainRNGRate{
-						Bytes: 512,
-					},
-					Backend: &libvirtxml.DomainRNGBackend{
-						Random: &libvirtxml.DomainRNGBackendRandom{},
-					},
-				},
-			},
 		},
 	}
 
@@ -704,11 +1292,14 @@ func (r *MachineReconciler) domainFor(
 		return nil, nil, nil, err
 	}
 
-	if err := r.setDomainResources(machine, domainDesc); err != nil {
+	if err := r.setDomainResources(log, machine, domainDesc); err != nil {
 		return nil, nil, nil, err
 	}
 
-	if err := r.setDomainPCIControllers(domainDesc); err != nil {
+	r.setDomainCPUPinning(domainDesc)
+	cgrouppolicy.SetCgroup(machine.Spec.Cgroup, domainDesc)
+
+	if err := r.setDomainPCIControllers(machine, domainDesc); err != nil {
 		return nil, nil, nil, err
 	}
 
@@ -716,6 +1307,15 @@ func (r *MachineReconciler) domainFor(
 		return nil, nil, nil, err
 	}
 
+	if err := setDomainFirmware(machine, domainDesc); err != nil {
+		return nil, nil, nil, err
+	}
+
+	setDomainSMBIOS(machine, domainDesc)
+	setDomainSGX(machine, domainDesc)
+	deviceprofile.SetDevices(machine.Spec.Devices, domainDesc.Devices)
+	windows.SetHyperV(machine.Spec.Windows, domainDesc)
+
 	if machine.Spec.GuestAgent != api.GuestAgentNone {
 		r.setGuestAgent(machine, domainDesc)
 	}
@@ -726,6 +1326,12 @@ func (r *MachineReconciler) domainFor(
 		}
 	}
 
+	if machine.Spec.Rescue != nil {
+		if err := r.setDomainRescue(ctx, log, machine, domainDesc); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
 	if ignitionSpec := machine.Spec.Ignition; ignitionSpec != nil {
 		if err := r.setDomainIgnition(machine, domainDesc); err != nil {
 			return nil, nil, nil, err
@@ -734,7 +1340,7 @@ func (r *MachineReconciler) domainFor(
 		r.Eventf(log, machine.Metadata, corev1.EventTypeWarning, "NoIgnitionData", "Machine does not have ignition data")
 	}
 
-	attacher, err := NewLibvirtVolumeAttacher(domainDesc, NewCreateDomainExecutor(r.libvirt), r.volumeCachePolicy)
+	attacher, err := NewLibvirtVolumeAttacher(domainDesc, NewCreateDomainExecutor(r.conn()), r.cpuPinning, r.virtioQueues(machine).disk)
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -757,25 +1363,39 @@ func (r *MachineReconciler) domainFor(
 		r.Eventf(log, machine.Metadata, corev1.EventTypeNormal, "AttchedNIC", "Successfully attached network interfaces")
 	}
 
+	if err := r.setDomainMedia(ctx, log, machine, domainDesc); err != nil {
+		return nil, nil, nil, err
+	}
+
+	if err := r.setDomainPCIDevices(machine, domainDesc); err != nil {
+		return nil, nil, nil, err
+	}
+
+	if err := r.setDomainBoot(log, machine, domainDesc); err != nil {
+		return nil, nil, nil, err
+	}
+
 	return domainDesc, volumeStates, nicStates, nil
 }
 
 func (r *MachineReconciler) setDomainMetadata(log logr.Logger, machine *api.Machine, domain *libvirtxml.Domain) error {
-	labels, found := machine.Metadata.Annotations[api.LabelsAnnotation]
-	if !found {
-		log.V(1).Info("IRI machine labels are not annotated in the API machine")
-		return nil
-	}
-	var irimachineLabels map[string]string
-	err := json.Unmarshal([]byte(labels), &irimachineLabels)
+	snapshot, err := machineSnapshot(machine)
 	if err != nil {
-		return fmt.Errorf("error unmarshalling iri machine labels: %w", err)
+		return err
 	}
 
-	encodedLabels := libvirtmeta.IRIMachineLabelsEncoder(irimachineLabels)
-
 	domainMetadata := &libvirtmeta.LibvirtProviderMetadata{
-		IRIMmachineLabels: encodedLabels,
+		Machine: snapshot,
+	}
+
+	if labels, found := machine.Metadata.Annotations[api.LabelsAnnotation]; found {
+		var irimachineLabels map[string]string
+		if err := json.Unmarshal([]byte(labels), &irimachineLabels); err != nil {
+			return fmt.Errorf("error unmarshalling iri machine labels: %w", err)
+		}
+		domainMetadata.IRIMmachineLabels = libvirtmeta.IRIMachineLabelsEncoder(irimachineLabels)
+	} else {
+		log.V(1).Info("IRI machine labels are not annotated in the API machine")
 	}
 
 	domainMetadataXML, err := xml.Marshal(domainMetadata)
@@ -788,40 +1408,127 @@ func (r *MachineReconciler) setDomainMetadata(log logr.Logger, machine *api.Mach
 	return nil
 }
 
-func (r *MachineReconciler) setDomainResources(machine *api.Machine, domain *libvirtxml.Domain) error {
+func (r *MachineReconciler) setDomainResources(log logr.Logger, machine *api.Machine, domain *libvirtxml.Domain) error {
 	// TODO: check if there is better or check possible while conversion to uint
 	domain.Memory = &libvirtxml.DomainMemory{
 		Value: uint(machine.Spec.MemoryBytes),
 		Unit:  "Byte",
 	}
 
-	if r.enableHugepages {
+	cpu := uint(machine.Spec.CpuMillis / 1000)
+	domain.VCPU = &libvirtxml.DomainVCPU{
+		Value: cpu,
+	}
+
+	switch {
+	case machine.Spec.Hugepages != nil:
+		domain.MemoryBacking = &libvirtxml.DomainMemoryBacking{
+			MemoryHugePages: &libvirtxml.DomainMemoryHugepages{
+				Hugepages: []libvirtxml.DomainMemoryHugepage{
+					{
+						Size: uint(machine.Spec.Hugepages.PageSize >> 10),
+						Unit: "KiB",
+					},
+				},
+			},
+		}
+	case r.enableHugepages:
+		// Machines created before the page size was part of the machine spec use the default page size.
 		domain.MemoryBacking = &libvirtxml.DomainMemoryBacking{
 			MemoryHugePages: &libvirtxml.DomainMemoryHugepages{},
 		}
 	}
 
-	cpu := uint(machine.Spec.CpuMillis / 1000)
-	domain.VCPU = &libvirtxml.DomainVCPU{
-		Value: cpu,
+	return r.setDomainPlacement(log, machine, domain)
+}
+
+// setDomainPlacement places the machine on a NUMA node holding its PCI devices and the hugepages backing its
+// memory, see placement.NUMACoordinator, and restricts the vCPUs to the cpus of that node. Memory backed by
+// hugepages is bound to the node strictly, other memory is only allocated from it preferably. The claimed PCI
+// devices are recorded in the machine status, they are passed through by setDomainPCIDevices.
+func (r *MachineReconciler) setDomainPlacement(log logr.Logger, machine *api.Machine, domain *libvirtxml.Domain) error {
+	if len(machine.Spec.PCIDevices) > 0 && r.pciDevices == nil {
+		return retrypolicy.Permanent(fmt.Errorf("cannot pass pci devices through: no pci device pools configured"))
+	}
+
+	req := placement.Request{
+		MachineID:       machine.ID,
+		PCIDeviceClaims: machine.Spec.PCIDevices,
+		PCIDevices:      machine.Status.PCIDevices,
+	}
+	if machine.Spec.Hugepages != nil {
+		pages, err := hugepages.Pages(machine.Spec.MemoryBytes, machine.Spec.Hugepages.PageSize)
+		if err != nil {
+			return err
+		}
+		req.HugepageSize, req.Hugepages = machine.Spec.Hugepages.PageSize, pages
+	}
+	claiming := len(req.PCIDevices) == 0 && len(req.PCIDeviceClaims) > 0
+
+	placed, err := r.placement.Place(req)
+	if err != nil {
+		if claiming {
+			r.Eventf(log, machine.Metadata, corev1.EventTypeWarning, "ClaimPCIDevicesFailed", "Claiming pci devices failed with error: %s", err)
+		}
+		if errors.Is(err, placement.ErrNoNode) {
+			err = retrypolicy.WithClass(retrypolicy.ClassUnschedulable, err)
+		}
+		return fmt.Errorf("error placing machine on numa node: %w", err)
+	}
+	if claiming {
+		machine.Status.PCIDevices = placed.PCIDevices
+		r.Eventf(log, machine.Metadata, corev1.EventTypeNormal, "ClaimedPCIDevices", "Claimed %d pci devices", len(placed.PCIDevices))
+	}
+	if placed.Node == placement.AnyNode {
+		return nil
 	}
 
+	mode := "preferred"
+	if machine.Spec.Hugepages != nil {
+		mode = "strict"
+	}
+	domain.NUMATune = &libvirtxml.DomainNUMATune{
+		Memory: &libvirtxml.DomainNUMATuneMemory{
+			Mode:    mode,
+			Nodeset: strconv.Itoa(placed.Node),
+		},
+	}
+	domain.VCPU.CPUSet = placed.CPUSet
 	return nil
 }
 
-// TODO: Investigate hotplugging the pcie-root-port controllers with disks.
-// Ref: https://libvirt.org/pci-hotplug.html#x86_64-q35
-func (r *MachineReconciler) setDomainPCIControllers(domain *libvirtxml.Domain) error {
+// setDomainPCIControllers adds the root ports and switch ports hotplugged devices are plugged into. Ports can't
+// be hotplugged themselves, see pci.Topology.
+func (r *MachineReconciler) setDomainPCIControllers(machine *api.Machine, domain *libvirtxml.Domain) error {
+	layout := r.pciLayout
+	if machine.Spec.PCI != nil {
+		layout = pci.Layout{
+			RootPorts:             machine.Spec.PCI.RootPorts,
+			SwitchDownstreamPorts: machine.Spec.PCI.SwitchDownstreamPorts,
+		}
+	}
+	if err := layout.Validate(); err != nil {
+		return fmt.Errorf("invalid pci layout: %w", err)
+	}
+
+	var rootIndex uint
 	domain.Devices.Controllers = append(domain.Devices.Controllers, libvirtxml.DomainController{
-		Type:  "pci",
-		Model: "pcie-root",
+		Type:  pci.ControllerType,
+		Model: pci.RootModel,
+		Index: &rootIndex,
 	})
 
-	for i := 1; i <= 30; i++ {
-		domain.Devices.Controllers = append(domain.Devices.Controllers, libvirtxml.DomainController{
-			Type:  "pci",
-			Model: "pcie-root-port",
-		})
+	topology, err := pci.ForDomain(domain)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < layout.RootPorts; i++ {
+		topology.AddRootPort()
+	}
+	if layout.SwitchDownstreamPorts > 0 {
+		if _, err := topology.AddSwitch(layout.SwitchDownstreamPorts); err != nil {
+			return fmt.Errorf("error adding pci switch: %w", err)
+		}
 	}
 	return nil
 }
@@ -870,6 +1577,14 @@ func (r *MachineReconciler) setGuestAgent(machine *api.Machine, domainDesc *libv
 	machine.Status.GuestAgentStatus = &api.GuestAgentStatus{Addr: "unix://" + socketPath}
 }
 
+// usesImage reports whether the domain of the machine is created from or holds the image with the given reference.
+func (r *MachineReconciler) usesImage(machine *api.Machine, ref string) bool {
+	if ptr.Deref(machine.Spec.Image, "") == ref || usesMediaImage(machine, ref) {
+		return true
+	}
+	return machine.Spec.Rescue != nil && r.rescueImageRef(machine) == ref
+}
+
 func (r *MachineReconciler) setDomainImage(
 	ctx context.Context,
 	log logr.Logger,
@@ -880,38 +1595,30 @@ func (r *MachineReconciler) setDomainImage(
 	img, err := r.imageCache.Get(ctx, machineImgRef)
 	if err != nil {
 		if !errors.Is(err, providerimage.ErrImagePulling) {
-			return err
+			setErrorCondition(machine, api.MachineConditionImageReady, conditionReasonPullFailed, err)
+			return retrypolicy.WithClass(retrypolicy.ClassImage, err)
 		}
 
+		setCondition(machine, api.MachineConditionImageReady, false, conditionReasonPulling, fmt.Sprintf("Pulling image %s", machineImgRef))
 		r.Eventf(log, machine.Metadata, corev1.EventTypeNormal, "PullingImage", "Pulling image %s", machineImgRef)
 		return err
 	}
+	setCondition(machine, api.MachineConditionImageReady, true, conditionReasonReady, "")
 
-	rootFSFile := r.host.MachineRootFSFile(machine.ID)
-	ok, err := osutils.RegularFileExists(rootFSFile)
+	rootFSFile, rootFSFormat, err := r.prepareRootFS(log, machine, img, machineImgRef)
 	if err != nil {
 		return err
 	}
-	if !ok {
-		if err := r.raw.Create(rootFSFile, raw.WithSourceFile(img.RootFS.Path)); err != nil {
-			return fmt.Errorf("error creating root fs disk: %w", err)
-		}
-		if err := os.Chmod(rootFSFile, filePerm); err != nil {
-			return fmt.Errorf("error changing root fs disk mode: %w", err)
-		}
-	}
+	pinImageDigest(machine, img)
 
-	domain.OS.Kernel = img.Kernel.Path
-	domain.OS.Initrd = img.InitRAMFs.Path
-	domain.OS.Cmdline = img.Config.CommandLine
-	domain.Devices.Disks = append(domain.Devices.Disks, libvirtxml.DomainDisk{
+	disk := libvirtxml.DomainDisk{
 		Alias: &libvirtxml.DomainAlias{
-			Name: rootFSAlias,
+			Name: alias.RootFS,
 		},
 		Device: "disk",
 		Driver: &libvirtxml.DomainDiskDriver{
 			Name: "qemu",
-			Type: "raw",
+			Type: rootFSFormat,
 		},
 		Source: &libvirtxml.DomainDiskSource{
 			File: &libvirtxml.DomainDiskSourceFile{
@@ -919,15 +1626,59 @@ func (r *MachineReconciler) setDomainImage(
 			},
 		},
 		Target: &libvirtxml.DomainDiskTarget{
-			Dev: "vdaaa", // TODO: Reserving vdaaa for ramdisk, so that it doesnt conflict with other volumes, investigate better solution.
+			Dev: device.RootFSTarget,
 			Bus: "virtio",
 		},
-		Serial:   "machineboot",
-		ReadOnly: &libvirtxml.DomainDiskReadOnly{},
-	})
+		Serial: rootFSSerial,
+	}
+	windows.SetRootFSBus(machine.Spec.Windows, &disk)
+	assignDiskIOThread(domain, &disk, r.cpuPinning.IOThreads)
+
+	if !img.IsDirectKernelBoot() {
+		if len(machine.Spec.ExtraKernelCmdline) > 0 {
+			r.Eventf(log, machine.Metadata, corev1.EventTypeWarning, "IgnoredKernelCmdline", "Image %s does not support direct kernel boot, extra kernel command line is ignored", machineImgRef)
+		}
+
+		// Without kernel and initramfs the rootfs is a bootable disk image that is booted by the firmware,
+		// hence it has to be writable and the first device in the boot order.
+		domain.OS.BootDevices = nil
+		disk.Boot = &libvirtxml.DomainDeviceBoot{
+			Order: 1,
+		}
+		domain.Devices.Disks = append(domain.Devices.Disks, disk)
+		return nil
+	}
+
+	cmdline, err := kernelcmdline.Merge(img.Config.CommandLine, machine.Spec.ExtraKernelCmdline)
+	if err != nil {
+		return fmt.Errorf("error merging kernel command line: %w", err)
+	}
+
+	domain.OS.Kernel = img.Kernel.Path
+	domain.OS.Initrd = img.InitRAMFs.Path
+	domain.OS.Cmdline = cmdline
+	disk.ReadOnly = &libvirtxml.DomainDiskReadOnly{}
+	domain.Devices.Disks = append(domain.Devices.Disks, disk)
 	return nil
 }
 
+func setDomainSMBIOS(machine *api.Machine, domain *libvirtxml.Domain) {
+	domain.OS.SMBios = &libvirtxml.DomainSMBios{Mode: "sysinfo"}
+	domain.SysInfo = append(domain.SysInfo, smbios.SysInfo(machine.ID, machine.Spec.SMBIOS))
+}
+
+// setDomainSGX adds the memory device backing the SGX enclave page cache of the machine.
+func setDomainSGX(machine *api.Machine, domain *libvirtxml.Domain) {
+	if machine.Spec.SGX == nil || machine.Spec.SGX.EPCBytes == 0 {
+		return
+	}
+
+	if domain.Devices == nil {
+		domain.Devices = &libvirtxml.DomainDeviceList{}
+	}
+	domain.Devices.Memorydevs = append(domain.Devices.Memorydevs, sgx.Memorydev(machine.Spec.SGX.EPCBytes))
+}
+
 func (r *MachineReconciler) setDomainIgnition(machine *api.Machine, domain *libvirtxml.Domain) error {
 	ignitionData := machine.Spec.Ignition
 
@@ -951,7 +1702,7 @@ func (r *MachineReconciler) setDomainIgnition(machine *api.Machine, domain *libv
 }
 
 func (r *MachineReconciler) getDomainDesc(machineID string) (*libvirtxml.Domain, error) {
-	domainXMLData, err := r.libvirt.DomainGetXMLDesc(libvirt.Domain{UUID: libvirtutils.UUIDStringToBytes(machineID)}, 0)
+	domainXMLData, err := r.conn().DomainGetXMLDesc(libvirt.Domain{UUID: libvirtutils.UUIDStringToBytes(machineID)}, 0)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,108 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/digitalocean/go-libvirt"
+	"github.com/go-logr/logr"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/alias"
+	"libvirt.org/go/libvirtxml"
+)
+
+// networkInterfaceNamesByMAC returns the names of the network interfaces of the domain by their MAC address.
+func networkInterfaceNamesByMAC(domainDesc *libvirtxml.Domain) map[string]string {
+	res := make(map[string]string)
+	for _, iface := range domainDescInterfaces(domainDesc) {
+		if iface.Alias == nil || iface.MAC == nil || !alias.IsNetworkInterface(iface.Alias.Name) {
+			continue
+		}
+
+		name, err := alias.ParseNetworkInterface(iface.Alias.Name)
+		if err != nil {
+			continue
+		}
+		res[strings.ToLower(iface.MAC.Address)] = name
+	}
+	return res
+}
+
+// networkInterfaceAddresses returns the IPs of the network interfaces of the machine by network interface name.
+// The guest agent is asked if it's connected, otherwise the DHCP leases of libvirt networks are used.
+func (r *MachineReconciler) networkInterfaceAddresses(machine *api.Machine, domainDesc *libvirtxml.Domain) (map[string][]string, error) {
+	namesByMAC := networkInterfaceNamesByMAC(domainDesc)
+	if len(namesByMAC) == 0 {
+		return nil, nil
+	}
+
+	source := libvirt.DomainInterfaceAddressesSrcLease
+	if machine.Spec.GuestAgent == api.GuestAgentQemu && guestAgentConnected(domainDesc) {
+		source = libvirt.DomainInterfaceAddressesSrcAgent
+	}
+
+	ifaces, err := r.conn().DomainInterfaceAddresses(machineDomain(machine.ID), uint32(source), 0)
+	if err != nil {
+		return nil, fmt.Errorf("error getting domain interface addresses: %w", err)
+	}
+	return networkInterfaceIPs(namesByMAC, ifaces), nil
+}
+
+// networkInterfaceIPs returns the sorted global IPs of the domain interfaces by network interface name.
+func networkInterfaceIPs(namesByMAC map[string]string, ifaces []libvirt.DomainInterface) map[string][]string {
+	res := make(map[string][]string)
+	for _, iface := range ifaces {
+		if len(iface.Hwaddr) == 0 {
+			continue
+		}
+
+		name, ok := namesByMAC[strings.ToLower(iface.Hwaddr[0])]
+		if !ok {
+			continue
+		}
+
+		for _, addr := range iface.Addrs {
+			ip, err := netip.ParseAddr(addr.Addr)
+			if err != nil || ip.IsLinkLocalUnicast() || ip.IsLoopback() {
+				continue
+			}
+			res[name] = append(res[name], ip.String())
+		}
+	}
+	for name := range res {
+		slices.Sort(res[name])
+		res[name] = slices.Compact(res[name])
+	}
+	return res
+}
+
+// setNetworkInterfaceIPs sets the IPs learned from the guest on the network interface states. Failing to
+// learn them doesn't fail the reconcile, the IPs of the last reconcile are kept instead.
+func (r *MachineReconciler) setNetworkInterfaceIPs(log logr.Logger, machine *api.Machine, domainDesc *libvirtxml.Domain, nicStates []api.NetworkInterfaceStatus) {
+	ips, err := r.networkInterfaceAddresses(machine, domainDesc)
+	if err != nil {
+		log.V(1).Info("Failed to get network interface addresses, keeping last known IPs", "Error", err)
+		for i := range nicStates {
+			nicStates[i].IPs = lastNetworkInterfaceIPs(machine, nicStates[i].Name)
+		}
+		return
+	}
+
+	for i := range nicStates {
+		nicStates[i].IPs = ips[nicStates[i].Name]
+	}
+}
+
+func lastNetworkInterfaceIPs(machine *api.Machine, name string) []string {
+	for _, nicStatus := range machine.Status.NetworkInterfaceStatus {
+		if nicStatus.Name == name {
+			return nicStatus.IPs
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"github.com/digitalocean/go-libvirt"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/alias"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"libvirt.org/go/libvirtxml"
+)
+
+var _ = Describe("Network interface addresses", func() {
+	It("should map the MAC addresses of the domain interfaces to the network interface names", func() {
+		domainDesc := &libvirtxml.Domain{Devices: &libvirtxml.DomainDeviceList{
+			Interfaces: []libvirtxml.DomainInterface{
+				{
+					Alias: &libvirtxml.DomainAlias{Name: alias.NetworkInterface("primary")},
+					MAC:   &libvirtxml.DomainInterfaceMAC{Address: "52:54:00:AA:BB:CC"},
+				},
+				{
+					// Not managed by the provider.
+					Alias: &libvirtxml.DomainAlias{Name: "net0"},
+					MAC:   &libvirtxml.DomainInterfaceMAC{Address: "52:54:00:00:00:01"},
+				},
+				{
+					Alias: &libvirtxml.DomainAlias{Name: alias.NetworkInterface("secondary")},
+				},
+			},
+		}}
+
+		Expect(networkInterfaceNamesByMAC(domainDesc)).To(Equal(map[string]string{
+			"52:54:00:aa:bb:cc": "primary",
+		}))
+	})
+
+	It("should return the sorted global IPs of the network interfaces", func() {
+		namesByMAC := map[string]string{"52:54:00:aa:bb:cc": "primary"}
+		ifaces := []libvirt.DomainInterface{
+			{
+				Hwaddr: []string{"52:54:00:AA:BB:CC"},
+				Addrs: []libvirt.DomainIPAddr{
+					{Addr: "fe80::1"},
+					{Addr: "10.0.0.2"},
+					{Addr: "2001:db8::2"},
+					{Addr: "10.0.0.2"},
+					{Addr: "invalid"},
+				},
+			},
+			{
+				Hwaddr: []string{"00:00:00:00:00:00"},
+				Addrs:  []libvirt.DomainIPAddr{{Addr: "127.0.0.1"}},
+			},
+		}
+
+		Expect(networkInterfaceIPs(namesByMAC, ifaces)).To(Equal(map[string][]string{
+			"primary": {"10.0.0.2", "2001:db8::2"},
+		}))
+	})
+
+	It("should keep the last known IPs if they can't be learned", func() {
+		machine := &api.Machine{Status: api.MachineStatus{
+			NetworkInterfaceStatus: []api.NetworkInterfaceStatus{
+				{Name: "primary", IPs: []string{"10.0.0.2"}},
+			},
+		}}
+		Expect(lastNetworkInterfaceIPs(machine, "primary")).To(Equal([]string{"10.0.0.2"}))
+		Expect(lastNetworkInterfaceIPs(machine, "secondary")).To(BeEmpty())
+	})
+})
@@ -0,0 +1,118 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/boot"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/alias"
+	"github.com/ironcore-dev/libvirt-provider/internal/osutils"
+	"github.com/ironcore-dev/libvirt-provider/internal/store"
+	corev1 "k8s.io/api/core/v1"
+	"libvirt.org/go/libvirtxml"
+)
+
+// setDomainBoot sets the boot order of the machine on the devices of the domain. The boot override of the
+// machine comes first and makes the firmware boot instead of the kernel of a direct kernel boot image.
+// Machines without boot spec keep the boot configuration of their image, rescued machines the one of the rescue
+// image.
+func (r *MachineReconciler) setDomainBoot(log logr.Logger, machine *api.Machine, domain *libvirtxml.Domain) error {
+	spec := machine.Spec.Boot
+	if spec == nil || machine.Spec.Rescue != nil {
+		return nil
+	}
+
+	var (
+		aliases []string
+		names   = make(map[string]string)
+	)
+	addDevice := func(device api.BootDevice) {
+		deviceAlias := boot.Alias(device)
+		aliases = append(aliases, deviceAlias)
+		names[deviceAlias] = strings.TrimSpace(fmt.Sprintf("%s %s", device.Type, device.Name))
+	}
+
+	if override := spec.Override; override != nil {
+		switch {
+		case override.ISO != "":
+			if r.rescueISODir == "" {
+				return fmt.Errorf("cannot boot iso %s: no rescue iso directory configured", override.ISO)
+			}
+			isoFile := filepath.Join(r.rescueISODir, override.ISO)
+			ok, err := osutils.RegularFileExists(isoFile)
+			if err != nil {
+				return fmt.Errorf("error checking rescue iso: %w", err)
+			}
+			if !ok {
+				return fmt.Errorf("rescue iso %s not found", isoFile)
+			}
+
+			domain.Devices.Disks = append(domain.Devices.Disks, boot.RescueDisk(isoFile))
+			aliases = append(aliases, alias.Rescue)
+		case override.Device != nil:
+			addDevice(*override.Device)
+		}
+
+		domain.OS.Kernel, domain.OS.Initrd, domain.OS.Cmdline = "", "", ""
+	}
+
+	devices := spec.Devices
+	if len(devices) == 0 {
+		devices = []api.BootDevice{{Type: api.BootDeviceTypeRootFS}}
+	}
+	for _, device := range devices {
+		addDevice(device)
+	}
+
+	missing := boot.SetOrder(domain, aliases)
+	if len(missing) == 0 {
+		return nil
+	}
+
+	var missingNames []string
+	for _, deviceAlias := range missing {
+		missingNames = append(missingNames, names[deviceAlias])
+	}
+	log.V(1).Info("Skipped missing boot devices", "Devices", missingNames)
+	r.Eventf(log, machine.Metadata, corev1.EventTypeWarning, "MissingBootDevice", "Skipped boot devices missing in the domain: %s", strings.Join(missingNames, ", "))
+	return nil
+}
+
+// consumeBootOverride drops the boot override of the machine once its domain was started with it. The override
+// of a rescued machine stays pending until it boots normally again. The spec change is persisted on its own, the
+// status update of the reconcile doesn't carry spec changes if the machine was updated meanwhile.
+func (r *MachineReconciler) consumeBootOverride(ctx context.Context, log logr.Logger, machine *api.Machine) {
+	if machine.Spec.Boot == nil || machine.Spec.Boot.Override == nil || machine.Spec.Rescue != nil {
+		return
+	}
+
+	override := machine.Spec.Boot.Override
+	log.V(1).Info("Booted boot override", "Override", override)
+	r.Eventf(log, machine.Metadata, corev1.EventTypeNormal, "BootedOverride", "Started domain with boot override")
+	machine.Spec.Boot.Override = nil
+
+	latest, err := r.machines.Get(ctx, machine.ID)
+	if err != nil {
+		log.Error(err, "failed to get machine to consume boot override")
+		return
+	}
+	dropOverride := func(latest *api.Machine) error {
+		// A boot override set meanwhile stays pending for the next start.
+		if latest.Spec.Boot != nil && reflect.DeepEqual(latest.Spec.Boot.Override, override) {
+			latest.Spec.Boot.Override = nil
+		}
+		return nil
+	}
+	_ = dropOverride(latest)
+	if _, err := store.RetryOnConflict(ctx, r.machines, latest, dropOverride); store.IgnoreErrNotFound(err) != nil {
+		log.Error(err, "failed to consume boot override")
+	}
+}
@@ -0,0 +1,201 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/go-logr/logr"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/store"
+	"libvirt.org/go/libvirtxml"
+)
+
+const (
+	guestAgentChannelName     = "org.qemu.guest_agent.0"
+	guestAgentStateConnected  = "connected"
+	conditionReasonNoImage    = "NoImage"
+	conditionReasonPulling    = "Pulling"
+	conditionReasonPullFailed = "PullFailed"
+	conditionReasonReady      = "Ready"
+	conditionReasonPending    = "Pending"
+	conditionReasonFailed     = "Failed"
+	conditionReasonConnected  = "Connected"
+	conditionReasonDisconnect = "Disconnected"
+	conditionReasonCreated    = "Created"
+	conditionReasonUpdated    = "Updated"
+
+	conditionReasonImagePulling          = "ImagePulling"
+	conditionReasonRootFSQueued          = "RootFSQueued"
+	conditionReasonVolumePluginUnhealthy = "VolumePluginUnhealthy"
+	conditionReasonReconcileFailed       = "ReconcileFailed"
+	conditionReasonTimeout               = "Timeout"
+)
+
+func setCondition(machine *api.Machine, conditionType api.MachineConditionType, ok bool, reason, message string) {
+	status := api.ConditionFalse
+	if ok {
+		status = api.ConditionTrue
+	}
+	api.SetMachineCondition(&machine.Status.Conditions, api.MachineCondition{
+		Type:    conditionType,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	})
+}
+
+func setErrorCondition(machine *api.Machine, conditionType api.MachineConditionType, reason string, err error) {
+	setCondition(machine, conditionType, false, reason, err.Error())
+}
+
+func setVolumesAttachedCondition(machine *api.Machine, volumeStates []api.VolumeStatus) {
+	for _, volumeStatus := range volumeStates {
+		if volumeStatus.State != api.VolumeStateAttached {
+			setCondition(machine, api.MachineConditionVolumesAttached, false, conditionReasonPending, fmt.Sprintf("Volume %s is %s", volumeStatus.Name, volumeStatus.State))
+			return
+		}
+	}
+	setCondition(machine, api.MachineConditionVolumesAttached, true, conditionReasonReady, "")
+}
+
+func setNetworkReadyCondition(machine *api.Machine, nicStates []api.NetworkInterfaceStatus) {
+	for _, nicStatus := range nicStates {
+		if nicStatus.State != api.NetworkInterfaceStateAttached {
+			setCondition(machine, api.MachineConditionNetworkReady, false, conditionReasonPending, fmt.Sprintf("Network interface %s is %s", nicStatus.Name, nicStatus.State))
+			return
+		}
+	}
+	setCondition(machine, api.MachineConditionNetworkReady, true, conditionReasonReady, "")
+}
+
+// setGuestAgentConnectedCondition reports whether the guest agent is connected to its channel of the domain.
+func setGuestAgentConnectedCondition(machine *api.Machine, domainDesc *libvirtxml.Domain) {
+	if machine.Spec.GuestAgent != api.GuestAgentQemu {
+		api.RemoveMachineCondition(&machine.Status.Conditions, api.MachineConditionGuestAgentConnected)
+		return
+	}
+
+	if guestAgentConnected(domainDesc) {
+		setCondition(machine, api.MachineConditionGuestAgentConnected, true, conditionReasonConnected, "")
+		return
+	}
+	setCondition(machine, api.MachineConditionGuestAgentConnected, false, conditionReasonDisconnect, "")
+}
+
+// guestAgentConnected reports whether the guest agent is connected to its channel of the domain.
+func guestAgentConnected(domainDesc *libvirtxml.Domain) bool {
+	if domainDesc.Devices == nil {
+		return false
+	}
+
+	for _, channel := range domainDesc.Devices.Channels {
+		if target := channel.Target; target != nil && target.VirtIO != nil && target.VirtIO.Name == guestAgentChannelName {
+			return target.VirtIO.State == guestAgentStateConnected
+		}
+	}
+	return false
+}
+
+func conditionsEqual(a, b []api.MachineCondition) bool {
+	return slices.EqualFunc(a, b, func(a, b api.MachineCondition) bool {
+		return a.Type == b.Type && a.Status == b.Status && a.Reason == b.Reason && a.Message == b.Message
+	})
+}
+
+// updateConditions persists the given conditions of a machine whose reconcile failed. The rest of the status is
+// left untouched. Unchanged conditions aren't written to not retrigger the reconcile of the machine.
+func (r *MachineReconciler) updateConditions(ctx context.Context, log logr.Logger, machineID string, conditions []api.MachineCondition) {
+	machine, err := r.machines.Get(ctx, machineID)
+	if err != nil {
+		if store.IgnoreErrNotFound(err) != nil {
+			log.Error(err, "failed to get machine to update conditions")
+		}
+		return
+	}
+
+	if conditionsEqual(machine.Status.Conditions, conditions) {
+		return
+	}
+
+	machine.Status.Conditions = conditions
+	if _, err := store.RetryOnConflict(ctx, r.machines, machine, func(latest *api.Machine) error {
+		latest.Status.Conditions = conditions
+		return nil
+	}); store.IgnoreErrNotFound(err) != nil {
+		log.Error(err, "failed to update machine conditions")
+	}
+}
+
+// setReconciledStatus sets the status fields owned by the reconcile on the latest machine. The sizes of the
+// volumes and the migrations started by earlier reconciles are owned by the resize and migration workers, they
+// are kept.
+func setReconciledStatus(latest *api.Machine, status api.MachineStatus) {
+	current := latest.Status.VolumeStatus
+	latest.Status = status
+	latest.Status.VolumeStatus = slices.Clone(status.VolumeStatus)
+	for i := range latest.Status.VolumeStatus {
+		volumeStatus := &latest.Status.VolumeStatus[i]
+		idx := slices.IndexFunc(current, func(s api.VolumeStatus) bool {
+			return s.Name == volumeStatus.Name && s.Handle == volumeStatus.Handle
+		})
+		if idx < 0 {
+			// The volume was attached or its migration was started by the reconcile.
+			continue
+		}
+		if volumeStatus.Migration != nil {
+			// The migration worker may have progressed or completed the migration meanwhile.
+			*volumeStatus = current[idx]
+			continue
+		}
+		if current[idx].Size != 0 {
+			volumeStatus.Size = current[idx].Size
+		}
+	}
+}
+
+// updateFailedStatus persists the conditions, the state with its failure and the volume and network interface
+// states of a machine whose reconcile failed. The rest of the status is left untouched. Its store event doesn't retrigger the reconcile,
+// the failed reconcile is retried with backoff instead.
+func (r *MachineReconciler) updateFailedStatus(ctx context.Context, log logr.Logger, machine *api.Machine) {
+	current, err := r.machines.Get(ctx, machine.ID)
+	if err != nil {
+		if store.IgnoreErrNotFound(err) != nil {
+			log.Error(err, "failed to get machine to update failed status")
+		}
+		return
+	}
+
+	status := machine.Status
+	if conditionsEqual(current.Status.Conditions, status.Conditions) &&
+		reflect.DeepEqual(current.Status.VolumeStatus, status.VolumeStatus) &&
+		reflect.DeepEqual(current.Status.NetworkInterfaceStatus, status.NetworkInterfaceStatus) &&
+		current.Status.State == status.State &&
+		current.Status.FailureReason == status.FailureReason &&
+		current.Status.FailureMessage == status.FailureMessage {
+		return
+	}
+
+	setStatus := func(latest *api.Machine) error {
+		latest.Status.Conditions = status.Conditions
+		latest.Status.VolumeStatus = status.VolumeStatus
+		latest.Status.NetworkInterfaceStatus = status.NetworkInterfaceStatus
+		latest.Status.State = status.State
+		latest.Status.FailureReason = status.FailureReason
+		latest.Status.FailureMessage = status.FailureMessage
+		return nil
+	}
+	_ = setStatus(current)
+	updated, err := store.RetryOnConflict(ctx, r.machines, current, setStatus)
+	if err != nil {
+		if store.IgnoreErrNotFound(err) != nil {
+			log.Error(err, "failed to update failed machine status")
+		}
+		return
+	}
+	r.failedStatusVersions.Store(updated.ID, updated.ResourceVersion)
+}
@@ -0,0 +1,99 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"github.com/ironcore-dev/libvirt-provider/api"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"libvirt.org/go/libvirtxml"
+)
+
+var _ = Describe("Machine conditions", func() {
+	condition := func(machine *api.Machine, conditionType api.MachineConditionType) *api.MachineCondition {
+		return api.GetMachineCondition(machine.Status.Conditions, conditionType)
+	}
+
+	It("should report the volumes attached once all volumes are attached", func() {
+		machine := &api.Machine{}
+		setVolumesAttachedCondition(machine, []api.VolumeStatus{
+			{Name: "root", State: api.VolumeStateAttached},
+			{Name: "data", State: api.VolumeStatePending},
+		})
+		Expect(condition(machine, api.MachineConditionVolumesAttached)).To(SatisfyAll(
+			HaveField("Status", api.ConditionFalse),
+			HaveField("Reason", conditionReasonPending),
+			HaveField("Message", "Volume data is Pending"),
+		))
+		transitioned := condition(machine, api.MachineConditionVolumesAttached).LastTransitionTime
+
+		setVolumesAttachedCondition(machine, []api.VolumeStatus{
+			{Name: "root", State: api.VolumeStateAttached},
+			{Name: "data", State: api.VolumeStateAttached},
+		})
+		Expect(machine.Status.Conditions).To(HaveLen(1))
+		Expect(condition(machine, api.MachineConditionVolumesAttached)).To(SatisfyAll(
+			HaveField("Status", api.ConditionTrue),
+			HaveField("Reason", conditionReasonReady),
+			HaveField("Message", ""),
+			HaveField("LastTransitionTime", Not(BeTemporally("<", transitioned))),
+		))
+	})
+
+	It("should report the network not ready while a network interface isn't attached", func() {
+		machine := &api.Machine{}
+		setNetworkReadyCondition(machine, []api.NetworkInterfaceStatus{
+			{Name: "primary", State: api.NetworkInterfaceStatePending},
+		})
+		Expect(condition(machine, api.MachineConditionNetworkReady)).To(SatisfyAll(
+			HaveField("Status", api.ConditionFalse),
+			HaveField("Message", "Network interface primary is Pending"),
+		))
+
+		setNetworkReadyCondition(machine, nil)
+		Expect(condition(machine, api.MachineConditionNetworkReady)).To(HaveField("Status", api.ConditionTrue))
+	})
+
+	It("should report the connection of the guest agent", func() {
+		domainDesc := func(state string) *libvirtxml.Domain {
+			return &libvirtxml.Domain{Devices: &libvirtxml.DomainDeviceList{
+				Channels: []libvirtxml.DomainChannel{{
+					Target: &libvirtxml.DomainChannelTarget{
+						VirtIO: &libvirtxml.DomainChannelTargetVirtIO{Name: guestAgentChannelName, State: state},
+					},
+				}},
+			}}
+		}
+
+		machine := &api.Machine{Spec: api.MachineSpec{GuestAgent: api.GuestAgentQemu}}
+		setGuestAgentConnectedCondition(machine, domainDesc("disconnected"))
+		Expect(condition(machine, api.MachineConditionGuestAgentConnected)).To(SatisfyAll(
+			HaveField("Status", api.ConditionFalse),
+			HaveField("Reason", conditionReasonDisconnect),
+		))
+
+		setGuestAgentConnectedCondition(machine, domainDesc(guestAgentStateConnected))
+		Expect(condition(machine, api.MachineConditionGuestAgentConnected)).To(SatisfyAll(
+			HaveField("Status", api.ConditionTrue),
+			HaveField("Reason", conditionReasonConnected),
+		))
+
+		By("disabling the guest agent")
+		machine.Spec.GuestAgent = ""
+		setGuestAgentConnectedCondition(machine, domainDesc(guestAgentStateConnected))
+		Expect(machine.Status.Conditions).To(BeEmpty())
+	})
+
+	It("should compare conditions regardless of their transition time", func() {
+		a := &api.Machine{}
+		setCondition(a, api.MachineConditionDomainSynced, true, conditionReasonCreated, "")
+		b := &api.Machine{}
+		setCondition(b, api.MachineConditionDomainSynced, true, conditionReasonCreated, "")
+		b.Status.Conditions[0].LastTransitionTime = b.Status.Conditions[0].LastTransitionTime.Add(1)
+		Expect(conditionsEqual(a.Status.Conditions, b.Status.Conditions)).To(BeTrue())
+
+		setCondition(b, api.MachineConditionDomainSynced, true, conditionReasonUpdated, "")
+		Expect(conditionsEqual(a.Status.Conditions, b.Status.Conditions)).To(BeFalse())
+	})
+})
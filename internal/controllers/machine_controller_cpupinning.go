@@ -0,0 +1,257 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"k8s.io/utils/cpuset"
+	"k8s.io/utils/ptr"
+	"libvirt.org/go/libvirtxml"
+)
+
+// CPUPinningOptions configure the pinning of the non-vCPU threads of machines to reserved host cpus.
+type CPUPinningOptions struct {
+	// EmulatorCPUSet are the host cpus the emulator threads are pinned to, e.g. 0-1. Empty disables the pinning.
+	EmulatorCPUSet string
+	// IOThreads is the number of iothreads of each machine, shared by its virtio disks. 0 disables iothreads.
+	IOThreads uint
+	// IOThreadCPUSet are the host cpus the iothreads are pinned to. Empty disables the pinning.
+	IOThreadCPUSet string
+	// DedicatedIOThreads is the maximum number of iothreads of each machine dedicated to a single virtio volume.
+	// Volumes exceeding it share the IOThreads. 0 disables dedicated iothreads.
+	DedicatedIOThreads uint
+}
+
+// normalize validates the cpu sets and converts them to their canonical form.
+func (o *CPUPinningOptions) normalize() error {
+	for name, set := range map[string]*string{"emulator": &o.EmulatorCPUSet, "iothread": &o.IOThreadCPUSet} {
+		if *set == "" {
+			continue
+		}
+
+		cpus, err := cpuset.Parse(*set)
+		if err != nil {
+			return fmt.Errorf("invalid %s cpu set %q: %w", name, *set, err)
+		}
+		if cpus.IsEmpty() {
+			return fmt.Errorf("%s cpu set %q is empty", name, *set)
+		}
+		*set = cpus.String()
+	}
+
+	if o.IOThreadCPUSet != "" && o.IOThreads == 0 && o.DedicatedIOThreads == 0 {
+		return fmt.Errorf("iothread cpu set requires iothreads")
+	}
+	return nil
+}
+
+func (r *MachineReconciler) setDomainCPUPinning(domain *libvirtxml.Domain) {
+	if r.cpuPinning.EmulatorCPUSet == "" && r.cpuPinning.IOThreads == 0 {
+		return
+	}
+
+	if domain.CPUTune == nil {
+		domain.CPUTune = &libvirtxml.DomainCPUTune{}
+	}
+
+	if r.cpuPinning.EmulatorCPUSet != "" {
+		domain.CPUTune.EmulatorPin = &libvirtxml.DomainCPUTuneEmulatorPin{
+			CPUSet: r.cpuPinning.EmulatorCPUSet,
+		}
+	}
+
+	domain.IOThreads = r.cpuPinning.IOThreads
+	if r.cpuPinning.DedicatedIOThreads > 0 && r.cpuPinning.IOThreads > 0 {
+		// Dedicated iothreads are numbered after the shared ones, which therefore have to be listed explicitly.
+		domain.IOThreadIDs = &libvirtxml.DomainIOThreadIDs{}
+		for id := uint(1); id <= r.cpuPinning.IOThreads; id++ {
+			domain.IOThreadIDs.IOThreads = append(domain.IOThreadIDs.IOThreads, libvirtxml.DomainIOThread{ID: id})
+		}
+	}
+	if r.cpuPinning.IOThreadCPUSet != "" {
+		for id := uint(1); id <= r.cpuPinning.IOThreads; id++ {
+			domain.CPUTune.IOThreadPin = append(domain.CPUTune.IOThreadPin, libvirtxml.DomainCPUTuneIOThreadPin{
+				IOThread: id,
+				CPUSet:   r.cpuPinning.IOThreadCPUSet,
+			})
+		}
+	}
+}
+
+// placementStatus returns the host cpus and NUMA nodes the domain is pinned to, nil if the domain isn't pinned.
+func placementStatus(domain *libvirtxml.Domain) *api.PlacementStatus {
+	placement := &api.PlacementStatus{}
+	if domain.VCPU != nil {
+		placement.VCPUCPUSet = domain.VCPU.CPUSet
+	}
+	if tune := domain.CPUTune; tune != nil {
+		var vcpuSets, ioThreadSets []string
+		for _, pin := range tune.VCPUPin {
+			vcpuSets = append(vcpuSets, pin.CPUSet)
+		}
+		for _, pin := range tune.IOThreadPin {
+			ioThreadSets = append(ioThreadSets, pin.CPUSet)
+		}
+		if len(vcpuSets) > 0 {
+			placement.VCPUCPUSet = unionCPUSets(vcpuSets)
+		}
+		placement.IOThreadCPUSet = unionCPUSets(ioThreadSets)
+		if tune.EmulatorPin != nil {
+			placement.EmulatorCPUSet = tune.EmulatorPin.CPUSet
+		}
+	}
+	if domain.NUMATune != nil && domain.NUMATune.Memory != nil {
+		placement.NUMANodes = domain.NUMATune.Memory.Nodeset
+		if domain.MemoryBacking != nil && domain.MemoryBacking.MemoryHugePages != nil {
+			placement.HugepagesNUMANodes = placement.NUMANodes
+		}
+	}
+
+	if *placement == (api.PlacementStatus{}) {
+		return nil
+	}
+	return placement
+}
+
+// unionCPUSets returns the canonical form of the union of the cpu sets. Sets that can't be parsed are kept as
+// they are.
+func unionCPUSets(sets []string) string {
+	union := cpuset.New()
+	for _, set := range sets {
+		cpus, err := cpuset.Parse(set)
+		if err != nil {
+			return strings.Join(sets, ",")
+		}
+		union = union.Union(cpus)
+	}
+	return union.String()
+}
+
+func diskIOThread(disk *libvirtxml.DomainDisk) (uint, bool) {
+	if disk.Driver == nil || disk.Driver.IOThread == nil {
+		return 0, false
+	}
+	return *disk.Driver.IOThread, true
+}
+
+func setDiskIOThread(disk *libvirtxml.DomainDisk, id uint) {
+	if disk.Driver == nil {
+		disk.Driver = &libvirtxml.DomainDiskDriver{}
+	}
+	disk.Driver.IOThread = ptr.To(id)
+}
+
+// assignDiskIOThread assigns a virtio disk to one of the shared iothreads of the domain. The disks are distributed
+// round-robin over the shared iothreads in the order they are attached.
+func assignDiskIOThread(domain *libvirtxml.Domain, disk *libvirtxml.DomainDisk, sharedIOThreads uint) {
+	if sharedIOThreads == 0 || disk.Target == nil || disk.Target.Bus != "virtio" {
+		return
+	}
+
+	var assigned uint
+	if domain.Devices != nil {
+		for _, existing := range domain.Devices.Disks {
+			if id, ok := diskIOThread(&existing); ok && id <= sharedIOThreads {
+				assigned++
+			}
+		}
+	}
+
+	setDiskIOThread(disk, assigned%sharedIOThreads+1)
+}
+
+// isDedicatedIOThread reports whether the iothread id lies in the range of dedicated iothreads, which are
+// numbered after the shared ones.
+func (o *CPUPinningOptions) isDedicatedIOThread(id uint) bool {
+	return id > o.IOThreads && id <= o.IOThreads+o.DedicatedIOThreads
+}
+
+// freeDedicatedIOThread returns the lowest dedicated iothread id not used by any disk of the domain. Ids of
+// detached disks are reused this way, keeping the iothread ids of a machine dense across hotplug and unplug.
+func (o *CPUPinningOptions) freeDedicatedIOThread(domain *libvirtxml.Domain) (uint, bool) {
+	used := make(map[uint]bool)
+	if domain.Devices != nil {
+		for _, disk := range domain.Devices.Disks {
+			if id, ok := diskIOThread(&disk); ok {
+				used[id] = true
+			}
+		}
+	}
+
+	for id := o.IOThreads + 1; id <= o.IOThreads+o.DedicatedIOThreads; id++ {
+		if !used[id] {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+func domainHasIOThread(domain *libvirtxml.Domain, id uint) bool {
+	if domain.IOThreadIDs == nil {
+		return id <= domain.IOThreads
+	}
+	return slices.ContainsFunc(domain.IOThreadIDs.IOThreads, func(ioThread libvirtxml.DomainIOThread) bool {
+		return ioThread.ID == id
+	})
+}
+
+// addDomainIOThread adds the iothread to the description of the domain, pinned to the given cpu set if not empty.
+func addDomainIOThread(domain *libvirtxml.Domain, id uint, cpuSet string) {
+	if domain.IOThreadIDs == nil {
+		domain.IOThreadIDs = &libvirtxml.DomainIOThreadIDs{}
+		for existing := uint(1); existing <= domain.IOThreads; existing++ {
+			domain.IOThreadIDs.IOThreads = append(domain.IOThreadIDs.IOThreads, libvirtxml.DomainIOThread{ID: existing})
+		}
+	}
+	domain.IOThreadIDs.IOThreads = append(domain.IOThreadIDs.IOThreads, libvirtxml.DomainIOThread{ID: id})
+	domain.IOThreads = uint(len(domain.IOThreadIDs.IOThreads))
+
+	if cpuSet == "" {
+		return
+	}
+	if domain.CPUTune == nil {
+		domain.CPUTune = &libvirtxml.DomainCPUTune{}
+	}
+	domain.CPUTune.IOThreadPin = append(domain.CPUTune.IOThreadPin, libvirtxml.DomainCPUTuneIOThreadPin{
+		IOThread: id,
+		CPUSet:   cpuSet,
+	})
+}
+
+// removeDomainIOThread removes the iothread and its pinning from the description of the domain.
+func removeDomainIOThread(domain *libvirtxml.Domain, id uint) {
+	if domain.IOThreadIDs != nil {
+		domain.IOThreadIDs.IOThreads = slices.DeleteFunc(domain.IOThreadIDs.IOThreads, func(ioThread libvirtxml.DomainIOThread) bool {
+			return ioThread.ID == id
+		})
+		domain.IOThreads = uint(len(domain.IOThreadIDs.IOThreads))
+	}
+	if domain.CPUTune != nil {
+		domain.CPUTune.IOThreadPin = slices.DeleteFunc(domain.CPUTune.IOThreadPin, func(pin libvirtxml.DomainCPUTuneIOThreadPin) bool {
+			return pin.IOThread == id
+		})
+	}
+}
+
+// cpuMap converts a cpu set into the cpu bitmap used by the libvirt pinning calls.
+func cpuMap(set string) ([]byte, error) {
+	cpus, err := cpuset.Parse(set)
+	if err != nil {
+		return nil, err
+	}
+
+	cpuList := cpus.List()
+	if len(cpuList) == 0 {
+		return nil, nil
+	}
+	res := make([]byte, cpuList[len(cpuList)-1]/8+1)
+	for _, cpu := range cpuList {
+		res[cpu/8] |= 1 << (cpu % 8)
+	}
+	return res, nil
+}
@@ -0,0 +1,237 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/pci"
+	providervolume "github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"libvirt.org/go/libvirtxml"
+)
+
+// fakeIOThreadExecutor attaches disks to the domain description only and records the iothreads added and deleted.
+type fakeIOThreadExecutor struct {
+	DomainExecutor
+	added, deleted []uint
+}
+
+func (e *fakeIOThreadExecutor) AttachDisk(*libvirtxml.DomainDisk) error { return nil }
+func (e *fakeIOThreadExecutor) DetachDisk(*libvirtxml.DomainDisk) error { return nil }
+func (e *fakeIOThreadExecutor) DeleteSecret(string) error               { return nil }
+
+func (e *fakeIOThreadExecutor) AddIOThread(id uint, _ string) error {
+	e.added = append(e.added, id)
+	return nil
+}
+
+func (e *fakeIOThreadExecutor) DeleteIOThread(id uint) error {
+	e.deleted = append(e.deleted, id)
+	return nil
+}
+
+func virtioDisk() *libvirtxml.DomainDisk {
+	return &libvirtxml.DomainDisk{Target: &libvirtxml.DomainDiskTarget{Bus: "virtio"}}
+}
+
+var _ = Describe("Machine cpu pinning", func() {
+	Describe("CPUPinningOptions", func() {
+		It("should normalize the cpu sets", func() {
+			opts := CPUPinningOptions{EmulatorCPUSet: "1,0", IOThreads: 1, IOThreadCPUSet: "2,3,4"}
+			Expect(opts.normalize()).To(Succeed())
+			Expect(opts.EmulatorCPUSet).To(Equal("0-1"))
+			Expect(opts.IOThreadCPUSet).To(Equal("2-4"))
+		})
+
+		It("should reject invalid cpu sets", func() {
+			opts := CPUPinningOptions{EmulatorCPUSet: "a-b"}
+			Expect(opts.normalize()).To(MatchError(ContainSubstring("invalid emulator cpu set")))
+		})
+
+		It("should reject an iothread cpu set without iothreads", func() {
+			opts := CPUPinningOptions{IOThreadCPUSet: "2-3"}
+			Expect(opts.normalize()).To(MatchError(ContainSubstring("requires iothreads")))
+		})
+	})
+
+	Describe("setDomainCPUPinning", func() {
+		It("should leave the domain unpinned without pinning options", func() {
+			r := &MachineReconciler{}
+			domain := &libvirtxml.Domain{}
+			r.setDomainCPUPinning(domain)
+			Expect(domain.CPUTune).To(BeNil())
+			Expect(placementStatus(domain)).To(BeNil())
+		})
+
+		It("should pin the emulator and the shared iothreads", func() {
+			r := &MachineReconciler{cpuPinning: CPUPinningOptions{EmulatorCPUSet: "0-1", IOThreads: 2, IOThreadCPUSet: "2-3"}}
+			domain := &libvirtxml.Domain{}
+			r.setDomainCPUPinning(domain)
+
+			Expect(domain.IOThreads).To(Equal(uint(2)))
+			Expect(domain.IOThreadIDs).To(BeNil())
+			Expect(domain.CPUTune).To(Equal(&libvirtxml.DomainCPUTune{
+				EmulatorPin: &libvirtxml.DomainCPUTuneEmulatorPin{CPUSet: "0-1"},
+				IOThreadPin: []libvirtxml.DomainCPUTuneIOThreadPin{
+					{IOThread: 1, CPUSet: "2-3"},
+					{IOThread: 2, CPUSet: "2-3"},
+				},
+			}))
+			Expect(placementStatus(domain)).To(Equal(&api.PlacementStatus{EmulatorCPUSet: "0-1", IOThreadCPUSet: "2-3"}))
+		})
+
+		It("should list the shared iothreads if iothreads are dedicated to volumes", func() {
+			r := &MachineReconciler{cpuPinning: CPUPinningOptions{IOThreads: 2, DedicatedIOThreads: 1}}
+			domain := &libvirtxml.Domain{}
+			r.setDomainCPUPinning(domain)
+			Expect(domain.IOThreadIDs.IOThreads).To(Equal([]libvirtxml.DomainIOThread{{ID: 1}, {ID: 2}}))
+		})
+	})
+
+	Describe("placementStatus", func() {
+		It("should report the union of the vcpu and iothread pinnings", func() {
+			domain := &libvirtxml.Domain{
+				CPUTune: &libvirtxml.DomainCPUTune{
+					VCPUPin: []libvirtxml.DomainCPUTuneVCPUPin{
+						{VCPU: 0, CPUSet: "4"},
+						{VCPU: 1, CPUSet: "5,6"},
+					},
+					IOThreadPin: []libvirtxml.DomainCPUTuneIOThreadPin{
+						{IOThread: 1, CPUSet: "2"},
+						{IOThread: 2, CPUSet: "3"},
+					},
+				},
+				NUMATune: &libvirtxml.DomainNUMATune{Memory: &libvirtxml.DomainNUMATuneMemory{Nodeset: "0"}},
+			}
+			Expect(placementStatus(domain)).To(Equal(&api.PlacementStatus{
+				VCPUCPUSet:     "4-6",
+				IOThreadCPUSet: "2-3",
+				NUMANodes:      "0",
+			}))
+		})
+
+		It("should keep cpu sets it can't parse", func() {
+			Expect(unionCPUSets([]string{"0-1", "^2"})).To(Equal("0-1,^2"))
+		})
+	})
+
+	It("should convert cpu sets into cpu bitmaps", func() {
+		Expect(cpuMap("0,2,9")).To(Equal([]byte{0b101, 0b10}))
+		Expect(cpuMap("")).To(BeNil())
+		_, err := cpuMap("x")
+		Expect(err).To(HaveOccurred())
+	})
+
+	Describe("iothreads", func() {
+		opts := CPUPinningOptions{IOThreads: 2, DedicatedIOThreads: 2}
+
+		It("should distribute virtio disks round-robin over the shared iothreads", func() {
+			domain := &libvirtxml.Domain{Devices: &libvirtxml.DomainDeviceList{}}
+			var ids []uint
+			for range 3 {
+				disk := virtioDisk()
+				assignDiskIOThread(domain, disk, 2)
+				id, ok := diskIOThread(disk)
+				Expect(ok).To(BeTrue())
+				ids = append(ids, id)
+				domain.Devices.Disks = append(domain.Devices.Disks, *disk)
+			}
+			Expect(ids).To(Equal([]uint{1, 2, 1}))
+
+			By("leaving other disks without iothread")
+			disk := &libvirtxml.DomainDisk{Target: &libvirtxml.DomainDiskTarget{Bus: "scsi"}}
+			assignDiskIOThread(domain, disk, 2)
+			_, ok := diskIOThread(disk)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("should number the dedicated iothreads after the shared ones", func() {
+			Expect(opts.isDedicatedIOThread(2)).To(BeFalse())
+			Expect(opts.isDedicatedIOThread(3)).To(BeTrue())
+			Expect(opts.isDedicatedIOThread(4)).To(BeTrue())
+			Expect(opts.isDedicatedIOThread(5)).To(BeFalse())
+		})
+
+		It("should reuse the lowest free dedicated iothread", func() {
+			domain := &libvirtxml.Domain{Devices: &libvirtxml.DomainDeviceList{}}
+			freeIOThread := func() uint {
+				id, ok := opts.freeDedicatedIOThread(domain)
+				Expect(ok).To(BeTrue())
+				return id
+			}
+			Expect(freeIOThread()).To(Equal(uint(3)))
+
+			disk := virtioDisk()
+			setDiskIOThread(disk, 4)
+			domain.Devices.Disks = append(domain.Devices.Disks, *disk)
+			Expect(freeIOThread()).To(Equal(uint(3)))
+
+			disk = virtioDisk()
+			setDiskIOThread(disk, 3)
+			domain.Devices.Disks = append(domain.Devices.Disks, *disk)
+			_, ok := opts.freeDedicatedIOThread(domain)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("should add and remove iothreads with their pinning", func() {
+			domain := &libvirtxml.Domain{IOThreads: 2}
+			Expect(domainHasIOThread(domain, 2)).To(BeTrue())
+			Expect(domainHasIOThread(domain, 3)).To(BeFalse())
+
+			addDomainIOThread(domain, 3, "4-5")
+			Expect(domain.IOThreads).To(Equal(uint(3)))
+			Expect(domain.IOThreadIDs.IOThreads).To(Equal([]libvirtxml.DomainIOThread{{ID: 1}, {ID: 2}, {ID: 3}}))
+			Expect(domain.CPUTune.IOThreadPin).To(Equal([]libvirtxml.DomainCPUTuneIOThreadPin{{IOThread: 3, CPUSet: "4-5"}}))
+			Expect(domainHasIOThread(domain, 3)).To(BeTrue())
+
+			removeDomainIOThread(domain, 3)
+			Expect(domain.IOThreads).To(Equal(uint(2)))
+			Expect(domain.IOThreadIDs.IOThreads).To(Equal([]libvirtxml.DomainIOThread{{ID: 1}, {ID: 2}}))
+			Expect(domain.CPUTune.IOThreadPin).To(BeEmpty())
+		})
+
+		It("should dedicate iothreads to attached volumes until they are used up", func() {
+			domain := &libvirtxml.Domain{UUID: "2a4a1d5e-4b43-4c09-a2ff-c8f5c3bb5b1a", IOThreads: 1}
+			topology, err := pci.ForDomain(domain)
+			Expect(err).NotTo(HaveOccurred())
+			for range 3 {
+				topology.AddRootPort()
+			}
+
+			executor := &fakeIOThreadExecutor{}
+			attacher, err := NewLibvirtVolumeAttacher(domain, executor, CPUPinningOptions{IOThreads: 1, DedicatedIOThreads: 1}, 0)
+			Expect(err).NotTo(HaveOccurred())
+
+			volumeIOThread := func(name string) uint {
+				disk, err := attacher.(*libvirtVolumeAttacher).attachedDisk(name)
+				Expect(err).NotTo(HaveOccurred())
+				id, ok := diskIOThread(disk)
+				Expect(ok).To(BeTrue())
+				return id
+			}
+
+			for _, volume := range []struct{ name, device string }{{"a", "oda"}, {"b", "odb"}} {
+				Expect(attacher.AttachVolume(&AttachVolume{
+					Name:   volume.name,
+					Device: volume.device,
+					Spec:   providervolume.Volume{RawFile: "/disks/" + volume.name, Handle: volume.name},
+				})).To(Succeed())
+			}
+			Expect(volumeIOThread("a")).To(Equal(uint(2)))
+			Expect(volumeIOThread("b")).To(Equal(uint(1)))
+			Expect(executor.added).To(Equal([]uint{2}))
+
+			By("deleting the dedicated iothread on detach")
+			Expect(attacher.DetachVolume("a")).To(Succeed())
+			Expect(executor.deleted).To(Equal([]uint{2}))
+			Expect(domainHasIOThread(domain, 2)).To(BeFalse())
+
+			By("keeping the shared iothreads on detach")
+			Expect(attacher.DetachVolume("b")).To(Succeed())
+			Expect(executor.deleted).To(Equal([]uint{2}))
+			Expect(domain.IOThreads).To(Equal(uint(1)))
+		})
+	})
+})
@@ -0,0 +1,131 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/go-logr/logr"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	providervolume "github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	deviceKindVolume           = "volume"
+	deviceKindNetworkInterface = "network interface"
+)
+
+// attachDetachError is the error of attaching and detaching the volumes or network interfaces of a machine. It
+// keeps the error of each failed device, so the devices can be reported as failed in the machine status.
+type attachDetachError struct {
+	kind string
+	errs []error
+	// failed are the errors of the failed devices by device name.
+	failed map[string]error
+}
+
+func newAttachDetachError(kind string) *attachDetachError {
+	return &attachDetachError{
+		kind:   kind,
+		failed: make(map[string]error),
+	}
+}
+
+// add records the error of the given action on the device with the given name.
+func (e *attachDetachError) add(name, action string, err error) {
+	e.errs = append(e.errs, fmt.Errorf("[%s %s] error %s: %w", e.kind, name, action, err))
+	e.failed[name] = fmt.Errorf("error %s: %w", action, err)
+}
+
+func (e *attachDetachError) len() int {
+	return len(e.errs)
+}
+
+func (e *attachDetachError) Error() string {
+	return fmt.Sprintf("attach/detach error(s): %v", e.errs)
+}
+
+func (e *attachDetachError) Unwrap() []error {
+	return e.errs
+}
+
+// volumePluginsUnhealthy reports whether err is caused by unhealthy volume plugins only. If other devices failed as
+// well, err is handled as failure, so their failures aren't hidden while a plugin is unhealthy.
+func volumePluginsUnhealthy(err error) bool {
+	var attachDetachErr *attachDetachError
+	if !errors.As(err, &attachDetachErr) {
+		return errors.Is(err, providervolume.ErrPluginUnhealthy)
+	}
+	for _, err := range attachDetachErr.errs {
+		if !errors.Is(err, providervolume.ErrPluginUnhealthy) {
+			return false
+		}
+	}
+	return true
+}
+
+// setFailed marks the failed devices as failed in the status of the machine. Devices failing again count
+// another failed attempt, the attempts of the other devices are kept.
+func (r *MachineReconciler) setFailed(log logr.Logger, machine *api.Machine, e *attachDetachError) {
+	names := make([]string, 0, len(e.failed))
+	for name := range e.failed {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		message := e.failed[name].Error()
+		var changed bool
+		switch e.kind {
+		case deviceKindVolume:
+			changed = setVolumeFailed(machine, name, message)
+		case deviceKindNetworkInterface:
+			changed = setNetworkInterfaceFailed(machine, name, message)
+		}
+		if changed {
+			r.Eventf(log, machine.Metadata, corev1.EventTypeWarning, "DeviceFailed", "The %s %s failed: %s", e.kind, name, message)
+		}
+	}
+}
+
+// setVolumeFailed marks the volume as failed and reports whether its failure changed.
+func setVolumeFailed(machine *api.Machine, name, message string) bool {
+	idx := slices.IndexFunc(machine.Status.VolumeStatus, func(status api.VolumeStatus) bool { return status.Name == name })
+	if idx < 0 {
+		machine.Status.VolumeStatus = append(machine.Status.VolumeStatus, api.VolumeStatus{Name: name})
+		idx = len(machine.Status.VolumeStatus) - 1
+	}
+
+	status := &machine.Status.VolumeStatus[idx]
+	changed := status.State != api.VolumeStateFailed || status.Message != message
+	if status.State != api.VolumeStateFailed {
+		status.FailedAttempts = 0
+	}
+	status.State = api.VolumeStateFailed
+	status.Message = message
+	status.FailedAttempts++
+	return changed
+}
+
+// setNetworkInterfaceFailed marks the network interface as failed and reports whether its failure changed.
+func setNetworkInterfaceFailed(machine *api.Machine, name, message string) bool {
+	idx := slices.IndexFunc(machine.Status.NetworkInterfaceStatus, func(status api.NetworkInterfaceStatus) bool { return status.Name == name })
+	if idx < 0 {
+		machine.Status.NetworkInterfaceStatus = append(machine.Status.NetworkInterfaceStatus, api.NetworkInterfaceStatus{Name: name})
+		idx = len(machine.Status.NetworkInterfaceStatus) - 1
+	}
+
+	status := &machine.Status.NetworkInterfaceStatus[idx]
+	changed := status.State != api.NetworkInterfaceStateFailed || status.Message != message
+	if status.State != api.NetworkInterfaceStateFailed {
+		status.FailedAttempts = 0
+	}
+	status.State = api.NetworkInterfaceStateFailed
+	status.Message = message
+	status.FailedAttempts++
+	return changed
+}
@@ -0,0 +1,92 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	providervolume "github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Machine device failures", func() {
+	Describe("setVolumeFailed", func() {
+		It("should add a failed status for volumes without status", func() {
+			machine := &api.Machine{}
+			Expect(setVolumeFailed(machine, "disk-1", "attach failed")).To(BeTrue())
+			Expect(machine.Status.VolumeStatus).To(Equal([]api.VolumeStatus{
+				{Name: "disk-1", State: api.VolumeStateFailed, Message: "attach failed", FailedAttempts: 1},
+			}))
+		})
+
+		It("should count consecutive failures and report changed messages only", func() {
+			machine := &api.Machine{Status: api.MachineStatus{VolumeStatus: []api.VolumeStatus{
+				{Name: "disk-1", Handle: "a", State: api.VolumeStateFailed, Message: "attach failed", FailedAttempts: 1},
+			}}}
+			Expect(setVolumeFailed(machine, "disk-1", "attach failed")).To(BeFalse())
+			Expect(setVolumeFailed(machine, "disk-1", "detach failed")).To(BeTrue())
+			Expect(machine.Status.VolumeStatus).To(Equal([]api.VolumeStatus{
+				{Name: "disk-1", Handle: "a", State: api.VolumeStateFailed, Message: "detach failed", FailedAttempts: 3},
+			}))
+		})
+
+		It("should restart counting failures of volumes that were attached meanwhile", func() {
+			machine := &api.Machine{Status: api.MachineStatus{VolumeStatus: []api.VolumeStatus{
+				{Name: "disk-1", State: api.VolumeStateAttached, FailedAttempts: 3},
+			}}}
+			Expect(setVolumeFailed(machine, "disk-1", "attach failed")).To(BeTrue())
+			Expect(machine.Status.VolumeStatus[0].FailedAttempts).To(Equal(1))
+		})
+	})
+
+	Describe("setNetworkInterfaceFailed", func() {
+		It("should add a failed status for network interfaces without status", func() {
+			machine := &api.Machine{}
+			Expect(setNetworkInterfaceFailed(machine, "nic-1", "attach failed")).To(BeTrue())
+			Expect(machine.Status.NetworkInterfaceStatus).To(Equal([]api.NetworkInterfaceStatus{
+				{Name: "nic-1", State: api.NetworkInterfaceStateFailed, Message: "attach failed", FailedAttempts: 1},
+			}))
+		})
+
+		It("should count consecutive failures and report changed messages only", func() {
+			machine := &api.Machine{Status: api.MachineStatus{NetworkInterfaceStatus: []api.NetworkInterfaceStatus{
+				{Name: "nic-1", Handle: "a", State: api.NetworkInterfaceStateFailed, Message: "attach failed", FailedAttempts: 1},
+			}}}
+			Expect(setNetworkInterfaceFailed(machine, "nic-1", "attach failed")).To(BeFalse())
+			Expect(setNetworkInterfaceFailed(machine, "nic-1", "detach failed")).To(BeTrue())
+			Expect(machine.Status.NetworkInterfaceStatus).To(Equal([]api.NetworkInterfaceStatus{
+				{Name: "nic-1", Handle: "a", State: api.NetworkInterfaceStateFailed, Message: "detach failed", FailedAttempts: 3},
+			}))
+		})
+
+		It("should restart counting failures of network interfaces that were attached meanwhile", func() {
+			machine := &api.Machine{Status: api.MachineStatus{NetworkInterfaceStatus: []api.NetworkInterfaceStatus{
+				{Name: "nic-1", State: api.NetworkInterfaceStateAttached, FailedAttempts: 3},
+			}}}
+			Expect(setNetworkInterfaceFailed(machine, "nic-1", "attach failed")).To(BeTrue())
+			Expect(machine.Status.NetworkInterfaceStatus[0].FailedAttempts).To(Equal(1))
+		})
+	})
+
+	Describe("volumePluginsUnhealthy", func() {
+		unhealthy := fmt.Errorf("%w: ceph: no ceph monitor reachable", providervolume.ErrPluginUnhealthy)
+
+		It("should report volumes held back by unhealthy plugins", func() {
+			err := newAttachDetachError(deviceKindVolume)
+			err.add("disk-1", "preparing", unhealthy)
+			err.add("disk-2", "preparing", unhealthy)
+			Expect(volumePluginsUnhealthy(fmt.Errorf("[volumes] %w", err))).To(BeTrue())
+		})
+
+		It("should not hide the failures of other volumes", func() {
+			err := newAttachDetachError(deviceKindVolume)
+			err.add("disk-1", "preparing", unhealthy)
+			err.add("disk-2", "reconciling", errors.New("attach failed"))
+			Expect(volumePluginsUnhealthy(fmt.Errorf("[volumes] %w", err))).To(BeFalse())
+		})
+	})
+})
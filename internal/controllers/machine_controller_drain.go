@@ -0,0 +1,130 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// drainPollInterval is the interval Drain checks whether the running work items finished.
+const drainPollInterval = 100 * time.Millisecond
+
+// queueState is the persisted state of the reconcile queue of a drained reconciler.
+type queueState struct {
+	// MachineIDs are the ids of the machines that were queued for reconciliation.
+	MachineIDs []string `json:"machineIDs"`
+}
+
+// Drain stops starting reconciles, garbage collections, volume resizes and volume migrations and waits for the
+// running ones to finish, until ctx is done. Machines queued for reconciliation in the meantime are persisted and
+// reconciled first after the next start. Skipped garbage collections, resizes and migrations are enqueued again by
+// their resyncs and the reconciles after the next start. The reconciler has to be stopped by cancelling the context
+// of Start afterwards, which interrupts the work that didn't finish.
+func (r *MachineReconciler) Drain(ctx context.Context) error {
+	log := r.log.WithName("drain")
+	r.draining.Store(true)
+
+	log.Info("Draining machine reconciles", "Running", r.inFlight.Load(), "Queued", r.queue.Len())
+	err := wait.PollUntilContextCancel(ctx, drainPollInterval, true, func(context.Context) (bool, error) {
+		return r.inFlight.Load() == 0 && r.queue.Len() == 0, nil
+	})
+	if err != nil {
+		err = fmt.Errorf("%d machine reconciles, garbage collections, resizes or migrations still running: %w", r.inFlight.Load(), err)
+	}
+
+	if persistErr := r.persistQueue(log); persistErr != nil {
+		return errors.Join(err, persistErr)
+	}
+	return err
+}
+
+// Draining reports whether the reconciler is draining.
+func (r *MachineReconciler) Draining() bool {
+	return r.draining.Load()
+}
+
+// startWork counts a work item of the garbage collection, resize or migration workers as running. It reports false
+// if the reconciler is draining, the item is skipped then. The returned func has to be called in both cases.
+func (r *MachineReconciler) startWork() (func(), bool) {
+	// Counting before checking for draining ensures Drain doesn't miss an item started concurrently.
+	r.inFlight.Add(1)
+	return func() { r.inFlight.Add(-1) }, !r.draining.Load()
+}
+
+// deferIfDraining keeps the machine to be persisted instead of reconciling it if the reconciler is draining.
+func (r *MachineReconciler) deferIfDraining(id string) bool {
+	if !r.draining.Load() {
+		return false
+	}
+
+	r.deferredMu.Lock()
+	defer r.deferredMu.Unlock()
+	r.deferred.Insert(id)
+	return true
+}
+
+func (r *MachineReconciler) persistQueue(log logr.Logger) error {
+	r.deferredMu.Lock()
+	ids := sets.List(r.deferred)
+	r.deferredMu.Unlock()
+
+	if r.host == nil || len(ids) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(queueState{MachineIDs: ids})
+	if err != nil {
+		return fmt.Errorf("error encoding reconcile queue: %w", err)
+	}
+
+	filename := r.host.ReconcileQueueFile()
+	tmpFilename := filename + ".tmp"
+	if err := os.WriteFile(tmpFilename, data, 0600); err != nil {
+		return fmt.Errorf("error writing reconcile queue: %w", err)
+	}
+	if err := os.Rename(tmpFilename, filename); err != nil {
+		return fmt.Errorf("error replacing reconcile queue: %w", err)
+	}
+	log.Info("Persisted reconcile queue", "Machines", len(ids))
+	return nil
+}
+
+// restoreQueue enqueues the machines persisted by the last drain and removes the persisted queue.
+func (r *MachineReconciler) restoreQueue(log logr.Logger) {
+	if r.host == nil {
+		return
+	}
+
+	filename := r.host.ReconcileQueueFile()
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Error(err, "failed to read persisted reconcile queue")
+		}
+		return
+	}
+
+	var state queueState
+	if err := json.Unmarshal(data, &state); err != nil {
+		// All machines are reconciled after a start anyway, the persisted queue only brings them forward.
+		log.Error(err, "failed to decode persisted reconcile queue, ignoring it")
+	}
+	for _, id := range state.MachineIDs {
+		r.queue.Add(id)
+	}
+	log.Info("Restored persisted reconcile queue", "Machines", len(state.MachineIDs))
+
+	if err := os.Remove(filename); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Error(err, "failed to remove persisted reconcile queue")
+	}
+}
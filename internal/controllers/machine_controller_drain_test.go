@@ -0,0 +1,72 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/workqueue"
+)
+
+var _ = Describe("Machine reconciler drain", func() {
+	var r *MachineReconciler
+
+	BeforeEach(func() {
+		r = &MachineReconciler{
+			log:            GinkgoLogr,
+			deferred:       sets.New[string](),
+			queue:          workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[string]()),
+			gcQueue:        workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[string]()),
+			resizeQueue:    workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[resizeRequest]()),
+			migrationQueue: workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[string]()),
+		}
+		DeferCleanup(r.queue.ShutDown)
+		DeferCleanup(r.gcQueue.ShutDown)
+		DeferCleanup(r.resizeQueue.ShutDown)
+		DeferCleanup(r.migrationQueue.ShutDown)
+	})
+
+	It("should wait for running garbage collections, resizes and migrations", func(ctx SpecContext) {
+		done, ok := r.startWork()
+		Expect(ok).To(BeTrue())
+
+		drained := make(chan error, 1)
+		go func() {
+			drained <- r.Drain(ctx)
+		}()
+		Consistently(drained, 3*drainPollInterval).ShouldNot(Receive())
+
+		done()
+		Eventually(drained).Should(Receive(Not(HaveOccurred())))
+	})
+
+	It("should fail if the running work doesn't finish in time", func(ctx SpecContext) {
+		done, _ := r.startWork()
+		defer done()
+
+		drainCtx, cancel := context.WithTimeout(ctx, 3*drainPollInterval)
+		defer cancel()
+		Expect(r.Drain(drainCtx)).To(MatchError(ContainSubstring("1 machine reconciles")))
+	})
+
+	It("should skip garbage collections, resizes and migrations while draining", func(ctx SpecContext) {
+		Expect(r.Drain(ctx)).To(Succeed())
+
+		// The machine store isn't set, processing any of the items would fail.
+		r.gcQueue.Add("foo")
+		Expect(r.processNextGCItem(ctx, GinkgoLogr)).To(BeTrue())
+		r.resizeQueue.Add(resizeRequest{machineID: "foo", volumeName: "a"})
+		Expect(r.processNextResizeItem(ctx, GinkgoLogr)).To(BeTrue())
+		r.migrationQueue.Add("foo")
+		Expect(r.processNextMigrationItem(ctx, GinkgoLogr)).To(BeTrue())
+
+		Expect(r.gcQueue.Len()).To(BeZero())
+		Expect(r.resizeQueue.Len()).To(BeZero())
+		Expect(r.migrationQueue.Len()).To(BeZero())
+		Expect(r.inFlight.Load()).To(BeZero())
+	})
+})
@@ -0,0 +1,90 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/digitalocean/go-libvirt"
+	"github.com/go-logr/logr"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/journal"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/drift"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/hotplug"
+	corev1 "k8s.io/api/core/v1"
+	"libvirt.org/go/libvirtxml"
+)
+
+const conditionReasonDrifted = "Drifted"
+
+// reconcileDrift detects changes made to the running domain outside the provider and reports or reverts them
+// according to the drift policy. Drift doesn't fail the reconcile, it is reported via the DomainDrifted condition.
+func (r *MachineReconciler) reconcileDrift(ctx context.Context, log logr.Logger, machine *api.Machine, domainDesc *libvirtxml.Domain) {
+	if r.driftPolicy == drift.PolicyIgnore {
+		api.RemoveMachineCondition(&machine.Status.Conditions, api.MachineConditionDomainDrifted)
+		return
+	}
+
+	drifts := drift.Detect(domainDesc, drift.Resources{
+		VCPUs:       uint(machine.Spec.CpuMillis / 1000),
+		MemoryBytes: uint64(machine.Spec.MemoryBytes),
+	})
+	if len(drifts) > 0 && r.driftPolicy == drift.PolicyRevert {
+		drifts = r.revertDrift(ctx, log, machine, drifts)
+	}
+	if len(drifts) == 0 {
+		api.RemoveMachineCondition(&machine.Status.Conditions, api.MachineConditionDomainDrifted)
+		return
+	}
+
+	message := drift.Summary(drifts)
+	if condition := api.GetMachineCondition(machine.Status.Conditions, api.MachineConditionDomainDrifted); condition == nil || condition.Message != message {
+		r.Eventf(log, machine.Metadata, corev1.EventTypeWarning, "DomainDrifted", "Domain was changed outside the provider: %s", message)
+	}
+	setCondition(machine, api.MachineConditionDomainDrifted, true, conditionReasonDrifted, message)
+}
+
+// revertDrift reverts the drifts on the running domain and returns the drifts that are not reverted yet.
+func (r *MachineReconciler) revertDrift(ctx context.Context, log logr.Logger, machine *api.Machine, drifts []drift.Drift) []drift.Drift {
+	var remaining []drift.Drift
+	for _, d := range drifts {
+		log.V(1).Info("Reverting domain drift", "Drift", d.String())
+		if err := r.revertDriftItem(ctx, log, machine, d); err != nil {
+			if !errors.Is(err, hotplug.ErrPending) {
+				log.Error(err, "failed to revert domain drift", "Drift", d.String())
+			}
+			remaining = append(remaining, d)
+			continue
+		}
+		r.Eventf(log, machine.Metadata, corev1.EventTypeNormal, "RevertedDomainDrift", "Reverted %s", d)
+	}
+	return remaining
+}
+
+func (r *MachineReconciler) revertDriftItem(ctx context.Context, log logr.Logger, machine *api.Machine, d drift.Drift) error {
+	domain := machineDomain(machine.ID)
+	switch d.Kind {
+	case drift.KindDevice:
+		conn, abort := r.mutatingConn()
+		err := detachDevice(ctx, conn, abort, r.libvirtCallTimeout, r.hotplug, machine.ID, d.Alias, d.Device)
+		if !errors.Is(err, hotplug.ErrPending) {
+			r.recordOperation(log, machine.ID, journal.OperationDetach, d.Alias, err)
+		}
+		return err
+	case drift.KindVCPUs:
+		if err := r.conn().DomainSetVcpusFlags(domain, uint32(d.Desired), uint32(libvirt.DomainAffectLive)); err != nil {
+			return fmt.Errorf("error setting vcpus: %w", err)
+		}
+		return nil
+	case drift.KindMemory:
+		if err := r.conn().DomainSetMemoryFlags(domain, d.Desired/1024, uint32(libvirt.DomainMemLive)); err != nil {
+			return fmt.Errorf("error setting memory: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported drift kind %s", d.Kind)
+	}
+}
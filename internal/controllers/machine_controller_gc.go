@@ -0,0 +1,65 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-logr/logr"
+	"github.com/ironcore-dev/libvirt-provider/internal/store"
+)
+
+const DefaultGCWorkers = 4
+
+// processNextGCItem processes the deletion of a single machine. The queue ensures a machine is only processed
+// by one worker at a time, forced finalizations of the machine wait for the worker.
+func (r *MachineReconciler) processNextGCItem(ctx context.Context, log logr.Logger) bool {
+	id, shutdown := r.gcQueue.Get()
+	if shutdown {
+		return false
+	}
+	defer r.gcQueue.Done(id)
+
+	done, ok := r.startWork()
+	defer done()
+	if !ok {
+		return true
+	}
+
+	log = log.WithValues("machineID", id)
+
+	defer r.lockFinalize(id)()
+
+	machine, err := r.machines.Get(ctx, id)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			log.Error(err, "failed to fetch machine from store")
+			r.gcQueue.AddRateLimited(id)
+			return true
+		}
+		r.gcQueue.Forget(id)
+		return true
+	}
+
+	if !isTerminating(machine) {
+		r.gcQueue.Forget(id)
+		return true
+	}
+
+	if err := r.waitReconcile(ctx, id); err != nil {
+		log.Error(err, "failed to wait for the reconcile of the machine")
+		r.gcQueue.AddRateLimited(id)
+		return true
+	}
+
+	if err := r.processMachineDeletion(ctx, log, machine); err != nil {
+		log.Error(err, "failed to garbage collect machine")
+		r.gcQueue.AddRateLimited(id)
+		return true
+	}
+
+	r.gcQueue.Forget(id)
+	return true
+}
@@ -0,0 +1,117 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"time"
+
+	"github.com/digitalocean/go-libvirt"
+	"github.com/digitalocean/go-libvirt/libvirttest"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/host"
+	"github.com/ironcore-dev/libvirt-provider/internal/store"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/keymutex"
+)
+
+var _ = Describe("Machine garbage collector", func() {
+	var (
+		r        *MachineReconciler
+		machines store.Store[*api.Machine]
+	)
+
+	BeforeEach(func() {
+		lv := libvirt.NewWithDialer(libvirttest.New())
+		Expect(lv.Connect()).To(Succeed())
+		DeferCleanup(lv.Disconnect)
+
+		var err error
+		machines, err = host.NewStore[*api.Machine](host.Options[*api.Machine]{
+			Dir:     GinkgoT().TempDir(),
+			NewFunc: func() *api.Machine { return &api.Machine{} },
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		r = &MachineReconciler{
+			libvirt:                     lv,
+			machines:                    machines,
+			EventRecorder:               nopEventRecorder{},
+			gcQueue:                     workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[string]()),
+			gcVMGracefulShutdownTimeout: time.Minute,
+			gcVMShutdownResendInterval:  time.Minute,
+			finalizeLocks:               keymutex.NewHashed(finalizeLockBuckets),
+		}
+		DeferCleanup(r.gcQueue.ShutDown)
+	})
+
+	createMachine := func(ctx SpecContext, id string, terminating bool) {
+		machine, err := machines.Create(ctx, &api.Machine{
+			Metadata: api.Metadata{ID: id, Finalizers: []string{MachineFinalizer}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		if terminating {
+			Expect(machines.Delete(ctx, machine.ID)).To(Succeed())
+		}
+	}
+
+	It("should enqueue a deleted machine on its reconcile without waiting for the next resync", func(ctx SpecContext) {
+		createMachine(ctx, "2a4a1d5e-4b43-4c09-a2ff-c8f5c3bb5b1a", true)
+
+		Expect(r.reconcileMachine(ctx, "2a4a1d5e-4b43-4c09-a2ff-c8f5c3bb5b1a")).To(Succeed())
+		Expect(r.gcQueue.Len()).To(Equal(1))
+	})
+
+	It("should shut down the domains of the deleted machines", func(ctx SpecContext) {
+		ids := []string{"2a4a1d5e-4b43-4c09-a2ff-c8f5c3bb5b1a", "6d0b2e59-7c0f-4d44-9f5e-2f6e4f7f0c11"}
+		for _, id := range ids {
+			createMachine(ctx, id, true)
+			r.gcQueue.Add(id)
+		}
+
+		for range ids {
+			Expect(r.processNextGCItem(ctx, GinkgoLogr)).To(BeTrue())
+		}
+		Expect(r.gcQueue.Len()).To(BeZero())
+		for _, id := range ids {
+			machine, err := machines.Get(ctx, id)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(machine.Status.Shutdown).To(HaveField("Stage", api.ShutdownStageACPI))
+			Expect(machine.Status.Shutdown.RequestedAt).NotTo(BeZero())
+		}
+	})
+
+	It("should not wait for the forced finalization of other machines", func(ctx SpecContext) {
+		createMachine(ctx, "2a4a1d5e-4b43-4c09-a2ff-c8f5c3bb5b1a", true)
+		r.gcQueue.Add("2a4a1d5e-4b43-4c09-a2ff-c8f5c3bb5b1a")
+
+		unlock := r.lockFinalize("6d0b2e59-7c0f-4d44-9f5e-2f6e4f7f0c11")
+		defer unlock()
+
+		Expect(r.processNextGCItem(ctx, GinkgoLogr)).To(BeTrue())
+		machine, err := machines.Get(ctx, "2a4a1d5e-4b43-4c09-a2ff-c8f5c3bb5b1a")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(machine.Status.Shutdown).NotTo(BeNil())
+	})
+
+	It("should drop machines that aren't terminating", func(ctx SpecContext) {
+		createMachine(ctx, "2a4a1d5e-4b43-4c09-a2ff-c8f5c3bb5b1a", false)
+		r.gcQueue.Add("2a4a1d5e-4b43-4c09-a2ff-c8f5c3bb5b1a")
+		r.gcQueue.Add("6d0b2e59-7c0f-4d44-9f5e-2f6e4f7f0c11")
+
+		Expect(r.processNextGCItem(ctx, GinkgoLogr)).To(BeTrue())
+		Expect(r.processNextGCItem(ctx, GinkgoLogr)).To(BeTrue())
+		Expect(r.gcQueue.Len()).To(BeZero())
+
+		machine, err := machines.Get(ctx, "2a4a1d5e-4b43-4c09-a2ff-c8f5c3bb5b1a")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(machine.Status.Shutdown).To(BeNil())
+	})
+
+	It("should stop once the queue is shut down", func(ctx SpecContext) {
+		r.gcQueue.ShutDown()
+		Expect(r.processNextGCItem(ctx, GinkgoLogr)).To(BeFalse())
+	})
+})
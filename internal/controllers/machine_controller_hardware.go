@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-logr/logr"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/guest"
+	corev1 "k8s.io/api/core/v1"
+	"libvirt.org/go/libvirtxml"
+)
+
+// machineTypeFor returns the machine type of the domain of the machine. A machine type pinned by the spec has to be
+// supported by the host. Otherwise, the machine type the domain was first started with is kept as long as the host
+// supports it, so the virtual hardware of the guest doesn't change across upgrades of QEMU.
+func (r *MachineReconciler) machineTypeFor(log logr.Logger, machine *api.Machine, reqs guest.Requests, preferred string) (string, error) {
+	if hardware := machine.Spec.Hardware; hardware != nil && hardware.MachineType != "" {
+		if !r.guestCapabilities.SupportsMachineType(reqs, hardware.MachineType) {
+			r.Eventf(log, machine.Metadata, corev1.EventTypeWarning, "UnsupportedMachineType", "Machine type %s is not supported by the host", hardware.MachineType)
+			return "", fmt.Errorf("machine type %s is not supported by the host", hardware.MachineType)
+		}
+		return hardware.MachineType, nil
+	}
+
+	machineType := machine.Status.MachineType
+	if machineType == "" || machineType == preferred {
+		return preferred, nil
+	}
+	if !r.guestCapabilities.SupportsMachineType(reqs, machineType) {
+		r.Eventf(log, machine.Metadata, corev1.EventTypeWarning, "MachineTypeChanged", "Machine type %s is not supported by the host anymore, using %s", machineType, preferred)
+		return preferred, nil
+	}
+	return machineType, nil
+}
+
+// setDomainFirmware replaces the firmware selected by libvirt with the loader and variable store template pinned
+// by the machine spec.
+func setDomainFirmware(machine *api.Machine, domain *libvirtxml.Domain) error {
+	hardware := machine.Spec.Hardware
+	if hardware == nil || hardware.Loader == "" {
+		return nil
+	}
+
+	if _, err := os.Stat(hardware.Loader); err != nil {
+		return fmt.Errorf("error checking loader: %w", err)
+	}
+
+	domain.OS.Firmware = ""
+	domain.OS.FirmwareInfo = nil
+	domain.OS.Loader = &libvirtxml.DomainLoader{
+		Path:     hardware.Loader,
+		Readonly: "yes",
+		Type:     "pflash",
+	}
+
+	if hardware.NVRAMTemplate != "" {
+		if _, err := os.Stat(hardware.NVRAMTemplate); err != nil {
+			return fmt.Errorf("error checking nvram template: %w", err)
+		}
+		domain.OS.NVRam = &libvirtxml.DomainNVRam{
+			Template: hardware.NVRAMTemplate,
+		}
+	}
+	return nil
+}
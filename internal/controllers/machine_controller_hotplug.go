@@ -0,0 +1,176 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/digitalocean/go-libvirt"
+	"github.com/go-logr/logr"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/call"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/hotplug"
+	"github.com/ironcore-dev/libvirt-provider/internal/store"
+	"libvirt.org/go/libvirtxml"
+)
+
+// DefaultDeviceEventTimeout is the time after which a hotplug operation not confirmed by a libvirt device event
+// is considered done for attachments and retried for detachments.
+const DefaultDeviceEventTimeout = 30 * time.Second
+
+// deviceEvent is a libvirt device event of a domain.
+type deviceEvent struct {
+	domain string
+	alias  string
+	id     libvirt.DomainEventID
+}
+
+// subscribeDeviceEvents subscribes to the libvirt events confirming device hotplug operations.
+func (r *MachineReconciler) subscribeDeviceEvents(ctx context.Context) (<-chan deviceEvent, error) {
+	events := make(chan deviceEvent)
+	for _, id := range []libvirt.DomainEventID{
+		libvirt.DomainEventIDDeviceAdded,
+		libvirt.DomainEventIDDeviceRemoved,
+		libvirt.DomainEventIDDeviceRemovalFailed,
+	} {
+		msgs, err := r.libvirt.SubscribeEvents(ctx, id, libvirt.OptDomain{})
+		if err != nil {
+			return nil, fmt.Errorf("error subscribing to device event %d: %w", id, err)
+		}
+
+		go func() {
+			for msg := range msgs {
+				var evt deviceEvent
+				switch msg := msg.(type) {
+				case *libvirt.DomainEventCallbackDeviceAddedMsg:
+					evt = deviceEvent{domain: msg.Dom.Name, alias: msg.DevAlias, id: id}
+				case *libvirt.DomainEventCallbackDeviceRemovedMsg:
+					evt = deviceEvent{domain: msg.Msg.Dom.Name, alias: msg.Msg.DevAlias, id: id}
+				case *libvirt.DomainEventCallbackDeviceRemovalFailedMsg:
+					evt = deviceEvent{domain: msg.Dom.Name, alias: msg.DevAlias, id: id}
+				default:
+					continue
+				}
+
+				select {
+				case events <- evt:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	return events, nil
+}
+
+func (r *MachineReconciler) startEnqueueMachineByDeviceEvent(ctx context.Context, log logr.Logger, events <-chan deviceEvent) {
+	log.Info("Subscribing to libvirt device events")
+
+	for {
+		select {
+		case evt := <-events:
+			var tracked bool
+			switch evt.id {
+			case libvirt.DomainEventIDDeviceAdded:
+				tracked = r.hotplug.Added(evt.domain, evt.alias)
+			case libvirt.DomainEventIDDeviceRemoved:
+				tracked = r.hotplug.Removed(evt.domain, evt.alias)
+			case libvirt.DomainEventIDDeviceRemovalFailed:
+				tracked = r.hotplug.RemovalFailed(evt.domain, evt.alias)
+			}
+
+			if _, err := r.machines.Get(ctx, evt.domain); err != nil {
+				if errors.Is(err, store.ErrNotFound) {
+					log.V(2).Info("Skipped: not managed by libvirt-provider", "machineID", evt.domain)
+					continue
+				}
+				log.Error(err, "failed to fetch machine from store")
+				continue
+			}
+
+			log.V(1).Info("requeue machine", "machineID", evt.domain, "deviceEventID", evt.id, "alias", evt.alias, "tracked", tracked)
+			r.queue.Add(evt.domain)
+		case <-ctx.Done():
+			log.Info("Context done for libvirt device events.")
+			return
+		}
+	}
+}
+
+// requeueDevicePending requeues the machine once the pending hotplug operation times out. It reports whether
+// the error is a pending operation.
+func (r *MachineReconciler) requeueDevicePending(log logr.Logger, machineID, name string, err error) bool {
+	if !errors.Is(err, hotplug.ErrPending) {
+		return false
+	}
+
+	log.V(1).Info("Waiting for libvirt to confirm device operation", "name", name)
+	r.queue.AddAfter(machineID, r.hotplug.Timeout())
+	return true
+}
+
+// devicePending reports whether the attachment of the device wasn't confirmed yet. Attachments that timed out
+// are considered done, the device is part of the domain.
+func (r *MachineReconciler) devicePending(log logr.Logger, machineID, deviceAlias string) bool {
+	op, state, ok := r.hotplug.Pending(machineID, deviceAlias)
+	if !ok || op != hotplug.OperationAttach {
+		return false
+	}
+	if state == hotplug.StatePending {
+		r.queue.AddAfter(machineID, r.hotplug.Timeout())
+		return true
+	}
+
+	log.V(1).Info("Device attachment not confirmed by libvirt", "alias", deviceAlias, "state", state)
+	r.hotplug.Forget(machineID, deviceAlias)
+	return false
+}
+
+// attachDevice attaches the device to the running domain and tracks the attachment until libvirt confirms it.
+// If the context is done or the call timeout passes, the call is aborted and awaited, so the attachment is only
+// forgotten once the call failed.
+func attachDevice(ctx context.Context, lv *libvirt.Libvirt, abort func(), callTimeout time.Duration, tracker *hotplug.Tracker, machineID, deviceAlias string, dev libvirtxml.Document) error {
+	data, err := dev.Marshal()
+	if err != nil {
+		return err
+	}
+
+	tracker.Expect(machineID, deviceAlias, hotplug.OperationAttach)
+	if err := call.Mutate(ctx, callTimeout, abort, func() error {
+		return lv.DomainAttachDevice(machineDomain(machineID), data)
+	}); err != nil {
+		tracker.Forget(machineID, deviceAlias)
+		return err
+	}
+	return nil
+}
+
+// detachDevice requests the removal of the device from the running domain. It returns hotplug.ErrPending until
+// libvirt confirms the removal. Removals that failed or timed out are requested again. The libvirt call is
+// aborted and awaited like the one of attachDevice.
+func detachDevice(ctx context.Context, lv *libvirt.Libvirt, abort func(), callTimeout time.Duration, tracker *hotplug.Tracker, machineID, deviceAlias string, dev libvirtxml.Document) error {
+	if op, state, ok := tracker.Pending(machineID, deviceAlias); ok && op == hotplug.OperationDetach && state == hotplug.StatePending {
+		return fmt.Errorf("detaching %s: %w", deviceAlias, hotplug.ErrPending)
+	}
+
+	data, err := dev.Marshal()
+	if err != nil {
+		return err
+	}
+
+	tracker.Expect(machineID, deviceAlias, hotplug.OperationDetach)
+	if err := call.Mutate(ctx, callTimeout, abort, func() error {
+		return lv.DomainDetachDevice(machineDomain(machineID), data)
+	}); err != nil {
+		tracker.Forget(machineID, deviceAlias)
+		return err
+	}
+
+	if _, _, ok := tracker.Pending(machineID, deviceAlias); ok {
+		return fmt.Errorf("detaching %s: %w", deviceAlias, hotplug.ErrPending)
+	}
+	return nil
+}
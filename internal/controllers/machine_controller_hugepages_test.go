@@ -0,0 +1,46 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/hugepages"
+	"github.com/ironcore-dev/libvirt-provider/internal/placement"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"libvirt.org/go/libvirtxml"
+)
+
+var _ = Describe("Machine hugepages", func() {
+	It("should restrict the vcpus to the numa node the hugepages are allocated from", func() {
+		root := GinkgoT().TempDir()
+		for node, free := range map[string]string{"node0": "1", "node1": "4"} {
+			dir := filepath.Join(root, "devices", "system", "node", node)
+			Expect(os.MkdirAll(filepath.Join(dir, "hugepages", "hugepages-1048576kB"), 0755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dir, "hugepages", "hugepages-1048576kB", "free_hugepages"), []byte(free), 0644)).To(Succeed())
+		}
+		Expect(os.WriteFile(filepath.Join(root, "devices", "system", "node", "node1", "cpulist"), []byte("8-15\n"), 0644)).To(Succeed())
+
+		manager := hugepages.NewManager(root)
+		r := &MachineReconciler{hugepages: manager, placement: placement.NewNUMACoordinator(manager, nil)}
+		machine := &api.Machine{Spec: api.MachineSpec{
+			CpuMillis:   2000,
+			MemoryBytes: 2 * hugepages.Size1Gi,
+			Hugepages:   &api.HugepagesSpec{PageSize: hugepages.Size1Gi},
+		}}
+		domain := &libvirtxml.Domain{}
+
+		Expect(r.setDomainResources(GinkgoLogr, machine, domain)).To(Succeed())
+		Expect(domain.NUMATune.Memory.Nodeset).To(Equal("1"))
+		Expect(domain.VCPU).To(Equal(&libvirtxml.DomainVCPU{Value: 2, CPUSet: "8-15"}))
+		Expect(placementStatus(domain)).To(Equal(&api.PlacementStatus{
+			VCPUCPUSet:         "8-15",
+			NUMANodes:          "1",
+			HugepagesNUMANodes: "1",
+		}))
+	})
+})
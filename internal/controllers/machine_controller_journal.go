@@ -0,0 +1,120 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/digitalocean/go-libvirt"
+	"github.com/go-logr/logr"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/journal"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/call"
+	libvirtutils "github.com/ironcore-dev/libvirt-provider/internal/libvirt/utils"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// errOperationInterrupted is recorded as the outcome of operations that didn't take place because a restart
+// interrupted them.
+var errOperationInterrupted = errors.New("interrupted by a restart of the provider")
+
+// replayJournal resolves the create and destroy operations the provider was interrupted in, e.g. by a crash between
+// creating a domain and persisting its status. Operations whose domain change took place are completed, the domains
+// of creations interrupted by the deletion of their machine are destroyed. Either way the machines are reconciled
+// again right away.
+func (r *MachineReconciler) replayJournal(ctx context.Context, log logr.Logger) {
+	if r.journal == nil {
+		return
+	}
+
+	machines, err := r.machines.List(ctx)
+	if err != nil {
+		log.Error(err, "failed to list machines to replay journal")
+		return
+	}
+
+	for _, machine := range machines {
+		pending, err := r.journal.Pending(machine.ID)
+		if err != nil {
+			log.Error(err, "failed to read journal", "machineID", machine.ID)
+			continue
+		}
+		if len(pending) == 0 {
+			continue
+		}
+		for _, entry := range pending {
+			if err := r.replayOperation(ctx, log.WithValues("machineID", machine.ID, "Operation", entry.Operation), machine, entry); err != nil {
+				log.Error(err, "failed to replay interrupted operation", "machineID", machine.ID, "Operation", entry.Operation)
+			}
+		}
+
+		if isTerminating(machine) {
+			r.gcQueue.Add(machine.ID)
+			continue
+		}
+		r.queue.Add(machine.ID)
+	}
+}
+
+func (r *MachineReconciler) replayOperation(ctx context.Context, log logr.Logger, machine *api.Machine, entry journal.Entry) error {
+	domain, err := call.Value(ctx, r.libvirtCallTimeout, func() (libvirt.Domain, error) {
+		return r.conn().DomainLookupByUUID(libvirtutils.UUIDStringToBytes(machine.ID))
+	})
+	if err != nil && !libvirt.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+
+	return r.resolveOperation(log, machine, entry, exists, func() error {
+		return r.destroyDomain(ctx, log, machine, domain)
+	})
+}
+
+// resolveOperation completes or compensates the interrupted operation of the journal entry. exists reports whether
+// the domain of the machine exists, destroy destroys it.
+func (r *MachineReconciler) resolveOperation(log logr.Logger, machine *api.Machine, entry journal.Entry, exists bool, destroy func() error) error {
+	switch entry.Operation {
+	case journal.OperationCreate:
+		switch {
+		case !exists:
+			// Nothing to undo, the reconcile creates the domain again.
+			log.Info("Domain creation was interrupted before it took place")
+			r.recordOperation(log, machine.ID, entry.Operation, entry.Target, errOperationInterrupted)
+			r.Eventf(log, machine.Metadata, corev1.EventTypeWarning, "InterruptedCreate", "Domain creation was interrupted by a restart, creating it again")
+		case machine.DeletedAt != nil:
+			log.Info("Rolling back interrupted domain creation of deleted machine")
+			if err := destroy(); err != nil {
+				return fmt.Errorf("error destroying domain of interrupted creation: %w", err)
+			}
+			r.recordOperation(log, machine.ID, entry.Operation, entry.Target, errOperationInterrupted)
+			r.Eventf(log, machine.Metadata, corev1.EventTypeWarning, "RolledBackInterruptedCreate", "Destroyed domain whose creation was interrupted by a restart")
+		default:
+			// The creation is completed once the reconcile persisted the status of the domain.
+			log.Info("Completing interrupted domain creation")
+			r.unresolvedCreates.Store(machine.ID, struct{}{})
+		}
+	case journal.OperationDestroy:
+		switch {
+		case !exists:
+			log.Info("Completed interrupted domain destruction")
+			r.recordOperation(log, machine.ID, entry.Operation, entry.Target, nil)
+		case isTerminating(machine):
+			log.Info("Completing interrupted domain destruction")
+			// Destroying records the outcome.
+			if err := destroy(); err != nil {
+				return fmt.Errorf("error completing interrupted domain destruction: %w", err)
+			}
+		default:
+			// The reconcile decides whether the domain still has to be destroyed, e.g. to power it off.
+			log.Info("Domain destruction was interrupted before it took place")
+			r.recordOperation(log, machine.ID, entry.Operation, entry.Target, errOperationInterrupted)
+		}
+	default:
+		// Only creates and destroys are begun, other operations are journaled once they completed.
+		r.recordOperation(log, machine.ID, entry.Operation, entry.Target, errOperationInterrupted)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	providerhost "github.com/ironcore-dev/libvirt-provider/internal/host"
+	"github.com/ironcore-dev/libvirt-provider/internal/journal"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Machine journal replay", func() {
+	var (
+		r         *MachineReconciler
+		machine   *api.Machine
+		destroyed int
+		destroy   func() error
+	)
+
+	BeforeEach(func() {
+		host, err := providerhost.NewAt(GinkgoT().TempDir())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(providerhost.MakeMachineDirs(host, "foo")).To(Succeed())
+
+		r = &MachineReconciler{
+			EventRecorder: nopEventRecorder{},
+			journal:       journal.New(host.MachineJournalFile, journal.Options{}),
+		}
+		machine = &api.Machine{Metadata: api.Metadata{ID: "foo", Finalizers: []string{MachineFinalizer}}}
+		destroyed = 0
+		destroy = func() error {
+			destroyed++
+			return nil
+		}
+	})
+
+	deleteMachine := func() {
+		now := time.Now()
+		machine.DeletedAt = &now
+	}
+
+	resolve := func(operation journal.Operation, exists bool) error {
+		Expect(r.journal.Begin("foo", operation, "")).To(Succeed())
+		pending, err := r.journal.Pending("foo")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pending).To(HaveLen(1))
+		return r.resolveOperation(GinkgoLogr, machine, pending[0], exists, destroy)
+	}
+
+	lastEntry := func() journal.Entry {
+		entries, err := r.journal.Read("foo")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).NotTo(BeEmpty())
+		return entries[len(entries)-1]
+	}
+
+	pending := func() []journal.Entry {
+		entries, err := r.journal.Pending("foo")
+		Expect(err).NotTo(HaveOccurred())
+		return entries
+	}
+
+	Describe("interrupted creations", func() {
+		It("should record a creation that didn't take place as interrupted", func() {
+			Expect(resolve(journal.OperationCreate, false)).To(Succeed())
+			Expect(destroyed).To(BeZero())
+			Expect(lastEntry()).To(SatisfyAll(
+				HaveField("Outcome", journal.OutcomeFailed),
+				HaveField("Message", errOperationInterrupted.Error()),
+			))
+		})
+
+		It("should destroy the created domain of a deleted machine", func() {
+			deleteMachine()
+			Expect(resolve(journal.OperationCreate, true)).To(Succeed())
+			Expect(destroyed).To(Equal(1))
+			Expect(pending()).To(BeEmpty())
+			Expect(lastEntry().Outcome).To(Equal(journal.OutcomeFailed))
+		})
+
+		It("should keep the creation pending if the domain can't be destroyed", func() {
+			deleteMachine()
+			destroy = func() error { return errors.New("boom") }
+			Expect(resolve(journal.OperationCreate, true)).To(MatchError(ContainSubstring("boom")))
+			Expect(pending()).To(ConsistOf(HaveField("Operation", journal.OperationCreate)))
+		})
+
+		It("should complete the creation once the reconcile persisted the status of the domain", func() {
+			Expect(resolve(journal.OperationCreate, true)).To(Succeed())
+			Expect(destroyed).To(BeZero())
+			Expect(pending()).To(ConsistOf(HaveField("Operation", journal.OperationCreate)))
+			_, unresolved := r.unresolvedCreates.Load("foo")
+			Expect(unresolved).To(BeTrue())
+		})
+	})
+
+	Describe("interrupted destructions", func() {
+		It("should complete a destruction that took place", func() {
+			deleteMachine()
+			Expect(resolve(journal.OperationDestroy, false)).To(Succeed())
+			Expect(destroyed).To(BeZero())
+			Expect(lastEntry().Outcome).To(Equal(journal.OutcomeSucceeded))
+		})
+
+		It("should destroy the domain of a deleted machine", func() {
+			deleteMachine()
+			Expect(resolve(journal.OperationDestroy, true)).To(Succeed())
+			Expect(destroyed).To(Equal(1))
+		})
+
+		It("should leave the domain of a machine that isn't deleted to the reconcile", func() {
+			Expect(resolve(journal.OperationDestroy, true)).To(Succeed())
+			Expect(destroyed).To(BeZero())
+			Expect(lastEntry()).To(SatisfyAll(
+				HaveField("Operation", journal.OperationDestroy),
+				HaveField("Outcome", journal.OutcomeFailed),
+			))
+		})
+	})
+})
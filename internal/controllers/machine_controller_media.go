@@ -0,0 +1,141 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/digitalocean/go-libvirt"
+	"github.com/go-logr/logr"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/journal"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/alias"
+	"github.com/ironcore-dev/libvirt-provider/internal/media"
+	providerimage "github.com/ironcore-dev/libvirt-provider/internal/oci"
+	"github.com/ironcore-dev/libvirt-provider/internal/osutils"
+	corev1 "k8s.io/api/core/v1"
+	"libvirt.org/go/libvirtxml"
+)
+
+// usesMediaImage reports whether any media drive of the machine holds the image with the given reference.
+func usesMediaImage(machine *api.Machine, ref string) bool {
+	for _, spec := range machine.Spec.Media {
+		if spec.Source != nil && spec.Source.Image == ref {
+			return true
+		}
+	}
+	return false
+}
+
+// mediaFile returns the ISO image file of the media source. The root fs layer of an image is the ISO image.
+func (r *MachineReconciler) mediaFile(ctx context.Context, source *api.MediaSource) (string, error) {
+	if source.Image != "" {
+		img, err := r.imageCache.Get(ctx, source.Image)
+		if err != nil {
+			return "", err
+		}
+		return img.RootFS.Path, nil
+	}
+
+	if r.mediaDir == "" {
+		return "", fmt.Errorf("cannot insert %s: no media directory configured", source.Path)
+	}
+	file := filepath.Join(r.mediaDir, source.Path)
+	ok, err := osutils.RegularFileExists(file)
+	if err != nil {
+		return "", fmt.Errorf("error checking media: %w", err)
+	}
+	if !ok {
+		return "", fmt.Errorf("media %s not found", file)
+	}
+	return file, nil
+}
+
+// setDomainMedia adds the media drives of the machine as cdrom disks to the domain. The domain is created once
+// the images of all inserted media are pulled.
+func (r *MachineReconciler) setDomainMedia(ctx context.Context, log logr.Logger, machine *api.Machine, domain *libvirtxml.Domain) error {
+	for i, spec := range machine.Spec.Media {
+		var file string
+		if spec.Source != nil {
+			var err error
+			file, err = r.mediaFile(ctx, spec.Source)
+			if err != nil {
+				if errors.Is(err, providerimage.ErrImagePulling) {
+					r.Eventf(log, machine.Metadata, corev1.EventTypeNormal, "PullingImage", "Pulling image %s", spec.Source.Image)
+				}
+				return fmt.Errorf("media drive %s: %w", spec.Name, err)
+			}
+		}
+
+		domain.Devices.Disks = append(domain.Devices.Disks, media.Disk(spec.Name, i, file))
+	}
+	return nil
+}
+
+// reconcileMedia inserts and ejects the media of the drives of the running domain. Drives the domain lacks are
+// only added at the next start of the domain.
+func (r *MachineReconciler) reconcileMedia(ctx context.Context, log logr.Logger, machine *api.Machine, domain *libvirtxml.Domain) error {
+	disks := make(map[string]*libvirtxml.DomainDisk)
+	for i := range domain.Devices.Disks {
+		if disk := &domain.Devices.Disks[i]; disk.Alias != nil {
+			disks[disk.Alias.Name] = disk
+		}
+	}
+
+	for _, spec := range machine.Spec.Media {
+		disk, ok := disks[alias.Media(spec.Name)]
+		if !ok {
+			log.V(1).Info("Media drive is added at the next start of the domain", "Drive", spec.Name)
+			continue
+		}
+
+		var file string
+		if spec.Source != nil {
+			var err error
+			file, err = r.mediaFile(ctx, spec.Source)
+			if err != nil {
+				if errors.Is(err, providerimage.ErrImagePulling) {
+					// The machine is requeued once the pull is done.
+					log.V(1).Info("Pulling media image", "Drive", spec.Name, "Image", spec.Source.Image)
+					continue
+				}
+				return fmt.Errorf("media drive %s: %w", spec.Name, err)
+			}
+		}
+		if media.File(disk) == file {
+			continue
+		}
+
+		if err := r.changeMedia(log, machine, spec.Name, *disk, file); err != nil {
+			return fmt.Errorf("media drive %s: %w", spec.Name, err)
+		}
+	}
+	return nil
+}
+
+// changeMedia replaces the media of the cdrom disk of the running domain with the file, ejecting it if empty.
+// The eject is forced, so guests can't keep the tray locked.
+func (r *MachineReconciler) changeMedia(log logr.Logger, machine *api.Machine, name string, disk libvirtxml.DomainDisk, file string) error {
+	media.SetFile(&disk, file)
+	data, err := disk.Marshal()
+	if err != nil {
+		return err
+	}
+
+	err = r.conn().DomainUpdateDeviceFlags(machineDomain(machine.ID), data, libvirt.DomainDeviceModifyLive|libvirt.DomainDeviceModifyForce)
+	r.recordOperation(log, machine.ID, journal.OperationChangeMedia, name, err)
+	if err != nil {
+		return fmt.Errorf("error changing media: %w", err)
+	}
+
+	if file == "" {
+		r.Eventf(log, machine.Metadata, corev1.EventTypeNormal, "EjectedMedia", "Ejected media of drive %s", name)
+	} else {
+		r.Eventf(log, machine.Metadata, corev1.EventTypeNormal, "InsertedMedia", "Inserted media into drive %s", name)
+	}
+	return nil
+}
@@ -0,0 +1,329 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/journal"
+	providervolume "github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
+	"github.com/ironcore-dev/libvirt-provider/internal/store"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// volumeMigrationPollInterval is the interval the progress of running volume migrations is polled in.
+	volumeMigrationPollInterval = 5 * time.Second
+	// volumeMigrationRetryDelay is the delay after which a failed volume migration is started again.
+	volumeMigrationRetryDelay = time.Minute
+)
+
+func getVolumeStatusByName(machine *api.Machine, name string) *api.VolumeStatus {
+	for _, volumeStatus := range machine.Status.VolumeStatus {
+		if volumeStatus.Name == name {
+			return &volumeStatus
+		}
+	}
+	return nil
+}
+
+func volumeMigrating(status *api.VolumeStatus) bool {
+	return status != nil && status.Migration != nil && status.Migration.Phase != api.VolumeMigrationPhaseFailed
+}
+
+// reconcileVolumeMigration returns the status of the volume if it is migrated live to another plugin, starting
+// the migration if the plugin of the attached volume changed. It returns nil if the volume isn't migrated.
+func (r *MachineReconciler) reconcileVolumeMigration(
+	ctx context.Context,
+	log logr.Logger,
+	machine *api.Machine,
+	spec *api.VolumeSpec,
+	mounter VolumeMounter,
+	attacher VolumeAttacher,
+) (*api.VolumeStatus, error) {
+	current := getVolumeStatusByName(machine, spec.Name)
+	switch {
+	case volumeMigrating(current):
+		r.migrationQueue.Add(machine.ID)
+		return current, nil
+	case current != nil && current.Migration != nil:
+		if retryAt := current.Migration.FailedAt.Add(volumeMigrationRetryDelay); time.Now().Before(retryAt) {
+			r.queue.AddAfter(machine.ID, time.Until(retryAt))
+			return current, nil
+		}
+	}
+
+	plugin, err := mounter.PluginManager().FindPluginBySpec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	mounted, err := mounter.ListVolumes()
+	if err != nil {
+		return nil, err
+	}
+	var source *MountVolume
+	for _, volume := range mounted {
+		if volume.ComputeVolumeName == spec.Name && volume.PluginName != plugin.Name() {
+			source = &volume
+		}
+	}
+	if source == nil {
+		return nil, nil
+	}
+	if spec.Shareable {
+		// Other machines keep writing shareable volumes during the copy, they are replaced without copying.
+		return nil, nil
+	}
+	if _, err := attacher.GetVolume(spec.Name); err != nil {
+		if errors.Is(err, ErrAttachedVolumeNotFound) {
+			// Volumes that aren't attached are replaced without copying.
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	log.V(1).Info("Starting live volume migration", "volumeName", spec.Name, "SourcePlugin", source.PluginName, "TargetPlugin", plugin.Name())
+	volumeID, err := plugin.GetBackingVolumeID(spec, machine.ID)
+	if err != nil {
+		return nil, err
+	}
+	volume, err := plugin.Apply(ctx, spec, machine)
+	if err != nil {
+		return nil, fmt.Errorf("error applying target volume: %w", err)
+	}
+
+	err = attacher.CopyVolume(&AttachVolume{
+		Name:   spec.Name,
+		Device: spec.Device,
+		Spec:   *volume,
+	})
+	r.recordOperation(log, machine.ID, journal.OperationMigrate, spec.Name, err)
+	if err != nil {
+		return nil, fmt.Errorf("error starting volume copy: %w", err)
+	}
+	r.Eventf(log, machine.Metadata, corev1.EventTypeNormal, "MigratingVolume", "Migrating volume %s from %s to %s", spec.Name, source.PluginName, plugin.Name())
+
+	r.migrationQueue.Add(machine.ID)
+	return &api.VolumeStatus{
+		Name:   spec.Name,
+		Handle: GetUniqueVolumeName(plugin.Name(), volumeID),
+		State:  api.VolumeStateAttached,
+		Size:   volume.Size,
+		Migration: &api.VolumeMigrationStatus{
+			SourcePlugin: source.PluginName,
+			TargetPlugin: plugin.Name(),
+			Phase:        api.VolumeMigrationPhaseCopying,
+			StartedAt:    time.Now(),
+			TotalBytes:   volume.Size,
+		},
+	}, nil
+}
+
+func (r *MachineReconciler) processNextMigrationItem(ctx context.Context, log logr.Logger) bool {
+	id, shutdown := r.migrationQueue.Get()
+	if shutdown {
+		return false
+	}
+	defer r.migrationQueue.Done(id)
+
+	done, ok := r.startWork()
+	defer done()
+	if !ok {
+		return true
+	}
+
+	log = log.WithValues("machineID", id)
+	unlock := r.lockMachine(id)
+	requeue, err := r.progressVolumeMigrations(ctx, log, id)
+	unlock()
+	if err != nil {
+		log.Error(err, "failed to progress volume migrations")
+		r.migrationQueue.AddRateLimited(id)
+		return true
+	}
+
+	r.migrationQueue.Forget(id)
+	if requeue {
+		r.migrationQueue.AddAfter(id, volumeMigrationPollInterval)
+	}
+	return true
+}
+
+// progressVolumeMigrations updates the progress of the volume migrations of the machine and completes the ones
+// whose copy is ready. It reports whether migrations are still running.
+func (r *MachineReconciler) progressVolumeMigrations(ctx context.Context, log logr.Logger, machineID string) (bool, error) {
+	machine, err := r.machines.Get(ctx, machineID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to fetch machine from store: %w", err)
+	}
+	if machine.DeletedAt != nil {
+		return false, nil
+	}
+
+	domainDesc, err := r.getDomainDesc(machine.ID)
+	if err != nil {
+		return false, fmt.Errorf("error getting domain description: %w", err)
+	}
+	attacher, err := NewLibvirtVolumeAttacher(domainDesc, r.newDomainExecutor(ctx, machine.ID), r.cpuPinning, r.virtioQueues(machine).disk)
+	if err != nil {
+		return false, fmt.Errorf("error construction volume attacher: %w", err)
+	}
+
+	var (
+		running, completed bool
+		errs               []error
+	)
+	for i := range machine.Status.VolumeStatus {
+		status := &machine.Status.VolumeStatus[i]
+		if !volumeMigrating(status) {
+			continue
+		}
+
+		if err := r.progressVolumeMigration(ctx, log.WithValues("volumeName", status.Name), machine, attacher, status); err != nil {
+			errs = append(errs, fmt.Errorf("[volume %s] %w", status.Name, err))
+		}
+		if volumeMigrating(status) {
+			running = true
+		} else {
+			completed = true
+		}
+	}
+
+	// The progress is persisted even if a migration failed to progress, so a pivot isn't repeated.
+	if _, err := store.RetryOnConflict(ctx, r.machines, machine, func(latest *api.Machine) error {
+		latest.Status.VolumeStatus = machine.Status.VolumeStatus
+		return nil
+	}); err != nil {
+		return false, fmt.Errorf("failed to update volume migration status: %w", err)
+	}
+	if completed {
+		r.queue.Add(machine.ID)
+	}
+	return running, errors.Join(errs...)
+}
+
+func (r *MachineReconciler) progressVolumeMigration(ctx context.Context, log logr.Logger, machine *api.Machine, attacher VolumeAttacher, status *api.VolumeStatus) error {
+	migration := status.Migration
+
+	if migration.Phase == api.VolumeMigrationPhaseCopying {
+		copied, total, ok, err := attacher.VolumeCopyProgress(status.Name)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			migration.CopiedBytes, migration.TotalBytes = copied, total
+			if total == 0 || copied < total {
+				log.V(2).Info("Volume copy in progress", "CopiedBytes", copied, "TotalBytes", total)
+				return nil
+			}
+
+			log.V(1).Info("Volume copy ready, pivoting")
+			if err := attacher.PivotVolume(status.Name); err != nil {
+				r.failVolumeMigration(ctx, log, machine, attacher, status, err.Error())
+				return nil
+			}
+		} else {
+			// The copy job also ends with the pivot, which may not have been persisted.
+			pivoted, err := r.volumeUsesTarget(ctx, machine, attacher, status)
+			if err != nil {
+				return err
+			}
+			if !pivoted {
+				r.failVolumeMigration(ctx, log, machine, attacher, status, "copy job ended before the pivot")
+				return nil
+			}
+		}
+		migration.Phase = api.VolumeMigrationPhasePivoted
+		migration.CopiedBytes = migration.TotalBytes
+	}
+
+	if err := attacher.DeleteUnusedVolumeSecrets(status.Name); err != nil {
+		return fmt.Errorf("error deleting secrets of source volume: %w", err)
+	}
+
+	log.V(1).Info("Deleting source volume of migration", "SourcePlugin", migration.SourcePlugin)
+	plugin, err := r.volumePluginManager.FindPluginByName(migration.SourcePlugin)
+	if err != nil {
+		return err
+	}
+	if err := plugin.Delete(ctx, status.Name, machine.ID); err != nil {
+		return fmt.Errorf("error deleting source volume: %w", err)
+	}
+
+	log.V(1).Info("Completed volume migration")
+	r.Eventf(log, machine.Metadata, corev1.EventTypeNormal, "MigratedVolume", "Migrated volume %s from %s to %s", status.Name, migration.SourcePlugin, migration.TargetPlugin)
+	status.Migration = nil
+	return nil
+}
+
+// volumeUsesTarget reports whether the domain already uses the volume of the target plugin of the migration.
+func (r *MachineReconciler) volumeUsesTarget(ctx context.Context, machine *api.Machine, attacher VolumeAttacher, status *api.VolumeStatus) (bool, error) {
+	var spec *api.VolumeSpec
+	for _, volume := range machine.Spec.Volumes {
+		if volume.Name == status.Name {
+			spec = volume
+		}
+	}
+	if spec == nil {
+		return false, nil
+	}
+
+	plugin, err := r.volumePluginManager.FindPluginByName(status.Migration.TargetPlugin)
+	if err != nil {
+		return false, err
+	}
+	target, err := plugin.Apply(ctx, spec, machine)
+	if err != nil {
+		return false, err
+	}
+	attached, err := attacher.GetVolume(status.Name)
+	if err != nil {
+		return false, err
+	}
+	return sameVolumeSource(&attached.Spec, target), nil
+}
+
+func sameVolumeSource(a, b *providervolume.Volume) bool {
+	switch {
+	case a.CephDisk != nil || b.CephDisk != nil:
+		return a.CephDisk != nil && b.CephDisk != nil && a.CephDisk.SourceName() == b.CephDisk.SourceName()
+	default:
+		return a.QCow2File == b.QCow2File && a.RawFile == b.RawFile && a.BlockDevice == b.BlockDevice
+	}
+}
+
+// failVolumeMigration cancels the migration of the volume. The domain keeps using the source volume and the
+// target volume is deleted, the migration is started again after volumeMigrationRetryDelay.
+func (r *MachineReconciler) failVolumeMigration(ctx context.Context, log logr.Logger, machine *api.Machine, attacher VolumeAttacher, status *api.VolumeStatus, message string) {
+	migration := status.Migration
+	log.V(1).Info("Volume migration failed", "Message", message)
+
+	if err := attacher.AbortVolumeCopy(status.Name); err != nil {
+		log.V(1).Info("Failed to abort volume copy", "Error", err)
+	}
+	if err := attacher.DeleteUnusedVolumeSecrets(status.Name); err != nil {
+		log.V(1).Info("Failed to delete secrets of target volume", "Error", err)
+	}
+	if plugin, err := r.volumePluginManager.FindPluginByName(migration.TargetPlugin); err != nil {
+		log.Error(err, "failed to find target plugin of volume migration")
+	} else if err := plugin.Delete(ctx, status.Name, machine.ID); err != nil {
+		log.Error(err, "failed to delete target volume of volume migration")
+	}
+
+	migration.Phase = api.VolumeMigrationPhaseFailed
+	migration.FailedAt = time.Now()
+	migration.Message = message
+	r.recordOperation(log, machine.ID, journal.OperationMigrate, status.Name, errors.New(message))
+	r.Eventf(log, machine.Metadata, corev1.EventTypeWarning, "MigrateVolumeFailed", "Failed to migrate volume %s: %s", status.Name, message)
+}
@@ -0,0 +1,125 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/pci"
+	providervolume "github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"libvirt.org/go/libvirtxml"
+)
+
+// fakeSecretExecutor keeps the secrets applied to it and the destination of the last disk copy.
+type fakeSecretExecutor struct {
+	DomainExecutor
+	secrets map[string]*libvirtxml.Secret
+	copied  *libvirtxml.DomainDisk
+}
+
+func (e *fakeSecretExecutor) AttachDisk(*libvirtxml.DomainDisk) error { return nil }
+func (e *fakeSecretExecutor) DetachDisk(*libvirtxml.DomainDisk) error { return nil }
+
+func (e *fakeSecretExecutor) ApplySecret(secret *libvirtxml.Secret, _ []byte) error {
+	e.secrets[secret.UUID] = secret
+	return nil
+}
+
+func (e *fakeSecretExecutor) DeleteSecret(secretUUID string) error {
+	delete(e.secrets, secretUUID)
+	return nil
+}
+
+func (e *fakeSecretExecutor) CopyDisk(_ string, dest *libvirtxml.DomainDisk) error {
+	e.copied = dest
+	return nil
+}
+
+var _ = Describe("Machine volume migration", func() {
+	var (
+		executor *fakeSecretExecutor
+		domain   *libvirtxml.Domain
+		attacher *libvirtVolumeAttacher
+	)
+
+	BeforeEach(func() {
+		executor = &fakeSecretExecutor{secrets: make(map[string]*libvirtxml.Secret)}
+		domain = &libvirtxml.Domain{UUID: "2a4a1d5e-4b43-4c09-a2ff-c8f5c3bb5b1a"}
+		topology, err := pci.ForDomain(domain)
+		Expect(err).NotTo(HaveOccurred())
+		topology.AddRootPort()
+
+		a, err := NewLibvirtVolumeAttacher(domain, executor, CPUPinningOptions{}, 0)
+		Expect(err).NotTo(HaveOccurred())
+		attacher = a.(*libvirtVolumeAttacher)
+	})
+
+	cephVolume := func(image string) *AttachVolume {
+		return &AttachVolume{
+			Name:   "root",
+			Device: "oda",
+			Spec: providervolume.Volume{
+				Handle: image,
+				CephDisk: &providervolume.CephDisk{
+					Name:       "pool/" + image,
+					Monitors:   []providervolume.CephMonitor{{Name: "mon", Port: "6789"}},
+					Auth:       &providervolume.CephAuthentication{UserName: "admin", UserKey: "a2V5"},
+					Encryption: &providervolume.CephEncryption{EncryptionKey: "passphrase"},
+				},
+			},
+		}
+	}
+
+	secretUUIDs := func() sets.Set[string] {
+		return sets.KeySet(executor.secrets)
+	}
+
+	pivot := func() {
+		disk, err := attacher.attachedDisk("root")
+		Expect(err).NotTo(HaveOccurred())
+		disk.Source = executor.copied.Source
+	}
+
+	It("should copy a volume with its own secrets and delete the ones of the source after the pivot", func() {
+		Expect(attacher.AttachVolume(cephVolume("source"))).To(Succeed())
+		sourceSecrets := secretUUIDs()
+		Expect(sourceSecrets).To(HaveLen(2))
+
+		By("copying the volume")
+		Expect(attacher.CopyVolume(cephVolume("target"))).To(Succeed())
+		Expect(secretUUIDs()).To(HaveLen(4))
+		targetSecrets := diskSecretUUIDs(executor.copied)
+		Expect(targetSecrets).To(HaveLen(2))
+		Expect(targetSecrets.Intersection(sourceSecrets).UnsortedList()).To(BeEmpty())
+		By("using other secret usages than the source")
+		usages := sets.New[string]()
+		for _, secret := range executor.secrets {
+			usages.Insert(secret.Usage.Name)
+		}
+		Expect(usages).To(HaveLen(4))
+
+		By("keeping the secrets of the source until the pivot")
+		Expect(attacher.DeleteUnusedVolumeSecrets("root")).To(Succeed())
+		Expect(secretUUIDs()).To(Equal(sourceSecrets))
+
+		By("pivoting to the copy")
+		Expect(attacher.CopyVolume(cephVolume("target"))).To(Succeed())
+		pivot()
+		Expect(attacher.DeleteUnusedVolumeSecrets("root")).To(Succeed())
+		Expect(secretUUIDs()).To(Equal(targetSecrets))
+
+		By("copying the volume back")
+		Expect(attacher.CopyVolume(cephVolume("source"))).To(Succeed())
+		Expect(diskSecretUUIDs(executor.copied)).To(Equal(sourceSecrets))
+	})
+
+	It("should delete the secrets of the copy and the source on detach", func() {
+		Expect(attacher.AttachVolume(cephVolume("source"))).To(Succeed())
+		Expect(attacher.CopyVolume(cephVolume("target"))).To(Succeed())
+
+		Expect(attacher.DetachVolume("root")).To(Succeed())
+		Expect(executor.secrets).To(BeEmpty())
+	})
+})
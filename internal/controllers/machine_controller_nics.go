@@ -4,6 +4,7 @@
 package controllers
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
@@ -11,19 +12,20 @@ import (
 	"reflect"
 	"strings"
 
-	"github.com/digitalocean/go-libvirt"
 	"github.com/go-logr/logr"
 	"github.com/ironcore-dev/libvirt-provider/api"
 	providerhost "github.com/ironcore-dev/libvirt-provider/internal/host"
+	"github.com/ironcore-dev/libvirt-provider/internal/journal"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/alias"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/nwfilter"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/pci"
 	providernetworkinterface "github.com/ironcore-dev/libvirt-provider/internal/plugins/networkinterface"
+	"github.com/ironcore-dev/libvirt-provider/internal/retrypolicy"
+	"github.com/ironcore-dev/libvirt-provider/internal/windows"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"libvirt.org/go/libvirtxml"
 )
 
-var (
-	errNoNetworkInterfaceAlias = errors.New("no network interface alias")
-)
-
 func (r *MachineReconciler) deleteNetworkInterfaces(ctx context.Context, log logr.Logger, machine *api.Machine) error {
 	machineNetworkInterfaces, err := providerhost.ReadMachineNetworkInterfaces(r.host, machine.ID)
 	if err != nil {
@@ -36,7 +38,7 @@ func (r *MachineReconciler) deleteNetworkInterfaces(ctx context.Context, log log
 	}
 
 	for _, machineNic := range machineNetworkInterfaces {
-		if err := r.networkInterfacePlugin.Delete(ctx, machineNic.NetworkInterfaceName, machine.ID); err != nil {
+		if err := r.deleteNetworkInterface(ctx, machine, machineNic); err != nil {
 			return fmt.Errorf("[machine network interface %s] error deleting: %w", machineNic.NetworkInterfaceName, err)
 		}
 	}
@@ -54,7 +56,7 @@ func (r *MachineReconciler) setDomainNetworkInterfaces(
 	machine *api.Machine,
 	domainDesc *libvirtxml.Domain,
 ) ([]api.NetworkInterfaceStatus, error) {
-	machineNics, err := providerhost.ReadMachineNetworkInterfaces(r.host, machine.ID)
+	machineNics, err := r.listMachineNetworkInterfaces(machine.ID)
 	if err != nil {
 		return nil, err
 	}
@@ -66,12 +68,24 @@ func (r *MachineReconciler) setDomainNetworkInterfaces(
 	for _, nic := range machine.Spec.NetworkInterfaces {
 		specNicNames.Insert(nic.Name)
 
-		providerNic, err := r.networkInterfacePlugin.Apply(ctx, nic, machine)
+		plugin, err := r.networkInterfacePlugins.FindPluginBySpec(nic)
 		if err != nil {
 			return nil, fmt.Errorf("[network interface %s] %w", nic.Name, err)
 		}
+		if err := r.recordNetworkInterfacePlugin(ctx, machine, machineNics, nic, plugin); err != nil {
+			return nil, fmt.Errorf("[network interface %s] %w", nic.Name, err)
+		}
+		providerNic, err := plugin.Apply(ctx, nic, machine)
+		if err != nil {
+			return nil, fmt.Errorf("[network interface %s] %w", nic.Name, err)
+		}
+		r.setDefaultNetworkInterfaceModel(machine, plugin, providerNic)
+		r.setNetworkInterfaceMACAddress(machine, plugin, nic, providerNic)
+		if err := r.applyNetworkInterfaceFilter(machine, nic, providerNic); err != nil {
+			return nil, fmt.Errorf("[network interface %s] %w", nic.Name, err)
+		}
 
-		libvirtNic, err := providerNetworkInterfaceToLibvirt(nic.Name, providerNic)
+		libvirtNic, err := providerNetworkInterfaceToLibvirt(nic.Name, providerNic, r.virtioQueues(machine).nic)
 		if err != nil {
 			return nil, fmt.Errorf("[network interface %s] %w", nic.Name, err)
 		}
@@ -86,19 +100,21 @@ func (r *MachineReconciler) setDomainNetworkInterfaces(
 		}
 
 		states = append(states, api.NetworkInterfaceStatus{
-			Name:   nic.Name,
-			Handle: providerNic.Handle,
-			State:  api.NetworkInterfaceStateAttached,
+			Name:       nic.Name,
+			Handle:     providerNic.Handle,
+			State:      api.NetworkInterfaceStateAttached,
+			MACAddress: providerNic.MACAddress,
+			IPFamilies: providerNic.IPFamilies,
 		})
 	}
 
-	for _, machineNic := range machineNics {
-		if specNicNames.Has(machineNic.NetworkInterfaceName) {
+	for nicName, machineNic := range machineNics {
+		if specNicNames.Has(nicName) {
 			continue
 		}
 
-		if err := r.networkInterfacePlugin.Delete(ctx, machineNic.NetworkInterfaceName, machine.ID); err != nil {
-			return nil, fmt.Errorf("[network interface %s] %w", machineNic.NetworkInterfaceName, err)
+		if err := r.deleteNetworkInterface(ctx, machine, machineNic); err != nil {
+			return nil, fmt.Errorf("[network interface %s] %w", nicName, err)
 		}
 	}
 	return states, nil
@@ -126,8 +142,6 @@ func (r *MachineReconciler) attachDetachNetworkInterfaces(
 	machine *api.Machine,
 	domainDesc *libvirtxml.Domain,
 ) ([]api.NetworkInterfaceStatus, error) {
-	domain := machineDomain(machine.ID)
-
 	machineNicByName, err := r.listMachineNetworkInterfaces(machine.ID)
 	if err != nil {
 		return nil, err
@@ -140,9 +154,14 @@ func (r *MachineReconciler) attachDetachNetworkInterfaces(
 
 	desiredNics := r.desiredNetworkInterfaces(machine)
 
+	topology, err := pci.ForDomain(domainDesc)
+	if err != nil {
+		return nil, err
+	}
+
 	var (
-		nicStates []api.NetworkInterfaceStatus
-		errs      []error
+		nicStates       []api.NetworkInterfaceStatus
+		attachDetachErr = newAttachDetachError(deviceKindNetworkInterface)
 	)
 
 	for nicName, actualNic := range mountedNics {
@@ -150,27 +169,43 @@ func (r *MachineReconciler) attachDetachNetworkInterfaces(
 			continue
 		}
 
-		log.V(1).Info("Detaching network interface", "NetworkInterfaceName", nicName)
-		if err := r.detachDomainDevice(domain, actualNic.libvirt.device()); err != nil {
-			errs = append(errs, fmt.Errorf("[network interface %s] error detaching: %w", nicName, err))
+		log.V(2).Info("Detaching network interface", "NetworkInterfaceName", nicName)
+		err := r.detachDomainDevice(ctx, machine.ID, actualNic.libvirt)
+		if r.requeueDevicePending(log, machine.ID, nicName, err) {
+			// The network interface isn't torn down until the guest released it.
+			continue
+		}
+		r.recordOperation(log, machine.ID, journal.OperationDetach, nicName, err)
+		if err != nil {
+			attachDetachErr.add(nicName, "detaching", err)
 		} else {
-			log.V(1).Info("Successfully detached network interface", "NetworkInterfaceName", nicName)
+			log.V(2).Info("Successfully detached network interface", "NetworkInterfaceName", nicName)
+			topology.Release(actualNic.libvirt.address())
 			delete(mountedNics, nicName)
 		}
 	}
 
 	for nicName, desiredNic := range desiredNics {
-		log.V(1).Info("Reconciling desired network interface", "NetworkInterfaceName", nicName)
-		mountedNic, err := r.reconcileDesiredNetworkInterface(ctx, machine, domain, mountedNics, desiredNic)
+		log.V(2).Info("Reconciling desired network interface", "NetworkInterfaceName", nicName)
+		mountedNic, err := r.reconcileDesiredNetworkInterface(ctx, log, machine, topology, mountedNics, machineNicByName, desiredNic)
+		if r.requeueDevicePending(log, machine.ID, nicName, err) {
+			continue
+		}
 		if err != nil {
-			errs = append(errs, fmt.Errorf("[network interface %s] error reconciling: %w", nicName, err))
+			attachDetachErr.add(nicName, "reconciling", err)
 		} else {
-			log.V(1).Info("Successfully reconciled desired network interface", "NetworkInterfaceName", nicName)
+			log.V(2).Info("Successfully reconciled desired network interface", "NetworkInterfaceName", nicName)
 			mountedNics[nicName] = *mountedNic
+			state := api.NetworkInterfaceStateAttached
+			if r.devicePending(log, machine.ID, alias.NetworkInterface(nicName)) {
+				state = api.NetworkInterfaceStatePending
+			}
 			nicStates = append(nicStates, api.NetworkInterfaceStatus{
-				Name:   nicName,
-				Handle: mountedNic.networkInterface.Handle,
-				State:  api.NetworkInterfaceStateAttached,
+				Name:       nicName,
+				Handle:     mountedNic.networkInterface.Handle,
+				State:      state,
+				MACAddress: mountedNic.networkInterface.MACAddress,
+				IPFamilies: mountedNic.networkInterface.IPFamilies,
 			})
 		}
 	}
@@ -180,60 +215,206 @@ func (r *MachineReconciler) attachDetachNetworkInterfaces(
 			continue
 		}
 
-		log.V(1).Info("Tearing down network interface", "NetworkInterfaceName", nicName)
+		log.V(2).Info("Tearing down network interface", "NetworkInterfaceName", nicName)
 		if err := r.deleteNetworkInterface(ctx, machine, machineNic); err != nil {
-			errs = append(errs, fmt.Errorf("[network interface %s] error deleting: %w", nicName, err))
+			attachDetachErr.add(nicName, "deleting", err)
 		} else {
-			log.V(1).Info("Successfully torn down network interface", "NetworkInterfaceName", nicName)
+			log.V(2).Info("Successfully torn down network interface", "NetworkInterfaceName", nicName)
 		}
 	}
 
-	if len(errs) > 0 {
-		return nil, fmt.Errorf("attach / detach error(s): %v", errs)
+	if attachDetachErr.len() > 0 {
+		return nil, attachDetachErr
 	}
 	return nicStates, nil
 }
 
+// networkInterfacePluginChanged reports whether the network interface was applied with another plugin than the
+// given one. Network interfaces recorded without plugin were applied with the default plugin.
+func (r *MachineReconciler) networkInterfacePluginChanged(machineNic providerhost.MachineNetworkInterface, plugin providernetworkinterface.Plugin) bool {
+	return cmp.Or(machineNic.PluginName, r.networkInterfacePlugins.DefaultPlugin().Name()) != plugin.Name()
+}
+
+// recordNetworkInterfacePlugin records the plugin the network interface is applied with, so the network interface
+// is deleted by the same plugin after it was removed from the machine spec. A network interface recorded with
+// another plugin is deleted by that plugin first, so its resources don't leak and the selected plugin doesn't update
+// a network interface it never applied. Mounted network interfaces have to be detached before.
+func (r *MachineReconciler) recordNetworkInterfacePlugin(
+	ctx context.Context,
+	machine *api.Machine,
+	machineNics map[string]providerhost.MachineNetworkInterface,
+	nic *api.NetworkInterfaceSpec,
+	plugin providernetworkinterface.Plugin,
+) error {
+	if machineNic, ok := machineNics[nic.Name]; ok && r.networkInterfacePluginChanged(machineNic, plugin) {
+		previous, err := r.networkInterfacePlugins.FindPluginByName(machineNic.PluginName)
+		if err != nil {
+			return err
+		}
+		if err := previous.Delete(ctx, nic.Name, machine.ID); err != nil {
+			return fmt.Errorf("error deleting network interface of plugin %s: %w", previous.Name(), err)
+		}
+		if err := os.RemoveAll(r.host.MachineNetworkInterfaceDir(machine.ID, nic.Name)); err != nil {
+			return err
+		}
+	}
+
+	if err := providerhost.WriteMachineNetworkInterfacePlugin(r.host, machine.ID, nic.Name, plugin.Name()); err != nil {
+		return err
+	}
+	machineNics[nic.Name] = providerhost.MachineNetworkInterface{
+		NetworkInterfaceName: nic.Name,
+		PluginName:           plugin.Name(),
+	}
+	return nil
+}
+
+// setDefaultNetworkInterfaceModel sets the model of Windows guests on emulated network interfaces without
+// explicit model.
+func (r *MachineReconciler) setDefaultNetworkInterfaceModel(machine *api.Machine, plugin providernetworkinterface.Plugin, nic *providernetworkinterface.NetworkInterface) {
+	if nic.Model == "" && plugin.Capabilities().Models {
+		nic.Model = windows.NetworkInterfaceModel(machine.Spec.Windows)
+	}
+}
+
+// setNetworkInterfaceMACAddress sets the MAC address of the spec, or the one generated for the network
+// interface, on network interfaces whose MAC address can be chosen.
+func (r *MachineReconciler) setNetworkInterfaceMACAddress(machine *api.Machine, plugin providernetworkinterface.Plugin, spec *api.NetworkInterfaceSpec, nic *providernetworkinterface.NetworkInterface) {
+	if nic.MACAddress != "" || !plugin.Capabilities().MACAddresses {
+		return
+	}
+
+	nic.MACAddress = spec.MACAddress
+	if nic.MACAddress == "" {
+		nic.MACAddress = providernetworkinterface.GenerateMACAddress(machine.ID, spec.Name)
+	}
+}
+
+// applyNetworkInterfaceFilter defines the nwfilter restricting the traffic of emulated network interfaces of
+// provider networks to their MAC address and IPs. Isolated network interfaces use user networking, which libvirt
+// can't filter.
+func (r *MachineReconciler) applyNetworkInterfaceFilter(machine *api.Machine, spec *api.NetworkInterfaceSpec, nic *providernetworkinterface.NetworkInterface) error {
+	if !r.networkInterfaceFilters || nic.ProviderNetwork == nil {
+		return nil
+	}
+
+	filter := nwfilter.Filter(machine.ID, spec.Name, nic.MACAddress, spec.Ips)
+	if err := nwfilter.Apply(r.conn(), filter); err != nil {
+		return err
+	}
+	nic.Filter = filter.Name
+	return nil
+}
+
 func (r *MachineReconciler) deleteNetworkInterface(
 	ctx context.Context,
 	machine *api.Machine,
 	nic providerhost.MachineNetworkInterface,
 ) error {
-	return r.networkInterfacePlugin.Delete(ctx, nic.NetworkInterfaceName, machine.ID)
+	plugin, err := r.networkInterfacePlugins.FindPluginByName(nic.PluginName)
+	if err != nil {
+		return err
+	}
+	if err := plugin.Delete(ctx, nic.NetworkInterfaceName, machine.ID); err != nil {
+		return err
+	}
+	// Plugins not keeping files of the network interface leave its directory with the recorded plugin behind.
+	if err := os.RemoveAll(r.host.MachineNetworkInterfaceDir(machine.ID, nic.NetworkInterfaceName)); err != nil {
+		return err
+	}
+	// Filters are deleted regardless of whether they are enabled, to clean up after disabling them.
+	return nwfilter.Delete(r.conn(), nwfilter.Name(machine.ID, nic.NetworkInterfaceName))
 }
 
 func (r *MachineReconciler) reconcileDesiredNetworkInterface(
 	ctx context.Context,
+	log logr.Logger,
 	machine *api.Machine,
-	domain libvirt.Domain,
+	topology *pci.Topology,
 	mountedNics map[string]mountedNetworkInterface,
+	machineNics map[string]providerhost.MachineNetworkInterface,
 	nic *api.NetworkInterfaceSpec,
 ) (*mountedNetworkInterface, error) {
-	providerNic, err := r.networkInterfacePlugin.Apply(ctx, nic, machine)
+	mountedNic, ok := mountedNics[nic.Name]
+
+	plugin, err := r.networkInterfacePlugins.FindPluginBySpec(nic)
 	if err != nil {
 		return nil, err
 	}
+	if machineNic, recorded := machineNics[nic.Name]; ok && recorded && r.networkInterfacePluginChanged(machineNic, plugin) {
+		// The network interface selects another plugin than it was attached with, the device is re-attached once
+		// the selected plugin applied it.
+		if err := r.detachDomainDevice(ctx, machine.ID, mountedNic.libvirt); err != nil {
+			return nil, err
+		}
+		topology.Release(mountedNic.libvirt.address())
+		delete(mountedNics, nic.Name)
+		ok = false
+	}
+	if err := r.recordNetworkInterfacePlugin(ctx, machine, machineNics, nic, plugin); err != nil {
+		return nil, err
+	}
+
+	var providerNic *providernetworkinterface.NetworkInterface
+	if ok && plugin.Capabilities().UpdateInPlace {
+		providerNic, err = plugin.Update(ctx, nic, machine)
+	} else {
+		providerNic, err = plugin.Apply(ctx, nic, machine)
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.setDefaultNetworkInterfaceModel(machine, plugin, providerNic)
+	r.setNetworkInterfaceMACAddress(machine, plugin, nic, providerNic)
 
-	mountedNic, ok := mountedNics[nic.Name]
 	if ok {
 		mountedNic.networkInterface.Handle = providerNic.Handle
+		// The addresses aren't part of the domain, changing them doesn't require re-attaching the device.
+		mountedNic.networkInterface.IPFamilies = providerNic.IPFamilies
+		if providerNic.Model == "" {
+			// Network interfaces without model keep the model the hypervisor chose.
+			providerNic.Model = mountedNic.networkInterface.Model
+		}
+		if nic.MACAddress == "" && mountedNic.networkInterface.MACAddress != "" {
+			// Network interfaces without MAC address keep the one they were attached with, so the guest keeps
+			// its DHCP lease.
+			providerNic.MACAddress = mountedNic.networkInterface.MACAddress
+		}
+	}
+	// The filter is applied with the final MAC address. Updating the filter of a mounted network interface takes
+	// effect without re-attaching it.
+	if err := r.applyNetworkInterfaceFilter(machine, nic, providerNic); err != nil {
+		return nil, err
+	}
+
+	if ok {
 		if reflect.DeepEqual(mountedNic.networkInterface, providerNic) {
 			return &mountedNic, nil
 		}
 
-		if err := r.detachDomainDevice(domain, mountedNic.libvirt.device()); err != nil {
+		if err := r.detachDomainDevice(ctx, machine.ID, mountedNic.libvirt); err != nil {
 			return nil, err
 		}
+		topology.Release(mountedNic.libvirt.address())
+	}
+
+	libvirtNic, err := providerNetworkInterfaceToLibvirt(nic.Name, providerNic, r.virtioQueues(machine).nic)
+	if err != nil {
+		return nil, err
 	}
 
-	libvirtNic, err := providerNetworkInterfaceToLibvirt(nic.Name, providerNic)
+	addr, err := topology.Assign()
 	if err != nil {
 		return nil, err
 	}
+	libvirtNic.setAddress(addr)
 
-	if err := r.attachDomainDevice(domain, libvirtNic.device()); err != nil {
+	if err := r.attachDomainDevice(ctx, machine.ID, libvirtNic); err != nil {
+		topology.Release(addr)
+		r.recordOperation(log, machine.ID, journal.OperationAttach, nic.Name, err)
 		return nil, fmt.Errorf("error attaching network interface device: %w", err)
 	}
+	r.recordOperation(log, machine.ID, journal.OperationAttach, nic.Name, nil)
 	return &mountedNetworkInterface{
 		networkInterface: providerNic,
 		libvirt:          libvirtNic,
@@ -263,6 +444,40 @@ type libvirtNetworkInterface struct {
 	iface   *libvirtxml.DomainInterface
 }
 
+func (i *libvirtNetworkInterface) address() *libvirtxml.DomainAddress {
+	switch {
+	case i.hostDev != nil:
+		return i.hostDev.Address
+	case i.iface != nil:
+		return i.iface.Address
+	default:
+		return nil
+	}
+}
+
+func (i *libvirtNetworkInterface) setAddress(addr *libvirtxml.DomainAddress) {
+	switch {
+	case i.hostDev != nil:
+		i.hostDev.Address = addr
+	case i.iface != nil:
+		i.iface.Address = addr
+	}
+}
+
+func (i *libvirtNetworkInterface) alias() string {
+	var domainAlias *libvirtxml.DomainAlias
+	switch {
+	case i.hostDev != nil:
+		domainAlias = i.hostDev.Alias
+	case i.iface != nil:
+		domainAlias = i.iface.Alias
+	}
+	if domainAlias == nil {
+		return ""
+	}
+	return domainAlias.Name
+}
+
 func (i *libvirtNetworkInterface) device() libvirtxml.Document {
 	switch {
 	case i.hostDev != nil:
@@ -277,11 +492,11 @@ func (i *libvirtNetworkInterface) device() libvirtxml.Document {
 func (r *MachineReconciler) computeMountedNetworkInterfaces(domainDesc *libvirtxml.Domain) (map[string]mountedNetworkInterface, error) {
 	res := make(map[string]mountedNetworkInterface)
 	for _, hostDev := range domainDescHostDevices(domainDesc) {
-		if hostDev.Alias == nil || !strings.HasPrefix(hostDev.Alias.Name, networkInterfaceAliasPrefix) {
+		if hostDev.Alias == nil || !alias.IsNetworkInterface(hostDev.Alias.Name) {
 			continue
 		}
 
-		name, err := parseNetworkInterfaceAlias(hostDev.Alias.Name)
+		name, err := alias.ParseNetworkInterface(hostDev.Alias.Name)
 		if err != nil {
 			return nil, err
 		}
@@ -300,11 +515,11 @@ func (r *MachineReconciler) computeMountedNetworkInterfaces(domainDesc *libvirtx
 		}
 	}
 	for _, iface := range domainDescInterfaces(domainDesc) {
-		if iface.Alias == nil || !strings.HasPrefix(iface.Alias.Name, networkInterfaceAliasPrefix) {
+		if iface.Alias == nil || !alias.IsNetworkInterface(iface.Alias.Name) {
 			continue
 		}
 
-		name, err := parseNetworkInterfaceAlias(iface.Alias.Name)
+		name, err := alias.ParseNetworkInterface(iface.Alias.Name)
 		if err != nil {
 			return nil, err
 		}
@@ -347,27 +562,14 @@ func (r *MachineReconciler) desiredNetworkInterfaces(machine *api.Machine) map[s
 	return res
 }
 
-func (r *MachineReconciler) attachDomainDevice(domain libvirt.Domain, dev libvirtxml.Document) error {
-	data, err := dev.Marshal()
-	if err != nil {
-		return err
-	}
-	return r.libvirt.DomainAttachDevice(domain, data)
+func (r *MachineReconciler) attachDomainDevice(ctx context.Context, machineID string, nic *libvirtNetworkInterface) error {
+	conn, abort := r.mutatingConn()
+	return attachDevice(ctx, conn, abort, r.libvirtCallTimeout, r.hotplug, machineID, nic.alias(), nic.device())
 }
 
-func (r *MachineReconciler) detachDomainDevice(domain libvirt.Domain, dev libvirtxml.Document) error {
-	data, err := dev.Marshal()
-	if err != nil {
-		return err
-	}
-	return r.libvirt.DomainDetachDevice(domain, data)
-}
-
-func parseNetworkInterfaceAlias(alias string) (string, error) {
-	if !strings.HasPrefix(alias, networkInterfaceAliasPrefix) {
-		return "", errNoNetworkInterfaceAlias
-	}
-	return strings.TrimPrefix(alias, networkInterfaceAliasPrefix), nil
+func (r *MachineReconciler) detachDomainDevice(ctx context.Context, machineID string, nic *libvirtNetworkInterface) error {
+	conn, abort := r.mutatingConn()
+	return detachDevice(ctx, conn, abort, r.libvirtCallTimeout, r.hotplug, machineID, nic.alias(), nic.device())
 }
 
 func libvirtHostdevToProviderNetworkInterface(hostDev *libvirtxml.DomainHostdev) (*providernetworkinterface.NetworkInterface, error) {
@@ -403,33 +605,49 @@ func libvirtInterfaceToProviderNetworkInterface(iface *libvirtxml.DomainInterfac
 		return nil, fmt.Errorf("no interface source specified")
 	}
 
+	var model string
+	if iface.Model != nil {
+		model = iface.Model.Type
+	}
+
+	var macAddress string
+	if iface.MAC != nil {
+		macAddress = strings.ToLower(iface.MAC.Address)
+	}
+
+	var filter string
+	if iface.FilterRef != nil {
+		filter = iface.FilterRef.Filter
+	}
+
 	switch {
 	case src.User != nil:
 		return &providernetworkinterface.NetworkInterface{
-			Isolated: &providernetworkinterface.Isolated{},
+			Isolated:   &providernetworkinterface.Isolated{},
+			Model:      model,
+			MACAddress: macAddress,
 		}, nil
 	case src.Network != nil:
 		return &providernetworkinterface.NetworkInterface{
 			ProviderNetwork: &providernetworkinterface.ProviderNetwork{
 				NetworkName: src.Network.Network,
 			},
+			Model:      model,
+			MACAddress: macAddress,
+			Filter:     filter,
 		}, nil
 	default:
 		return nil, fmt.Errorf("invalid network source")
 	}
 }
 
-func networkInterfaceAlias(name string) string {
-	return fmt.Sprintf("%s%s", networkInterfaceAliasPrefix, name)
-}
-
-func providerNetworkInterfaceToLibvirt(name string, nic *providernetworkinterface.NetworkInterface) (*libvirtNetworkInterface, error) {
+func providerNetworkInterfaceToLibvirt(name string, nic *providernetworkinterface.NetworkInterface, queues uint) (*libvirtNetworkInterface, error) {
 	switch {
 	case nic.HostDevice != nil:
 		return &libvirtNetworkInterface{
 			hostDev: &libvirtxml.DomainHostdev{
 				Alias: &libvirtxml.DomainAlias{
-					Name: networkInterfaceAlias(name),
+					Name: alias.NetworkInterface(name),
 				},
 				Managed: "yes",
 				SubsysPCI: &libvirtxml.DomainHostdevSubsysPCI{
@@ -450,29 +668,46 @@ func providerNetworkInterfaceToLibvirt(name string, nic *providernetworkinterfac
 		}, nil
 	case nic.Isolated != nil:
 		return &libvirtNetworkInterface{
-			iface: &libvirtxml.DomainInterface{
+			iface: setInterfaceModel(&libvirtxml.DomainInterface{
 				Alias: &libvirtxml.DomainAlias{
-					Name: networkInterfaceAlias(name),
+					Name: alias.NetworkInterface(name),
 				},
 				Source: &libvirtxml.DomainInterfaceSource{
 					User: &libvirtxml.DomainInterfaceSourceUser{},
 				},
-			},
+				MAC: interfaceMAC(nic.MACAddress),
+			}, nic.Model, queues),
 		}, nil
 	case nic.ProviderNetwork != nil:
 		return &libvirtNetworkInterface{
-			iface: &libvirtxml.DomainInterface{
+			iface: setInterfaceModel(&libvirtxml.DomainInterface{
 				Alias: &libvirtxml.DomainAlias{
-					Name: networkInterfaceAlias(name),
+					Name: alias.NetworkInterface(name),
 				},
 				Source: &libvirtxml.DomainInterfaceSource{
 					Network: &libvirtxml.DomainInterfaceSourceNetwork{
 						Network: nic.ProviderNetwork.NetworkName,
 					},
 				},
-			},
+				MAC:       interfaceMAC(nic.MACAddress),
+				FilterRef: interfaceFilterRef(nic.Filter),
+			}, nic.Model, queues),
 		}, nil
 	default:
-		return nil, fmt.Errorf("unsupported provider network interface: %#+v", nic)
+		return nil, retrypolicy.Permanent(fmt.Errorf("unsupported provider network interface: %#+v", nic))
+	}
+}
+
+func interfaceMAC(macAddress string) *libvirtxml.DomainInterfaceMAC {
+	if macAddress == "" {
+		return nil
+	}
+	return &libvirtxml.DomainInterfaceMAC{Address: macAddress}
+}
+
+func interfaceFilterRef(filter string) *libvirtxml.DomainInterfaceFilterRef {
+	if filter == "" {
+		return nil
 	}
+	return nwfilter.FilterRef(filter)
 }
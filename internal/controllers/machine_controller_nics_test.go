@@ -0,0 +1,116 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"context"
+	"os"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	providerhost "github.com/ironcore-dev/libvirt-provider/internal/host"
+	providernetworkinterface "github.com/ironcore-dev/libvirt-provider/internal/plugins/networkinterface"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"libvirt.org/go/libvirtxml"
+)
+
+// fakeNetworkInterfacePlugin creates isolated network interfaces and records the network interfaces it applied and
+// deleted.
+type fakeNetworkInterfacePlugin struct {
+	name    string
+	applied []string
+	deleted []string
+}
+
+func (p *fakeNetworkInterfacePlugin) Name() string                      { return p.name }
+func (p *fakeNetworkInterfacePlugin) Init(providerhost.Host) error      { return nil }
+func (p *fakeNetworkInterfacePlugin) HealthCheck(context.Context) error { return nil }
+
+func (p *fakeNetworkInterfacePlugin) Capabilities() providernetworkinterface.Capabilities {
+	return providernetworkinterface.Capabilities{UpdateInPlace: true}
+}
+
+func (p *fakeNetworkInterfacePlugin) Apply(_ context.Context, spec *api.NetworkInterfaceSpec, _ *api.Machine) (*providernetworkinterface.NetworkInterface, error) {
+	p.applied = append(p.applied, spec.Name)
+	return &providernetworkinterface.NetworkInterface{Isolated: &providernetworkinterface.Isolated{}}, nil
+}
+
+func (p *fakeNetworkInterfacePlugin) Update(ctx context.Context, spec *api.NetworkInterfaceSpec, machine *api.Machine) (*providernetworkinterface.NetworkInterface, error) {
+	return p.Apply(ctx, spec, machine)
+}
+
+func (p *fakeNetworkInterfacePlugin) Delete(_ context.Context, computeNicName string, _ string) error {
+	p.deleted = append(p.deleted, computeNicName)
+	return nil
+}
+
+var _ = Describe("Machine network interfaces", func() {
+	var (
+		r                *MachineReconciler
+		host             providerhost.Host
+		defaultPlugin    *fakeNetworkInterfacePlugin
+		additionalPlugin *fakeNetworkInterfacePlugin
+		machine          *api.Machine
+	)
+
+	BeforeEach(func() {
+		var err error
+		host, err = providerhost.NewAt(GinkgoT().TempDir())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(providerhost.MakeMachineDirs(host, "foo")).To(Succeed())
+
+		defaultPlugin = &fakeNetworkInterfacePlugin{name: "default"}
+		additionalPlugin = &fakeNetworkInterfacePlugin{name: "additional"}
+		plugins := providernetworkinterface.NewPluginManager()
+		Expect(plugins.InitPlugins(host, []providernetworkinterface.Plugin{defaultPlugin, additionalPlugin})).To(Succeed())
+
+		r = &MachineReconciler{host: host, networkInterfacePlugins: plugins}
+		machine = &api.Machine{
+			Metadata: api.Metadata{ID: "foo"},
+			Spec: api.MachineSpec{NetworkInterfaces: []*api.NetworkInterfaceSpec{{
+				Name:       "nic-1",
+				Attributes: map[string]string{providernetworkinterface.AttributePluginKey: "additional"},
+			}}},
+		}
+	})
+
+	recordedPlugin := func() string {
+		data, err := os.ReadFile(host.MachineNetworkInterfacePluginFile("foo", "nic-1"))
+		Expect(err).NotTo(HaveOccurred())
+		return string(data)
+	}
+
+	It("should record the plugin a network interface is applied with", func(ctx SpecContext) {
+		_, err := r.setDomainNetworkInterfaces(ctx, machine, &libvirtxml.Domain{})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(additionalPlugin.applied).To(ConsistOf("nic-1"))
+		Expect(defaultPlugin.deleted).To(BeEmpty())
+		Expect(recordedPlugin()).To(Equal("additional"))
+	})
+
+	It("should delete a network interface by its previous plugin when it selects another plugin", func(ctx SpecContext) {
+		Expect(providerhost.WriteMachineNetworkInterfacePlugin(host, "foo", "nic-1", "default")).To(Succeed())
+
+		_, err := r.setDomainNetworkInterfaces(ctx, machine, &libvirtxml.Domain{})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(defaultPlugin.deleted).To(ConsistOf("nic-1"))
+		Expect(additionalPlugin.deleted).To(BeEmpty())
+		Expect(additionalPlugin.applied).To(ConsistOf("nic-1"))
+		Expect(recordedPlugin()).To(Equal("additional"))
+	})
+
+	It("should keep network interfaces recorded without plugin on the default plugin", func(ctx SpecContext) {
+		Expect(providerhost.WriteMachineNetworkInterfacePlugin(host, "foo", "nic-1", "")).To(Succeed())
+		machine.Spec.NetworkInterfaces[0].Attributes = nil
+
+		_, err := r.setDomainNetworkInterfaces(ctx, machine, &libvirtxml.Domain{})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(defaultPlugin.deleted).To(BeEmpty())
+		Expect(defaultPlugin.applied).To(ConsistOf("nic-1"))
+		Expect(recordedPlugin()).To(Equal("default"))
+	})
+})
@@ -0,0 +1,135 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/pcidevice"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"libvirt.org/go/libvirtxml"
+)
+
+// restorePCIDeviceClaims restores the claims of the PCI devices recorded in the machine status, so they aren't
+// claimed by other machines after a restart.
+func (r *MachineReconciler) restorePCIDeviceClaims(ctx context.Context, log logr.Logger) error {
+	machines, err := r.machines.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list machines: %w", err)
+	}
+
+	for _, machine := range machines {
+		if err := r.pciDevices.Restore(machine.ID, machine.Status.PCIDevices); err != nil {
+			log.Error(err, "failed to restore pci device claims", "machineID", machine.ID)
+		}
+	}
+	return nil
+}
+
+// setDomainPCIDevices binds the PCI devices claimed by the machine when it was placed, see setDomainPlacement, to
+// the vfio-pci driver and passes them through to the domain. The claimed devices are kept across restarts of the
+// domain.
+func (r *MachineReconciler) setDomainPCIDevices(machine *api.Machine, domain *libvirtxml.Domain) error {
+	if len(machine.Spec.PCIDevices) == 0 || len(machine.Status.PCIDevices) == 0 {
+		return nil
+	}
+
+	for _, device := range machine.Status.PCIDevices {
+		if err := r.pciDevices.BindVFIO(device.Address); err != nil {
+			return fmt.Errorf("error binding pci device %s to %s: %w", device.Address, pcidevice.VFIODriver, err)
+		}
+
+		hostdev, err := pcidevice.Hostdev(device.Address)
+		if err != nil {
+			return err
+		}
+		domain.Devices.Hostdevs = append(domain.Devices.Hostdevs, hostdev)
+	}
+	return nil
+}
+
+// releasePCIDevices returns the PCI devices of the deleted machine to their host drivers and releases them.
+func (r *MachineReconciler) releasePCIDevices(log logr.Logger, machine *api.Machine) error {
+	if r.pciDevices == nil || len(machine.Status.PCIDevices) == 0 {
+		return nil
+	}
+
+	for _, device := range machine.Status.PCIDevices {
+		log.V(1).Info("Unbinding pci device", "Address", device.Address)
+		if err := r.pciDevices.UnbindVFIO(device.Address); err != nil {
+			return err
+		}
+	}
+	r.pciDevices.Release(machine.ID)
+	return nil
+}
+
+// startCheckPCIDeviceHealth periodically checks the health of the PCI devices passed through to running machines.
+func (r *MachineReconciler) startCheckPCIDeviceHealth(ctx context.Context, log logr.Logger) {
+	if r.pciDevices == nil || r.resyncIntervalPCIDeviceHealth == 0 {
+		log.V(1).Info("pci device health check loop is disabled")
+		return
+	}
+
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		log.V(1).Info("starting pci device health check loop")
+		r.checkPCIDeviceHealth(ctx, log)
+	}, r.resyncIntervalPCIDeviceHealth)
+}
+
+// checkPCIDeviceHealth checks the PCI devices of the running machines. Failing devices are marked unhealthy, so
+// they aren't claimed by other machines, and their machine gets a warning event. Unhealthy devices not passed
+// through anymore are checked again until they recover.
+func (r *MachineReconciler) checkPCIDeviceHealth(ctx context.Context, log logr.Logger) {
+	machines, err := r.machines.List(ctx)
+	if err != nil {
+		log.Error(err, "failed to list machines")
+		return
+	}
+
+	checked := sets.New[string]()
+	for _, machine := range machines {
+		if isTerminating(machine) || machine.Status.State != api.MachineStateRunning {
+			continue
+		}
+
+		for _, device := range machine.Status.PCIDevices {
+			checked.Insert(device.Address)
+			if err := r.pciDevices.Check(device.Address, true); err != nil {
+				if !errors.Is(err, pcidevice.ErrUnhealthy) {
+					log.Error(err, "failed to check pci device", "machineID", machine.ID, "Address", device.Address)
+					continue
+				}
+				if r.pciDevices.MarkUnhealthy(device, err) {
+					r.Eventf(log, machine.Metadata, corev1.EventTypeWarning, "PCIDeviceUnhealthy", "PCI device %s of pool %s is unhealthy: %s", device.Address, device.Pool, err)
+				}
+				continue
+			}
+			if r.pciDevices.MarkHealthy(device.Address) {
+				r.Eventf(log, machine.Metadata, corev1.EventTypeNormal, "PCIDeviceRecovered", "PCI device %s of pool %s is healthy again", device.Address, device.Pool)
+			}
+		}
+	}
+
+	for _, device := range r.pciDevices.Unhealthy() {
+		if checked.Has(device.Address) {
+			continue
+		}
+		if err := r.pciDevices.Check(device.Address, false); err != nil {
+			if !errors.Is(err, pcidevice.ErrUnhealthy) {
+				log.Error(err, "failed to check pci device", "Address", device.Address)
+			}
+			continue
+		}
+		if r.pciDevices.MarkHealthy(device.Address) {
+			log.Info("PCI device is healthy again", "Pool", device.Pool, "Address", device.Address)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/go-logr/logr"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/host"
+	"github.com/ironcore-dev/libvirt-provider/internal/pcidevice"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Machine pci devices", func() {
+	It("should mark the unhealthy pci devices of running machines and emit events", func(ctx SpecContext) {
+		root := GinkgoT().TempDir()
+		vfioDir := filepath.Join(root, "bus", "pci", "drivers", pcidevice.VFIODriver)
+		Expect(os.MkdirAll(vfioDir, 0755)).To(Succeed())
+		for _, address := range []string{"0000:3b:00.0", "0000:3c:00.0"} {
+			dir := filepath.Join(root, "bus", "pci", "devices", address)
+			Expect(os.MkdirAll(dir, 0755)).To(Succeed())
+			Expect(os.Symlink(vfioDir, filepath.Join(dir, "driver"))).To(Succeed())
+		}
+		pciDevices := pcidevice.NewManager(root, map[string][]pcidevice.Selector{
+			"gpu": {{Address: "0000:3b:00.0"}, {Address: "0000:3c:00.0"}},
+		})
+
+		machines, err := host.NewStore[*api.Machine](host.Options[*api.Machine]{
+			Dir:     GinkgoT().TempDir(),
+			NewFunc: func() *api.Machine { return &api.Machine{} },
+		})
+		Expect(err).NotTo(HaveOccurred())
+		for _, machine := range []*api.Machine{
+			{
+				Metadata: api.Metadata{ID: "running"},
+				Status: api.MachineStatus{
+					State:      api.MachineStateRunning,
+					PCIDevices: []api.PCIDeviceStatus{{Pool: "gpu", Address: "0000:3b:00.0"}},
+				},
+			},
+			{
+				Metadata: api.Metadata{ID: "stopped"},
+				Status: api.MachineStatus{
+					State:      api.MachineStateTerminated,
+					PCIDevices: []api.PCIDeviceStatus{{Pool: "gpu", Address: "0000:3c:00.0"}},
+				},
+			},
+		} {
+			_, err := machines.Create(ctx, machine)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		recorder := &reasonEventRecorder{}
+		r := &MachineReconciler{
+			machines:      machines,
+			EventRecorder: recorder,
+			pciDevices:    pciDevices,
+		}
+
+		r.checkPCIDeviceHealth(ctx, GinkgoLogr)
+		Expect(pciDevices.Unhealthy()).To(BeEmpty())
+		Expect(recorder.reasons).To(BeEmpty())
+
+		By("unbinding the devices from vfio-pci")
+		for _, address := range []string{"0000:3b:00.0", "0000:3c:00.0"} {
+			Expect(os.Remove(filepath.Join(root, "bus", "pci", "devices", address, "driver"))).To(Succeed())
+		}
+		r.checkPCIDeviceHealth(ctx, GinkgoLogr)
+		r.checkPCIDeviceHealth(ctx, GinkgoLogr)
+		Expect(pciDevices.Unhealthy()).To(Equal([]api.PCIDeviceStatus{{Pool: "gpu", Address: "0000:3b:00.0"}}))
+		Expect(pciDevices.UnhealthyCount("gpu")).To(Equal(1))
+		Expect(recorder.reasons).To(Equal([]string{"PCIDeviceUnhealthy"}))
+
+		By("binding the device to vfio-pci again")
+		Expect(os.Symlink(vfioDir, filepath.Join(root, "bus", "pci", "devices", "0000:3b:00.0", "driver"))).To(Succeed())
+		r.checkPCIDeviceHealth(ctx, GinkgoLogr)
+		Expect(pciDevices.Unhealthy()).To(BeEmpty())
+		Expect(recorder.reasons).To(Equal([]string{"PCIDeviceUnhealthy", "PCIDeviceRecovered"}))
+	})
+})
+
+// reasonEventRecorder records the reasons of the events.
+type reasonEventRecorder struct {
+	reasons []string
+}
+
+func (r *reasonEventRecorder) Eventf(_ logr.Logger, _ api.Metadata, _, reason, _ string, _ ...any) {
+	r.reasons = append(r.reasons, reason)
+}
@@ -0,0 +1,68 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/digitalocean/go-libvirt"
+	"github.com/go-logr/logr"
+	"github.com/ironcore-dev/libvirt-provider/api"
+)
+
+const conditionReasonPoweredOff = "PoweredOff"
+
+// reconcilePowerOff shuts down the domain of a machine powered off via its spec. The shutdown escalates like the
+// shutdown of deleted machines, its progress is kept in the machine status. Powered off machines keep their
+// volumes, network interfaces and other resources, they are started again once powered on.
+func (r *MachineReconciler) reconcilePowerOff(ctx context.Context, log logr.Logger, machine *api.Machine, domain libvirt.Domain, exists bool) (api.MachineState, error) {
+	if !exists {
+		machine.Status.Shutdown = nil
+		setCondition(machine, api.MachineConditionDomainSynced, true, conditionReasonPoweredOff, "")
+		return api.MachineStateTerminated, nil
+	}
+
+	now := time.Now()
+	shutdown := machine.Status.Shutdown
+	if shutdown == nil {
+		shutdown = &api.ShutdownStatus{Stage: api.ShutdownStageACPI, StageStartedAt: now}
+	}
+	shutdown = r.escalateShutdown(log, machine, shutdown, now)
+	machine.Status.Shutdown = shutdown
+
+	if shutdown.Stage == api.ShutdownStageDestroy {
+		if err := r.destroyDomain(ctx, log, machine, domain); err != nil {
+			return "", err
+		}
+		machine.Status.Shutdown = nil
+		setCondition(machine, api.MachineConditionDomainSynced, true, conditionReasonPoweredOff, "")
+		return api.MachineStateTerminated, nil
+	}
+
+	// Like for deleted machines, the shutdown is requested again after the resend interval in case it was missed.
+	if shutdown.RequestedAt.IsZero() || (r.gcVMShutdownResendInterval > 0 && !now.Before(shutdown.RequestedAt.Add(r.gcVMShutdownResendInterval))) {
+		requested, err := r.shutdownMachine(ctx, log, machine, domain, shutdown.Stage)
+		if err != nil {
+			return "", err
+		}
+		if !requested {
+			machine.Status.Shutdown = nil
+			setCondition(machine, api.MachineConditionDomainSynced, true, conditionReasonPoweredOff, "")
+			return api.MachineStateTerminated, nil
+		}
+		shutdown.RequestedAt = now
+	}
+
+	// The domain going away triggers a reconcile via its lifecycle event, escalate or resend otherwise.
+	after := shutdown.StageStartedAt.Add(r.shutdownStageTimeout(shutdown.Stage)).Sub(now)
+	if r.gcVMShutdownResendInterval > 0 {
+		after = min(after, shutdown.RequestedAt.Add(r.gcVMShutdownResendInterval).Sub(now))
+	}
+	r.queue.AddAfter(machine.ID, max(after, time.Second))
+
+	setCondition(machine, api.MachineConditionDomainSynced, false, conditionReasonPending, fmt.Sprintf("Shutting down domain (%s)", shutdown.Stage))
+	return r.getMachineState(machine.ID)
+}
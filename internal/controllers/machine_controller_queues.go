@@ -0,0 +1,47 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"github.com/ironcore-dev/libvirt-provider/api"
+	providernetworkinterface "github.com/ironcore-dev/libvirt-provider/internal/plugins/networkinterface"
+	"libvirt.org/go/libvirtxml"
+)
+
+// virtioQueues is the number of queues of the virtio devices of a machine. Values up to 1 mean single queue.
+type virtioQueues struct {
+	disk uint
+	nic  uint
+}
+
+// virtioQueues returns the number of queues of the virtio devices of the machine. Unless the spec of the machine
+// defines them, one queue per vCPU is used, bounded by virtioMaxQueues.
+func (r *MachineReconciler) virtioQueues(machine *api.Machine) virtioQueues {
+	derived := min(uint(machine.Spec.CpuMillis/1000), r.virtioMaxQueues)
+	queues := virtioQueues{disk: derived, nic: derived}
+	if spec := machine.Spec.Queues; spec != nil {
+		if spec.Disk > 0 {
+			queues.disk = spec.Disk
+		}
+		if spec.NetworkInterface > 0 {
+			queues.nic = spec.NetworkInterface
+		}
+	}
+	return queues
+}
+
+// setInterfaceModel sets the model of the network interface. virtio-net interfaces with more than one queue use
+// vhost with the given number of queues, single queue interfaces without model are left to the hypervisor.
+func setInterfaceModel(iface *libvirtxml.DomainInterface, model string, queues uint) *libvirtxml.DomainInterface {
+	if model != "" {
+		iface.Model = &libvirtxml.DomainInterfaceModel{Type: model}
+	}
+	if queues <= 1 || (model != "" && model != providernetworkinterface.ModelVirtio) {
+		return iface
+	}
+
+	iface.Model = &libvirtxml.DomainInterfaceModel{Type: providernetworkinterface.ModelVirtio}
+	iface.Driver = &libvirtxml.DomainInterfaceDriver{Name: "vhost", Queues: queues}
+	return iface
+}
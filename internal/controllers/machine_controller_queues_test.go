@@ -0,0 +1,63 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"github.com/ironcore-dev/libvirt-provider/api"
+	providervolume "github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/utils/ptr"
+	"libvirt.org/go/libvirtxml"
+)
+
+var _ = Describe("Virtio queues", func() {
+	DescribeTable("virtioQueues",
+		func(cpuMillis int64, spec *api.QueuesSpec, expected virtioQueues) {
+			r := &MachineReconciler{virtioMaxQueues: 8}
+			machine := &api.Machine{Spec: api.MachineSpec{CpuMillis: cpuMillis, Queues: spec}}
+			Expect(r.virtioQueues(machine)).To(Equal(expected))
+		},
+		Entry("one queue per vcpu", int64(4000), nil, virtioQueues{disk: 4, nic: 4}),
+		Entry("fractional vcpus", int64(2500), nil, virtioQueues{disk: 2, nic: 2}),
+		Entry("less than one vcpu", int64(500), nil, virtioQueues{}),
+		Entry("bounded by the maximum", int64(32000), nil, virtioQueues{disk: 8, nic: 8}),
+		Entry("spec queues", int64(4000), &api.QueuesSpec{Disk: 2, NetworkInterface: 16},
+			virtioQueues{disk: 2, nic: 16}),
+		Entry("partial spec queues", int64(4000), &api.QueuesSpec{NetworkInterface: 1},
+			virtioQueues{disk: 4, nic: 1}),
+	)
+
+	DescribeTable("setInterfaceModel",
+		func(model string, queues uint, expectedModel *libvirtxml.DomainInterfaceModel, expectedDriver *libvirtxml.DomainInterfaceDriver) {
+			iface := setInterfaceModel(&libvirtxml.DomainInterface{}, model, queues)
+			Expect(iface.Model).To(Equal(expectedModel))
+			Expect(iface.Driver).To(Equal(expectedDriver))
+		},
+		Entry("single queue without model", "", uint(1), nil, nil),
+		Entry("single queue virtio", "virtio", uint(0),
+			&libvirtxml.DomainInterfaceModel{Type: "virtio"}, nil),
+		Entry("multi queue without model", "", uint(4),
+			&libvirtxml.DomainInterfaceModel{Type: "virtio"},
+			&libvirtxml.DomainInterfaceDriver{Name: "vhost", Queues: 4}),
+		Entry("multi queue virtio", "virtio", uint(4),
+			&libvirtxml.DomainInterfaceModel{Type: "virtio"},
+			&libvirtxml.DomainInterfaceDriver{Name: "vhost", Queues: 4}),
+		Entry("multi queue with another model", "e1000", uint(4),
+			&libvirtxml.DomainInterfaceModel{Type: "e1000"}, nil),
+	)
+
+	DescribeTable("setDiskDriverModes",
+		func(diskQueues uint, expected *uint) {
+			a := &libvirtVolumeAttacher{diskQueues: diskQueues}
+			driver := &libvirtxml.DomainDiskDriver{}
+			a.setDiskDriverModes(driver, &providervolume.Volume{Cache: "none", IO: "native"})
+			Expect(driver.Queues).To(Equal(expected))
+			Expect(driver.Cache).To(Equal("none"))
+			Expect(driver.IO).To(Equal("native"))
+		},
+		Entry("single queue", uint(1), nil),
+		Entry("multi queue", uint(4), ptr.To[uint](4)),
+	)
+})
@@ -0,0 +1,71 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	machineDirUsedBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "libvirt_provider",
+		Name:      "machine_dir_used_bytes",
+		Help:      "Disk space used by the directory of a machine when it was reconciled last.",
+	}, []string{"machine_id"})
+	machineDirLimitBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "libvirt_provider",
+		Name:      "machine_dir_limit_bytes",
+		Help:      "Disk space the directory of a machine is limited to.",
+	}, []string{"machine_id"})
+)
+
+func init() {
+	prometheus.MustRegister(machineDirUsedBytes, machineDirLimitBytes)
+}
+
+// reconcileDirQuota limits the disk space of the machine directory, so runaway disks or logs of the machine can't
+// fill the file system, and reports its usage in the status of the machine.
+func (r *MachineReconciler) reconcileDirQuota(machine *api.Machine) error {
+	if r.dirQuotas == nil {
+		return nil
+	}
+
+	id, err := r.dirQuotas.Assign(r.host.MachineDir(machine.ID))
+	if err != nil {
+		return err
+	}
+	if err := r.dirQuotas.SetLimit(id, r.dirQuotaBytes(&machine.Spec)); err != nil {
+		return err
+	}
+	quota, err := r.dirQuotas.Get(id)
+	if err != nil {
+		return err
+	}
+
+	machine.Status.DirQuota = &api.DirQuotaStatus{
+		ProjectID:  id,
+		LimitBytes: quota.LimitBytes,
+		UsedBytes:  quota.UsedBytes,
+	}
+	machineDirUsedBytes.WithLabelValues(machine.ID).Set(float64(quota.UsedBytes))
+	machineDirLimitBytes.WithLabelValues(machine.ID).Set(float64(quota.LimitBytes))
+	return nil
+}
+
+// releaseDirQuota removes the limit of the project of the removed machine directory.
+func (r *MachineReconciler) releaseDirQuota(log logr.Logger, machine *api.Machine) {
+	machineDirUsedBytes.DeleteLabelValues(machine.ID)
+	machineDirLimitBytes.DeleteLabelValues(machine.ID)
+	if r.dirQuotas == nil || machine.Status.DirQuota == nil {
+		return
+	}
+
+	if err := r.dirQuotas.SetLimit(machine.Status.DirQuota.ProjectID, 0); err != nil {
+		log.Error(fmt.Errorf("error releasing machine directory quota: %w", err), "failed to release machine directory quota")
+	}
+}
@@ -0,0 +1,346 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/digitalocean/go-libvirt"
+	"github.com/go-logr/logr"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	providerhost "github.com/ironcore-dev/libvirt-provider/internal/host"
+	libvirtalias "github.com/ironcore-dev/libvirt-provider/internal/libvirt/alias"
+	libvirtmeta "github.com/ironcore-dev/libvirt-provider/internal/libvirt/meta"
+	libvirtutils "github.com/ironcore-dev/libvirt-provider/internal/libvirt/utils"
+	"github.com/ironcore-dev/libvirt-provider/internal/store"
+	corev1 "k8s.io/api/core/v1"
+	"libvirt.org/go/libvirtxml"
+)
+
+var ErrNoProviderMetadata = errors.New("domain has no libvirt provider metadata")
+
+// RebuildResult describes the outcome of rebuilding the machine store from the libvirt domains.
+type RebuildResult struct {
+	// Rebuilt are the ids of the machines added to the store.
+	Rebuilt []string `json:"rebuilt,omitempty"`
+	// Existing are the ids of the machines already in the store.
+	Existing []string `json:"existing,omitempty"`
+	// Failed are the domains of the provider whose machine couldn't be rebuilt.
+	Failed []store.Problem `json:"failed,omitempty"`
+}
+
+// RecoverMachine reconstructs a machine from its domain, see ReconstructMachine. The ignition and the volume
+// secrets are restored from the machine directory.
+func RecoverMachine(lv *libvirt.Libvirt, paths providerhost.Paths, machineID string) (*api.Machine, error) {
+	domain := machineDomain(machineID)
+	domainXMLData, err := lv.DomainGetXMLDesc(domain, 0)
+	if err != nil {
+		return nil, fmt.Errorf("error getting domain xml: %w", err)
+	}
+	return reconstructDomainMachine(lv, paths, domain, domainXMLData)
+}
+
+// RebuildStore adds the machines of all domains carrying provider metadata that are missing in the machine
+// store, e.g. after the store was lost.
+func (r *MachineReconciler) RebuildStore(ctx context.Context) (*RebuildResult, error) {
+	log := logr.FromContextOrDiscard(ctx)
+
+	domains, _, err := r.conn().ConnectListAllDomains(1, 0)
+	if err != nil {
+		return nil, fmt.Errorf("error listing domains: %w", err)
+	}
+
+	res := &RebuildResult{}
+	for _, domain := range domains {
+		machineID := libvirtutils.UUIDBytesToString(domain.UUID)
+
+		domainXMLData, err := r.conn().DomainGetXMLDesc(domain, 0)
+		if err != nil {
+			res.Failed = append(res.Failed, store.Problem{ID: machineID, Reason: fmt.Sprintf("error getting domain xml: %v", err)})
+			continue
+		}
+
+		machine, err := reconstructDomainMachine(r.conn(), r.host, domain, domainXMLData)
+		if err != nil {
+			if errors.Is(err, ErrNoProviderMetadata) {
+				log.V(1).Info("Skipping domain not managed by the provider", "Domain", domain.Name)
+				continue
+			}
+			res.Failed = append(res.Failed, store.Problem{ID: machineID, Reason: err.Error()})
+			continue
+		}
+
+		if _, err := r.machines.Create(ctx, machine); err != nil {
+			if errors.Is(err, store.ErrAlreadyExists) {
+				res.Existing = append(res.Existing, machineID)
+				continue
+			}
+			res.Failed = append(res.Failed, store.Problem{ID: machineID, Reason: fmt.Sprintf("error creating machine: %v", err)})
+			continue
+		}
+
+		log.Info("Rebuilt machine from domain", "MachineID", machineID)
+		r.Eventf(log, machine.Metadata, corev1.EventTypeWarning, "RebuiltMachine", "Machine was rebuilt from its domain")
+		res.Rebuilt = append(res.Rebuilt, machineID)
+	}
+	return res, nil
+}
+
+func reconstructDomainMachine(lv *libvirt.Libvirt, paths providerhost.Paths, domain libvirt.Domain, domainXMLData string) (*api.Machine, error) {
+	domainXML := &libvirtxml.Domain{}
+	if err := domainXML.Unmarshal(domainXMLData); err != nil {
+		return nil, fmt.Errorf("error unmarshalling domain xml: %w", err)
+	}
+
+	domainState, _, err := lv.DomainGetState(domain, 0)
+	if err != nil {
+		return nil, fmt.Errorf("error getting domain state: %w", err)
+	}
+	state, err := domainMachineState(paths, domainXML.UUID, libvirt.DomainState(domainState))
+	if err != nil {
+		return nil, err
+	}
+
+	machine, err := ReconstructMachine(domainXML, state)
+	if err != nil {
+		return nil, err
+	}
+	if err := restoreSecrets(paths, machine); err != nil {
+		return nil, err
+	}
+	return machine, nil
+}
+
+// ReconstructMachine reconstructs a machine from its domain. The spec is taken from the machine snapshot in
+// the domain metadata, if present. Otherwise, it is derived from the domain, lacking the image and the
+// connection details of volumes and network interfaces. The ignition and the volume secrets are never part of
+// the domain, see restoreSecrets. The status reflects the domain.
+func ReconstructMachine(domainXML *libvirtxml.Domain, state api.MachineState) (*api.Machine, error) {
+	if domainXML.Metadata == nil {
+		return nil, ErrNoProviderMetadata
+	}
+	metadata := &libvirtmeta.LibvirtProviderMetadata{}
+	if err := xml.Unmarshal([]byte(domainXML.Metadata.XML), metadata); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNoProviderMetadata, err)
+	}
+
+	machine := &api.Machine{}
+	if metadata.Machine != nil {
+		machineData, err := metadata.Machine.Decode()
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(machineData, machine); err != nil {
+			return nil, fmt.Errorf("error unmarshalling machine snapshot: %w", err)
+		}
+		if machine.ID != domainXML.UUID {
+			return nil, fmt.Errorf("machine snapshot has id %q", machine.ID)
+		}
+	} else {
+		var err error
+		if machine, err = machineFromDomain(domainXML, metadata); err != nil {
+			return nil, err
+		}
+	}
+
+	machine.Status = api.MachineStatus{State: state}
+	for _, volume := range domainVolumes(domainXML) {
+		machine.Status.VolumeStatus = append(machine.Status.VolumeStatus, api.VolumeStatus{
+			Name:  volume.Name,
+			State: api.VolumeStateAttached,
+		})
+	}
+	for _, name := range domainNetworkInterfaceNames(domainXML) {
+		machine.Status.NetworkInterfaceStatus = append(machine.Status.NetworkInterfaceStatus, api.NetworkInterfaceStatus{
+			Name:  name,
+			State: api.NetworkInterfaceStateAttached,
+		})
+	}
+	return machine, nil
+}
+
+func machineFromDomain(domainXML *libvirtxml.Domain, metadata *libvirtmeta.LibvirtProviderMetadata) (*api.Machine, error) {
+	labels, err := json.Marshal(libvirtmeta.IRIMachineLabelsDecoder(metadata.IRIMmachineLabels))
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling labels: %w", err)
+	}
+
+	machine := &api.Machine{
+		Metadata: api.Metadata{
+			ID: domainXML.UUID,
+			Annotations: map[string]string{
+				api.AnnotationsAnnotation: "{}",
+				api.LabelsAnnotation:      string(labels),
+			},
+			CreatedAt: time.Now(),
+		},
+	}
+
+	if domainXML.VCPU != nil {
+		machine.Spec.CpuMillis = int64(domainXML.VCPU.Value) * 1000
+	}
+	if domainXML.Memory != nil {
+		memoryBytes, err := domainMemoryBytes(domainXML.Memory)
+		if err != nil {
+			return nil, err
+		}
+		machine.Spec.MemoryBytes = memoryBytes
+	}
+
+	machine.Spec.Volumes = domainVolumes(domainXML)
+	for _, name := range domainNetworkInterfaceNames(domainXML) {
+		machine.Spec.NetworkInterfaces = append(machine.Spec.NetworkInterfaces, &api.NetworkInterfaceSpec{Name: name})
+	}
+	return machine, nil
+}
+
+// domainVolumes returns the volumes attached to the domain, identified by the alias of their disk.
+func domainVolumes(domainXML *libvirtxml.Domain) []*api.VolumeSpec {
+	if domainXML.Devices == nil {
+		return nil
+	}
+
+	var volumes []*api.VolumeSpec
+	for _, disk := range domainXML.Devices.Disks {
+		if disk.Alias == nil || !libvirtalias.IsVolume(disk.Alias.Name) {
+			continue
+		}
+		name, err := libvirtalias.ParseVolume(disk.Alias.Name)
+		if err != nil {
+			continue
+		}
+		volume := &api.VolumeSpec{Name: name}
+		if disk.Target != nil {
+			volume.Device = disk.Target.Dev
+		}
+		volumes = append(volumes, volume)
+	}
+	return volumes
+}
+
+// domainNetworkInterfaceNames returns the names of the network interfaces of the domain, identified by their alias.
+func domainNetworkInterfaceNames(domainXML *libvirtxml.Domain) []string {
+	if domainXML.Devices == nil {
+		return nil
+	}
+
+	var names []string
+	for _, nic := range domainXML.Devices.Interfaces {
+		if nic.Alias == nil || !libvirtalias.IsNetworkInterface(nic.Alias.Name) {
+			continue
+		}
+		name, err := libvirtalias.ParseNetworkInterface(nic.Alias.Name)
+		if err != nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func domainMemoryBytes(memory *libvirtxml.DomainMemory) (int64, error) {
+	value := int64(memory.Value)
+	switch memory.Unit {
+	case "b", "bytes", "Byte":
+		return value, nil
+	case "", "k", "KiB":
+		return value << 10, nil
+	case "M", "MiB":
+		return value << 20, nil
+	case "G", "GiB":
+		return value << 30, nil
+	default:
+		return 0, fmt.Errorf("unsupported memory unit %q", memory.Unit)
+	}
+}
+
+// refreshDomainMetadata updates the labels and the machine snapshot in the domain metadata if the machine
+// changed, e.g. because its annotations were updated. The domains are transient, so only the live domain is
+// updated.
+func (r *MachineReconciler) refreshDomainMetadata(log logr.Logger, machine *api.Machine, domainDesc *libvirtxml.Domain) error {
+	data, changed, err := domainMetadataUpdate(log, machine, domainDesc)
+	if err != nil || !changed {
+		return err
+	}
+
+	if err := r.conn().DomainSetMetadata(
+		machineDomain(machine.ID),
+		int32(libvirt.DomainMetadataElement),
+		libvirt.OptString{data},
+		libvirt.OptString{libvirtmeta.NamespacePrefix},
+		libvirt.OptString{libvirtmeta.Namespace},
+		libvirt.DomainAffectLive,
+	); err != nil {
+		return fmt.Errorf("error setting domain metadata: %w", err)
+	}
+	log.V(2).Info("Refreshed domain metadata")
+	return nil
+}
+
+// domainMetadataUpdate returns the provider metadata of the domain with the labels and the machine snapshot of
+// the machine and whether it differs from the current one.
+func domainMetadataUpdate(log logr.Logger, machine *api.Machine, domainDesc *libvirtxml.Domain) (string, bool, error) {
+	metadata := &libvirtmeta.LibvirtProviderMetadata{}
+	if domainDesc.Metadata != nil {
+		if err := xml.Unmarshal([]byte(domainDesc.Metadata.XML), metadata); err != nil {
+			log.V(1).Info("Replacing unreadable domain metadata", "Error", err)
+		}
+	}
+
+	labelsChanged := false
+	if machineLabels, err := api.GetLabelsAnnotation(machine.Metadata); err == nil &&
+		!maps.Equal(libvirtmeta.IRIMachineLabelsDecoder(metadata.IRIMmachineLabels), machineLabels) {
+		metadata.IRIMmachineLabels = libvirtmeta.IRIMachineLabelsEncoder(machineLabels)
+		labelsChanged = true
+	}
+
+	machineData, err := machineSnapshotData(machine)
+	if err != nil {
+		return "", false, err
+	}
+	if metadata.Machine != nil && !labelsChanged {
+		if current, err := metadata.Machine.Decode(); err == nil && bytes.Equal(current, machineData) {
+			return "", false, nil
+		}
+	}
+	if metadata.Machine, err = libvirtmeta.NewMachineSnapshot(machineData); err != nil {
+		return "", false, err
+	}
+
+	data, err := metadata.ElementXML()
+	if err != nil {
+		return "", false, fmt.Errorf("error marshalling domain metadata: %w", err)
+	}
+	return data, true, nil
+}
+
+// machineSnapshot returns the snapshot of the machine to embed in the domain metadata.
+func machineSnapshot(machine *api.Machine) (*libvirtmeta.MachineSnapshot, error) {
+	machineData, err := machineSnapshotData(machine)
+	if err != nil {
+		return nil, err
+	}
+	return libvirtmeta.NewMachineSnapshot(machineData)
+}
+
+// machineSnapshotData returns the JSON encoded machine without its status, which is reconstructed from the
+// domain, and without its resource version, so status updates don't change the snapshot. The ignition and the
+// volume secrets are left out, the domain metadata is readable with read-only libvirt access.
+func machineSnapshotData(machine *api.Machine) ([]byte, error) {
+	snapshot := *withoutSecrets(machine)
+	snapshot.ResourceVersion = 0
+	snapshot.Status = api.MachineStatus{}
+	data, err := json.Marshal(&snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling machine: %w", err)
+	}
+	return data, nil
+}
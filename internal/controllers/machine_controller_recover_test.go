@@ -0,0 +1,136 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"encoding/xml"
+
+	"github.com/go-logr/logr"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/alias"
+	libvirtmeta "github.com/ironcore-dev/libvirt-provider/internal/libvirt/meta"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/utils/ptr"
+	"libvirt.org/go/libvirtxml"
+)
+
+var _ = Describe("Machine recovery", func() {
+	const machineID = "d2f0dd7e-6e69-4c6c-9c59-5e3a4c7d3b5a"
+
+	newMachine := func() *api.Machine {
+		machine := &api.Machine{
+			Metadata: api.Metadata{ID: machineID, ResourceVersion: 3},
+			Spec: api.MachineSpec{
+				CpuMillis:   2000,
+				MemoryBytes: 1 << 30,
+				Image:       ptr.To("ghcr.io/ironcore-dev/os-images/gardenlinux:latest"),
+				Ignition:    []byte("ignition"),
+				Volumes:     []*api.VolumeSpec{{Name: "root", Device: "vda"}},
+			},
+			Status: api.MachineStatus{State: api.MachineStateRunning},
+		}
+		Expect(api.SetLabelsAnnotation(machine, map[string]string{"foo": "bar"})).To(Succeed())
+		return machine
+	}
+
+	domainWithMetadata := func(data string) *libvirtxml.Domain {
+		return &libvirtxml.Domain{
+			UUID:     machineID,
+			Metadata: &libvirtxml.DomainMetadata{XML: data},
+			Devices: &libvirtxml.DomainDeviceList{
+				Disks: []libvirtxml.DomainDisk{{
+					Alias:  &libvirtxml.DomainAlias{Name: alias.Volume("root")},
+					Target: &libvirtxml.DomainDiskTarget{Dev: "vda"},
+				}},
+				Interfaces: []libvirtxml.DomainInterface{{
+					Alias: &libvirtxml.DomainAlias{Name: alias.NetworkInterface("primary")},
+				}},
+			},
+		}
+	}
+
+	Describe("domainMetadataUpdate", func() {
+		It("should only update the metadata if the machine changed", func() {
+			machine := newMachine()
+			data, changed, err := domainMetadataUpdate(logr.Discard(), machine, &libvirtxml.Domain{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changed).To(BeTrue())
+
+			By("refreshing the metadata of an unchanged machine")
+			machine.ResourceVersion++
+			machine.Status.State = api.MachineStateTerminating
+			_, changed, err = domainMetadataUpdate(logr.Discard(), machine, domainWithMetadata(data))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changed).To(BeFalse())
+
+			By("changing the labels of the machine")
+			Expect(api.SetLabelsAnnotation(machine, map[string]string{"foo": "baz"})).To(Succeed())
+			updated, changed, err := domainMetadataUpdate(logr.Discard(), machine, domainWithMetadata(data))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changed).To(BeTrue())
+
+			metadata := &libvirtmeta.LibvirtProviderMetadata{}
+			Expect(xml.Unmarshal([]byte(updated), metadata)).To(Succeed())
+			Expect(libvirtmeta.IRIMachineLabelsDecoder(metadata.IRIMmachineLabels)).To(Equal(map[string]string{"foo": "baz"}))
+		})
+
+		It("should replace unreadable metadata", func() {
+			_, changed, err := domainMetadataUpdate(logr.Discard(), newMachine(), domainWithMetadata("<broken"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changed).To(BeTrue())
+		})
+	})
+
+	Describe("ReconstructMachine", func() {
+		It("should reconstruct the machine from the snapshot in the domain metadata", func() {
+			machine := newMachine()
+			data, _, err := domainMetadataUpdate(logr.Discard(), machine, &libvirtxml.Domain{})
+			Expect(err).NotTo(HaveOccurred())
+
+			reconstructed, err := ReconstructMachine(domainWithMetadata(data), api.MachineStateRunning)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reconstructed.Spec.Image).To(Equal(machine.Spec.Image))
+			Expect(reconstructed.Spec.Volumes).To(Equal(machine.Spec.Volumes))
+			Expect(reconstructed.Spec.Ignition).To(BeNil())
+			Expect(reconstructed.ResourceVersion).To(BeZero())
+			Expect(reconstructed.Status).To(Equal(api.MachineStatus{
+				State:                  api.MachineStateRunning,
+				VolumeStatus:           []api.VolumeStatus{{Name: "root", State: api.VolumeStateAttached}},
+				NetworkInterfaceStatus: []api.NetworkInterfaceStatus{{Name: "primary", State: api.NetworkInterfaceStateAttached}},
+			}))
+		})
+
+		It("should derive the machine from the domain without snapshot", func() {
+			domain := domainWithMetadata(`<metadata><irimachinelabels>
+"foo": "bar"</irimachinelabels></metadata>`)
+			domain.VCPU = &libvirtxml.DomainVCPU{Value: 2}
+			domain.Memory = &libvirtxml.DomainMemory{Value: 1, Unit: "GiB"}
+
+			machine, err := ReconstructMachine(domain, api.MachineStateSuspended)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(machine.ID).To(Equal(machineID))
+			Expect(machine.Spec.CpuMillis).To(Equal(int64(2000)))
+			Expect(machine.Spec.MemoryBytes).To(Equal(int64(1 << 30)))
+			Expect(machine.Spec.Volumes).To(Equal([]*api.VolumeSpec{{Name: "root", Device: "vda"}}))
+			Expect(machine.Spec.NetworkInterfaces).To(Equal([]*api.NetworkInterfaceSpec{{Name: "primary"}}))
+			Expect(api.GetLabelsAnnotation(machine.Metadata)).To(Equal(map[string]string{"foo": "bar"}))
+		})
+
+		It("should reject domains without provider metadata", func() {
+			_, err := ReconstructMachine(&libvirtxml.Domain{UUID: machineID}, api.MachineStateRunning)
+			Expect(err).To(MatchError(ErrNoProviderMetadata))
+		})
+
+		It("should reject snapshots of other machines", func() {
+			data, _, err := domainMetadataUpdate(logr.Discard(), newMachine(), &libvirtxml.Domain{})
+			Expect(err).NotTo(HaveOccurred())
+			domain := domainWithMetadata(data)
+			domain.UUID = "other"
+
+			_, err = ReconstructMachine(domain, api.MachineStateRunning)
+			Expect(err).To(MatchError(ContainSubstring("machine snapshot has id")))
+		})
+	})
+})
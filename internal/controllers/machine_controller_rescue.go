@@ -0,0 +1,138 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/digitalocean/go-libvirt"
+	"github.com/go-logr/logr"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/alias"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/device"
+	providerimage "github.com/ironcore-dev/libvirt-provider/internal/oci"
+	corev1 "k8s.io/api/core/v1"
+	"libvirt.org/go/libvirtxml"
+)
+
+const (
+	conditionReasonRescue = "Rescue"
+
+	// rescueTargetSerial is the serial of the root fs disk of a rescued machine. The rescue image takes over the
+	// rootFSSerial, so it boots like any other image.
+	rescueTargetSerial = "machinerootfs"
+)
+
+// rescueImageRef returns the reference of the rescue image of the machine.
+func (r *MachineReconciler) rescueImageRef(machine *api.Machine) string {
+	if machine.Spec.Rescue != nil && machine.Spec.Rescue.Image != "" {
+		return machine.Spec.Rescue.Image
+	}
+	return r.rescueImage
+}
+
+// rescueChanged reports whether the domain of the machine has to be restarted to enter or leave the rescue image,
+// or to switch to another one. Switching from an explicit image back to the default one is a switch as well.
+func (r *MachineReconciler) rescueChanged(machine *api.Machine) bool {
+	spec, status := machine.Spec.Rescue, machine.Status.Rescue
+	if (spec == nil) != (status == nil) {
+		return true
+	}
+	return spec != nil && r.rescueImageRef(machine) != status.Image
+}
+
+// stopDomainForRescue destroys the domain, so it is created again with or without the rescue image. The guest
+// is not shut down, rescuing is meant for machines that don't boot properly. The domain keeps running until the
+// rescue image is pulled.
+func (r *MachineReconciler) stopDomainForRescue(ctx context.Context, log logr.Logger, machine *api.Machine, domain libvirt.Domain) error {
+	if machine.Spec.Rescue != nil {
+		if _, err := r.imageCache.Get(ctx, r.rescueImageRef(machine)); err != nil {
+			return fmt.Errorf("rescue image: %w", err)
+		}
+
+		log.V(1).Info("Restarting domain into rescue image")
+		r.Eventf(log, machine.Metadata, corev1.EventTypeNormal, "RestartingIntoRescue", "Restarting domain into rescue image %s", r.rescueImageRef(machine))
+	} else {
+		log.V(1).Info("Restarting domain out of rescue image")
+		r.Eventf(log, machine.Metadata, corev1.EventTypeNormal, "RestartingOutOfRescue", "Restarting domain to boot normally")
+	}
+	return r.destroyDomain(ctx, log, machine, domain)
+}
+
+// setDomainRescue boots the domain from the kernel, initramfs and root fs of the rescue image. The root fs disk
+// of the machine stays attached under the rescueTargetSerial, the volumes are attached as usual.
+func (r *MachineReconciler) setDomainRescue(ctx context.Context, log logr.Logger, machine *api.Machine, domain *libvirtxml.Domain) error {
+	ref := r.rescueImageRef(machine)
+	if ref == "" {
+		return fmt.Errorf("cannot rescue machine: no rescue image configured")
+	}
+
+	img, err := r.imageCache.Get(ctx, ref)
+	if err != nil {
+		if errors.Is(err, providerimage.ErrImagePulling) {
+			r.Eventf(log, machine.Metadata, corev1.EventTypeNormal, "PullingImage", "Pulling rescue image %s", ref)
+		}
+		return fmt.Errorf("rescue image: %w", err)
+	}
+	if !img.IsDirectKernelBoot() {
+		return fmt.Errorf("rescue image %s does not support direct kernel boot", ref)
+	}
+
+	for i := range domain.Devices.Disks {
+		disk := &domain.Devices.Disks[i]
+		if disk.Alias != nil && disk.Alias.Name == alias.RootFS {
+			disk.Serial = rescueTargetSerial
+			disk.Boot = nil
+		}
+	}
+
+	domain.OS.BootDevices = nil
+	domain.OS.Kernel = img.Kernel.Path
+	domain.OS.Initrd = img.InitRAMFs.Path
+	domain.OS.Cmdline = img.Config.CommandLine
+
+	disk := libvirtxml.DomainDisk{
+		Alias: &libvirtxml.DomainAlias{
+			Name: alias.RescueRootFS,
+		},
+		Device: "disk",
+		Driver: &libvirtxml.DomainDiskDriver{
+			Name: "qemu",
+			Type: "raw",
+		},
+		Source: &libvirtxml.DomainDiskSource{
+			File: &libvirtxml.DomainDiskSourceFile{
+				File: img.RootFS.Path,
+			},
+		},
+		Target: &libvirtxml.DomainDiskTarget{
+			Dev: device.RescueRootFSTarget,
+			Bus: "virtio",
+		},
+		Serial:   rootFSSerial,
+		ReadOnly: &libvirtxml.DomainDiskReadOnly{},
+	}
+	assignDiskIOThread(domain, &disk, r.cpuPinning.IOThreads)
+	domain.Devices.Disks = append(domain.Devices.Disks, disk)
+	return nil
+}
+
+// setRescueStatus records whether the domain of the machine was started with the rescue image.
+func (r *MachineReconciler) setRescueStatus(machine *api.Machine) {
+	if machine.Spec.Rescue == nil {
+		machine.Status.Rescue = nil
+		api.RemoveMachineCondition(&machine.Status.Conditions, api.MachineConditionRescued)
+		return
+	}
+
+	ref := r.rescueImageRef(machine)
+	machine.Status.Rescue = &api.RescueStatus{
+		Image:     ref,
+		StartedAt: time.Now(),
+	}
+	setCondition(machine, api.MachineConditionRescued, true, conditionReasonRescue, fmt.Sprintf("Booted rescue image %s", ref))
+}
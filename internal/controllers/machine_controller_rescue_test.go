@@ -0,0 +1,36 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"github.com/ironcore-dev/libvirt-provider/api"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Machine rescue", func() {
+	const (
+		defaultRescueImage = "example.org/rescue:default"
+		explicitImage      = "example.org/rescue:explicit"
+	)
+
+	r := &MachineReconciler{rescueImage: defaultRescueImage}
+
+	DescribeTable("rescueChanged",
+		func(spec *api.RescueSpec, status *api.RescueStatus, changed bool) {
+			machine := &api.Machine{
+				Spec:   api.MachineSpec{Rescue: spec},
+				Status: api.MachineStatus{Rescue: status},
+			}
+			Expect(r.rescueChanged(machine)).To(Equal(changed))
+		},
+		Entry("not rescued", nil, nil, false),
+		Entry("entering the rescue image", &api.RescueSpec{}, nil, true),
+		Entry("leaving the rescue image", nil, &api.RescueStatus{Image: defaultRescueImage}, true),
+		Entry("rescued with the default image", &api.RescueSpec{}, &api.RescueStatus{Image: defaultRescueImage}, false),
+		Entry("rescued with an explicit image", &api.RescueSpec{Image: explicitImage}, &api.RescueStatus{Image: explicitImage}, false),
+		Entry("switching to an explicit image", &api.RescueSpec{Image: explicitImage}, &api.RescueStatus{Image: defaultRescueImage}, true),
+		Entry("switching back to the default image", &api.RescueSpec{}, &api.RescueStatus{Image: explicitImage}, true),
+	)
+})
@@ -0,0 +1,150 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/go-logr/logr"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/journal"
+	"github.com/ironcore-dev/libvirt-provider/internal/store"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	DefaultResizeWorkers   = 2
+	DefaultResizeQueueSize = 100
+)
+
+// errResizeRequiresReconcile is returned if a resize can't be done on its own, e.g. because the volume plugin changed.
+var errResizeRequiresReconcile = errors.New("resize requires machine reconcile")
+
+type resizeRequest struct {
+	machineID  string
+	volumeName string
+}
+
+// enqueueResize adds a resize to the resize queue unless the queue is full, in which case the resize is picked
+// up again by the next volume size resync.
+func (r *MachineReconciler) enqueueResize(log logr.Logger, req resizeRequest) {
+	if r.resizeQueue.Len() >= r.resizeQueueSize {
+		log.V(1).Info("Resize queue is full, deferring resize to next resync", "machineID", req.machineID, "volumeName", req.volumeName)
+		return
+	}
+	r.resizeQueue.Add(req)
+}
+
+func (r *MachineReconciler) processNextResizeItem(ctx context.Context, log logr.Logger) bool {
+	req, shutdown := r.resizeQueue.Get()
+	if shutdown {
+		return false
+	}
+	defer r.resizeQueue.Done(req)
+
+	done, ok := r.startWork()
+	defer done()
+	if !ok {
+		return true
+	}
+
+	// Resizes run on their own few workers, which bounds how much they compete with the creations and deletions of
+	// the machine reconciles. The machine lock serializes a resize with the reconcile of its machine, so the resize
+	// works on the state the reconcile left behind.
+	log = log.WithValues("machineID", req.machineID, "volumeName", req.volumeName)
+	unlock := r.lockMachine(req.machineID)
+	err := r.resizeVolume(ctx, log, req)
+	unlock()
+	if err != nil {
+		if errors.Is(err, errResizeRequiresReconcile) {
+			log.V(1).Info("Falling back to machine reconcile for resize", "Reason", err.Error())
+			r.resizeQueue.Forget(req)
+			r.queue.Add(req.machineID)
+			return true
+		}
+
+		log.Error(err, "failed to resize volume")
+		r.resizeQueue.AddRateLimited(req)
+		return true
+	}
+
+	r.resizeQueue.Forget(req)
+	return true
+}
+
+// resizeVolume resizes a single volume of a running domain via the attacher, without reconciling the whole machine.
+func (r *MachineReconciler) resizeVolume(ctx context.Context, log logr.Logger, req resizeRequest) error {
+	machine, err := r.machines.Get(ctx, req.machineID)
+	if err != nil {
+		return store.IgnoreErrNotFound(err)
+	}
+	if machine.DeletedAt != nil || !slices.Contains(machine.Finalizers, MachineFinalizer) {
+		return nil
+	}
+
+	idx := slices.IndexFunc(machine.Spec.Volumes, func(volume *api.VolumeSpec) bool {
+		return volume.Name == req.volumeName
+	})
+	if idx < 0 {
+		return nil
+	}
+	spec := machine.Spec.Volumes[idx]
+	r.migrateVolumeStatusHandles(machine)
+
+	domainDesc, err := r.getDomainDesc(machine.ID)
+	if err != nil {
+		return fmt.Errorf("%w: error getting domain description: %w", errResizeRequiresReconcile, err)
+	}
+
+	attacher, err := NewLibvirtVolumeAttacher(domainDesc, r.newDomainExecutor(ctx, machine.ID), r.cpuPinning, r.virtioQueues(machine).disk)
+	if err != nil {
+		return fmt.Errorf("error constructing volume attacher: %w", err)
+	}
+
+	attached, err := attacher.GetVolume(spec.Name)
+	if err != nil {
+		return fmt.Errorf("%w: volume is not attached: %w", errResizeRequiresReconcile, err)
+	}
+
+	volumeID, providerVolume, err := r.machineVolumeMounter(machine).ApplyVolume(ctx, spec, func(*MountVolume) error {
+		return fmt.Errorf("%w: volume plugin changed", errResizeRequiresReconcile)
+	})
+	if err != nil {
+		return fmt.Errorf("error applying volume mount: %w", err)
+	}
+
+	lastVolumeSize := getLastVolumeSize(machine, volumeID)
+	if lastVolumeSize == 0 || providerVolume.Size == lastVolumeSize {
+		return nil
+	}
+
+	log.V(1).Info("Resize volume", "volumeID", volumeID, "lastSize", lastVolumeSize, "volumeSize", providerVolume.Size)
+	err = attacher.ResizeVolume(&AttachVolume{
+		Name:   spec.Name,
+		Device: attached.Device,
+		Spec:   *providerVolume,
+	})
+	r.recordOperation(log, machine.ID, journal.OperationResize, spec.Name, err)
+	if err != nil {
+		r.Eventf(log, machine.Metadata, corev1.EventTypeWarning, "ResizeVolumeFailed", "Failed to resize volume %s: %s", spec.Name, err)
+		return fmt.Errorf("failed to resize volume: %w", err)
+	}
+
+	setSize := func(machine *api.Machine) error {
+		for i := range machine.Status.VolumeStatus {
+			if status := &machine.Status.VolumeStatus[i]; status.Handle == volumeID {
+				status.Size = providerVolume.Size
+			}
+		}
+		return nil
+	}
+	_ = setSize(machine)
+	if _, err := store.RetryOnConflict(ctx, r.machines, machine, setSize); store.IgnoreErrNotFound(err) != nil {
+		return fmt.Errorf("failed to update machine status: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/host"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/keymutex"
+)
+
+var _ = Describe("Machine volume resize", func() {
+	var r *MachineReconciler
+
+	BeforeEach(func() {
+		machines, err := host.NewStore[*api.Machine](host.Options[*api.Machine]{
+			Dir:     GinkgoT().TempDir(),
+			NewFunc: func() *api.Machine { return &api.Machine{} },
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		r = &MachineReconciler{
+			machines:        machines,
+			machineLocks:    keymutex.NewHashed(0),
+			queue:           workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[string]()),
+			resizeQueue:     workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[resizeRequest]()),
+			resizeQueueSize: 1,
+		}
+		DeferCleanup(r.queue.ShutDown)
+		DeferCleanup(r.resizeQueue.ShutDown)
+	})
+
+	It("should wait for the reconcile of the machine", func(ctx SpecContext) {
+		unlock := r.lockMachine("foo")
+		r.enqueueResize(GinkgoLogr, resizeRequest{machineID: "foo", volumeName: "a"})
+
+		processed := make(chan bool, 1)
+		go func() {
+			processed <- r.processNextResizeItem(ctx, GinkgoLogr)
+		}()
+		Consistently(processed).ShouldNot(Receive())
+
+		unlock()
+		Eventually(processed).Should(Receive(BeTrue()))
+		Expect(r.resizeQueue.Len()).To(BeZero())
+	})
+
+	It("should not wait for queued reconciles of other machines", func(ctx SpecContext) {
+		r.queue.Add("bar")
+		r.enqueueResize(GinkgoLogr, resizeRequest{machineID: "foo", volumeName: "a"})
+
+		Expect(r.processNextResizeItem(ctx, GinkgoLogr)).To(BeTrue())
+		Expect(r.resizeQueue.Len()).To(BeZero())
+	})
+
+	It("should defer resizes to the next resync if the queue is full", func() {
+		r.enqueueResize(GinkgoLogr, resizeRequest{machineID: "foo", volumeName: "a"})
+		r.enqueueResize(GinkgoLogr, resizeRequest{machineID: "foo", volumeName: "b"})
+		Expect(r.resizeQueue.Len()).To(Equal(1))
+	})
+})
@@ -0,0 +1,138 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/digitalocean/go-libvirt"
+	"github.com/go-logr/logr"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/event"
+	libvirtutils "github.com/ironcore-dev/libvirt-provider/internal/libvirt/utils"
+	"github.com/ironcore-dev/libvirt-provider/internal/retrypolicy"
+	"github.com/ironcore-dev/libvirt-provider/internal/store"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// retryReconcile schedules the retry of the failed reconcile of the machine according to the retry policy of
+// the class of the error. Machines not retried anymore are marked failed until they change.
+func (r *MachineReconciler) retryReconcile(ctx context.Context, log logr.Logger, id string, err error) {
+	class := retrypolicy.ClassOf(err)
+	delay, retry := r.backoff.Next(id, class)
+	if retry {
+		log.Error(err, "failed to reconcile machine", "Class", class, "RetryAfter", delay)
+		r.queue.AddAfter(id, delay)
+		return
+	}
+
+	log.Error(err, "failed to reconcile machine, not retrying", "Class", class, "Retries", r.backoff.Retries(id))
+	r.markReconcileFailed(ctx, log, id, class, err)
+}
+
+// specVersion is the state of a machine changed by users only.
+type specVersion struct {
+	generation int64
+	deleting   bool
+}
+
+// forgetBackoffOnSpecChange resets the backoff of the machine if its spec changed since its last store event, so
+// changed machines get a new chance even if their retries were exhausted. Deleting a machine counts as a spec
+// change. It reports whether the backoff was reset.
+func (r *MachineReconciler) forgetBackoffOnSpecChange(machine *api.Machine, deleted bool) bool {
+	changed := true
+	if deleted {
+		r.specVersions.Delete(machine.ID)
+	} else {
+		version := specVersion{generation: machine.Generation, deleting: machine.DeletedAt != nil}
+		previous, ok := r.specVersions.Swap(machine.ID, version)
+		changed = !ok || previous != version
+	}
+	if changed {
+		r.backoff.Forget(machine.ID)
+	}
+	return changed
+}
+
+// enqueueMachineEvent requeues the machine of the store event. Spec changes are always reconciled, other events
+// are skipped for the status of a failed reconcile, which is retried with backoff, and for machines whose
+// reconcile was given up.
+func (r *MachineReconciler) enqueueMachineEvent(evt event.Event[*api.Machine]) {
+	specChanged := r.forgetBackoffOnSpecChange(evt.Object, evt.Type == event.TypeDeleted)
+	version, failedStatus := r.failedStatusVersions.LoadAndDelete(evt.Object.ID)
+	switch {
+	case specChanged:
+	case failedStatus && version == evt.Object.ResourceVersion:
+		return
+	case reconcileGivenUp(evt.Object):
+		return
+	}
+	if evt.Type == event.TypeDeleted || evt.Object.DeletedAt != nil {
+		r.cancelReconcile(evt.Object.ID)
+	}
+	r.queue.Add(evt.Object.ID)
+}
+
+// reconcileGivenUp reports whether the machine was marked failed because its reconcile isn't retried anymore.
+func reconcileGivenUp(machine *api.Machine) bool {
+	condition := api.GetMachineCondition(machine.Status.Conditions, api.MachineConditionDomainSynced)
+	return condition != nil && condition.Reason == conditionReasonFailed
+}
+
+// markReconcileFailed marks the machine failed by its DomainSynced condition. Machines without domain can't be
+// created on this host and are put into the Failed state with the reason of the failure.
+func (r *MachineReconciler) markReconcileFailed(ctx context.Context, log logr.Logger, id string, class retrypolicy.Class, err error) {
+	machine, getErr := r.machines.Get(ctx, id)
+	if getErr != nil {
+		if store.IgnoreErrNotFound(getErr) != nil {
+			log.Error(getErr, "failed to get machine to mark it failed")
+		}
+		return
+	}
+
+	_, lookupErr := r.conn().DomainLookupByUUID(libvirtutils.UUIDStringToBytes(machine.ID))
+	// Machines whose domain can't be looked up are assumed to have one.
+	hasDomain := !libvirt.IsNotFound(lookupErr)
+	if lookupErr != nil && hasDomain {
+		log.Error(lookupErr, "failed to look up domain of failed machine")
+	}
+	r.setReconcileFailed(log, machine, class, err, hasDomain)
+	r.updateFailedStatus(ctx, log, machine)
+}
+
+// setReconcileFailed sets the DomainSynced condition of the machine whose reconcile isn't retried anymore. Machines
+// without domain are put into the Failed state.
+func (r *MachineReconciler) setReconcileFailed(log logr.Logger, machine *api.Machine, class retrypolicy.Class, err error, hasDomain bool) {
+	message := fmt.Sprintf("%s error, not retrying: %v", class, err)
+	if class != retrypolicy.ClassPermanent {
+		message = fmt.Sprintf("%s error, giving up after %d retries: %v", class, r.backoff.Retries(machine.ID), err)
+	}
+	if !reconcileGivenUp(machine) {
+		r.Eventf(log, machine.Metadata, corev1.EventTypeWarning, "ReconcileFailed", "Machine failed: %s", message)
+	}
+	setCondition(machine, api.MachineConditionDomainSynced, false, conditionReasonFailed, message)
+
+	if !hasDomain && machine.DeletedAt == nil {
+		machine.Status.State = api.MachineStateFailed
+		machine.Status.FailureReason = failureReason(class)
+		machine.Status.FailureMessage = err.Error()
+	}
+}
+
+// failureReason is the reason of a machine failed with an error of the given class.
+func failureReason(class retrypolicy.Class) api.MachineFailureReason {
+	switch class {
+	case retrypolicy.ClassPermanent:
+		return api.MachineFailureReasonInvalidSpec
+	case retrypolicy.ClassUnschedulable:
+		return api.MachineFailureReasonUnschedulable
+	case retrypolicy.ClassLibvirt, retrypolicy.ClassTimeout:
+		return api.MachineFailureReasonLibvirtError
+	case retrypolicy.ClassImage:
+		return api.MachineFailureReasonImageError
+	default:
+		return api.MachineFailureReasonReconcileError
+	}
+}
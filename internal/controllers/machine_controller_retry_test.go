@@ -0,0 +1,211 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/event"
+	"github.com/ironcore-dev/libvirt-provider/internal/host"
+	"github.com/ironcore-dev/libvirt-provider/internal/retrypolicy"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/client-go/util/workqueue"
+)
+
+var _ = Describe("Machine retries", func() {
+	Describe("forgetBackoffOnSpecChange", func() {
+		var (
+			r       *MachineReconciler
+			machine *api.Machine
+		)
+
+		BeforeEach(func() {
+			r = &MachineReconciler{backoff: retrypolicy.NewBackoff(nil)}
+			machine = &api.Machine{Metadata: api.Metadata{ID: "foo", Generation: 1}}
+			Expect(r.forgetBackoffOnSpecChange(machine, false)).To(BeTrue())
+
+			for range 3 {
+				r.backoff.Next(machine.ID, retrypolicy.ClassDefault)
+			}
+		})
+
+		It("should keep the backoff on status writes", func() {
+			machine.ResourceVersion++
+			machine.Status.State = api.MachineStateRunning
+			Expect(r.forgetBackoffOnSpecChange(machine, false)).To(BeFalse())
+			Expect(r.backoff.Retries(machine.ID)).To(Equal(2))
+		})
+
+		It("should reset the backoff on spec changes", func() {
+			machine.IncrementGeneration()
+			Expect(r.forgetBackoffOnSpecChange(machine, false)).To(BeTrue())
+			Expect(r.backoff.Retries(machine.ID)).To(BeZero())
+		})
+
+		It("should reset the backoff once the machine is deleted", func() {
+			machine.DeletedAt = &time.Time{}
+			Expect(r.forgetBackoffOnSpecChange(machine, false)).To(BeTrue())
+			r.backoff.Next(machine.ID, retrypolicy.ClassDefault)
+			Expect(r.forgetBackoffOnSpecChange(machine, false)).To(BeFalse())
+			Expect(r.forgetBackoffOnSpecChange(machine, true)).To(BeTrue())
+		})
+	})
+
+	Describe("enqueueMachineEvent", func() {
+		var (
+			r       *MachineReconciler
+			machine *api.Machine
+		)
+
+		BeforeEach(func() {
+			r = &MachineReconciler{backoff: retrypolicy.NewBackoff(nil)}
+			r.queue = workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[string]())
+			DeferCleanup(r.queue.ShutDown)
+
+			machine = &api.Machine{Metadata: api.Metadata{ID: "foo", Generation: 1, ResourceVersion: 1}}
+			r.enqueueMachineEvent(event.Event[*api.Machine]{Type: event.TypeCreated, Object: machine})
+			Expect(r.queue.Len()).To(Equal(1))
+			id, _ := r.queue.Get()
+			r.queue.Done(id)
+		})
+
+		It("should skip the store event of the status of a failed reconcile", func() {
+			machine.ResourceVersion++
+			r.failedStatusVersions.Store(machine.ID, machine.ResourceVersion)
+			r.enqueueMachineEvent(event.Event[*api.Machine]{Type: event.TypeUpdated, Object: machine})
+			Expect(r.queue.Len()).To(BeZero())
+		})
+
+		It("should reconcile a spec change written with the status of a failed reconcile", func() {
+			machine.ResourceVersion++
+			machine.IncrementGeneration()
+			r.failedStatusVersions.Store(machine.ID, machine.ResourceVersion)
+			r.enqueueMachineEvent(event.Event[*api.Machine]{Type: event.TypeUpdated, Object: machine})
+			Expect(r.queue.Len()).To(Equal(1))
+		})
+
+		It("should skip status writes of machines whose reconcile was given up until their spec changes", func() {
+			setCondition(machine, api.MachineConditionDomainSynced, false, conditionReasonFailed, "permanent error")
+			machine.ResourceVersion++
+			r.enqueueMachineEvent(event.Event[*api.Machine]{Type: event.TypeUpdated, Object: machine})
+			Expect(r.queue.Len()).To(BeZero())
+
+			machine.IncrementGeneration()
+			r.enqueueMachineEvent(event.Event[*api.Machine]{Type: event.TypeUpdated, Object: machine})
+			Expect(r.queue.Len()).To(Equal(1))
+		})
+	})
+
+	It("should report machines whose reconcile was given up", func() {
+		machine := &api.Machine{}
+		Expect(reconcileGivenUp(machine)).To(BeFalse())
+
+		setCondition(machine, api.MachineConditionDomainSynced, false, conditionReasonFailed, "permanent error")
+		Expect(reconcileGivenUp(machine)).To(BeTrue())
+	})
+
+	DescribeTable("failureReason",
+		func(class retrypolicy.Class, reason api.MachineFailureReason) {
+			Expect(failureReason(class)).To(Equal(reason))
+		},
+		Entry("permanent", retrypolicy.ClassPermanent, api.MachineFailureReasonInvalidSpec),
+		Entry("unschedulable", retrypolicy.ClassUnschedulable, api.MachineFailureReasonUnschedulable),
+		Entry("libvirt", retrypolicy.ClassLibvirt, api.MachineFailureReasonLibvirtError),
+		Entry("timeout", retrypolicy.ClassTimeout, api.MachineFailureReasonLibvirtError),
+		Entry("image", retrypolicy.ClassImage, api.MachineFailureReasonImageError),
+		Entry("default", retrypolicy.ClassDefault, api.MachineFailureReasonReconcileError),
+	)
+
+	Describe("markReconcileFailed", func() {
+		var (
+			r        *MachineReconciler
+			recorder *countingEventRecorder
+			machines *host.Store[*api.Machine]
+			machine  *api.Machine
+		)
+
+		BeforeEach(func(ctx SpecContext) {
+			var err error
+			machines, err = host.NewStore[*api.Machine](host.Options[*api.Machine]{
+				Dir:     GinkgoT().TempDir(),
+				NewFunc: func() *api.Machine { return &api.Machine{} },
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			recorder = &countingEventRecorder{}
+			r = &MachineReconciler{machines: machines, backoff: retrypolicy.NewBackoff(nil), EventRecorder: recorder}
+			machine, err = machines.Create(ctx, &api.Machine{
+				Metadata: api.Metadata{ID: "foo"},
+				Status:   api.MachineStatus{State: api.MachineStatePending},
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should put machines without domain into the failed state", func() {
+			r.setReconcileFailed(GinkgoLogr, machine, retrypolicy.ClassPermanent, errors.New("invalid spec"), false)
+
+			Expect(machine.Status.State).To(Equal(api.MachineStateFailed))
+			Expect(machine.Status.FailureReason).To(Equal(api.MachineFailureReasonInvalidSpec))
+			Expect(machine.Status.FailureMessage).To(Equal("invalid spec"))
+			Expect(reconcileGivenUp(machine)).To(BeTrue())
+			Expect(recorder.events).To(Equal(1))
+		})
+
+		It("should persist the failed status", func(ctx SpecContext) {
+			r.setReconcileFailed(GinkgoLogr, machine, retrypolicy.ClassPermanent, errors.New("invalid spec"), false)
+			r.updateFailedStatus(ctx, GinkgoLogr, machine)
+
+			latest, err := machines.Get(ctx, "foo")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(latest.Status.State).To(Equal(api.MachineStateFailed))
+			Expect(latest.Status.FailureReason).To(Equal(api.MachineFailureReasonInvalidSpec))
+			Expect(reconcileGivenUp(latest)).To(BeTrue())
+
+			By("suppressing the store event of the failed status only")
+			version, ok := r.failedStatusVersions.Load("foo")
+			Expect(ok).To(BeTrue())
+			Expect(version).To(Equal(latest.ResourceVersion))
+		})
+
+		It("should keep the state of machines with domain", func() {
+			for range 3 {
+				r.backoff.Next(machine.ID, retrypolicy.ClassLibvirt)
+			}
+			r.setReconcileFailed(GinkgoLogr, machine, retrypolicy.ClassLibvirt, errors.New("libvirt failed"), true)
+
+			Expect(machine.Status.State).To(Equal(api.MachineStatePending))
+			Expect(machine.Status.FailureReason).To(BeEmpty())
+			condition := api.GetMachineCondition(machine.Status.Conditions, api.MachineConditionDomainSynced)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Message).To(ContainSubstring("giving up after 2 retries: libvirt failed"))
+		})
+
+		It("should report the failure once", func() {
+			for range 2 {
+				r.setReconcileFailed(GinkgoLogr, machine, retrypolicy.ClassPermanent, errors.New("invalid spec"), false)
+			}
+			Expect(recorder.events).To(Equal(1))
+		})
+
+		It("should not fail deleted machines", func() {
+			machine.DeletedAt = &time.Time{}
+			r.setReconcileFailed(GinkgoLogr, machine, retrypolicy.ClassPermanent, errors.New("invalid spec"), false)
+			Expect(machine.Status.State).To(Equal(api.MachineStatePending))
+		})
+	})
+})
+
+// countingEventRecorder counts the events recorded.
+type countingEventRecorder struct {
+	events int
+}
+
+func (r *countingEventRecorder) Eventf(logr.Logger, api.Metadata, string, string, string, ...any) {
+	r.events++
+}
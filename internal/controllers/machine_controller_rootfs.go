@@ -0,0 +1,202 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	providerimage "github.com/ironcore-dev/libvirt-provider/internal/oci"
+	"github.com/ironcore-dev/libvirt-provider/internal/osutils"
+	"github.com/ironcore-dev/libvirt-provider/internal/qcow2"
+	"github.com/ironcore-dev/libvirt-provider/internal/raw"
+	"github.com/ironcore-dev/libvirt-provider/internal/retrypolicy"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// rootFSCreationRetryDelay is the delay after which a machine waiting for a root fs creation slot is
+	// reconciled again. Waiting machines are reconciled as soon as a slot is released as well, the delay only
+	// keeps them from losing their place in the queue.
+	rootFSCreationRetryDelay = 10 * time.Second
+	// rootFSCreationStaleAfter is the time after which a machine waiting for a root fs creation slot loses its
+	// place in the queue if it didn't ask for a slot again, e.g. since it doesn't need a root fs anymore.
+	rootFSCreationStaleAfter = time.Minute
+)
+
+// errRootFSCreationQueued is returned if the root fs disk of a machine can't be created because all root fs
+// creation slots are taken.
+var errRootFSCreationQueued = errors.New("root fs creation queued")
+
+// rootFSCreationQueue hands out a bounded number of root fs creation slots in the order the machines asked for
+// them. Copying large images saturates the disk, bounding the concurrent copies keeps the IO of running guests
+// from starving. A nil queue doesn't bound the root fs creations.
+type rootFSCreationQueue struct {
+	slots int
+	// ready is called with the waiting machines a slot became free for.
+	ready func(machineID string)
+
+	mu      sync.Mutex
+	used    int
+	waiting []rootFSCreationWaiter
+}
+
+type rootFSCreationWaiter struct {
+	machineID string
+	lastSeen  time.Time
+}
+
+func newRootFSCreationQueue(slots int, ready func(machineID string)) *rootFSCreationQueue {
+	if slots <= 0 {
+		return nil
+	}
+	return &rootFSCreationQueue{slots: slots, ready: ready}
+}
+
+// acquire takes a root fs creation slot for the machine without waiting for it. If the free slots are taken by
+// machines that asked before, the machine is queued and acquire returns false. The returned function releases
+// the slot again.
+func (q *rootFSCreationQueue) acquire(machineID string) (func(), bool) {
+	if q == nil {
+		return func() {}, true
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	q.waiting = slices.DeleteFunc(q.waiting, func(w rootFSCreationWaiter) bool {
+		return w.machineID != machineID && now.Sub(w.lastSeen) > rootFSCreationStaleAfter
+	})
+
+	idx := slices.IndexFunc(q.waiting, func(w rootFSCreationWaiter) bool { return w.machineID == machineID })
+	if idx < 0 {
+		idx = len(q.waiting)
+		q.waiting = append(q.waiting, rootFSCreationWaiter{machineID: machineID})
+	}
+	q.waiting[idx].lastSeen = now
+	if idx >= q.slots-q.used {
+		return nil, false
+	}
+
+	q.waiting = slices.Delete(q.waiting, idx, idx+1)
+	q.used++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.mu.Lock()
+			q.used--
+			q.mu.Unlock()
+			q.notify()
+		})
+	}, true
+}
+
+// forget removes the machine from the queue, e.g. since it was deleted.
+func (q *rootFSCreationQueue) forget(machineID string) {
+	if q == nil {
+		return
+	}
+
+	q.mu.Lock()
+	idx := slices.IndexFunc(q.waiting, func(w rootFSCreationWaiter) bool { return w.machineID == machineID })
+	if idx >= 0 {
+		q.waiting = slices.Delete(q.waiting, idx, idx+1)
+	}
+	q.mu.Unlock()
+
+	if idx >= 0 {
+		q.notify()
+	}
+}
+
+// notify calls ready with the waiting machines the free slots are handed out to.
+func (q *rootFSCreationQueue) notify() {
+	q.mu.Lock()
+	var ready []string
+	for _, w := range q.waiting[:min(max(q.slots-q.used, 0), len(q.waiting))] {
+		ready = append(ready, w.machineID)
+	}
+	q.mu.Unlock()
+
+	for _, machineID := range ready {
+		q.ready(machineID)
+	}
+}
+
+// pinImageDigest records the digest of the image the domain of the machine is created from, keeping the image
+// from being evicted. An existing overlay stays backed by the root fs of the image it was created from, even if
+// the ref resolves to another image by now, hence its digest is kept.
+func pinImageDigest(machine *api.Machine, img *providerimage.Image) {
+	if machine.Spec.RootFSMode == api.RootFSModeOverlay && machine.Status.ImageDigest != "" {
+		return
+	}
+	machine.Status.ImageDigest = img.Digest.String()
+}
+
+// prepareRootFS returns the file and format of the root fs disk of the machine according to its root fs mode,
+// creating the per-machine file if it doesn't exist yet.
+func (r *MachineReconciler) prepareRootFS(log logr.Logger, machine *api.Machine, img *providerimage.Image, machineImgRef string) (string, string, error) {
+	switch machine.Spec.RootFSMode {
+	case api.RootFSModeShared:
+		if !img.IsDirectKernelBoot() {
+			return "", "", retrypolicy.Permanent(fmt.Errorf("root fs mode %s requires a direct kernel boot image, %s boots via firmware", api.RootFSModeShared, machineImgRef))
+		}
+		// The image is pinned by the machine, hence the cached root fs isn't evicted while the machine exists.
+		return img.RootFS.Path, "raw", nil
+	case api.RootFSModeOverlay:
+		rootFSFile := r.host.MachineRootFSFile(machine.ID)
+		ok, err := osutils.RegularFileExists(rootFSFile)
+		if err != nil {
+			return "", "", err
+		}
+		if !ok {
+			if r.qcow2 == nil {
+				return "", "", fmt.Errorf("root fs mode %s requires a qcow2 implementation", api.RootFSModeOverlay)
+			}
+			if err := r.qcow2.Create(rootFSFile, qcow2.WithSourceFile(img.RootFS.Path)); err != nil {
+				return "", "", fmt.Errorf("error creating root fs overlay: %w", err)
+			}
+			if err := os.Chmod(rootFSFile, filePerm); err != nil {
+				return "", "", fmt.Errorf("error changing root fs overlay mode: %w", err)
+			}
+			log.V(1).Info("Created root fs overlay", "BackingFile", img.RootFS.Path)
+		}
+		return rootFSFile, "qcow2", nil
+	default:
+		rootFSFile := r.host.MachineRootFSFile(machine.ID)
+		ok, err := osutils.RegularFileExists(rootFSFile)
+		if err != nil {
+			return "", "", err
+		}
+		if ok {
+			return rootFSFile, "raw", nil
+		}
+
+		release, acquired := r.rootFSCreations.acquire(machine.ID)
+		if !acquired {
+			if condition := api.GetMachineCondition(machine.Status.Conditions, api.MachineConditionImageReady); condition == nil || condition.Reason != conditionReasonRootFSQueued {
+				r.Eventf(log, machine.Metadata, corev1.EventTypeNormal, "RootFSCreationQueued", "Waiting for one of %d root fs creation slots", r.rootFSCreations.slots)
+			}
+			setCondition(machine, api.MachineConditionImageReady, false, conditionReasonRootFSQueued, "Waiting for a free root fs creation slot")
+			return "", "", errRootFSCreationQueued
+		}
+		defer release()
+
+		r.Eventf(log, machine.Metadata, corev1.EventTypeNormal, "CreatingRootFS", "Creating root fs disk from image %s", machineImgRef)
+		if err := r.raw.Create(rootFSFile, raw.WithSourceFile(img.RootFS.Path)); err != nil {
+			return "", "", fmt.Errorf("error creating root fs disk: %w", err)
+		}
+		if err := os.Chmod(rootFSFile, filePerm); err != nil {
+			return "", "", fmt.Errorf("error changing root fs disk mode: %w", err)
+		}
+		return rootFSFile, "raw", nil
+	}
+}
@@ -0,0 +1,150 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"time"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	providerimage "github.com/ironcore-dev/libvirt-provider/internal/oci"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/opencontainers/go-digest"
+)
+
+var _ = Describe("Machine root fs", func() {
+	Describe("pinImageDigest", func() {
+		var (
+			created = &providerimage.Image{Digest: digest.FromString("created")}
+			current = &providerimage.Image{Digest: digest.FromString("current")}
+		)
+
+		It("should pin the image the domain is created from", func() {
+			machine := &api.Machine{}
+			pinImageDigest(machine, created)
+			Expect(machine.Status.ImageDigest).To(Equal(created.Digest.String()))
+		})
+
+		It("should pin the current image of a copied or shared root fs", func() {
+			for _, mode := range []api.RootFSMode{api.RootFSModeCopy, api.RootFSModeShared} {
+				machine := &api.Machine{
+					Spec:   api.MachineSpec{RootFSMode: mode},
+					Status: api.MachineStatus{ImageDigest: created.Digest.String()},
+				}
+				pinImageDigest(machine, current)
+				Expect(machine.Status.ImageDigest).To(Equal(current.Digest.String()), "root fs mode %s", mode)
+			}
+		})
+
+		It("should keep the image an overlay was created from", func() {
+			machine := &api.Machine{
+				Spec:   api.MachineSpec{RootFSMode: api.RootFSModeOverlay},
+				Status: api.MachineStatus{ImageDigest: created.Digest.String()},
+			}
+			pinImageDigest(machine, current)
+			Expect(machine.Status.ImageDigest).To(Equal(created.Digest.String()))
+		})
+	})
+
+	Describe("prepareRootFS", func() {
+		It("should use the cached root fs of a shared image", func() {
+			r := &MachineReconciler{}
+			img := &providerimage.Image{
+				RootFS:    &providerimage.FileLayer{Path: "/images/rootfs"},
+				Kernel:    &providerimage.FileLayer{Path: "/images/kernel"},
+				InitRAMFs: &providerimage.FileLayer{Path: "/images/initramfs"},
+			}
+			machine := &api.Machine{Spec: api.MachineSpec{RootFSMode: api.RootFSModeShared}}
+
+			file, format, err := r.prepareRootFS(GinkgoLogr, machine, img, "image:latest")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(file).To(Equal("/images/rootfs"))
+			Expect(format).To(Equal("raw"))
+		})
+
+		It("should reject sharing the root fs of an image booted via firmware", func() {
+			r := &MachineReconciler{}
+			img := &providerimage.Image{RootFS: &providerimage.FileLayer{Path: "/images/rootfs"}}
+			machine := &api.Machine{Spec: api.MachineSpec{RootFSMode: api.RootFSModeShared}}
+
+			_, _, err := r.prepareRootFS(GinkgoLogr, machine, img, "image:latest")
+			Expect(err).To(MatchError(ContainSubstring("requires a direct kernel boot image")))
+		})
+	})
+
+	Describe("rootFSCreationQueue", func() {
+		var (
+			queue *rootFSCreationQueue
+			ready []string
+		)
+
+		BeforeEach(func() {
+			ready = nil
+			queue = newRootFSCreationQueue(1, func(machineID string) { ready = append(ready, machineID) })
+		})
+
+		It("should hand out the slots in the order the machines asked for them", func() {
+			release, ok := queue.acquire("foo")
+			Expect(ok).To(BeTrue())
+			_, ok = queue.acquire("bar")
+			Expect(ok).To(BeFalse())
+			_, ok = queue.acquire("baz")
+			Expect(ok).To(BeFalse())
+
+			By("notifying the first waiting machine once the slot is released")
+			release()
+			release()
+			Expect(ready).To(Equal([]string{"bar"}))
+
+			By("keeping the slot for the first waiting machine")
+			_, ok = queue.acquire("baz")
+			Expect(ok).To(BeFalse())
+			release, ok = queue.acquire("bar")
+			Expect(ok).To(BeTrue())
+
+			release()
+			Expect(ready).To(Equal([]string{"bar", "baz"}))
+			_, ok = queue.acquire("baz")
+			Expect(ok).To(BeTrue())
+		})
+
+		It("should hand the slot to the next machine if a waiting machine is forgotten", func() {
+			release, ok := queue.acquire("foo")
+			Expect(ok).To(BeTrue())
+			_, ok = queue.acquire("bar")
+			Expect(ok).To(BeFalse())
+			_, ok = queue.acquire("baz")
+			Expect(ok).To(BeFalse())
+			release()
+			Expect(ready).To(Equal([]string{"bar"}))
+
+			queue.forget("bar")
+			Expect(ready).To(Equal([]string{"bar", "baz"}))
+			_, ok = queue.acquire("baz")
+			Expect(ok).To(BeTrue())
+		})
+
+		It("should drop machines that stopped asking for a slot", func() {
+			release, ok := queue.acquire("foo")
+			Expect(ok).To(BeTrue())
+			_, ok = queue.acquire("bar")
+			Expect(ok).To(BeFalse())
+			_, ok = queue.acquire("baz")
+			Expect(ok).To(BeFalse())
+			release()
+
+			queue.waiting[0].lastSeen = time.Now().Add(-2 * rootFSCreationStaleAfter)
+			_, ok = queue.acquire("baz")
+			Expect(ok).To(BeTrue())
+		})
+
+		It("should not bound the root fs creations without slots", func() {
+			queue = newRootFSCreationQueue(0, nil)
+			for range 3 {
+				_, ok := queue.acquire("foo")
+				Expect(ok).To(BeTrue())
+			}
+		})
+	})
+})
@@ -0,0 +1,129 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	providerhost "github.com/ironcore-dev/libvirt-provider/internal/host"
+)
+
+const (
+	// volumeSecretsFile keeps the secret and encryption data of a volume connection in the volume directory. The
+	// machine snapshot in the domain metadata is readable with read-only libvirt access, so it doesn't contain
+	// them and they are restored from here when the machine is reconstructed.
+	volumeSecretsFile = "secrets.json"
+
+	secretFilePerm = 0600
+	volumeDirPerm  = 0777
+)
+
+type volumeSecrets struct {
+	SecretData     map[string][]byte `json:"secretData,omitempty"`
+	EncryptionData map[string][]byte `json:"encryptionData,omitempty"`
+}
+
+// withoutSecrets returns a copy of the machine without its ignition and the secret and encryption data of its
+// volumes.
+func withoutSecrets(machine *api.Machine) *api.Machine {
+	res := *machine
+	res.Spec.Ignition = nil
+	res.Spec.Volumes = make([]*api.VolumeSpec, 0, len(machine.Spec.Volumes))
+	for _, volume := range machine.Spec.Volumes {
+		if volume.Connection != nil {
+			connection := *volume.Connection
+			connection.SecretData = nil
+			connection.EncryptionData = nil
+			stripped := *volume
+			stripped.Connection = &connection
+			volume = &stripped
+		}
+		res.Spec.Volumes = append(res.Spec.Volumes, volume)
+	}
+	return &res
+}
+
+// writeVolumeSecrets writes the secret and encryption data of the volume to volumeDir, or removes them if there
+// are none.
+func writeVolumeSecrets(volumeDir string, spec *api.VolumeSpec) error {
+	path := filepath.Join(volumeDir, volumeSecretsFile)
+	connection := spec.Connection
+	if connection == nil || (len(connection.SecretData) == 0 && len(connection.EncryptionData) == 0) {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error removing volume secrets: %w", err)
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(&volumeSecrets{
+		SecretData:     connection.SecretData,
+		EncryptionData: connection.EncryptionData,
+	})
+	if err != nil {
+		return fmt.Errorf("error marshalling volume secrets: %w", err)
+	}
+	if err := os.MkdirAll(volumeDir, volumeDirPerm); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, secretFilePerm); err != nil {
+		return fmt.Errorf("error writing volume secrets: %w", err)
+	}
+	return nil
+}
+
+// restoreSecrets restores the ignition and the secret and encryption data of the volumes of a machine
+// reconstructed from its domain from the machine directory. Missing files are skipped, the machine then lacks
+// the data.
+func restoreSecrets(paths providerhost.Paths, machine *api.Machine) error {
+	ignition, err := os.ReadFile(paths.MachineIgnitionFile(machine.ID))
+	switch {
+	case err == nil:
+		machine.Spec.Ignition = ignition
+	case !errors.Is(err, os.ErrNotExist):
+		return fmt.Errorf("error reading ignition: %w", err)
+	}
+
+	pluginEntries, err := os.ReadDir(paths.MachineVolumesDir(machine.ID))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("error reading volumes directory: %w", err)
+	}
+
+	for _, volume := range machine.Spec.Volumes {
+		if volume.Connection == nil {
+			continue
+		}
+		for _, pluginEntry := range pluginEntries {
+			if !pluginEntry.IsDir() {
+				continue
+			}
+
+			path := filepath.Join(paths.MachineVolumesDir(machine.ID), pluginEntry.Name(), volume.Name, volumeSecretsFile)
+			data, err := os.ReadFile(path)
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					continue
+				}
+				return fmt.Errorf("[volume %s] error reading volume secrets: %w", volume.Name, err)
+			}
+
+			secrets := &volumeSecrets{}
+			if err := json.Unmarshal(data, secrets); err != nil {
+				return fmt.Errorf("[volume %s] error unmarshalling volume secrets: %w", volume.Name, err)
+			}
+			volume.Connection.SecretData = maps.Clone(secrets.SecretData)
+			volume.Connection.EncryptionData = maps.Clone(secrets.EncryptionData)
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	providerhost "github.com/ironcore-dev/libvirt-provider/internal/host"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	utilstrings "k8s.io/utils/strings"
+)
+
+var _ = Describe("Machine secrets", func() {
+	newMachine := func() *api.Machine {
+		return &api.Machine{
+			Metadata: api.Metadata{ID: "machine-1"},
+			Spec: api.MachineSpec{
+				Ignition: []byte("ignition"),
+				Volumes: []*api.VolumeSpec{
+					{
+						Name: "disk",
+						Connection: &api.VolumeConnection{
+							Driver:         "ceph",
+							Handle:         "pool/image",
+							SecretData:     map[string][]byte{"userKey": []byte("key")},
+							EncryptionData: map[string][]byte{"encryptionKey": []byte("luks")},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	It("should leave the secrets out of the machine snapshot", func() {
+		machine := newMachine()
+		data, err := machineSnapshotData(machine)
+		Expect(err).NotTo(HaveOccurred())
+
+		snapshot := &api.Machine{}
+		Expect(json.Unmarshal(data, snapshot)).To(Succeed())
+		Expect(snapshot.Spec.Ignition).To(BeEmpty())
+		Expect(snapshot.Spec.Volumes[0].Connection.Handle).To(Equal("pool/image"))
+		Expect(snapshot.Spec.Volumes[0].Connection.SecretData).To(BeNil())
+		Expect(snapshot.Spec.Volumes[0].Connection.EncryptionData).To(BeNil())
+
+		By("keeping the secrets of the machine")
+		Expect(machine.Spec.Ignition).To(Equal([]byte("ignition")))
+		Expect(machine.Spec.Volumes[0].Connection.SecretData).To(HaveKey("userKey"))
+	})
+
+	It("should restore the secrets from the machine directory", func() {
+		paths, err := providerhost.PathsAt(GinkgoT().TempDir())
+		Expect(err).NotTo(HaveOccurred())
+		machine := newMachine()
+		Expect(providerhost.MakeMachineDirs(paths, machine.ID)).To(Succeed())
+
+		Expect(os.WriteFile(paths.MachineIgnitionFile(machine.ID), machine.Spec.Ignition, filePerm)).To(Succeed())
+		volumeDir := paths.MachineVolumeDir(machine.ID, utilstrings.EscapeQualifiedName("libvirt-provider.ironcore.dev/ceph"), "disk")
+		Expect(writeVolumeSecrets(volumeDir, machine.Spec.Volumes[0])).To(Succeed())
+
+		reconstructed := withoutSecrets(machine)
+		Expect(restoreSecrets(paths, reconstructed)).To(Succeed())
+		Expect(reconstructed.Spec).To(Equal(machine.Spec))
+	})
+
+	It("should remove the secrets of volumes without secrets", func() {
+		volumeDir := GinkgoT().TempDir()
+		volume := newMachine().Spec.Volumes[0]
+		Expect(writeVolumeSecrets(volumeDir, volume)).To(Succeed())
+		Expect(volumeDir + "/" + volumeSecretsFile).To(BeAnExistingFile())
+
+		volume.Connection.SecretData, volume.Connection.EncryptionData = nil, nil
+		Expect(writeVolumeSecrets(volumeDir, volume)).To(Succeed())
+		Expect(volumeDir + "/" + volumeSecretsFile).NotTo(BeAnExistingFile())
+	})
+})
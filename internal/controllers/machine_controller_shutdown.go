@@ -0,0 +1,156 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/digitalocean/go-libvirt"
+	"github.com/go-logr/logr"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/call"
+	libvirtutils "github.com/ironcore-dev/libvirt-provider/internal/libvirt/utils"
+	"github.com/ironcore-dev/libvirt-provider/internal/store"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// nextShutdownStage returns the stage the shutdown of the machine escalates to from the given stage. The guest
+// agent stage is skipped for machines without qemu guest agent.
+func (r *MachineReconciler) nextShutdownStage(machine *api.Machine, stage api.ShutdownStage) api.ShutdownStage {
+	if stage == api.ShutdownStageACPI && machine.Spec.GuestAgent == api.GuestAgentQemu && r.gcVMGuestAgentShutdownTimeout > 0 {
+		return api.ShutdownStageGuestAgent
+	}
+	return api.ShutdownStageDestroy
+}
+
+func (r *MachineReconciler) shutdownStageTimeout(stage api.ShutdownStage) time.Duration {
+	switch stage {
+	case api.ShutdownStageACPI:
+		return r.gcVMGracefulShutdownTimeout
+	case api.ShutdownStageGuestAgent:
+		return r.gcVMGuestAgentShutdownTimeout
+	default:
+		return 0
+	}
+}
+
+// deleteMachine shuts down the domain of the deleted machine, escalating from an ACPI shutdown over a guest
+// agent shutdown to destroying the domain once a stage times out. It reports whether the shutdown is still in
+// progress.
+func (r *MachineReconciler) deleteMachine(ctx context.Context, log logr.Logger, machine *api.Machine) (bool, error) {
+	domain := libvirt.Domain{
+		UUID: libvirtutils.UUIDStringToBytes(machine.ID),
+	}
+
+	var (
+		now     = time.Now()
+		changed bool
+	)
+	if machine.Spec.ShutdownAt.IsZero() {
+		machine.Status.State = api.MachineStateTerminating
+		machine.Spec.ShutdownAt = now
+		changed = true
+	}
+
+	shutdown := machine.Status.Shutdown
+	if shutdown == nil {
+		// Machines deleted before the shutdown was staged continue from their ShutdownAt.
+		shutdown = &api.ShutdownStatus{Stage: api.ShutdownStageACPI, StageStartedAt: machine.Spec.ShutdownAt}
+		changed = true
+	}
+	if escalated := r.escalateShutdown(log, machine, shutdown, now); escalated != shutdown {
+		shutdown = escalated
+		changed = true
+	}
+
+	if shutdown.Stage == api.ShutdownStageDestroy {
+		if err := r.updateShutdown(ctx, log, machine, shutdown, changed); err != nil {
+			return false, err
+		}
+		return false, r.destroyDomain(ctx, log, machine, domain)
+	}
+
+	// Due to heavy load, the shutdown signal might be missed by the VM. Hence, the shutdown is requested again
+	// after the resend interval, but not on every garbage collection to not flood the guest.
+	if !shutdown.RequestedAt.IsZero() && (r.gcVMShutdownResendInterval <= 0 || now.Before(shutdown.RequestedAt.Add(r.gcVMShutdownResendInterval))) {
+		if err := call.Do(ctx, r.libvirtCallTimeout, func() error {
+			_, _, err := r.conn().DomainGetState(domain, 0)
+			return err
+		}); err != nil {
+			if libvirt.IsNotFound(err) {
+				return false, nil
+			}
+			return false, fmt.Errorf("error getting domain state: %w", err)
+		}
+		return true, r.updateShutdown(ctx, log, machine, shutdown, changed)
+	}
+
+	requested, err := r.shutdownMachine(ctx, log, machine, domain, shutdown.Stage)
+	if err != nil || !requested {
+		return requested, err
+	}
+	shutdown.RequestedAt = now
+	return true, r.updateShutdown(ctx, log, machine, shutdown, true)
+}
+
+// escalateShutdown returns the shutdown escalated to the stage reached at now, or the given shutdown if none of its
+// stages timed out.
+func (r *MachineReconciler) escalateShutdown(log logr.Logger, machine *api.Machine, shutdown *api.ShutdownStatus, now time.Time) *api.ShutdownStatus {
+	for shutdown.Stage != api.ShutdownStageDestroy {
+		deadline := shutdown.StageStartedAt.Add(r.shutdownStageTimeout(shutdown.Stage))
+		if now.Before(deadline) {
+			break
+		}
+
+		next := r.nextShutdownStage(machine, shutdown.Stage)
+		log.V(1).Info("Escalating shutdown", "Stage", shutdown.Stage, "NextStage", next)
+		// The next stage starts at the deadline of the previous one, so the time the provider was down counts.
+		shutdown = &api.ShutdownStatus{Stage: next, StageStartedAt: deadline}
+	}
+	return shutdown
+}
+
+// updateShutdown persists the shutdown progress of the machine if it changed.
+func (r *MachineReconciler) updateShutdown(ctx context.Context, log logr.Logger, machine *api.Machine, shutdown *api.ShutdownStatus, changed bool) error {
+	if !changed {
+		return nil
+	}
+
+	machine.Status.Shutdown = shutdown
+	updated, err := store.RetryOnConflict(ctx, r.machines, machine, func(latest *api.Machine) error {
+		latest.Status.Shutdown = shutdown
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update shutdown: %w", err)
+	}
+	*machine = *updated
+	log.V(1).Info("Updated shutdown", "ShutdownAt", machine.Spec.ShutdownAt, "Stage", shutdown.Stage, "RequestedAt", shutdown.RequestedAt)
+	return nil
+}
+
+// shutdownMachine requests the shutdown of the domain in the given stage. It reports whether the domain still
+// exists.
+func (r *MachineReconciler) shutdownMachine(ctx context.Context, log logr.Logger, machine *api.Machine, domain libvirt.Domain, stage api.ShutdownStage) (bool, error) {
+	log.V(1).Info("Triggering shutdown", "ShutdownAt", machine.Spec.ShutdownAt, "Stage", stage)
+
+	shutdownMode := libvirt.DomainShutdownAcpiPowerBtn
+	if stage == api.ShutdownStageGuestAgent {
+		shutdownMode = libvirt.DomainShutdownGuestAgent
+	}
+	conn, abort := r.mutatingConn()
+	if err := call.Mutate(ctx, r.libvirtCallTimeout, abort, func() error {
+		return conn.DomainShutdownFlags(domain, shutdownMode)
+	}); err != nil {
+		if libvirt.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to initiate shutdown: %w", err)
+	}
+
+	r.Eventf(log, machine.Metadata, corev1.EventTypeNormal, "TriggeringShutdown", "Shutdown Triggered (%s)", stage)
+	return true, nil
+}
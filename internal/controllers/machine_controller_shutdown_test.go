@@ -0,0 +1,119 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"time"
+
+	"github.com/digitalocean/go-libvirt"
+	"github.com/digitalocean/go-libvirt/libvirttest"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/host"
+	"github.com/ironcore-dev/libvirt-provider/internal/store"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Machine shutdown", func() {
+	var (
+		r        *MachineReconciler
+		machines store.Store[*api.Machine]
+	)
+
+	BeforeEach(func() {
+		lv := libvirt.NewWithDialer(libvirttest.New())
+		Expect(lv.Connect()).To(Succeed())
+		DeferCleanup(lv.Disconnect)
+
+		var err error
+		machines, err = host.NewStore[*api.Machine](host.Options[*api.Machine]{
+			Dir:     GinkgoT().TempDir(),
+			NewFunc: func() *api.Machine { return &api.Machine{} },
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		r = &MachineReconciler{
+			libvirt:                       lv,
+			machines:                      machines,
+			EventRecorder:                 nopEventRecorder{},
+			gcVMGracefulShutdownTimeout:   time.Minute,
+			gcVMGuestAgentShutdownTimeout: time.Minute,
+			gcVMShutdownResendInterval:    30 * time.Second,
+		}
+	})
+
+	Describe("escalateShutdown", func() {
+		started := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		acpi := &api.ShutdownStatus{Stage: api.ShutdownStageACPI, StageStartedAt: started}
+
+		It("should keep the stage until it times out", func() {
+			Expect(r.escalateShutdown(GinkgoLogr, &api.Machine{}, acpi, started.Add(time.Second))).To(BeIdenticalTo(acpi))
+		})
+
+		It("should escalate over the guest agent of machines with qemu guest agent", func() {
+			machine := &api.Machine{Spec: api.MachineSpec{GuestAgent: api.GuestAgentQemu}}
+
+			shutdown := r.escalateShutdown(GinkgoLogr, machine, acpi, started.Add(90*time.Second))
+			Expect(shutdown).To(Equal(&api.ShutdownStatus{Stage: api.ShutdownStageGuestAgent, StageStartedAt: started.Add(time.Minute)}))
+
+			By("counting the time the provider was down towards the stages")
+			shutdown = r.escalateShutdown(GinkgoLogr, machine, acpi, started.Add(time.Hour))
+			Expect(shutdown).To(Equal(&api.ShutdownStatus{Stage: api.ShutdownStageDestroy, StageStartedAt: started.Add(2 * time.Minute)}))
+		})
+
+		It("should skip the guest agent stage of machines without guest agent", func() {
+			shutdown := r.escalateShutdown(GinkgoLogr, &api.Machine{}, acpi, started.Add(90*time.Second))
+			Expect(shutdown).To(Equal(&api.ShutdownStatus{Stage: api.ShutdownStageDestroy, StageStartedAt: started.Add(time.Minute)}))
+
+			r.gcVMGuestAgentShutdownTimeout = 0
+			machine := &api.Machine{Spec: api.MachineSpec{GuestAgent: api.GuestAgentQemu}}
+			Expect(r.nextShutdownStage(machine, api.ShutdownStageACPI)).To(Equal(api.ShutdownStageDestroy))
+		})
+	})
+
+	Describe("deleteMachine", func() {
+		var machine *api.Machine
+
+		BeforeEach(func(ctx SpecContext) {
+			var err error
+			machine, err = machines.Create(ctx, &api.Machine{Metadata: api.Metadata{ID: "2a4a1d5e-4b43-4c09-a2ff-c8f5c3bb5b1a"}})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		storedShutdown := func(ctx SpecContext) *api.ShutdownStatus {
+			stored, err := machines.Get(ctx, machine.ID)
+			Expect(err).NotTo(HaveOccurred())
+			return stored.Status.Shutdown
+		}
+
+		It("should persist the requested shutdown and not request it again until the resend interval passed", func(ctx SpecContext) {
+			Expect(r.deleteMachine(ctx, GinkgoLogr, machine)).To(BeTrue())
+			Expect(machine.Spec.ShutdownAt).NotTo(BeZero())
+			shutdown := storedShutdown(ctx)
+			Expect(shutdown.Stage).To(Equal(api.ShutdownStageACPI))
+			Expect(shutdown.RequestedAt).NotTo(BeZero())
+			requestedAt := shutdown.RequestedAt
+
+			By("not requesting the shutdown on the next garbage collection")
+			Expect(r.deleteMachine(ctx, GinkgoLogr, machine)).To(BeTrue())
+			Expect(storedShutdown(ctx).RequestedAt).To(Equal(requestedAt))
+
+			By("requesting the shutdown again after the resend interval")
+			r.gcVMShutdownResendInterval = time.Nanosecond
+			Expect(r.deleteMachine(ctx, GinkgoLogr, machine)).To(BeTrue())
+			Expect(storedShutdown(ctx).RequestedAt).To(BeTemporally(">", requestedAt))
+		})
+
+		It("should continue the shutdown of machines deleted before it was staged from their shutdown time", func(ctx SpecContext) {
+			shutdownAt := time.Now().Add(-90 * time.Second).Truncate(time.Second)
+			machine.Spec.ShutdownAt = shutdownAt
+			machine.Spec.GuestAgent = api.GuestAgentQemu
+
+			Expect(r.deleteMachine(ctx, GinkgoLogr, machine)).To(BeTrue())
+			shutdown := storedShutdown(ctx)
+			Expect(shutdown.Stage).To(Equal(api.ShutdownStageGuestAgent))
+			Expect(shutdown.StageStartedAt).To(BeTemporally("==", shutdownAt.Add(time.Minute)))
+		})
+	})
+})
@@ -0,0 +1,165 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"os"
+
+	"github.com/digitalocean/go-libvirt"
+	"github.com/go-logr/logr"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/host"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type nopEventRecorder struct{}
+
+func (nopEventRecorder) Eventf(logr.Logger, api.Metadata, string, string, string, ...any) {}
+
+var _ = Describe("Machine status", func() {
+	Describe("setReconciledStatus", func() {
+		It("should replace the state and conditions of the latest machine", func() {
+			latest := &api.Machine{Status: api.MachineStatus{State: api.MachineStatePending}}
+			setReconciledStatus(latest, api.MachineStatus{
+				State:      api.MachineStateRunning,
+				Conditions: []api.MachineCondition{{Type: api.MachineConditionVolumesAttached, Status: api.ConditionTrue}},
+			})
+			Expect(latest.Status.State).To(Equal(api.MachineStateRunning))
+			Expect(latest.Status.Conditions).To(HaveLen(1))
+		})
+
+		It("should keep the volume sizes set by the resize worker", func() {
+			latest := &api.Machine{Status: api.MachineStatus{VolumeStatus: []api.VolumeStatus{
+				{Name: "root", Handle: "a", State: api.VolumeStateAttached, Size: 2048},
+			}}}
+			status := api.MachineStatus{VolumeStatus: []api.VolumeStatus{
+				{Name: "root", Handle: "a", State: api.VolumeStateAttached, Size: 1024},
+				{Name: "data", Handle: "b", State: api.VolumeStateAttached, Size: 512},
+			}}
+			setReconciledStatus(latest, status)
+			Expect(latest.Status.VolumeStatus).To(Equal([]api.VolumeStatus{
+				{Name: "root", Handle: "a", State: api.VolumeStateAttached, Size: 2048},
+				{Name: "data", Handle: "b", State: api.VolumeStateAttached, Size: 512},
+			}))
+			By("not modifying the status of the reconcile")
+			Expect(status.VolumeStatus[0].Size).To(Equal(int64(1024)))
+		})
+
+		It("should keep the migration progress of the migration worker", func() {
+			latest := &api.Machine{Status: api.MachineStatus{VolumeStatus: []api.VolumeStatus{{
+				Name: "root", Handle: "a", State: api.VolumeStateAttached,
+				Migration: &api.VolumeMigrationStatus{Phase: api.VolumeMigrationPhasePivoted, CopiedBytes: 1024},
+			}}}}
+			setReconciledStatus(latest, api.MachineStatus{VolumeStatus: []api.VolumeStatus{{
+				Name: "root", Handle: "a", State: api.VolumeStateAttached,
+				Migration: &api.VolumeMigrationStatus{Phase: api.VolumeMigrationPhaseCopying},
+			}}})
+			Expect(latest.Status.VolumeStatus).To(ConsistOf(HaveField("Migration", SatisfyAll(
+				HaveField("Phase", api.VolumeMigrationPhasePivoted),
+				HaveField("CopiedBytes", int64(1024)),
+			))))
+		})
+
+		It("should keep a migration started by the reconcile", func() {
+			latest := &api.Machine{Status: api.MachineStatus{VolumeStatus: []api.VolumeStatus{
+				{Name: "root", Handle: "a", State: api.VolumeStateAttached},
+			}}}
+			setReconciledStatus(latest, api.MachineStatus{VolumeStatus: []api.VolumeStatus{{
+				Name: "root", Handle: "b", State: api.VolumeStateAttached,
+				Migration: &api.VolumeMigrationStatus{Phase: api.VolumeMigrationPhaseCopying},
+			}}})
+			Expect(latest.Status.VolumeStatus).To(ConsistOf(SatisfyAll(
+				HaveField("Handle", "b"),
+				HaveField("Migration.Phase", api.VolumeMigrationPhaseCopying),
+			)))
+		})
+	})
+
+	Describe("consumeBootOverride", func() {
+		var (
+			machines *host.Store[*api.Machine]
+			r        *MachineReconciler
+		)
+
+		BeforeEach(func() {
+			var err error
+			machines, err = host.NewStore[*api.Machine](host.Options[*api.Machine]{
+				Dir:     GinkgoT().TempDir(),
+				NewFunc: func() *api.Machine { return &api.Machine{} },
+			})
+			Expect(err).NotTo(HaveOccurred())
+			r = &MachineReconciler{machines: machines, EventRecorder: nopEventRecorder{}}
+		})
+
+		It("should persist the consumed boot override without the status", func(ctx SpecContext) {
+			machine, err := machines.Create(ctx, &api.Machine{
+				Metadata: api.Metadata{ID: "foo"},
+				Spec: api.MachineSpec{Boot: &api.BootSpec{
+					Override: &api.BootOverride{ISO: "installer.iso"},
+				}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("updating the machine concurrently")
+			updated := *machine
+			updated.Spec.Power = api.PowerStatePowerOff
+			_, err = machines.Update(ctx, &updated)
+			Expect(err).NotTo(HaveOccurred())
+
+			machine.Status.State = api.MachineStateRunning
+			r.consumeBootOverride(ctx, logr.Discard(), machine)
+			Expect(machine.Spec.Boot.Override).To(BeNil())
+
+			latest, err := machines.Get(ctx, "foo")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(latest.Spec.Boot.Override).To(BeNil())
+			Expect(latest.Spec.Power).To(Equal(api.PowerStatePowerOff))
+			Expect(latest.Status.State).NotTo(Equal(api.MachineStateRunning))
+		})
+
+		It("should keep a boot override set meanwhile", func(ctx SpecContext) {
+			machine, err := machines.Create(ctx, &api.Machine{
+				Metadata: api.Metadata{ID: "foo"},
+				Spec: api.MachineSpec{Boot: &api.BootSpec{
+					Override: &api.BootOverride{ISO: "installer.iso"},
+				}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			latest, err := machines.Get(ctx, "foo")
+			Expect(err).NotTo(HaveOccurred())
+			latest.Spec.Boot.Override = &api.BootOverride{ISO: "rescue.iso"}
+			_, err = machines.Update(ctx, latest)
+			Expect(err).NotTo(HaveOccurred())
+
+			r.consumeBootOverride(ctx, logr.Discard(), machine)
+
+			latest, err = machines.Get(ctx, "foo")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(latest.Spec.Boot.Override).To(Equal(&api.BootOverride{ISO: "rescue.iso"}))
+		})
+	})
+
+	Describe("domainMachineState", func() {
+		var paths host.Paths
+
+		BeforeEach(func() {
+			var err error
+			paths, err = host.PathsAt(GinkgoT().TempDir())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(host.MakeMachineDirs(paths, "foo")).To(Succeed())
+		})
+
+		It("should report paused domains as pending", func() {
+			Expect(domainMachineState(paths, "foo", libvirt.DomainPaused)).To(Equal(api.MachineStatePending))
+		})
+
+		It("should report domains paused due to a host condition as suspended", func() {
+			Expect(os.WriteFile(paths.MachineHostEventFile("foo"), []byte(api.HostEventPolicyPause), 0600)).To(Succeed())
+			Expect(domainMachineState(paths, "foo", libvirt.DomainPaused)).To(Equal(api.MachineStateSuspended))
+			Expect(domainMachineState(paths, "foo", libvirt.DomainRunning)).To(Equal(api.MachineStateRunning))
+		})
+	})
+})
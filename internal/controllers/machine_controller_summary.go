@@ -0,0 +1,113 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// ReconcileSummaryFormat is the format of the single summary line logged per reconcile.
+type ReconcileSummaryFormat string
+
+const (
+	ReconcileSummaryFormatText ReconcileSummaryFormat = "text"
+	ReconcileSummaryFormatJSON ReconcileSummaryFormat = "json"
+)
+
+func ParseReconcileSummaryFormat(s string) (ReconcileSummaryFormat, error) {
+	switch format := ReconcileSummaryFormat(s); format {
+	case ReconcileSummaryFormatText, ReconcileSummaryFormatJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown reconcile summary format %q", s)
+	}
+}
+
+type reconcileOutcome string
+
+const (
+	reconcileOutcomeNotFound              reconcileOutcome = "NotFound"
+	reconcileOutcomeDeleting              reconcileOutcome = "Deleting"
+	reconcileOutcomeFinalizerAdded        reconcileOutcome = "FinalizerAdded"
+	reconcileOutcomeImagePulling          reconcileOutcome = "ImagePulling"
+	reconcileOutcomeRootFSQueued          reconcileOutcome = "RootFSQueued"
+	reconcileOutcomeVolumePluginUnhealthy reconcileOutcome = "VolumePluginUnhealthy"
+	reconcileOutcomeCreated               reconcileOutcome = "Created"
+	reconcileOutcomeUpdated               reconcileOutcome = "Updated"
+	reconcileOutcomePoweredOff            reconcileOutcome = "PoweredOff"
+	reconcileOutcomeError                 reconcileOutcome = "Error"
+	reconcileOutcomeTimeout               reconcileOutcome = "Timeout"
+	reconcileOutcomeCancelled             reconcileOutcome = "Cancelled"
+)
+
+type reconcilePhase struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+}
+
+// reconcileSummary collects the phase timings of a single reconcile, so the reconcile is logged as one line
+// instead of a line per step.
+type reconcileSummary struct {
+	start   time.Time
+	outcome reconcileOutcome
+	phases  []reconcilePhase
+}
+
+type reconcileSummaryContextKey struct{}
+
+func newReconcileSummaryContext(ctx context.Context) (context.Context, *reconcileSummary) {
+	summary := &reconcileSummary{start: time.Now()}
+	return context.WithValue(ctx, reconcileSummaryContextKey{}, summary), summary
+}
+
+func reconcileSummaryFromContext(ctx context.Context) *reconcileSummary {
+	summary, _ := ctx.Value(reconcileSummaryContextKey{}).(*reconcileSummary)
+	return summary
+}
+
+// phase starts timing the named phase and returns a function ending it. It is safe to use on a nil summary.
+func (s *reconcileSummary) phase(name string) func() {
+	if s == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		s.phases = append(s.phases, reconcilePhase{Name: name, Duration: time.Since(start)})
+	}
+}
+
+func (s *reconcileSummary) setOutcome(outcome reconcileOutcome) {
+	if s != nil {
+		s.outcome = outcome
+	}
+}
+
+func (s *reconcileSummary) log(log logr.Logger, format ReconcileSummaryFormat) {
+	duration := time.Since(s.start)
+	if format == ReconcileSummaryFormatJSON {
+		data, err := json.Marshal(struct {
+			Outcome  reconcileOutcome `json:"outcome"`
+			Duration time.Duration    `json:"duration"`
+			Phases   []reconcilePhase `json:"phases,omitempty"`
+		}{s.outcome, duration, s.phases})
+		if err != nil {
+			log.Error(err, "failed to marshal reconcile summary")
+			return
+		}
+		log.V(1).Info("Reconciled machine", "Summary", string(data))
+		return
+	}
+
+	phases := make([]string, 0, len(s.phases))
+	for _, phase := range s.phases {
+		phases = append(phases, fmt.Sprintf("%s=%s", phase.Name, phase.Duration.Round(time.Microsecond)))
+	}
+	log.V(1).Info("Reconciled machine", "Outcome", s.outcome, "Duration", duration.Round(time.Microsecond), "Phases", strings.Join(phases, " "))
+}
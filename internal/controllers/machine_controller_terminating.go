@@ -0,0 +1,244 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/digitalocean/go-libvirt"
+	"github.com/go-logr/logr"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	providerhost "github.com/ironcore-dev/libvirt-provider/internal/host"
+	"github.com/ironcore-dev/libvirt-provider/internal/store"
+	"github.com/ironcore-dev/libvirt-provider/internal/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+var (
+	ErrMachineNotTerminating = errors.New("machine is not terminating")
+	ErrForceFinalizeTooEarly = errors.New("machine has not been terminating long enough")
+
+	terminatingMachines = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "libvirt_provider",
+		Name:      "terminating_machines",
+		Help:      "Number of deleted machines whose cleanup has not completed yet.",
+	})
+	oldestTerminatingMachineAge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "libvirt_provider",
+		Name:      "terminating_machine_oldest_age_seconds",
+		Help:      "Age of the oldest deleted machine whose cleanup has not completed yet.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(terminatingMachines, oldestTerminatingMachineAge)
+}
+
+// ForceFinalizeResult describes the outcome of a forced finalization.
+type ForceFinalizeResult struct {
+	MachineID   string    `json:"machineID"`
+	FinalizedAt time.Time `json:"finalizedAt"`
+	// LeftBehind lists the cleanup steps that failed and whose resources may still exist on the host.
+	LeftBehind []string `json:"leftBehind,omitempty"`
+}
+
+// removeFinalizer removes the MachineFinalizer from the latest version of a machine updated with
+// store.RetryOnConflict.
+func removeFinalizer(latest *api.Machine) error {
+	latest.Finalizers = utils.DeleteSliceElement(latest.Finalizers, MachineFinalizer)
+	return nil
+}
+
+func isTerminating(machine *api.Machine) bool {
+	return machine.DeletedAt != nil && slices.Contains(machine.Finalizers, MachineFinalizer)
+}
+
+// updateTerminatingMachines updates the terminating metrics and emits an event once for every machine
+// exceeding the terminating warning threshold.
+func (r *MachineReconciler) updateTerminatingMachines(log logr.Logger, machines []*api.Machine) {
+	var (
+		now    = time.Now()
+		count  int
+		oldest time.Duration
+		seen   = sets.New[string]()
+	)
+	for _, machine := range machines {
+		if !isTerminating(machine) {
+			continue
+		}
+
+		count++
+		seen.Insert(machine.ID)
+		age := now.Sub(*machine.DeletedAt)
+		oldest = max(oldest, age)
+
+		if r.terminatingWarningThreshold > 0 && age >= r.terminatingWarningThreshold && !r.stuckTerminating.Has(machine.ID) {
+			r.stuckTerminating.Insert(machine.ID)
+			r.Eventf(log, machine.Metadata, corev1.EventTypeWarning, "StuckTerminating", "Machine has been terminating for %s", age.Round(time.Second))
+		}
+	}
+
+	// Forget machines that are gone so the set doesn't grow unbounded.
+	r.stuckTerminating = r.stuckTerminating.Intersection(seen)
+
+	terminatingMachines.Set(float64(count))
+	oldestTerminatingMachineAge.Set(oldest.Seconds())
+}
+
+// ForceFinalize removes the finalizer of a machine that has been terminating for at least the terminating
+// warning threshold. Cleanup steps are still attempted, but failures don't block the finalization; instead
+// they are reported as event and persisted in the leftovers directory for manual cleanup.
+func (r *MachineReconciler) ForceFinalize(ctx context.Context, machineID string) (*ForceFinalizeResult, error) {
+	log := r.log.WithName("force-finalize").WithValues("machineID", machineID)
+
+	defer r.lockFinalize(machineID)()
+
+	machine, err := r.machines.Get(ctx, machineID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isTerminating(machine) {
+		return nil, ErrMachineNotTerminating
+	}
+	if age := time.Since(*machine.DeletedAt); age < r.terminatingWarningThreshold {
+		return nil, fmt.Errorf("%w: terminating for %s, required %s", ErrForceFinalizeTooEarly, age.Round(time.Second), r.terminatingWarningThreshold)
+	}
+
+	return r.forceFinalize(ctx, log, machine)
+}
+
+// ForceDelete deletes a machine and force finalizes it right away, without waiting for its regular cleanup to get
+// stuck first. As with ForceFinalize, failed cleanup steps don't block the deletion but are reported as left behind.
+func (r *MachineReconciler) ForceDelete(ctx context.Context, machineID string) (*ForceFinalizeResult, error) {
+	log := r.log.WithName("force-delete").WithValues("machineID", machineID)
+
+	defer r.lockFinalize(machineID)()
+
+	log.Info("Force deleting machine")
+	if err := r.machines.Delete(ctx, machineID); err != nil {
+		return nil, err
+	}
+	// The deletion cancels a running reconcile, which mustn't recreate anything after the cleanup.
+	if err := r.waitReconcile(ctx, machineID); err != nil {
+		return nil, fmt.Errorf("error waiting for the reconcile of the machine: %w", err)
+	}
+
+	machine, err := r.machines.Get(ctx, machineID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			// The machine had no finalizer and was removed by the deletion.
+			return &ForceFinalizeResult{MachineID: machineID, FinalizedAt: time.Now()}, nil
+		}
+		return nil, err
+	}
+	return r.forceFinalize(ctx, log, machine)
+}
+
+// forceFinalize cleans up the deleted machine as far as possible and removes its finalizer. It has to be called
+// with the finalization of the machine locked.
+func (r *MachineReconciler) forceFinalize(ctx context.Context, log logr.Logger, machine *api.Machine) (*ForceFinalizeResult, error) {
+	log.Info("Force finalizing machine")
+	result := &ForceFinalizeResult{
+		MachineID:   machine.ID,
+		FinalizedAt: time.Now(),
+		LeftBehind:  r.forceCleanupMachine(ctx, log, machine),
+	}
+
+	if len(result.LeftBehind) > 0 {
+		if err := r.writeLeftovers(result); err != nil {
+			log.Error(err, "failed to persist leftovers", "LeftBehind", result.LeftBehind)
+		}
+		log.Info("Force finalized machine with leftovers", "LeftBehind", result.LeftBehind)
+		r.Eventf(log, machine.Metadata, corev1.EventTypeWarning, "ForceFinalized", "Force finalized, left behind: %v", result.LeftBehind)
+	} else {
+		r.Eventf(log, machine.Metadata, corev1.EventTypeNormal, "ForceFinalized", "Force finalized, all resources cleaned up")
+	}
+
+	machine.Finalizers = utils.DeleteSliceElement(machine.Finalizers, MachineFinalizer)
+	if _, err := store.RetryOnConflict(ctx, r.machines, machine, removeFinalizer); store.IgnoreErrNotFound(err) != nil {
+		return nil, fmt.Errorf("failed to update machine metadata: %w", err)
+	}
+	return result, nil
+}
+
+func (r *MachineReconciler) forceCleanupMachine(ctx context.Context, log logr.Logger, machine *api.Machine) []string {
+	var leftBehind []string
+	leave := func(format string, args ...any) {
+		leftBehind = append(leftBehind, fmt.Sprintf(format, args...))
+	}
+
+	if err := r.conn().DomainDestroyFlags(machineDomain(machine.ID), libvirt.DomainDestroyGraceful); err != nil && !libvirt.IsNotFound(err) {
+		leave("domain: %v", err)
+	}
+
+	mounter := r.machineVolumeMounter(machine)
+	if err := mounter.ForEachVolume(func(volume *MountVolume) bool {
+		log.V(1).Info("Deleting volume", "volumeName", volume.ComputeVolumeName)
+		if err := mounter.DeleteVolume(ctx, volume.ComputeVolumeName); err != nil {
+			leave("volume %s: %v", volume.ComputeVolumeName, err)
+		}
+		return true
+	}); err != nil && !errors.Is(err, os.ErrNotExist) {
+		leave("volumes: %v", err)
+	}
+
+	machineNics, err := providerhost.ReadMachineNetworkInterfaces(r.host, machine.ID)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		leave("network interfaces: %v", err)
+	}
+	for _, machineNic := range machineNics {
+		log.V(1).Info("Deleting network interface", "networkInterfaceName", machineNic.NetworkInterfaceName)
+		plugin, err := r.networkInterfacePlugins.FindPluginByName(machineNic.PluginName)
+		if err == nil {
+			err = plugin.Delete(ctx, machineNic.NetworkInterfaceName, machine.ID)
+		}
+		if err != nil {
+			leave("network interface %s: %v", machineNic.NetworkInterfaceName, err)
+		}
+	}
+
+	if r.pciDevices != nil {
+		for _, device := range machine.Status.PCIDevices {
+			if err := r.pciDevices.UnbindVFIO(device.Address); err != nil {
+				leave("pci device %s: %v", device.Address, err)
+			}
+		}
+		r.pciDevices.Release(machine.ID)
+	}
+
+	// Keep the machine directory if anything was left behind, it holds the state required for manual cleanup.
+	if len(leftBehind) > 0 {
+		leave("machine directory: %s", r.host.MachineDir(machine.ID))
+		return leftBehind
+	}
+	if err := os.RemoveAll(r.host.MachineDir(machine.ID)); err != nil {
+		leave("machine directory: %v", err)
+		return leftBehind
+	}
+	r.releaseDirQuota(log, machine)
+	return leftBehind
+}
+
+func (r *MachineReconciler) writeLeftovers(result *ForceFinalizeResult) error {
+	if err := os.MkdirAll(r.host.LeftoversDir(), 0700); err != nil {
+		return fmt.Errorf("error creating leftovers directory: %w", err)
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("error marshalling leftovers: %w", err)
+	}
+
+	return os.WriteFile(filepath.Join(r.host.LeftoversDir(), result.MachineID+".json"), data, 0600)
+}
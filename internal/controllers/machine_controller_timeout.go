@@ -0,0 +1,68 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/call"
+)
+
+// errMachineDeleted is the cause of reconciles cancelled because their machine got deleted meanwhile.
+var errMachineDeleted = errors.New("machine deleted during reconcile")
+
+// runningReconcile is a running reconcile of a machine.
+type runningReconcile struct {
+	cancel context.CancelCauseFunc
+	// done is closed once the reconcile returned.
+	done chan struct{}
+}
+
+// reconcileContext returns the context of a reconcile of the machine. It times out after the reconcile timeout
+// and is cancelled if the machine gets deleted during the reconcile.
+func (r *MachineReconciler) reconcileContext(ctx context.Context, id string) (context.Context, context.CancelFunc) {
+	ctx, cancelCause := context.WithCancelCause(ctx)
+	// The queue doesn't process a machine concurrently, so there's at most one reconcile per machine.
+	running := &runningReconcile{cancel: cancelCause, done: make(chan struct{})}
+	r.reconciles.Store(id, running)
+
+	cancelTimeout := func() {}
+	if r.reconcileTimeout > 0 {
+		ctx, cancelTimeout = context.WithTimeoutCause(ctx, r.reconcileTimeout, fmt.Errorf("%w: reconcile exceeded %s", call.ErrTimeout, r.reconcileTimeout))
+	}
+
+	return ctx, func() {
+		r.reconciles.Delete(id)
+		cancelTimeout()
+		cancelCause(context.Canceled)
+		close(running.done)
+	}
+}
+
+// cancelReconcile cancels the running reconcile of the deleted machine, if any.
+func (r *MachineReconciler) cancelReconcile(id string) {
+	if running, ok := r.reconciles.Load(id); ok {
+		running.(*runningReconcile).cancel(errMachineDeleted)
+	}
+}
+
+// waitReconcile cancels the running reconcile of the deleted machine, if any, and waits until it returned. Its
+// mutating libvirt calls are awaited as well, so the machine can be deleted without leaving e.g. a domain created
+// meanwhile behind.
+func (r *MachineReconciler) waitReconcile(ctx context.Context, id string) error {
+	running, ok := r.reconciles.Load(id)
+	if !ok {
+		return nil
+	}
+	running.(*runningReconcile).cancel(errMachineDeleted)
+
+	select {
+	case <-running.(*runningReconcile).done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
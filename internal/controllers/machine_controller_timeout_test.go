@@ -0,0 +1,48 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Reconcile context", func() {
+	It("should cancel the running reconcile and wait until it returned", func(ctx SpecContext) {
+		r := &MachineReconciler{}
+		reconcileCtx, cancel := r.reconcileContext(ctx, "machine-1")
+
+		returned := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			<-reconcileCtx.Done()
+			Expect(context.Cause(reconcileCtx)).To(MatchError(errMachineDeleted))
+			close(returned)
+			cancel()
+		}()
+
+		Expect(r.waitReconcile(ctx, "machine-1")).To(Succeed())
+		Expect(returned).To(BeClosed())
+	})
+
+	It("should not wait without a running reconcile", func(ctx SpecContext) {
+		r := &MachineReconciler{}
+		_, cancel := r.reconcileContext(ctx, "machine-1")
+		cancel()
+
+		Expect(r.waitReconcile(ctx, "machine-1")).To(Succeed())
+	})
+
+	It("should stop waiting once its context is done", func(ctx SpecContext) {
+		r := &MachineReconciler{}
+		_, cancel := r.reconcileContext(ctx, "machine-1")
+		DeferCleanup(cancel)
+
+		waitCtx, cancelWait := context.WithCancel(ctx)
+		cancelWait()
+		Expect(r.waitReconcile(waitCtx, "machine-1")).To(MatchError(context.Canceled))
+	})
+})
@@ -12,20 +12,30 @@ import (
 	"os"
 	"path/filepath"
 	"slices"
-	"strings"
+	"time"
 
 	"github.com/digitalocean/go-libvirt"
 	"github.com/go-logr/logr"
 	"github.com/google/uuid"
 	"github.com/ironcore-dev/libvirt-provider/api"
 	providerhost "github.com/ironcore-dev/libvirt-provider/internal/host"
+	"github.com/ironcore-dev/libvirt-provider/internal/journal"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/alias"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/device"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/hotplug"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/pci"
 	libvirtutils "github.com/ironcore-dev/libvirt-provider/internal/libvirt/utils"
 	providervolume "github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/utils/ptr"
 	utilstrings "k8s.io/utils/strings"
 	"libvirt.org/go/libvirtxml"
 )
 
+// volumePluginUnhealthyRetryDelay is the delay after which a machine waiting for an unhealthy volume plugin is
+// reconciled again.
+const volumePluginUnhealthyRetryDelay = 30 * time.Second
+
 func (r *MachineReconciler) deleteVolumes(ctx context.Context, log logr.Logger, machine *api.Machine) error {
 	mounter := r.machineVolumeMounter(machine)
 	var errs []error
@@ -75,6 +85,34 @@ func getVolumeStatus(machine *api.Machine, volumeID string) *api.VolumeStatus {
 	return nil
 }
 
+// migrateVolumeStatusHandles rewrites volume status handles created before machine local plugins included the
+// machine id in their backing volume id, so the last known volume sizes are still found.
+func (r *MachineReconciler) migrateVolumeStatusHandles(machine *api.Machine) {
+	for _, volume := range machine.Spec.Volumes {
+		plugin, err := r.volumePluginManager.FindPluginBySpec(volume)
+		if err != nil {
+			continue
+		}
+
+		volumeID, err := plugin.GetBackingVolumeID(volume, machine.ID)
+		if err != nil {
+			continue
+		}
+
+		legacyHandle := GetUniqueVolumeName(plugin.Name(), volume.Name)
+		handle := GetUniqueVolumeName(plugin.Name(), volumeID)
+		if handle == legacyHandle {
+			continue
+		}
+
+		for i := range machine.Status.VolumeStatus {
+			if status := &machine.Status.VolumeStatus[i]; status.Name == volume.Name && status.Handle == legacyHandle {
+				status.Handle = handle
+			}
+		}
+	}
+}
+
 func getLastVolumeSize(machine *api.Machine, volumeID string) int64 {
 	if status := getVolumeStatus(machine, volumeID); status != nil && status.Size != 0 {
 		return status.Size
@@ -83,6 +121,8 @@ func getLastVolumeSize(machine *api.Machine, volumeID string) int64 {
 }
 
 func (r *MachineReconciler) attachDetachVolumes(ctx context.Context, log logr.Logger, machine *api.Machine, attacher VolumeAttacher) ([]api.VolumeStatus, error) {
+	r.migrateVolumeStatusHandles(machine)
+
 	mounter := r.machineVolumeMounter(machine)
 	specVolumes := r.listDesiredVolumes(machine)
 
@@ -101,51 +141,99 @@ func (r *MachineReconciler) attachDetachVolumes(ctx context.Context, log logr.Lo
 		return nil, fmt.Errorf("error iterating mounted volumes: %w", err)
 	}
 
-	var errs []error
+	attachDetachErr := newAttachDetachError(deviceKindVolume)
 	for volumeName := range currentVolumeNames {
 		if _, ok := specVolumes[volumeName]; ok {
 			continue
 		}
 
-		log.V(1).Info("Deleting non-required volume", "volumeName", volumeName)
-		if err := r.deleteVolume(ctx, log, mounter, attacher, volumeName); err != nil {
-			errs = append(errs, fmt.Errorf("[volume %s] error detaching: %w", volumeName, err))
+		if volumeMigrating(getVolumeStatusByName(machine, volumeName)) {
+			log.V(1).Info("Aborting migration of non-required volume", "volumeName", volumeName)
+			if err := attacher.AbortVolumeCopy(volumeName); err != nil && !errors.Is(err, ErrAttachedVolumeNotFound) {
+				log.V(1).Info("Failed to abort volume copy", "volumeName", volumeName, "Error", err)
+			}
+		}
+
+		log.V(2).Info("Deleting non-required volume", "volumeName", volumeName)
+		err := r.deleteVolume(ctx, log, mounter, attacher, volumeName)
+		if r.requeueDevicePending(log, machine.ID, volumeName, err) {
+			// The volume stays mounted until the guest released it.
+			continue
+		}
+		r.recordOperation(log, machine.ID, journal.OperationDetach, volumeName, err)
+		if err != nil {
+			attachDetachErr.add(volumeName, "detaching", err)
 		} else {
-			log.V(1).Info("Successfully detached volume", "volumeName", volumeName)
+			log.V(2).Info("Successfully detached volume", "volumeName", volumeName)
 		}
 	}
 
+	// Each volume is reconciled on its own, a failing volume doesn't keep the others from being attached.
 	var volumeStates []api.VolumeStatus
-	for _, volume := range specVolumes {
-		log.V(1).Info("Reconciling volume", "volumeName", volume.Name)
-		volumeID, volumeSize, err := r.applyVolume(ctx, log, machine, volume, mounter, attacher)
+	for _, volume := range machine.Spec.Volumes {
+		if r.liveVolumeMigration {
+			status, err := r.reconcileVolumeMigration(ctx, log, machine, volume, mounter, attacher)
+			if err != nil {
+				attachDetachErr.add(volume.Name, "migrating", err)
+				continue
+			}
+			if status != nil {
+				// Migrated volumes stay attached with their source until the migration completes.
+				volumeStates = append(volumeStates, *status)
+				continue
+			}
+		}
+
+		log.V(2).Info("Preparing volume", "volumeName", volume.Name)
+		prepared, err := r.prepareVolume(ctx, log, machine, volume, mounter, attacher)
 		if err != nil {
-			errs = append(errs, fmt.Errorf("[volume %s] error reconciling: %w", volume.Name, err))
+			attachDetachErr.add(volume.Name, "preparing", err)
+			continue
+		}
+
+		log.V(2).Info("Reconciling volume", "volumeName", volume.Name)
+		if err := r.attachPreparedVolume(log, machine, prepared, attacher); err != nil {
+			attachDetachErr.add(volume.Name, "reconciling", err)
 			continue
 		}
 
-		log.V(1).Info("Successfully reconciled volume", "volumeName", volume.Name, "volumeID", volumeID)
+		log.V(2).Info("Successfully reconciled volume", "volumeName", volume.Name, "volumeID", prepared.volumeID)
+		state := api.VolumeStateAttached
+		if r.devicePending(log, machine.ID, alias.Volume(volume.Name)) {
+			state = api.VolumeStatePending
+		}
 		volumeStates = append(volumeStates, api.VolumeStatus{
 			Name:   volume.Name,
-			Handle: volumeID,
-			State:  api.VolumeStateAttached,
-			Size:   volumeSize,
+			Handle: prepared.volumeID,
+			State:  state,
+			Size:   prepared.volume.Size,
 		})
 	}
 
-	if len(errs) > 0 {
-		return nil, fmt.Errorf("attach/detach error(s): %v", errs)
+	if attachDetachErr.len() > 0 {
+		return withFailedVolumeStates(machine, volumeStates, attachDetachErr), attachDetachErr
 	}
 	return volumeStates, nil
 }
 
+// withFailedVolumeStates appends the last known states of the failed volumes to the states of the reconciled
+// ones, so their failed attempts are counted on.
+func withFailedVolumeStates(machine *api.Machine, volumeStates []api.VolumeStatus, attachDetachErr *attachDetachError) []api.VolumeStatus {
+	for _, status := range machine.Status.VolumeStatus {
+		if _, ok := attachDetachErr.failed[status.Name]; ok {
+			volumeStates = append(volumeStates, status)
+		}
+	}
+	return volumeStates
+}
+
 func (r *MachineReconciler) deleteVolume(ctx context.Context, log logr.Logger, mounter VolumeMounter, attacher VolumeAttacher, volumeName string) error {
-	log.V(1).Info("Detaching volume if attached")
+	log.V(2).Info("Detaching volume if attached")
 	if err := attacher.DetachVolume(volumeName); err != nil && !errors.Is(err, ErrAttachedVolumeNotFound) {
 		return fmt.Errorf("error detaching volume: %w", err)
 	}
 
-	log.V(1).Info("Unmounting volume if mounted")
+	log.V(2).Info("Unmounting volume if mounted")
 	if err := mounter.DeleteVolume(ctx, volumeName); err != nil && !errors.Is(err, ErrMountedVolumeNotFound) {
 		return fmt.Errorf("error unmounting volume: %w", err)
 	}
@@ -166,17 +254,54 @@ type VolumeAttacher interface {
 	AttachVolume(volume *AttachVolume) error
 	DetachVolume(name string) error
 	ResizeVolume(volume *AttachVolume) error
+	// QuiesceVolume prepares the attached volume to be detached without losing writes. It returns
+	// ErrBlockJobActive while a block job runs on the volume.
+	QuiesceVolume(name string) error
+	// FreezeGuest freezes the guest file systems if the guest agent is connected. It reports whether they were
+	// frozen.
+	FreezeGuest() (bool, error)
+	// ThawGuest thaws the guest file systems frozen by FreezeGuest.
+	ThawGuest() error
+
+	// CopyVolume starts copying the data of the attached volume with the name of the given volume to the given
+	// volume. The domain keeps using the attached volume until PivotVolume switches it over.
+	CopyVolume(volume *AttachVolume) error
+	// VolumeCopyProgress returns the copied and total bytes of the copy job of the volume. ok is false if the
+	// volume has no copy job.
+	VolumeCopyProgress(name string) (copied, total int64, ok bool, err error)
+	// PivotVolume switches the domain to the copy of the volume once the copy job is ready.
+	PivotVolume(name string) error
+	// AbortVolumeCopy cancels the copy job of the volume, the domain keeps using the attached volume.
+	AbortVolumeCopy(name string) error
+	// DeleteUnusedVolumeSecrets deletes the secrets of the volume its disk doesn't use, i.e. the ones of the
+	// source after the pivot or the ones of the copy after it was aborted.
+	DeleteUnusedVolumeSecrets(name string) error
 }
 
 var (
 	ErrAttachedVolumeNotFound      = errors.New("volume not found")
 	ErrAttachedVolumeAlreadyExists = errors.New("volume already exists")
+
+	ErrBlockJobActive       = errors.New("block job active")
+	ErrLiveCopyNotSupported = errors.New("live copy requires a running domain")
 )
 
 type DomainExecutor interface {
 	AttachDisk(disk *libvirtxml.DomainDisk) error
 	DetachDisk(disk *libvirtxml.DomainDisk) error
-	ResizeDisk(device string, size int64) error
+	ResizeDisk(target string, size int64) error
+
+	AddIOThread(id uint, cpuSet string) error
+	DeleteIOThread(id uint) error
+
+	BlockJobActive(target string) (bool, error)
+	FreezeFilesystems() error
+	ThawFilesystems() error
+
+	CopyDisk(target string, dest *libvirtxml.DomainDisk) error
+	BlockJobProgress(target string) (cur, end uint64, found bool, err error)
+	PivotDisk(target string) error
+	AbortBlockJob(target string) error
 
 	ApplySecret(secret *libvirtxml.Secret, data []byte) error
 	DeleteSecret(secretUUID string) error
@@ -193,6 +318,22 @@ func NewCreateDomainExecutor(lv *libvirt.Libvirt) DomainExecutor {
 func (e *createDomainExecutor) AttachDisk(*libvirtxml.DomainDisk) error { return nil }
 func (e *createDomainExecutor) DetachDisk(*libvirtxml.DomainDisk) error { return nil }
 func (e *createDomainExecutor) ResizeDisk(string, int64) error          { return nil }
+func (e *createDomainExecutor) AddIOThread(uint, string) error          { return nil }
+func (e *createDomainExecutor) DeleteIOThread(uint) error               { return nil }
+
+func (e *createDomainExecutor) BlockJobActive(string) (bool, error) { return false, nil }
+func (e *createDomainExecutor) FreezeFilesystems() error            { return nil }
+func (e *createDomainExecutor) ThawFilesystems() error              { return nil }
+
+func (e *createDomainExecutor) CopyDisk(string, *libvirtxml.DomainDisk) error {
+	return ErrLiveCopyNotSupported
+}
+func (e *createDomainExecutor) BlockJobProgress(string) (uint64, uint64, bool, error) {
+	return 0, 0, false, nil
+}
+func (e *createDomainExecutor) PivotDisk(string) error     { return ErrLiveCopyNotSupported }
+func (e *createDomainExecutor) AbortBlockJob(string) error { return nil }
+
 func (e *createDomainExecutor) ApplySecret(secret *libvirtxml.Secret, value []byte) error {
 	return libvirtutils.ApplySecret(e.libvirt, secret, value)
 }
@@ -204,14 +345,24 @@ func (e *createDomainExecutor) DeleteSecret(secretUUID string) error {
 }
 
 type domainExecutor struct {
-	libvirt   *libvirt.Libvirt
-	machineID string
+	// ctx bounds the device operations of the reconcile the executor was created for.
+	ctx     context.Context
+	libvirt *libvirt.Libvirt
+	// abort aborts the calls of the connection, it is nil if they can't be aborted.
+	abort       func()
+	callTimeout time.Duration
+	machineID   string
+	hotplug     *hotplug.Tracker
 }
 
-func NewRunningDomainExecutor(lv *libvirt.Libvirt, machineID string) DomainExecutor {
+func NewRunningDomainExecutor(ctx context.Context, lv *libvirt.Libvirt, abort func(), callTimeout time.Duration, machineID string, tracker *hotplug.Tracker) DomainExecutor {
 	return &domainExecutor{
-		libvirt:   lv,
-		machineID: machineID,
+		ctx:         ctx,
+		libvirt:     lv,
+		abort:       abort,
+		callTimeout: callTimeout,
+		machineID:   machineID,
+		hotplug:     tracker,
 	}
 }
 
@@ -220,21 +371,40 @@ func (a *domainExecutor) domain() libvirt.Domain {
 }
 
 func (a *domainExecutor) AttachDisk(disk *libvirtxml.DomainDisk) error {
-	data, err := disk.Marshal()
-	if err != nil {
-		return err
-	}
-
-	return a.libvirt.DomainAttachDevice(a.domain(), data)
+	return attachDevice(a.ctx, a.libvirt, a.abort, a.callTimeout, a.hotplug, a.machineID, diskAlias(disk), disk)
 }
 
 func (a *domainExecutor) DetachDisk(disk *libvirtxml.DomainDisk) error {
-	data, err := disk.Marshal()
+	return detachDevice(a.ctx, a.libvirt, a.abort, a.callTimeout, a.hotplug, a.machineID, diskAlias(disk), disk)
+}
+
+func diskAlias(disk *libvirtxml.DomainDisk) string {
+	if disk.Alias == nil {
+		return ""
+	}
+	return disk.Alias.Name
+}
+
+func (a *domainExecutor) AddIOThread(id uint, cpuSet string) error {
+	if err := a.libvirt.DomainAddIothread(a.domain(), uint32(id), libvirt.DomainAffectLive); err != nil {
+		return fmt.Errorf("error adding iothread %d: %w", id, err)
+	}
+	if cpuSet == "" {
+		return nil
+	}
+
+	cpus, err := cpuMap(cpuSet)
 	if err != nil {
 		return err
 	}
+	if err := a.libvirt.DomainPinIothread(a.domain(), uint32(id), cpus, libvirt.DomainAffectLive); err != nil {
+		return fmt.Errorf("error pinning iothread %d: %w", id, err)
+	}
+	return nil
+}
 
-	return a.libvirt.DomainDetachDevice(a.domain(), data)
+func (a *domainExecutor) DeleteIOThread(id uint) error {
+	return a.libvirt.DomainDelIothread(a.domain(), uint32(id), libvirt.DomainAffectLive)
 }
 
 func (a *domainExecutor) ApplySecret(secret *libvirtxml.Secret, value []byte) error {
@@ -247,21 +417,83 @@ func (a *domainExecutor) DeleteSecret(secretUUID string) error {
 	})
 }
 
-func (a *domainExecutor) ResizeDisk(device string, size int64) error {
-	return a.libvirt.DomainBlockResize(a.domain(), computeVirtioDiskTargetDeviceName(device), uint64(size), libvirt.DomainBlockResizeBytes)
+func (a *domainExecutor) ResizeDisk(target string, size int64) error {
+	return a.libvirt.DomainBlockResize(a.domain(), target, uint64(size), libvirt.DomainBlockResizeBytes)
+}
+
+func (a *domainExecutor) BlockJobActive(target string) (bool, error) {
+	found, _, _, _, _, err := a.libvirt.DomainGetBlockJobInfo(a.domain(), target, 0)
+	if err != nil {
+		return false, fmt.Errorf("error getting block job of %s: %w", target, err)
+	}
+	return found == 1, nil
+}
+
+// CopyDisk starts a block copy of the disk with the given target to the source of dest. The destination is
+// created by the volume plugin beforehand and reused.
+func (a *domainExecutor) CopyDisk(target string, dest *libvirtxml.DomainDisk) error {
+	data, err := dest.Marshal()
+	if err != nil {
+		return err
+	}
+
+	flags := libvirt.DomainBlockCopyReuseExt | libvirt.DomainBlockCopyTransientJob
+	if err := a.libvirt.DomainBlockCopy(a.domain(), target, data, nil, flags); err != nil {
+		return fmt.Errorf("error starting block copy of %s: %w", target, err)
+	}
+	return nil
+}
+
+func (a *domainExecutor) BlockJobProgress(target string) (uint64, uint64, bool, error) {
+	found, _, _, cur, end, err := a.libvirt.DomainGetBlockJobInfo(a.domain(), target, 0)
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("error getting block job of %s: %w", target, err)
+	}
+	return cur, end, found == 1, nil
+}
+
+func (a *domainExecutor) PivotDisk(target string) error {
+	if err := a.libvirt.DomainBlockJobAbort(a.domain(), target, libvirt.DomainBlockJobAbortPivot); err != nil {
+		return fmt.Errorf("error pivoting block copy of %s: %w", target, err)
+	}
+	return nil
+}
+
+func (a *domainExecutor) AbortBlockJob(target string) error {
+	if err := a.libvirt.DomainBlockJobAbort(a.domain(), target, 0); err != nil {
+		return fmt.Errorf("error aborting block job of %s: %w", target, err)
+	}
+	return nil
+}
+
+func (a *domainExecutor) FreezeFilesystems() error {
+	if _, err := a.libvirt.DomainFsfreeze(a.domain(), nil, 0); err != nil {
+		return fmt.Errorf("error freezing guest file systems: %w", err)
+	}
+	return nil
+}
+
+func (a *domainExecutor) ThawFilesystems() error {
+	if _, err := a.libvirt.DomainFsthaw(a.domain(), nil, 0); err != nil {
+		return fmt.Errorf("error thawing guest file systems: %w", err)
+	}
+	return nil
 }
 
 type libvirtVolumeAttacher struct {
-	domainDesc        *libvirtxml.Domain
-	executor          DomainExecutor
-	volumeCachePolicy string
+	domainDesc *libvirtxml.Domain
+	executor   DomainExecutor
+	cpuPinning CPUPinningOptions
+	// diskQueues is the number of queues of attached virtio-blk disks.
+	diskQueues uint
 }
 
-func NewLibvirtVolumeAttacher(domainDesc *libvirtxml.Domain, executor DomainExecutor, policy string) (VolumeAttacher, error) {
+func NewLibvirtVolumeAttacher(domainDesc *libvirtxml.Domain, executor DomainExecutor, cpuPinning CPUPinningOptions, diskQueues uint) (VolumeAttacher, error) {
 	a := &libvirtVolumeAttacher{
-		domainDesc:        domainDesc,
-		executor:          executor,
-		volumeCachePolicy: policy,
+		domainDesc: domainDesc,
+		executor:   executor,
+		cpuPinning: cpuPinning,
+		diskQueues: diskQueues,
 	}
 	return a, nil
 }
@@ -275,12 +507,12 @@ func (a *libvirtVolumeAttacher) domainDevices() *libvirtxml.DomainDeviceList {
 
 func (a *libvirtVolumeAttacher) diskByVolumeNameIndex(name string) (int, error) {
 	for i, disk := range a.domainDevices().Disks {
-		alias := disk.Alias
-		if alias == nil || !isDiskAlias(alias.Name) {
+		diskAlias := disk.Alias
+		if diskAlias == nil || !alias.IsVolume(diskAlias.Name) {
 			continue
 		}
 
-		parsed, err := parseVolumeDiskAlias(alias.Name)
+		parsed, err := alias.ParseVolume(diskAlias.Name)
 		if err != nil {
 			return 0, err
 		}
@@ -318,20 +550,15 @@ func getDiskTargetDevice(disk *libvirtxml.DomainDisk) (string, error) {
 	return device, nil
 }
 
-// computeVirtioDiskTargetDeviceName computes the deviceName for the Virtio volumes from the Machine.Volumes.Device.
-func computeVirtioDiskTargetDeviceName(device string) string {
-	return "v" + device[1:]
-}
-
 func (a *libvirtVolumeAttacher) forEachVolumeAndDisk(f func(*libvirtxml.DomainDisk, *AttachVolume) bool) error {
 	for _, disk := range a.domainDevices().Disks {
-		alias := disk.Alias
-		if alias == nil || !isDiskAlias(alias.Name) {
+		diskAlias := disk.Alias
+		if diskAlias == nil || !alias.IsVolume(diskAlias.Name) {
 			continue
 		}
 
 		// TODO: Revisit how to handle errors in these cases.
-		parsed, err := parseVolumeDiskAlias(alias.Name)
+		parsed, err := alias.ParseVolume(diskAlias.Name)
 		if err != nil {
 			return err
 		}
@@ -374,16 +601,35 @@ func (a *libvirtVolumeAttacher) AttachVolume(volume *AttachVolume) error {
 		return ErrAttachedVolumeAlreadyExists
 	}
 
-	if err := func() error {
-		disk, secret, encryptionSecret, secretValue, encryptionSecretValue, err := a.providerVolumeToLibvirt(volume.Name, &volume.Spec, volume.Device)
+	if err := func() (retErr error) {
+		target, err := device.ForDomain(a.domainDesc).AllocateVolume(volume.Name, volume.Device)
+		if err != nil {
+			return err
+		}
+
+		disk, secret, encryptionSecret, secretValue, encryptionSecretValue, err := a.providerVolumeToLibvirt(volume.Name, volume.Name, &volume.Spec, volume.Device, target)
 		if err != nil {
 			return err
 		}
 
+		// Secrets applied for a disk that can't be attached aren't used by the domain, remove them again.
+		var appliedSecrets []string
+		defer func() {
+			if retErr == nil {
+				return
+			}
+			for _, secretUUID := range appliedSecrets {
+				if err := a.executor.DeleteSecret(secretUUID); libvirtutils.IgnoreErrorCode(err, libvirt.ErrNoSecret) != nil {
+					retErr = errors.Join(retErr, fmt.Errorf("error deleting secret %s: %w", secretUUID, err))
+				}
+			}
+		}()
+
 		if secret != nil {
 			if err := a.executor.ApplySecret(secret, secretValue); err != nil {
 				return err
 			}
+			appliedSecrets = append(appliedSecrets, secret.UUID)
 		} else {
 			if err := a.executor.DeleteSecret(a.secretUUID(volume.Name)); libvirtutils.IgnoreErrorCode(err, libvirt.ErrNoSecret) != nil {
 				return err
@@ -394,13 +640,23 @@ func (a *libvirtVolumeAttacher) AttachVolume(volume *AttachVolume) error {
 			if err := a.executor.ApplySecret(encryptionSecret, encryptionSecretValue); err != nil {
 				return err
 			}
+			appliedSecrets = append(appliedSecrets, encryptionSecret.UUID)
 		} else {
 			if err := a.executor.DeleteSecret(a.secretEncryptionUUID(volume.Name)); libvirtutils.IgnoreErrorCode(err, libvirt.ErrNoSecret) != nil {
 				return err
 			}
 		}
 
+		if disk.Address, err = assignPCIAddress(a.domainDesc); err != nil {
+			return err
+		}
+
+		release, err := a.assignIOThread(disk)
+		if err != nil {
+			return err
+		}
 		if err := a.executor.AttachDisk(disk); err != nil {
+			release()
 			return err
 		}
 
@@ -427,21 +683,267 @@ func (a *libvirtVolumeAttacher) DetachVolume(name string) error {
 		return err
 	}
 
-	if err := a.executor.DeleteSecret(a.secretUUID(name)); libvirtutils.IgnoreErrorCode(err, libvirt.ErrNoSecret) != nil {
-		return err
+	for _, secretKey := range volumeSecretKeys(name) {
+		if err := a.deleteVolumeSecrets(secretKey, nil); err != nil {
+			return err
+		}
+	}
+
+	ioThread, ok := diskIOThread(disk)
+	a.domainDevices().Disks = slices.Delete(a.domainDevices().Disks, idx, idx+1)
+
+	if ok && a.cpuPinning.isDedicatedIOThread(ioThread) {
+		// The guest may not have released the disk yet. A dedicated iothread failing to be deleted stays with
+		// the domain and is reused by the next attached volume.
+		if err := a.executor.DeleteIOThread(ioThread); err == nil {
+			removeDomainIOThread(a.domainDesc, ioThread)
+		}
 	}
 
-	if err := a.executor.DeleteSecret(a.secretEncryptionUUID(name)); libvirtutils.IgnoreErrorCode(err, libvirt.ErrNoSecret) != nil {
+	return nil
+}
+
+func (a *libvirtVolumeAttacher) QuiesceVolume(name string) error {
+	target, err := a.attachedDiskTarget(name)
+	if err != nil {
+		return err
+	}
+	active, err := a.executor.BlockJobActive(target)
+	if err != nil {
 		return err
 	}
+	if active {
+		return fmt.Errorf("volume %s: %w", name, ErrBlockJobActive)
+	}
+	return nil
+}
+
+func (a *libvirtVolumeAttacher) FreezeGuest() (bool, error) {
+	if !guestAgentConnected(a.domainDesc) {
+		return false, nil
+	}
+	if err := a.executor.FreezeFilesystems(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (a *libvirtVolumeAttacher) ThawGuest() error {
+	return a.executor.ThawFilesystems()
+}
+
+// attachedDisk returns the disk of the attached volume.
+func (a *libvirtVolumeAttacher) attachedDisk(name string) (*libvirtxml.DomainDisk, error) {
+	idx, err := a.diskByVolumeNameIndex(name)
+	if err != nil {
+		return nil, err
+	}
+	if idx == -1 {
+		return nil, ErrAttachedVolumeNotFound
+	}
+	return &a.domainDevices().Disks[idx], nil
+}
+
+// attachedDiskTarget returns the target of the disk of the attached volume.
+func (a *libvirtVolumeAttacher) attachedDiskTarget(name string) (string, error) {
+	disk, err := a.attachedDisk(name)
+	if err != nil {
+		return "", err
+	}
+	return getDiskTargetDevice(disk)
+}
+
+// volumeSecretKeys are the keys the secrets of a volume are derived from. The first key is the one of attached
+// volumes, a copy of the volume uses the key its attached disk doesn't use.
+func volumeSecretKeys(computeVolumeName string) []string {
+	return []string{computeVolumeName, computeVolumeName + "/copy"}
+}
+
+// unusedSecretKey returns the secret key of the volume the given disk doesn't use secrets of.
+func (a *libvirtVolumeAttacher) unusedSecretKey(computeVolumeName string, disk *libvirtxml.DomainDisk) string {
+	used := diskSecretUUIDs(disk)
+	keys := volumeSecretKeys(computeVolumeName)
+	for _, secretKey := range keys {
+		if !used.Has(a.secretUUID(secretKey)) && !used.Has(a.secretEncryptionUUID(secretKey)) {
+			return secretKey
+		}
+	}
+	return keys[0]
+}
+
+// diskSecretUUIDs returns the UUIDs of the secrets the disk uses.
+func diskSecretUUIDs(disk *libvirtxml.DomainDisk) sets.Set[string] {
+	uuids := sets.New[string]()
+	addAuth := func(auth *libvirtxml.DomainDiskAuth) {
+		if auth != nil && auth.Secret != nil {
+			uuids.Insert(auth.Secret.UUID)
+		}
+	}
+	addEncryption := func(encryption *libvirtxml.DomainDiskEncryption) {
+		if encryption == nil {
+			return
+		}
+		for _, secret := range encryption.Secrets {
+			uuids.Insert(secret.UUID)
+		}
+	}
+
+	addAuth(disk.Auth)
+	addEncryption(disk.Encryption)
+	if source := disk.Source; source != nil {
+		if source.Network != nil {
+			addAuth(source.Network.Auth)
+		}
+		addEncryption(source.Encryption)
+	}
+	return uuids
+}
 
-	a.domainDevices().Disks = slices.Delete(a.domainDevices().Disks, idx, idx)
+// deleteVolumeSecrets deletes the secrets of the secret key that aren't in use.
+func (a *libvirtVolumeAttacher) deleteVolumeSecrets(secretKey string, inUse sets.Set[string]) error {
+	for _, secretUUID := range []string{a.secretUUID(secretKey), a.secretEncryptionUUID(secretKey)} {
+		if inUse.Has(secretUUID) {
+			continue
+		}
+		if err := a.executor.DeleteSecret(secretUUID); libvirtutils.IgnoreErrorCode(err, libvirt.ErrNoSecret) != nil {
+			return err
+		}
+	}
+	return nil
+}
 
+func (a *libvirtVolumeAttacher) DeleteUnusedVolumeSecrets(name string) error {
+	disk, err := a.attachedDisk(name)
+	if err != nil {
+		return err
+	}
+
+	inUse := diskSecretUUIDs(disk)
+	for _, secretKey := range volumeSecretKeys(name) {
+		if err := a.deleteVolumeSecrets(secretKey, inUse); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+func (a *libvirtVolumeAttacher) CopyVolume(volume *AttachVolume) error {
+	attached, err := a.attachedDisk(volume.Name)
+	if err != nil {
+		return err
+	}
+	target, err := getDiskTargetDevice(attached)
+	if err != nil {
+		return err
+	}
+
+	// The domain uses the attached disk until the pivot, the copy uses secrets with other UUIDs and usages than
+	// the ones of the attached disk, so they aren't overwritten.
+	secretKey := a.unusedSecretKey(volume.Name, attached)
+	disk, secret, encryptionSecret, secretValue, encryptionSecretValue, err := a.providerVolumeToLibvirt(volume.Name, secretKey, &volume.Spec, volume.Device, target)
+	if err != nil {
+		return err
+	}
+
+	if secret != nil {
+		if err := a.executor.ApplySecret(secret, secretValue); err != nil {
+			return err
+		}
+	}
+	if encryptionSecret != nil {
+		if err := a.executor.ApplySecret(encryptionSecret, encryptionSecretValue); err != nil {
+			return err
+		}
+	}
+
+	// Only the source and driver of the destination are used, the disk keeps its address, alias and target.
+	return a.executor.CopyDisk(target, disk)
+}
+
+func (a *libvirtVolumeAttacher) VolumeCopyProgress(name string) (int64, int64, bool, error) {
+	target, err := a.attachedDiskTarget(name)
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	cur, end, found, err := a.executor.BlockJobProgress(target)
+	if err != nil || !found {
+		return 0, 0, false, err
+	}
+	return int64(cur), int64(end), true, nil
+}
+
+func (a *libvirtVolumeAttacher) PivotVolume(name string) error {
+	target, err := a.attachedDiskTarget(name)
+	if err != nil {
+		return err
+	}
+	return a.executor.PivotDisk(target)
+}
+
+func (a *libvirtVolumeAttacher) AbortVolumeCopy(name string) error {
+	target, err := a.attachedDiskTarget(name)
+	if err != nil {
+		return err
+	}
+	return a.executor.AbortBlockJob(target)
+}
+
+// assignPCIAddress assigns a free root port of the domain. Root ports can't be hotplugged, they are only added
+// when the domain is created, see pci.Layout.
+func assignPCIAddress(domainDesc *libvirtxml.Domain) (*libvirtxml.DomainAddress, error) {
+	topology, err := pci.ForDomain(domainDesc)
+	if err != nil {
+		return nil, err
+	}
+	return topology.Assign()
+}
+
+// assignIOThread assigns a dedicated iothread to a virtio disk, adding it to the domain if it doesn't exist yet.
+// If all dedicated iothreads are in use, the disk shares the iothreads of the domain. The returned function
+// releases a newly added iothread again if the disk can't be attached.
+func (a *libvirtVolumeAttacher) assignIOThread(disk *libvirtxml.DomainDisk) (func(), error) {
+	release := func() {}
+	if disk.Target == nil || disk.Target.Bus != "virtio" {
+		return release, nil
+	}
+
+	id, ok := a.cpuPinning.freeDedicatedIOThread(a.domainDesc)
+	if !ok {
+		assignDiskIOThread(a.domainDesc, disk, a.cpuPinning.IOThreads)
+		return release, nil
+	}
+
+	if !domainHasIOThread(a.domainDesc, id) {
+		if err := a.executor.AddIOThread(id, a.cpuPinning.IOThreadCPUSet); err != nil {
+			return nil, err
+		}
+		addDomainIOThread(a.domainDesc, id, a.cpuPinning.IOThreadCPUSet)
+		release = func() {
+			if err := a.executor.DeleteIOThread(id); err == nil {
+				removeDomainIOThread(a.domainDesc, id)
+			}
+		}
+	}
+
+	setDiskIOThread(disk, id)
+	return release, nil
+}
+
 func (a *libvirtVolumeAttacher) ResizeVolume(volume *AttachVolume) error {
-	return a.executor.ResizeDisk(volume.Device, volume.Spec.Size)
+	idx, err := a.diskByVolumeNameIndex(volume.Name)
+	if err != nil {
+		return err
+	}
+	if idx == -1 {
+		return ErrAttachedVolumeNotFound
+	}
+
+	target, err := getDiskTargetDevice(&a.domainDevices().Disks[idx])
+	if err != nil {
+		return err
+	}
+	return a.executor.ResizeDisk(target, volume.Spec.Size)
 }
 
 func (a *libvirtVolumeAttacher) GetVolume(name string) (*AttachVolume, error) {
@@ -573,6 +1075,16 @@ func (m *volumeMounter) DeleteVolume(ctx context.Context, computeVolumeName stri
 		return err
 	}
 
+	inUse, err := m.pluginManager.UnrefSharedVolume(mountedVolume.PluginName, m.machine.ID, computeVolumeName)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		// The backing volume is still attached to other machines, only the volume of this machine goes away.
+		volumeDir := m.host.MachineVolumeDir(m.machine.ID, utilstrings.EscapeQualifiedName(mountedVolume.PluginName), computeVolumeName)
+		return os.RemoveAll(volumeDir)
+	}
+
 	if err := plugin.Delete(ctx, computeVolumeName, m.machine.ID); err != nil {
 		return err
 	}
@@ -600,7 +1112,7 @@ func (m *volumeMounter) ApplyVolume(ctx context.Context, spec *api.VolumeSpec, o
 		}
 	}
 
-	volumeID, err := plugin.GetBackingVolumeID(spec)
+	volumeID, err := plugin.GetBackingVolumeID(spec, m.machine.ID)
 	if err != nil {
 		return "", nil, err
 	}
@@ -609,39 +1121,154 @@ func (m *volumeMounter) ApplyVolume(ctx context.Context, spec *api.VolumeSpec, o
 	if err != nil {
 		return "", nil, err
 	}
+	volume.Shareable = spec.Shareable
+	volumeDir := m.host.MachineVolumeDir(m.machine.ID, utilstrings.EscapeQualifiedName(plugin.Name()), spec.Name)
+	if err := writeVolumeSecrets(volumeDir, spec); err != nil {
+		return "", nil, err
+	}
+	if spec.Shareable {
+		if err := m.pluginManager.RefSharedVolume(plugin.Name(), volumeID, m.machine.ID, spec.Name); err != nil {
+			return "", nil, err
+		}
+	}
 
 	return GetUniqueVolumeName(plugin.Name(), volumeID), volume, nil
 }
 
-func (r *MachineReconciler) applyVolume(
+// preparedVolume is a mounted volume that is ready to be attached.
+type preparedVolume struct {
+	spec     *api.VolumeSpec
+	volumeID string
+	volume   *providervolume.Volume
+}
+
+func (r *MachineReconciler) prepareVolume(
 	ctx context.Context,
 	log logr.Logger,
 	machine *api.Machine,
 	desiredVolume *api.VolumeSpec,
 	mountedVolumes VolumeMounter,
 	attacher VolumeAttacher,
-) (string, int64, error) {
-	log.V(1).Info("Getting volume spec")
+) (*preparedVolume, error) {
+	// Volumes of unhealthy plugins aren't attached, the attached ones are left alone.
+	if _, err := attacher.GetVolume(desiredVolume.Name); errors.Is(err, ErrAttachedVolumeNotFound) {
+		if plugin, err := mountedVolumes.PluginManager().FindPluginBySpec(desiredVolume); err == nil {
+			if err := mountedVolumes.PluginManager().VolumeHealth(plugin, desiredVolume); err != nil {
+				return nil, err
+			}
+		}
+	}
 
-	log.V(1).Info("Applying volume")
+	log.V(2).Info("Applying volume")
 	volumeID, providerVolume, err := mountedVolumes.ApplyVolume(ctx, desiredVolume, func(outdated *MountVolume) error {
-		log.V(1).Info("Detaching outdated mounted volume before deleting", "PluginName", outdated.PluginName)
-		if err := attacher.DetachVolume(outdated.ComputeVolumeName); err != nil && !errors.Is(err, ErrAttachedVolumeNotFound) {
-			return fmt.Errorf("error detaching volume: %w", err)
-		}
-		return nil
+		log.V(2).Info("Detaching outdated mounted volume before deleting", "PluginName", outdated.PluginName)
+		return r.detachOutdatedVolume(log, machine.ID, attacher, outdated.ComputeVolumeName)
 	})
 	if err != nil {
-		return "", 0, fmt.Errorf("error applying volume mount: %w", err)
+		return nil, fmt.Errorf("error applying volume mount: %w", err)
+	}
+
+	return &preparedVolume{
+		spec:     desiredVolume,
+		volumeID: volumeID,
+		volume:   providerVolume,
+	}, nil
+}
+
+// frozenGuest is a guest whose file systems are frozen until the pending detachments of its outdated volumes
+// complete.
+type frozenGuest struct {
+	// volumes are the names of the volumes whose detachment is pending.
+	volumes sets.Set[string]
+	// deadline is the time the guest is thawed at if the detachments didn't complete by then.
+	deadline time.Time
+	// thawed is set once the guest is thawed, the pending detachments are retried without freezing it again.
+	thawed bool
+}
+
+// detachOutdatedVolume detaches a volume whose plugin changed. If enabled, the volume is quiesced before, so
+// the guest flushed all writes before the volume is migrated to the new plugin. The guest stays frozen while the
+// detachment is pending, until it completes or the quiesce timeout passes.
+func (r *MachineReconciler) detachOutdatedVolume(log logr.Logger, machineID string, attacher VolumeAttacher, volumeName string) error {
+	if !r.quiesceVolumeDetach {
+		return detachVolume(attacher, volumeName)
+	}
+
+	var guest *frozenGuest
+	if value, ok := r.frozenGuests.Load(machineID); ok {
+		guest = value.(*frozenGuest)
+	}
+	if guest == nil || !guest.volumes.Has(volumeName) {
+		if err := attacher.QuiesceVolume(volumeName); err != nil {
+			if errors.Is(err, ErrAttachedVolumeNotFound) {
+				return nil
+			}
+			return fmt.Errorf("error quiescing volume: %w", err)
+		}
+		if guest == nil {
+			frozen, err := attacher.FreezeGuest()
+			if err != nil {
+				return err
+			}
+			guest = &frozenGuest{volumes: sets.New[string](), deadline: time.Now().Add(r.quiesceTimeout), thawed: !frozen}
+			r.frozenGuests.Store(machineID, guest)
+		}
+		guest.volumes.Insert(volumeName)
 	}
 
-	log.V(1).Info("Ensuring volume is attached")
+	err := detachVolume(attacher, volumeName)
+	if !errors.Is(err, hotplug.ErrPending) {
+		guest.volumes.Delete(volumeName)
+	}
+	switch {
+	case guest.volumes.Len() == 0:
+		r.frozenGuests.Delete(machineID)
+	case guest.thawed:
+		return err
+	case time.Now().Before(guest.deadline):
+		r.queue.AddAfter(machineID, time.Until(guest.deadline))
+		return err
+	default:
+		log.Info("Thawing guest, detaching volumes did not complete within the quiesce timeout", "volumeNames", sets.List(guest.volumes))
+	}
+
+	if !guest.thawed {
+		guest.thawed = true
+		if thawErr := attacher.ThawGuest(); thawErr != nil {
+			err = errors.Join(err, fmt.Errorf("error thawing guest: %w", thawErr))
+		}
+	}
+	return err
+}
+
+func detachVolume(attacher VolumeAttacher, volumeName string) error {
+	if err := attacher.DetachVolume(volumeName); err != nil && !errors.Is(err, ErrAttachedVolumeNotFound) {
+		return fmt.Errorf("error detaching volume: %w", err)
+	}
+	return nil
+}
+
+// attachPreparedVolume ensures the prepared volume is attached and has its current size.
+func (r *MachineReconciler) attachPreparedVolume(
+	log logr.Logger,
+	machine *api.Machine,
+	prepared *preparedVolume,
+	attacher VolumeAttacher,
+) error {
+	desiredVolume, volumeID, providerVolume := prepared.spec, prepared.volumeID, prepared.volume
+
+	log.V(2).Info("Ensuring volume is attached")
 	if err := attacher.AttachVolume(&AttachVolume{
 		Name:   desiredVolume.Name,
 		Device: desiredVolume.Device,
 		Spec:   *providerVolume,
-	}); err != nil && !errors.Is(err, ErrAttachedVolumeAlreadyExists) {
-		return "", 0, fmt.Errorf("error ensuring volume is attached: %w", err)
+	}); err != nil {
+		if !errors.Is(err, ErrAttachedVolumeAlreadyExists) {
+			r.recordOperation(log, machine.ID, journal.OperationAttach, desiredVolume.Name, err)
+			return fmt.Errorf("error ensuring volume is attached: %w", err)
+		}
+	} else {
+		r.recordOperation(log, machine.ID, journal.OperationAttach, desiredVolume.Name, nil)
 	}
 
 	//TODO do epsilon comparison
@@ -652,11 +1279,13 @@ func (r *MachineReconciler) applyVolume(
 			Device: desiredVolume.Device,
 			Spec:   *providerVolume,
 		}); err != nil {
-			return "", 0, fmt.Errorf("failed to resize volume: %w", err)
+			r.recordOperation(log, machine.ID, journal.OperationResize, desiredVolume.Name, err)
+			return fmt.Errorf("failed to resize volume: %w", err)
 		}
+		r.recordOperation(log, machine.ID, journal.OperationResize, desiredVolume.Name, nil)
 	}
 
-	return volumeID, providerVolume.Size, nil
+	return nil
 }
 
 func (r *MachineReconciler) listDesiredVolumes(machine *api.Machine) map[string]*api.VolumeSpec {
@@ -668,55 +1297,32 @@ func (r *MachineReconciler) listDesiredVolumes(machine *api.Machine) map[string]
 	return res
 }
 
-const (
-	volumeAliasPrefix = "ua-volume-"
-)
-
-func isDiskAlias(alias string) bool {
-	return strings.HasPrefix(alias, volumeAliasPrefix)
-}
-
-func parseVolumeDiskAlias(alias string) (computeVolumeName string, err error) {
-	if !strings.HasPrefix(alias, volumeAliasPrefix) {
-		return "", fmt.Errorf("no disk volume alias: %s", alias)
-	}
-
-	data := strings.TrimPrefix(alias, volumeAliasPrefix)
-	res, err := base64.RawURLEncoding.DecodeString(data)
-	if err != nil {
-		return "", err
-	}
-
-	return string(res), nil
+func (a *libvirtVolumeAttacher) secretUUID(secretKey string) string {
+	return uuid.NewHash(sha256.New(), uuid.Nil, []byte(fmt.Sprintf("%s/%s", a.domainDesc.UUID, secretKey)), 5).String()
 }
 
-func volumeDiskAlias(computeVolumeName string) string {
-	data := base64.RawURLEncoding.EncodeToString([]byte(computeVolumeName))
-	return fmt.Sprintf("%s%s", volumeAliasPrefix, data)
+func (a *libvirtVolumeAttacher) secretEncryptionUUID(secretKey string) string {
+	return uuid.NewHash(sha256.New(), uuid.Nil, []byte(fmt.Sprintf("enc/%s/%s", a.domainDesc.UUID, secretKey)), 5).String()
 }
 
-func (a *libvirtVolumeAttacher) secretUUID(computeVolumeName string) string {
-	return uuid.NewHash(sha256.New(), uuid.Nil, []byte(fmt.Sprintf("%s/%s", a.domainDesc.UUID, computeVolumeName)), 5).String()
-}
-
-func (a *libvirtVolumeAttacher) secretEncryptionUUID(computeVolumeName string) string {
-	return uuid.NewHash(sha256.New(), uuid.Nil, []byte(fmt.Sprintf("enc/%s/%s", a.domainDesc.UUID, computeVolumeName)), 5).String()
-}
-
-func (a *libvirtVolumeAttacher) providerVolumeToLibvirt(computeVolumeName string, vol *providervolume.Volume, dev string) (*libvirtxml.DomainDisk, *libvirtxml.Secret, *libvirtxml.Secret, []byte, []byte, error) {
-	deviceName := computeVirtioDiskTargetDeviceName(dev)
+// providerVolumeToLibvirt returns the disk of the volume and the secrets it uses. The UUIDs and usages of the
+// secrets are derived from the secret key, see volumeSecretKeys.
+func (a *libvirtVolumeAttacher) providerVolumeToLibvirt(computeVolumeName, secretKey string, vol *providervolume.Volume, dev, target string) (*libvirtxml.DomainDisk, *libvirtxml.Secret, *libvirtxml.Secret, []byte, []byte, error) {
 
 	disk := &libvirtxml.DomainDisk{
 		Alias: &libvirtxml.DomainAlias{
-			Name: volumeDiskAlias(computeVolumeName),
+			Name: alias.Volume(computeVolumeName),
 		},
 		Device: "disk",
 		Target: &libvirtxml.DomainDiskTarget{
-			Dev: deviceName,
+			Dev: target,
 			Bus: "virtio",
 		},
 		Serial: dev + "-" + vol.Handle,
 	}
+	if vol.Shareable {
+		disk.Shareable = &libvirtxml.DomainDiskShareable{}
+	}
 
 	switch {
 	case vol.QCow2File != "":
@@ -729,6 +1335,7 @@ func (a *libvirtVolumeAttacher) providerVolumeToLibvirt(computeVolumeName string
 				File: vol.QCow2File,
 			},
 		}
+		a.setDiskDriverModes(disk.Driver, vol)
 		return disk, nil, nil, nil, nil, nil
 	case vol.RawFile != "":
 		disk.Driver = &libvirtxml.DomainDiskDriver{
@@ -740,6 +1347,19 @@ func (a *libvirtVolumeAttacher) providerVolumeToLibvirt(computeVolumeName string
 				File: vol.RawFile,
 			},
 		}
+		a.setDiskDriverModes(disk.Driver, vol)
+		return disk, nil, nil, nil, nil, nil
+	case vol.BlockDevice != "":
+		disk.Driver = &libvirtxml.DomainDiskDriver{
+			Name: "qemu",
+			Type: "raw",
+		}
+		disk.Source = &libvirtxml.DomainDiskSource{
+			Block: &libvirtxml.DomainDiskSourceBlock{
+				Dev: vol.BlockDevice,
+			},
+		}
+		a.setDiskDriverModes(disk.Driver, vol)
 		return disk, nil, nil, nil, nil, nil
 	case vol.CephDisk != nil:
 		var (
@@ -756,17 +1376,17 @@ func (a *libvirtVolumeAttacher) providerVolumeToLibvirt(computeVolumeName string
 				Username: auth.UserName,
 				Secret: &libvirtxml.DomainDiskSecret{
 					Type: "ceph",
-					UUID: a.secretUUID(computeVolumeName),
+					UUID: a.secretUUID(secretKey),
 				},
 			}
 
 			secret = &libvirtxml.Secret{
 				Ephemeral: "no",
 				Private:   "no",
-				UUID:      a.secretUUID(computeVolumeName),
+				UUID:      a.secretUUID(secretKey),
 				Usage: &libvirtxml.SecretUsage{
 					Type: "ceph",
-					Name: fmt.Sprintf("domain.%s.volume.%s.client.%s secret", a.domainDesc.UUID, computeVolumeName, auth.UserName),
+					Name: fmt.Sprintf("domain.%s.volume.%s.client.%s secret", a.domainDesc.UUID, secretKey, auth.UserName),
 				},
 			}
 
@@ -786,7 +1406,7 @@ func (a *libvirtVolumeAttacher) providerVolumeToLibvirt(computeVolumeName string
 				Secrets: []libvirtxml.DomainDiskSecret{
 					{
 						Type: "passphrase",
-						UUID: a.secretEncryptionUUID(computeVolumeName),
+						UUID: a.secretEncryptionUUID(secretKey),
 					},
 				},
 			}
@@ -794,11 +1414,11 @@ func (a *libvirtVolumeAttacher) providerVolumeToLibvirt(computeVolumeName string
 			encryptionSecret = &libvirtxml.Secret{
 				Ephemeral: "no",
 				Private:   "yes",
-				UUID:      a.secretEncryptionUUID(computeVolumeName),
+				UUID:      a.secretEncryptionUUID(secretKey),
 				Usage: &libvirtxml.SecretUsage{
 					Type:   "volume",
-					Name:   fmt.Sprintf("domain.%s.volume.%s secret", a.domainDesc.UUID, computeVolumeName),
-					Volume: fmt.Sprintf("domain.%s.volume.%s", a.domainDesc.UUID, computeVolumeName),
+					Name:   fmt.Sprintf("domain.%s.volume.%s secret", a.domainDesc.UUID, secretKey),
+					Volume: fmt.Sprintf("domain.%s.volume.%s", a.domainDesc.UUID, secretKey),
 				},
 			}
 
@@ -815,16 +1435,17 @@ func (a *libvirtVolumeAttacher) providerVolumeToLibvirt(computeVolumeName string
 		disk.Source = &libvirtxml.DomainDiskSource{
 			Network: &libvirtxml.DomainDiskSourceNetwork{
 				Protocol: "rbd",
-				Name:     vol.CephDisk.Name,
+				Name:     vol.CephDisk.SourceName(),
 				Hosts:    hosts,
 				Auth:     diskAuth,
 			},
 			Encryption: diskEncryption,
 		}
-		disk.Driver = &libvirtxml.DomainDiskDriver{
-			Cache: a.volumeCachePolicy,
-			IO:    "threads",
+		if configFile := vol.CephDisk.ConfigFile; configFile != "" {
+			disk.Source.Network.Config = &libvirtxml.DomainDiskSourceNetworkConfig{File: configFile}
 		}
+		disk.Driver = &libvirtxml.DomainDiskDriver{}
+		a.setDiskDriverModes(disk.Driver, vol)
 
 		return disk, secret, encryptionSecret, secretValue, encryptionSecretValue, nil
 	default:
@@ -832,6 +1453,18 @@ func (a *libvirtVolumeAttacher) providerVolumeToLibvirt(computeVolumeName string
 	}
 }
 
+// setDiskDriverModes sets the cache, io, discard and detect_zeroes modes of the volume and the number of queues
+// to the disk driver.
+func (a *libvirtVolumeAttacher) setDiskDriverModes(driver *libvirtxml.DomainDiskDriver, vol *providervolume.Volume) {
+	if a.diskQueues > 1 {
+		driver.Queues = ptr.To(a.diskQueues)
+	}
+	driver.Cache = vol.Cache
+	driver.IO = vol.IO
+	driver.Discard = vol.Discard
+	driver.DetectZeros = vol.DetectZeroes
+}
+
 func libvirtDiskToProviderVolume(disk *libvirtxml.DomainDisk) (*providervolume.Volume, error) {
 	src := disk.Source
 	if src == nil {
@@ -847,6 +1480,10 @@ func libvirtDiskToProviderVolume(disk *libvirtxml.DomainDisk) (*providervolume.V
 		return &providervolume.Volume{
 			RawFile: src.File.File,
 		}, nil
+	case src.Block != nil && src.Block.Dev != "":
+		return &providervolume.Volume{
+			BlockDevice: src.Block.Dev,
+		}, nil
 	case src.Network != nil && src.Network.Protocol == "rbd":
 		netSrc := src.Network
 		monitors := make([]providervolume.CephMonitor, 0, len(netSrc.Hosts))
@@ -854,10 +1491,17 @@ func libvirtDiskToProviderVolume(disk *libvirtxml.DomainDisk) (*providervolume.V
 			monitors = append(monitors, providervolume.CephMonitor{Name: host.Name, Port: host.Port})
 		}
 
+		var configFile string
+		if netSrc.Config != nil {
+			configFile = netSrc.Config.File
+		}
+
+		// The namespace stays part of the name, SourceName renders it unchanged.
 		return &providervolume.Volume{
 			CephDisk: &providervolume.CephDisk{
-				Name:     netSrc.Name,
-				Monitors: monitors,
+				Name:       netSrc.Name,
+				Monitors:   monitors,
+				ConfigFile: configFile,
 				// TODO: Check whether it's necessary to reconstruct Auth.
 			},
 		}, nil
@@ -0,0 +1,267 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"time"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	providerhost "github.com/ironcore-dev/libvirt-provider/internal/host"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/alias"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/hotplug"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/pci"
+	providervolume "github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/workqueue"
+	"libvirt.org/go/libvirtxml"
+)
+
+// fakeVolumePlugin provides raw file volumes for empty disk specs. Applying the volumes in failApply fails.
+type fakeVolumePlugin struct {
+	dir       string
+	failApply sets.Set[string]
+}
+
+func (p *fakeVolumePlugin) Init(providervolume.Host) error { return nil }
+func (p *fakeVolumePlugin) Name() string                   { return "fake" }
+
+func (p *fakeVolumePlugin) GetBackingVolumeID(spec *api.VolumeSpec, machineID string) (string, error) {
+	return machineID + "/" + spec.Name, nil
+}
+
+func (p *fakeVolumePlugin) CanSupport(spec *api.VolumeSpec) bool { return spec.EmptyDisk != nil }
+
+func (p *fakeVolumePlugin) Apply(_ context.Context, spec *api.VolumeSpec, _ *api.Machine) (*providervolume.Volume, error) {
+	if p.failApply.Has(spec.Name) {
+		return nil, errors.New("apply failed")
+	}
+	return &providervolume.Volume{RawFile: filepath.Join(p.dir, spec.Name), Size: spec.EmptyDisk.Size}, nil
+}
+
+func (p *fakeVolumePlugin) Delete(context.Context, string, string) error            { return nil }
+func (p *fakeVolumePlugin) GetSize(context.Context, *api.VolumeSpec) (int64, error) { return 0, nil }
+func (p *fakeVolumePlugin) HealthCheck(context.Context) error                       { return nil }
+
+// fakeDomainExecutor attaches and detaches disks to the domain description only. Attaching the volumes in
+// failAttach fails.
+type fakeDomainExecutor struct {
+	DomainExecutor
+	failAttach sets.Set[string]
+}
+
+func (e *fakeDomainExecutor) AttachDisk(disk *libvirtxml.DomainDisk) error {
+	name, err := alias.ParseVolume(disk.Alias.Name)
+	if err != nil {
+		return err
+	}
+	if e.failAttach.Has(name) {
+		return errors.New("attach failed")
+	}
+	return nil
+}
+
+func (e *fakeDomainExecutor) DetachDisk(*libvirtxml.DomainDisk) error { return nil }
+
+func (e *fakeDomainExecutor) ApplySecret(*libvirtxml.Secret, []byte) error { return nil }
+func (e *fakeDomainExecutor) DeleteSecret(string) error                    { return nil }
+
+// fakeQuiesceAttacher detaches volumes with detachErr and counts how often the guest was frozen and thawed.
+type fakeQuiesceAttacher struct {
+	VolumeAttacher
+	detachErr      error
+	freezes, thaws int
+}
+
+func (a *fakeQuiesceAttacher) QuiesceVolume(string) error { return nil }
+func (a *fakeQuiesceAttacher) DetachVolume(string) error  { return a.detachErr }
+
+func (a *fakeQuiesceAttacher) FreezeGuest() (bool, error) {
+	a.freezes++
+	return true, nil
+}
+
+func (a *fakeQuiesceAttacher) ThawGuest() error {
+	a.thaws++
+	return nil
+}
+
+var _ = Describe("Machine volumes", func() {
+	var (
+		r        *MachineReconciler
+		plugin   *fakeVolumePlugin
+		executor *fakeDomainExecutor
+		domain   *libvirtxml.Domain
+		machine  *api.Machine
+	)
+
+	BeforeEach(func() {
+		dir := GinkgoT().TempDir()
+		host, err := providerhost.NewAt(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(providerhost.MakeMachineDirs(host, "foo")).To(Succeed())
+
+		plugin = &fakeVolumePlugin{dir: dir, failApply: sets.New[string]()}
+		plugins := providervolume.NewPluginManager()
+		Expect(plugins.InitPlugins(host, []providervolume.Plugin{plugin})).To(Succeed())
+
+		r = &MachineReconciler{host: host, volumePluginManager: plugins}
+		executor = &fakeDomainExecutor{failAttach: sets.New[string]()}
+
+		domain = &libvirtxml.Domain{UUID: "foo"}
+		topology, err := pci.ForDomain(domain)
+		Expect(err).NotTo(HaveOccurred())
+		for range 4 {
+			topology.AddRootPort()
+		}
+
+		machine = &api.Machine{
+			Metadata: api.Metadata{ID: "foo"},
+			Spec: api.MachineSpec{Volumes: []*api.VolumeSpec{
+				{Name: "a", Device: "oda", EmptyDisk: &api.EmptyDiskSpec{Size: 1024}},
+				{Name: "b", Device: "odb", EmptyDisk: &api.EmptyDiskSpec{Size: 1024}},
+				{Name: "c", Device: "odc", EmptyDisk: &api.EmptyDiskSpec{Size: 1024}},
+			}},
+		}
+	})
+
+	attachDetachVolumes := func(ctx context.Context) ([]api.VolumeStatus, error) {
+		attacher, err := NewLibvirtVolumeAttacher(domain, executor, CPUPinningOptions{}, 0)
+		Expect(err).NotTo(HaveOccurred())
+		return r.attachDetachVolumes(ctx, GinkgoLogr, machine, attacher)
+	}
+
+	attachedVolumes := func() []string {
+		var names []string
+		for _, disk := range domain.Devices.Disks {
+			name, err := alias.ParseVolume(disk.Alias.Name)
+			Expect(err).NotTo(HaveOccurred())
+			names = append(names, name)
+		}
+		return names
+	}
+
+	It("should attach all volumes", func(ctx SpecContext) {
+		states, err := attachDetachVolumes(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(states).To(ConsistOf(
+			HaveField("Name", "a"), HaveField("Name", "b"), HaveField("Name", "c"),
+		))
+		Expect(attachedVolumes()).To(ConsistOf("a", "b", "c"))
+	})
+
+	It("should attach the other volumes if a volume fails to be prepared", func(ctx SpecContext) {
+		plugin.failApply.Insert("b")
+
+		states, err := attachDetachVolumes(ctx)
+		var attachDetachErr *attachDetachError
+		Expect(errors.As(err, &attachDetachErr)).To(BeTrue())
+		Expect(attachDetachErr.failed).To(HaveKey("b"))
+		Expect(attachDetachErr.failed).To(HaveLen(1))
+
+		Expect(states).To(ConsistOf(
+			SatisfyAll(HaveField("Name", "a"), HaveField("State", api.VolumeStateAttached)),
+			SatisfyAll(HaveField("Name", "c"), HaveField("State", api.VolumeStateAttached)),
+		))
+		Expect(attachedVolumes()).To(ConsistOf("a", "c"))
+	})
+
+	It("should keep the other volumes attached if a volume fails to be attached", func(ctx SpecContext) {
+		executor.failAttach.Insert("a")
+		machine.Status.VolumeStatus = []api.VolumeStatus{
+			{Name: "a", State: api.VolumeStateFailed, Message: "attach failed", FailedAttempts: 1},
+		}
+
+		states, err := attachDetachVolumes(ctx)
+		var attachDetachErr *attachDetachError
+		Expect(errors.As(err, &attachDetachErr)).To(BeTrue())
+		Expect(attachDetachErr.failed).To(HaveKey("a"))
+
+		By("reporting the last known state of the failed volume")
+		Expect(states).To(ConsistOf(
+			SatisfyAll(HaveField("Name", "a"), HaveField("FailedAttempts", 1)),
+			HaveField("Name", "b"),
+			HaveField("Name", "c"),
+		))
+		Expect(attachedVolumes()).To(ConsistOf("b", "c"))
+
+		By("counting the failed attempt")
+		machine.Status.VolumeStatus = states
+		r.EventRecorder = nopEventRecorder{}
+		r.setFailed(GinkgoLogr, machine, attachDetachErr)
+		Expect(machine.Status.VolumeStatus).To(ContainElement(SatisfyAll(
+			HaveField("Name", "a"),
+			HaveField("State", api.VolumeStateFailed),
+			HaveField("FailedAttempts", 2),
+		)))
+	})
+
+	It("should remove a detached volume from the domain", func(ctx SpecContext) {
+		_, err := attachDetachVolumes(ctx)
+		Expect(err).NotTo(HaveOccurred())
+
+		attacher, err := NewLibvirtVolumeAttacher(domain, executor, CPUPinningOptions{}, 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(attacher.DetachVolume("b")).To(Succeed())
+		Expect(attachedVolumes()).To(ConsistOf("a", "c"))
+	})
+
+	Describe("detachOutdatedVolume", func() {
+		var attacher *fakeQuiesceAttacher
+
+		BeforeEach(func() {
+			r.quiesceVolumeDetach = true
+			r.quiesceTimeout = time.Hour
+			r.queue = workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[string]())
+			DeferCleanup(r.queue.ShutDown)
+			attacher = &fakeQuiesceAttacher{detachErr: hotplug.ErrPending}
+		})
+
+		It("should keep the guest frozen until the detachment completes", func() {
+			for range 3 {
+				Expect(r.detachOutdatedVolume(GinkgoLogr, "foo", attacher, "a")).To(MatchError(hotplug.ErrPending))
+			}
+			Expect(attacher.freezes).To(Equal(1))
+			Expect(attacher.thaws).To(BeZero())
+
+			By("thawing the guest once the detachment completed")
+			attacher.detachErr = nil
+			Expect(r.detachOutdatedVolume(GinkgoLogr, "foo", attacher, "a")).To(Succeed())
+			Expect(attacher.freezes).To(Equal(1))
+			Expect(attacher.thaws).To(Equal(1))
+		})
+
+		It("should keep the guest frozen until the detachments of all volumes complete", func() {
+			Expect(r.detachOutdatedVolume(GinkgoLogr, "foo", attacher, "a")).To(MatchError(hotplug.ErrPending))
+			Expect(r.detachOutdatedVolume(GinkgoLogr, "foo", attacher, "b")).To(MatchError(hotplug.ErrPending))
+
+			attacher.detachErr = nil
+			Expect(r.detachOutdatedVolume(GinkgoLogr, "foo", attacher, "a")).To(Succeed())
+			Expect(attacher.thaws).To(BeZero())
+			Expect(r.detachOutdatedVolume(GinkgoLogr, "foo", attacher, "b")).To(Succeed())
+			Expect(attacher.freezes).To(Equal(1))
+			Expect(attacher.thaws).To(Equal(1))
+		})
+
+		It("should thaw the guest once if the detachment times out", func() {
+			r.quiesceTimeout = 0
+			for range 3 {
+				Expect(r.detachOutdatedVolume(GinkgoLogr, "foo", attacher, "a")).To(MatchError(hotplug.ErrPending))
+			}
+			Expect(attacher.freezes).To(Equal(1))
+			Expect(attacher.thaws).To(Equal(1))
+
+			By("freezing the guest again for the next detachment")
+			attacher.detachErr = nil
+			Expect(r.detachOutdatedVolume(GinkgoLogr, "foo", attacher, "a")).To(Succeed())
+			Expect(r.detachOutdatedVolume(GinkgoLogr, "foo", attacher, "b")).To(Succeed())
+			Expect(attacher.freezes).To(Equal(2))
+			Expect(attacher.thaws).To(Equal(2))
+		})
+	})
+})
@@ -0,0 +1,118 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package deviceprofile renders the auxiliary devices of domains, e.g. the random number generator, the memory
+// balloon, input and video devices, from the device profile of the machine class.
+package deviceprofile
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"libvirt.org/go/libvirtxml"
+)
+
+// DefaultRNGBytes is the number of bytes per second passed to guests of machines without device profile.
+const DefaultRNGBytes = 512
+
+var (
+	inputTypes  = []string{"tablet", "keyboard", "mouse"}
+	inputBuses  = []string{"usb", "virtio"}
+	videoModels = []string{"virtio", "vga", "bochs", "cirrus", "ramfb"}
+)
+
+// Validate checks that the devices of the spec are supported.
+func Validate(spec *api.DevicesSpec) error {
+	if spec == nil {
+		return nil
+	}
+
+	if spec.RNG != nil && spec.RNG.Bytes == 0 {
+		return fmt.Errorf("rng bytes have to be positive")
+	}
+	for i, input := range spec.Inputs {
+		if !slices.Contains(inputTypes, input.Type) {
+			return fmt.Errorf("input %d: unsupported type %q, supported: %v", i, input.Type, inputTypes)
+		}
+		if input.Bus != "" && !slices.Contains(inputBuses, input.Bus) {
+			return fmt.Errorf("input %d: unsupported bus %q, supported: %v", i, input.Bus, inputBuses)
+		}
+	}
+	if spec.Video != "" && !slices.Contains(videoModels, spec.Video) {
+		return fmt.Errorf("unsupported video model %q, supported: %v", spec.Video, videoModels)
+	}
+	return nil
+}
+
+// LoadClassProfiles loads the device profiles per machine class name from a YAML or JSON file.
+func LoadClassProfiles(filename string) (map[string]api.DevicesSpec, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open device class profiles file (%s): %w", filename, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var profiles map[string]api.DevicesSpec
+	if err := yaml.NewYAMLOrJSONDecoder(file, 4096).Decode(&profiles); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal device class profiles: %w", err)
+	}
+
+	for class, spec := range profiles {
+		if err := Validate(&spec); err != nil {
+			return nil, fmt.Errorf("invalid device profile of class %s: %w", class, err)
+		}
+	}
+	return profiles, nil
+}
+
+func rng(bytes, period uint) libvirtxml.DomainRNG {
+	return libvirtxml.DomainRNG{
+		Model: "virtio",
+		Rate: &libvirtxml.DomainRNGRate{
+			Bytes:  bytes,
+			Period: period,
+		},
+		Backend: &libvirtxml.DomainRNGBackend{
+			Random: &libvirtxml.DomainRNGBackendRandom{},
+		},
+	}
+}
+
+// SetDevices adds the devices of the spec to the domain devices. A nil spec adds a virtio RNG passing
+// DefaultRNGBytes per second and leaves the other devices to the libvirt defaults.
+func SetDevices(spec *api.DevicesSpec, devices *libvirtxml.DomainDeviceList) {
+	if spec == nil {
+		devices.RNGs = append(devices.RNGs, rng(DefaultRNGBytes, 0))
+		return
+	}
+
+	if spec.RNG != nil {
+		devices.RNGs = append(devices.RNGs, rng(spec.RNG.Bytes, spec.RNG.PeriodMillis))
+	}
+
+	if spec.MemBalloon {
+		devices.MemBalloon = &libvirtxml.DomainMemBalloon{Model: "virtio"}
+	} else {
+		devices.MemBalloon = &libvirtxml.DomainMemBalloon{Model: "none"}
+	}
+
+	for _, input := range spec.Inputs {
+		bus := input.Bus
+		if bus == "" {
+			bus = "usb"
+		}
+		devices.Inputs = append(devices.Inputs, libvirtxml.DomainInput{
+			Type: input.Type,
+			Bus:  bus,
+		})
+	}
+
+	if spec.Video != "" {
+		devices.Videos = append(devices.Videos, libvirtxml.DomainVideo{
+			Model: libvirtxml.DomainVideoModel{Type: spec.Video},
+		})
+	}
+}
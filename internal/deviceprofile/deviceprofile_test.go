@@ -0,0 +1,95 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package deviceprofile_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	. "github.com/ironcore-dev/libvirt-provider/internal/deviceprofile"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"libvirt.org/go/libvirtxml"
+)
+
+var _ = Describe("DeviceProfile", func() {
+	It("should validate the devices", func() {
+		Expect(Validate(nil)).To(Succeed())
+		Expect(Validate(&api.DevicesSpec{
+			RNG:    &api.RNGSpec{Bytes: 1024},
+			Inputs: []api.InputSpec{{Type: "tablet"}, {Type: "keyboard", Bus: "virtio"}},
+			Video:  "virtio",
+		})).To(Succeed())
+
+		Expect(Validate(&api.DevicesSpec{RNG: &api.RNGSpec{}})).To(HaveOccurred())
+		Expect(Validate(&api.DevicesSpec{Inputs: []api.InputSpec{{Type: "joystick"}}})).To(HaveOccurred())
+		Expect(Validate(&api.DevicesSpec{Inputs: []api.InputSpec{{Type: "tablet", Bus: "ps2"}}})).To(HaveOccurred())
+		Expect(Validate(&api.DevicesSpec{Video: "qxl"})).To(HaveOccurred())
+	})
+
+	It("should load the class profiles", func() {
+		filename := filepath.Join(GinkgoT().TempDir(), "profiles.yaml")
+		Expect(os.WriteFile(filename, []byte(`x3-xlarge:
+  rng:
+    bytes: 1024
+    periodMillis: 500
+  memBalloon: true
+  inputs:
+  - type: tablet
+  video: virtio
+x3-small: {}
+`), 0644)).To(Succeed())
+
+		Expect(LoadClassProfiles(filename)).To(Equal(map[string]api.DevicesSpec{
+			"x3-xlarge": {
+				RNG:        &api.RNGSpec{Bytes: 1024, PeriodMillis: 500},
+				MemBalloon: true,
+				Inputs:     []api.InputSpec{{Type: "tablet"}},
+				Video:      "virtio",
+			},
+			"x3-small": {},
+		}))
+
+		Expect(os.WriteFile(filename, []byte("x3-xlarge:\n  video: qxl\n"), 0644)).To(Succeed())
+		_, err := LoadClassProfiles(filename)
+		Expect(err).To(MatchError(ContainSubstring("invalid device profile of class x3-xlarge")))
+	})
+
+	It("should add the default rng to machines without profile", func() {
+		devices := &libvirtxml.DomainDeviceList{}
+		SetDevices(nil, devices)
+
+		Expect(devices.RNGs).To(ConsistOf(HaveField("Rate.Bytes", uint(DefaultRNGBytes))))
+		Expect(devices.MemBalloon).To(BeNil())
+		Expect(devices.Inputs).To(BeEmpty())
+		Expect(devices.Videos).To(BeEmpty())
+	})
+
+	It("should add the devices of the profile", func() {
+		devices := &libvirtxml.DomainDeviceList{}
+		SetDevices(&api.DevicesSpec{
+			RNG:        &api.RNGSpec{Bytes: 1024, PeriodMillis: 500},
+			MemBalloon: true,
+			Inputs:     []api.InputSpec{{Type: "tablet"}, {Type: "keyboard", Bus: "virtio"}},
+			Video:      "virtio",
+		}, devices)
+
+		Expect(devices.RNGs).To(ConsistOf(HaveField("Rate", Equal(&libvirtxml.DomainRNGRate{Bytes: 1024, Period: 500}))))
+		Expect(devices.MemBalloon).To(Equal(&libvirtxml.DomainMemBalloon{Model: "virtio"}))
+		Expect(devices.Inputs).To(Equal([]libvirtxml.DomainInput{
+			{Type: "tablet", Bus: "usb"},
+			{Type: "keyboard", Bus: "virtio"},
+		}))
+		Expect(devices.Videos).To(ConsistOf(HaveField("Model.Type", "virtio")))
+	})
+
+	It("should disable the memory balloon and rng of an empty profile", func() {
+		devices := &libvirtxml.DomainDeviceList{}
+		SetDevices(&api.DevicesSpec{}, devices)
+
+		Expect(devices.RNGs).To(BeEmpty())
+		Expect(devices.MemBalloon).To(Equal(&libvirtxml.DomainMemBalloon{Model: "none"}))
+	})
+})
@@ -13,7 +13,6 @@ import (
 	"github.com/ironcore-dev/libvirt-provider/api"
 	"github.com/ironcore-dev/libvirt-provider/internal/store"
 	"k8s.io/apimachinery/pkg/util/sets"
-	"k8s.io/apimachinery/pkg/util/wait"
 )
 
 type Handler[e api.Object] interface {
@@ -48,6 +47,8 @@ type Event[E api.Object] struct {
 }
 
 type ListWatchSourceOptions struct {
+	// ResyncDuration is the interval in which all objects are listed and passed to the handlers as generic
+	// events. Objects are listed right away if the watch dropped events.
 	ResyncDuration time.Duration
 }
 
@@ -65,6 +66,7 @@ func NewListWatchSource[E api.Object](listFunc func(ctx context.Context) ([]E, e
 		watchFunc:      watchFunc,
 		handles:        sets.New[*handle[E]](),
 		resyncDuration: opts.ResyncDuration,
+		relist:         make(chan struct{}, 1),
 	}, nil
 }
 
@@ -76,6 +78,8 @@ type ListWatchSource[E api.Object] struct {
 	handles   sets.Set[*handle[E]]
 
 	resyncDuration time.Duration
+	// relist triggers listing the objects before the next resync.
+	relist chan struct{}
 }
 
 func (s *ListWatchSource[E]) Start(ctx context.Context) error {
@@ -107,6 +111,11 @@ func (s *ListWatchSource[E]) Start(ctx context.Context) error {
 					Type:   eventType,
 					Object: evt.Object,
 				})
+			case <-watch.Dropped():
+				select {
+				case s.relist <- struct{}{}:
+				default:
+				}
 			}
 		}
 	}()
@@ -115,25 +124,42 @@ func (s *ListWatchSource[E]) Start(ctx context.Context) error {
 	go func() {
 		defer wg.Done()
 
-		wait.UntilWithContext(ctx, func(ctx context.Context) {
-			objs, err := s.listFunc(ctx)
-			if err != nil {
-				log.Error(err, "failed to list objects")
+		timer := time.NewTimer(0)
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
 				return
+			case <-timer.C:
+			case <-s.relist:
+				log.V(1).Info("Watch dropped events, listing objects")
+				timer.Stop()
 			}
 
-			for _, obj := range objs {
-				s.enqueue(Event[E]{
-					Type:   TypeGeneric,
-					Object: obj,
-				})
-			}
-		}, s.resyncDuration)
+			s.list(ctx, log)
+			timer.Reset(s.resyncDuration)
+		}
 	}()
 
 	return nil
 }
 
+// list passes all objects to the handlers as generic events.
+func (s *ListWatchSource[E]) list(ctx context.Context, log logr.Logger) {
+	objs, err := s.listFunc(ctx)
+	if err != nil {
+		log.Error(err, "failed to list objects")
+		return
+	}
+
+	for _, obj := range objs {
+		s.enqueue(Event[E]{
+			Type:   TypeGeneric,
+			Object: obj,
+		})
+	}
+}
+
 func (s *ListWatchSource[E]) AddHandler(handler Handler[E]) (HandlerRegistration, error) {
 	s.handlesMu.Lock()
 	defer s.handlesMu.Unlock()
@@ -0,0 +1,110 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package hardware pins the QEMU machine type and UEFI firmware of machines, so upgrades of QEMU and its firmware
+// packages don't change the virtual hardware of long-lived guests.
+package hardware
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+var machineTypeRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// Parse parses the value of the api.HardwareAnnotation. An empty value results in a nil spec.
+func Parse(s string) (*api.HardwareSpec, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	spec := &api.HardwareSpec{}
+	if err := json.Unmarshal([]byte(s), spec); err != nil {
+		return nil, fmt.Errorf("error unmarshalling hardware spec: %w", err)
+	}
+	if err := Validate(spec); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+func validatePath(field, path string) error {
+	if !filepath.IsAbs(path) || filepath.Clean(path) != path {
+		return fmt.Errorf("%s %q has to be a clean absolute path", field, path)
+	}
+	return nil
+}
+
+// Validate checks the machine type and firmware paths of the spec. Whether the host supports them is only known
+// when the domain is started.
+func Validate(spec *api.HardwareSpec) error {
+	if spec == nil {
+		return nil
+	}
+
+	if spec.MachineType != "" && !machineTypeRegexp.MatchString(spec.MachineType) {
+		return fmt.Errorf("invalid machine type %q", spec.MachineType)
+	}
+	if spec.Loader != "" {
+		if err := validatePath("loader", spec.Loader); err != nil {
+			return err
+		}
+	}
+	if spec.NVRAMTemplate != "" {
+		if spec.Loader == "" {
+			return fmt.Errorf("nvram template requires a loader")
+		}
+		if err := validatePath("nvram template", spec.NVRAMTemplate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadClassDefaults loads the HardwareSpec defaults per machine class name from a YAML or JSON file.
+func LoadClassDefaults(filename string) (map[string]api.HardwareSpec, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open hardware class defaults file (%s): %w", filename, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var defaults map[string]api.HardwareSpec
+	if err := yaml.NewYAMLOrJSONDecoder(file, 4096).Decode(&defaults); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal hardware class defaults: %w", err)
+	}
+
+	for class, spec := range defaults {
+		if err := Validate(&spec); err != nil {
+			return nil, fmt.Errorf("invalid hardware defaults of class %s: %w", class, err)
+		}
+	}
+	return defaults, nil
+}
+
+// Merge returns the class defaults overridden by the non-empty fields of the machine spec. The firmware is
+// overridden as a whole, as the variable store template has to match the loader.
+func Merge(defaults, spec *api.HardwareSpec) *api.HardwareSpec {
+	if defaults == nil {
+		return spec
+	}
+
+	res := *defaults
+	if spec == nil {
+		return &res
+	}
+	if spec.MachineType != "" {
+		res.MachineType = spec.MachineType
+	}
+	if spec.Loader != "" {
+		res.Loader = spec.Loader
+		res.NVRAMTemplate = spec.NVRAMTemplate
+	}
+	return &res
+}
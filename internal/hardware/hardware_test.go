@@ -0,0 +1,72 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package hardware_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	. "github.com/ironcore-dev/libvirt-provider/internal/hardware"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Hardware", func() {
+	Describe("Parse", func() {
+		It("should return nil for an empty value", func() {
+			Expect(Parse("")).To(BeNil())
+		})
+
+		It("should parse a valid spec", func() {
+			Expect(Parse(`{"machineType":"pc-q35-8.2","loader":"/usr/share/OVMF/OVMF_CODE.fd","nvramTemplate":"/usr/share/OVMF/OVMF_VARS.fd"}`)).To(Equal(&api.HardwareSpec{
+				MachineType:   "pc-q35-8.2",
+				Loader:        "/usr/share/OVMF/OVMF_CODE.fd",
+				NVRAMTemplate: "/usr/share/OVMF/OVMF_VARS.fd",
+			}))
+		})
+
+		It("should reject invalid specs", func() {
+			_, err := Parse(`{"machineType":"pc q35"}`)
+			Expect(err).To(MatchError(ContainSubstring("invalid machine type")))
+			_, err = Parse(`{"loader":"OVMF_CODE.fd"}`)
+			Expect(err).To(MatchError(ContainSubstring("clean absolute path")))
+			_, err = Parse(`{"loader":"/usr/share/OVMF/../OVMF_CODE.fd"}`)
+			Expect(err).To(MatchError(ContainSubstring("clean absolute path")))
+			_, err = Parse(`{"nvramTemplate":"/usr/share/OVMF/OVMF_VARS.fd"}`)
+			Expect(err).To(MatchError(ContainSubstring("requires a loader")))
+			_, err = Parse(`not json`)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	It("should merge machine specs over class defaults", func() {
+		defaults := &api.HardwareSpec{MachineType: "pc-q35-8.2", Loader: "/ovmf/code.fd", NVRAMTemplate: "/ovmf/vars.fd"}
+		Expect(Merge(defaults, nil)).To(Equal(defaults))
+		Expect(Merge(nil, &api.HardwareSpec{MachineType: "pc-q35-9.0"})).To(Equal(&api.HardwareSpec{MachineType: "pc-q35-9.0"}))
+		Expect(Merge(defaults, &api.HardwareSpec{MachineType: "pc-q35-9.0"})).To(Equal(&api.HardwareSpec{
+			MachineType:   "pc-q35-9.0",
+			Loader:        "/ovmf/code.fd",
+			NVRAMTemplate: "/ovmf/vars.fd",
+		}))
+
+		By("overriding the firmware as a whole")
+		Expect(Merge(defaults, &api.HardwareSpec{Loader: "/other/code.fd"})).To(Equal(&api.HardwareSpec{
+			MachineType: "pc-q35-8.2",
+			Loader:      "/other/code.fd",
+		}))
+	})
+
+	It("should load class defaults", func() {
+		filename := filepath.Join(GinkgoT().TempDir(), "hardware.yaml")
+		Expect(os.WriteFile(filename, []byte("x3-xlarge:\n  machineType: pc-q35-8.2\n  loader: /ovmf/code.fd\n"), 0600)).To(Succeed())
+		Expect(LoadClassDefaults(filename)).To(Equal(map[string]api.HardwareSpec{
+			"x3-xlarge": {MachineType: "pc-q35-8.2", Loader: "/ovmf/code.fd"},
+		}))
+
+		Expect(os.WriteFile(filename, []byte("x3-xlarge:\n  loader: ovmf/code.fd\n"), 0600)).To(Succeed())
+		_, err := LoadClassDefaults(filename)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -4,23 +4,141 @@
 package healthcheck
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/digitalocean/go-libvirt"
 	"github.com/go-logr/logr"
 	libvirtutils "github.com/ironcore-dev/libvirt-provider/internal/libvirt/utils"
 )
 
+const (
+	// DefaultProbeTimeout is the time a single probe may take before it is considered failed.
+	DefaultProbeTimeout = 5 * time.Second
+
+	libvirtProbeName = "libvirt"
+)
+
+// Probe checks a single dependency of the provider.
+type Probe struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// Result is the JSON response of the health check.
+type Result struct {
+	Healthy bool `json:"healthy"`
+	// Draining reports that the provider is shutting down and finishing its in-flight work.
+	Draining bool          `json:"draining,omitempty"`
+	Probes   []ProbeResult `json:"probes"`
+}
+
+type ProbeResult struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+}
+
 type HealthCheck struct {
 	Libvirt *libvirt.Libvirt
 	Log     logr.Logger
+
+	// ProbeTimeout defaults to DefaultProbeTimeout.
+	ProbeTimeout time.Duration
+
+	mu sync.RWMutex
+	// probes are checked in addition to the libvirt connection.
+	probes   []Probe
+	draining bool
+}
+
+// AddProbes adds probes to the health check. Probes may be added while the health check is served, as
+// the components they check are set up.
+func (h *HealthCheck) AddProbes(probes ...Probe) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.probes = append(h.probes, probes...)
+}
+
+// SetDraining sets whether the provider is draining. A draining provider isn't reported unhealthy, as it is
+// expected to finish its in-flight work before exiting.
+func (h *HealthCheck) SetDraining(draining bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.draining = draining
 }
 
-func (h HealthCheck) HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
-	if err := libvirtutils.IsConnected(h.Libvirt); err == nil {
+// Check runs the libvirt connection check and all probes. While draining, failing probes are reported but the
+// provider is healthy.
+func (h *HealthCheck) Check(ctx context.Context) Result {
+	h.mu.RLock()
+	probes := append([]Probe{{
+		Name: libvirtProbeName,
+		Check: func(context.Context) error {
+			return libvirtutils.IsConnected(h.Libvirt)
+		},
+	}}, h.probes...)
+	draining := h.draining
+	h.mu.RUnlock()
+
+	timeout := h.ProbeTimeout
+	if timeout == 0 {
+		timeout = DefaultProbeTimeout
+	}
+
+	res := Result{Draining: draining}
+	res.Probes, res.Healthy = runProbes(ctx, h.Log, probes, timeout)
+	res.Healthy = res.Healthy || draining
+	return res
+}
+
+// runProbes runs the probes and reports whether all of them succeeded.
+func runProbes(ctx context.Context, log logr.Logger, probes []Probe, timeout time.Duration) ([]ProbeResult, bool) {
+	var results []ProbeResult
+	ok := true
+	for _, probe := range probes {
+		probeRes := ProbeResult{Name: probe.Name, Healthy: true}
+		if err := runProbe(ctx, probe, timeout); err != nil {
+			log.Error(err, "probe failed", "Probe", probe.Name)
+			probeRes.Healthy = false
+			probeRes.Error = err.Error()
+			ok = false
+		}
+		results = append(results, probeRes)
+	}
+	return results, ok
+}
+
+func runProbe(ctx context.Context, probe Probe, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- probe.Check(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (h *HealthCheck) HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
+	res := h.Check(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	if res.Healthy {
 		w.WriteHeader(http.StatusOK)
 	} else {
-		h.Log.Error(err, "failed to get active connection to libvirtd")
 		w.WriteHeader(http.StatusServiceUnavailable)
 	}
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		h.Log.Error(err, "failed to write health check response")
+	}
 }
@@ -0,0 +1,105 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package healthcheck_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/digitalocean/go-libvirt"
+	"github.com/go-logr/logr"
+	. "github.com/ironcore-dev/libvirt-provider/internal/healthcheck"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HealthCheck", func() {
+	var healthCheck *HealthCheck
+
+	BeforeEach(func() {
+		// Not connected, the libvirt probe always fails.
+		healthCheck = &HealthCheck{
+			Libvirt: libvirt.NewWithDialer(nil),
+			Log:     logr.Discard(),
+		}
+	})
+
+	serve := func() (int, *Result) {
+		rec := httptest.NewRecorder()
+		healthCheck.HealthCheckHandler(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+		res := &Result{}
+		Expect(json.NewDecoder(rec.Body).Decode(res)).To(Succeed())
+		return rec.Code, res
+	}
+
+	It("should report the result of each probe", func() {
+		healthCheck.AddProbes(
+			ReadWriteProbe("store", GinkgoT().TempDir()),
+			DiskSpaceProbe("disk", GinkgoT().TempDir(), 0),
+		)
+
+		code, res := serve()
+		Expect(code).To(Equal(http.StatusServiceUnavailable))
+		Expect(res.Healthy).To(BeFalse())
+		Expect(res.Probes).To(Equal([]ProbeResult{
+			{Name: "libvirt", Error: "no active libvirt connection"},
+			{Name: "store", Healthy: true},
+			{Name: "disk", Healthy: true},
+		}))
+	})
+
+	It("should report each failing probe", func() {
+		healthCheck.AddProbes(
+			Probe{Name: "broken", Check: func(context.Context) error { return errors.New("broken") }},
+			ReadWriteProbe("store", "/does/not/exist"),
+			DiskSpaceProbe("disk", GinkgoT().TempDir(), math.MaxInt64),
+		)
+
+		code, res := serve()
+		Expect(code).To(Equal(http.StatusServiceUnavailable))
+		Expect(res.Healthy).To(BeFalse())
+		Expect(res.Probes).To(HaveLen(4))
+		Expect(res.Probes[1]).To(Equal(ProbeResult{Name: "broken", Error: "broken"}))
+		Expect(res.Probes[2].Healthy).To(BeFalse())
+		Expect(res.Probes[2].Error).To(ContainSubstring("error creating probe file"))
+		Expect(res.Probes[3].Healthy).To(BeFalse())
+		Expect(res.Probes[3].Error).To(ContainSubstring("bytes available"))
+	})
+
+	It("should fail probes exceeding the timeout", func() {
+		healthCheck.ProbeTimeout = 10 * time.Millisecond
+		healthCheck.AddProbes(Probe{
+			Name: "hanging",
+			Check: func(ctx context.Context) error {
+				<-ctx.Done()
+				time.Sleep(time.Second)
+				return nil
+			},
+		})
+
+		res := healthCheck.Check(context.Background())
+		Expect(res.Healthy).To(BeFalse())
+		Expect(res.Probes[1].Error).To(Equal(context.DeadlineExceeded.Error()))
+	})
+
+	It("should report a draining provider", func() {
+		code, res := serve()
+		Expect(code).To(Equal(http.StatusServiceUnavailable))
+		Expect(res.Draining).To(BeFalse())
+
+		By("not reporting it unhealthy")
+		healthCheck.SetDraining(true)
+		code, res = serve()
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(res.Healthy).To(BeTrue())
+		Expect(res.Draining).To(BeTrue())
+		Expect(res.Probes).To(ConsistOf(HaveField("Healthy", false)))
+	})
+})
@@ -0,0 +1,79 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package healthcheck
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Checker is implemented by components able to check their own health, e.g. network interface plugins
+// talking to a remote API.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerProbe returns a probe with the given name backed by the checker.
+func CheckerProbe(name string, checker Checker) Probe {
+	return Probe{Name: name, Check: checker.Check}
+}
+
+// ReadWriteProbe writes a file to dir and reads it back, detecting read-only or broken file systems.
+func ReadWriteProbe(name, dir string) Probe {
+	return Probe{
+		Name: name,
+		Check: func(context.Context) error {
+			f, err := os.CreateTemp(dir, ".healthz-*")
+			if err != nil {
+				return fmt.Errorf("error creating probe file: %w", err)
+			}
+			defer func() { _ = os.Remove(f.Name()) }()
+
+			data := []byte(strconv.FormatInt(time.Now().UnixNano(), 10))
+			if _, err := f.Write(data); err != nil {
+				_ = f.Close()
+				return fmt.Errorf("error writing probe file: %w", err)
+			}
+			if err := f.Close(); err != nil {
+				return fmt.Errorf("error closing probe file: %w", err)
+			}
+
+			read, err := os.ReadFile(filepath.Clean(f.Name()))
+			if err != nil {
+				return fmt.Errorf("error reading probe file: %w", err)
+			}
+			if !bytes.Equal(read, data) {
+				return fmt.Errorf("probe file content mismatch")
+			}
+			return nil
+		},
+	}
+}
+
+// DiskSpaceProbe fails if the file system of dir has less than minFreeBytes available. With minFreeBytes 0,
+// it only fails if the file system is full.
+func DiskSpaceProbe(name, dir string, minFreeBytes int64) Probe {
+	return Probe{
+		Name: name,
+		Check: func(context.Context) error {
+			var stat unix.Statfs_t
+			if err := unix.Statfs(dir, &stat); err != nil {
+				return fmt.Errorf("error getting file system stats: %w", err)
+			}
+
+			free := int64(stat.Bavail) * stat.Bsize
+			if free <= 0 || free < minFreeBytes {
+				return fmt.Errorf("%d bytes available, need at least %d", free, max(minFreeBytes, 1))
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,98 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package healthcheck
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/go-logr/logr"
+)
+
+// ReadinessResult is the JSON response of the readiness check.
+type ReadinessResult struct {
+	Ready   bool     `json:"ready"`
+	Pending []string `json:"pending,omitempty"`
+	// Probes are the results of the dependency probes.
+	Probes []ProbeResult `json:"probes,omitempty"`
+}
+
+// Readiness tracks the startup conditions that have to be met before the provider serves IRI requests, and
+// probes the dependencies of a started provider. Unlike the health check, a failing dependency doesn't get the
+// provider restarted, it only isn't ready until the dependency recovers.
+type Readiness struct {
+	log logr.Logger
+
+	mu         sync.RWMutex
+	conditions []string
+	ready      map[string]bool
+	probes     []Probe
+}
+
+// NewReadiness creates a readiness check that is ready once all conditions are set ready.
+func NewReadiness(log logr.Logger, conditions ...string) *Readiness {
+	return &Readiness{
+		log:        log,
+		conditions: conditions,
+		ready:      make(map[string]bool),
+	}
+}
+
+// SetReady sets the condition ready or, e.g. when shutting down, not ready again.
+func (r *Readiness) SetReady(condition string, ready bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !slices.Contains(r.conditions, condition) {
+		r.log.Info("Ignoring unknown readiness condition", "Condition", condition)
+		return
+	}
+	if r.ready[condition] != ready {
+		r.log.V(1).Info("Readiness condition changed", "Condition", condition, "Ready", ready)
+	}
+	r.ready[condition] = ready
+}
+
+// AddProbes adds probes of dependencies to the readiness check. Probes may be added while the readiness check is
+// served, as the components they check are set up.
+func (r *Readiness) AddProbes(probes ...Probe) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.probes = append(r.probes, probes...)
+}
+
+func (r *Readiness) Check(ctx context.Context) ReadinessResult {
+	r.mu.RLock()
+	res := ReadinessResult{Ready: true}
+	for _, condition := range r.conditions {
+		if !r.ready[condition] {
+			res.Ready = false
+			res.Pending = append(res.Pending, condition)
+		}
+	}
+	probes := slices.Clone(r.probes)
+	r.mu.RUnlock()
+
+	var ok bool
+	res.Probes, ok = runProbes(ctx, r.log, probes, DefaultProbeTimeout)
+	res.Ready = res.Ready && ok
+	return res
+}
+
+func (r *Readiness) ReadinessHandler(w http.ResponseWriter, req *http.Request) {
+	res := r.Check(req.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	if res.Ready {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		r.log.Error(err, "failed to write readiness response")
+	}
+}
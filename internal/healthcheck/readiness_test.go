@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package healthcheck_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/go-logr/logr"
+	. "github.com/ironcore-dev/libvirt-provider/internal/healthcheck"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Readiness", func() {
+	serve := func(readiness *Readiness) (int, *ReadinessResult) {
+		rec := httptest.NewRecorder()
+		readiness.ReadinessHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		res := &ReadinessResult{}
+		Expect(json.NewDecoder(rec.Body).Decode(res)).To(Succeed())
+		return rec.Code, res
+	}
+
+	It("should only be ready once all conditions are ready", func() {
+		readiness := NewReadiness(logr.Discard(), "store", "grpc")
+
+		code, res := serve(readiness)
+		Expect(code).To(Equal(http.StatusServiceUnavailable))
+		Expect(res).To(Equal(&ReadinessResult{Pending: []string{"store", "grpc"}}))
+
+		readiness.SetReady("store", true)
+		readiness.SetReady("unknown", true)
+		code, res = serve(readiness)
+		Expect(code).To(Equal(http.StatusServiceUnavailable))
+		Expect(res).To(Equal(&ReadinessResult{Pending: []string{"grpc"}}))
+
+		readiness.SetReady("grpc", true)
+		code, res = serve(readiness)
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(res).To(Equal(&ReadinessResult{Ready: true}))
+
+		By("setting a condition not ready again")
+		readiness.SetReady("grpc", false)
+		code, res = serve(readiness)
+		Expect(code).To(Equal(http.StatusServiceUnavailable))
+		Expect(res).To(Equal(&ReadinessResult{Pending: []string{"grpc"}}))
+	})
+	It("should not be ready while a dependency probe fails", func() {
+		readiness := NewReadiness(logr.Discard(), "store")
+		readiness.SetReady("store", true)
+
+		var probeErr error
+		readiness.AddProbes(Probe{Name: "apinet", Check: func(context.Context) error { return probeErr }})
+
+		code, res := serve(readiness)
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(res).To(Equal(&ReadinessResult{Ready: true, Probes: []ProbeResult{{Name: "apinet", Healthy: true}}}))
+
+		probeErr = errors.New("apinet unreachable")
+		code, res = serve(readiness)
+		Expect(code).To(Equal(http.StatusServiceUnavailable))
+		Expect(res).To(Equal(&ReadinessResult{Probes: []ProbeResult{{Name: "apinet", Error: "apinet unreachable"}}}))
+	})
+})
@@ -0,0 +1,134 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package host
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ironcore-dev/libvirt-provider/internal/store"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const defaultMaxHistory = 1024
+
+type change struct {
+	revision uint64
+	id       string
+	deleted  bool
+}
+
+// history is a bounded, in-memory log of object changes identified by a monotonically increasing revision.
+// Revisions are prefixed with an epoch so that revisions of a previous process are never mistaken as valid.
+type history struct {
+	mu sync.Mutex
+
+	epoch      int64
+	revision   uint64
+	maxChanges int
+	changes    []change
+}
+
+func newHistory(maxChanges int) *history {
+	if maxChanges <= 0 {
+		maxChanges = defaultMaxHistory
+	}
+	return &history{
+		epoch:      time.Now().UnixNano(),
+		maxChanges: maxChanges,
+	}
+}
+
+func (h *history) record(id string, deleted bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.revision++
+	h.changes = append(h.changes, change{
+		revision: h.revision,
+		id:       id,
+		deleted:  deleted,
+	})
+	if len(h.changes) > h.maxChanges {
+		h.changes = h.changes[len(h.changes)-h.maxChanges:]
+	}
+}
+
+func (h *history) formatRevision(revision uint64) string {
+	return fmt.Sprintf("%d.%d", h.epoch, revision)
+}
+
+func (h *history) parseRevision(s string) (uint64, error) {
+	epochString, revisionString, ok := strings.Cut(s, ".")
+	if !ok {
+		return 0, fmt.Errorf("invalid revision %q", s)
+	}
+
+	epoch, err := strconv.ParseInt(epochString, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid revision epoch %q: %w", epochString, err)
+	}
+	revision, err := strconv.ParseUint(revisionString, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid revision %q: %w", revisionString, err)
+	}
+
+	if epoch != h.epoch {
+		return 0, fmt.Errorf("revision %q is from a different epoch: %w", s, store.ErrRevisionCompacted)
+	}
+	return revision, nil
+}
+
+func (h *history) Revision() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.formatRevision(h.revision)
+}
+
+func (h *history) ChangedSince(revision string) (*store.Changes, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	since, err := h.parseRevision(revision)
+	if err != nil {
+		return nil, err
+	}
+	if since > h.revision {
+		return nil, fmt.Errorf("revision %q is newer than current revision", revision)
+	}
+
+	// The history only covers changes after the revision preceding its oldest entry.
+	if len(h.changes) > 0 && since < h.changes[0].revision-1 {
+		return nil, fmt.Errorf("revision %q: %w", revision, store.ErrRevisionCompacted)
+	}
+	if len(h.changes) == 0 && since < h.revision {
+		return nil, fmt.Errorf("revision %q: %w", revision, store.ErrRevisionCompacted)
+	}
+
+	changed := sets.New[string]()
+	deleted := sets.New[string]()
+	for _, c := range h.changes {
+		if c.revision <= since {
+			continue
+		}
+
+		if c.deleted {
+			changed.Delete(c.id)
+			deleted.Insert(c.id)
+		} else {
+			deleted.Delete(c.id)
+			changed.Insert(c.id)
+		}
+	}
+
+	return &store.Changes{
+		Revision: h.formatRevision(h.revision),
+		Changed:  sets.List(changed),
+		Deleted:  sets.List(deleted),
+	}, nil
+}
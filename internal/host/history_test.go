@@ -0,0 +1,66 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package host_test
+
+import (
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/host"
+	"github.com/ironcore-dev/libvirt-provider/internal/store"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("History", func() {
+	It("should report objects changed since a revision", func(ctx SpecContext) {
+		By("getting the current revision")
+		history := machineStore.(store.History)
+		revision := history.Revision()
+
+		By("creating two machine objects")
+		first, err := machineStore.Create(ctx, &api.Machine{Metadata: api.Metadata{ID: "history-first"}})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = machineStore.Create(ctx, &api.Machine{Metadata: api.Metadata{ID: "history-second"}})
+		Expect(err).NotTo(HaveOccurred())
+
+		By("checking that both objects are reported as changed")
+		changes, err := history.ChangedSince(revision)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changes.Changed).To(ConsistOf("history-first", "history-second"))
+		Expect(changes.Deleted).To(BeEmpty())
+
+		By("deleting the first object")
+		Expect(machineStore.Delete(ctx, first.ID)).To(Succeed())
+
+		By("checking that only the deletion is reported since the last revision")
+		changes, err = history.ChangedSince(changes.Revision)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changes.Changed).To(BeEmpty())
+		Expect(changes.Deleted).To(ConsistOf("history-first"))
+	})
+
+	It("should report compacted revisions", func(ctx SpecContext) {
+		By("creating a store with a small history")
+		smallStore, err := host.NewStore[*api.Machine](host.Options[*api.Machine]{
+			Dir:        GinkgoT().TempDir(),
+			NewFunc:    func() *api.Machine { return &api.Machine{} },
+			MaxHistory: 1,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		revision := smallStore.Revision()
+
+		By("creating more objects than the history can hold")
+		for _, id := range []string{"a", "b", "c"} {
+			_, err := smallStore.Create(ctx, &api.Machine{Metadata: api.Metadata{ID: id}})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		By("checking that the initial revision is compacted")
+		_, err = smallStore.ChangedSince(revision)
+		Expect(err).To(MatchError(store.ErrRevisionCompacted))
+
+		By("checking that revisions from another store epoch are rejected")
+		_, err = smallStore.ChangedSince("1.0")
+		Expect(err).To(MatchError(store.ErrRevisionCompacted))
+	})
+})
@@ -4,6 +4,7 @@
 package host
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -13,12 +14,20 @@ import (
 )
 
 const (
-	DefaultImagesDir  = "images"
-	DefaultPluginsDir = "plugins"
+	DefaultImagesDir    = "images"
+	DefaultPluginsDir   = "plugins"
+	DefaultLeftoversDir = "leftovers"
+
+	DefaultExternalPluginsDir                 = "external"
+	DefaultExternalVolumePluginsDir           = "volume"
+	DefaultExternalNetworkInterfacePluginsDir = "networkinterface"
 
 	DefaultMachinesDir                 = "machines"
 	DefaultStoreDir                    = "store"
 	DefaultMachineStoreDir             = "machines"
+	DefaultMachineStoreDBFile          = "machines.db"
+	DefaultMachineStoreQuarantineDir   = "quarantine"
+	DefaultReconcileQueueFile          = "reconcile-queue.json"
 	DefaultMachineVolumesDir           = "volumes"
 	DefaultMachineIgnitionsDir         = "ignitions"
 	DefaultMachineIgnitionFile         = "data.ign"
@@ -26,6 +35,11 @@ const (
 	DefaultMachineRootFSFile           = "rootfs"
 	DefaultMachinePluginsDir           = "plugins"
 	DefaultMachineNetworkInterfacesDir = "networkinterfaces"
+	// DefaultMachineNetworkInterfacePluginFile records the name of the plugin a network interface was applied with.
+	DefaultMachineNetworkInterfacePluginFile = "plugin"
+	DefaultMachineJournalFile                = "journal.jsonl"
+	DefaultMachineDomainXMLFile              = "domain.xml"
+	DefaultMachineHostEventFile              = "hostevent"
 )
 
 type Paths interface {
@@ -34,8 +48,17 @@ type Paths interface {
 
 	MachinesDir() string
 	MachineStoreDir() string
+	MachineStoreDBFile() string
+	MachineStoreQuarantineDir() string
+	ReconcileQueueFile() string
 	ImagesDir() string
 	PluginsDir() string
+	LeftoversDir() string
+
+	// ExternalVolumePluginsDir is the directory of the unix sockets of external volume plugins.
+	ExternalVolumePluginsDir() string
+	// ExternalNetworkInterfacePluginsDir is the directory of the unix sockets of external network interface plugins.
+	ExternalNetworkInterfacePluginsDir() string
 
 	PluginDir(pluginName string) string
 	MachinePluginsDir(machineUID string) string
@@ -51,9 +74,14 @@ type Paths interface {
 
 	MachineNetworkInterfacesDir(machineUID string) string
 	MachineNetworkInterfaceDir(machineUID string, networkInterfaceName string) string
+	MachineNetworkInterfacePluginFile(machineUID string, networkInterfaceName string) string
 
 	MachineIgnitionsDir(machineUID string) string
 	MachineIgnitionFile(machineUID string) string
+
+	MachineJournalFile(machineUID string) string
+	MachineDomainXMLFile(machineUID string) string
+	MachineHostEventFile(machineUID string) string
 }
 
 type paths struct {
@@ -76,6 +104,18 @@ func (p *paths) MachineStoreDir() string {
 	return filepath.Join(p.StoreDir(), DefaultMachineStoreDir)
 }
 
+func (p *paths) MachineStoreDBFile() string {
+	return filepath.Join(p.StoreDir(), DefaultMachineStoreDBFile)
+}
+
+func (p *paths) MachineStoreQuarantineDir() string {
+	return filepath.Join(p.StoreDir(), DefaultMachineStoreQuarantineDir)
+}
+
+func (p *paths) ReconcileQueueFile() string {
+	return filepath.Join(p.StoreDir(), DefaultReconcileQueueFile)
+}
+
 func (p *paths) ImagesDir() string {
 	return filepath.Join(p.rootDir, DefaultImagesDir)
 }
@@ -84,6 +124,18 @@ func (p *paths) PluginsDir() string {
 	return filepath.Join(p.rootDir, DefaultPluginsDir)
 }
 
+func (p *paths) LeftoversDir() string {
+	return filepath.Join(p.rootDir, DefaultLeftoversDir)
+}
+
+func (p *paths) ExternalVolumePluginsDir() string {
+	return filepath.Join(p.PluginsDir(), DefaultExternalPluginsDir, DefaultExternalVolumePluginsDir)
+}
+
+func (p *paths) ExternalNetworkInterfacePluginsDir() string {
+	return filepath.Join(p.PluginsDir(), DefaultExternalPluginsDir, DefaultExternalNetworkInterfacePluginsDir)
+}
+
 func (p *paths) PluginDir(pluginName string) string {
 	return filepath.Join(p.PluginsDir(), pluginName)
 }
@@ -128,6 +180,10 @@ func (p *paths) MachineNetworkInterfaceDir(machineUID string, networkInterfaceNa
 	return filepath.Join(p.MachineNetworkInterfacesDir(machineUID), networkInterfaceName)
 }
 
+func (p *paths) MachineNetworkInterfacePluginFile(machineUID string, networkInterfaceName string) string {
+	return filepath.Join(p.MachineNetworkInterfaceDir(machineUID, networkInterfaceName), DefaultMachineNetworkInterfacePluginFile)
+}
+
 func (p *paths) MachineIgnitionsDir(machineUID string) string {
 	return filepath.Join(p.MachineDir(machineUID), DefaultMachineIgnitionsDir)
 }
@@ -136,6 +192,18 @@ func (p *paths) MachineIgnitionFile(machineUID string) string {
 	return filepath.Join(p.MachineIgnitionsDir(machineUID), DefaultMachineIgnitionFile)
 }
 
+func (p *paths) MachineJournalFile(machineUID string) string {
+	return filepath.Join(p.MachineDir(machineUID), DefaultMachineJournalFile)
+}
+
+func (p *paths) MachineDomainXMLFile(machineUID string) string {
+	return filepath.Join(p.MachineDir(machineUID), DefaultMachineDomainXMLFile)
+}
+
+func (p *paths) MachineHostEventFile(machineUID string) string {
+	return filepath.Join(p.MachineDir(machineUID), DefaultMachineHostEventFile)
+}
+
 type Host interface {
 	Paths
 	OCIStore() *ocistore.Store
@@ -211,6 +279,9 @@ type MachineVolume struct {
 
 type MachineNetworkInterface struct {
 	NetworkInterfaceName string
+	// PluginName is the name of the plugin the network interface was applied with. It is empty for network
+	// interfaces applied before plugins were recorded, those belong to the default plugin.
+	PluginName string
 }
 
 func ReadMachineUIDs(paths Paths) ([]string, error) {
@@ -274,13 +345,31 @@ func ReadMachineNetworkInterfaces(paths Paths, machineUID string) ([]MachineNetw
 			continue
 		}
 
+		pluginName, err := os.ReadFile(paths.MachineNetworkInterfacePluginFile(machineUID, entry.Name()))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("error reading plugin of network interface %s: %w", entry.Name(), err)
+		}
+
 		machineNics = append(machineNics, MachineNetworkInterface{
 			NetworkInterfaceName: entry.Name(),
+			PluginName:           string(pluginName),
 		})
 	}
 	return machineNics, nil
 }
 
+// WriteMachineNetworkInterfacePlugin records the name of the plugin the network interface is applied with, so the
+// network interface is deleted by the same plugin after it was removed from the machine spec.
+func WriteMachineNetworkInterfacePlugin(paths Paths, machineUID, networkInterfaceName, pluginName string) error {
+	if err := os.MkdirAll(paths.MachineNetworkInterfaceDir(machineUID, networkInterfaceName), perm); err != nil {
+		return fmt.Errorf("error creating network interface directory: %w", err)
+	}
+	if err := os.WriteFile(paths.MachineNetworkInterfacePluginFile(machineUID, networkInterfaceName), []byte(pluginName), 0666); err != nil {
+		return fmt.Errorf("error writing network interface plugin: %w", err)
+	}
+	return nil
+}
+
 func MakeMachineDirs(paths Paths, machineUID string) error {
 	if err := os.MkdirAll(paths.MachineDir(machineUID), perm); err != nil {
 		return fmt.Errorf("error creating machine directory: %w", err)
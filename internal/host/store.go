@@ -4,12 +4,11 @@
 package host
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
-	"reflect"
+	"slices"
 	"sync"
 	"time"
 
@@ -17,17 +16,47 @@ import (
 	"github.com/ironcore-dev/libvirt-provider/internal/store"
 	utilssync "github.com/ironcore-dev/libvirt-provider/internal/sync"
 	"github.com/ironcore-dev/libvirt-provider/internal/utils"
+	"github.com/prometheus/client_golang/prometheus"
 	"k8s.io/apimachinery/pkg/util/json"
 	"k8s.io/apimachinery/pkg/util/sets"
 )
 
 const perm = 0777
 
+// DefaultWatchBufferSize is the number of events buffered per watch if not set in the Options.
+const DefaultWatchBufferSize = 10
+
+var droppedWatchEvents = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "libvirt_provider",
+	Name:      "store_watch_events_dropped_total",
+	Help:      "Number of store watch events dropped because the watch buffer was full.",
+})
+
+func init() {
+	prometheus.MustRegister(droppedWatchEvents)
+}
+
 type Options[E api.Object] struct {
-	//TODO
-	Dir            string
+	// Dir is the directory of the DirBackend used if no Backend is set.
+	Dir string
+	// Backend persists the objects.
+	Backend Backend
+
 	NewFunc        func() E
 	CreateStrategy CreateStrategy[E]
+	// MaxHistory is the number of changes kept to answer ChangedSince queries.
+	MaxHistory int
+
+	// QuarantineDir receives objects failing validation on read. If not set, reading them fails.
+	QuarantineDir string
+	// Recover reconstructs an object moved to quarantine.
+	Recover func(id string) (E, error)
+	// OnCorrupt is called after an object was moved to quarantine.
+	OnCorrupt func(corruption Corruption[E])
+
+	// WatchBufferSize is the number of events buffered per watch. Events exceeding it are dropped. Defaults to
+	// DefaultWatchBufferSize.
+	WatchBufferSize int
 }
 
 func NewStore[E api.Object](opts Options[E]) (*Store[E], error) {
@@ -36,32 +65,66 @@ func NewStore[E api.Object](opts Options[E]) (*Store[E], error) {
 		return nil, fmt.Errorf("must specify opts.NewFunc")
 	}
 
-	if err := os.MkdirAll(opts.Dir, perm); err != nil {
-		return nil, fmt.Errorf("error creating store directory: %w", err)
+	if opts.WatchBufferSize <= 0 {
+		opts.WatchBufferSize = DefaultWatchBufferSize
+	}
+
+	backend := opts.Backend
+	if backend == nil {
+		var err error
+		if backend, err = NewDirBackend(opts.Dir); err != nil {
+			return nil, err
+		}
+	}
+
+	var payloads map[string]payload
+	if _, ok := backend.(*CachingBackend); ok {
+		payloads = make(map[string]payload)
 	}
 
 	return &Store[E]{
-		dir: opts.Dir,
+		backend:  backend,
+		payloads: payloads,
 
 		idMu: utilssync.NewMutexMap[string](),
 
 		newFunc:        opts.NewFunc,
 		createStrategy: opts.CreateStrategy,
 
-		watches: sets.New[*watch[E]](),
+		watches:         sets.New[*watch[E]](),
+		watchBufferSize: opts.WatchBufferSize,
+
+		history: newHistory(opts.MaxHistory),
+
+		quarantineDir: opts.QuarantineDir,
+		recover:       opts.Recover,
+		onCorrupt:     opts.OnCorrupt,
 	}, nil
 }
 
 type Store[E api.Object] struct {
-	dir string
+	backend Backend
+
+	// payloads caches the verified object data of the envelopes served by a CachingBackend, see decodePayload.
+	// Decoded objects aren't cached, callers own the objects they get and modify them, and copying an object
+	// costs about as much as unmarshalling its data. It is nil for other backends.
+	payloadsMu sync.Mutex
+	payloads   map[string]payload
 
 	idMu *utilssync.MutexMap[string]
 
 	newFunc        func() E
 	createStrategy CreateStrategy[E]
 
-	watchesMu sync.RWMutex
-	watches   sets.Set[*watch[E]]
+	watchesMu       sync.RWMutex
+	watches         sets.Set[*watch[E]]
+	watchBufferSize int
+
+	*history
+
+	quarantineDir string
+	recover       func(id string) (E, error)
+	onCorrupt     func(corruption Corruption[E])
 }
 
 type CreateStrategy[E api.Object] interface {
@@ -93,6 +156,7 @@ func (s *Store[E]) Create(_ context.Context, obj E) (E, error) {
 		return utils.Zero[E](), err
 	}
 
+	s.record(obj.GetID(), false)
 	s.enqueue(store.WatchEvent[E]{
 		Type:   store.WatchEventTypeCreated,
 		Object: obj,
@@ -126,6 +190,7 @@ func (s *Store[E]) Update(_ context.Context, obj E) (E, error) {
 		if err := s.delete(obj.GetID()); err != nil {
 			return utils.Zero[E](), fmt.Errorf("failed to delete object metadata: %w", err)
 		}
+		s.record(obj.GetID(), true)
 		return obj, nil
 	}
 
@@ -133,7 +198,13 @@ func (s *Store[E]) Update(_ context.Context, obj E) (E, error) {
 		return utils.Zero[E](), fmt.Errorf("failed to update object: %w", store.ErrResourceVersionNotLatest)
 	}
 
-	if reflect.DeepEqual(oldObj, obj) {
+	// Objects are compared in their persisted form, so nil and empty slices or times differing only in their
+	// monotonic clock reading don't cause writes and watch events.
+	unchanged, err := persistedEqual(oldObj, obj)
+	if err != nil {
+		return utils.Zero[E](), err
+	}
+	if unchanged {
 		return obj, nil
 	}
 
@@ -144,6 +215,7 @@ func (s *Store[E]) Update(_ context.Context, obj E) (E, error) {
 		return utils.Zero[E](), err
 	}
 
+	s.record(obj.GetID(), false)
 	s.enqueue(store.WatchEvent[E]{
 		Type:   store.WatchEventTypeUpdated,
 		Object: obj,
@@ -162,7 +234,11 @@ func (s *Store[E]) Delete(_ context.Context, id string) error {
 	}
 
 	if len(obj.GetFinalizers()) == 0 {
-		return s.delete(id)
+		if err := s.delete(id); err != nil {
+			return err
+		}
+		s.record(id, true)
+		return nil
 	}
 
 	if obj.GetDeletedAt() != nil {
@@ -177,6 +253,7 @@ func (s *Store[E]) Delete(_ context.Context, id string) error {
 		return fmt.Errorf("failed to set object metadata: %w", err)
 	}
 
+	s.record(id, false)
 	s.enqueue(store.WatchEvent[E]{
 		Type:   store.WatchEventTypeDeleted,
 		Object: obj,
@@ -186,19 +263,19 @@ func (s *Store[E]) Delete(_ context.Context, id string) error {
 }
 
 func (s *Store[E]) List(ctx context.Context) ([]E, error) {
-	entries, err := os.ReadDir(s.dir)
+	ids, err := s.backend.IDs()
 	if err != nil {
-		return nil, fmt.Errorf("failed to list objects: %w", err)
+		return nil, err
 	}
 
 	var objs []E
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
-
-		object, err := s.Get(ctx, entry.Name())
+	for _, id := range ids {
+		object, err := s.Get(ctx, id)
 		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				// Deleted or moved to quarantine in the meantime.
+				continue
+			}
 			return nil, fmt.Errorf("failed to read object: %w", err)
 		}
 
@@ -208,15 +285,47 @@ func (s *Store[E]) List(ctx context.Context) ([]E, error) {
 	return objs, nil
 }
 
+// Verify reads all objects of the store and reports the ones that can't be unmarshalled or whose id
+// doesn't match the id they are stored as. Unlike reads, it doesn't move corrupt objects to quarantine.
+func (s *Store[E]) Verify(_ context.Context) ([]store.Problem, error) {
+	ids, err := s.backend.IDs()
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []store.Problem
+	for _, id := range ids {
+		object, err := s.read(id)
+		if err != nil {
+			problems = append(problems, store.Problem{ID: id, Reason: err.Error()})
+			continue
+		}
+		if object.GetID() != id {
+			problems = append(problems, store.Problem{ID: id, Reason: fmt.Sprintf("object has id %q", object.GetID())})
+		}
+	}
+
+	return problems, nil
+}
+
+// IDs returns the sorted ids of all objects without reading them.
+func (s *Store[E]) IDs(_ context.Context) ([]string, error) {
+	ids, err := s.backend.IDs()
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
 func (s *Store[E]) Watch(_ context.Context) (store.Watch[E], error) {
-	//TODO make configurable
-	const bufferSize = 10
 	s.watchesMu.Lock()
 	defer s.watchesMu.Unlock()
 
 	w := &watch[E]{
-		store:  s,
-		events: make(chan store.WatchEvent[E], bufferSize),
+		store:   s,
+		events:  make(chan store.WatchEvent[E], s.watchBufferSize),
+		dropped: make(chan struct{}, 1),
 	}
 
 	s.watches.Insert(w)
@@ -225,42 +334,116 @@ func (s *Store[E]) Watch(_ context.Context) (store.Watch[E], error) {
 }
 
 func (s *Store[E]) get(id string) (E, error) {
-	file, err := os.ReadFile(filepath.Join(s.dir, id))
+	if s.quarantineDir == "" {
+		return s.read(id)
+	}
+
+	data, err := s.backend.Read(id)
 	if err != nil {
-		if !os.IsNotExist(err) {
-			return utils.Zero[E](), fmt.Errorf("failed to read file: %w", err)
-		}
+		return utils.Zero[E](), err
+	}
+
+	obj, err := s.decode(id, data)
+	if err == nil && obj.GetID() != id {
+		err = fmt.Errorf("object has id %q", obj.GetID())
+	}
+	if err != nil {
+		return s.quarantine(id, data, err)
+	}
+	return obj, nil
+}
+
+func (s *Store[E]) read(id string) (E, error) {
+	data, err := s.backend.Read(id)
+	if err != nil {
+		return utils.Zero[E](), err
+	}
+	return s.decode(id, data)
+}
 
-		return utils.Zero[E](), fmt.Errorf("object with id %q %w", id, store.ErrNotFound)
+func (s *Store[E]) decode(id string, data []byte) (E, error) {
+	objData, err := s.decodePayload(id, data)
+	if err != nil {
+		return utils.Zero[E](), fmt.Errorf("failed to decode object %s: %w", id, err)
 	}
 
 	obj := s.newFunc()
-	if err := json.Unmarshal(file, &obj); err != nil {
-		return utils.Zero[E](), fmt.Errorf("failed to unmarshal object from file %s: %w", id, err)
+	if err := json.Unmarshal(objData, &obj); err != nil {
+		return utils.Zero[E](), fmt.Errorf("failed to unmarshal object %s: %w", id, err)
+	}
+	return obj, nil
+}
+
+// payload is the object data of an envelope verified before.
+type payload struct {
+	envelope []byte
+	object   []byte
+}
+
+// decodePayload decodes the envelope of the object and verifies its checksum. A CachingBackend serves the same data
+// until the object is written again, so the verified object data is cached for the data and reused for reads of
+// the unchanged object.
+func (s *Store[E]) decodePayload(id string, data []byte) ([]byte, error) {
+	if s.payloads == nil || len(data) == 0 {
+		return decodeEnvelope(data)
+	}
+
+	s.payloadsMu.Lock()
+	cached, ok := s.payloads[id]
+	s.payloadsMu.Unlock()
+	if ok && &cached.envelope[0] == &data[0] && len(cached.envelope) == len(data) {
+		return cached.object, nil
+	}
+
+	objData, err := decodeEnvelope(data)
+	if err != nil {
+		return nil, err
 	}
 
-	return obj, err
+	s.payloadsMu.Lock()
+	s.payloads[id] = payload{envelope: data, object: objData}
+	s.payloadsMu.Unlock()
+	return objData, nil
+}
+
+// persistedEqual reports whether both objects are persisted identically.
+func persistedEqual[E api.Object](a, b E) (bool, error) {
+	aData, err := json.Marshal(a)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal obj: %w", err)
+	}
+	bData, err := json.Marshal(b)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal obj: %w", err)
+	}
+	return bytes.Equal(aData, bData), nil
 }
 
 func (s *Store[E]) set(obj E) (E, error) {
-	data, err := json.Marshal(obj)
+	objData, err := json.Marshal(obj)
+	if err != nil {
+		return utils.Zero[E](), fmt.Errorf("failed to marshal obj: %w", err)
+	}
+
+	data, err := encodeEnvelope(objData)
 	if err != nil {
 		return utils.Zero[E](), fmt.Errorf("failed to marshal obj: %w", err)
 	}
 
-	if err := os.WriteFile(filepath.Join(s.dir, obj.GetID()), data, 0666); err != nil {
-		return utils.Zero[E](), nil
+	if err := s.backend.Write(obj.GetID(), data); err != nil {
+		return utils.Zero[E](), err
 	}
 
 	return obj, nil
 }
 
 func (s *Store[E]) delete(id string) error {
-	if err := os.Remove(filepath.Join(s.dir, id)); err != nil {
-		return fmt.Errorf("failed to delete object from store: %w", err)
+	if s.payloads != nil {
+		s.payloadsMu.Lock()
+		delete(s.payloads, id)
+		s.payloadsMu.Unlock()
 	}
-
-	return nil
+	return s.backend.Remove(id)
 }
 
 func (s *Store[E]) watchHandlers() []*watch[E] {
@@ -272,9 +455,6 @@ func (s *Store[E]) watchHandlers() []*watch[E] {
 
 func (s *Store[E]) enqueue(evt store.WatchEvent[E]) {
 	for _, handler := range s.watchHandlers() {
-		select {
-		case handler.events <- evt:
-		default:
-		}
+		handler.send(evt)
 	}
 }
@@ -0,0 +1,136 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package host
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ironcore-dev/libvirt-provider/internal/store"
+)
+
+// Backend persists the serialized objects of a Store.
+type Backend interface {
+	// Read returns the data of the object, or an error wrapping store.ErrNotFound.
+	Read(id string) ([]byte, error)
+	// Write atomically replaces the data of the object.
+	Write(id string, data []byte) error
+	// Remove removes the object, or returns an error wrapping store.ErrNotFound.
+	Remove(id string) error
+	// IDs returns the ids of all objects.
+	IDs() ([]string, error)
+}
+
+// dirBackendTempPrefix prefixes the files written before being renamed to their object id. Object ids
+// never start with a dot.
+const dirBackendTempPrefix = "."
+
+// DirBackend stores each object as a file named after its id.
+type DirBackend struct {
+	dir string
+}
+
+func NewDirBackend(dir string) (*DirBackend, error) {
+	if err := os.MkdirAll(dir, perm); err != nil {
+		return nil, fmt.Errorf("error creating store directory: %w", err)
+	}
+	return &DirBackend{dir: dir}, nil
+}
+
+func (b *DirBackend) Read(id string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(b.dir, id))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		return nil, fmt.Errorf("object with id %q %w", id, store.ErrNotFound)
+	}
+	return data, nil
+}
+
+// Write writes the object to a temporary file first, so a crash never leaves a partially written object.
+func (b *DirBackend) Write(id string, data []byte) error {
+	f, err := os.CreateTemp(b.dir, dirBackendTempPrefix+id+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to sync file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close file: %w", err)
+	}
+	if err := os.Chmod(f.Name(), 0666); err != nil {
+		return fmt.Errorf("failed to set file permissions: %w", err)
+	}
+
+	if err := os.Rename(f.Name(), filepath.Join(b.dir, id)); err != nil {
+		return fmt.Errorf("failed to rename file: %w", err)
+	}
+	return nil
+}
+
+func (b *DirBackend) Remove(id string) error {
+	if err := os.Remove(filepath.Join(b.dir, id)); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("object with id %q %w", id, store.ErrNotFound)
+		}
+		return fmt.Errorf("failed to delete object from store: %w", err)
+	}
+	return nil
+}
+
+func (b *DirBackend) IDs() ([]string, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), dirBackendTempPrefix) {
+			continue
+		}
+		ids = append(ids, entry.Name())
+	}
+	return ids, nil
+}
+
+// Migrate copies all objects from one backend to another, empty one and returns the number of copied objects.
+// Objects are copied as they are, so objects that can't be read back are migrated as well.
+func Migrate(from, to Backend) (int, error) {
+	existing, err := to.IDs()
+	if err != nil {
+		return 0, fmt.Errorf("error listing target objects: %w", err)
+	}
+	if len(existing) > 0 {
+		return 0, fmt.Errorf("target store isn't empty, it contains %d objects", len(existing))
+	}
+
+	ids, err := from.IDs()
+	if err != nil {
+		return 0, fmt.Errorf("error listing source objects: %w", err)
+	}
+
+	for i, id := range ids {
+		data, err := from.Read(id)
+		if err != nil {
+			return i, fmt.Errorf("error reading object %s: %w", id, err)
+		}
+		if err := to.Write(id, data); err != nil {
+			return i, fmt.Errorf("error writing object %s: %w", id, err)
+		}
+	}
+	return len(ids), nil
+}
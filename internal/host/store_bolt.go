@@ -0,0 +1,101 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package host
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ironcore-dev/libvirt-provider/internal/store"
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltOpenTimeout is the time to wait for the database lock, which is held by a running provider.
+const boltOpenTimeout = 5 * time.Second
+
+var boltBucket = []byte("objects")
+
+// BoltBackend stores all objects in a bbolt database file. Every write is a transaction, so the database
+// never contains partially written objects.
+type BoltBackend struct {
+	db *bolt.DB
+}
+
+func NewBoltBackend(file string) (*BoltBackend, error) {
+	if err := os.MkdirAll(filepath.Dir(file), perm); err != nil {
+		return nil, fmt.Errorf("error creating store directory: %w", err)
+	}
+
+	db, err := bolt.Open(file, 0666, &bolt.Options{Timeout: boltOpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("error opening store database %s: %w", file, err)
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	}); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("error creating store bucket: %w", err)
+	}
+
+	return &BoltBackend{db: db}, nil
+}
+
+func (b *BoltBackend) Close() error {
+	return b.db.Close()
+}
+
+func (b *BoltBackend) Read(id string) ([]byte, error) {
+	var data []byte
+	if err := b.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(boltBucket).Get([]byte(id))
+		if value == nil {
+			return fmt.Errorf("object with id %q %w", id, store.ErrNotFound)
+		}
+		// Values are only valid within the transaction.
+		data = append([]byte(nil), value...)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (b *BoltBackend) Write(id string, data []byte) error {
+	if err := b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put([]byte(id), data)
+	}); err != nil {
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+	return nil
+}
+
+func (b *BoltBackend) Remove(id string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		if bucket.Get([]byte(id)) == nil {
+			return fmt.Errorf("object with id %q %w", id, store.ErrNotFound)
+		}
+		if err := bucket.Delete([]byte(id)); err != nil {
+			return fmt.Errorf("failed to delete object from store: %w", err)
+		}
+		return nil
+	})
+}
+
+func (b *BoltBackend) IDs() ([]string, error) {
+	var ids []string
+	if err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).ForEach(func(k, _ []byte) error {
+			ids = append(ids, string(k))
+			return nil
+		})
+	}); err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+	return ids, nil
+}
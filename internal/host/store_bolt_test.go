@@ -0,0 +1,90 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package host_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/host"
+	"github.com/ironcore-dev/libvirt-provider/internal/store"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("BoltBackend", func() {
+	var backend *host.BoltBackend
+
+	BeforeEach(func() {
+		var err error
+		backend, err = host.NewBoltBackend(filepath.Join(GinkgoT().TempDir(), "store", "machines.db"))
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(backend.Close)
+	})
+
+	It("should store objects in the database", func(ctx SpecContext) {
+		boltStore, err := host.NewStore[*api.Machine](host.Options[*api.Machine]{
+			Backend: backend,
+			NewFunc: func() *api.Machine { return &api.Machine{} },
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		By("creating a machine")
+		watch, err := boltStore.Watch(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(watch.Stop)
+
+		machine, err := boltStore.Create(ctx, &api.Machine{Metadata: api.Metadata{ID: "foo"}})
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(watch.Events()).Should(Receive(Equal(store.WatchEvent[*api.Machine]{
+			Type:   store.WatchEventTypeCreated,
+			Object: machine,
+		})))
+
+		By("updating the machine")
+		machine.Spec.Power = api.PowerStatePowerOff
+		machine, err = boltStore.Update(ctx, machine)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(boltStore.Get(ctx, "foo")).To(HaveField("Spec.Power", api.PowerStatePowerOff))
+		Expect(boltStore.List(ctx)).To(ConsistOf(HaveField("ResourceVersion", machine.ResourceVersion)))
+
+		By("deleting the machine")
+		Expect(boltStore.Delete(ctx, "foo")).To(Succeed())
+		_, err = boltStore.Get(ctx, "foo")
+		Expect(err).To(MatchError(store.ErrNotFound))
+		Expect(boltStore.List(ctx)).To(BeEmpty())
+	})
+
+	It("should migrate the objects of a directory store", func(ctx SpecContext) {
+		dir := GinkgoT().TempDir()
+		dirStore, err := host.NewStore[*api.Machine](host.Options[*api.Machine]{
+			Dir:     dir,
+			NewFunc: func() *api.Machine { return &api.Machine{} },
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		machine, err := dirStore.Create(ctx, &api.Machine{Metadata: api.Metadata{ID: "foo"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(dir, "corrupt"), []byte("not json"), 0666)).To(Succeed())
+
+		dirBackend, err := host.NewDirBackend(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(host.Migrate(dirBackend, backend)).To(Equal(2))
+
+		By("reading the objects back")
+		boltStore, err := host.NewStore[*api.Machine](host.Options[*api.Machine]{
+			Backend: backend,
+			NewFunc: func() *api.Machine { return &api.Machine{} },
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(boltStore.Get(ctx, "foo")).To(HaveField("ResourceVersion", machine.ResourceVersion))
+		Expect(boltStore.Verify(ctx)).To(ConsistOf(HaveField("ID", "corrupt")))
+
+		By("refusing to migrate into a non-empty store")
+		_, err = host.Migrate(dirBackend, backend)
+		Expect(err).To(MatchError(ContainSubstring("isn't empty")))
+	})
+})
@@ -0,0 +1,110 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package host
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/ironcore-dev/libvirt-provider/internal/store"
+)
+
+// CachingBackend keeps the data of all objects of a backend in memory, indexed by their sorted ids, so reads and
+// listings don't hit the backend. Writes go through to the backend before they are cached, so the backend has to
+// be written exclusively via the CachingBackend.
+type CachingBackend struct {
+	backend Backend
+
+	mu   sync.RWMutex
+	data map[string][]byte
+	ids  []string
+}
+
+// NewCachingBackend loads all objects of the backend into memory.
+func NewCachingBackend(backend Backend) (*CachingBackend, error) {
+	ids, err := backend.IDs()
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string][]byte, len(ids))
+	for _, id := range ids {
+		objData, err := backend.Read(id)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				continue
+			}
+			return nil, fmt.Errorf("error loading object %s: %w", id, err)
+		}
+		data[id] = objData
+	}
+
+	cachedIDs := make([]string, 0, len(data))
+	for id := range data {
+		cachedIDs = append(cachedIDs, id)
+	}
+	slices.Sort(cachedIDs)
+
+	return &CachingBackend{
+		backend: backend,
+		data:    data,
+		ids:     cachedIDs,
+	}, nil
+}
+
+func (b *CachingBackend) Read(id string) ([]byte, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	data, ok := b.data[id]
+	if !ok {
+		return nil, fmt.Errorf("object with id %q %w", id, store.ErrNotFound)
+	}
+	return data, nil
+}
+
+func (b *CachingBackend) Write(id string, data []byte) error {
+	if err := b.backend.Write(id, data); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.data[id]; !ok {
+		idx, _ := slices.BinarySearch(b.ids, id)
+		b.ids = slices.Insert(b.ids, idx, id)
+	}
+	b.data[id] = bytes.Clone(data)
+	return nil
+}
+
+func (b *CachingBackend) Remove(id string) error {
+	err := b.backend.Remove(id)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.data[id]; ok {
+		delete(b.data, id)
+		if idx, found := slices.BinarySearch(b.ids, id); found {
+			b.ids = slices.Delete(b.ids, idx, idx+1)
+		}
+	}
+	return err
+}
+
+// IDs returns the sorted ids of all objects.
+func (b *CachingBackend) IDs() ([]string, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return slices.Clone(b.ids), nil
+}
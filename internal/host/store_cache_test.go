@@ -0,0 +1,121 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package host_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/host"
+	"github.com/ironcore-dev/libvirt-provider/internal/store"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CachingBackend", func() {
+	It("should serve objects from memory and write them through", func(ctx SpecContext) {
+		dir := GinkgoT().TempDir()
+		dirBackend, err := host.NewDirBackend(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(dirBackend.Write("existing", []byte(`{"metadata":{"id":"existing"}}`))).To(Succeed())
+
+		backend, err := host.NewCachingBackend(dirBackend)
+		Expect(err).NotTo(HaveOccurred())
+		cachingStore, err := host.NewStore[*api.Machine](host.Options[*api.Machine]{
+			Backend: backend,
+			NewFunc: func() *api.Machine { return &api.Machine{} },
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		By("creating machines out of order")
+		for _, id := range []string{"c", "a", "b"} {
+			_, err := cachingStore.Create(ctx, &api.Machine{Metadata: api.Metadata{ID: id}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(filepath.Join(dir, id)).To(BeAnExistingFile())
+		}
+		Expect(backend.IDs()).To(Equal([]string{"a", "b", "c", "existing"}))
+
+		By("serving the machines from memory")
+		Expect(os.Remove(filepath.Join(dir, "a"))).To(Succeed())
+		Expect(cachingStore.Get(ctx, "a")).To(HaveField("ID", "a"))
+
+		By("deleting a machine")
+		Expect(cachingStore.Delete(ctx, "b")).To(Succeed())
+		Expect(filepath.Join(dir, "b")).NotTo(BeAnExistingFile())
+		_, err = cachingStore.Get(ctx, "b")
+		Expect(err).To(MatchError(store.ErrNotFound))
+		Expect(cachingStore.List(ctx)).To(HaveLen(3))
+	})
+
+	It("should decode a new object on every read", func(ctx SpecContext) {
+		dirBackend, err := host.NewDirBackend(GinkgoT().TempDir())
+		Expect(err).NotTo(HaveOccurred())
+		backend, err := host.NewCachingBackend(dirBackend)
+		Expect(err).NotTo(HaveOccurred())
+		cachingStore, err := host.NewStore[*api.Machine](host.Options[*api.Machine]{
+			Backend: backend,
+			NewFunc: func() *api.Machine { return &api.Machine{} },
+		})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = cachingStore.Create(ctx, &api.Machine{Metadata: api.Metadata{ID: "a", Labels: map[string]string{"foo": "bar"}}})
+		Expect(err).NotTo(HaveOccurred())
+
+		By("modifying a read machine")
+		machine, err := cachingStore.Get(ctx, "a")
+		Expect(err).NotTo(HaveOccurred())
+		machine.Labels["foo"] = "baz"
+		Expect(cachingStore.Get(ctx, "a")).To(HaveField("Labels", HaveKeyWithValue("foo", "bar")))
+
+		By("updating the machine")
+		_, err = cachingStore.Update(ctx, machine)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cachingStore.Get(ctx, "a")).To(HaveField("Labels", HaveKeyWithValue("foo", "baz")))
+		Expect(cachingStore.IDs(ctx)).To(Equal([]string{"a"}))
+	})
+})
+
+func BenchmarkStoreList(b *testing.B) {
+	const machines = 1000
+
+	for _, cached := range []bool{false, true} {
+		b.Run(fmt.Sprintf("cached=%t", cached), func(b *testing.B) {
+			dirBackend, err := host.NewDirBackend(b.TempDir())
+			if err != nil {
+				b.Fatal(err)
+			}
+			var backend host.Backend = dirBackend
+			if cached {
+				if backend, err = host.NewCachingBackend(dirBackend); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			benchStore, err := host.NewStore[*api.Machine](host.Options[*api.Machine]{
+				Backend: backend,
+				NewFunc: func() *api.Machine { return &api.Machine{} },
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			ctx := context.Background()
+			for i := range machines {
+				if _, err := benchStore.Create(ctx, &api.Machine{Metadata: api.Metadata{ID: fmt.Sprintf("machine-%04d", i)}}); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.ResetTimer()
+			for range b.N {
+				if _, err := benchStore.List(ctx); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package host
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/store"
+	"github.com/ironcore-dev/libvirt-provider/internal/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+
+	corruptObjects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "libvirt_provider",
+		Name:      "store_corrupt_objects_total",
+		Help:      "Number of corrupt store objects moved to quarantine, by whether they could be recovered.",
+	}, []string{"recovered"})
+)
+
+func init() {
+	prometheus.MustRegister(corruptObjects)
+}
+
+// envelope wraps persisted objects with a checksum of their data. Objects persisted before checksums were
+// introduced aren't wrapped and are read without verification.
+type envelope struct {
+	Checksum string          `json:"checksum"`
+	Object   json.RawMessage `json:"object"`
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func encodeEnvelope(objData []byte) ([]byte, error) {
+	return json.Marshal(envelope{
+		Checksum: checksum(objData),
+		Object:   objData,
+	})
+}
+
+// decodeEnvelope returns the object data of the envelope, or the data itself if it isn't wrapped.
+func decodeEnvelope(data []byte) ([]byte, error) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, err
+	}
+	if env.Object == nil {
+		return data, nil
+	}
+
+	if sum := checksum(env.Object); sum != env.Checksum {
+		return nil, fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, env.Checksum, sum)
+	}
+	return env.Object, nil
+}
+
+// Corruption describes a corrupt object that was moved to quarantine.
+type Corruption[E api.Object] struct {
+	ID string
+	// QuarantineFile contains the data of the corrupt object.
+	QuarantineFile string
+	// Err is the reason the object is considered corrupt.
+	Err error
+
+	// Recovered is the recovered object, if RecoverErr is nil.
+	Recovered  E
+	RecoverErr error
+}
+
+// quarantine moves a corrupt object out of the store and tries to recover it. Objects that can't be
+// recovered are reported as not found, so a single corrupt object doesn't fail listing all objects.
+func (s *Store[E]) quarantine(id string, data []byte, cause error) (E, error) {
+	if err := os.MkdirAll(s.quarantineDir, perm); err != nil {
+		return utils.Zero[E](), fmt.Errorf("error creating quarantine directory: %w", err)
+	}
+
+	file := filepath.Join(s.quarantineDir, fmt.Sprintf("%s.%d", id, time.Now().UnixNano()))
+	if err := os.WriteFile(file, data, 0666); err != nil {
+		return utils.Zero[E](), fmt.Errorf("error quarantining corrupt object %s: %w", id, err)
+	}
+	if err := s.delete(id); err != nil {
+		return utils.Zero[E](), fmt.Errorf("error removing corrupt object %s: %w", id, err)
+	}
+
+	corruption := Corruption[E]{
+		ID:             id,
+		QuarantineFile: file,
+		Err:            cause,
+		RecoverErr:     errors.New("no recovery configured"),
+	}
+	if s.recover != nil {
+		corruption.Recovered, corruption.RecoverErr = s.recover(id)
+	}
+	if corruption.RecoverErr == nil {
+		corruption.Recovered.IncrementResourceVersion()
+		if _, err := s.set(corruption.Recovered); err != nil {
+			corruption.RecoverErr = fmt.Errorf("error storing recovered object: %w", err)
+		}
+	}
+
+	recovered := corruption.RecoverErr == nil
+	corruptObjects.WithLabelValues(strconv.FormatBool(recovered)).Inc()
+	if s.onCorrupt != nil {
+		s.onCorrupt(corruption)
+	}
+
+	if !recovered {
+		s.record(id, true)
+		return utils.Zero[E](), fmt.Errorf("corrupt object with id %q %w", id, store.ErrNotFound)
+	}
+
+	s.record(id, false)
+	s.enqueue(store.WatchEvent[E]{
+		Type:   store.WatchEventTypeCreated,
+		Object: corruption.Recovered,
+	})
+	return corruption.Recovered, nil
+}
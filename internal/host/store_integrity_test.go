@@ -0,0 +1,112 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package host_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/host"
+	"github.com/ironcore-dev/libvirt-provider/internal/store"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Store integrity", func() {
+	var (
+		dir           string
+		quarantineDir string
+		recovered     map[string]*api.Machine
+		corruptions   []host.Corruption[*api.Machine]
+		integrity     *host.Store[*api.Machine]
+	)
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		quarantineDir = filepath.Join(GinkgoT().TempDir(), "quarantine")
+		recovered = map[string]*api.Machine{}
+		corruptions = nil
+
+		var err error
+		integrity, err = host.NewStore[*api.Machine](host.Options[*api.Machine]{
+			Dir:           dir,
+			NewFunc:       func() *api.Machine { return &api.Machine{} },
+			QuarantineDir: quarantineDir,
+			Recover: func(id string) (*api.Machine, error) {
+				machine, ok := recovered[id]
+				if !ok {
+					return nil, errors.New("no snapshot")
+				}
+				return machine, nil
+			},
+			OnCorrupt: func(corruption host.Corruption[*api.Machine]) {
+				corruptions = append(corruptions, corruption)
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should read objects persisted without checksum", func(ctx SpecContext) {
+		Expect(os.WriteFile(filepath.Join(dir, "legacy"), []byte(`{"metadata":{"id":"legacy"}}`), 0666)).To(Succeed())
+
+		Expect(integrity.Get(ctx, "legacy")).To(HaveField("ID", "legacy"))
+		Expect(corruptions).To(BeEmpty())
+	})
+
+	It("should quarantine objects failing the checksum and list the remaining ones", func(ctx SpecContext) {
+		_, err := integrity.Create(ctx, &api.Machine{Metadata: api.Metadata{ID: "valid"}})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = integrity.Create(ctx, &api.Machine{Metadata: api.Metadata{ID: "flipped"}})
+		Expect(err).NotTo(HaveOccurred())
+
+		By("modifying the persisted object")
+		data, err := os.ReadFile(filepath.Join(dir, "flipped"))
+		Expect(err).NotTo(HaveOccurred())
+		data = bytes.Replace(data, []byte(`"resourceVersion":1`), []byte(`"resourceVersion":7`), 1)
+		Expect(os.WriteFile(filepath.Join(dir, "flipped"), data, 0666)).To(Succeed())
+
+		By("listing the objects")
+		Expect(integrity.List(ctx)).To(ConsistOf(HaveField("ID", "valid")))
+
+		Expect(corruptions).To(HaveLen(1))
+		Expect(corruptions[0].ID).To(Equal("flipped"))
+		Expect(corruptions[0].Err).To(MatchError(host.ErrChecksumMismatch))
+		Expect(corruptions[0].RecoverErr).To(HaveOccurred())
+		Expect(os.ReadFile(corruptions[0].QuarantineFile)).To(Equal(data))
+
+		_, err = integrity.Get(ctx, "flipped")
+		Expect(err).To(MatchError(store.ErrNotFound))
+	})
+
+	It("should recover quarantined objects", func(ctx SpecContext) {
+		Expect(os.WriteFile(filepath.Join(dir, "broken"), []byte("not json"), 0666)).To(Succeed())
+		recovered["broken"] = &api.Machine{Metadata: api.Metadata{ID: "broken"}}
+
+		watch, err := integrity.Watch(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(watch.Stop)
+
+		machine, err := integrity.Get(ctx, "broken")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(machine.ID).To(Equal("broken"))
+		Eventually(watch.Events()).Should(Receive(HaveField("Type", store.WatchEventTypeCreated)))
+
+		Expect(corruptions).To(ConsistOf(HaveField("RecoverErr", BeNil())))
+
+		By("reading the recovered object back")
+		Expect(integrity.Get(ctx, "broken")).To(HaveField("ResourceVersion", machine.ResourceVersion))
+		Expect(corruptions).To(HaveLen(1))
+	})
+
+	It("should report but not quarantine corrupt objects when verifying", func(ctx SpecContext) {
+		Expect(os.WriteFile(filepath.Join(dir, "broken"), []byte("not json"), 0666)).To(Succeed())
+
+		Expect(integrity.Verify(ctx)).To(ConsistOf(HaveField("ID", "broken")))
+		Expect(corruptions).To(BeEmpty())
+		Expect(filepath.Join(dir, "broken")).To(BeAnExistingFile())
+	})
+})
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 
 	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/host"
 	"github.com/ironcore-dev/libvirt-provider/internal/store"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -44,4 +45,107 @@ var _ = Describe("Store", func() {
 		}
 		Eventually(watch.Events()).Should(Receive(event))
 	})
+
+	It("should not write objects that didn't change", func(ctx SpecContext) {
+		created, err := machineStore.Create(ctx, &api.Machine{Metadata: api.Metadata{ID: "unchanged"}})
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(func(ctx SpecContext) {
+			Expect(machineStore.Delete(ctx, "unchanged")).To(Succeed())
+		})
+
+		watch, err := machineStore.Watch(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(watch.Stop)
+
+		By("updating the object with an equivalent status")
+		machine, err := machineStore.Get(ctx, created.ID)
+		Expect(err).NotTo(HaveOccurred())
+		machine.Status.PCIDevices = []api.PCIDeviceStatus{}
+		machine.Status.Conditions = []api.MachineCondition{}
+		updated, err := machineStore.Update(ctx, machine)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated.ResourceVersion).To(Equal(created.ResourceVersion))
+		Consistently(watch.Events()).ShouldNot(Receive())
+
+		By("updating the status")
+		machine.Status.State = api.MachineStateRunning
+		updated, err = machineStore.Update(ctx, machine)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated.ResourceVersion).To(BeNumerically(">", created.ResourceVersion))
+		Eventually(watch.Events()).Should(Receive(HaveField("Type", store.WatchEventTypeUpdated)))
+	})
+
+	It("should notify watches about dropped events", func(ctx SpecContext) {
+		watchStore, err := host.NewStore[*api.Machine](host.Options[*api.Machine]{
+			Dir:             GinkgoT().TempDir(),
+			NewFunc:         func() *api.Machine { return &api.Machine{} },
+			WatchBufferSize: 1,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		watch, err := watchStore.Watch(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(watch.Stop)
+
+		By("creating more objects than the watch buffers")
+		_, err = watchStore.Create(ctx, &api.Machine{Metadata: api.Metadata{ID: "machine-1"}})
+		Expect(err).NotTo(HaveOccurred())
+		Consistently(watch.Dropped()).ShouldNot(Receive())
+		_, err = watchStore.Create(ctx, &api.Machine{Metadata: api.Metadata{ID: "machine-2"}})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(watch.Dropped()).To(Receive())
+		Expect(watch.Events()).To(Receive(HaveField("Object.ID", "machine-1")))
+		Expect(watch.Events()).NotTo(Receive())
+	})
+
+	It("should report objects that can't be read back", func(ctx SpecContext) {
+		dir := GinkgoT().TempDir()
+		verifyStore, err := host.NewStore[*api.Machine](host.Options[*api.Machine]{
+			Dir:     dir,
+			NewFunc: func() *api.Machine { return &api.Machine{} },
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		By("creating a valid, a corrupt and a misnamed object")
+		_, err = verifyStore.Create(ctx, &api.Machine{Metadata: api.Metadata{ID: "valid"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(dir, "corrupt"), []byte("not json"), 0666)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "misnamed"), []byte(`{"metadata":{"id":"other"}}`), 0666)).To(Succeed())
+
+		By("verifying the store")
+		problems, err := verifyStore.Verify(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(problems).To(ConsistOf(
+			HaveField("ID", "corrupt"),
+			store.Problem{ID: "misnamed", Reason: `object has id "other"`},
+		))
+	})
+	It("should retry updates of outdated objects on the latest version", func(ctx SpecContext) {
+		created, err := machineStore.Create(ctx, &api.Machine{Metadata: api.Metadata{ID: "conflict"}})
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(func(ctx SpecContext) {
+			Expect(machineStore.Delete(ctx, "conflict")).To(Succeed())
+		})
+
+		By("updating the object concurrently")
+		concurrent, err := machineStore.Get(ctx, created.ID)
+		Expect(err).NotTo(HaveOccurred())
+		concurrent.Annotations = map[string]string{"foo": "bar"}
+		_, err = machineStore.Update(ctx, concurrent)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("updating the outdated object")
+		created.Status.State = api.MachineStateRunning
+		_, err = machineStore.Update(ctx, created)
+		Expect(store.IsConflict(err)).To(BeTrue())
+
+		updated, err := store.RetryOnConflict(ctx, machineStore, created, func(latest *api.Machine) error {
+			latest.Status.State = api.MachineStateRunning
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated.Annotations).To(HaveKeyWithValue("foo", "bar"))
+		Expect(updated.Status.State).To(Equal(api.MachineStateRunning))
+	})
 })
@@ -9,8 +9,9 @@ import (
 )
 
 type watch[E api.Object] struct {
-	store  *Store[E]
-	events chan store.WatchEvent[E]
+	store   *Store[E]
+	events  chan store.WatchEvent[E]
+	dropped chan struct{}
 }
 
 func (w *watch[E]) Stop() {
@@ -23,3 +24,23 @@ func (w *watch[E]) Stop() {
 func (w *watch[E]) Events() <-chan store.WatchEvent[E] {
 	return w.events
 }
+
+func (w *watch[E]) Dropped() <-chan struct{} {
+	return w.dropped
+}
+
+// send sends the event to the watch without blocking. If the buffer of the watch is full, the event is dropped
+// and the consumer is notified via Dropped.
+func (w *watch[E]) send(evt store.WatchEvent[E]) {
+	select {
+	case w.events <- evt:
+		return
+	default:
+	}
+
+	droppedWatchEvents.Inc()
+	select {
+	case w.dropped <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,287 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package hostevent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/digitalocean/go-libvirt"
+	"github.com/go-logr/logr"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	machineevent "github.com/ironcore-dev/libvirt-provider/internal/event/machineevent"
+	"github.com/ironcore-dev/libvirt-provider/internal/host"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/call"
+	libvirtutils "github.com/ironcore-dev/libvirt-provider/internal/libvirt/utils"
+	"github.com/ironcore-dev/libvirt-provider/internal/store"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	DefaultPollInterval    = 10 * time.Second
+	DefaultThrottlePercent = 50
+
+	vcpuPeriod = 100000
+	// vcpuQuotaUnlimited lifts any bandwidth limit of the vcpus.
+	vcpuQuotaUnlimited = -1
+
+	filePerm = 0600
+)
+
+type Options struct {
+	Source Source
+
+	PollInterval time.Duration
+	// DefaultPolicy applies to machines that don't specify a HostEventPolicy.
+	DefaultPolicy api.HostEventPolicy
+	// ThrottlePercent is the share of cpu bandwidth left to throttled machines.
+	ThrottlePercent int
+
+	// Conn returns the libvirt connection to suspend, resume and throttle domains with and the function aborting
+	// its calls on timeout, e.g. MachineReconciler.MutatingConn. Defaults to the connection of the Manager,
+	// whose calls aren't aborted.
+	Conn func() (*libvirt.Libvirt, func())
+	// CallTimeout bounds the duration of the libvirt calls. Zero means no limit.
+	CallTimeout time.Duration
+}
+
+func setOptionsDefaults(o *Options) {
+	if o.PollInterval <= 0 {
+		o.PollInterval = DefaultPollInterval
+	}
+	if o.DefaultPolicy == "" {
+		o.DefaultPolicy = api.HostEventPolicyNone
+	}
+	if o.ThrottlePercent <= 0 || o.ThrottlePercent > 100 {
+		o.ThrottlePercent = DefaultThrottlePercent
+	}
+}
+
+// Manager pauses or throttles machines according to their HostEventPolicy while the host reports a
+// thermal or power condition and reverts the action once the condition clears.
+// The applied action is persisted in the machine directory, so it is reverted after a restart as well.
+type Manager struct {
+	log         logr.Logger
+	conn        func() (*libvirt.Libvirt, func())
+	callTimeout time.Duration
+	machines    store.Store[*api.Machine]
+	paths       host.Paths
+	machineevent.EventRecorder
+
+	source          Source
+	pollInterval    time.Duration
+	defaultPolicy   api.HostEventPolicy
+	throttlePercent int
+
+	condition Condition
+}
+
+func NewManager(
+	log logr.Logger,
+	conn *libvirt.Libvirt,
+	machines store.Store[*api.Machine],
+	paths host.Paths,
+	eventRecorder machineevent.EventRecorder,
+	opts Options,
+) (*Manager, error) {
+	if opts.Source == nil {
+		return nil, fmt.Errorf("must specify source")
+	}
+	setOptionsDefaults(&opts)
+	if opts.Conn == nil {
+		opts.Conn = func() (*libvirt.Libvirt, func()) {
+			return conn, nil
+		}
+	}
+
+	return &Manager{
+		log:             log,
+		conn:            opts.Conn,
+		callTimeout:     opts.CallTimeout,
+		machines:        machines,
+		paths:           paths,
+		EventRecorder:   eventRecorder,
+		source:          opts.Source,
+		pollInterval:    opts.PollInterval,
+		defaultPolicy:   opts.DefaultPolicy,
+		throttlePercent: opts.ThrottlePercent,
+		condition:       ConditionNormal,
+	}, nil
+}
+
+func (m *Manager) Start(ctx context.Context) {
+	wait.UntilWithContext(ctx, m.sync, m.pollInterval)
+}
+
+func (m *Manager) sync(ctx context.Context) {
+	condition, err := m.source.Condition(ctx)
+	if err != nil {
+		m.log.Error(err, "failed to get host condition")
+		return
+	}
+
+	if condition != m.condition {
+		m.log.Info("Host condition changed", "From", m.condition, "To", condition)
+		m.condition = condition
+	}
+
+	machines, err := m.machines.List(ctx)
+	if err != nil {
+		m.log.Error(err, "failed to list machines")
+		return
+	}
+
+	for _, machine := range machines {
+		log := m.log.WithValues("machineID", machine.ID)
+		if err := m.syncMachine(ctx, log, machine, condition); err != nil {
+			log.Error(err, "failed to apply host condition to machine")
+		}
+	}
+}
+
+func (m *Manager) syncMachine(ctx context.Context, log logr.Logger, machine *api.Machine, condition Condition) error {
+	applied, err := m.appliedPolicy(machine.ID)
+	if err != nil {
+		return err
+	}
+
+	if condition == ConditionNormal || machine.DeletedAt != nil {
+		if applied == "" {
+			return nil
+		}
+
+		log.V(1).Info("Reverting host event action", "Policy", applied)
+		if err := m.revert(ctx, machine.ID, applied); err != nil {
+			m.Eventf(log, machine.Metadata, corev1.EventTypeWarning, "HostEventResumeFailed", "Failed to revert %s: %s", strings.ToLower(string(applied)), err)
+			return err
+		}
+		if err := os.Remove(m.paths.MachineHostEventFile(machine.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error removing host event file: %w", err)
+		}
+		m.Eventf(log, machine.Metadata, corev1.EventTypeNormal, "HostEventResumed", "Host condition cleared, reverted %s", strings.ToLower(string(applied)))
+		return nil
+	}
+
+	policy := machine.Spec.HostEventPolicy
+	if policy == "" {
+		policy = m.defaultPolicy
+	}
+	if applied != "" || policy == api.HostEventPolicyNone || machine.Spec.Power != api.PowerStatePowerOn {
+		return nil
+	}
+
+	// The action is persisted before it is applied, so it is reverted even if the provider stops in between.
+	// Machines whose directory is gone are being removed and aren't acted on anymore.
+	if err := os.WriteFile(m.paths.MachineHostEventFile(machine.ID), []byte(policy), filePerm); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("error writing host event file: %w", err)
+	}
+
+	log.V(1).Info("Applying host event action", "Condition", condition, "Policy", policy)
+	if err := m.apply(ctx, machine.ID, policy); err != nil {
+		if rmErr := os.Remove(m.paths.MachineHostEventFile(machine.ID)); rmErr != nil && !errors.Is(rmErr, os.ErrNotExist) {
+			log.Error(rmErr, "failed to remove host event file")
+		}
+		if libvirt.IsNotFound(err) {
+			return nil
+		}
+		m.Eventf(log, machine.Metadata, corev1.EventTypeWarning, "HostEventActionFailed", "Failed to %s on host %s condition: %s", strings.ToLower(string(policy)), strings.ToLower(string(condition)), err)
+		return err
+	}
+	switch policy {
+	case api.HostEventPolicyPause:
+		m.Eventf(log, machine.Metadata, corev1.EventTypeWarning, "HostEventPaused", "Paused due to host %s condition", strings.ToLower(string(condition)))
+	case api.HostEventPolicyThrottle:
+		m.Eventf(log, machine.Metadata, corev1.EventTypeWarning, "HostEventThrottled", "Throttled to %d%% cpu due to host %s condition", m.throttlePercent, strings.ToLower(string(condition)))
+	}
+	return nil
+}
+
+func (m *Manager) appliedPolicy(machineID string) (api.HostEventPolicy, error) {
+	return readAppliedPolicy(m.paths, machineID)
+}
+
+// PausedByHostEvent reports whether the machine is paused by the Manager due to a host condition.
+func PausedByHostEvent(paths host.Paths, machineID string) (bool, error) {
+	applied, err := readAppliedPolicy(paths, machineID)
+	if err != nil {
+		return false, err
+	}
+	return applied == api.HostEventPolicyPause, nil
+}
+
+func readAppliedPolicy(paths host.Paths, machineID string) (api.HostEventPolicy, error) {
+	data, err := os.ReadFile(paths.MachineHostEventFile(machineID))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", fmt.Errorf("error reading host event file: %w", err)
+	}
+	return ParsePolicy(string(data))
+}
+
+func (m *Manager) apply(ctx context.Context, machineID string, policy api.HostEventPolicy) error {
+	domain := machineDomain(machineID)
+	switch policy {
+	case api.HostEventPolicyPause:
+		return m.mutate(ctx, func(conn *libvirt.Libvirt) error {
+			return conn.DomainSuspend(domain)
+		})
+	case api.HostEventPolicyThrottle:
+		return m.setVCPUQuota(ctx, domain, int64(vcpuPeriod*m.throttlePercent/100))
+	default:
+		return fmt.Errorf("unsupported host event policy %q", policy)
+	}
+}
+
+func (m *Manager) revert(ctx context.Context, machineID string, policy api.HostEventPolicy) error {
+	domain := machineDomain(machineID)
+
+	var err error
+	switch policy {
+	case api.HostEventPolicyPause:
+		err = m.mutate(ctx, func(conn *libvirt.Libvirt) error {
+			return conn.DomainResume(domain)
+		})
+	case api.HostEventPolicyThrottle:
+		err = m.setVCPUQuota(ctx, domain, vcpuQuotaUnlimited)
+	default:
+		return fmt.Errorf("unsupported host event policy %q", policy)
+	}
+	if libvirt.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (m *Manager) setVCPUQuota(ctx context.Context, domain libvirt.Domain, quota int64) error {
+	return m.mutate(ctx, func(conn *libvirt.Libvirt) error {
+		return conn.DomainSetSchedulerParametersFlags(domain, []libvirt.TypedParam{
+			{Field: "vcpu_period", Value: *libvirt.NewTypedParamValueUllong(vcpuPeriod)},
+			{Field: "vcpu_quota", Value: *libvirt.NewTypedParamValueLlong(quota)},
+		}, uint32(libvirt.DomainAffectLive))
+	})
+}
+
+// mutate runs the mutating libvirt call f on the connection of the Manager, bounded by the call timeout.
+func (m *Manager) mutate(ctx context.Context, f func(conn *libvirt.Libvirt) error) error {
+	conn, abort := m.conn()
+	return call.Mutate(ctx, m.callTimeout, abort, func() error {
+		return f(conn)
+	})
+}
+
+func machineDomain(machineID string) libvirt.Domain {
+	return libvirt.Domain{
+		UUID: libvirtutils.UUIDStringToBytes(machineID),
+	}
+}
@@ -0,0 +1,97 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package hostevent_test
+
+import (
+	"context"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/digitalocean/go-libvirt"
+	"github.com/go-logr/logr"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/host"
+	"github.com/ironcore-dev/libvirt-provider/internal/hostevent"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PausedByHostEvent", func() {
+	var paths host.Paths
+
+	BeforeEach(func() {
+		var err error
+		paths, err = host.PathsAt(GinkgoT().TempDir())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.MkdirAll(paths.MachineDir("foo"), 0700)).To(Succeed())
+	})
+
+	It("should report machines without applied host event action as not paused", func() {
+		Expect(hostevent.PausedByHostEvent(paths, "foo")).To(BeFalse())
+	})
+
+	It("should report machines paused due to a host condition", func() {
+		Expect(os.WriteFile(paths.MachineHostEventFile("foo"), []byte("Pause"), 0600)).To(Succeed())
+		Expect(hostevent.PausedByHostEvent(paths, "foo")).To(BeTrue())
+	})
+
+	It("should report throttled machines as not paused", func() {
+		Expect(os.WriteFile(paths.MachineHostEventFile("foo"), []byte("Throttle"), 0600)).To(Succeed())
+		Expect(hostevent.PausedByHostEvent(paths, "foo")).To(BeFalse())
+	})
+})
+
+type conditionSource struct {
+	condition hostevent.Condition
+	calls     atomic.Int32
+}
+
+func (s *conditionSource) Condition(context.Context) (hostevent.Condition, error) {
+	s.calls.Add(1)
+	return s.condition, nil
+}
+
+type nopEventRecorder struct{}
+
+func (nopEventRecorder) Eventf(logr.Logger, api.Metadata, string, string, string, ...any) {}
+
+var _ = Describe("Manager", func() {
+	It("should not act on machines whose directory is gone", func(ctx SpecContext) {
+		paths, err := host.PathsAt(GinkgoT().TempDir())
+		Expect(err).NotTo(HaveOccurred())
+
+		machines, err := host.NewStore[*api.Machine](host.Options[*api.Machine]{
+			Dir:     GinkgoT().TempDir(),
+			NewFunc: func() *api.Machine { return &api.Machine{} },
+		})
+		Expect(err).NotTo(HaveOccurred())
+		machine, err := machines.Create(ctx, &api.Machine{
+			Metadata: api.Metadata{ID: "foo"},
+			Spec:     api.MachineSpec{Power: api.PowerStatePowerOn, HostEventPolicy: api.HostEventPolicyPause},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		var conns atomic.Int32
+		source := &conditionSource{condition: hostevent.ConditionThermal}
+		manager, err := hostevent.NewManager(GinkgoLogr, nil, machines, paths, nopEventRecorder{}, hostevent.Options{
+			Source:       source,
+			PollInterval: time.Millisecond,
+			Conn: func() (*libvirt.Libvirt, func()) {
+				conns.Add(1)
+				return nil, nil
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		startCtx, cancel := context.WithCancel(ctx)
+		DeferCleanup(cancel)
+		go manager.Start(startCtx)
+
+		By("waiting for the machine to be synced at least once")
+		Eventually(source.calls.Load).Should(BeNumerically(">", 2))
+		Expect(conns.Load()).To(BeZero())
+		Expect(hostevent.PausedByHostEvent(paths, machine.ID)).To(BeFalse())
+	})
+})
@@ -0,0 +1,80 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package hostevent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+)
+
+// Condition is the power / thermal condition of the host.
+type Condition string
+
+const (
+	ConditionNormal  Condition = "Normal"
+	ConditionThermal Condition = "Thermal"
+	ConditionPower   Condition = "Power"
+)
+
+// Source reports the current host condition. Implementations may be backed by files, DBus or any
+// other signal the host exposes.
+type Source interface {
+	Condition(ctx context.Context) (Condition, error)
+}
+
+// FileSource reads the host condition from a file, typically written by a host agent or a udev / systemd hook.
+// The file holds one of the condition names (case-insensitive). A missing or empty file is reported as
+// ConditionNormal.
+type FileSource struct {
+	Path string
+}
+
+func NewFileSource(path string) *FileSource {
+	return &FileSource{Path: path}
+}
+
+func (s *FileSource) Condition(context.Context) (Condition, error) {
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ConditionNormal, nil
+		}
+		return "", fmt.Errorf("error reading host condition file: %w", err)
+	}
+
+	return ParseCondition(string(data))
+}
+
+func ParseCondition(s string) (Condition, error) {
+	s = strings.TrimSpace(s)
+	for _, condition := range []Condition{ConditionNormal, ConditionThermal, ConditionPower} {
+		if strings.EqualFold(s, string(condition)) {
+			return condition, nil
+		}
+	}
+	if s == "" {
+		return ConditionNormal, nil
+	}
+	return "", fmt.Errorf("unknown host condition %q", s)
+}
+
+// ParsePolicy parses a host event policy. An empty value yields an empty policy, meaning the default policy applies.
+func ParsePolicy(s string) (api.HostEventPolicy, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", nil
+	}
+
+	for _, policy := range []api.HostEventPolicy{api.HostEventPolicyNone, api.HostEventPolicyPause, api.HostEventPolicyThrottle} {
+		if strings.EqualFold(s, string(policy)) {
+			return policy, nil
+		}
+	}
+	return "", fmt.Errorf("unknown host event policy %q", s)
+}
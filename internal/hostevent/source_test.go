@@ -0,0 +1,57 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package hostevent_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/hostevent"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FileSource", func() {
+	var (
+		path   string
+		source *hostevent.FileSource
+	)
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "condition")
+		source = hostevent.NewFileSource(path)
+	})
+
+	It("should report a normal condition if the file is missing", func(ctx SpecContext) {
+		Expect(source.Condition(ctx)).To(Equal(hostevent.ConditionNormal))
+	})
+
+	It("should report the condition written to the file", func(ctx SpecContext) {
+		Expect(os.WriteFile(path, []byte("thermal\n"), 0600)).To(Succeed())
+		Expect(source.Condition(ctx)).To(Equal(hostevent.ConditionThermal))
+	})
+
+	It("should fail on an unknown condition", func(ctx SpecContext) {
+		Expect(os.WriteFile(path, []byte("meltdown"), 0600)).To(Succeed())
+		_, err := source.Condition(ctx)
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("ParsePolicy", func() {
+	It("should parse policies case-insensitively", func() {
+		Expect(hostevent.ParsePolicy("pause")).To(Equal(api.HostEventPolicyPause))
+		Expect(hostevent.ParsePolicy("Throttle")).To(Equal(api.HostEventPolicyThrottle))
+	})
+
+	It("should return an empty policy for an empty value", func() {
+		Expect(hostevent.ParsePolicy("")).To(BeEmpty())
+	})
+
+	It("should fail on an unknown policy", func() {
+		_, err := hostevent.ParsePolicy("hibernate")
+		Expect(err).To(HaveOccurred())
+	})
+})
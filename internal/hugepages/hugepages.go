@@ -0,0 +1,133 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package hugepages reads the hugepage pools of the host per page size and NUMA node.
+package hugepages
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	Size2Mi int64 = 2 << 20
+	Size1Gi int64 = 1 << 30
+
+	DefaultSysfsRoot = "/sys"
+)
+
+var supportedSizes = []int64{Size2Mi, Size1Gi}
+
+// ParseSize parses a supported page size like 2Mi or 1Gi into bytes.
+func ParseSize(s string) (int64, error) {
+	quantity, err := resource.ParseQuantity(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid hugepage size %q: %w", s, err)
+	}
+
+	size := quantity.Value()
+	if !slices.Contains(supportedSizes, size) {
+		return 0, fmt.Errorf("unsupported hugepage size %q, supported: %v", s, []string{"2Mi", "1Gi"})
+	}
+	return size, nil
+}
+
+// Pages returns the number of pages of the given size backing the memory.
+func Pages(memoryBytes, size int64) (uint64, error) {
+	if size <= 0 || memoryBytes%size != 0 {
+		return 0, fmt.Errorf("memory of %d bytes is not a multiple of the hugepage size %d", memoryBytes, size)
+	}
+	return uint64(memoryBytes / size), nil
+}
+
+// Manager reads the hugepage pools from sysfs.
+type Manager struct {
+	sysfsRoot string
+}
+
+// NewManager creates a Manager reading from the given sysfs root. If empty, DefaultSysfsRoot is used.
+func NewManager(sysfsRoot string) *Manager {
+	if sysfsRoot == "" {
+		sysfsRoot = DefaultSysfsRoot
+	}
+	return &Manager{sysfsRoot: sysfsRoot}
+}
+
+func poolDir(size int64) string {
+	return fmt.Sprintf("hugepages-%dkB", size>>10)
+}
+
+func readCount(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// readPoolCount reads a count of a hugepage pool. Pools of page sizes the kernel doesn't provide have no pages.
+func readPoolCount(path string) (uint64, error) {
+	count, err := readCount(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	return count, err
+}
+
+// Nodes returns the NUMA nodes of the host in ascending order.
+func (m *Manager) Nodes() ([]int, error) {
+	entries, err := os.ReadDir(filepath.Join(m.sysfsRoot, "devices", "system", "node"))
+	if err != nil {
+		return nil, fmt.Errorf("error reading numa nodes: %w", err)
+	}
+
+	var nodes []int
+	for _, entry := range entries {
+		id, ok := strings.CutPrefix(entry.Name(), "node")
+		if !ok {
+			continue
+		}
+		node, err := strconv.Atoi(id)
+		if err != nil {
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	slices.Sort(nodes)
+	return nodes, nil
+}
+
+// NodeCPUs returns the cpu list of the NUMA node, e.g. 0-7,16-23.
+func (m *Manager) NodeCPUs(node int) (string, error) {
+	data, err := os.ReadFile(filepath.Join(m.sysfsRoot, "devices", "system", "node", fmt.Sprintf("node%d", node), "cpulist"))
+	if err != nil {
+		return "", fmt.Errorf("error reading cpus of numa node %d: %w", node, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// FreePages returns the number of free pages of the given size on the NUMA node.
+func (m *Manager) FreePages(node int, size int64) (uint64, error) {
+	path := filepath.Join(m.sysfsRoot, "devices", "system", "node", fmt.Sprintf("node%d", node), "hugepages", poolDir(size), "free_hugepages")
+	free, err := readPoolCount(path)
+	if err != nil {
+		return 0, fmt.Errorf("error reading free hugepages of numa node %d: %w", node, err)
+	}
+	return free, nil
+}
+
+// TotalBytes returns the size of the pool of pages of the given size of the host.
+func (m *Manager) TotalBytes(size int64) (int64, error) {
+	total, err := readPoolCount(filepath.Join(m.sysfsRoot, "kernel", "mm", "hugepages", poolDir(size), "nr_hugepages"))
+	if err != nil {
+		return 0, fmt.Errorf("error reading hugepages: %w", err)
+	}
+	return int64(total) * size, nil
+}
@@ -0,0 +1,77 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package hugepages_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	. "github.com/ironcore-dev/libvirt-provider/internal/hugepages"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func writeCount(path string, count int) {
+	Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+	Expect(os.WriteFile(path, []byte(fmt.Sprintf("%d\n", count)), 0644)).To(Succeed())
+}
+
+var _ = Describe("Hugepages", func() {
+	It("should parse supported sizes", func() {
+		Expect(ParseSize("2Mi")).To(Equal(Size2Mi))
+		Expect(ParseSize("1Gi")).To(Equal(Size1Gi))
+		_, err := ParseSize("4Ki")
+		Expect(err).To(MatchError(ContainSubstring("unsupported hugepage size")))
+		_, err = ParseSize("foo")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should compute the number of pages", func() {
+		Expect(Pages(4*Size1Gi, Size1Gi)).To(Equal(uint64(4)))
+		Expect(Pages(Size1Gi, Size2Mi)).To(Equal(uint64(512)))
+		_, err := Pages(3*Size2Mi, Size1Gi)
+		Expect(err).To(HaveOccurred())
+	})
+
+	Describe("Manager", func() {
+		var (
+			root    string
+			manager *Manager
+		)
+
+		BeforeEach(func() {
+			root = GinkgoT().TempDir()
+			manager = NewManager(root)
+
+			writeCount(filepath.Join(root, "kernel", "mm", "hugepages", "hugepages-1048576kB", "nr_hugepages"), 8)
+			writeCount(filepath.Join(root, "devices", "system", "node", "node0", "hugepages", "hugepages-1048576kB", "free_hugepages"), 2)
+			writeCount(filepath.Join(root, "devices", "system", "node", "node1", "hugepages", "hugepages-1048576kB", "free_hugepages"), 5)
+			Expect(os.MkdirAll(filepath.Join(root, "devices", "system", "node", "possible"), 0755)).To(Succeed())
+		})
+
+		It("should list the numa nodes", func() {
+			Expect(manager.Nodes()).To(Equal([]int{0, 1}))
+		})
+
+		It("should report the pool size", func() {
+			Expect(manager.TotalBytes(Size1Gi)).To(Equal(8 * Size1Gi))
+			By("reporting an empty pool for a page size the kernel doesn't provide")
+			Expect(manager.TotalBytes(Size2Mi)).To(BeZero())
+		})
+
+		It("should report the free pages of a numa node", func() {
+			Expect(manager.FreePages(1, Size1Gi)).To(Equal(uint64(5)))
+			By("reporting no free pages for a page size the kernel doesn't provide")
+			Expect(manager.FreePages(1, Size2Mi)).To(BeZero())
+		})
+
+		It("should report the cpus of a numa node", func() {
+			Expect(os.WriteFile(filepath.Join(root, "devices", "system", "node", "node1", "cpulist"), []byte("8-15,24-31\n"), 0644)).To(Succeed())
+			Expect(manager.NodeCPUs(1)).To(Equal("8-15,24-31"))
+			_, err := manager.NodeCPUs(0)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,188 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package iricompat detects IRI request fields unknown to the provider, which would otherwise be dropped silently
+// when a newer IRI client talks to an older provider, e.g. during rolling upgrades.
+package iricompat
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/protoc-gen-gogo/descriptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// FeatureLevel is the IRI feature level supported by the provider. It is increased whenever the provider
+	// supports IRI fields added to the machine API.
+	FeatureLevel = 1
+
+	// FeatureLevelHeader is the gRPC response header advertising the FeatureLevel.
+	FeatureLevelHeader = "libvirt-provider-iri-feature-level"
+)
+
+var unknownFieldsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "libvirt_provider",
+	Name:      "iri_unknown_fields_total",
+	Help:      "Number of IRI requests containing fields unknown to the provider.",
+}, []string{"type"})
+
+func init() {
+	prometheus.MustRegister(unknownFieldsTotal)
+}
+
+// Codec is the gRPC proto codec of the IRI server. It reports requests containing unknown fields.
+type Codec struct {
+	log logr.Logger
+	// reject fails requests containing unknown fields instead of dropping them.
+	reject bool
+
+	descriptors sync.Map
+}
+
+func NewCodec(log logr.Logger, reject bool) *Codec {
+	return &Codec{
+		log:    log,
+		reject: reject,
+	}
+}
+
+func (c *Codec) Name() string {
+	return "proto"
+}
+
+func (c *Codec) Marshal(v any) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("failed to marshal, message is %T, want proto.Message", v)
+	}
+	return proto.Marshal(msg)
+}
+
+func (c *Codec) Unmarshal(data []byte, v any) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("failed to unmarshal, message is %T, want proto.Message", v)
+	}
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return err
+	}
+
+	fields, err := c.UnknownFields(msg, data)
+	if err != nil {
+		c.log.V(1).Info("Failed to check for unknown fields", "Type", proto.MessageName(msg), "Error", err)
+		return nil
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	unknownFieldsTotal.WithLabelValues(proto.MessageName(msg)).Inc()
+	if c.reject {
+		return status.Errorf(codes.InvalidArgument, "request contains fields unknown to the provider: %v", fields)
+	}
+	c.log.Info("Request contains fields unknown to the provider, dropping them", "Type", proto.MessageName(msg), "Fields", fields)
+	return nil
+}
+
+// UnknownFields returns the paths of the fields of the encoded message that aren't part of its descriptor.
+// Unknown fields are reported by field number, e.g. machine.spec.#42.
+func (c *Codec) UnknownFields(msg proto.Message, data []byte) ([]string, error) {
+	descMsg, ok := msg.(descriptor.Message)
+	if !ok {
+		return nil, fmt.Errorf("message %T has no descriptor", msg)
+	}
+
+	file, desc := descriptor.ForMessage(descMsg)
+	c.indexFile(file)
+
+	var fields []string
+	if err := c.walk(data, desc, "", &fields); err != nil {
+		return nil, err
+	}
+	slices.Sort(fields)
+	return slices.Compact(fields), nil
+}
+
+func (c *Codec) walk(data []byte, desc *descriptor.DescriptorProto, path string, fields *[]string) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		m := protowire.ConsumeFieldValue(num, typ, data)
+		if m < 0 {
+			return protowire.ParseError(m)
+		}
+		value := data[:m]
+		data = data[m:]
+
+		idx := slices.IndexFunc(desc.GetField(), func(field *descriptor.FieldDescriptorProto) bool {
+			return field.GetNumber() == int32(num)
+		})
+		if idx < 0 {
+			*fields = append(*fields, fmt.Sprintf("%s#%d", path, num))
+			continue
+		}
+
+		field := desc.GetField()[idx]
+		if field.GetType() != descriptor.FieldDescriptorProto_TYPE_MESSAGE || typ != protowire.BytesType {
+			continue
+		}
+
+		fieldDesc, err := c.descriptorFor(field.GetTypeName())
+		if err != nil {
+			return err
+		}
+		fieldData, _ := protowire.ConsumeBytes(value)
+		if err := c.walk(fieldData, fieldDesc, path+field.GetName()+".", fields); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Codec) indexFile(file *descriptor.FileDescriptorProto) {
+	var index func(prefix string, descs []*descriptor.DescriptorProto)
+	index = func(prefix string, descs []*descriptor.DescriptorProto) {
+		for _, desc := range descs {
+			name := prefix + "." + desc.GetName()
+			c.descriptors.LoadOrStore(name, desc)
+			index(name, desc.GetNestedType())
+		}
+	}
+	index("."+file.GetPackage(), file.GetMessageType())
+}
+
+func (c *Codec) descriptorFor(typeName string) (*descriptor.DescriptorProto, error) {
+	if desc, ok := c.descriptors.Load(typeName); ok {
+		return desc.(*descriptor.DescriptorProto), nil
+	}
+
+	typ := proto.MessageType(strings.TrimPrefix(typeName, "."))
+	if typ == nil || typ.Kind() != reflect.Ptr {
+		return nil, fmt.Errorf("unknown message type %s", typeName)
+	}
+	msg, ok := reflect.New(typ.Elem()).Interface().(descriptor.Message)
+	if !ok {
+		return nil, fmt.Errorf("message type %s has no descriptor", typeName)
+	}
+
+	file, _ := descriptor.ForMessage(msg)
+	c.indexFile(file)
+	if desc, ok := c.descriptors.Load(typeName); ok {
+		return desc.(*descriptor.DescriptorProto), nil
+	}
+	return nil, fmt.Errorf("unknown message type %s", typeName)
+}
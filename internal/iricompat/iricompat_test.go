@@ -0,0 +1,85 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package iricompat_test
+
+import (
+	"github.com/go-logr/logr"
+	"github.com/gogo/protobuf/proto"
+	iri "github.com/ironcore-dev/ironcore/iri/apis/machine/v1alpha1"
+	irimeta "github.com/ironcore-dev/ironcore/iri/apis/meta/v1alpha1"
+	. "github.com/ironcore-dev/libvirt-provider/internal/iricompat"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// createMachineRequestWithUnknownSpecField encodes a CreateMachineRequest whose machine spec has the unknown
+// field 42, as sent by a client of a newer IRI version.
+func createMachineRequestWithUnknownSpecField() []byte {
+	spec, err := proto.Marshal(&iri.MachineSpec{Class: "x3-xlarge"})
+	Expect(err).NotTo(HaveOccurred())
+	spec = protowire.AppendTag(spec, 42, protowire.BytesType)
+	spec = protowire.AppendString(spec, "new")
+
+	metadata, err := proto.Marshal(&irimeta.ObjectMetadata{Labels: map[string]string{"foo": "bar"}})
+	Expect(err).NotTo(HaveOccurred())
+
+	var machine []byte
+	machine = protowire.AppendTag(machine, 1, protowire.BytesType)
+	machine = protowire.AppendBytes(machine, metadata)
+	machine = protowire.AppendTag(machine, 2, protowire.BytesType)
+	machine = protowire.AppendBytes(machine, spec)
+
+	var req []byte
+	req = protowire.AppendTag(req, 1, protowire.BytesType)
+	return protowire.AppendBytes(req, machine)
+}
+
+var _ = Describe("Codec", func() {
+	It("should not report known fields", func() {
+		codec := NewCodec(logr.Discard(), true)
+		data, err := codec.Marshal(&iri.CreateMachineRequest{
+			Machine: &iri.Machine{
+				Metadata: &irimeta.ObjectMetadata{Labels: map[string]string{"foo": "bar"}},
+				Spec: &iri.MachineSpec{
+					Class:   "x3-xlarge",
+					Image:   &iri.ImageSpec{Image: "example.org/image"},
+					Volumes: []*iri.Volume{{Name: "disk-1", EmptyDisk: &iri.EmptyDisk{SizeBytes: 1}}},
+				},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		req := &iri.CreateMachineRequest{}
+		Expect(codec.UnknownFields(req, data)).To(BeEmpty())
+		Expect(codec.Unmarshal(data, req)).To(Succeed())
+		Expect(req.Machine.Spec.Volumes).To(HaveLen(1))
+	})
+
+	It("should report unknown nested fields", func() {
+		codec := NewCodec(logr.Discard(), false)
+		data := createMachineRequestWithUnknownSpecField()
+
+		req := &iri.CreateMachineRequest{}
+		Expect(codec.UnknownFields(req, data)).To(Equal([]string{"machine.spec.#42"}))
+		Expect(codec.Unmarshal(data, req)).To(Succeed())
+		Expect(req.Machine.Spec.Class).To(Equal("x3-xlarge"))
+		Expect(req.Machine.Metadata.Labels).To(Equal(map[string]string{"foo": "bar"}))
+	})
+
+	It("should only encode proto messages", func() {
+		codec := NewCodec(logr.Discard(), false)
+		_, err := codec.Marshal(struct{}{})
+		Expect(err).To(MatchError(ContainSubstring("want proto.Message")))
+		Expect(codec.Unmarshal([]byte("{}"), &struct{}{})).To(MatchError(ContainSubstring("want proto.Message")))
+	})
+
+	It("should reject unknown fields if configured", func() {
+		codec := NewCodec(logr.Discard(), true)
+		err := codec.Unmarshal(createMachineRequestWithUnknownSpecField(), &iri.CreateMachineRequest{})
+		Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+	})
+})
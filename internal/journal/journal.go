@@ -0,0 +1,227 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMaxEntries = 256
+
+	perm = 0600
+)
+
+type Operation string
+
+const (
+	OperationCreate      Operation = "Create"
+	OperationAttach      Operation = "Attach"
+	OperationDetach      Operation = "Detach"
+	OperationResize      Operation = "Resize"
+	OperationDestroy     Operation = "Destroy"
+	OperationMigrate     Operation = "Migrate"
+	OperationChangeMedia Operation = "ChangeMedia"
+)
+
+type Outcome string
+
+const (
+	// OutcomeStarted records the intent to run an operation, before its outcome is known.
+	OutcomeStarted   Outcome = "Started"
+	OutcomeSucceeded Outcome = "Succeeded"
+	OutcomeFailed    Outcome = "Failed"
+)
+
+// Entry is a single journaled operation on a machine.
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Operation Operation `json:"operation"`
+	Target    string    `json:"target,omitempty"`
+	Outcome   Outcome   `json:"outcome"`
+	Message   string    `json:"message,omitempty"`
+}
+
+type Options struct {
+	// MaxEntries is the number of entries kept per machine. Older entries are dropped first.
+	MaxEntries int
+}
+
+func setOptionsDefaults(o *Options) {
+	if o.MaxEntries <= 0 {
+		o.MaxEntries = DefaultMaxEntries
+	}
+}
+
+// Journal keeps a bounded, per-machine operation log as a JSON lines file in the machine directory.
+type Journal struct {
+	mu sync.Mutex
+
+	fileFor    func(machineID string) string
+	maxEntries int
+}
+
+func New(fileFor func(machineID string) string, opts Options) *Journal {
+	setOptionsDefaults(&opts)
+
+	return &Journal{
+		fileFor:    fileFor,
+		maxEntries: opts.MaxEntries,
+	}
+}
+
+// Begin records the intent to run the given operation. The operation is pending until its outcome is recorded,
+// so an operation interrupted by a crash can be completed or rolled back on the next start.
+func (j *Journal) Begin(machineID string, operation Operation, target string) error {
+	if j == nil {
+		return nil
+	}
+
+	return j.append(machineID, Entry{
+		Time:      time.Now().UTC(),
+		Operation: operation,
+		Target:    target,
+		Outcome:   OutcomeStarted,
+	})
+}
+
+// Record appends an entry for the given operation. A nil opErr is recorded as success.
+// Recording is skipped if the machine directory does not exist (anymore).
+func (j *Journal) Record(machineID string, operation Operation, target string, opErr error) error {
+	if j == nil {
+		return nil
+	}
+
+	entry := Entry{
+		Time:      time.Now().UTC(),
+		Operation: operation,
+		Target:    target,
+		Outcome:   OutcomeSucceeded,
+	}
+	if opErr != nil {
+		entry.Outcome = OutcomeFailed
+		entry.Message = opErr.Error()
+	}
+	return j.append(machineID, entry)
+}
+
+func (j *Journal) append(machineID string, entry Entry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	filename := j.fileFor(machineID)
+	if _, err := os.Stat(filepath.Dir(filename)); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("error checking machine directory: %w", err)
+	}
+
+	entries, err := readEntries(filename)
+	if err != nil {
+		return err
+	}
+
+	entries = append(entries, entry)
+	if len(entries) > j.maxEntries {
+		entries = entries[len(entries)-j.maxEntries:]
+	}
+
+	return writeEntries(filename, entries)
+}
+
+// Pending returns the begun operations of the given machine whose outcome wasn't recorded, oldest first.
+func (j *Journal) Pending(machineID string) ([]Entry, error) {
+	if j == nil {
+		return nil, nil
+	}
+
+	entries, err := j.Read(machineID)
+	if err != nil {
+		return nil, err
+	}
+
+	type key struct {
+		operation Operation
+		target    string
+	}
+	// An operation is pending if its intent is the last entry recorded for it.
+	last := make(map[key]int, len(entries))
+	for i, entry := range entries {
+		last[key{entry.Operation, entry.Target}] = i
+	}
+
+	var pending []Entry
+	for i, entry := range entries {
+		if entry.Outcome == OutcomeStarted && last[key{entry.Operation, entry.Target}] == i {
+			pending = append(pending, entry)
+		}
+	}
+	return pending, nil
+}
+
+// Read returns all journaled entries of the given machine, oldest first.
+func (j *Journal) Read(machineID string) ([]Entry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return readEntries(j.fileFor(machineID))
+}
+
+func readEntries(filename string) ([]Entry, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading journal: %w", err)
+	}
+
+	var entries []Entry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			// A torn write must not render the whole journal unusable.
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error scanning journal: %w", err)
+	}
+	return entries, nil
+}
+
+func writeEntries(filename string, entries []Entry) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			return fmt.Errorf("error encoding journal entry: %w", err)
+		}
+	}
+
+	tmpFilename := filename + ".tmp"
+	if err := os.WriteFile(tmpFilename, buf.Bytes(), perm); err != nil {
+		return fmt.Errorf("error writing journal: %w", err)
+	}
+	if err := os.Rename(tmpFilename, filename); err != nil {
+		return fmt.Errorf("error replacing journal: %w", err)
+	}
+	return nil
+}
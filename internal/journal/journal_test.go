@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package journal_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ironcore-dev/libvirt-provider/internal/journal"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Journal", func() {
+	var (
+		rootDir string
+		j       *journal.Journal
+	)
+
+	BeforeEach(func() {
+		rootDir = GinkgoT().TempDir()
+		j = journal.New(func(machineID string) string {
+			return filepath.Join(rootDir, machineID, "journal.jsonl")
+		}, journal.Options{MaxEntries: 3})
+	})
+
+	It("should record operations with their outcome", func() {
+		Expect(os.Mkdir(filepath.Join(rootDir, "foo"), 0700)).To(Succeed())
+
+		Expect(j.Record("foo", journal.OperationCreate, "", nil)).To(Succeed())
+		Expect(j.Record("foo", journal.OperationAttach, "disk-1", errors.New("boom"))).To(Succeed())
+
+		entries, err := j.Read("foo")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(2))
+		Expect(entries[0].Operation).To(Equal(journal.OperationCreate))
+		Expect(entries[0].Outcome).To(Equal(journal.OutcomeSucceeded))
+		Expect(entries[1].Target).To(Equal("disk-1"))
+		Expect(entries[1].Outcome).To(Equal(journal.OutcomeFailed))
+		Expect(entries[1].Message).To(Equal("boom"))
+	})
+
+	It("should only keep the most recent entries", func() {
+		Expect(os.Mkdir(filepath.Join(rootDir, "foo"), 0700)).To(Succeed())
+
+		for i := 0; i < 5; i++ {
+			Expect(j.Record("foo", journal.OperationResize, fmt.Sprintf("disk-%d", i), nil)).To(Succeed())
+		}
+
+		entries, err := j.Read("foo")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(3))
+		Expect(entries[0].Target).To(Equal("disk-2"))
+		Expect(entries[2].Target).To(Equal("disk-4"))
+	})
+
+	It("should skip recording for machines without a directory", func() {
+		Expect(j.Record("bar", journal.OperationDestroy, "", nil)).To(Succeed())
+
+		entries, err := j.Read("bar")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(BeEmpty())
+	})
+
+	It("should report begun operations without outcome as pending", func() {
+		j = journal.New(func(machineID string) string {
+			return filepath.Join(rootDir, machineID, "journal.jsonl")
+		}, journal.Options{})
+		Expect(os.Mkdir(filepath.Join(rootDir, "foo"), 0700)).To(Succeed())
+
+		Expect(j.Begin("foo", journal.OperationCreate, "")).To(Succeed())
+		Expect(j.Record("foo", journal.OperationCreate, "", nil)).To(Succeed())
+		Expect(j.Begin("foo", journal.OperationDestroy, "")).To(Succeed())
+		Expect(j.Record("foo", journal.OperationAttach, "disk-1", nil)).To(Succeed())
+
+		pending, err := j.Pending("foo")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pending).To(ConsistOf(HaveField("Operation", journal.OperationDestroy)))
+
+		Expect(j.Record("foo", journal.OperationDestroy, "", errors.New("boom"))).To(Succeed())
+		Expect(j.Pending("foo")).To(BeEmpty())
+	})
+})
@@ -0,0 +1,50 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package kernelcmdline
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MaxLength is the maximum length of the kernel command line on x86 (COMMAND_LINE_SIZE).
+const MaxLength = 2048
+
+var paramRegexp = regexp.MustCompile(`^[A-Za-z0-9_.\-]+(=[A-Za-z0-9_.,:/@+=\-]*)?$`)
+
+// Parse splits the given string into kernel command line parameters and validates each of them.
+func Parse(s string) ([]string, error) {
+	params := strings.Fields(s)
+	if err := Validate(params); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+// Validate checks that every parameter is a plain key or key=value pair without quoting,
+// whitespace or shell meta characters.
+func Validate(params []string) error {
+	for _, param := range params {
+		if !paramRegexp.MatchString(param) {
+			return fmt.Errorf("invalid kernel command line parameter %q", param)
+		}
+	}
+	return nil
+}
+
+// Merge appends the extra parameters to the base command line.
+func Merge(base string, extra []string) (string, error) {
+	parts := make([]string, 0, len(extra)+1)
+	if base = strings.TrimSpace(base); base != "" {
+		parts = append(parts, base)
+	}
+	parts = append(parts, extra...)
+
+	cmdline := strings.Join(parts, " ")
+	if len(cmdline) > MaxLength {
+		return "", fmt.Errorf("kernel command line exceeds maximum length of %d bytes", MaxLength)
+	}
+	return cmdline, nil
+}
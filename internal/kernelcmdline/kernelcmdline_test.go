@@ -0,0 +1,51 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package kernelcmdline_test
+
+import (
+	"strings"
+
+	. "github.com/ironcore-dev/libvirt-provider/internal/kernelcmdline"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("KernelCmdline", func() {
+	Context("Parse", func() {
+		It("parses valid parameters", func() {
+			params, err := Parse("  console=ttyS0,115200n8 quiet systemd.unified_cgroup_hierarchy=1 ")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(params).To(Equal([]string{"console=ttyS0,115200n8", "quiet", "systemd.unified_cgroup_hierarchy=1"}))
+		})
+
+		It("returns no parameters for an empty string", func() {
+			params, err := Parse("")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(params).To(BeEmpty())
+		})
+
+		It("rejects quoted or shell-like parameters", func() {
+			_, err := Parse(`init="/bin/sh -c"`)
+			Expect(err).To(HaveOccurred())
+
+			_, err = Parse("foo=$(reboot)")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("Merge", func() {
+		It("appends the extra parameters to the base command line", func() {
+			Expect(Merge("root=/dev/vda ro", []string{"console=ttyS0"})).To(Equal("root=/dev/vda ro console=ttyS0"))
+		})
+
+		It("handles an empty base command line", func() {
+			Expect(Merge("", []string{"quiet"})).To(Equal("quiet"))
+		})
+
+		It("rejects command lines exceeding the maximum length", func() {
+			_, err := Merge("root=/dev/vda", []string{"foo=" + strings.Repeat("a", MaxLength)})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
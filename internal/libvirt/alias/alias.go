@@ -0,0 +1,145 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package alias generates and parses the user aliases of the libvirt domain devices managed by the provider.
+package alias
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// RootFS is the alias of the root fs disk of a machine.
+	RootFS = "ua-rootfs"
+	// Rescue is the alias of the rescue cdrom of a machine.
+	Rescue = "ua-rescue"
+	// RescueRootFS is the alias of the root fs disk of the rescue image of a rescued machine.
+	RescueRootFS = "ua-rescue-rootfs"
+
+	volumePrefix           = "ua-volume-"
+	networkInterfacePrefix = "ua-networkinterface-"
+	mediaPrefix            = "ua-media-"
+	pciDevicePrefix        = "ua-pcidevice-"
+
+	// MaxLength is the maximum length of a generated alias.
+	MaxLength = 255
+)
+
+var (
+	ErrNoVolumeAlias           = errors.New("no volume alias")
+	ErrNoNetworkInterfaceAlias = errors.New("no network interface alias")
+
+	// validAlias matches the alias names accepted by libvirt.
+	validAlias = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+)
+
+// Volume returns the alias of the disk of the volume with the given name.
+// The name is encoded, so any volume name results in a valid alias.
+func Volume(name string) string {
+	return volumePrefix + base64.RawURLEncoding.EncodeToString([]byte(name))
+}
+
+// IsVolume reports whether the alias is the alias of a volume disk.
+func IsVolume(alias string) bool {
+	return strings.HasPrefix(alias, volumePrefix)
+}
+
+// ParseVolume returns the volume name of a volume disk alias.
+func ParseVolume(alias string) (string, error) {
+	if !IsVolume(alias) {
+		return "", fmt.Errorf("%w: %s", ErrNoVolumeAlias, alias)
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(alias, volumePrefix))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// NetworkInterface returns the alias of the device of the network interface with the given name.
+func NetworkInterface(name string) string {
+	return networkInterfacePrefix + name
+}
+
+// IsNetworkInterface reports whether the alias is the alias of a network interface device.
+func IsNetworkInterface(alias string) bool {
+	return strings.HasPrefix(alias, networkInterfacePrefix)
+}
+
+// ParseNetworkInterface returns the network interface name of a network interface alias.
+func ParseNetworkInterface(alias string) (string, error) {
+	if !IsNetworkInterface(alias) {
+		return "", ErrNoNetworkInterfaceAlias
+	}
+	return strings.TrimPrefix(alias, networkInterfacePrefix), nil
+}
+
+// Media returns the alias of the cdrom disk of the media drive with the given name.
+func Media(name string) string {
+	return mediaPrefix + name
+}
+
+// PCIDevice returns the alias of the hostdev passing the PCI device with the given address through.
+func PCIDevice(address string) string {
+	return pciDevicePrefix + strings.ReplaceAll(address, ":", "-")
+}
+
+// IsManaged reports whether the alias is the alias of a device managed by the provider.
+func IsManaged(alias string) bool {
+	switch alias {
+	case RootFS, Rescue, RescueRootFS:
+		return true
+	}
+	return IsVolume(alias) ||
+		IsNetworkInterface(alias) ||
+		strings.HasPrefix(alias, mediaPrefix) ||
+		strings.HasPrefix(alias, pciDevicePrefix)
+}
+
+func validate(alias string) error {
+	if len(alias) > MaxLength {
+		return fmt.Errorf("alias %s exceeds maximum length %d", alias, MaxLength)
+	}
+	if !validAlias.MatchString(alias) {
+		return fmt.Errorf("alias %s contains invalid characters", alias)
+	}
+	return nil
+}
+
+// Registry detects collisions between the aliases of the devices of a single domain.
+type Registry struct {
+	owners map[string]string
+}
+
+// NewRegistry returns a Registry in which the RootFS alias is already taken.
+func NewRegistry() *Registry {
+	return &Registry{
+		owners: map[string]string{RootFS: "root fs"},
+	}
+}
+
+func (r *Registry) register(alias, owner string) error {
+	if err := validate(alias); err != nil {
+		return fmt.Errorf("%s: %w", owner, err)
+	}
+	if existing, ok := r.owners[alias]; ok {
+		return fmt.Errorf("%s: alias %s collides with %s", owner, alias, existing)
+	}
+	r.owners[alias] = owner
+	return nil
+}
+
+// RegisterVolume registers the alias of the volume with the given name.
+func (r *Registry) RegisterVolume(name string) error {
+	return r.register(Volume(name), fmt.Sprintf("volume %q", name))
+}
+
+// RegisterNetworkInterface registers the alias of the network interface with the given name.
+func (r *Registry) RegisterNetworkInterface(name string) error {
+	return r.register(NetworkInterface(name), fmt.Sprintf("network interface %q", name))
+}
@@ -0,0 +1,75 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package alias_test
+
+import (
+	"strings"
+
+	. "github.com/ironcore-dev/libvirt-provider/internal/libvirt/alias"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Alias", func() {
+	It("should round trip volume aliases", func() {
+		for _, name := range []string{"disk-1", "rootfs", "../ua-rootfs", "vol/üñí"} {
+			a := Volume(name)
+			Expect(IsVolume(a)).To(BeTrue())
+			Expect(IsNetworkInterface(a)).To(BeFalse())
+			Expect(ParseVolume(a)).To(Equal(name))
+		}
+	})
+
+	It("should round trip network interface aliases", func() {
+		a := NetworkInterface("nic-1")
+		Expect(IsNetworkInterface(a)).To(BeTrue())
+		Expect(IsVolume(a)).To(BeFalse())
+		Expect(ParseNetworkInterface(a)).To(Equal("nic-1"))
+	})
+
+	It("should fail to parse foreign aliases", func() {
+		_, err := ParseVolume(RootFS)
+		Expect(err).To(MatchError(ErrNoVolumeAlias))
+		_, err = ParseNetworkInterface(RootFS)
+		Expect(err).To(MatchError(ErrNoNetworkInterfaceAlias))
+	})
+
+	It("should recognize the aliases of managed devices", func() {
+		for _, a := range []string{RootFS, Rescue, RescueRootFS, Volume("disk-1"), NetworkInterface("nic-1"), Media("installer"), PCIDevice("0000:3b:00.0")} {
+			Expect(IsManaged(a)).To(BeTrue(), a)
+		}
+		for _, a := range []string{"", "virtio-disk1", "net0", "hostdev0", "ua-foo"} {
+			Expect(IsManaged(a)).To(BeFalse(), a)
+		}
+	})
+
+	Describe("Registry", func() {
+		It("should accept distinct devices", func() {
+			registry := NewRegistry()
+			Expect(registry.RegisterVolume("rootfs")).To(Succeed())
+			Expect(registry.RegisterVolume("disk-1")).To(Succeed())
+			Expect(registry.RegisterNetworkInterface("nic-1")).To(Succeed())
+			Expect(registry.RegisterNetworkInterface("disk-1")).To(Succeed())
+		})
+
+		It("should detect collisions", func() {
+			registry := NewRegistry()
+			Expect(registry.RegisterVolume("disk-1")).To(Succeed())
+			Expect(registry.RegisterVolume("disk-1")).To(MatchError(ContainSubstring("collides with volume")))
+			Expect(registry.RegisterNetworkInterface("nic-1")).To(Succeed())
+			Expect(registry.RegisterNetworkInterface("nic-1")).To(MatchError(ContainSubstring("collides with network interface")))
+		})
+
+		It("should reject invalid network interface names", func() {
+			registry := NewRegistry()
+			Expect(registry.RegisterNetworkInterface("nic 1")).To(MatchError(ContainSubstring("invalid characters")))
+		})
+
+		It("should reject too long aliases", func() {
+			registry := NewRegistry()
+			Expect(registry.RegisterVolume(strings.Repeat("a", MaxLength))).To(MatchError(ContainSubstring("maximum length")))
+			Expect(registry.RegisterNetworkInterface(strings.Repeat("a", MaxLength))).To(MatchError(ContainSubstring("maximum length")))
+		})
+	})
+})
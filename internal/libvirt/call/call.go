@@ -0,0 +1,105 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package call bounds libvirt calls by deadlines. Calls of go-libvirt can't be cancelled, a hung libvirt daemon
+// blocks them until the connection is closed. Bounded calls return early instead and leave the call running
+// in the background. Mutating calls must not be left running, their effect would be unknown to the caller, so
+// they abort the call and wait for it to return instead.
+package call
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrTimeout is the error of calls that didn't return within their timeout.
+var ErrTimeout = errors.New("libvirt call timed out")
+
+// Do runs the call f and waits until it returns, the timeout passes or the context is done. It returns an error
+// wrapping ErrTimeout if the timeout passes and the cause of the context otherwise. A timeout of zero only
+// bounds the call by the context.
+func Do(ctx context.Context, timeout time.Duration, f func() error) error {
+	_, err := Value(ctx, timeout, func() (struct{}, error) {
+		return struct{}{}, f()
+	})
+	return err
+}
+
+// Value runs the call f returning a value like Do.
+func Value[T any](ctx context.Context, timeout time.Duration, f func() (T, error)) (T, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, timeout, fmt.Errorf("%w after %s", ErrTimeout, timeout))
+		defer cancel()
+	}
+
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := f()
+		done <- result{value, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.value, res.err
+	case <-ctx.Done():
+		var zero T
+		return zero, context.Cause(ctx)
+	}
+}
+
+// Mutate runs the mutating call f and waits until it returns. If the timeout passes or the context is done before,
+// abort is called once to make the call return, e.g. by closing its connection. Unlike Do, the call is never left
+// running, so its outcome is known: a call succeeding despite the deadline returns no error, a failing one returns
+// an error wrapping the cause of the deadline and the error of the call. A nil abort waits for the call.
+func Mutate(ctx context.Context, timeout time.Duration, abort func(), f func() error) error {
+	_, err := MutateValue(ctx, timeout, abort, func() (struct{}, error) {
+		return struct{}{}, f()
+	})
+	return err
+}
+
+// MutateValue runs the mutating call f returning a value like Mutate.
+func MutateValue[T any](ctx context.Context, timeout time.Duration, abort func(), f func() (T, error)) (T, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, timeout, fmt.Errorf("%w after %s", ErrTimeout, timeout))
+		defer cancel()
+	}
+
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := f()
+		done <- result{value, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.value, res.err
+	case <-ctx.Done():
+	}
+
+	if abort != nil {
+		abort()
+	}
+	res := <-done
+	if res.err != nil {
+		return res.value, fmt.Errorf("%w: %w", context.Cause(ctx), res.err)
+	}
+	return res.value, nil
+}
+
+// IsTimeout reports whether the error is a timeout of a call or of its context.
+func IsTimeout(err error) bool {
+	return errors.Is(err, ErrTimeout) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,96 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package call_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/ironcore-dev/libvirt-provider/internal/libvirt/call"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Call", func() {
+	It("should return the result of calls returning in time", func(ctx SpecContext) {
+		value, err := Value(ctx, time.Minute, func() (int, error) { return 42, nil })
+		Expect(err).NotTo(HaveOccurred())
+		Expect(value).To(Equal(42))
+
+		callErr := errors.New("call failed")
+		Expect(Do(ctx, 0, func() error { return callErr })).To(MatchError(callErr))
+	})
+
+	It("should time out hung calls", func(ctx SpecContext) {
+		release := make(chan struct{})
+		DeferCleanup(func() { close(release) })
+
+		err := Do(ctx, 10*time.Millisecond, func() error {
+			<-release
+			return nil
+		})
+		Expect(err).To(MatchError(ErrTimeout))
+		Expect(IsTimeout(err)).To(BeTrue())
+	})
+
+	It("should return the cause of cancelled contexts", func(ctx SpecContext) {
+		release := make(chan struct{})
+		DeferCleanup(func() { close(release) })
+
+		cause := errors.New("machine deleted")
+		callCtx, cancel := context.WithCancelCause(ctx)
+		cancel(cause)
+
+		err := Do(callCtx, time.Minute, func() error {
+			<-release
+			return nil
+		})
+		Expect(err).To(MatchError(cause))
+		Expect(IsTimeout(err)).To(BeFalse())
+	})
+	It("should abort mutating calls and wait for them to return", func(ctx SpecContext) {
+		release := make(chan struct{})
+		var aborted int
+		err := Mutate(ctx, 10*time.Millisecond, func() {
+			aborted++
+			close(release)
+		}, func() error {
+			<-release
+			return errors.New("connection closed")
+		})
+		Expect(aborted).To(Equal(1))
+		Expect(err).To(MatchError(ErrTimeout))
+		Expect(err).To(MatchError(ContainSubstring("connection closed")))
+	})
+
+	It("should return the result of mutating calls succeeding despite the deadline", func(ctx SpecContext) {
+		cause := errors.New("machine deleted")
+		callCtx, cancel := context.WithCancelCause(ctx)
+		cancel(cause)
+
+		release := make(chan struct{})
+		value, err := MutateValue(callCtx, time.Minute, func() { close(release) }, func() (int, error) {
+			<-release
+			return 42, nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(value).To(Equal(42))
+	})
+
+	It("should wait for mutating calls without abort", func(ctx SpecContext) {
+		returned := make(chan struct{})
+		go func() {
+			defer close(returned)
+			time.Sleep(20 * time.Millisecond)
+		}()
+
+		err := Mutate(ctx, time.Millisecond, nil, func() error {
+			<-returned
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(returned).To(BeClosed())
+	})
+})
@@ -0,0 +1,138 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package device allocates the target device names of the libvirt domain disks managed by the provider.
+package device
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/alias"
+	"libvirt.org/go/libvirtxml"
+)
+
+const (
+	virtioPrefix = "vd"
+	sataPrefix   = "sd"
+
+	// RootFSTarget is the target of the root fs disk. Volume device names have an index of at most two
+	// letters, the three letter index of the root fs target never collides with a volume.
+	RootFSTarget = virtioPrefix + "aaa"
+	// RescueRootFSTarget is the target of the root fs disk of the rescue image of a rescued machine.
+	RescueRootFSTarget = virtioPrefix + "aab"
+
+	// RescueTarget is the target of the rescue cdrom, on the SATA bus separate from the virtio disks.
+	RescueTarget = sataPrefix + "a"
+	// MaxMediaTargets is the number of SATA targets following the RescueTarget reserved for media drives.
+	MaxMediaTargets = 8
+	// SATARootFSTarget is the target of the root fs disk attached to the SATA bus. It follows the media targets,
+	// so a rescued machine with media drives never has two disks on the same target.
+	SATARootFSTarget = sataPrefix + "j"
+)
+
+var (
+	ErrInvalidDevice = errors.New("invalid device name")
+	ErrTargetInUse   = errors.New("target in use")
+
+	// deviceName matches the volume device names, a two letter prefix followed by a one or two letter index,
+	// e.g. oda or odab.
+	deviceName = regexp.MustCompile(`^[a-z]{2}([a-z]{1,2})$`)
+)
+
+// VirtioTarget returns the virtio disk target of the volume device with the given name, e.g. vdb for odb.
+// The target only depends on the device name, so it is stable across reboots and recreations of the domain.
+func VirtioTarget(device string) (string, error) {
+	match := deviceName.FindStringSubmatch(device)
+	if match == nil {
+		return "", fmt.Errorf("%w %q: must match %s", ErrInvalidDevice, device, deviceName)
+	}
+	return virtioPrefix + match[1], nil
+}
+
+// MediaTarget returns the SATA target of the media drive at the given index, e.g. sdb for the first drive.
+func MediaTarget(index int) string {
+	return fmt.Sprintf("%s%c", sataPrefix, 'b'+index)
+}
+
+// Allocator tracks the disk targets of a single domain and detects conflicting device requests.
+type Allocator struct {
+	owners map[string]string
+}
+
+// NewAllocator returns an Allocator in which the targets of the root fs and the rescue cdrom are already taken.
+func NewAllocator() *Allocator {
+	return &Allocator{
+		owners: map[string]string{
+			RootFSTarget:     alias.RootFS,
+			SATARootFSTarget: alias.RootFS,
+			RescueTarget:     alias.Rescue,
+		},
+	}
+}
+
+// ForDomain returns an Allocator in which the targets of the disks of the domain are taken.
+func ForDomain(domain *libvirtxml.Domain) *Allocator {
+	a := NewAllocator()
+	if domain.Devices == nil {
+		return a
+	}
+
+	for _, disk := range domain.Devices.Disks {
+		if disk.Target == nil || disk.Target.Dev == "" {
+			continue
+		}
+
+		var owner string
+		if disk.Alias != nil {
+			owner = disk.Alias.Name
+		}
+		a.owners[disk.Target.Dev] = owner
+	}
+	return a
+}
+
+// Claim takes the target for the device with the given alias. Claiming a target again for the same alias
+// succeeds.
+func (a *Allocator) Claim(target, owner string) error {
+	if existing, ok := a.owners[target]; ok && existing != owner {
+		return fmt.Errorf("%w: %s is used by %s", ErrTargetInUse, target, describe(existing))
+	}
+	a.owners[target] = owner
+	return nil
+}
+
+// Release frees the target.
+func (a *Allocator) Release(target string) {
+	delete(a.owners, target)
+}
+
+// AllocateVolume claims the virtio target of the volume with the given name and device.
+func (a *Allocator) AllocateVolume(name, device string) (string, error) {
+	target, err := VirtioTarget(device)
+	if err != nil {
+		return "", fmt.Errorf("volume %q: %w", name, err)
+	}
+	if err := a.Claim(target, alias.Volume(name)); err != nil {
+		return "", fmt.Errorf("volume %q: %w", name, err)
+	}
+	return target, nil
+}
+
+// describe returns a readable description of the device with the given alias.
+func describe(owner string) string {
+	switch {
+	case owner == "":
+		return "a foreign disk"
+	case owner == alias.RootFS:
+		return "the root fs"
+	case owner == alias.Rescue:
+		return "the rescue cdrom"
+	case alias.IsVolume(owner):
+		if name, err := alias.ParseVolume(owner); err == nil {
+			return fmt.Sprintf("volume %q", name)
+		}
+	}
+	return owner
+}
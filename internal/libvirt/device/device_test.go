@@ -0,0 +1,79 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package device_test
+
+import (
+	"fmt"
+
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/alias"
+	. "github.com/ironcore-dev/libvirt-provider/internal/libvirt/device"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"libvirt.org/go/libvirtxml"
+)
+
+var _ = Describe("Device", func() {
+	It("should compute the virtio targets of volume devices", func() {
+		Expect(VirtioTarget("oda")).To(Equal("vda"))
+		Expect(VirtioTarget("odab")).To(Equal("vdab"))
+	})
+
+	It("should reject invalid device names", func() {
+		for _, device := range []string{"", "od", "odabc", "ODA", "od1"} {
+			_, err := VirtioTarget(device)
+			Expect(err).To(MatchError(ErrInvalidDevice), device)
+		}
+	})
+
+	Describe("Allocator", func() {
+		It("should allocate distinct devices", func() {
+			allocator := NewAllocator()
+			Expect(allocator.AllocateVolume("disk-1", "oda")).To(Equal("vda"))
+			Expect(allocator.AllocateVolume("disk-2", "odb")).To(Equal("vdb"))
+			Expect(allocator.AllocateVolume("disk-1", "oda")).To(Equal("vda"))
+		})
+
+		It("should reject conflicting devices", func() {
+			allocator := NewAllocator()
+			Expect(allocator.AllocateVolume("disk-1", "oda")).To(Equal("vda"))
+			_, err := allocator.AllocateVolume("disk-2", "xda")
+			Expect(err).To(MatchError(ErrTargetInUse))
+			Expect(err).To(MatchError(ContainSubstring(`used by volume "disk-1"`)))
+
+			Expect(allocator.Claim(RootFSTarget, alias.Volume("disk-2"))).To(MatchError(ContainSubstring("used by the root fs")))
+		})
+
+		It("should keep the sata targets of the root fs, the rescue cdrom and the media drives distinct", func() {
+			allocator := NewAllocator()
+			Expect(allocator.Claim(SATARootFSTarget, alias.Rescue)).To(MatchError(ContainSubstring("used by the root fs")))
+			Expect(allocator.Claim(RescueTarget, alias.RootFS)).To(MatchError(ContainSubstring("used by the rescue cdrom")))
+			for i := range MaxMediaTargets {
+				Expect(allocator.Claim(MediaTarget(i), alias.Media(fmt.Sprint(i)))).To(Succeed())
+			}
+		})
+
+		It("should track the disks of a domain", func() {
+			allocator := ForDomain(&libvirtxml.Domain{
+				Devices: &libvirtxml.DomainDeviceList{
+					Disks: []libvirtxml.DomainDisk{
+						{
+							Alias:  &libvirtxml.DomainAlias{Name: alias.Volume("disk-1")},
+							Target: &libvirtxml.DomainDiskTarget{Dev: "vda"},
+						},
+						{
+							Target: &libvirtxml.DomainDiskTarget{Dev: "vdb"},
+						},
+					},
+				},
+			})
+
+			Expect(allocator.AllocateVolume("disk-1", "oda")).To(Equal("vda"))
+			_, err := allocator.AllocateVolume("disk-2", "odb")
+			Expect(err).To(MatchError(ContainSubstring("used by a foreign disk")))
+
+			allocator.Release("vdb")
+			Expect(allocator.AllocateVolume("disk-2", "odb")).To(Equal("vdb"))
+		})
+	})
+})
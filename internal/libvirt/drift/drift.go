@@ -0,0 +1,187 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package drift detects changes made to running domains outside the provider, e.g. devices attached via
+// virsh attach-device or resources changed via virsh setvcpus and setmem.
+package drift
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/alias"
+	"libvirt.org/go/libvirtxml"
+)
+
+// Policy defines how drift of a domain is handled.
+type Policy string
+
+const (
+	// PolicyIgnore doesn't detect drift.
+	PolicyIgnore Policy = "Ignore"
+	// PolicyReport reports drift via machine condition and event.
+	PolicyReport Policy = "Report"
+	// PolicyRevert reports drift and reverts it on the running domain.
+	PolicyRevert Policy = "Revert"
+)
+
+// Policies are the available policies.
+var Policies = []Policy{PolicyIgnore, PolicyReport, PolicyRevert}
+
+// ParsePolicy parses a policy case-insensitively.
+func ParsePolicy(s string) (Policy, error) {
+	for _, policy := range Policies {
+		if strings.EqualFold(strings.TrimSpace(s), string(policy)) {
+			return policy, nil
+		}
+	}
+	return "", fmt.Errorf("unknown drift policy %q, available: %v", s, Policies)
+}
+
+// Kind is the kind of a drift.
+type Kind string
+
+const (
+	// KindDevice is a device not managed by the provider.
+	KindDevice Kind = "Device"
+	// KindVCPUs is a number of vCPUs differing from the machine spec.
+	KindVCPUs Kind = "VCPUs"
+	// KindMemory is a memory size differing from the machine spec.
+	KindMemory Kind = "Memory"
+)
+
+// memoryAlignment is the granularity in bytes in which libvirt and QEMU size the memory of domains.
+const memoryAlignment = 1 << 20
+
+// Drift is a single difference between the running domain and the domain desired by the provider.
+type Drift struct {
+	Kind Kind
+	// Alias is the alias libvirt assigned to the device of KindDevice drift.
+	Alias string
+	// Device is the device of KindDevice drift. Detaching it reverts the drift.
+	Device libvirtxml.Document
+	// Desired and Actual are the resource values of KindVCPUs and KindMemory drift, the memory in bytes.
+	Desired, Actual uint64
+}
+
+func (d Drift) String() string {
+	switch d.Kind {
+	case KindDevice:
+		return fmt.Sprintf("unmanaged %s %s", deviceType(d.Device), d.Alias)
+	case KindMemory:
+		return fmt.Sprintf("memory %d bytes instead of %d bytes", d.Actual, d.Desired)
+	default:
+		return fmt.Sprintf("%s %d instead of %d", strings.ToLower(string(d.Kind)), d.Actual, d.Desired)
+	}
+}
+
+func deviceType(device libvirtxml.Document) string {
+	switch device.(type) {
+	case *libvirtxml.DomainDisk:
+		return "disk"
+	case *libvirtxml.DomainInterface:
+		return "interface"
+	case *libvirtxml.DomainHostdev:
+		return "hostdev"
+	case *libvirtxml.DomainFilesystem:
+		return "filesystem"
+	default:
+		return "device"
+	}
+}
+
+// Resources are the resources of a domain desired by the provider.
+type Resources struct {
+	VCPUs       uint
+	MemoryBytes uint64
+}
+
+func deviceAlias(a *libvirtxml.DomainAlias) string {
+	if a == nil {
+		return ""
+	}
+	return a.Name
+}
+
+// Detect returns the drift of the running domain. All disks, interfaces and host devices the provider attaches
+// carry a managed alias, any other one was attached out-of-band. Controllers, consoles and similar devices added
+// by libvirt itself are not considered.
+func Detect(domain *libvirtxml.Domain, desired Resources) []Drift {
+	var drifts []Drift
+
+	if devices := domain.Devices; devices != nil {
+		addDevice := func(a *libvirtxml.DomainAlias, device libvirtxml.Document) {
+			if name := deviceAlias(a); !alias.IsManaged(name) {
+				drifts = append(drifts, Drift{Kind: KindDevice, Alias: name, Device: device})
+			}
+		}
+		for i := range devices.Disks {
+			addDevice(devices.Disks[i].Alias, &devices.Disks[i])
+		}
+		for i := range devices.Interfaces {
+			addDevice(devices.Interfaces[i].Alias, &devices.Interfaces[i])
+		}
+		for i := range devices.Hostdevs {
+			addDevice(devices.Hostdevs[i].Alias, &devices.Hostdevs[i])
+		}
+		for i := range devices.Filesystems {
+			addDevice(devices.Filesystems[i].Alias, &devices.Filesystems[i])
+		}
+	}
+
+	if vcpus := domain.VCPU; vcpus != nil && desired.VCPUs > 0 {
+		actual := vcpus.Value
+		if vcpus.Current > 0 {
+			actual = vcpus.Current
+		}
+		if actual != desired.VCPUs {
+			drifts = append(drifts, Drift{Kind: KindVCPUs, Desired: uint64(desired.VCPUs), Actual: uint64(actual)})
+		}
+	}
+
+	if memory := domain.CurrentMemory; memory != nil && desired.MemoryBytes > 0 {
+		actual, ok := memoryBytes(memory.Value, memory.Unit)
+		if ok && alignMemory(actual) != alignMemory(desired.MemoryBytes) {
+			drifts = append(drifts, Drift{Kind: KindMemory, Desired: desired.MemoryBytes, Actual: actual})
+		}
+	}
+
+	return drifts
+}
+
+func alignMemory(bytes uint64) uint64 {
+	return (bytes + memoryAlignment - 1) / memoryAlignment
+}
+
+// memoryBytes converts a libvirt memory value to bytes. Libvirt defaults to KiB if no unit is given.
+func memoryBytes(value uint, unit string) (uint64, bool) {
+	var scale uint64
+	switch unit {
+	case "b", "bytes":
+		scale = 1
+	case "KB":
+		scale = 1000
+	case "", "k", "KiB":
+		scale = 1 << 10
+	case "MB":
+		scale = 1000 * 1000
+	case "M", "MiB":
+		scale = 1 << 20
+	case "GB":
+		scale = 1000 * 1000 * 1000
+	case "G", "GiB":
+		scale = 1 << 30
+	default:
+		return 0, false
+	}
+	return uint64(value) * scale, true
+}
+
+// Summary returns a human-readable summary of the drifts.
+func Summary(drifts []Drift) string {
+	descriptions := make([]string, 0, len(drifts))
+	for _, d := range drifts {
+		descriptions = append(descriptions, d.String())
+	}
+	return strings.Join(descriptions, ", ")
+}
@@ -0,0 +1,74 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package drift_test
+
+import (
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/alias"
+	. "github.com/ironcore-dev/libvirt-provider/internal/libvirt/drift"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"libvirt.org/go/libvirtxml"
+)
+
+var _ = Describe("Drift", func() {
+	It("should parse policies", func() {
+		Expect(ParsePolicy("revert")).To(Equal(PolicyRevert))
+		Expect(ParsePolicy("Report")).To(Equal(PolicyReport))
+		_, err := ParsePolicy("fix")
+		Expect(err).To(HaveOccurred())
+	})
+
+	Describe("Detect", func() {
+		var domain *libvirtxml.Domain
+
+		BeforeEach(func() {
+			domain = &libvirtxml.Domain{
+				VCPU:          &libvirtxml.DomainVCPU{Value: 4},
+				CurrentMemory: &libvirtxml.DomainCurrentMemory{Value: 4 << 20, Unit: "KiB"},
+				Devices: &libvirtxml.DomainDeviceList{
+					Disks: []libvirtxml.DomainDisk{
+						{Alias: &libvirtxml.DomainAlias{Name: alias.RootFS}},
+						{Alias: &libvirtxml.DomainAlias{Name: alias.Volume("data")}},
+					},
+					Interfaces: []libvirtxml.DomainInterface{
+						{Alias: &libvirtxml.DomainAlias{Name: alias.NetworkInterface("nic-1")}},
+					},
+					Controllers: []libvirtxml.DomainController{
+						{Type: "usb", Alias: &libvirtxml.DomainAlias{Name: "usb"}},
+					},
+				},
+			}
+		})
+
+		It("should not report a domain matching the desired resources", func() {
+			Expect(Detect(domain, Resources{VCPUs: 4, MemoryBytes: 4 << 30})).To(BeEmpty())
+		})
+
+		It("should report unmanaged devices", func() {
+			domain.Devices.Disks = append(domain.Devices.Disks, libvirtxml.DomainDisk{Alias: &libvirtxml.DomainAlias{Name: "virtio-disk2"}})
+			domain.Devices.Hostdevs = append(domain.Devices.Hostdevs, libvirtxml.DomainHostdev{Alias: &libvirtxml.DomainAlias{Name: "hostdev0"}})
+
+			drifts := Detect(domain, Resources{VCPUs: 4, MemoryBytes: 4 << 30})
+			Expect(drifts).To(HaveLen(2))
+			Expect(drifts[0].Kind).To(Equal(KindDevice))
+			Expect(drifts[0].Alias).To(Equal("virtio-disk2"))
+			Expect(drifts[0].Device).To(BeIdenticalTo(&domain.Devices.Disks[2]))
+			Expect(Summary(drifts)).To(Equal("unmanaged disk virtio-disk2, unmanaged hostdev hostdev0"))
+		})
+
+		It("should report changed resources", func() {
+			domain.VCPU.Current = 2
+			domain.CurrentMemory.Value = 2 << 20
+
+			Expect(Detect(domain, Resources{VCPUs: 4, MemoryBytes: 4 << 30})).To(ConsistOf(
+				Drift{Kind: KindVCPUs, Desired: 4, Actual: 2},
+				Drift{Kind: KindMemory, Desired: 4 << 30, Actual: 2 << 30},
+			))
+		})
+
+		It("should tolerate the memory alignment of libvirt", func() {
+			Expect(Detect(domain, Resources{VCPUs: 4, MemoryBytes: 4<<30 - 4096})).To(BeEmpty())
+		})
+	})
+})
@@ -31,10 +31,15 @@ type Settings struct {
 
 type Capabilities interface {
 	SettingsFor(reqs Requests) (*Settings, error)
+	// SupportsMachineType reports whether the exact machine type, e.g. pc-q35-8.2, is supported for the requests.
+	SupportsMachineType(reqs Requests, machineType string) bool
+	// HostArchitecture is the cpu architecture of the host, e.g. x86_64.
+	HostArchitecture() string
 }
 
 type capabilties struct {
-	caps []libvirtxml.CapsGuest
+	caps     []libvirtxml.CapsGuest
+	hostArch string
 
 	preferredDomainTypes  []string
 	preferredMachineTypes []string
@@ -214,6 +219,37 @@ func (c *capabilties) SettingsFor(reqs Requests) (*Settings, error) {
 	return nil, fmt.Errorf("no matching settings for requests %#+v", reqs)
 }
 
+func (c *capabilties) SupportsMachineType(reqs Requests, machineType string) bool {
+	matches := func(machines []libvirtxml.CapsGuestMachine) bool {
+		for _, machine := range machines {
+			if machine.Name == machineType || machine.Canonical == machineType {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, capability := range c.caps {
+		if capability.OSType != string(reqs.OSType) || capability.Arch.Name != reqs.Architecture {
+			continue
+		}
+
+		if matches(capability.Arch.Machines) {
+			return true
+		}
+		for _, domain := range capability.Arch.Domains {
+			if matches(domain.Machines) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *capabilties) HostArchitecture() string {
+	return c.hostArch
+}
+
 type CapabilitiesOptions struct {
 	PreferredMachineTypes []string
 	PreferredDomainTypes  []string
@@ -230,9 +266,13 @@ func DetectCapabilities(lv *libvirt.Libvirt, opts CapabilitiesOptions) (Capabili
 	if err := xml.Unmarshal(capsData, &caps); err != nil {
 		return nil, fmt.Errorf("error unmarshalling guest capabilities: %w", err)
 	}
+	if caps.Host.CPU == nil || caps.Host.CPU.Arch == "" {
+		return nil, fmt.Errorf("capabilities don't report the host cpu architecture")
+	}
 
 	return &capabilties{
 		caps:                  caps.Guests,
+		hostArch:              caps.Host.CPU.Arch,
 		preferredDomainTypes:  opts.PreferredDomainTypes,
 		preferredMachineTypes: opts.PreferredMachineTypes,
 	}, nil
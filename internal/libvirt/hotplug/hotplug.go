@@ -0,0 +1,184 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package hotplug tracks device hotplug operations of running domains until libvirt confirms them with a
+// device event. Detaching a device only requests its removal from the guest, which may complete later or
+// never, e.g. if the guest doesn't release the device.
+package hotplug
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+type Operation string
+
+const (
+	OperationAttach Operation = "Attach"
+	OperationDetach Operation = "Detach"
+)
+
+type State int
+
+const (
+	// StatePending means libvirt hasn't confirmed the operation yet.
+	StatePending State = iota
+	// StateTimedOut means libvirt didn't confirm the operation within the timeout of the Tracker.
+	StateTimedOut
+	// StateFailed means libvirt reported the removal of the device to have failed.
+	StateFailed
+)
+
+func (s State) String() string {
+	switch s {
+	case StatePending:
+		return "Pending"
+	case StateTimedOut:
+		return "TimedOut"
+	case StateFailed:
+		return "Failed"
+	default:
+		return "Unknown"
+	}
+}
+
+// ErrPending is returned for operations that were issued but not confirmed yet.
+var ErrPending = errors.New("device operation pending")
+
+type key struct {
+	domain string
+	alias  string
+}
+
+type operation struct {
+	operation Operation
+	started   time.Time
+	failed    bool
+}
+
+// Tracker tracks the unconfirmed operations per domain and device alias. A nil Tracker tracks nothing, all
+// operations are considered confirmed right away.
+type Tracker struct {
+	timeout time.Duration
+
+	mu         sync.Mutex
+	operations map[key]operation
+}
+
+func NewTracker(timeout time.Duration) *Tracker {
+	return &Tracker{
+		timeout:    timeout,
+		operations: make(map[key]operation),
+	}
+}
+
+// Timeout returns the duration after which unconfirmed operations time out.
+func (t *Tracker) Timeout() time.Duration {
+	if t == nil {
+		return 0
+	}
+	return t.timeout
+}
+
+// Expect records the operation on the device. It has to be called before the operation is issued, libvirt may
+// emit the confirming event before the call returns.
+func (t *Tracker) Expect(domain, alias string, op Operation) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.operations[key{domain, alias}] = operation{operation: op, started: time.Now()}
+}
+
+// Forget drops the operation on the device, e.g. because issuing it failed.
+func (t *Tracker) Forget(domain, alias string) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.operations, key{domain, alias})
+}
+
+// ForgetDomain drops all operations on the devices of the domain.
+func (t *Tracker) ForgetDomain(domain string) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for k := range t.operations {
+		if k.domain == domain {
+			delete(t.operations, k)
+		}
+	}
+}
+
+// Added confirms the attachment of the device. It reports whether an attachment was tracked.
+func (t *Tracker) Added(domain, alias string) bool {
+	return t.confirm(domain, alias, OperationAttach)
+}
+
+// Removed confirms the detachment of the device. It reports whether a detachment was tracked.
+func (t *Tracker) Removed(domain, alias string) bool {
+	return t.confirm(domain, alias, OperationDetach)
+}
+
+func (t *Tracker) confirm(domain, alias string, op Operation) bool {
+	if t == nil {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	k := key{domain, alias}
+	if existing, ok := t.operations[k]; !ok || existing.operation != op {
+		return false
+	}
+	delete(t.operations, k)
+	return true
+}
+
+// RemovalFailed marks the detachment of the device as failed. It reports whether a detachment was tracked.
+func (t *Tracker) RemovalFailed(domain, alias string) bool {
+	if t == nil {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	k := key{domain, alias}
+	existing, ok := t.operations[k]
+	if !ok || existing.operation != OperationDetach {
+		return false
+	}
+	existing.failed = true
+	t.operations[k] = existing
+	return true
+}
+
+// Pending returns the unconfirmed operation on the device and its state. If there is none, ok is false.
+func (t *Tracker) Pending(domain, alias string) (op Operation, state State, ok bool) {
+	if t == nil {
+		return "", 0, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	existing, ok := t.operations[key{domain, alias}]
+	switch {
+	case !ok:
+		return "", 0, false
+	case existing.failed:
+		return existing.operation, StateFailed, true
+	case time.Since(existing.started) >= t.timeout:
+		return existing.operation, StateTimedOut, true
+	default:
+		return existing.operation, StatePending, true
+	}
+}
@@ -0,0 +1,83 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package hotplug_test
+
+import (
+	"time"
+
+	. "github.com/ironcore-dev/libvirt-provider/internal/libvirt/hotplug"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tracker", func() {
+	const (
+		domain = "machine-1"
+		alias  = "ua-volume-disk-1"
+	)
+
+	It("should track operations until they are confirmed", func() {
+		tracker := NewTracker(time.Hour)
+		tracker.Expect(domain, alias, OperationAttach)
+
+		op, state, ok := tracker.Pending(domain, alias)
+		Expect(ok).To(BeTrue())
+		Expect(op).To(Equal(OperationAttach))
+		Expect(state).To(Equal(StatePending))
+
+		By("ignoring events of other operations")
+		Expect(tracker.Removed(domain, alias)).To(BeFalse())
+		Expect(tracker.Added("machine-2", alias)).To(BeFalse())
+		_, _, ok = tracker.Pending(domain, alias)
+		Expect(ok).To(BeTrue())
+
+		Expect(tracker.Added(domain, alias)).To(BeTrue())
+		_, _, ok = tracker.Pending(domain, alias)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should report failed removals", func() {
+		tracker := NewTracker(time.Hour)
+		tracker.Expect(domain, alias, OperationDetach)
+		Expect(tracker.RemovalFailed(domain, alias)).To(BeTrue())
+
+		op, state, ok := tracker.Pending(domain, alias)
+		Expect(ok).To(BeTrue())
+		Expect(op).To(Equal(OperationDetach))
+		Expect(state).To(Equal(StateFailed))
+
+		By("retrying the removal")
+		tracker.Expect(domain, alias, OperationDetach)
+		_, state, _ = tracker.Pending(domain, alias)
+		Expect(state).To(Equal(StatePending))
+	})
+
+	It("should time out unconfirmed operations", func() {
+		tracker := NewTracker(0)
+		tracker.Expect(domain, alias, OperationDetach)
+
+		_, state, ok := tracker.Pending(domain, alias)
+		Expect(ok).To(BeTrue())
+		Expect(state).To(Equal(StateTimedOut))
+	})
+
+	It("should forget the operations of a domain", func() {
+		tracker := NewTracker(time.Hour)
+		tracker.Expect(domain, alias, OperationAttach)
+		tracker.Expect("machine-2", alias, OperationAttach)
+
+		tracker.ForgetDomain(domain)
+		_, _, ok := tracker.Pending(domain, alias)
+		Expect(ok).To(BeFalse())
+		_, _, ok = tracker.Pending("machine-2", alias)
+		Expect(ok).To(BeTrue())
+	})
+
+	It("should consider all operations confirmed without tracker", func() {
+		var tracker *Tracker
+		tracker.Expect(domain, alias, OperationAttach)
+		_, _, ok := tracker.Pending(domain, alias)
+		Expect(ok).To(BeFalse())
+	})
+})
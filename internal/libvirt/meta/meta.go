@@ -4,13 +4,88 @@
 package meta
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
 	"encoding/xml"
 	"fmt"
+	"io"
 	"strings"
 )
 
+const (
+	// Namespace is the XML namespace of the libvirt provider domain metadata.
+	Namespace = "https://github.com/ironcore-dev/libvirt-provider"
+	// NamespacePrefix is the prefix of Namespace in the domain XML.
+	NamespacePrefix = "libvirtprovider"
+
+	// MachineSnapshotVersion is the version of the machine snapshots written by the provider.
+	MachineSnapshotVersion = 1
+	// MachineSnapshotEncodingGzipBase64 marks snapshots whose data is gzip compressed and base64 encoded.
+	MachineSnapshotEncodingGzipBase64 = "gzip+base64"
+)
+
 type LibvirtProviderMetadata struct {
 	IRIMmachineLabels string `xml:"irimachinelabels"`
+	// Machine is a snapshot of the machine the domain was created for, used to recover the machine if its
+	// store object is lost or corrupt.
+	Machine *MachineSnapshot `xml:"machine"`
+}
+
+// MachineSnapshot is a versioned snapshot of the JSON encoded machine. Snapshots without version contain
+// the plain JSON.
+type MachineSnapshot struct {
+	Version  int    `xml:"version,attr,omitempty"`
+	Encoding string `xml:"encoding,attr,omitempty"`
+	Data     string `xml:",chardata"`
+}
+
+// NewMachineSnapshot returns a compressed snapshot of the JSON encoded machine.
+func NewMachineSnapshot(machineData []byte) (*MachineSnapshot, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(machineData); err != nil {
+		return nil, fmt.Errorf("error compressing machine: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("error compressing machine: %w", err)
+	}
+
+	return &MachineSnapshot{
+		Version:  MachineSnapshotVersion,
+		Encoding: MachineSnapshotEncodingGzipBase64,
+		Data:     base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}, nil
+}
+
+// Decode returns the JSON encoded machine of the snapshot.
+func (s *MachineSnapshot) Decode() ([]byte, error) {
+	if s.Version > MachineSnapshotVersion {
+		return nil, fmt.Errorf("unsupported machine snapshot version %d", s.Version)
+	}
+
+	switch s.Encoding {
+	case "":
+		return []byte(s.Data), nil
+	case MachineSnapshotEncodingGzipBase64:
+		data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s.Data))
+		if err != nil {
+			return nil, fmt.Errorf("error decoding machine snapshot: %w", err)
+		}
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("error decompressing machine snapshot: %w", err)
+		}
+		defer r.Close()
+		machineData, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("error decompressing machine snapshot: %w", err)
+		}
+		return machineData, nil
+	default:
+		return nil, fmt.Errorf("unsupported machine snapshot encoding %q", s.Encoding)
+	}
 }
 
 // Since go does not support XML namespaces easily (see https://github.com/golang/go/issues/9519),
@@ -20,8 +95,9 @@ type LibvirtProviderMetadata struct {
 func (m *LibvirtProviderMetadata) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	start.Name.Local = "libvirtprovider:metadata"
 	return e.EncodeElement(&marshalMetadata{
-		XMLNS:             "https://github.com/ironcore-dev/libvirt-provider",
+		XMLNS:             Namespace,
 		IRIMmachineLabels: m.IRIMmachineLabels,
+		Machine:           m.Machine,
 	}, start)
 }
 
@@ -32,18 +108,34 @@ func (m *LibvirtProviderMetadata) UnmarshalXML(d *xml.Decoder, start xml.StartEl
 	}
 
 	m.IRIMmachineLabels = unmarshal.IRIMmachineLabels
+	m.Machine = unmarshal.Machine
 	return nil
 }
 
+// ElementXML returns the metadata element without namespace, as expected by libvirt when replacing the
+// metadata of a domain with the key NamespacePrefix and the uri Namespace.
+func (m *LibvirtProviderMetadata) ElementXML() (string, error) {
+	data, err := xml.Marshal(&unmarshalMetadata{
+		IRIMmachineLabels: m.IRIMmachineLabels,
+		Machine:           m.Machine,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
 type marshalMetadata struct {
-	XMLName           xml.Name `xml:"libvirtprovider:metadata"`
-	XMLNS             string   `xml:"xmlns:libvirtprovider,attr"`
-	IRIMmachineLabels string   `xml:"libvirtprovider:irimachinelabels"`
+	XMLName           xml.Name         `xml:"libvirtprovider:metadata"`
+	XMLNS             string           `xml:"xmlns:libvirtprovider,attr"`
+	IRIMmachineLabels string           `xml:"libvirtprovider:irimachinelabels"`
+	Machine           *MachineSnapshot `xml:"libvirtprovider:machine,omitempty"`
 }
 
 type unmarshalMetadata struct {
-	XMLName           xml.Name `xml:"metadata"`
-	IRIMmachineLabels string   `xml:"irimachinelabels"`
+	XMLName           xml.Name         `xml:"metadata"`
+	IRIMmachineLabels string           `xml:"irimachinelabels"`
+	Machine           *MachineSnapshot `xml:"machine,omitempty"`
 }
 
 func IRIMachineLabelsEncoder(data map[string]string) string {
@@ -55,3 +147,24 @@ func IRIMachineLabelsEncoder(data map[string]string) string {
 
 	return builder.String()
 }
+
+// IRIMachineLabelsDecoder decodes labels encoded by IRIMachineLabelsEncoder. Lines that can't be decoded
+// are skipped.
+func IRIMachineLabelsDecoder(data string) map[string]string {
+	labels := make(map[string]string)
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		var label map[string]string
+		if err := json.Unmarshal([]byte("{"+line+"}"), &label); err != nil {
+			continue
+		}
+		for key, value := range label {
+			labels[key] = value
+		}
+	}
+	return labels
+}
@@ -34,6 +34,45 @@ var _ = Describe("LibvirtProviderMetadata", func() {
 		})
 	})
 
+	Context("Machine", func() {
+		It("round trips the machine snapshot", func() {
+			snapshot, err := NewMachineSnapshot([]byte(`{"metadata":{"id":"foo"}}`))
+			Expect(err).NotTo(HaveOccurred())
+			metadata := &LibvirtProviderMetadata{
+				IRIMmachineLabels: "test-labels",
+				Machine:           snapshot,
+			}
+
+			data, err := xml.Marshal(metadata)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(ContainSubstring(`<libvirtprovider:machine version="1" encoding="gzip+base64">`))
+
+			unmarshalled := &LibvirtProviderMetadata{}
+			Expect(xml.Unmarshal(data, unmarshalled)).To(Succeed())
+			Expect(unmarshalled).To(Equal(metadata))
+			Expect(unmarshalled.Machine.Decode()).To(MatchJSON(`{"metadata":{"id":"foo"}}`))
+		})
+
+		It("decodes unversioned snapshots", func() {
+			metadata := &LibvirtProviderMetadata{}
+			Expect(xml.Unmarshal([]byte(`<metadata><machine>{"metadata":{"id":"foo"}}</machine></metadata>`), metadata)).To(Succeed())
+			Expect(metadata.Machine.Decode()).To(MatchJSON(`{"metadata":{"id":"foo"}}`))
+		})
+
+		It("rejects snapshots of newer versions", func() {
+			snapshot := &MachineSnapshot{Version: MachineSnapshotVersion + 1, Data: "{}"}
+			_, err := snapshot.Decode()
+			Expect(err).To(MatchError(ContainSubstring("unsupported machine snapshot version")))
+		})
+	})
+
+	Context("ElementXML", func() {
+		It("marshals the metadata without namespace", func() {
+			metadata := &LibvirtProviderMetadata{IRIMmachineLabels: "test-labels", Machine: &MachineSnapshot{Data: "{}"}}
+			Expect(metadata.ElementXML()).To(Equal(`<metadata><irimachinelabels>test-labels</irimachinelabels><machine>{}</machine></metadata>`))
+		})
+	})
+
 	Context("Unmarshalling", func() {
 		It("unmarshals XML to LibvirtProviderMetadata correctly when metadata is populated", func() {
 			metadata := &LibvirtProviderMetadata{}
@@ -69,6 +108,7 @@ var _ = Describe("LibvirtProviderMetadata", func() {
 			Expect(data).To(ContainSubstring(`"downward-api.machinepoollet.ironcore.dev/root-machine-uid": "root-test-uid"`))
 			Expect(data).To(ContainSubstring(`"machinepoollet.ironcore.dev/machine-namespace": "test-namespace"`))
 			Expect(data).To(ContainSubstring(`"machinepoollet.ironcore.dev/machine-name": "test-name"`))
+			Expect(IRIMachineLabelsDecoder(data)).To(Equal(labels))
 		})
 	})
 })
@@ -0,0 +1,137 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package nwfilter manages the libvirt network filters preventing network interfaces from spoofing MAC and IP
+// addresses.
+package nwfilter
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/netip"
+
+	"github.com/digitalocean/go-libvirt"
+	"github.com/google/uuid"
+	libvirtutils "github.com/ironcore-dev/libvirt-provider/internal/libvirt/utils"
+	"libvirt.org/go/libvirtxml"
+)
+
+const (
+	// CleanTraffic is the libvirt builtin filter preventing MAC, IPv4 and ARP spoofing. It drops all other
+	// traffic, including IPv6.
+	CleanTraffic = "clean-traffic"
+
+	// linkLocalIPv6 is the prefix of the IPv6 link-local addresses guests use for neighbor discovery.
+	linkLocalIPv6     = "fe80::"
+	linkLocalIPv6Mask = "10"
+	// unspecifiedIPv6 is the source address of the duplicate address detection of guests.
+	unspecifiedIPv6 = "::"
+
+	namePrefix = "libvirt-provider-"
+
+	parameterMAC = "MAC"
+	parameterIP  = "IP"
+)
+
+func filterUUID(machineID, networkInterfaceName string) uuid.UUID {
+	return uuid.NewHash(sha256.New(), uuid.Nil, []byte(fmt.Sprintf("%s/%s", machineID, networkInterfaceName)), 5)
+}
+
+// Name returns the name of the filter of the network interface of the machine.
+func Name(machineID, networkInterfaceName string) string {
+	return namePrefix + filterUUID(machineID, networkInterfaceName).String()
+}
+
+// Filter returns the filter of the network interface of the machine, restricting its traffic to the given MAC
+// address and IPs. clean-traffic filters the IPv4 addresses, without IPv4 address the address is learned from
+// the first packets of the guest. Dual-stack network interfaces additionally accept incoming IPv6 traffic and
+// outgoing IPv6 traffic from their IPv6 addresses and the link-local ones, other IPv6 traffic is dropped by
+// clean-traffic.
+func Filter(machineID, networkInterfaceName, macAddress string, ips []string) *libvirtxml.NWFilter {
+	ref := &libvirtxml.NWFilterRef{Filter: CleanTraffic}
+	if macAddress != "" {
+		ref.Parameters = append(ref.Parameters, libvirtxml.NWFilterParameter{Name: parameterMAC, Value: macAddress})
+	}
+	var ipv6Addrs []string
+	for _, ip := range ips {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			continue
+		}
+		if addr.Is4() {
+			ref.Parameters = append(ref.Parameters, libvirtxml.NWFilterParameter{Name: parameterIP, Value: addr.String()})
+		} else {
+			ipv6Addrs = append(ipv6Addrs, addr.String())
+		}
+	}
+
+	entries := []libvirtxml.NWFilterEntry{{Ref: ref}}
+	if len(ipv6Addrs) > 0 {
+		entries = append(entries, ipv6Rules(macAddress, ipv6Addrs)...)
+	}
+
+	return &libvirtxml.NWFilter{
+		Name:    Name(machineID, networkInterfaceName),
+		UUID:    filterUUID(machineID, networkInterfaceName).String(),
+		Chain:   "root",
+		Entries: entries,
+	}
+}
+
+func ipv6Rules(macAddress string, addrs []string) []libvirtxml.NWFilterEntry {
+	outgoing := func(addr, mask string) libvirtxml.NWFilterEntry {
+		rule := &libvirtxml.NWFilterRuleIPv6{
+			SrcIPAddr: libvirtxml.NWFilterField{Str: addr},
+			SrcIPMask: libvirtxml.NWFilterField{Str: mask},
+		}
+		rule.SrcMACAddr = libvirtxml.NWFilterField{Str: macAddress}
+		return libvirtxml.NWFilterEntry{Rule: &libvirtxml.NWFilterRule{Action: "accept", Direction: "out", IPv6: rule}}
+	}
+
+	entries := []libvirtxml.NWFilterEntry{
+		outgoing(linkLocalIPv6, linkLocalIPv6Mask),
+		outgoing(unspecifiedIPv6, "128"),
+	}
+	for _, addr := range addrs {
+		entries = append(entries, outgoing(addr, "128"))
+	}
+	return append(entries, libvirtxml.NWFilterEntry{
+		Rule: &libvirtxml.NWFilterRule{Action: "accept", Direction: "in", IPv6: &libvirtxml.NWFilterRuleIPv6{}},
+	})
+}
+
+// FilterRef returns the reference of a domain interface to the filter with the given name.
+func FilterRef(name string) *libvirtxml.DomainInterfaceFilterRef {
+	return &libvirtxml.DomainInterfaceFilterRef{Filter: name}
+}
+
+// Apply defines the filter. Filters already defined are updated, libvirt reapplies them to the network
+// interfaces referencing them.
+func Apply(lv *libvirt.Libvirt, filter *libvirtxml.NWFilter) error {
+	data, err := filter.Marshal()
+	if err != nil {
+		return err
+	}
+
+	if _, err := lv.NwfilterDefineXML(data); err != nil {
+		return fmt.Errorf("error defining nwfilter %s: %w", filter.Name, err)
+	}
+	return nil
+}
+
+// Delete undefines the filter with the given name. Filters already gone are ignored. Filters can only be
+// undefined once no network interface references them anymore.
+func Delete(lv *libvirt.Libvirt, name string) error {
+	filter, err := lv.NwfilterLookupByName(name)
+	if err != nil {
+		if libvirtutils.IsErrorCode(err, libvirt.ErrNoNwfilter) {
+			return nil
+		}
+		return fmt.Errorf("error looking up nwfilter %s: %w", name, err)
+	}
+
+	if err := lv.NwfilterUndefine(filter); libvirtutils.IgnoreErrorCode(err, libvirt.ErrNoNwfilter) != nil {
+		return fmt.Errorf("error undefining nwfilter %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package nwfilter_test
+
+import (
+	. "github.com/ironcore-dev/libvirt-provider/internal/libvirt/nwfilter"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"libvirt.org/go/libvirtxml"
+)
+
+var _ = Describe("NWFilter", func() {
+	It("should name filters stably per network interface", func() {
+		Expect(Name("machine", "nic")).To(Equal(Name("machine", "nic")))
+		Expect(Name("machine", "nic")).NotTo(Equal(Name("machine", "other")))
+		Expect(Name("machine", "nic")).To(HavePrefix("libvirt-provider-"))
+	})
+
+	It("should restrict the traffic to the MAC address and IPv4 addresses", func() {
+		filter := Filter("machine", "nic", "02:00:00:00:00:01", []string{"10.0.0.1"})
+		Expect(filter.Name).To(Equal(Name("machine", "nic")))
+		Expect(filter.UUID).NotTo(BeEmpty())
+		Expect(filter.Entries).To(ConsistOf(libvirtxml.NWFilterEntry{
+			Ref: &libvirtxml.NWFilterRef{
+				Filter: CleanTraffic,
+				Parameters: []libvirtxml.NWFilterParameter{
+					{Name: "MAC", Value: "02:00:00:00:00:01"},
+					{Name: "IP", Value: "10.0.0.1"},
+				},
+			},
+		}))
+
+		data, err := filter.Marshal()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(ContainSubstring(`<filterref filter="clean-traffic">`))
+		Expect(data).NotTo(ContainSubstring("ipv6"))
+	})
+
+	It("should accept the IPv6 traffic of dual-stack network interfaces from their IPv6 addresses", func() {
+		filter := Filter("machine", "nic", "02:00:00:00:00:01", []string{"10.0.0.1", "fd00::1", "fd00::2"})
+		Expect(filter.Entries[0].Ref.Parameters).To(ConsistOf(
+			libvirtxml.NWFilterParameter{Name: "MAC", Value: "02:00:00:00:00:01"},
+			libvirtxml.NWFilterParameter{Name: "IP", Value: "10.0.0.1"},
+		))
+
+		data, err := filter.Marshal()
+		Expect(err).NotTo(HaveOccurred())
+		for _, addr := range []string{"fe80::", "::", "fd00::1", "fd00::2"} {
+			Expect(data).To(ContainSubstring(`<rule action="accept" direction="out">`))
+			Expect(data).To(MatchRegexp(`<ipv6 srcmacaddr="02:00:00:00:00:01" srcipaddr="%s" srcipmask="\d+"`, addr))
+		}
+		Expect(data).To(ContainSubstring(`<rule action="accept" direction="in">`))
+
+		By("not matching the MAC address of network interfaces without one")
+		data, err = Filter("machine", "nic", "", []string{"fd00::1"}).Marshal()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(ContainSubstring(`<ipv6 srcipaddr="fd00::1" srcipmask="128">`))
+		Expect(data).NotTo(ContainSubstring("srcmacaddr"))
+	})
+})
@@ -0,0 +1,268 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package pci manages the PCI topology of the libvirt domains. Devices are plugged into pcie-root-ports or
+// the downstream ports of a pcie-switch, each of which provides a single slot on its own bus.
+// Ref: https://libvirt.org/pci-hotplug.html#x86_64-q35
+package pci
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"libvirt.org/go/libvirtxml"
+)
+
+const (
+	ControllerType = "pci"
+	RootModel      = "pcie-root"
+	RootPortModel  = "pcie-root-port"
+
+	SwitchUpstreamPortModel   = "pcie-switch-upstream-port"
+	SwitchDownstreamPortModel = "pcie-switch-downstream-port"
+
+	// MaxSwitchDownstreamPorts is the number of slots of the bus of a switch upstream port.
+	MaxSwitchDownstreamPorts = 32
+	// maxControllers is the number of PCI buses, each controller provides one.
+	maxControllers = 256
+)
+
+var ErrNoFreeRootPort = errors.New("no free pcie-root-port")
+
+// Layout is the PCI controller layout of a new domain.
+type Layout struct {
+	// RootPorts is the number of pcie-root-ports.
+	RootPorts int
+	// SwitchDownstreamPorts is the number of downstream ports of a pcie-switch plugged into one of the root
+	// ports. Zero means no switch is added.
+	SwitchDownstreamPorts int
+}
+
+// Validate checks that the layout fits into the PCI buses of a domain.
+func (l Layout) Validate() error {
+	if l.RootPorts < 1 {
+		return fmt.Errorf("at least one root port is required")
+	}
+	if l.SwitchDownstreamPorts < 0 || l.SwitchDownstreamPorts > MaxSwitchDownstreamPorts {
+		return fmt.Errorf("switch downstream ports must be between 0 and %d", MaxSwitchDownstreamPorts)
+	}
+
+	// The pcie-root, the root ports, and the upstream and downstream ports of the switch each use an index.
+	controllers := 1 + l.RootPorts
+	if l.SwitchDownstreamPorts > 0 {
+		controllers += 1 + l.SwitchDownstreamPorts
+	}
+	if controllers > maxControllers {
+		return fmt.Errorf("layout requires %d controllers, at most %d are supported", controllers, maxControllers)
+	}
+	return nil
+}
+
+// ParseLayout parses a layout of the form <root-ports>[+<switch-downstream-ports>], e.g. 16+32 for 16 root
+// ports, one of which hosts a switch with 32 downstream ports.
+func ParseLayout(s string) (Layout, error) {
+	rootPorts, switchPorts, hasSwitch := strings.Cut(s, "+")
+
+	var (
+		layout Layout
+		err    error
+	)
+	if layout.RootPorts, err = strconv.Atoi(rootPorts); err != nil {
+		return Layout{}, fmt.Errorf("invalid root ports %q: %w", rootPorts, err)
+	}
+	if hasSwitch {
+		if layout.SwitchDownstreamPorts, err = strconv.Atoi(switchPorts); err != nil {
+			return Layout{}, fmt.Errorf("invalid switch downstream ports %q: %w", switchPorts, err)
+		}
+	}
+	if err := layout.Validate(); err != nil {
+		return Layout{}, err
+	}
+	return layout, nil
+}
+
+// Topology tracks the ports of a domain devices can be plugged into and the buses used by its devices.
+type Topology struct {
+	domain *libvirtxml.Domain
+	ports  []uint
+	used   map[uint]bool
+}
+
+// ForDomain returns the topology of the domain. Only ports with an index are taken into account, which is
+// always the case for the description of a defined domain.
+func ForDomain(domain *libvirtxml.Domain) (*Topology, error) {
+	t := &Topology{
+		domain: domain,
+		used:   make(map[uint]bool),
+	}
+	if domain.Devices == nil {
+		return t, nil
+	}
+
+	for _, controller := range domain.Devices.Controllers {
+		if controller.Type == ControllerType && isPort(controller.Model) && controller.Index != nil {
+			t.ports = append(t.ports, *controller.Index)
+		}
+	}
+	slices.Sort(t.ports)
+
+	buses, err := usedBuses(domain.Devices)
+	if err != nil {
+		return nil, err
+	}
+	for _, bus := range buses {
+		t.used[bus] = true
+	}
+	return t, nil
+}
+
+// isPort reports whether devices can be plugged into controllers of the model.
+func isPort(model string) bool {
+	return model == RootPortModel || model == SwitchDownstreamPortModel
+}
+
+// usedBuses returns the buses of the pci addresses of all devices.
+func usedBuses(devices *libvirtxml.DomainDeviceList) ([]uint, error) {
+	data, err := xml.Marshal(devices)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling domain devices: %w", err)
+	}
+
+	var (
+		buses   []uint
+		decoder = xml.NewDecoder(bytes.NewReader(data))
+	)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return buses, nil
+			}
+			return nil, fmt.Errorf("error decoding domain devices: %w", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "address" || attr(start, "type") != "pci" {
+			continue
+		}
+		bus, err := strconv.ParseUint(attr(start, "bus"), 0, 32)
+		if err != nil {
+			continue
+		}
+		buses = append(buses, uint(bus))
+	}
+}
+
+func attr(start xml.StartElement, name string) string {
+	for _, a := range start.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// FreeRootPorts returns the number of root ports and switch downstream ports without device.
+func (t *Topology) FreeRootPorts() int {
+	free := 0
+	for _, port := range t.ports {
+		if !t.used[port] {
+			free++
+		}
+	}
+	return free
+}
+
+// AddRootPort adds a root port to the domain description and returns it.
+func (t *Topology) AddRootPort() libvirtxml.DomainController {
+	controller := t.addController(RootPortModel, nil)
+	t.ports = append(t.ports, *controller.Index)
+	return controller
+}
+
+// AddSwitch plugs a pcie-switch with the given number of downstream ports into a free root port and returns
+// the added controllers, the upstream port first.
+func (t *Topology) AddSwitch(downstreamPorts int) ([]libvirtxml.DomainController, error) {
+	if downstreamPorts < 1 || downstreamPorts > MaxSwitchDownstreamPorts {
+		return nil, fmt.Errorf("switch downstream ports must be between 1 and %d", MaxSwitchDownstreamPorts)
+	}
+
+	addr, err := t.Assign()
+	if err != nil {
+		return nil, err
+	}
+	upstream := t.addController(SwitchUpstreamPortModel, addr)
+	controllers := []libvirtxml.DomainController{upstream}
+
+	for slot := uint(0); slot < uint(downstreamPorts); slot++ {
+		addr := address(*upstream.Index)
+		addr.PCI.Slot = &slot
+		downstream := t.addController(SwitchDownstreamPortModel, addr)
+		t.ports = append(t.ports, *downstream.Index)
+		controllers = append(controllers, downstream)
+	}
+	return controllers, nil
+}
+
+// addController adds a controller with the next free index to the domain description and returns it.
+func (t *Topology) addController(model string, addr *libvirtxml.DomainAddress) libvirtxml.DomainController {
+	// Index 0 is the pcie-root.
+	index := uint(1)
+	if t.domain.Devices != nil {
+		for _, controller := range t.domain.Devices.Controllers {
+			if controller.Type == ControllerType && controller.Index != nil && *controller.Index >= index {
+				index = *controller.Index + 1
+			}
+		}
+	} else {
+		t.domain.Devices = &libvirtxml.DomainDeviceList{}
+	}
+
+	controller := libvirtxml.DomainController{
+		Type:    ControllerType,
+		Model:   model,
+		Index:   &index,
+		Address: addr,
+	}
+	t.domain.Devices.Controllers = append(t.domain.Devices.Controllers, controller)
+	return controller
+}
+
+// Assign assigns a free port and returns the address of the device plugged into it.
+func (t *Topology) Assign() (*libvirtxml.DomainAddress, error) {
+	for _, port := range t.ports {
+		if t.used[port] {
+			continue
+		}
+
+		t.used[port] = true
+		return address(port), nil
+	}
+	return nil, ErrNoFreeRootPort
+}
+
+// Release frees the port of the device with the given address.
+func (t *Topology) Release(addr *libvirtxml.DomainAddress) {
+	if addr == nil || addr.PCI == nil || addr.PCI.Bus == nil {
+		return
+	}
+	delete(t.used, *addr.PCI.Bus)
+}
+
+func address(bus uint) *libvirtxml.DomainAddress {
+	var domain, slot, function uint
+	return &libvirtxml.DomainAddress{
+		PCI: &libvirtxml.DomainAddressPCI{
+			Domain:   &domain,
+			Bus:      &bus,
+			Slot:     &slot,
+			Function: &function,
+		},
+	}
+}
@@ -0,0 +1,136 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package pci_test
+
+import (
+	. "github.com/ironcore-dev/libvirt-provider/internal/libvirt/pci"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/utils/ptr"
+	"libvirt.org/go/libvirtxml"
+)
+
+func pciAddress(bus uint) *libvirtxml.DomainAddress {
+	return &libvirtxml.DomainAddress{
+		PCI: &libvirtxml.DomainAddressPCI{
+			Domain:   ptr.To[uint](0),
+			Bus:      ptr.To(bus),
+			Slot:     ptr.To[uint](0),
+			Function: ptr.To[uint](0),
+		},
+	}
+}
+
+var _ = Describe("Topology", func() {
+	var domain *libvirtxml.Domain
+
+	BeforeEach(func() {
+		domain = &libvirtxml.Domain{
+			Devices: &libvirtxml.DomainDeviceList{
+				Controllers: []libvirtxml.DomainController{
+					{Type: ControllerType, Model: RootModel, Index: ptr.To[uint](0)},
+					{Type: ControllerType, Model: RootPortModel, Index: ptr.To[uint](1), Address: pciAddress(0)},
+					{Type: ControllerType, Model: RootPortModel, Index: ptr.To[uint](2), Address: pciAddress(0)},
+					{Type: ControllerType, Model: RootPortModel, Index: ptr.To[uint](3), Address: pciAddress(0)},
+				},
+				Disks: []libvirtxml.DomainDisk{
+					{Address: pciAddress(1)},
+				},
+				Hostdevs: []libvirtxml.DomainHostdev{
+					{
+						SubsysPCI: &libvirtxml.DomainHostdevSubsysPCI{
+							Source: &libvirtxml.DomainHostdevSubsysPCISource{
+								Address: &libvirtxml.DomainAddressPCI{Bus: ptr.To[uint](2)},
+							},
+						},
+						Address: pciAddress(3),
+					},
+				},
+			},
+		}
+	})
+
+	It("should track the used root ports", func() {
+		topology, err := ForDomain(domain)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(topology.FreeRootPorts()).To(Equal(1))
+
+		Expect(topology.Assign()).To(Equal(pciAddress(2)))
+		_, err = topology.Assign()
+		Expect(err).To(MatchError(ErrNoFreeRootPort))
+
+		topology.Release(pciAddress(1))
+		Expect(topology.Assign()).To(Equal(pciAddress(1)))
+	})
+
+	It("should add root ports", func() {
+		topology, err := ForDomain(domain)
+		Expect(err).NotTo(HaveOccurred())
+
+		controller := topology.AddRootPort()
+		Expect(controller.Index).To(HaveValue(Equal(uint(4))))
+		Expect(domain.Devices.Controllers).To(ContainElement(controller))
+		Expect(topology.FreeRootPorts()).To(Equal(2))
+
+		By("reading the topology of the extended domain")
+		topology, err = ForDomain(domain)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(topology.FreeRootPorts()).To(Equal(2))
+	})
+
+	It("should add root ports to domains without devices", func() {
+		topology, err := ForDomain(&libvirtxml.Domain{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(topology.AddRootPort().Index).To(HaveValue(Equal(uint(1))))
+		Expect(topology.Assign()).To(Equal(pciAddress(1)))
+	})
+
+	It("should add switches", func() {
+		topology, err := ForDomain(domain)
+		Expect(err).NotTo(HaveOccurred())
+
+		controllers, err := topology.AddSwitch(2)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(controllers).To(HaveLen(3))
+		Expect(controllers[0].Model).To(Equal(SwitchUpstreamPortModel))
+		Expect(controllers[0].Address).To(Equal(pciAddress(2)))
+		downstream := pciAddress(4)
+		downstream.PCI.Slot = ptr.To[uint](1)
+		Expect(controllers[2].Model).To(Equal(SwitchDownstreamPortModel))
+		Expect(controllers[2].Address).To(Equal(downstream))
+		Expect(topology.FreeRootPorts()).To(Equal(2))
+
+		By("reading the topology of the extended domain")
+		topology, err = ForDomain(domain)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(topology.FreeRootPorts()).To(Equal(2))
+		Expect(topology.Assign()).To(Equal(pciAddress(5)))
+		Expect(topology.Assign()).To(Equal(pciAddress(6)))
+		_, err = topology.Assign()
+		Expect(err).To(MatchError(ErrNoFreeRootPort))
+	})
+
+	It("should not add switches without free root port", func() {
+		topology, err := ForDomain(domain)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(topology.Assign()).To(Equal(pciAddress(2)))
+
+		_, err = topology.AddSwitch(2)
+		Expect(err).To(MatchError(ErrNoFreeRootPort))
+	})
+})
+
+var _ = Describe("Layout", func() {
+	It("should parse layouts", func() {
+		Expect(ParseLayout("30")).To(Equal(Layout{RootPorts: 30}))
+		Expect(ParseLayout("16+32")).To(Equal(Layout{RootPorts: 16, SwitchDownstreamPorts: 32}))
+	})
+
+	It("should reject invalid layouts", func() {
+		for _, layout := range []string{"", "0", "abc", "16+", "16+33", "255+1", "-1"} {
+			_, err := ParseLayout(layout)
+			Expect(err).To(HaveOccurred(), layout)
+		}
+	})
+})
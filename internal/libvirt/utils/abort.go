@@ -0,0 +1,68 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package utils
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/digitalocean/go-libvirt"
+	"github.com/digitalocean/go-libvirt/socket"
+)
+
+// abortableDialers are the dialers of the connections returned by GetLibvirt by connection.
+var abortableDialers sync.Map
+
+// abortableDialer remembers the last dialed socket, so it can be closed without a round trip to the daemon.
+type abortableDialer struct {
+	socket.Dialer
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (d *abortableDialer) Dial() (net.Conn, error) {
+	conn, err := d.Dialer.Dial()
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.conn = conn
+	return conn, nil
+}
+
+func (d *abortableDialer) close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.conn == nil {
+		return nil
+	}
+	return d.conn.Close()
+}
+
+// newAbortableLibvirt returns a connection that can be aborted by Abort.
+func newAbortableLibvirt(dialer socket.Dialer) *libvirt.Libvirt {
+	d := &abortableDialer{Dialer: dialer}
+	lv := libvirt.NewWithDialer(d)
+	abortableDialers.Store(lv, d)
+	return lv
+}
+
+// Abort closes the socket of the connection, so its pending calls fail instead of waiting for a hung daemon.
+// Unlike Disconnect, it doesn't wait for the daemon. The connection stays disconnected until it is redialed.
+func Abort(lv *libvirt.Libvirt) error {
+	d, ok := abortableDialers.Load(lv)
+	if !ok {
+		return fmt.Errorf("libvirt connection can't be aborted")
+	}
+	return d.(*abortableDialer).close()
+}
+
+// forgetAbortable releases the connection once it is replaced.
+func forgetAbortable(lv *libvirt.Libvirt) {
+	abortableDialers.Delete(lv)
+}
@@ -10,6 +10,8 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/digitalocean/go-libvirt"
@@ -22,61 +24,108 @@ import (
 )
 
 const (
-	defaultSocket = "/var/run/libvirt/libvirt-sock"
+	// systemSocketDir is the directory of the sockets of the libvirt system daemons.
+	systemSocketDir = "/var/run/libvirt"
 )
 
 var (
 	log = ctrl.Log.WithName("libvirtutils")
 )
 
-func wellKnownSocketPaths() []string {
-	paths := []string{defaultSocket}
+// socketCandidate is a well known libvirt socket and the URI of the daemon listening on it.
+type socketCandidate struct {
+	path string
+	uri  libvirt.ConnectURI
+}
+
+func (c socketCandidate) String() string {
+	return fmt.Sprintf("%s (%s)", c.path, c.uri)
+}
+
+// wellKnownSockets returns the well known libvirt sockets in the order they are probed. libvirt-sock, which is
+// served by the monolithic libvirtd or proxied to all modular daemons by virtproxyd, is preferred, system daemons
+// over session daemons. The socket of the modular virtqemud is only a last resort: it only serves the qemu driver,
+// so e.g. defining secrets or network filters fails on it.
+func wellKnownSockets() []socketCandidate {
+	sockets := []socketCandidate{
+		{path: filepath.Join(systemSocketDir, "libvirt-sock"), uri: libvirt.QEMUSystem},
+	}
+
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir != "" {
+		sockets = append(sockets, socketCandidate{path: filepath.Join(runtimeDir, "libvirt", "libvirt-sock"), uri: libvirt.QEMUSession})
+	}
 
 	homeDir, err := os.UserHomeDir()
 	if err == nil {
-		paths = append(paths, filepath.Join(homeDir, ".cache", "libvirt", "libvirt-sock"))
+		sockets = append(sockets, socketCandidate{path: filepath.Join(homeDir, ".cache", "libvirt", "libvirt-sock"), uri: libvirt.QEMUSession})
 	}
 
 	if runtime.GOOS == "darwin" && runtime.GOARCH == "arm64" {
-		paths = append(paths, filepath.Join("/", "opt", "homebrew", "var", "run", "libvirt", "libvirt-sock"))
+		sockets = append(sockets, socketCandidate{path: filepath.Join("/", "opt", "homebrew", "var", "run", "libvirt", "libvirt-sock"), uri: libvirt.QEMUSystem})
 	}
 
-	return paths
+	sockets = append(sockets, socketCandidate{path: filepath.Join(systemSocketDir, "virtqemud-sock"), uri: libvirt.QEMUSystem})
+	if runtimeDir != "" {
+		sockets = append(sockets, socketCandidate{path: filepath.Join(runtimeDir, "libvirt", "virtqemud-sock"), uri: libvirt.QEMUSession})
+	}
+
+	return sockets
 }
 
-func GetDialer(socket, address string) (socket.Dialer, error) {
+// detectSocket returns the first of the candidate sockets that exists. Its error lists the tried sockets.
+func detectSocket(candidates []socketCandidate) (socketCandidate, error) {
+	log.V(1).Info("Probing well known sockets", "Candidates", candidates)
+
+	var tried []string
+	for _, candidate := range candidates {
+		stat, err := os.Stat(candidate.path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			tried = append(tried, fmt.Sprintf("%s: not found", candidate.path))
+		case err != nil:
+			tried = append(tried, fmt.Sprintf("%s: %v", candidate.path, err))
+		case stat.Mode()&os.ModeSocket == 0:
+			tried = append(tried, fmt.Sprintf("%s: not a socket", candidate.path))
+		default:
+			log.V(1).Info("Determined socket", "Socket", candidate.path, "URI", candidate.uri)
+			return candidate, nil
+		}
+	}
+	return socketCandidate{}, fmt.Errorf("could not determine libvirt socket, tried: %s", strings.Join(tried, ", "))
+}
+
+// GetDialer returns the dialer of the explicit socket or address, or of the detected well known socket. For
+// detected sockets, it also returns the URI of the daemon listening on it.
+func GetDialer(socket, address string) (socket.Dialer, libvirt.ConnectURI, error) {
 	if socket != "" {
 		log.V(1).Info("Using explicit local socket", "Socket", socket)
-		return dialers.NewLocal(dialers.WithSocket(socket), dialers.WithLocalTimeout(1*time.Second)), nil
+		return dialers.NewLocal(dialers.WithSocket(socket), dialers.WithLocalTimeout(1*time.Second)), "", nil
 	}
 	if address != "" {
 		log.V(1).Info("Using explicit remote socket", "Address", address)
-		return dialers.NewRemote(address), nil
-	}
-
-	wellKnownSocketPaths := wellKnownSocketPaths()
-	log.V(1).Info("Probing well known socket paths", "WellKnownSocketPaths", wellKnownSocketPaths)
-	for _, wellKnownSocketPath := range wellKnownSocketPaths {
-		stat, err := os.Stat(wellKnownSocketPath)
-		if err != nil && !errors.Is(err, os.ErrNotExist) {
-			log.Error(err, "Error checking socket path", "SocketPath", wellKnownSocketPath)
-		} else if err == nil {
-			if (stat.Mode() & os.ModeSocket) != 0 {
-				log.V(1).Info("Determined socket", "Socket", wellKnownSocketPath)
-				return dialers.NewLocal(dialers.WithSocket(wellKnownSocketPath)), nil
-			}
-		}
+		return dialers.NewRemote(address), "", nil
+	}
+
+	candidate, err := detectSocket(wellKnownSockets())
+	if err != nil {
+		return nil, "", err
 	}
-	return nil, fmt.Errorf("could not determine libvirt dialer to use")
+	return dialers.NewLocal(dialers.WithSocket(candidate.path)), candidate.uri, nil
 }
 
-func wellKnownConnectURIs() []libvirt.ConnectURI {
+// wellKnownConnectURIs returns the URIs to probe, the URI of the detected socket after LIBVIRT_DEFAULT_URI.
+func wellKnownConnectURIs(detected libvirt.ConnectURI) []libvirt.ConnectURI {
 	var uris []libvirt.ConnectURI
 	if defaultURI := os.Getenv("LIBVIRT_DEFAULT_URI"); defaultURI != "" {
 		uris = append(uris, libvirt.ConnectURI(defaultURI))
 	}
-
-	uris = append(uris, libvirt.QEMUSystem)
+	if detected != "" && !slices.Contains(uris, detected) {
+		uris = append(uris, detected)
+	}
+	if !slices.Contains(uris, libvirt.QEMUSystem) {
+		uris = append(uris, libvirt.QEMUSystem)
+	}
 	return uris
 }
 
@@ -84,13 +133,14 @@ var (
 	expectedConnectErrorMessageRegex = regexp.MustCompile(`\Qinternal error: unexpected qemu URI path\E|\Qno polkit agent available\E`)
 )
 
-func Connect(lv *libvirt.Libvirt, uri string) error {
+// Connect connects to the explicit uri or probes the well known URIs, preferring the URI of the detected socket.
+func Connect(lv *libvirt.Libvirt, uri string, detected libvirt.ConnectURI) error {
 	if uri != "" {
 		log.V(1).Info("Connecting to explicit uri", "URI", uri)
 		return lv.ConnectToURI(libvirt.ConnectURI(uri))
 	}
 
-	wellKnownConnectURIs := wellKnownConnectURIs()
+	wellKnownConnectURIs := wellKnownConnectURIs(detected)
 	log.V(1).Info("Probing well known connect URIs", "WellKnownConnectURIs", wellKnownConnectURIs)
 	for _, wellKnownConnectURI := range wellKnownConnectURIs {
 		if err := lv.ConnectToURI(wellKnownConnectURI); err != nil {
@@ -107,17 +157,40 @@ func Connect(lv *libvirt.Libvirt, uri string) error {
 		log.V(1).Info("Determined connect uri", "URI", wellKnownConnectURI)
 		return nil
 	}
-	return fmt.Errorf("could not determine connect uri")
+	return fmt.Errorf("could not determine connect uri, tried: %v", wellKnownConnectURIs)
 }
 
+// GetLibvirt connects to libvirt. Remote URIs with ssh or tls transport, e.g. qemu+ssh://user@host/system,
+// are dialed via their transport and can't be combined with a socket or address. The domains reference the disks,
+// ignition and root fs files of the provider by their local paths, so a remote host has to see the provider
+// directory at the same path, e.g. via a shared file system.
 func GetLibvirt(socket, address, uri string) (*libvirt.Libvirt, error) {
-	dialer, err := GetDialer(socket, address)
+	if isRemoteURI(uri) {
+		if socket != "" || address != "" {
+			return nil, fmt.Errorf("remote libvirt uri can't be combined with a socket or address")
+		}
+
+		dialer, driverURI, err := remoteDialer(uri)
+		if err != nil {
+			return nil, err
+		}
+		log.V(1).Info("Using remote uri", "URI", uri)
+		lv := newAbortableLibvirt(dialer)
+		if err := Connect(lv, string(driverURI), ""); err != nil {
+			forgetAbortable(lv)
+			return nil, err
+		}
+		return lv, nil
+	}
+
+	dialer, detected, err := GetDialer(socket, address)
 	if err != nil {
 		return nil, err
 	}
 
-	lv := libvirt.NewWithDialer(dialer)
-	if err := Connect(lv, uri); err != nil {
+	lv := newAbortableLibvirt(dialer)
+	if err := Connect(lv, uri, detected); err != nil {
+		forgetAbortable(lv)
 		return nil, err
 	}
 	return lv, nil
@@ -153,6 +226,10 @@ func UUIDStringToBytes(uid string) libvirt.UUID {
 	return lUUID
 }
 
+func UUIDBytesToString(lUUID libvirt.UUID) string {
+	return uuid.UUID(lUUID).String()
+}
+
 func ApplySecret(lv *libvirt.Libvirt, secret *libvirtxml.Secret, value []byte) error {
 	data, err := secret.Marshal()
 	if err != nil {
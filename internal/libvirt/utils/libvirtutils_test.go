@@ -0,0 +1,85 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package utils
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/digitalocean/go-libvirt"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Libvirt utils", func() {
+	Describe("wellKnownSockets", func() {
+		It("should prefer libvirt-sock and probe virtqemud-sock last", func() {
+			runtimeDir := GinkgoT().TempDir()
+			GinkgoT().Setenv("XDG_RUNTIME_DIR", runtimeDir)
+
+			sockets := wellKnownSockets()
+			Expect(sockets[0]).To(Equal(socketCandidate{path: "/var/run/libvirt/libvirt-sock", uri: libvirt.QEMUSystem}))
+			Expect(sockets[1]).To(Equal(socketCandidate{path: filepath.Join(runtimeDir, "libvirt", "libvirt-sock"), uri: libvirt.QEMUSession}))
+			Expect(sockets[len(sockets)-2:]).To(Equal([]socketCandidate{
+				{path: "/var/run/libvirt/virtqemud-sock", uri: libvirt.QEMUSystem},
+				{path: filepath.Join(runtimeDir, "libvirt", "virtqemud-sock"), uri: libvirt.QEMUSession},
+			}))
+		})
+	})
+
+	Describe("detectSocket", func() {
+		listen := func(path string) {
+			listener, err := net.Listen("unix", path)
+			Expect(err).NotTo(HaveOccurred())
+			DeferCleanup(listener.Close)
+		}
+
+		It("should return the first existing socket", func() {
+			dir := GinkgoT().TempDir()
+			proxy := socketCandidate{path: filepath.Join(dir, "libvirt-sock"), uri: libvirt.QEMUSystem}
+			qemu := socketCandidate{path: filepath.Join(dir, "virtqemud-sock"), uri: libvirt.QEMUSystem}
+
+			listen(qemu.path)
+			Expect(detectSocket([]socketCandidate{proxy, qemu})).To(Equal(qemu))
+
+			listen(proxy.path)
+			Expect(detectSocket([]socketCandidate{proxy, qemu})).To(Equal(proxy))
+		})
+
+		It("should list the tried sockets if none exists", func() {
+			dir := GinkgoT().TempDir()
+			file := filepath.Join(dir, "file")
+			Expect(os.WriteFile(file, nil, 0644)).To(Succeed())
+
+			_, err := detectSocket([]socketCandidate{
+				{path: filepath.Join(dir, "missing")},
+				{path: file},
+			})
+			Expect(err).To(MatchError(SatisfyAll(
+				ContainSubstring("missing: not found"),
+				ContainSubstring("file: not a socket"),
+			)))
+		})
+	})
+
+	Describe("wellKnownConnectURIs", func() {
+		It("should probe the default URI before the URI of the detected socket", func() {
+			GinkgoT().Setenv("LIBVIRT_DEFAULT_URI", "qemu:///custom")
+
+			Expect(wellKnownConnectURIs(libvirt.QEMUSession)).To(Equal([]libvirt.ConnectURI{
+				"qemu:///custom",
+				libvirt.QEMUSession,
+				libvirt.QEMUSystem,
+			}))
+		})
+
+		It("should not probe URIs twice", func() {
+			GinkgoT().Setenv("LIBVIRT_DEFAULT_URI", "")
+
+			Expect(wellKnownConnectURIs(libvirt.QEMUSystem)).To(Equal([]libvirt.ConnectURI{libvirt.QEMUSystem}))
+			Expect(wellKnownConnectURIs("")).To(Equal([]libvirt.ConnectURI{libvirt.QEMUSystem}))
+		})
+	})
+})
@@ -0,0 +1,154 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/digitalocean/go-libvirt"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// Pool is a pool of libvirt connections handed out round-robin, so concurrent workers don't serialize their
+// calls on a single connection. Disconnected connections are skipped and redialed by Check.
+type Pool struct {
+	dial func() (*libvirt.Libvirt, error)
+	next atomic.Uint64
+
+	mu    sync.RWMutex
+	conns []*libvirt.Libvirt
+
+	// degradedSince is the time less than a quorum of the connections was found connected first, zero while a
+	// quorum is connected.
+	degradedMu    sync.Mutex
+	degradedSince time.Time
+}
+
+// NewPool dials size connections with the dial function.
+func NewPool(size int, dial func() (*libvirt.Libvirt, error)) (*Pool, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("pool size must be positive, got %d", size)
+	}
+
+	p := &Pool{
+		dial:  dial,
+		conns: make([]*libvirt.Libvirt, 0, size),
+	}
+	for i := 0; i < size; i++ {
+		conn, err := dial()
+		if err != nil {
+			_ = p.Close()
+			return nil, fmt.Errorf("error dialing connection %d: %w", i, err)
+		}
+		p.conns = append(p.conns, conn)
+	}
+	return p, nil
+}
+
+// Get returns the next connected connection of the pool. If no connection is connected, the next connection is
+// returned and its calls fail until it is redialed.
+func (p *Pool) Get() *libvirt.Libvirt {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	n := uint64(len(p.conns))
+	start := p.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		if conn := p.conns[(start+i)%n]; conn.IsConnected() {
+			return conn
+		}
+	}
+	return p.conns[start%n]
+}
+
+// Healthy returns an error if less than a quorum, the majority, of the connections of the pool has been connected
+// for longer than the grace period. Disconnects are redialed by Check in the meantime, so neither a single
+// disconnect nor a restart of libvirt fails the pool right away.
+func (p *Pool) Healthy(grace time.Duration) error {
+	p.mu.RLock()
+	var connected int
+	for _, conn := range p.conns {
+		if conn.IsConnected() {
+			connected++
+		}
+	}
+	total := len(p.conns)
+	p.mu.RUnlock()
+
+	p.degradedMu.Lock()
+	defer p.degradedMu.Unlock()
+
+	if connected > total/2 {
+		p.degradedSince = time.Time{}
+		return nil
+	}
+	if p.degradedSince.IsZero() {
+		p.degradedSince = time.Now()
+	}
+	if degraded := time.Since(p.degradedSince); degraded >= grace {
+		return fmt.Errorf("%d of %d libvirt connections disconnected for %s", total-connected, total, degraded.Round(time.Second))
+	}
+	return nil
+}
+
+// Check redials the disconnected connections of the pool, including aborted ones. Callers still holding a
+// replaced connection get errors from it and retry with another one.
+func (p *Pool) Check() error {
+	p.mu.RLock()
+	var disconnected []int
+	for i, conn := range p.conns {
+		if !conn.IsConnected() {
+			disconnected = append(disconnected, i)
+		}
+	}
+	p.mu.RUnlock()
+
+	var errs []error
+	for _, i := range disconnected {
+		conn, err := p.dial()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error redialing connection %d: %w", i, err))
+			continue
+		}
+
+		p.mu.Lock()
+		forgetAbortable(p.conns[i])
+		p.conns[i] = conn
+		p.mu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// Run checks the connections of the pool in the interval until the context is done.
+func (p *Pool) Run(ctx context.Context, log logr.Logger, interval time.Duration) {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := p.Check(); err != nil {
+			log.Error(err, "failed to redial libvirt connections")
+		}
+	}, interval)
+}
+
+// Close closes all connections of the pool.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var errs []error
+	for _, conn := range p.conns {
+		forgetAbortable(conn)
+		if !conn.IsConnected() {
+			continue
+		}
+		if err := conn.ConnectClose(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
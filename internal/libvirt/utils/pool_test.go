@@ -0,0 +1,83 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package utils
+
+import (
+	"errors"
+	"time"
+
+	"github.com/digitalocean/go-libvirt"
+	"github.com/digitalocean/go-libvirt/libvirttest"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pool", func() {
+	var (
+		dialErr error
+		pool    *Pool
+	)
+
+	dial := func() (*libvirt.Libvirt, error) {
+		if dialErr != nil {
+			return nil, dialErr
+		}
+		lv := libvirt.NewWithDialer(libvirttest.New())
+		if err := lv.Connect(); err != nil {
+			return nil, err
+		}
+		return lv, nil
+	}
+
+	disconnect := func(n int) {
+		for _, conn := range pool.conns[:n] {
+			Expect(conn.Disconnect()).To(Succeed())
+		}
+	}
+
+	BeforeEach(func() {
+		dialErr = nil
+		var err error
+		pool, err = NewPool(3, dial)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(pool.Close)
+	})
+
+	It("should skip disconnected connections", func() {
+		disconnect(2)
+		for range 3 {
+			Expect(pool.Get()).To(BeIdenticalTo(pool.conns[2]))
+		}
+	})
+
+	It("should stay healthy while a quorum is connected", func() {
+		disconnect(1)
+		Expect(pool.Healthy(0)).To(Succeed())
+	})
+
+	It("should fail once the quorum is lost for longer than the grace period", func() {
+		disconnect(2)
+		Expect(pool.Healthy(time.Hour)).To(Succeed())
+		Expect(pool.Healthy(0)).To(MatchError(ContainSubstring("2 of 3 libvirt connections disconnected")))
+	})
+
+	It("should redial disconnected connections", func() {
+		disconnect(3)
+		Expect(pool.Healthy(time.Hour)).To(Succeed())
+
+		By("failing to redial")
+		dialErr = errors.New("libvirt is down")
+		Expect(pool.Check()).To(MatchError(ContainSubstring("libvirt is down")))
+		Expect(pool.Healthy(0)).NotTo(Succeed())
+
+		By("redialing once libvirt is back")
+		dialErr = nil
+		Expect(pool.Check()).To(Succeed())
+		Expect(pool.Healthy(0)).To(Succeed())
+
+		By("starting the grace period over")
+		disconnect(2)
+		Expect(pool.Healthy(time.Hour)).To(Succeed())
+	})
+})
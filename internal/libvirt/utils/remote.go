@@ -0,0 +1,81 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/digitalocean/go-libvirt"
+	"github.com/digitalocean/go-libvirt/socket"
+	"github.com/digitalocean/go-libvirt/socket/dialers"
+)
+
+const (
+	transportSSH = "ssh"
+	transportTLS = "tls"
+)
+
+// isRemoteURI reports whether the URI connects to a remote host via a transport, e.g. qemu+ssh://host/system.
+func isRemoteURI(uri string) bool {
+	_, transport, ok := strings.Cut(strings.SplitN(uri, "://", 2)[0], "+")
+	return ok && (transport == transportSSH || transport == transportTLS)
+}
+
+// remoteDialer returns the dialer of the remote URI and the URI of the driver to connect to once dialed. It
+// supports the libvirt URI parameters of the transports:
+//   - ssh: keyfile, known_hosts, socket and no_verify=1. Keys are authenticated via the SSH agent or the key
+//     file, by default ~/.ssh/id_rsa.
+//   - tls: pkipath holding the client certificate, key and CA, and no_verify=1.
+func remoteDialer(uri string) (socket.Dialer, libvirt.ConnectURI, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, "", fmt.Errorf("error parsing libvirt uri: %w", err)
+	}
+
+	driver, transport, _ := strings.Cut(u.Scheme, "+")
+	if u.Hostname() == "" {
+		return nil, "", fmt.Errorf("libvirt uri %s has no host", uri)
+	}
+	driverURI := libvirt.ConnectURI(fmt.Sprintf("%s://%s", driver, u.EscapedPath()))
+	query := u.Query()
+	noVerify := query.Get("no_verify") == "1"
+
+	switch transport {
+	case transportSSH:
+		opts := []dialers.SSHOption{
+			dialers.UseSSHUsername(u.User.Username()),
+			dialers.UseSSHPort(u.Port()),
+			dialers.WithSSHAuthMethods((&dialers.SSHAuthMethods{}).Agent().PrivKey()),
+		}
+		if keyFile := query.Get("keyfile"); keyFile != "" {
+			opts = append(opts, dialers.UseKeyFile(keyFile))
+		}
+		if knownHosts := query.Get("known_hosts"); knownHosts != "" {
+			opts = append(opts, dialers.UseKnownHostsFile(knownHosts))
+		}
+		if remoteSocket := query.Get("socket"); remoteSocket != "" {
+			opts = append(opts, dialers.WithRemoteSocket(remoteSocket))
+		}
+		if noVerify {
+			opts = append(opts, dialers.WithInsecureIgnoreHostKey())
+		}
+		return dialers.NewSSH(u.Hostname(), opts...), driverURI, nil
+	case transportTLS:
+		var opts []dialers.TLSOption
+		if port := u.Port(); port != "" {
+			opts = append(opts, dialers.UseTLSPort(port))
+		}
+		if pkiPath := query.Get("pkipath"); pkiPath != "" {
+			opts = append(opts, dialers.UsePKIPath(pkiPath))
+		}
+		if noVerify {
+			opts = append(opts, dialers.WithInsecureNoVerify())
+		}
+		return dialers.NewTLS(u.Hostname(), opts...), driverURI, nil
+	default:
+		return nil, "", fmt.Errorf("unsupported libvirt transport %q", transport)
+	}
+}
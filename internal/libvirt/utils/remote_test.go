@@ -0,0 +1,57 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package utils
+
+import (
+	"github.com/digitalocean/go-libvirt"
+	"github.com/digitalocean/go-libvirt/socket/dialers"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Remote", func() {
+	DescribeTable("isRemoteURI",
+		func(uri string, remote bool) {
+			Expect(isRemoteURI(uri)).To(Equal(remote))
+		},
+		Entry("ssh", "qemu+ssh://user@host/system", true),
+		Entry("tls", "qemu+tls://host/system", true),
+		Entry("local system", "qemu:///system", false),
+		Entry("local session", "qemu:///session", false),
+		Entry("unix transport", "qemu+unix:///system", false),
+		Entry("tcp transport", "qemu+tcp://host/system", false),
+		Entry("empty", "", false),
+	)
+
+	Describe("remoteDialer", func() {
+		It("should dial ssh and connect to the driver uri", func() {
+			dialer, driverURI, err := remoteDialer("qemu+ssh://user@host:2222/system?keyfile=/key&known_hosts=/known_hosts&socket=/run/libvirt/libvirt-sock")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dialer).To(BeAssignableToTypeOf(&dialers.SSH{}))
+			Expect(driverURI).To(Equal(libvirt.ConnectURI("qemu:///system")))
+		})
+
+		It("should dial tls and connect to the driver uri", func() {
+			dialer, driverURI, err := remoteDialer("qemu+tls://host:16514/session?pkipath=/pki&no_verify=1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dialer).To(BeAssignableToTypeOf(&dialers.TLS{}))
+			Expect(driverURI).To(Equal(libvirt.ConnectURI("qemu:///session")))
+		})
+
+		It("should reject uris without host", func() {
+			_, _, err := remoteDialer("qemu+ssh:///system")
+			Expect(err).To(MatchError(ContainSubstring("has no host")))
+		})
+
+		It("should reject unsupported transports", func() {
+			_, _, err := remoteDialer("qemu+tcp://host/system")
+			Expect(err).To(MatchError(ContainSubstring(`unsupported libvirt transport "tcp"`)))
+		})
+	})
+
+	It("should not combine a remote uri with a socket or address", func() {
+		_, err := GetLibvirt("/run/libvirt/libvirt-sock", "", "qemu+ssh://host/system")
+		Expect(err).To(MatchError(ContainSubstring("can't be combined with a socket or address")))
+	})
+})
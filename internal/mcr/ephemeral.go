@@ -0,0 +1,106 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package mcr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"golang.org/x/sys/unix"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// ErrEphemeralStorageExhausted is returned when reserving more ephemeral storage than available.
+var ErrEphemeralStorageExhausted = errors.New("ephemeral storage exhausted")
+
+// EphemeralStorage is the source of the ephemeral-storage resource. It accounts the local disk space allocated to
+// machines, their empty disks and root fs copies, against the file system of the machines directory. Allocations
+// count in full even before the disks are written, so machines can't outgrow the file system later on.
+type EphemeralStorage struct {
+	// Dir is a directory on the file system the machine disks are stored on.
+	Dir string
+	// Reserved is the disk space kept free for the host.
+	Reserved int64
+	// RootFSBytes is the disk space allocated to the root fs of machines not sharing the root fs of their image.
+	RootFSBytes int64
+	// ListMachines lists the machines whose disks are allocated.
+	ListMachines func(ctx context.Context) ([]*api.Machine, error)
+
+	// mu serializes reservations until they are persisted in the machines.
+	mu sync.Mutex
+}
+
+func (s *EphemeralStorage) Validate(quantity resource.Quantity) error {
+	if quantity.Sign() <= 0 {
+		return fmt.Errorf("quantity %s has to be positive", quantity.String())
+	}
+	return nil
+}
+
+// Available returns the disk space neither allocated to machines nor reserved. It never exceeds the free space of
+// the file system, which may be used otherwise as well.
+func (s *EphemeralStorage) Available(ctx context.Context) (resource.Quantity, error) {
+	available, err := s.available(ctx)
+	if err != nil {
+		return resource.Quantity{}, err
+	}
+	return *resource.NewQuantity(available, resource.BinarySI), nil
+}
+
+func (s *EphemeralStorage) available(ctx context.Context) (int64, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(s.Dir, &stat); err != nil {
+		return 0, fmt.Errorf("error getting file system stats of %s: %w", s.Dir, err)
+	}
+
+	machines, err := s.ListMachines(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error listing machines: %w", err)
+	}
+	var allocated int64
+	for _, machine := range machines {
+		allocated += s.MachineBytes(&machine.Spec)
+	}
+
+	size := int64(stat.Blocks) * stat.Bsize
+	free := int64(stat.Bavail) * stat.Bsize
+	return max(min(size-allocated, free)-s.Reserved, 0), nil
+}
+
+// MachineBytes returns the disk space allocated to a machine with the given spec.
+func (s *EphemeralStorage) MachineBytes(spec *api.MachineSpec) int64 {
+	var bytes int64
+	for _, volume := range spec.Volumes {
+		if volume.EmptyDisk != nil {
+			bytes += volume.EmptyDisk.Size
+		}
+	}
+	if spec.Image != nil && *spec.Image != "" && spec.RootFSMode != api.RootFSModeShared {
+		bytes += s.RootFSBytes
+	}
+	return bytes
+}
+
+// Reserve reserves the given disk space, failing with ErrEphemeralStorageExhausted if it isn't available. Further
+// reservations wait until the returned func is called, once the allocation is persisted in the machine.
+func (s *EphemeralStorage) Reserve(ctx context.Context, bytes int64) (func(), error) {
+	if bytes <= 0 {
+		return func() {}, nil
+	}
+
+	s.mu.Lock()
+	available, err := s.available(ctx)
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	if bytes > available {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("%w: %d bytes requested, %d bytes available", ErrEphemeralStorageExhausted, bytes, available)
+	}
+	return s.mu.Unlock, nil
+}
@@ -0,0 +1,67 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package mcr_test
+
+import (
+	"context"
+	"math"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/mcr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/utils/ptr"
+)
+
+var _ = Describe("EphemeralStorage", func() {
+	var (
+		machines []*api.Machine
+		storage  *mcr.EphemeralStorage
+	)
+
+	BeforeEach(func() {
+		machines = nil
+		storage = &mcr.EphemeralStorage{
+			Dir:         GinkgoT().TempDir(),
+			RootFSBytes: 10 << 30,
+			ListMachines: func(context.Context) ([]*api.Machine, error) {
+				return machines, nil
+			},
+		}
+	})
+
+	It("should account empty disks and root fs copies of machines", func() {
+		spec := &api.MachineSpec{
+			Image: ptr.To("example.org/image:latest"),
+			Volumes: []*api.VolumeSpec{
+				{Name: "scratch", EmptyDisk: &api.EmptyDiskSpec{Size: 1 << 30}},
+				{Name: "data", Connection: &api.VolumeConnection{Driver: "ceph"}},
+			},
+		}
+		Expect(storage.MachineBytes(spec)).To(Equal(int64(11 << 30)))
+
+		spec.RootFSMode = api.RootFSModeShared
+		Expect(storage.MachineBytes(spec)).To(Equal(int64(1 << 30)))
+	})
+
+	It("should reject reservations exceeding the available disk space", func(ctx SpecContext) {
+		available, err := storage.Available(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(available.Value()).To(BeNumerically(">", 0))
+
+		release, err := storage.Reserve(ctx, 1<<20)
+		Expect(err).NotTo(HaveOccurred())
+		release()
+
+		_, err = storage.Reserve(ctx, math.MaxInt64)
+		Expect(err).To(MatchError(mcr.ErrEphemeralStorageExhausted))
+
+		By("allocating the disk space to a machine")
+		machines = append(machines, &api.Machine{Spec: api.MachineSpec{
+			Volumes: []*api.VolumeSpec{{Name: "scratch", EmptyDisk: &api.EmptyDiskSpec{Size: math.MaxInt64 / 2}}},
+		}})
+		_, err = storage.Reserve(ctx, 1<<20)
+		Expect(err).To(MatchError(mcr.ErrEphemeralStorageExhausted))
+	})
+})
@@ -11,14 +11,34 @@ import (
 	"os"
 
 	iri "github.com/ironcore-dev/ironcore/iri/apis/machine/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/shirou/gopsutil/v3/cpu"
 	"github.com/shirou/gopsutil/v3/mem"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/util/yaml"
 )
 
-func LoadMachineClasses(reader io.Reader) ([]iri.MachineClass, error) {
-	var classList []iri.MachineClass
+var overcommitRatio = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "libvirt_provider",
+	Name:      "overcommit_ratio",
+	Help:      "Effective overcommit ratio of host resources applied when computing machine class quantities.",
+}, []string{"resource"})
+
+func init() {
+	prometheus.MustRegister(overcommitRatio)
+}
+
+// MachineClass is a machine class of the supported machine classes file. Besides the cpu and memory of the IRI
+// machine class, it may request extended resources.
+type MachineClass struct {
+	iri.MachineClass `json:",inline"`
+
+	// ExtendedResources are the quantities of the extended resources a machine of the class consumes.
+	ExtendedResources map[ResourceName]resource.Quantity `json:"extendedResources,omitempty"`
+}
+
+func LoadMachineClasses(reader io.Reader) ([]MachineClass, error) {
+	var classList []MachineClass
 	if err := yaml.NewYAMLOrJSONDecoder(reader, 4096).Decode(&classList); err != nil {
 		return nil, fmt.Errorf("unable to unmarshal machine classes: %w", err)
 	}
@@ -26,7 +46,7 @@ func LoadMachineClasses(reader io.Reader) ([]iri.MachineClass, error) {
 	return classList, nil
 }
 
-func LoadMachineClassesFile(filename string) ([]iri.MachineClass, error) {
+func LoadMachineClassesFile(filename string) ([]MachineClass, error) {
 	file, err := os.Open(filename)
 	if err != nil {
 		return nil, fmt.Errorf("unable to open machine class file (%s): %w", filename, err)
@@ -35,23 +55,41 @@ func LoadMachineClassesFile(filename string) ([]iri.MachineClass, error) {
 	return LoadMachineClasses(file)
 }
 
-func NewMachineClassRegistry(classes []iri.MachineClass) (*Mcr, error) {
+// NewMachineClassRegistry creates a registry of the given classes. The extended resources of the classes have to
+// be provided by the given sources, which validate the requested quantities.
+func NewMachineClassRegistry(classes []MachineClass, sources map[ResourceName]Source) (*Mcr, error) {
 	registry := Mcr{
-		classes: map[string]iri.MachineClass{},
+		classes:           map[string]iri.MachineClass{},
+		extendedResources: map[string]map[ResourceName]resource.Quantity{},
+		sources:           sources,
 	}
 
 	for _, class := range classes {
 		if _, ok := registry.classes[class.Name]; ok {
 			return nil, fmt.Errorf("multiple classes with same name (%s) found", class.Name)
 		}
-		registry.classes[class.Name] = class
+		for name, quantity := range class.ExtendedResources {
+			source, ok := sources[name]
+			if !ok {
+				return nil, fmt.Errorf("class %s requests extended resource %s, which isn't available on the host", class.Name, name)
+			}
+			if err := source.Validate(quantity); err != nil {
+				return nil, fmt.Errorf("class %s requests invalid extended resource %s: %w", class.Name, name, err)
+			}
+		}
+		registry.classes[class.Name] = class.MachineClass
+		if len(class.ExtendedResources) > 0 {
+			registry.extendedResources[class.Name] = class.ExtendedResources
+		}
 	}
 
 	return &registry, nil
 }
 
 type Mcr struct {
-	classes map[string]iri.MachineClass
+	classes           map[string]iri.MachineClass
+	extendedResources map[string]map[ResourceName]resource.Quantity
+	sources           map[ResourceName]Source
 }
 
 func (m *Mcr) Get(machineClassName string) (*iri.MachineClass, bool) {
@@ -68,6 +106,25 @@ func (m *Mcr) List() []*iri.MachineClass {
 	return classes
 }
 
+// ExtendedResources returns the extended resources requested by the machine class.
+func (m *Mcr) ExtendedResources(machineClassName string) map[ResourceName]resource.Quantity {
+	return m.extendedResources[machineClassName]
+}
+
+// ExtendedQuantity returns the number of machines of the class the available extended resources suffice for. It
+// is math.MaxInt64 for classes without extended resources.
+func (m *Mcr) ExtendedQuantity(ctx context.Context, machineClassName string) (int64, error) {
+	quantity := int64(math.MaxInt64)
+	for name, requested := range m.extendedResources[machineClassName] {
+		available, err := m.sources[name].Available(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("error getting available extended resource %s: %w", name, err)
+		}
+		quantity = min(quantity, max(available.Value(), 0)/requested.Value())
+	}
+	return quantity, nil
+}
+
 func GetQuantity(class *iri.MachineClass, host *Host) int64 {
 	cpuRatio := host.Cpu.Value() / class.Capabilities.CpuMillis
 	memoryRatio := host.Mem.Value() / class.Capabilities.MemoryBytes
@@ -108,3 +165,84 @@ type Host struct {
 	Cpu *resource.Quantity
 	Mem *resource.Quantity
 }
+
+// NewReserved returns reserved host resources to subtract from the ones of GetResources, which accounts cpus
+// in millicores.
+func NewReserved(cpu, mem *resource.Quantity) *Host {
+	reserved := &Host{Mem: mem}
+	if cpu != nil {
+		reserved.Cpu = resource.NewQuantity(cpu.MilliValue(), resource.DecimalSI)
+	}
+	return reserved
+}
+
+// Subtract returns the host resources without the reserved ones. Resources never drop below zero.
+func (h *Host) Subtract(reserved *Host) *Host {
+	if reserved == nil {
+		return h
+	}
+
+	return &Host{
+		Cpu: subtractQuantity(h.Cpu, reserved.Cpu),
+		Mem: subtractQuantity(h.Mem, reserved.Mem),
+	}
+}
+
+func subtractQuantity(q, reserved *resource.Quantity) *resource.Quantity {
+	if q == nil || reserved == nil {
+		return q
+	}
+
+	res := q.DeepCopy()
+	res.Sub(*reserved)
+	if res.Sign() < 0 {
+		res.Set(0)
+	}
+	return &res
+}
+
+// OvercommitRatios are the factors the host resources are scaled by when computing machine class quantities,
+// e.g. a Cpu ratio of 4 allows 4 virtual cpus per host cpu. Ratios of 0 are treated as 1.
+type OvercommitRatios struct {
+	Cpu float64
+	Mem float64
+}
+
+// Validate checks that the ratios aren't negative.
+func (r OvercommitRatios) Validate() error {
+	if r.Cpu < 0 {
+		return fmt.Errorf("cpu overcommit ratio %v must not be negative", r.Cpu)
+	}
+	if r.Mem < 0 {
+		return fmt.Errorf("memory overcommit ratio %v must not be negative", r.Mem)
+	}
+	return nil
+}
+
+// Observe exposes the ratios as metrics.
+func (r OvercommitRatios) Observe() {
+	overcommitRatio.WithLabelValues("cpu").Set(effectiveRatio(r.Cpu))
+	overcommitRatio.WithLabelValues("memory").Set(effectiveRatio(r.Mem))
+}
+
+// Overcommit returns the host resources scaled by the overcommit ratios.
+func (h *Host) Overcommit(ratios OvercommitRatios) *Host {
+	return &Host{
+		Cpu: scaleQuantity(h.Cpu, ratios.Cpu),
+		Mem: scaleQuantity(h.Mem, ratios.Mem),
+	}
+}
+
+func effectiveRatio(ratio float64) float64 {
+	if ratio == 0 {
+		return 1
+	}
+	return ratio
+}
+
+func scaleQuantity(q *resource.Quantity, ratio float64) *resource.Quantity {
+	if q == nil || effectiveRatio(ratio) == 1 {
+		return q
+	}
+	return resource.NewQuantity(int64(float64(q.Value())*ratio), q.Format)
+}
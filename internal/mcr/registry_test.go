@@ -0,0 +1,140 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package mcr_test
+
+import (
+	"math"
+	"strings"
+
+	iri "github.com/ironcore-dev/ironcore/iri/apis/machine/v1alpha1"
+	"github.com/ironcore-dev/libvirt-provider/internal/mcr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var _ = Describe("Registry", func() {
+	const classes = `[
+  {"name": "x3-xlarge", "capabilities": {"cpu_millis": 4000, "memory_bytes": 8589934592}},
+  {"name": "g1-gpu-large", "capabilities": {"cpu_millis": 8000, "memory_bytes": 17179869184},
+   "extendedResources": {"nvidia.com/gpu": "2", "sgx-epc": "64Mi"}}
+]`
+
+	sources := map[mcr.ResourceName]mcr.Source{
+		mcr.ResourceNvidiaGPU: mcr.CountSource{Count: 5},
+		mcr.ResourceSGXEPC:    mcr.BytesSource{Bytes: 256 << 20, Granularity: 4 << 10},
+	}
+
+	It("should load extended resources of machine classes", func(ctx SpecContext) {
+		loaded, err := mcr.LoadMachineClasses(strings.NewReader(classes))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loaded).To(HaveLen(2))
+		Expect(loaded[1].Capabilities.CpuMillis).To(Equal(int64(8000)))
+		Expect(loaded[1].ExtendedResources).To(HaveKeyWithValue(mcr.ResourceNvidiaGPU, resource.MustParse("2")))
+
+		registry, err := mcr.NewMachineClassRegistry(loaded, sources)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(registry.ExtendedQuantity(ctx, "x3-xlarge")).To(Equal(int64(math.MaxInt64)))
+		Expect(registry.ExtendedQuantity(ctx, "g1-gpu-large")).To(Equal(int64(2)))
+	})
+
+	It("should reject extended resources without or with an invalid quantity of their source", func() {
+		loaded, err := mcr.LoadMachineClasses(strings.NewReader(classes))
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = mcr.NewMachineClassRegistry(loaded, map[mcr.ResourceName]mcr.Source{
+			mcr.ResourceNvidiaGPU: mcr.CountSource{Count: 5},
+		})
+		Expect(err).To(MatchError(ContainSubstring("sgx-epc, which isn't available")))
+
+		loaded[1].ExtendedResources[mcr.ResourceNvidiaGPU] = resource.MustParse("500m")
+		_, err = mcr.NewMachineClassRegistry(loaded, sources)
+		Expect(err).To(MatchError(ContainSubstring("has to be a positive integer")))
+
+		loaded[1].ExtendedResources[mcr.ResourceNvidiaGPU] = resource.MustParse("1")
+		loaded[1].ExtendedResources[mcr.ResourceSGXEPC] = resource.MustParse("1000")
+		_, err = mcr.NewMachineClassRegistry(loaded, sources)
+		Expect(err).To(MatchError(ContainSubstring("multiple of 4096 bytes")))
+	})
+})
+
+var _ = Describe("Host", func() {
+	quantity := func(value string) *resource.Quantity {
+		q := resource.MustParse(value)
+		return &q
+	}
+
+	It("should reserve cpus in millicores", func() {
+		reserved := mcr.NewReserved(quantity("1500m"), quantity("1Gi"))
+		Expect(reserved.Cpu.Value()).To(Equal(int64(1500)))
+		Expect(reserved.Mem.Value()).To(Equal(int64(1 << 30)))
+
+		reserved = mcr.NewReserved(quantity("2"), nil)
+		Expect(reserved.Cpu.Value()).To(Equal(int64(2000)))
+		Expect(reserved.Mem).To(BeNil())
+	})
+
+	It("should subtract the reserved resources", func() {
+		host := &mcr.Host{Cpu: quantity("8000"), Mem: quantity("16Gi")}
+
+		res := host.Subtract(mcr.NewReserved(quantity("2"), quantity("4Gi")))
+		Expect(res.Cpu.Value()).To(Equal(int64(6000)))
+		Expect(res.Mem.Value()).To(Equal(int64(12 << 30)))
+
+		By("keeping the resources of the host unchanged")
+		Expect(host.Cpu.Value()).To(Equal(int64(8000)))
+		Expect(host.Mem.Value()).To(Equal(int64(16 << 30)))
+	})
+
+	It("should keep resources without reservation", func() {
+		host := &mcr.Host{Cpu: quantity("8000"), Mem: quantity("16Gi")}
+		Expect(host.Subtract(nil)).To(BeIdenticalTo(host))
+
+		res := host.Subtract(mcr.NewReserved(nil, quantity("4Gi")))
+		Expect(res.Cpu.Value()).To(Equal(int64(8000)))
+		Expect(res.Mem.Value()).To(Equal(int64(12 << 30)))
+	})
+
+	It("should not drop below zero", func() {
+		host := &mcr.Host{Cpu: quantity("1000"), Mem: quantity("1Gi")}
+
+		res := host.Subtract(mcr.NewReserved(quantity("2"), quantity("2Gi")))
+		Expect(res.Cpu.Value()).To(BeZero())
+		Expect(res.Mem.Value()).To(BeZero())
+	})
+
+	It("should scale the resources by the overcommit ratios", func() {
+		host := &mcr.Host{Cpu: quantity("8000"), Mem: quantity("16Gi")}
+
+		res := host.Overcommit(mcr.OvercommitRatios{Cpu: 4, Mem: 1.5})
+		Expect(res.Cpu.Value()).To(Equal(int64(32000)))
+		Expect(res.Mem.Value()).To(Equal(int64(24 << 30)))
+
+		By("keeping the resources of the host unchanged")
+		Expect(host.Cpu.Value()).To(Equal(int64(8000)))
+		Expect(host.Mem.Value()).To(Equal(int64(16 << 30)))
+	})
+
+	It("should treat ratios of 0 as 1", func() {
+		host := &mcr.Host{Cpu: quantity("8000"), Mem: quantity("16Gi")}
+
+		res := host.Overcommit(mcr.OvercommitRatios{Cpu: 2})
+		Expect(res.Cpu.Value()).To(Equal(int64(16000)))
+		Expect(res.Mem).To(BeIdenticalTo(host.Mem))
+	})
+
+	It("should increase the machine class quantity", func() {
+		class := &iri.MachineClass{Capabilities: &iri.MachineClassCapabilities{CpuMillis: 2000, MemoryBytes: 4 << 30}}
+		host := &mcr.Host{Cpu: quantity("8000"), Mem: quantity("16Gi")}
+		Expect(mcr.GetQuantity(class, host)).To(Equal(int64(4)))
+		Expect(mcr.GetQuantity(class, host.Overcommit(mcr.OvercommitRatios{Cpu: 2}))).To(Equal(int64(4)))
+		Expect(mcr.GetQuantity(class, host.Overcommit(mcr.OvercommitRatios{Cpu: 2, Mem: 2}))).To(Equal(int64(8)))
+	})
+
+	It("should reject negative ratios", func() {
+		Expect(mcr.OvercommitRatios{Cpu: 1, Mem: 2}.Validate()).To(Succeed())
+		Expect(mcr.OvercommitRatios{Cpu: -1}.Validate()).To(MatchError(ContainSubstring("cpu overcommit ratio")))
+		Expect(mcr.OvercommitRatios{Mem: -1}.Validate()).To(MatchError(ContainSubstring("memory overcommit ratio")))
+	})
+})
@@ -0,0 +1,117 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package mcr
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// ResourceName is the name of an extended resource requested by machine classes besides cpu and memory.
+type ResourceName string
+
+const (
+	ResourceNvidiaGPU        ResourceName = "nvidia.com/gpu"
+	ResourceSGXEPC           ResourceName = "sgx-epc"
+	ResourceEphemeralStorage ResourceName = "ephemeral-storage"
+)
+
+// Source provides an extended resource of the host.
+type Source interface {
+	// Validate checks the quantity of the resource requested by a machine class.
+	Validate(quantity resource.Quantity) error
+	// Available returns the quantity of the resource available to machines.
+	Available(ctx context.Context) (resource.Quantity, error)
+}
+
+// AllocatedFunc returns the quantity of a resource allocated to machines.
+type AllocatedFunc func(ctx context.Context) (int64, error)
+
+// MachinesAllocated returns an AllocatedFunc summing up the quantity allocated to each listed machine. Deleted
+// machines count until they are gone, their resources aren't released before.
+func MachinesAllocated(listMachines func(ctx context.Context) ([]*api.Machine, error), allocated func(spec *api.MachineSpec) int64) AllocatedFunc {
+	return func(ctx context.Context) (int64, error) {
+		machines, err := listMachines(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("error listing machines: %w", err)
+		}
+		var sum int64
+		for _, machine := range machines {
+			sum += allocated(&machine.Spec)
+		}
+		return sum, nil
+	}
+}
+
+// SGXEPCBytes returns the size of the SGX enclave page cache allocated to a machine.
+func SGXEPCBytes(spec *api.MachineSpec) int64 {
+	if spec.SGX == nil {
+		return 0
+	}
+	return spec.SGX.EPCBytes
+}
+
+// available returns the total quantity less the allocated one.
+func available(ctx context.Context, total int64, allocated AllocatedFunc) (int64, error) {
+	if allocated == nil {
+		return total, nil
+	}
+	n, err := allocated(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return max(total-n, 0), nil
+}
+
+// CountSource provides a fixed number of devices, e.g. the gpus of a pci device pool.
+type CountSource struct {
+	Count int64
+	// Allocated returns the number of devices allocated to machines. If nil, all devices are available.
+	Allocated AllocatedFunc
+}
+
+func (s CountSource) Validate(quantity resource.Quantity) error {
+	if quantity.Sign() <= 0 || quantity.MilliValue()%1000 != 0 {
+		return fmt.Errorf("quantity %s has to be a positive integer", quantity.String())
+	}
+	return nil
+}
+
+func (s CountSource) Available(ctx context.Context) (resource.Quantity, error) {
+	count, err := available(ctx, s.Count, s.Allocated)
+	if err != nil {
+		return resource.Quantity{}, err
+	}
+	return *resource.NewQuantity(count, resource.DecimalSI), nil
+}
+
+// BytesSource provides a fixed number of bytes, e.g. the sgx enclave page cache of the host. Requested quantities
+// have to be multiples of Granularity if set.
+type BytesSource struct {
+	Bytes       int64
+	Granularity int64
+	// Allocated returns the bytes allocated to machines. If nil, all bytes are available.
+	Allocated AllocatedFunc
+}
+
+func (s BytesSource) Validate(quantity resource.Quantity) error {
+	if quantity.Sign() <= 0 {
+		return fmt.Errorf("quantity %s has to be positive", quantity.String())
+	}
+	if s.Granularity > 0 && quantity.Value()%s.Granularity != 0 {
+		return fmt.Errorf("quantity %s has to be a multiple of %d bytes", quantity.String(), s.Granularity)
+	}
+	return nil
+}
+
+func (s BytesSource) Available(ctx context.Context) (resource.Quantity, error) {
+	bytes, err := available(ctx, s.Bytes, s.Allocated)
+	if err != nil {
+		return resource.Quantity{}, err
+	}
+	return *resource.NewQuantity(bytes, resource.BinarySI), nil
+}
@@ -0,0 +1,70 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package mcr_test
+
+import (
+	"context"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/mcr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var _ = Describe("Resources", func() {
+	var machines []*api.Machine
+
+	BeforeEach(func() {
+		machines = []*api.Machine{
+			{Spec: api.MachineSpec{SGX: &api.SGXSpec{EPCBytes: 64 << 20}, PCIDevices: []api.PCIDeviceClaim{{Pool: "gpu", Count: 2}}}},
+			{Spec: api.MachineSpec{PCIDevices: []api.PCIDeviceClaim{{Pool: "nic", Count: 1}, {Pool: "gpu", Count: 1}}}},
+			{},
+		}
+	})
+
+	available := func(ctx context.Context, source interface {
+		Available(context.Context) (resource.Quantity, error)
+	}) int64 {
+		quantity, err := source.Available(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		return quantity.Value()
+	}
+
+	listMachines := func(context.Context) ([]*api.Machine, error) {
+		return machines, nil
+	}
+
+	It("should subtract the devices claimed by machines", func(ctx SpecContext) {
+		source := mcr.CountSource{
+			Count: 5,
+			Allocated: mcr.MachinesAllocated(listMachines, func(spec *api.MachineSpec) int64 {
+				var count int64
+				for _, claim := range spec.PCIDevices {
+					if claim.Pool == "gpu" {
+						count += int64(claim.Count)
+					}
+				}
+				return count
+			}),
+		}
+		Expect(available(ctx, source)).To(Equal(int64(2)))
+
+		By("not reporting a negative quantity if more devices are claimed than available")
+		source.Count = 2
+		Expect(available(ctx, source)).To(BeZero())
+	})
+
+	It("should subtract the bytes allocated to machines", func(ctx SpecContext) {
+		source := mcr.BytesSource{
+			Bytes:     256 << 20,
+			Allocated: mcr.MachinesAllocated(listMachines, mcr.SGXEPCBytes),
+		}
+		Expect(available(ctx, source)).To(Equal(int64(192 << 20)))
+	})
+
+	It("should provide the whole quantity without allocations", func(ctx SpecContext) {
+		Expect(available(ctx, mcr.CountSource{Count: 5})).To(Equal(int64(5)))
+	})
+})
@@ -0,0 +1,141 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package media renders the removable media drives of machines as cdrom disks, e.g. to boot installers or
+// rescue images.
+package media
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/alias"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/device"
+	"libvirt.org/go/libvirtxml"
+)
+
+const (
+	// MaxDrives is the maximum number of media drives of a machine.
+	MaxDrives = device.MaxMediaTargets
+	// MaxNameLength is the maximum length of the name of a media drive.
+	MaxNameLength = 63
+)
+
+var nameRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// Parse parses the value of the api.MediaAnnotation. An empty value results in no drives.
+func Parse(s string) ([]*api.MediaSpec, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var specs []*api.MediaSpec
+	if err := json.Unmarshal([]byte(s), &specs); err != nil {
+		return nil, fmt.Errorf("error unmarshalling media: %w", err)
+	}
+	if err := Validate(specs); err != nil {
+		return nil, err
+	}
+	return specs, nil
+}
+
+// Validate checks that the drives have unique names and valid sources.
+func Validate(specs []*api.MediaSpec) error {
+	if len(specs) > MaxDrives {
+		return fmt.Errorf("number of media drives %d exceeds maximum of %d", len(specs), MaxDrives)
+	}
+
+	seen := make(map[string]bool)
+	for _, spec := range specs {
+		if spec == nil {
+			return fmt.Errorf("media drive must not be empty")
+		}
+		if len(spec.Name) > MaxNameLength || !nameRegexp.MatchString(spec.Name) {
+			return fmt.Errorf("invalid media drive name %q", spec.Name)
+		}
+		if seen[spec.Name] {
+			return fmt.Errorf("duplicate media drive %q", spec.Name)
+		}
+		seen[spec.Name] = true
+
+		if spec.Source != nil {
+			if err := ValidateSource(spec.Source); err != nil {
+				return fmt.Errorf("media drive %q: %w", spec.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateSource checks that the source specifies either an image or a path within the media directory.
+func ValidateSource(source *api.MediaSource) error {
+	switch {
+	case source.Image != "" && source.Path != "":
+		return fmt.Errorf("must not specify both image and path")
+	case source.Image != "":
+		return nil
+	case source.Path != "":
+		if !filepath.IsLocal(source.Path) {
+			return fmt.Errorf("path %q has to be relative to the media directory", source.Path)
+		}
+		return nil
+	default:
+		return fmt.Errorf("must specify image or path")
+	}
+}
+
+// Find returns the drive with the given name.
+func Find(specs []*api.MediaSpec, name string) (*api.MediaSpec, bool) {
+	for _, spec := range specs {
+		if spec.Name == name {
+			return spec, true
+		}
+	}
+	return nil, false
+}
+
+// Disk returns the cdrom disk of the drive at the given index holding the ISO image file. An empty file results
+// in an empty drive.
+func Disk(name string, index int, file string) libvirtxml.DomainDisk {
+	disk := libvirtxml.DomainDisk{
+		Alias: &libvirtxml.DomainAlias{
+			Name: alias.Media(name),
+		},
+		Device: "cdrom",
+		Driver: &libvirtxml.DomainDiskDriver{
+			Name: "qemu",
+			Type: "raw",
+		},
+		Target: &libvirtxml.DomainDiskTarget{
+			Dev: device.MediaTarget(index),
+			Bus: "sata",
+		},
+		ReadOnly: &libvirtxml.DomainDiskReadOnly{},
+	}
+	SetFile(&disk, file)
+	return disk
+}
+
+// SetFile inserts the ISO image file into the cdrom disk. An empty file ejects the media.
+func SetFile(disk *libvirtxml.DomainDisk, file string) {
+	if file == "" {
+		disk.Source = nil
+		return
+	}
+	disk.Source = &libvirtxml.DomainDiskSource{
+		File: &libvirtxml.DomainDiskSourceFile{
+			File: file,
+		},
+	}
+}
+
+// File returns the ISO image file inserted into the cdrom disk, empty if the drive is empty.
+func File(disk *libvirtxml.DomainDisk) string {
+	if disk.Source == nil || disk.Source.File == nil {
+		return ""
+	}
+	return disk.Source.File.File
+}
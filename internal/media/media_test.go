@@ -0,0 +1,53 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package media_test
+
+import (
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/alias"
+	. "github.com/ironcore-dev/libvirt-provider/internal/media"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Media", func() {
+	Describe("Parse", func() {
+		It("should return no drives for an empty value", func() {
+			Expect(Parse("")).To(BeEmpty())
+		})
+
+		It("should parse the drives", func() {
+			Expect(Parse(`[{"name":"installer","source":{"image":"ghcr.io/foo/installer:1.0"}},{"name":"tools"}]`)).To(Equal([]*api.MediaSpec{
+				{Name: "installer", Source: &api.MediaSource{Image: "ghcr.io/foo/installer:1.0"}},
+				{Name: "tools"},
+			}))
+		})
+
+		It("should reject invalid drives", func() {
+			_, err := Parse(`[{"name":"Installer"}]`)
+			Expect(err).To(MatchError(ContainSubstring("invalid media drive name")))
+			_, err = Parse(`[{"name":"a"},{"name":"a"}]`)
+			Expect(err).To(MatchError(ContainSubstring("duplicate")))
+			_, err = Parse(`[{"name":"a","source":{"image":"foo","path":"foo.iso"}}]`)
+			Expect(err).To(MatchError(ContainSubstring("both image and path")))
+			_, err = Parse(`[{"name":"a","source":{"path":"../foo.iso"}}]`)
+			Expect(err).To(MatchError(ContainSubstring("relative to the media directory")))
+			_, err = Parse(`[{"name":"a","source":{}}]`)
+			Expect(err).To(MatchError(ContainSubstring("must specify image or path")))
+		})
+	})
+
+	It("should render cdrom disks", func() {
+		disk := Disk("installer", 1, "/isos/installer.iso")
+		Expect(disk.Device).To(Equal("cdrom"))
+		Expect(disk.Alias.Name).To(Equal(alias.Media("installer")))
+		Expect(disk.Target.Dev).To(Equal("sdc"))
+		Expect(disk.Target.Bus).To(Equal("sata"))
+		Expect(File(&disk)).To(Equal("/isos/installer.iso"))
+
+		SetFile(&disk, "")
+		Expect(disk.Source).To(BeNil())
+		Expect(File(&disk)).To(BeEmpty())
+	})
+})
@@ -0,0 +1,31 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package networkinterfaceplugin
+
+import (
+	providernetworkinterface "github.com/ironcore-dev/libvirt-provider/internal/plugins/networkinterface"
+	"github.com/ironcore-dev/libvirt-provider/internal/plugins/networkinterface/external"
+	"github.com/spf13/pflag"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+)
+
+type externalOptions struct {
+	ExternalPluginName string
+}
+
+func (o *externalOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.ExternalPluginName, "external-network-interface-plugin", "", "Name of the external network interface plugin to use. Empty uses the only one registered.")
+}
+
+func (o *externalOptions) PluginName() string {
+	return external.PluginExternal
+}
+
+func (o *externalOptions) NetworkInterfacePlugin() (providernetworkinterface.Plugin, func(), error) {
+	return external.NewPlugin(o.ExternalPluginName), nil, nil
+}
+
+func init() {
+	utilruntime.Must(DefaultPluginTypeRegistry.Register(&externalOptions{}, 20))
+}
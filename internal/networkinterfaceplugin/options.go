@@ -5,6 +5,7 @@ package networkinterfaceplugin
 
 import (
 	"fmt"
+	"slices"
 	"sort"
 
 	providernetworkinterface "github.com/ironcore-dev/libvirt-provider/internal/plugins/networkinterface"
@@ -87,7 +88,9 @@ func (r *TypeOptionsRegistry) PluginTypeOptsByName(pluginName string) (TypeOptio
 
 type Options struct {
 	PluginName string
-	registry   *TypeOptionsRegistry
+	// AdditionalPluginNames are the plugins network interfaces can select besides the default plugin.
+	AdditionalPluginNames []string
+	registry              *TypeOptionsRegistry
 }
 
 func NewOptions(registry *TypeOptionsRegistry) *Options {
@@ -98,6 +101,7 @@ func NewOptions(registry *TypeOptionsRegistry) *Options {
 
 func (o *Options) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&o.PluginName, "network-interface-plugin-name", o.registry.DefaultPluginName(), fmt.Sprintf("Name of the network interface plugin to use. Available: %v", o.registry.PluginNames()))
+	fs.StringSliceVar(&o.AdditionalPluginNames, "additional-network-interface-plugin-names", nil, fmt.Sprintf("Names of additional network interface plugins network interfaces can select with the %q attribute. Available: %v", providernetworkinterface.AttributePluginKey, o.registry.PluginNames()))
 	o.registry.ForeachPluginTypeOpts(func(pluginName string, pluginOpts TypeOptions) bool {
 		pluginOpts.AddFlags(fs)
 		return true
@@ -105,7 +109,42 @@ func (o *Options) AddFlags(fs *pflag.FlagSet) {
 }
 
 func (o *Options) NetworkInterfacePlugin() (providernetworkinterface.Plugin, func(), error) {
-	pluginOpts, err := o.registry.PluginTypeOptsByName(o.PluginName)
+	return o.networkInterfacePlugin(o.PluginName)
+}
+
+// NetworkInterfacePlugins returns the default plugin followed by the additional plugins.
+func (o *Options) NetworkInterfacePlugins() ([]providernetworkinterface.Plugin, func(), error) {
+	var (
+		nicPlugins []providernetworkinterface.Plugin
+		cleanups   []func()
+	)
+	cleanup := func() {
+		for _, cleanup := range cleanups {
+			cleanup()
+		}
+	}
+
+	pluginNames := append([]string{o.PluginName}, o.AdditionalPluginNames...)
+	for i, pluginName := range pluginNames {
+		if slices.Contains(pluginNames[:i], pluginName) {
+			continue
+		}
+
+		nicPlugin, pluginCleanup, err := o.networkInterfacePlugin(pluginName)
+		if err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+		nicPlugins = append(nicPlugins, nicPlugin)
+		if pluginCleanup != nil {
+			cleanups = append(cleanups, pluginCleanup)
+		}
+	}
+	return nicPlugins, cleanup, nil
+}
+
+func (o *Options) networkInterfacePlugin(pluginName string) (providernetworkinterface.Plugin, func(), error) {
+	pluginOpts, err := o.registry.PluginTypeOptsByName(pluginName)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -0,0 +1,312 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package oci
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/containerd/containerd/content"
+	ocicontent "github.com/ironcore-dev/ironcore-image/oci/content"
+	"github.com/ironcore-dev/ironcore-image/oci/descriptormatcher"
+	"github.com/ironcore-dev/ironcore-image/oci/image"
+	"github.com/ironcore-dev/ironcore-image/utils/sets"
+	"github.com/opencontainers/go-digest"
+	ocispecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"golang.org/x/sys/unix"
+)
+
+const DefaultEvictionInterval = 10 * time.Minute
+
+// EvictionOptions configure the eviction of images from the local cache.
+// Eviction is disabled if neither MaxBytes nor MinFreeBytes is set.
+type EvictionOptions struct {
+	// MaxBytes is the maximum size of all blobs in the store.
+	MaxBytes int64
+	// MinFreeBytes is the minimum free space that has to remain on the file system of the store.
+	MinFreeBytes int64
+	// Interval is the interval in which the cache is checked for eviction.
+	Interval time.Duration
+	// Dir is the directory of the store, used to determine the free space of its file system.
+	Dir string
+	// References lists the images currently in use. Referenced images are never evicted.
+	References func(ctx context.Context) (ImageReferences, error)
+}
+
+// ImageReferences are the images in use by machines.
+type ImageReferences struct {
+	// Refs lists the ids of the machines using an image by its ref.
+	Refs map[string][]string
+	// Digests lists the ids of the machines using an image by its manifest digest. The image is kept even if its
+	// ref resolves to another image by now.
+	Digests map[digest.Digest][]string
+}
+
+func (o EvictionOptions) enabled() bool {
+	return o.MaxBytes > 0 || o.MinFreeBytes > 0
+}
+
+func setEvictionOptionsDefaults(o *EvictionOptions) {
+	if o.Interval <= 0 {
+		o.Interval = DefaultEvictionInterval
+	}
+}
+
+type cachedImage struct {
+	descriptor ocispecv1.Descriptor
+	blobs      []digest.Digest
+	lastUsed   time.Time
+}
+
+// touch marks the image with the given manifest digest as used.
+func (c *LocalCache) touch(dgst digest.Digest) {
+	c.lastUsed[dgst] = time.Now()
+}
+
+// evict removes least recently used, unreferenced images until the configured limits are met and deletes all
+// blobs not belonging to an indexed image anymore. It has to be run from the cache loop, with no pulls in progress.
+func (c *LocalCache) evict(ctx context.Context) error {
+	log := c.log.WithName("eviction")
+
+	inUse, err := c.referencedDigests(ctx)
+	if err != nil {
+		return err
+	}
+
+	images, err := c.listCachedImages(ctx)
+	if err != nil {
+		return err
+	}
+
+	blobSizes, err := c.blobSizes(ctx)
+	if err != nil {
+		return err
+	}
+
+	pinned, err := c.unindexedBlobs(ctx, inUse, images, blobSizes)
+	if err != nil {
+		return err
+	}
+
+	// Least recently used images first.
+	slices.SortFunc(images, func(a, b cachedImage) int {
+		return a.lastUsed.Compare(b.lastUsed)
+	})
+
+	for {
+		if err := c.deleteUnreferencedBlobs(ctx, images, pinned, blobSizes); err != nil {
+			return err
+		}
+
+		exceeded, err := c.limitsExceeded(blobSizes)
+		if err != nil {
+			return err
+		}
+		if !exceeded {
+			return nil
+		}
+
+		idx := slices.IndexFunc(images, func(img cachedImage) bool {
+			return !inUse.Has(img.descriptor.Digest)
+		})
+		if idx < 0 {
+			log.Info("Cache limits exceeded but all cached images are in use")
+			return nil
+		}
+
+		img := images[idx]
+		log.V(1).Info("Evicting image", "Digest", img.descriptor.Digest, "Ref", img.descriptor.Annotations[ocispecv1.AnnotationRefName], "LastUsed", img.lastUsed)
+		if err := c.store.Layout().Indexer().Delete(ctx, descriptormatcher.Digests(img.descriptor.Digest)); err != nil {
+			return fmt.Errorf("error removing image %s from index: %w", img.descriptor.Digest, err)
+		}
+		delete(c.lastUsed, img.descriptor.Digest)
+		images = slices.Delete(images, idx, idx+1)
+	}
+}
+
+// referencedDigests returns the manifest digests of the images in use. The digests pinned by machines are in use
+// as they are, the refs in use are resolved to the digests they point to in the cache.
+func (c *LocalCache) referencedDigests(ctx context.Context) (sets.Set[digest.Digest], error) {
+	res := sets.New[digest.Digest]()
+	if c.eviction.References == nil {
+		return res, nil
+	}
+
+	refs, err := c.eviction.References(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing referenced images: %w", err)
+	}
+
+	for dgst, machineIDs := range refs.Digests {
+		c.log.V(2).Info("Image in use", "Digest", dgst, "MachineIDs", machineIDs)
+		res.Insert(dgst)
+	}
+	for ref, machineIDs := range refs.Refs {
+		img, err := c.store.Resolve(ctx, ref)
+		if err != nil {
+			// Not cached (anymore), nothing to protect.
+			continue
+		}
+		c.log.V(2).Info("Image in use", "Ref", ref, "Digest", img.Descriptor().Digest, "MachineIDs", machineIDs)
+		res.Insert(img.Descriptor().Digest)
+	}
+	return res, nil
+}
+
+// unindexedBlobs returns the blobs of the images in use that are not indexed anymore, e.g. because their ref
+// was pulled again and resolves to another image by now. Their blobs are kept as long as they are in use.
+func (c *LocalCache) unindexedBlobs(
+	ctx context.Context,
+	inUse sets.Set[digest.Digest],
+	images []cachedImage,
+	blobSizes map[digest.Digest]int64,
+) (sets.Set[digest.Digest], error) {
+	res := sets.New[digest.Digest]()
+	for dgst := range inUse {
+		if slices.ContainsFunc(images, func(img cachedImage) bool { return img.descriptor.Digest == dgst }) {
+			continue
+		}
+		size, ok := blobSizes[dgst]
+		if !ok {
+			// The manifest is gone, so are the blobs only it referenced.
+			continue
+		}
+
+		desc := ocispecv1.Descriptor{MediaType: ocispecv1.MediaTypeImageManifest, Digest: dgst, Size: size}
+		blobs, err := imageBlobs(ctx, ocicontent.Image(c.store.Layout().Store(), desc))
+		if err != nil {
+			return nil, fmt.Errorf("error getting blobs of unindexed image %s: %w", dgst, err)
+		}
+		res.Insert(blobs...)
+	}
+	return res, nil
+}
+
+func (c *LocalCache) listCachedImages(ctx context.Context) ([]cachedImage, error) {
+	ociImgs, err := c.store.Layout().Images(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing images: %w", err)
+	}
+
+	seen := sets.New[digest.Digest]()
+	var res []cachedImage
+	for _, ociImg := range ociImgs {
+		desc := ociImg.Descriptor()
+		if seen.Has(desc.Digest) {
+			// Multiple refs pointing to the same image.
+			continue
+		}
+		seen.Insert(desc.Digest)
+
+		blobs, err := imageBlobs(ctx, ociImg)
+		if err != nil {
+			return nil, err
+		}
+
+		lastUsed, ok := c.lastUsed[desc.Digest]
+		if !ok {
+			// Unknown images (e.g. after a restart) count as used now to not evict them right away.
+			lastUsed = time.Now()
+			c.lastUsed[desc.Digest] = lastUsed
+		}
+
+		res = append(res, cachedImage{
+			descriptor: desc,
+			blobs:      blobs,
+			lastUsed:   lastUsed,
+		})
+	}
+	return res, nil
+}
+
+// imageBlobs returns the digests of the manifest, config and layers of the image.
+func imageBlobs(ctx context.Context, ociImg image.Image) ([]digest.Digest, error) {
+	desc := ociImg.Descriptor()
+	blobs := []digest.Digest{desc.Digest}
+	config, err := ociImg.Config(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting config of image %s: %w", desc.Digest, err)
+	}
+	blobs = append(blobs, config.Descriptor().Digest)
+
+	layers, err := ociImg.Layers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting layers of image %s: %w", desc.Digest, err)
+	}
+	for _, layer := range layers {
+		blobs = append(blobs, layer.Descriptor().Digest)
+	}
+	return blobs, nil
+}
+
+func (c *LocalCache) blobSizes(ctx context.Context) (map[digest.Digest]int64, error) {
+	res := make(map[digest.Digest]int64)
+	if err := c.store.Layout().Store().Walk(ctx, func(info content.Info) error {
+		res[info.Digest] = info.Size
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("error walking blobs: %w", err)
+	}
+	return res, nil
+}
+
+// Size returns the total size in bytes of the blobs of the cache.
+func (c *LocalCache) Size(ctx context.Context) (int64, error) {
+	blobSizes, err := c.blobSizes(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, size := range blobSizes {
+		total += size
+	}
+	return total, nil
+}
+
+func (c *LocalCache) deleteUnreferencedBlobs(ctx context.Context, images []cachedImage, pinned sets.Set[digest.Digest], blobSizes map[digest.Digest]int64) error {
+	referenced := sets.New[digest.Digest]()
+	for _, img := range images {
+		referenced.Insert(img.blobs...)
+	}
+
+	for dgst := range blobSizes {
+		if referenced.Has(dgst) || pinned.Has(dgst) {
+			continue
+		}
+
+		c.log.V(1).Info("Deleting unreferenced blob", "Digest", dgst)
+		if err := c.store.Layout().Store().Delete(ctx, dgst); err != nil {
+			return fmt.Errorf("error deleting blob %s: %w", dgst, err)
+		}
+		delete(blobSizes, dgst)
+		delete(c.verifiedBlobs, dgst)
+	}
+	return nil
+}
+
+func (c *LocalCache) limitsExceeded(blobSizes map[digest.Digest]int64) (bool, error) {
+	if c.eviction.MaxBytes > 0 {
+		var total int64
+		for _, size := range blobSizes {
+			total += size
+		}
+		if total > c.eviction.MaxBytes {
+			return true, nil
+		}
+	}
+
+	if c.eviction.MinFreeBytes > 0 {
+		var stat unix.Statfs_t
+		if err := unix.Statfs(c.eviction.Dir, &stat); err != nil {
+			return false, fmt.Errorf("error getting file system stats: %w", err)
+		}
+		if free := int64(stat.Bavail) * stat.Bsize; free < c.eviction.MinFreeBytes {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,102 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package oci
+
+import (
+	"context"
+
+	ironcoreimage "github.com/ironcore-dev/ironcore-image"
+	"github.com/ironcore-dev/ironcore-image/oci/descriptormatcher"
+	"github.com/ironcore-dev/ironcore-image/oci/image"
+	"github.com/ironcore-dev/ironcore-image/oci/imageutil"
+	"github.com/ironcore-dev/ironcore-image/oci/store"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/opencontainers/go-digest"
+)
+
+var _ = Describe("Eviction", func() {
+	const ref = "example.org/image:latest"
+
+	var (
+		ociStore *store.Store
+		refs     ImageReferences
+		cache    *LocalCache
+	)
+
+	newImage := func(rootFS string) image.Image {
+		img, err := imageutil.NewJSONConfigBuilder(ironcoreimage.Config{}, imageutil.WithMediaType(ironcoreimage.ConfigMediaType)).
+			BytesLayer([]byte(rootFS), imageutil.WithMediaType(ironcoreimage.RootFSLayerMediaType)).
+			Complete()
+		Expect(err).NotTo(HaveOccurred())
+		return img
+	}
+
+	imageBlobsOf := func(ctx context.Context, img image.Image) []digest.Digest {
+		blobs, err := imageBlobs(ctx, img)
+		Expect(err).NotTo(HaveOccurred())
+		return blobs
+	}
+
+	storedBlobs := func(ctx context.Context) []digest.Digest {
+		blobSizes, err := cache.blobSizes(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		var res []digest.Digest
+		for dgst := range blobSizes {
+			res = append(res, dgst)
+		}
+		return res
+	}
+
+	BeforeEach(func() {
+		var err error
+		ociStore, err = store.New(GinkgoT().TempDir())
+		Expect(err).NotTo(HaveOccurred())
+
+		refs = ImageReferences{}
+		cache, err = NewLocalCache(GinkgoLogr, nil, ociStore, LocalCacheOptions{
+			Eviction: EvictionOptions{
+				// Exceeded by any image, hence every image not in use is evicted.
+				MaxBytes: 1,
+				References: func(context.Context) (ImageReferences, error) {
+					return refs, nil
+				},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should evict images not in use", func(ctx SpecContext) {
+		img := newImage("rootfs")
+		Expect(ociStore.Push(ctx, ref, img)).To(Succeed())
+
+		Expect(cache.evict(ctx)).To(Succeed())
+		Expect(storedBlobs(ctx)).To(BeEmpty())
+	})
+
+	It("should keep images referenced by ref", func(ctx SpecContext) {
+		img := newImage("rootfs")
+		Expect(ociStore.Push(ctx, ref, img)).To(Succeed())
+		refs.Refs = map[string][]string{ref: {"foo"}}
+
+		Expect(cache.evict(ctx)).To(Succeed())
+		Expect(storedBlobs(ctx)).To(ConsistOf(imageBlobsOf(ctx, img)))
+	})
+
+	It("should keep the blobs of an image pinned by digest after its ref moved", func(ctx SpecContext) {
+		pinned := newImage("pinned rootfs")
+		Expect(ociStore.Push(ctx, ref, pinned)).To(Succeed())
+		pinnedBlobs := imageBlobsOf(ctx, pinned)
+
+		By("pulling another image with the same ref")
+		Expect(ociStore.Layout().Indexer().Delete(ctx, descriptormatcher.Digests(pinned.Descriptor().Digest))).To(Succeed())
+		current := newImage("current rootfs")
+		Expect(ociStore.Push(ctx, ref, current)).To(Succeed())
+
+		refs.Digests = map[digest.Digest][]string{pinned.Descriptor().Digest: {"foo"}}
+
+		Expect(cache.evict(ctx)).To(Succeed())
+		Expect(storedBlobs(ctx)).To(ConsistOf(pinnedBlobs))
+	})
+})
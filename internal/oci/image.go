@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"sync"
 	"time"
 
@@ -16,19 +17,27 @@ import (
 	ironcoreimage "github.com/ironcore-dev/ironcore-image"
 	"github.com/ironcore-dev/ironcore-image/oci/image"
 	"github.com/ironcore-dev/ironcore-image/oci/indexer"
-	"github.com/ironcore-dev/ironcore-image/oci/remote"
 	"github.com/ironcore-dev/ironcore-image/oci/store"
 	"github.com/ironcore-dev/ironcore-image/utils/sets"
+	"github.com/opencontainers/go-digest"
 	ocispecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
 type Image struct {
+	// Digest is the digest of the manifest of the image.
+	Digest    digest.Digest
 	Config    ironcoreimage.Config
 	RootFS    *FileLayer
 	InitRAMFs *FileLayer
 	Kernel    *FileLayer
 }
 
+// IsDirectKernelBoot reports whether the image ships a kernel and initramfs to boot directly
+// instead of booting the rootfs disk via firmware.
+func (i *Image) IsDirectKernelBoot() bool {
+	return i.Kernel != nil && i.InitRAMFs != nil
+}
+
 type FileLayer struct {
 	Descriptor ocispecv1.Descriptor
 	Path       string
@@ -41,10 +50,30 @@ type LocalCache struct {
 	log logr.Logger
 
 	store    *store.Store
-	registry *remote.Registry
+	registry image.Source
 
 	pullRequests chan pullRequest
 	listeners    []Listener
+
+	verifier SignatureVerifier
+
+	// pullSlots bounds the concurrent pulls. It is nil if they are not bounded.
+	pullSlots chan struct{}
+
+	eviction EvictionOptions
+	// lastUsed is only accessed from the cache loop.
+	lastUsed map[digest.Digest]time.Time
+	// verifiedBlobs is only accessed from the cache loop.
+	verifiedBlobs map[digest.Digest]verifiedBlob
+}
+
+type LocalCacheOptions struct {
+	Eviction EvictionOptions
+	// Verifier verifies pulled images before they are stored. If nil, images are not verified.
+	Verifier SignatureVerifier
+	// MaxConcurrentPulls bounds the number of images pulled at once. Further pulls are queued until a pull
+	// completes. Zero means no limit.
+	MaxConcurrentPulls int
 }
 
 type pullRequest struct {
@@ -90,7 +119,7 @@ func (c *LocalCache) resolveImage(ctx context.Context, ociImg image.Image) (*Ima
 
 	var (
 		localStore = c.store.Layout().Store()
-		img        = Image{Config: *config}
+		img        = Image{Digest: ociImg.Descriptor().Digest, Config: *config}
 	)
 	for _, layer := range layers {
 		switch layer.Descriptor().MediaType {
@@ -128,12 +157,15 @@ func (c *LocalCache) resolveImage(ctx context.Context, ociImg image.Image) (*Ima
 	if img.RootFS == nil || img.RootFS.Path == "" {
 		missing = append(missing, "rootfs")
 	}
-	if img.Kernel == nil || img.Kernel.Path == "" {
-		missing = append(missing, "kernel")
-	}
-	if img.InitRAMFs == nil || img.InitRAMFs.Path == "" {
+	// Kernel and initramfs are optional: images shipping only a bootable disk are booted via firmware.
+	hasKernel := img.Kernel != nil && img.Kernel.Path != ""
+	hasInitRAMFs := img.InitRAMFs != nil && img.InitRAMFs.Path != ""
+	if hasKernel && !hasInitRAMFs {
 		missing = append(missing, "initramfs")
 	}
+	if hasInitRAMFs && !hasKernel {
+		missing = append(missing, "kernel")
+	}
 	if len(missing) > 0 {
 		return nil, fmt.Errorf("incomplete oci: components are missing: %v", missing)
 	}
@@ -144,18 +176,51 @@ func (c *LocalCache) resolveImage(ctx context.Context, ociImg image.Image) (*Ima
 func (c *LocalCache) loop(ctx context.Context) {
 	var (
 		activePulls = sets.New[string]()
-		pullDone    = make(chan string)
+		pullDone    = make(chan PullDoneEvent)
+		verifyDone  = make(chan verifyResult)
+		evictTick   <-chan time.Time
 	)
 
+	if c.eviction.enabled() {
+		ticker := time.NewTicker(c.eviction.Interval)
+		defer ticker.Stop()
+		evictTick = ticker.C
+	}
+
+	notify := func(evt PullDoneEvent) {
+		activePulls.Delete(evt.Ref)
+		for _, listener := range c.listeners {
+			listener.HandlePullDone(evt)
+		}
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case ref := <-pullDone:
-			activePulls.Delete(ref)
-			for _, listener := range c.listeners {
-				listener.HandlePullDone(PullDoneEvent{Ref: ref})
+		case <-evictTick:
+			if len(activePulls) > 0 {
+				c.log.V(1).Info("Skipping eviction while pulls are in progress")
+				continue
+			}
+			if err := c.evict(ctx); err != nil {
+				c.log.Error(err, "failed to evict images")
+			}
+		case evt := <-pullDone:
+			notify(evt)
+		case res := <-verifyDone:
+			maps.Copy(c.verifiedBlobs, res.verified)
+			if len(res.corrupted) == 0 {
+				notify(PullDoneEvent{Ref: res.ref, Err: res.err})
+				continue
 			}
+
+			c.log.Info("Cached image is corrupted, pulling it again", "Ref", res.ref, "Error", res.err.Error())
+			if err := c.removeCorrupted(ctx, res.ref, res.corrupted); err != nil {
+				notify(PullDoneEvent{Ref: res.ref, Err: fmt.Errorf("error removing corrupted image %s: %w", res.ref, err)})
+				continue
+			}
+			c.startPull(ctx, res.ref, pullDone)
 		case req := <-c.pullRequests:
 			req.ctx = setupMediaTypeKeyPrefixes(ctx)
 			if activePulls.Has(req.ref) {
@@ -164,38 +229,106 @@ func (c *LocalCache) loop(ctx context.Context) {
 			}
 
 			ociImg, err := c.store.Resolve(req.ctx, req.ref)
-			if err != nil {
-				if !errors.Is(err, indexer.ErrNotFound) {
-					req.res <- pullResult{err: fmt.Errorf("error pulling %s: %w", req.ref, err)}
-				}
-
-				activePulls.Insert(req.ref)
-				go func() {
-					log := c.log.WithValues("Ref", req.ref)
-					defer func() {
+			switch {
+			case err == nil:
+				img, pending, err := c.resolveCachedImage(req.ctx, req.ref, ociImg)
+				switch {
+				case errors.Is(err, errCorruptedBlob):
+					// Pull the image again below.
+				case err != nil || len(pending) == 0:
+					req.res <- pullResult{image: img, err: err}
+					continue
+				default:
+					// The image is reported as pulling until its blobs are verified.
+					activePulls.Insert(req.ref)
+					go func() {
 						select {
-						case pullDone <- req.ref:
+						case verifyDone <- verifyBlobs(req.ref, pending):
 						case <-ctx.Done():
 						}
 					}()
+					req.res <- pullResult{err: ErrImagePulling}
+					continue
+				}
+			case !errors.Is(err, indexer.ErrNotFound):
+				req.res <- pullResult{err: fmt.Errorf("error pulling %s: %w", req.ref, err)}
+				continue
+			}
+
+			activePulls.Insert(req.ref)
+			c.startPull(ctx, req.ref, pullDone)
+			req.res <- pullResult{err: ErrImagePulling}
+		}
+	}
+}
 
-					log.V(1).Info("Start pulling")
-					err := c.retryPullImage(ctx, req.ref)
-					if err != nil {
-						log.Error(err, "Error copying oci")
-						return
-					}
-					log.V(1).Info("Successfully pulled")
-				}()
+// startPull pulls the image with the given ref in the background and reports the result to pullDone.
+func (c *LocalCache) startPull(ctx context.Context, ref string, pullDone chan<- PullDoneEvent) {
+	go func() {
+		var (
+			log = c.log.WithValues("Ref", ref)
+			err error
+		)
+		defer func() {
+			select {
+			case pullDone <- PullDoneEvent{Ref: ref, Err: err}:
+			case <-ctx.Done():
+			}
+		}()
 
-				req.res <- pullResult{err: ErrImagePulling}
-				continue
+		if c.pullSlots != nil {
+			select {
+			case c.pullSlots <- struct{}{}:
+			default:
+				log.V(1).Info("Waiting for a free pull slot", "MaxConcurrentPulls", cap(c.pullSlots))
+				select {
+				case c.pullSlots <- struct{}{}:
+				case <-ctx.Done():
+					err = ctx.Err()
+					return
+				}
 			}
+			defer func() { <-c.pullSlots }()
+		}
+
+		log.V(1).Info("Start pulling")
+		err = c.retryPullImage(ctx, ref)
+		if err != nil {
+			log.Error(err, "Error copying oci")
+			return
+		}
+		log.V(1).Info("Successfully pulled")
+	}()
+}
+
+// resolveCachedImage resolves a cached image and checks its blobs by their size. If blobs of the image are missing
+// or corrupted, they are removed and an error wrapping errCorruptedBlob is returned, so the image is pulled again.
+// Blobs that changed since their last verification are returned as pending instead of the image, their digests
+// have to be verified outside the cache loop first.
+func (c *LocalCache) resolveCachedImage(ctx context.Context, ref string, ociImg image.Image) (*Image, []pendingBlob, error) {
+	img, err := c.resolveImage(ctx, ociImg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pending, corrupted, err := c.statImage(img)
+	if err != nil {
+		if len(corrupted) == 0 {
+			return nil, nil, err
+		}
 
-			img, err := c.resolveImage(req.ctx, ociImg)
-			req.res <- pullResult{image: img, err: err}
+		c.log.Info("Cached image is corrupted, pulling it again", "Ref", ref, "Error", err.Error())
+		if err := c.removeCorrupted(ctx, ref, corrupted); err != nil {
+			return nil, nil, fmt.Errorf("error removing corrupted image %s: %w", ref, err)
 		}
+		return nil, nil, err
 	}
+	if len(pending) > 0 {
+		return nil, pending, nil
+	}
+
+	c.touch(ociImg.Descriptor().Digest)
+	return img, nil, nil
 }
 
 func (c *LocalCache) retryPullImage(ctx context.Context, ref string) error {
@@ -218,6 +351,9 @@ func (c *LocalCache) retryPullImage(ctx context.Context, ref string) error {
 		if err == nil {
 			return nil
 		}
+		if IsSignatureVerificationError(err) {
+			return err
+		}
 		log.Error(err, "oci couldn't be pulled")
 		errs = append(errs, fmt.Errorf("trial %d of oci pull failed with: %w ", i+1, err))
 	}
@@ -226,9 +362,19 @@ func (c *LocalCache) retryPullImage(ctx context.Context, ref string) error {
 }
 
 func (c *LocalCache) pullImage(ctx context.Context, ref string) error {
-	sourceImg, err := image.Copy(ctx, c.store, c.registry, ref)
+	sourceImg, err := c.registry.Resolve(ctx, ref)
 	if err != nil {
-		return err
+		return fmt.Errorf("error resolving ref %s: %w", ref, err)
+	}
+
+	if c.verifier != nil {
+		if err := c.verifier.Verify(ctx, c.registry, ref, sourceImg); err != nil {
+			return err
+		}
+	}
+
+	if err := c.store.Push(ctx, ref, sourceImg); err != nil {
+		return fmt.Errorf("error pushing to ref %s: %w", ref, err)
 	}
 	ociImg, err := c.store.Resolve(ctx, ref)
 	if err != nil {
@@ -291,12 +437,27 @@ func (c *LocalCache) Start(ctx context.Context) error {
 	return nil
 }
 
-func NewLocalCache(log logr.Logger, registry *remote.Registry, store *store.Store) (*LocalCache, error) {
+func NewLocalCache(log logr.Logger, registry image.Source, store *store.Store, opts LocalCacheOptions) (*LocalCache, error) {
+	if opts.Eviction.MinFreeBytes > 0 && opts.Eviction.Dir == "" {
+		return nil, fmt.Errorf("must specify store directory to evict by free space")
+	}
+	setEvictionOptionsDefaults(&opts.Eviction)
+
+	var pullSlots chan struct{}
+	if opts.MaxConcurrentPulls > 0 {
+		pullSlots = make(chan struct{}, opts.MaxConcurrentPulls)
+	}
+
 	return &LocalCache{
-		log:          log,
-		store:        store,
-		registry:     registry,
-		pullRequests: make(chan pullRequest),
+		log:           log,
+		store:         store,
+		registry:      registry,
+		verifier:      opts.Verifier,
+		pullSlots:     pullSlots,
+		pullRequests:  make(chan pullRequest),
+		eviction:      opts.Eviction,
+		lastUsed:      make(map[digest.Digest]time.Time),
+		verifiedBlobs: make(map[digest.Digest]verifiedBlob),
 	}, nil
 }
 
@@ -307,6 +468,8 @@ type Cache interface {
 
 type PullDoneEvent struct {
 	Ref string
+	// Err is set if the pull failed.
+	Err error
 }
 
 type Listener interface {
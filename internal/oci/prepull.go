@@ -0,0 +1,130 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package oci
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+type PullState string
+
+const (
+	// PullStatePulling indicates the image is being pulled.
+	PullStatePulling PullState = "Pulling"
+	// PullStatePulled indicates the image has been pulled but not verified yet.
+	PullStatePulled PullState = "Pulled"
+	// PullStateReady indicates the image is cached and verified to be usable for machines.
+	PullStateReady PullState = "Ready"
+	// PullStateFailed indicates the image could not be pulled or verified.
+	PullStateFailed PullState = "Failed"
+)
+
+type ImageStatus struct {
+	Ref       string    `json:"ref"`
+	State     PullState `json:"state"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// PrePuller pulls images into the cache ahead of machines using them and tracks their pull status.
+type PrePuller struct {
+	cache Cache
+
+	mu       sync.Mutex
+	statuses map[string]*ImageStatus
+}
+
+func NewPrePuller(cache Cache) *PrePuller {
+	p := &PrePuller{
+		cache:    cache,
+		statuses: make(map[string]*ImageStatus),
+	}
+	cache.AddListener(p)
+	return p
+}
+
+// PrePull starts pulling the given refs, retrying previously failed ones, and returns their current status.
+func (p *PrePuller) PrePull(ctx context.Context, refs []string) []ImageStatus {
+	res := make([]ImageStatus, 0, len(refs))
+	for _, ref := range refs {
+		res = append(res, p.check(ctx, ref))
+	}
+	return res
+}
+
+// Status returns the status of the given refs or of all pre-pulled refs if none are given.
+// Refs still pulling are looked up again and pulled images are verified on the way.
+func (p *PrePuller) Status(ctx context.Context, refs []string) []ImageStatus {
+	p.mu.Lock()
+	if len(refs) == 0 {
+		for ref := range p.statuses {
+			refs = append(refs, ref)
+		}
+		slices.Sort(refs)
+	}
+	var (
+		res     = make([]ImageStatus, 0, len(refs))
+		recheck []string
+	)
+	for _, ref := range refs {
+		status, ok := p.statuses[ref]
+		if !ok {
+			continue
+		}
+		if status.State == PullStatePulling || status.State == PullStatePulled {
+			recheck = append(recheck, ref)
+			continue
+		}
+		res = append(res, *status)
+	}
+	p.mu.Unlock()
+
+	for _, ref := range recheck {
+		res = append(res, p.check(ctx, ref))
+	}
+	return res
+}
+
+func (p *PrePuller) check(ctx context.Context, ref string) ImageStatus {
+	status := ImageStatus{Ref: ref}
+	_, err := p.cache.Get(ctx, ref)
+	switch {
+	case err == nil:
+		status.State = PullStateReady
+	case errors.Is(err, ErrImagePulling):
+		status.State = PullStatePulling
+	default:
+		status.State = PullStateFailed
+		status.Error = err.Error()
+	}
+	return p.setStatus(status)
+}
+
+func (p *PrePuller) setStatus(status ImageStatus) ImageStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	status.UpdatedAt = time.Now()
+	p.statuses[status.Ref] = &status
+	return status
+}
+
+func (p *PrePuller) HandlePullDone(evt PullDoneEvent) {
+	p.mu.Lock()
+	_, ok := p.statuses[evt.Ref]
+	p.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	status := ImageStatus{Ref: evt.Ref, State: PullStatePulled}
+	if evt.Err != nil {
+		status.State = PullStateFailed
+		status.Error = evt.Err.Error()
+	}
+	_ = p.setStatus(status)
+}
@@ -0,0 +1,195 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package oci
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/containerd/containerd/remotes"
+	"github.com/containerd/containerd/remotes/docker"
+	ociimage "github.com/ironcore-dev/ironcore-image/oci/image"
+	"github.com/ironcore-dev/ironcore-image/oci/remote"
+	orasdocker "oras.land/oras-go/pkg/auth/docker"
+)
+
+const (
+	dockerHubHost         = "docker.io"
+	dockerHubRegistryHost = "registry-1.docker.io"
+)
+
+type RegistryOptions struct {
+	// ConfigPaths are docker config.json files to read registry credentials from.
+	// Credentials are selected by the host of the registry (or mirror) being contacted.
+	// If empty, the default docker config is used.
+	ConfigPaths []string
+	// Mirrors maps a registry host to its mirrors, which are tried in order before the registry itself.
+	// A mirror is specified as host[:port][/path], optionally prefixed by http:// or https://. Like for
+	// containerd mirrors, /v2 is appended to the path unless it already ends with it.
+	Mirrors map[string][]string
+	// CAFiles are PEM encoded CA bundles trusted in addition to the system roots.
+	CAFiles []string
+}
+
+// Registry resolves images from remote registries, honoring mirrors, custom CAs and per-host credentials.
+type Registry struct {
+	resolver remotes.Resolver
+}
+
+var _ ociimage.Source = (*Registry)(nil)
+
+func NewRegistry(opts RegistryOptions) (*Registry, error) {
+	authClient, err := orasdocker.NewClient(opts.ConfigPaths...)
+	if err != nil {
+		return nil, fmt.Errorf("error loading registry credentials: %w", err)
+	}
+	credClient, ok := authClient.(*orasdocker.Client)
+	if !ok {
+		return nil, fmt.Errorf("unexpected registry credential client type %T", authClient)
+	}
+
+	httpClient, err := registryHTTPClient(opts.CAFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	mirrors := make(map[string][]docker.RegistryHost, len(opts.Mirrors))
+	for registry, registryMirrors := range opts.Mirrors {
+		for _, mirror := range registryMirrors {
+			host, err := parseMirror(mirror)
+			if err != nil {
+				return nil, fmt.Errorf("[registry %s] invalid mirror %q: %w", registry, mirror, err)
+			}
+			mirrors[registry] = append(mirrors[registry], host)
+		}
+	}
+
+	authorizer := docker.NewDockerAuthorizer(
+		docker.WithAuthClient(httpClient),
+		docker.WithAuthCreds(credClient.Credential),
+	)
+
+	hosts := func(registry string) ([]docker.RegistryHost, error) {
+		var res []docker.RegistryHost
+		for _, mirror := range mirrors[registry] {
+			mirror.Client = httpClient
+			mirror.Authorizer = authorizer
+			res = append(res, mirror)
+		}
+
+		host := registry
+		if host == dockerHubHost {
+			host = dockerHubRegistryHost
+		}
+		return append(res, docker.RegistryHost{
+			Client:       httpClient,
+			Authorizer:   authorizer,
+			Host:         host,
+			Scheme:       "https",
+			Path:         "/v2",
+			Capabilities: docker.HostCapabilityPull | docker.HostCapabilityResolve,
+		}), nil
+	}
+
+	return &Registry{
+		resolver: docker.NewResolver(docker.ResolverOptions{
+			Hosts: hosts,
+		}),
+	}, nil
+}
+
+func (r *Registry) Resolve(ctx context.Context, ref string) (ociimage.Image, error) {
+	name, desc, err := r.resolver.Resolve(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving %s: %w", ref, err)
+	}
+
+	fetcher, err := r.resolver.Fetcher(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("error getting fetcher for %s: %w", ref, err)
+	}
+
+	return remote.Image(fetcher, desc), nil
+}
+
+func parseMirror(mirror string) (docker.RegistryHost, error) {
+	if !strings.Contains(mirror, "://") {
+		mirror = "https://" + mirror
+	}
+
+	u, err := url.Parse(mirror)
+	if err != nil {
+		return docker.RegistryHost{}, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return docker.RegistryHost{}, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return docker.RegistryHost{}, fmt.Errorf("no host specified")
+	}
+
+	path := strings.TrimSuffix(u.Path, "/")
+	if !strings.HasSuffix(path, "/v2") {
+		path += "/v2"
+	}
+
+	return docker.RegistryHost{
+		Host:         u.Host,
+		Scheme:       u.Scheme,
+		Path:         path,
+		Capabilities: docker.HostCapabilityPull | docker.HostCapabilityResolve,
+	}, nil
+}
+
+func registryHTTPClient(caFiles []string) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if len(caFiles) == 0 {
+		return &http.Client{Transport: transport}, nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		return nil, fmt.Errorf("error loading system cert pool: %w", err)
+	}
+
+	for _, caFile := range caFiles {
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading ca file: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in ca file %s", caFile)
+		}
+	}
+
+	transport.TLSClientConfig = &tls.Config{
+		RootCAs:    pool,
+		MinVersion: tls.VersionTLS12,
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// ParseMirrors parses mirror specifications of the form <registry>=<mirror>[,<mirror>...].
+func ParseMirrors(specs []string) (map[string][]string, error) {
+	res := make(map[string][]string)
+	for _, spec := range specs {
+		registry, mirrors, ok := strings.Cut(spec, "=")
+		if !ok || registry == "" || mirrors == "" {
+			return nil, fmt.Errorf("invalid mirror specification %q, expected <registry>=<mirror>[,<mirror>...]", spec)
+		}
+
+		for _, mirror := range strings.Split(mirrors, ",") {
+			if mirror = strings.TrimSpace(mirror); mirror != "" {
+				res[registry] = append(res[registry], mirror)
+			}
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,45 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package oci
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Registry", func() {
+	DescribeTable("parseMirror",
+		func(mirror, scheme, host, path string) {
+			res, err := parseMirror(mirror)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.Scheme).To(Equal(scheme))
+			Expect(res.Host).To(Equal(host))
+			Expect(res.Path).To(Equal(path))
+		},
+		Entry("host", "mirror.example.org", "https", "mirror.example.org", "/v2"),
+		Entry("http host with port", "http://mirror.example.org:5000", "http", "mirror.example.org:5000", "/v2"),
+		Entry("path", "https://mirror.example.org/proxy/docker.io", "https", "mirror.example.org", "/proxy/docker.io/v2"),
+		Entry("path with trailing slash", "mirror.example.org/proxy/", "https", "mirror.example.org", "/proxy/v2"),
+		Entry("path ending with /v2", "mirror.example.org/proxy/v2/", "https", "mirror.example.org", "/proxy/v2"),
+	)
+
+	It("should reject invalid mirrors", func() {
+		_, err := parseMirror("ftp://mirror.example.org")
+		Expect(err).To(MatchError(ContainSubstring("unsupported scheme")))
+		_, err = parseMirror("https:///proxy")
+		Expect(err).To(MatchError(ContainSubstring("no host")))
+	})
+
+	It("should parse the mirrors of registries", func() {
+		Expect(ParseMirrors([]string{
+			"docker.io=mirror.example.org/proxy/docker.io, http://cache.local:5000",
+			"ghcr.io=mirror.example.org/proxy/ghcr.io",
+		})).To(Equal(map[string][]string{
+			"docker.io": {"mirror.example.org/proxy/docker.io", "http://cache.local:5000"},
+			"ghcr.io":   {"mirror.example.org/proxy/ghcr.io"},
+		}))
+		_, err := ParseMirrors([]string{"docker.io"})
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,219 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package oci
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/reference"
+	"github.com/ironcore-dev/ironcore-image/oci/image"
+)
+
+const (
+	cosignSimpleSigningMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+	cosignSignatureAnnotation    = "dev.cosignproject.cosign/signature"
+	cosignSignatureTagSuffix     = ".sig"
+
+	// maxSignaturePayloadSize bounds the simple signing payload read from the registry.
+	maxSignaturePayloadSize = 1 << 20
+)
+
+// ErrSignatureVerification is returned if an image is unsigned or none of its signatures is valid.
+// Pulls failing with it are not retried.
+var ErrSignatureVerification = errors.New("image signature verification failed")
+
+func IsSignatureVerificationError(err error) bool {
+	return errors.Is(err, ErrSignatureVerification)
+}
+
+// SignatureVerifier verifies an image resolved from a source before it is stored in the cache.
+type SignatureVerifier interface {
+	Verify(ctx context.Context, source image.Source, ref string, img image.Image) error
+}
+
+// CosignVerifier verifies cosign signatures stored in the registry next to the image
+// (<repository>:sha256-<hex>.sig) against a set of public keys.
+type CosignVerifier struct {
+	keys []crypto.PublicKey
+}
+
+var _ SignatureVerifier = (*CosignVerifier)(nil)
+
+// NewCosignVerifier creates a CosignVerifier trusting the PEM encoded public keys in the given files.
+func NewCosignVerifier(keyFiles []string) (*CosignVerifier, error) {
+	if len(keyFiles) == 0 {
+		return nil, fmt.Errorf("must specify at least one public key")
+	}
+
+	var keys []crypto.PublicKey
+	for _, keyFile := range keyFiles {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading public key file: %w", err)
+		}
+
+		fileKeys, err := parsePublicKeys(data)
+		if err != nil {
+			return nil, fmt.Errorf("[public key file %s] %w", keyFile, err)
+		}
+		keys = append(keys, fileKeys...)
+	}
+	return &CosignVerifier{keys: keys}, nil
+}
+
+func parsePublicKeys(data []byte) ([]crypto.PublicKey, error) {
+	var keys []crypto.PublicKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing public key: %w", err)
+		}
+		switch key.(type) {
+		case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+		default:
+			return nil, fmt.Errorf("unsupported public key type %T", key)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no public keys found")
+	}
+	return keys, nil
+}
+
+// cosignPayload is the subset of the cosign simple signing payload required for verification.
+type cosignPayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+	} `json:"critical"`
+}
+
+func (v *CosignVerifier) Verify(ctx context.Context, source image.Source, ref string, img image.Image) error {
+	dgst := img.Descriptor().Digest
+	spec, err := reference.Parse(ref)
+	if err != nil {
+		return fmt.Errorf("error parsing reference %s: %w", ref, err)
+	}
+
+	sigRef := fmt.Sprintf("%s:%s-%s%s", spec.Locator, dgst.Algorithm(), dgst.Encoded(), cosignSignatureTagSuffix)
+	sigImg, err := source.Resolve(ctx, sigRef)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return fmt.Errorf("%w: image %s (%s) is not signed", ErrSignatureVerification, ref, dgst)
+		}
+		return fmt.Errorf("error resolving signature %s: %w", sigRef, err)
+	}
+
+	layers, err := sigImg.Layers(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting signature layers: %w", err)
+	}
+
+	var errs []error
+	for _, layer := range layers {
+		desc := layer.Descriptor()
+		if desc.MediaType != cosignSimpleSigningMediaType {
+			continue
+		}
+
+		if err := v.verifyLayer(ctx, layer, dgst.String()); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		return nil
+	}
+	if len(errs) == 0 {
+		return fmt.Errorf("%w: no cosign signatures found for image %s (%s)", ErrSignatureVerification, ref, dgst)
+	}
+	return fmt.Errorf("%w: no valid signature for image %s (%s): %w", ErrSignatureVerification, ref, dgst, errors.Join(errs...))
+}
+
+func (v *CosignVerifier) verifyLayer(ctx context.Context, layer image.Layer, manifestDigest string) error {
+	desc := layer.Descriptor()
+	sig, err := base64.StdEncoding.DecodeString(desc.Annotations[cosignSignatureAnnotation])
+	if err != nil || len(sig) == 0 {
+		return fmt.Errorf("[signature %s] missing or malformed signature annotation", desc.Digest)
+	}
+
+	payload, err := readLayer(ctx, layer)
+	if err != nil {
+		return fmt.Errorf("[signature %s] %w", desc.Digest, err)
+	}
+	if err := desc.Digest.Validate(); err != nil || desc.Digest.Algorithm().FromBytes(payload) != desc.Digest {
+		return fmt.Errorf("[signature %s] payload does not match digest", desc.Digest)
+	}
+
+	if !v.verifySignature(payload, sig) {
+		return fmt.Errorf("[signature %s] not signed by any trusted key", desc.Digest)
+	}
+
+	p := &cosignPayload{}
+	if err := json.Unmarshal(payload, p); err != nil {
+		return fmt.Errorf("[signature %s] error decoding payload: %w", desc.Digest, err)
+	}
+	if p.Critical.Image.DockerManifestDigest != manifestDigest {
+		return fmt.Errorf("[signature %s] signs manifest %s instead of %s", desc.Digest, p.Critical.Image.DockerManifestDigest, manifestDigest)
+	}
+	return nil
+}
+
+func (v *CosignVerifier) verifySignature(payload, sig []byte) bool {
+	hash := sha256.Sum256(payload)
+	for _, key := range v.keys {
+		switch key := key.(type) {
+		case *ecdsa.PublicKey:
+			if ecdsa.VerifyASN1(key, hash[:], sig) {
+				return true
+			}
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], sig) == nil {
+				return true
+			}
+		case ed25519.PublicKey:
+			if ed25519.Verify(key, payload, sig) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func readLayer(ctx context.Context, layer image.Layer) ([]byte, error) {
+	rc, err := layer.Content(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting content: %w", err)
+	}
+	defer func() { _ = rc.Close() }()
+
+	data, err := io.ReadAll(io.LimitReader(rc, maxSignaturePayloadSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading content: %w", err)
+	}
+	if len(data) > maxSignaturePayloadSize {
+		return nil, fmt.Errorf("content exceeds %d bytes", maxSignaturePayloadSize)
+	}
+	return data, nil
+}
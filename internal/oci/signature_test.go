@@ -0,0 +1,154 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package oci_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/containerd/containerd/errdefs"
+	"github.com/ironcore-dev/ironcore-image/oci/image"
+	"github.com/ironcore-dev/libvirt-provider/internal/oci"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/opencontainers/go-digest"
+	ocispecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+type fakeLayer struct {
+	desc    ocispecv1.Descriptor
+	content []byte
+}
+
+func newFakeLayer(mediaType string, content []byte, annotations map[string]string) *fakeLayer {
+	return &fakeLayer{
+		desc: ocispecv1.Descriptor{
+			MediaType:   mediaType,
+			Digest:      digest.FromBytes(content),
+			Size:        int64(len(content)),
+			Annotations: annotations,
+		},
+		content: content,
+	}
+}
+
+func (l *fakeLayer) Descriptor() ocispecv1.Descriptor { return l.desc }
+
+func (l *fakeLayer) Content(context.Context) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(l.content)), nil
+}
+
+type fakeImage struct {
+	*fakeLayer
+	layers []image.Layer
+}
+
+func (i *fakeImage) Manifest(context.Context) (*ocispecv1.Manifest, error) {
+	return &ocispecv1.Manifest{}, nil
+}
+
+func (i *fakeImage) Config(context.Context) (image.Layer, error) {
+	return newFakeLayer(ocispecv1.MediaTypeImageConfig, []byte("{}"), nil), nil
+}
+
+func (i *fakeImage) Layers(context.Context) ([]image.Layer, error) { return i.layers, nil }
+
+type fakeSource map[string]image.Image
+
+func (s fakeSource) Resolve(_ context.Context, ref string) (image.Image, error) {
+	img, ok := s[ref]
+	if !ok {
+		return nil, fmt.Errorf("ref %s: %w", ref, errdefs.ErrNotFound)
+	}
+	return img, nil
+}
+
+var _ = Describe("CosignVerifier", func() {
+	const ref = "registry.example.org/os/image:v1"
+
+	var (
+		key      *ecdsa.PrivateKey
+		verifier *oci.CosignVerifier
+		img      *fakeImage
+		source   fakeSource
+	)
+
+	sign := func(key *ecdsa.PrivateKey, manifestDigest digest.Digest) image.Image {
+		payload := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"registry.example.org/os/image"},"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"}}`, manifestDigest))
+		hash := sha256.Sum256(payload)
+		sig, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+		Expect(err).NotTo(HaveOccurred())
+
+		return &fakeImage{
+			fakeLayer: newFakeLayer(ocispecv1.MediaTypeImageManifest, []byte("signature manifest"), nil),
+			layers: []image.Layer{
+				newFakeLayer("application/vnd.dev.cosign.simplesigning.v1+json", payload, map[string]string{
+					"dev.cosignproject.cosign/signature": base64.StdEncoding.EncodeToString(sig),
+				}),
+			},
+		}
+	}
+
+	sigRef := func() string {
+		dgst := img.Descriptor().Digest
+		return fmt.Sprintf("registry.example.org/os/image:%s-%s.sig", dgst.Algorithm(), dgst.Encoded())
+	}
+
+	BeforeEach(func() {
+		var err error
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+
+		der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		Expect(err).NotTo(HaveOccurred())
+		keyFile := filepath.Join(GinkgoT().TempDir(), "cosign.pub")
+		Expect(os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600)).To(Succeed())
+
+		verifier, err = oci.NewCosignVerifier([]string{keyFile})
+		Expect(err).NotTo(HaveOccurred())
+
+		img = &fakeImage{fakeLayer: newFakeLayer(ocispecv1.MediaTypeImageManifest, []byte("image manifest"), nil)}
+		source = fakeSource{ref: img}
+	})
+
+	It("should accept an image signed by a trusted key", func(ctx SpecContext) {
+		source[sigRef()] = sign(key, img.Descriptor().Digest)
+		Expect(verifier.Verify(ctx, source, ref, img)).To(Succeed())
+	})
+
+	It("should reject an unsigned image", func(ctx SpecContext) {
+		err := verifier.Verify(ctx, source, ref, img)
+		Expect(oci.IsSignatureVerificationError(err)).To(BeTrue())
+		Expect(err).To(MatchError(ContainSubstring("is not signed")))
+	})
+
+	It("should reject an image signed by an untrusted key", func(ctx SpecContext) {
+		otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		source[sigRef()] = sign(otherKey, img.Descriptor().Digest)
+
+		err = verifier.Verify(ctx, source, ref, img)
+		Expect(oci.IsSignatureVerificationError(err)).To(BeTrue())
+		Expect(err).To(MatchError(ContainSubstring("not signed by any trusted key")))
+	})
+
+	It("should reject a signature for another manifest", func(ctx SpecContext) {
+		source[sigRef()] = sign(key, digest.FromString("other manifest"))
+
+		err := verifier.Verify(ctx, source, ref, img)
+		Expect(oci.IsSignatureVerificationError(err)).To(BeTrue())
+		Expect(err).To(MatchError(ContainSubstring("instead of")))
+	})
+})
@@ -0,0 +1,169 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package oci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/containerd/containerd/errdefs"
+	"github.com/ironcore-dev/ironcore-image/oci/descriptormatcher"
+	"github.com/opencontainers/go-digest"
+	ocispecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// errCorruptedBlob is returned if a cached blob doesn't match its descriptor, e.g. after an unclean shutdown.
+var errCorruptedBlob = errors.New("corrupted blob")
+
+// verifiedBlob identifies the state of a blob file at the time its digest was verified.
+type verifiedBlob struct {
+	size    int64
+	modTime time.Time
+}
+
+// pendingBlob is a blob whose digest has to be verified since it changed after its last successful verification.
+type pendingBlob struct {
+	desc  ocispecv1.Descriptor
+	path  string
+	state verifiedBlob
+}
+
+// verifyResult is the result of verifying the pending blobs of a cached image.
+type verifyResult struct {
+	ref       string
+	verified  map[digest.Digest]verifiedBlob
+	corrupted []digest.Digest
+	err       error
+}
+
+// statBlob checks the size of the blob at path against its descriptor. It returns the blob if its digest has to be
+// verified, nil if it was verified in the same state before. It has to be called from the cache loop.
+func (c *LocalCache) statBlob(desc ocispecv1.Descriptor, path string) (*pendingBlob, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("error stating blob %s: %w", desc.Digest, err)
+	}
+	if stat.Size() != desc.Size {
+		return nil, fmt.Errorf("%w %s: size %d, expected %d", errCorruptedBlob, desc.Digest, stat.Size(), desc.Size)
+	}
+
+	state := verifiedBlob{size: stat.Size(), modTime: stat.ModTime()}
+	if c.verifiedBlobs[desc.Digest] == state {
+		return nil, nil
+	}
+	return &pendingBlob{desc: desc, path: path, state: state}, nil
+}
+
+// statImage checks the file layers of the image by their size. It returns the layers whose digest has to be
+// verified and the digests of missing or corrupted ones. It has to be called from the cache loop.
+func (c *LocalCache) statImage(img *Image) ([]pendingBlob, []digest.Digest, error) {
+	var (
+		pending   []pendingBlob
+		corrupted []digest.Digest
+		errs      []error
+	)
+	for _, layer := range []*FileLayer{img.RootFS, img.Kernel, img.InitRAMFs} {
+		if layer == nil {
+			continue
+		}
+
+		blob, err := c.statBlob(layer.Descriptor, layer.Path)
+		if err != nil {
+			if !errors.Is(err, errCorruptedBlob) && !errors.Is(err, os.ErrNotExist) {
+				return nil, nil, err
+			}
+			corrupted = append(corrupted, layer.Descriptor.Digest)
+			errs = append(errs, err)
+			continue
+		}
+		if blob != nil {
+			pending = append(pending, *blob)
+		}
+	}
+	return pending, corrupted, errors.Join(errs...)
+}
+
+// verifyBlobs computes the digests of the pending blobs of the image with the given ref. Computing the digest of a
+// large root fs takes a while, hence it is run outside the cache loop, which records the result.
+func verifyBlobs(ref string, blobs []pendingBlob) verifyResult {
+	res := verifyResult{ref: ref, verified: make(map[digest.Digest]verifiedBlob)}
+	var errs []error
+	for _, blob := range blobs {
+		dgst, err := digestFile(blob.desc.Digest.Algorithm(), blob.path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			res.corrupted = append(res.corrupted, blob.desc.Digest)
+			errs = append(errs, fmt.Errorf("%w %s: %w", errCorruptedBlob, blob.desc.Digest, err))
+		case err != nil:
+			errs = append(errs, fmt.Errorf("error computing digest of blob %s: %w", blob.desc.Digest, err))
+		case dgst != blob.desc.Digest:
+			res.corrupted = append(res.corrupted, blob.desc.Digest)
+			errs = append(errs, fmt.Errorf("%w %s: digest %s", errCorruptedBlob, blob.desc.Digest, dgst))
+		default:
+			res.verified[blob.desc.Digest] = blob.state
+		}
+	}
+	res.err = errors.Join(errs...)
+	return res
+}
+
+func digestFile(algorithm digest.Algorithm, path string) (digest.Digest, error) {
+	if !algorithm.Available() {
+		return "", fmt.Errorf("unsupported digest algorithm %s", algorithm)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	digester := algorithm.Digester()
+	if _, err := io.Copy(digester.Hash(), f); err != nil {
+		return "", err
+	}
+	return digester.Digest(), nil
+}
+
+// removeCorrupted removes the corrupted blobs, so they are fetched again by the next pull. Blobs may be shared by
+// several images, hence the refs of all images using a corrupted blob are removed as well, so none of them keeps
+// resolving to an image with a missing blob. It has to be called from the cache loop.
+func (c *LocalCache) removeCorrupted(ctx context.Context, ref string, blobs []digest.Digest) error {
+	if err := c.store.Delete(ctx, ref); err != nil {
+		return fmt.Errorf("error deleting ref %s: %w", ref, err)
+	}
+
+	ociImgs, err := c.store.Layout().Images(ctx)
+	if err != nil {
+		return fmt.Errorf("error listing images: %w", err)
+	}
+	for _, ociImg := range ociImgs {
+		imgBlobs, err := imageBlobs(ctx, ociImg)
+		if err != nil {
+			return err
+		}
+		if !slices.ContainsFunc(imgBlobs, func(dgst digest.Digest) bool { return slices.Contains(blobs, dgst) }) {
+			continue
+		}
+
+		desc := ociImg.Descriptor()
+		c.log.Info("Removing image sharing a corrupted blob", "Ref", desc.Annotations[ocispecv1.AnnotationRefName], "Digest", desc.Digest)
+		if err := c.store.Layout().Indexer().Delete(ctx, descriptormatcher.Digests(desc.Digest)); err != nil {
+			return fmt.Errorf("error removing image %s from index: %w", desc.Digest, err)
+		}
+	}
+
+	for _, dgst := range blobs {
+		delete(c.verifiedBlobs, dgst)
+		if err := c.store.Layout().Store().Delete(ctx, dgst); err != nil && !errdefs.IsNotFound(err) {
+			return fmt.Errorf("error deleting blob %s: %w", dgst, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package oci
+
+import (
+	"context"
+	"os"
+
+	ironcoreimage "github.com/ironcore-dev/ironcore-image"
+	"github.com/ironcore-dev/ironcore-image/oci/image"
+	"github.com/ironcore-dev/ironcore-image/oci/imageutil"
+	"github.com/ironcore-dev/ironcore-image/oci/store"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/opencontainers/go-digest"
+)
+
+var _ = Describe("Verification", func() {
+	const ref = "example.org/image:latest"
+
+	var (
+		ociStore *store.Store
+		cache    *LocalCache
+	)
+
+	newImage := func(rootFS, kernel string) image.Image {
+		img, err := imageutil.NewJSONConfigBuilder(ironcoreimage.Config{}, imageutil.WithMediaType(ironcoreimage.ConfigMediaType)).
+			BytesLayer([]byte(rootFS), imageutil.WithMediaType(ironcoreimage.RootFSLayerMediaType)).
+			BytesLayer([]byte(kernel), imageutil.WithMediaType(ironcoreimage.KernelLayerMediaType)).
+			BytesLayer([]byte("initramfs"), imageutil.WithMediaType(ironcoreimage.InitRAMFSLayerMediaType)).
+			Complete()
+		Expect(err).NotTo(HaveOccurred())
+		return img
+	}
+
+	resolve := func(ctx context.Context, ref string) (*Image, []pendingBlob, error) {
+		ociImg, err := ociStore.Resolve(ctx, ref)
+		Expect(err).NotTo(HaveOccurred())
+		return cache.resolveCachedImage(ctx, ref, ociImg)
+	}
+
+	verify := func(ctx context.Context, ref string) verifyResult {
+		_, pending, err := resolve(ctx, ref)
+		Expect(err).NotTo(HaveOccurred())
+		res := verifyBlobs(ref, pending)
+		for dgst, state := range res.verified {
+			cache.verifiedBlobs[dgst] = state
+		}
+		return res
+	}
+
+	BeforeEach(func() {
+		var err error
+		ociStore, err = store.New(GinkgoT().TempDir())
+		Expect(err).NotTo(HaveOccurred())
+
+		cache, err = NewLocalCache(GinkgoLogr, nil, ociStore, LocalCacheOptions{})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should only return the image once its blobs are verified", func(ctx SpecContext) {
+		Expect(ociStore.Push(ctx, ref, newImage("rootfs", "kernel"))).To(Succeed())
+
+		img, pending, err := resolve(ctx, ref)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(img).To(BeNil())
+		Expect(pending).To(HaveLen(3))
+
+		res := verify(ctx, ref)
+		Expect(res.err).NotTo(HaveOccurred())
+		Expect(res.corrupted).To(BeEmpty())
+		Expect(res.verified).To(HaveLen(3))
+
+		img, pending, err = resolve(ctx, ref)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pending).To(BeEmpty())
+		Expect(img).NotTo(BeNil())
+	})
+
+	It("should verify a blob again once its file changed", func(ctx SpecContext) {
+		Expect(ociStore.Push(ctx, ref, newImage("rootfs", "kernel"))).To(Succeed())
+		Expect(verify(ctx, ref).err).NotTo(HaveOccurred())
+		img, _, err := resolve(ctx, ref)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("overwriting the root fs with content of the same size")
+		Expect(os.WriteFile(img.RootFS.Path, []byte("rootFS"), 0644)).To(Succeed())
+
+		res := verify(ctx, ref)
+		Expect(res.err).To(MatchError(errCorruptedBlob))
+		Expect(res.corrupted).To(ConsistOf(img.RootFS.Descriptor.Digest))
+	})
+
+	It("should remove the ref and a blob of the wrong size", func(ctx SpecContext) {
+		Expect(ociStore.Push(ctx, ref, newImage("rootfs", "kernel"))).To(Succeed())
+		Expect(verify(ctx, ref).err).NotTo(HaveOccurred())
+		img, _, err := resolve(ctx, ref)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.WriteFile(img.RootFS.Path, []byte("truncated"), 0644)).To(Succeed())
+
+		_, _, err = resolve(ctx, ref)
+		Expect(err).To(MatchError(errCorruptedBlob))
+		_, err = ociStore.Resolve(ctx, ref)
+		Expect(err).To(HaveOccurred())
+		Expect(img.RootFS.Path).NotTo(BeAnExistingFile())
+		Expect(img.Kernel.Path).To(BeAnExistingFile())
+	})
+
+	It("should remove the images sharing a corrupted blob", func(ctx SpecContext) {
+		const (
+			sharingRef = "example.org/sharing:latest"
+			otherRef   = "example.org/other:latest"
+		)
+		Expect(ociStore.Push(ctx, ref, newImage("rootfs", "kernel"))).To(Succeed())
+		Expect(ociStore.Push(ctx, sharingRef, newImage("rootfs", "other kernel"))).To(Succeed())
+		Expect(ociStore.Push(ctx, otherRef, newImage("other rootfs", "kernel"))).To(Succeed())
+
+		Expect(verify(ctx, ref).err).NotTo(HaveOccurred())
+		img, _, err := resolve(ctx, ref)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cache.removeCorrupted(ctx, ref, []digest.Digest{img.RootFS.Descriptor.Digest})).To(Succeed())
+
+		_, err = ociStore.Resolve(ctx, ref)
+		Expect(err).To(HaveOccurred())
+		_, err = ociStore.Resolve(ctx, sharingRef)
+		Expect(err).To(HaveOccurred())
+
+		By("keeping the images not using the corrupted blob")
+		_, err = ociStore.Resolve(ctx, otherRef)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(img.RootFS.Path).NotTo(BeAnExistingFile())
+		Expect(img.Kernel.Path).To(BeAnExistingFile())
+	})
+
+	It("should report an image as pulling while its blobs are verified", func(ctx SpecContext) {
+		Expect(ociStore.Push(ctx, ref, newImage("rootfs", "kernel"))).To(Succeed())
+
+		events := make(chan PullDoneEvent, 1)
+		cache.AddListener(ListenerFuncs{HandlePullDoneFunc: func(evt PullDoneEvent) { events <- evt }})
+		cacheCtx, cancel := context.WithCancel(ctx)
+		DeferCleanup(cancel)
+		go func() {
+			defer GinkgoRecover()
+			Expect(cache.Start(cacheCtx)).To(Succeed())
+		}()
+
+		Eventually(func() error {
+			_, err := cache.Get(ctx, ref)
+			return err
+		}).Should(MatchError(ErrImagePulling))
+
+		var evt PullDoneEvent
+		Eventually(ctx, events).Should(Receive(&evt))
+		Expect(evt).To(Equal(PullDoneEvent{Ref: ref}))
+
+		img, err := cache.Get(ctx, ref)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(img.RootFS).NotTo(BeNil())
+	})
+})
@@ -0,0 +1,600 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package pcidevice passes PCI devices of the host, e.g. FPGAs, NVMe controllers or crypto accelerators, through
+// to machines. The devices are grouped into pools selecting them by address or by vendor and device ID, the
+// machines of a class claim devices of pools. GPUs are passed through as devices of a pool as well.
+package pcidevice
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/alias"
+	"libvirt.org/go/libvirtxml"
+)
+
+const (
+	DefaultSysfsRoot = "/sys"
+
+	// VFIODriver is the driver devices are bound to while they are passed through.
+	VFIODriver = "vfio-pci"
+
+	// pcieportDriver is the driver of PCIe ports. VFIO accepts ports bound to it in the IOMMU group of a device
+	// passed through.
+	pcieportDriver = "pcieport"
+
+	// AnyNUMANode stands for devices not attached to a particular NUMA node and claims on any node.
+	AnyNUMANode = -1
+)
+
+var (
+	ErrUnknownPool   = errors.New("unknown pci device pool")
+	ErrNoFreeDevices = errors.New("not enough free pci devices")
+	ErrConflict      = errors.New("pci device conflict")
+	ErrUnhealthy     = errors.New("unhealthy pci device")
+
+	addressPattern = regexp.MustCompile(`^([0-9a-f]{4}):([0-9a-f]{2}):([0-9a-f]{2})\.([0-7])$`)
+	idPattern      = regexp.MustCompile(`^([0-9a-f]{4}):([0-9a-f]{4})$`)
+	poolPattern    = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+)
+
+// Selector selects PCI devices either by Address, e.g. 0000:3b:00.0, or by VendorID and DeviceID, e.g.
+// 8086:0b25.
+type Selector struct {
+	Address  string
+	VendorID string
+	DeviceID string
+}
+
+// ParseSelector parses a PCI address or a vendor and device ID separated by a colon.
+func ParseSelector(s string) (Selector, error) {
+	s = strings.ToLower(s)
+	if addressPattern.MatchString(s) {
+		return Selector{Address: s}, nil
+	}
+	if match := idPattern.FindStringSubmatch(s); match != nil {
+		return Selector{VendorID: match[1], DeviceID: match[2]}, nil
+	}
+	return Selector{}, fmt.Errorf("invalid pci device selector %q: must be an address like 0000:3b:00.0 or a vendor and device id like 8086:0b25", s)
+}
+
+// ParsePool parses the selectors of a pool separated by +, e.g. 0000:3b:00.0+8086:0b25.
+func ParsePool(s string) ([]Selector, error) {
+	var selectors []Selector
+	for _, part := range strings.Split(s, "+") {
+		selector, err := ParseSelector(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, selector)
+	}
+	return selectors, nil
+}
+
+// ParseClaims parses the claims of the machines of a class, pool names with an optional device count separated
+// by +, e.g. fpga+nvme:2.
+func ParseClaims(s string) ([]api.PCIDeviceClaim, error) {
+	var claims []api.PCIDeviceClaim
+	for _, part := range strings.Split(s, "+") {
+		pool, countStr, hasCount := strings.Cut(strings.TrimSpace(part), ":")
+		if !poolPattern.MatchString(pool) {
+			return nil, fmt.Errorf("invalid pci device pool name %q", pool)
+		}
+
+		count := 1
+		if hasCount {
+			var err error
+			if count, err = strconv.Atoi(countStr); err != nil || count < 1 {
+				return nil, fmt.Errorf("invalid count %q of pci device pool %s: has to be a positive integer", countStr, pool)
+			}
+		}
+		claims = append(claims, api.PCIDeviceClaim{Pool: pool, Count: count})
+	}
+	return claims, nil
+}
+
+// Hostdev returns the hostdev passing the device with the given address through. The device has to be bound to
+// the VFIODriver already, libvirt doesn't manage it.
+func Hostdev(address string) (libvirtxml.DomainHostdev, error) {
+	match := addressPattern.FindStringSubmatch(address)
+	if match == nil {
+		return libvirtxml.DomainHostdev{}, fmt.Errorf("invalid pci address %q", address)
+	}
+
+	var parts [4]uint
+	for i := range parts {
+		value, err := strconv.ParseUint(match[i+1], 16, 32)
+		if err != nil {
+			return libvirtxml.DomainHostdev{}, fmt.Errorf("invalid pci address %q: %w", address, err)
+		}
+		parts[i] = uint(value)
+	}
+
+	return libvirtxml.DomainHostdev{
+		Managed: "no",
+		SubsysPCI: &libvirtxml.DomainHostdevSubsysPCI{
+			Source: &libvirtxml.DomainHostdevSubsysPCISource{
+				Address: &libvirtxml.DomainAddressPCI{
+					Domain:   &parts[0],
+					Bus:      &parts[1],
+					Slot:     &parts[2],
+					Function: &parts[3],
+				},
+			},
+		},
+		Alias: &libvirtxml.DomainAlias{
+			Name: alias.PCIDevice(address),
+		},
+	}, nil
+}
+
+// Manager discovers the devices of the pools in sysfs, tracks which machines claimed them and binds them to the
+// VFIODriver. The claims aren't persisted, they are restored from the machine status after a restart.
+type Manager struct {
+	sysfsRoot string
+	pools     map[string][]Selector
+
+	mu sync.Mutex
+	// claims are the ids of the machines claiming the devices, keyed by device address.
+	claims map[string]string
+	// unhealthy are the devices that failed their health check, keyed by device address. Unhealthy devices aren't
+	// claimed.
+	unhealthy map[string]unhealthyDevice
+}
+
+// NewManager creates a Manager for the given pools. If sysfsRoot is empty, DefaultSysfsRoot is used.
+func NewManager(sysfsRoot string, pools map[string][]Selector) *Manager {
+	if sysfsRoot == "" {
+		sysfsRoot = DefaultSysfsRoot
+	}
+	return &Manager{
+		sysfsRoot: sysfsRoot,
+		pools:     pools,
+		claims:    make(map[string]string),
+		unhealthy: make(map[string]unhealthyDevice),
+	}
+}
+
+func (m *Manager) devicesDir() string {
+	return filepath.Join(m.sysfsRoot, "bus", "pci", "devices")
+}
+
+func (m *Manager) deviceDir(address string) string {
+	return filepath.Join(m.devicesDir(), address)
+}
+
+func (m *Manager) readID(address, file string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(m.deviceDir(address), file))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(strings.TrimSpace(string(data)), "0x"), nil
+}
+
+func (m *Manager) matches(selectors []Selector, address string) (bool, error) {
+	for _, selector := range selectors {
+		if selector.Address != "" {
+			if selector.Address == address {
+				return true, nil
+			}
+			continue
+		}
+
+		vendorID, err := m.readID(address, "vendor")
+		if err != nil {
+			return false, fmt.Errorf("error reading vendor of pci device %s: %w", address, err)
+		}
+		deviceID, err := m.readID(address, "device")
+		if err != nil {
+			return false, fmt.Errorf("error reading device id of pci device %s: %w", address, err)
+		}
+		if selector.VendorID == vendorID && selector.DeviceID == deviceID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Devices returns the addresses of the devices of the pool present on the host in ascending order.
+func (m *Manager) Devices(pool string) ([]string, error) {
+	selectors, ok := m.pools[pool]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownPool, pool)
+	}
+
+	entries, err := os.ReadDir(m.devicesDir())
+	if err != nil {
+		return nil, fmt.Errorf("error reading pci devices: %w", err)
+	}
+
+	var addresses []string
+	for _, entry := range entries {
+		ok, err := m.matches(selectors, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			addresses = append(addresses, entry.Name())
+		}
+	}
+	slices.Sort(addresses)
+	return addresses, nil
+}
+
+// iommuGroup returns the addresses of the devices sharing the IOMMU group of the device. They can only be passed
+// through to the same machine.
+func (m *Manager) iommuGroup(address string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(m.deviceDir(address), "iommu_group", "devices"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("pci device %s has no iommu group, is the iommu enabled?", address)
+		}
+		return nil, fmt.Errorf("error reading iommu group of pci device %s: %w", address, err)
+	}
+
+	var addresses []string
+	for _, entry := range entries {
+		addresses = append(addresses, entry.Name())
+	}
+	return addresses, nil
+}
+
+// conflict returns an error if a device of the IOMMU group of the device is claimed by another machine.
+func (m *Manager) conflict(machineID, address string) error {
+	group, err := m.iommuGroup(address)
+	if err != nil {
+		return err
+	}
+	for _, member := range group {
+		if owner, ok := m.claims[member]; ok && owner != machineID {
+			return fmt.Errorf("%w: %s shares its iommu group with %s claimed by machine %s", ErrConflict, address, member, owner)
+		}
+	}
+	return nil
+}
+
+// NUMANode returns the NUMA node the device is attached to, or AnyNUMANode if the host doesn't report it.
+func (m *Manager) NUMANode(address string) (int, error) {
+	data, err := os.ReadFile(filepath.Join(m.deviceDir(address), "numa_node"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return AnyNUMANode, nil
+		}
+		return 0, fmt.Errorf("error reading numa node of pci device %s: %w", address, err)
+	}
+	node, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid numa node of pci device %s: %w", address, err)
+	}
+	if node < 0 {
+		return AnyNUMANode, nil
+	}
+	return node, nil
+}
+
+// Claim claims devices for the machine as requested by the claims. If the machine claimed devices already, e.g.
+// because the domain failed to start, they are returned again.
+func (m *Manager) Claim(machineID string, claims []api.PCIDeviceClaim) ([]api.PCIDeviceStatus, error) {
+	return m.ClaimOnNode(machineID, claims, AnyNUMANode)
+}
+
+// ClaimOnNode claims devices for the machine like Claim, but only devices attached to the NUMA node. Devices the
+// host doesn't report a NUMA node for are claimed on any node.
+func (m *Manager) ClaimOnNode(machineID string, claims []api.PCIDeviceClaim, node int) ([]api.PCIDeviceStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var (
+		claimed []api.PCIDeviceStatus
+		// added are the addresses of the devices claimed by this call, the devices claimed by the machine before
+		// stay claimed if it fails.
+		added []string
+	)
+	release := func() {
+		for _, address := range added {
+			delete(m.claims, address)
+		}
+	}
+
+	for _, claim := range claims {
+		devices, err := m.Devices(claim.Pool)
+		if err != nil {
+			release()
+			return nil, err
+		}
+
+		var lastErr error
+		count := 0
+		for _, address := range devices {
+			if count == claim.Count {
+				break
+			}
+			if owner, ok := m.claims[address]; ok {
+				// Devices claimed by the machine before are claimed again, unless claimed for another claim of
+				// the same call.
+				if owner == machineID && !slices.ContainsFunc(claimed, func(device api.PCIDeviceStatus) bool { return device.Address == address }) {
+					claimed = append(claimed, api.PCIDeviceStatus{Pool: claim.Pool, Address: address})
+					count++
+				}
+				continue
+			}
+			if node != AnyNUMANode {
+				deviceNode, err := m.NUMANode(address)
+				if err != nil {
+					release()
+					return nil, err
+				}
+				if deviceNode != AnyNUMANode && deviceNode != node {
+					continue
+				}
+			}
+			if device, ok := m.unhealthy[address]; ok {
+				lastErr = device.err
+				continue
+			}
+			if err := m.conflict(machineID, address); err != nil {
+				lastErr = err
+				continue
+			}
+
+			m.claims[address] = machineID
+			added = append(added, address)
+			claimed = append(claimed, api.PCIDeviceStatus{Pool: claim.Pool, Address: address})
+			count++
+		}
+		if count < claim.Count {
+			release()
+			if lastErr != nil {
+				return nil, fmt.Errorf("%w in pool %s: requested %d, %d free: %w", ErrNoFreeDevices, claim.Pool, claim.Count, count, lastErr)
+			}
+			if node != AnyNUMANode {
+				return nil, fmt.Errorf("%w in pool %s on numa node %d: requested %d, %d free", ErrNoFreeDevices, claim.Pool, node, claim.Count, count)
+			}
+			return nil, fmt.Errorf("%w in pool %s: requested %d, %d free", ErrNoFreeDevices, claim.Pool, claim.Count, count)
+		}
+	}
+	return claimed, nil
+}
+
+// Restore records the devices claimed by the machine before, e.g. read from its status after a restart.
+func (m *Manager) Restore(machineID string, devices []api.PCIDeviceStatus) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, device := range devices {
+		if owner, ok := m.claims[device.Address]; ok && owner != machineID {
+			return fmt.Errorf("%w: %s is claimed by machines %s and %s", ErrConflict, device.Address, owner, machineID)
+		}
+		m.claims[device.Address] = machineID
+	}
+	return nil
+}
+
+// Release releases the devices claimed by the machine.
+func (m *Manager) Release(machineID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for address, owner := range m.claims {
+		if owner == machineID {
+			delete(m.claims, address)
+		}
+	}
+}
+
+// driver returns the name of the driver the device is bound to. It is empty if the device is unbound.
+func (m *Manager) driver(address string) (string, error) {
+	target, err := os.Readlink(filepath.Join(m.deviceDir(address), "driver"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", fmt.Errorf("error reading driver of pci device %s: %w", address, err)
+	}
+	return filepath.Base(target), nil
+}
+
+func (m *Manager) write(path, value string) error {
+	return os.WriteFile(path, []byte(value), 0200)
+}
+
+// bind overrides the driver of the device, unbinds it from its current driver and probes it again.
+func (m *Manager) bind(address, driverOverride string) error {
+	dir := m.deviceDir(address)
+	if err := m.write(filepath.Join(dir, "driver_override"), driverOverride+"\n"); err != nil {
+		return fmt.Errorf("error overriding driver of pci device %s: %w", address, err)
+	}
+
+	driver, err := m.driver(address)
+	if err != nil {
+		return err
+	}
+	if driver != "" {
+		if err := m.write(filepath.Join(dir, "driver", "unbind"), address); err != nil {
+			return fmt.Errorf("error unbinding pci device %s from %s: %w", address, driver, err)
+		}
+	}
+
+	if err := m.write(filepath.Join(m.sysfsRoot, "bus", "pci", "drivers_probe"), address); err != nil {
+		return fmt.Errorf("error probing pci device %s: %w", address, err)
+	}
+	return nil
+}
+
+// BindVFIO binds the device and the other devices of its IOMMU group to the VFIODriver, unbinding them from their
+// host drivers. VFIO only passes a device through if no device of its group is used by the host. Unbound devices
+// and PCIe ports of the group are left alone.
+func (m *Manager) BindVFIO(address string) error {
+	group, err := m.iommuGroup(address)
+	if err != nil {
+		return err
+	}
+
+	for _, member := range group {
+		driver, err := m.driver(member)
+		if err != nil {
+			return err
+		}
+		if driver == VFIODriver || (member != address && (driver == "" || driver == pcieportDriver)) {
+			continue
+		}
+		if err := m.bind(member, VFIODriver); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnbindVFIO returns the device and the other devices of its IOMMU group from the VFIODriver to their host drivers.
+func (m *Manager) UnbindVFIO(address string) error {
+	group, err := m.iommuGroup(address)
+	if err != nil {
+		return err
+	}
+
+	for _, member := range group {
+		driver, err := m.driver(member)
+		if err != nil {
+			return err
+		}
+		if driver != VFIODriver {
+			continue
+		}
+		if err := m.bind(member, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fatalErrors returns the number of fatal PCIe errors AER reported for the device since the boot of the host. It
+// is zero if the host doesn't report AER errors.
+func (m *Manager) fatalErrors(address string) (uint64, error) {
+	f, err := os.Open(filepath.Join(m.deviceDir(address), "aer_dev_fatal"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("error reading aer errors of pci device %s: %w", address, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		if !ok || name != "TOTAL_ERR_FATAL" {
+			continue
+		}
+		count, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid aer errors of pci device %s: %w", address, err)
+		}
+		return count, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("error reading aer errors of pci device %s: %w", address, err)
+	}
+	return 0, nil
+}
+
+// Check checks the health of the device in sysfs: it has to be present on the bus and free of fatal PCIe errors.
+// Devices passed through to a running machine have to be bound to the VFIODriver as well, a host driver taking
+// them over breaks the machine. Failed checks return an error wrapping ErrUnhealthy.
+func (m *Manager) Check(address string, passedThrough bool) error {
+	if _, err := os.Stat(m.deviceDir(address)); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w %s: device is gone from the bus", ErrUnhealthy, address)
+		}
+		return fmt.Errorf("error checking pci device %s: %w", address, err)
+	}
+
+	fatal, err := m.fatalErrors(address)
+	if err != nil {
+		return err
+	}
+	if fatal > 0 {
+		return fmt.Errorf("%w %s: %d fatal pcie errors", ErrUnhealthy, address, fatal)
+	}
+
+	if passedThrough {
+		driver, err := m.driver(address)
+		if err != nil {
+			return err
+		}
+		if driver != VFIODriver {
+			if driver == "" {
+				driver = "no driver"
+			}
+			return fmt.Errorf("%w %s: bound to %s instead of %s", ErrUnhealthy, address, driver, VFIODriver)
+		}
+	}
+	return nil
+}
+
+// unhealthyDevice is a device of a pool that failed its health check with err.
+type unhealthyDevice struct {
+	pool string
+	err  error
+}
+
+// MarkUnhealthy marks the device unhealthy because of the error of its health check, so it isn't claimed anymore.
+// It returns whether the device was healthy before.
+func (m *Manager) MarkUnhealthy(device api.PCIDeviceStatus, err error) bool {
+	if !errors.Is(err, ErrUnhealthy) {
+		err = fmt.Errorf("%w %s: %w", ErrUnhealthy, device.Address, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.unhealthy[device.Address]
+	m.unhealthy[device.Address] = unhealthyDevice{pool: device.Pool, err: err}
+	return !ok
+}
+
+// MarkHealthy marks the device healthy again. It returns whether the device was unhealthy before.
+func (m *Manager) MarkHealthy(address string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.unhealthy[address]
+	delete(m.unhealthy, address)
+	return ok
+}
+
+// Unhealthy returns the unhealthy devices in ascending order of their address.
+func (m *Manager) Unhealthy() []api.PCIDeviceStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	devices := make([]api.PCIDeviceStatus, 0, len(m.unhealthy))
+	for address, device := range m.unhealthy {
+		devices = append(devices, api.PCIDeviceStatus{Pool: device.pool, Address: address})
+	}
+	slices.SortFunc(devices, func(a, b api.PCIDeviceStatus) int { return strings.Compare(a.Address, b.Address) })
+	return devices
+}
+
+// UnhealthyCount returns the number of unhealthy devices of the pool.
+func (m *Manager) UnhealthyCount(pool string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := 0
+	for _, device := range m.unhealthy {
+		if device.pool == pool {
+			count++
+		}
+	}
+	return count
+}
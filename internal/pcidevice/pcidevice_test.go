@@ -0,0 +1,267 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package pcidevice_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	. "github.com/ironcore-dev/libvirt-provider/internal/pcidevice"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/utils/ptr"
+)
+
+func writeFile(path, data string) {
+	Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+	Expect(os.WriteFile(path, []byte(data), 0644)).To(Succeed())
+}
+
+// addDevice adds a device bound to the driver in the iommu group to the sysfs at root.
+func addDevice(root, address, vendorID, deviceID, driver, group string) {
+	dir := filepath.Join(root, "bus", "pci", "devices", address)
+	writeFile(filepath.Join(dir, "vendor"), "0x"+vendorID+"\n")
+	writeFile(filepath.Join(dir, "device"), "0x"+deviceID+"\n")
+	writeFile(filepath.Join(dir, "driver_override"), "(null)\n")
+
+	if driver != "" {
+		driverDir := filepath.Join(root, "bus", "pci", "drivers", driver)
+		writeFile(filepath.Join(driverDir, "unbind"), "")
+		Expect(os.Symlink(driverDir, filepath.Join(dir, "driver"))).To(Succeed())
+	}
+
+	groupDir := filepath.Join(root, "kernel", "iommu_groups", group)
+	writeFile(filepath.Join(groupDir, "devices", address), "")
+	if _, err := os.Lstat(filepath.Join(dir, "iommu_group")); os.IsNotExist(err) {
+		Expect(os.Symlink(groupDir, filepath.Join(dir, "iommu_group"))).To(Succeed())
+	}
+}
+
+var _ = Describe("PCIDevice", func() {
+	It("should parse selectors", func() {
+		Expect(ParseSelector("0000:3B:00.0")).To(Equal(Selector{Address: "0000:3b:00.0"}))
+		Expect(ParseSelector("8086:0b25")).To(Equal(Selector{VendorID: "8086", DeviceID: "0b25"}))
+		_, err := ParseSelector("3b:00.0")
+		Expect(err).To(HaveOccurred())
+
+		Expect(ParsePool("0000:3b:00.0+8086:0b25")).To(Equal([]Selector{
+			{Address: "0000:3b:00.0"},
+			{VendorID: "8086", DeviceID: "0b25"},
+		}))
+	})
+
+	It("should parse claims", func() {
+		Expect(ParseClaims("fpga+nvme:2")).To(Equal([]api.PCIDeviceClaim{
+			{Pool: "fpga", Count: 1},
+			{Pool: "nvme", Count: 2},
+		}))
+		_, err := ParseClaims("fpga:0")
+		Expect(err).To(HaveOccurred())
+		_, err = ParseClaims("FPGA")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should return the hostdev of a device", func() {
+		hostdev, err := Hostdev("0000:3b:00.1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hostdev.Managed).To(Equal("no"))
+		Expect(hostdev.Alias.Name).To(Equal("ua-pcidevice-0000-3b-00.1"))
+		Expect(hostdev.SubsysPCI.Source.Address).To(SatisfyAll(
+			HaveField("Domain", Equal(ptr.To[uint](0))),
+			HaveField("Bus", Equal(ptr.To[uint](0x3b))),
+			HaveField("Slot", Equal(ptr.To[uint](0))),
+			HaveField("Function", Equal(ptr.To[uint](1))),
+		))
+	})
+
+	Describe("Manager", func() {
+		var (
+			root    string
+			manager *Manager
+		)
+
+		BeforeEach(func() {
+			root = GinkgoT().TempDir()
+			writeFile(filepath.Join(root, "bus", "pci", "drivers_probe"), "")
+
+			addDevice(root, "0000:3b:00.0", "1d0f", "cd01", "", "10")
+			addDevice(root, "0000:3c:00.0", "1d0f", "cd01", "", "11")
+			addDevice(root, "0000:5e:00.0", "144d", "a80a", "nvme", "20")
+			addDevice(root, "0000:5e:00.1", "144d", "a80a", "nvme", "20")
+			addDevice(root, "0000:00:1f.0", "8086", "a1c8", "lpc_ich", "30")
+
+			manager = NewManager(root, map[string][]Selector{
+				"fpga": {{VendorID: "1d0f", DeviceID: "cd01"}},
+				"nvme": {{Address: "0000:5e:00.0"}, {Address: "0000:5e:00.1"}},
+			})
+		})
+
+		It("should discover the devices of a pool", func() {
+			Expect(manager.Devices("fpga")).To(Equal([]string{"0000:3b:00.0", "0000:3c:00.0"}))
+			Expect(manager.Devices("nvme")).To(Equal([]string{"0000:5e:00.0", "0000:5e:00.1"}))
+			_, err := manager.Devices("gpu")
+			Expect(err).To(MatchError(ErrUnknownPool))
+		})
+
+		It("should claim free devices", func() {
+			Expect(manager.Claim("machine-1", []api.PCIDeviceClaim{{Pool: "fpga", Count: 1}})).To(Equal([]api.PCIDeviceStatus{
+				{Pool: "fpga", Address: "0000:3b:00.0"},
+			}))
+			By("claiming again for the same machine")
+			Expect(manager.Claim("machine-1", []api.PCIDeviceClaim{{Pool: "fpga", Count: 1}})).To(Equal([]api.PCIDeviceStatus{
+				{Pool: "fpga", Address: "0000:3b:00.0"},
+			}))
+
+			Expect(manager.Claim("machine-2", []api.PCIDeviceClaim{{Pool: "fpga", Count: 1}})).To(Equal([]api.PCIDeviceStatus{
+				{Pool: "fpga", Address: "0000:3c:00.0"},
+			}))
+			_, err := manager.Claim("machine-3", []api.PCIDeviceClaim{{Pool: "fpga", Count: 1}})
+			Expect(err).To(MatchError(ErrNoFreeDevices))
+
+			By("releasing the devices of a machine")
+			manager.Release("machine-1")
+			Expect(manager.Claim("machine-3", []api.PCIDeviceClaim{{Pool: "fpga", Count: 1}})).To(Equal([]api.PCIDeviceStatus{
+				{Pool: "fpga", Address: "0000:3b:00.0"},
+			}))
+		})
+
+		It("should claim devices of a numa node", func() {
+			writeFile(filepath.Join(root, "bus", "pci", "devices", "0000:3b:00.0", "numa_node"), "0\n")
+			writeFile(filepath.Join(root, "bus", "pci", "devices", "0000:3c:00.0", "numa_node"), "1\n")
+			writeFile(filepath.Join(root, "bus", "pci", "devices", "0000:5e:00.0", "numa_node"), "-1\n")
+			Expect(manager.NUMANode("0000:3c:00.0")).To(Equal(1))
+			Expect(manager.NUMANode("0000:5e:00.0")).To(Equal(AnyNUMANode))
+			Expect(manager.NUMANode("0000:5e:00.1")).To(Equal(AnyNUMANode))
+
+			Expect(manager.ClaimOnNode("machine-1", []api.PCIDeviceClaim{{Pool: "fpga", Count: 1}}, 1)).To(Equal([]api.PCIDeviceStatus{
+				{Pool: "fpga", Address: "0000:3c:00.0"},
+			}))
+			_, err := manager.ClaimOnNode("machine-2", []api.PCIDeviceClaim{{Pool: "fpga", Count: 1}}, 1)
+			Expect(err).To(MatchError(ErrNoFreeDevices))
+
+			By("claiming devices without numa node on any node")
+			Expect(manager.ClaimOnNode("machine-2", []api.PCIDeviceClaim{{Pool: "nvme", Count: 2}}, 1)).To(HaveLen(2))
+		})
+
+		It("should not claim devices sharing an iommu group with devices of other machines", func() {
+			Expect(manager.Restore("machine-1", []api.PCIDeviceStatus{{Pool: "nvme", Address: "0000:5e:00.0"}})).To(Succeed())
+
+			_, err := manager.Claim("machine-2", []api.PCIDeviceClaim{{Pool: "nvme", Count: 1}})
+			Expect(err).To(MatchError(ErrNoFreeDevices))
+			Expect(err).To(MatchError(ErrConflict))
+
+			Expect(manager.Claim("machine-1", []api.PCIDeviceClaim{{Pool: "nvme", Count: 2}})).To(ConsistOf(
+				api.PCIDeviceStatus{Pool: "nvme", Address: "0000:5e:00.0"},
+				api.PCIDeviceStatus{Pool: "nvme", Address: "0000:5e:00.1"},
+			))
+		})
+
+		It("should roll back partial claims", func() {
+			_, err := manager.Claim("machine-1", []api.PCIDeviceClaim{{Pool: "fpga", Count: 1}, {Pool: "nvme", Count: 3}})
+			Expect(err).To(MatchError(ErrNoFreeDevices))
+
+			Expect(manager.Claim("machine-2", []api.PCIDeviceClaim{{Pool: "fpga", Count: 2}})).To(HaveLen(2))
+		})
+
+		It("should keep the devices claimed before when rolling back partial claims", func() {
+			Expect(manager.Restore("machine-1", []api.PCIDeviceStatus{{Pool: "fpga", Address: "0000:3b:00.0"}})).To(Succeed())
+
+			_, err := manager.Claim("machine-1", []api.PCIDeviceClaim{{Pool: "fpga", Count: 1}, {Pool: "nvme", Count: 3}})
+			Expect(err).To(MatchError(ErrNoFreeDevices))
+
+			Expect(manager.Claim("machine-2", []api.PCIDeviceClaim{{Pool: "fpga", Count: 1}})).To(Equal([]api.PCIDeviceStatus{
+				{Pool: "fpga", Address: "0000:3c:00.0"},
+			}))
+		})
+
+		It("should reject conflicting restored claims", func() {
+			Expect(manager.Restore("machine-1", []api.PCIDeviceStatus{{Pool: "fpga", Address: "0000:3b:00.0"}})).To(Succeed())
+			Expect(manager.Restore("machine-2", []api.PCIDeviceStatus{{Pool: "fpga", Address: "0000:3b:00.0"}})).To(MatchError(ErrConflict))
+		})
+
+		It("should bind devices to vfio-pci", func() {
+			Expect(manager.BindVFIO("0000:3b:00.0")).To(Succeed())
+
+			Expect(os.ReadFile(filepath.Join(root, "bus", "pci", "devices", "0000:3b:00.0", "driver_override"))).To(BeEquivalentTo("vfio-pci\n"))
+			Expect(os.ReadFile(filepath.Join(root, "bus", "pci", "drivers_probe"))).To(BeEquivalentTo("0000:3b:00.0"))
+		})
+
+		It("should bind the other devices of the iommu group to vfio-pci", func() {
+			addDevice(root, "0000:5d:00.0", "8086", "2030", "pcieport", "20")
+			addDevice(root, "0000:5f:00.0", "8086", "0b25", "", "20")
+
+			Expect(manager.BindVFIO("0000:5e:00.0")).To(Succeed())
+
+			for _, address := range []string{"0000:5e:00.0", "0000:5e:00.1"} {
+				Expect(os.ReadFile(filepath.Join(root, "bus", "pci", "devices", address, "driver_override"))).To(BeEquivalentTo("vfio-pci\n"), "device %s", address)
+			}
+			Expect(os.ReadFile(filepath.Join(root, "bus", "pci", "drivers", "nvme", "unbind"))).To(BeEquivalentTo("0000:5e:00.1"))
+
+			By("leaving pcie ports and unbound devices alone")
+			for _, address := range []string{"0000:5d:00.0", "0000:5f:00.0"} {
+				Expect(os.ReadFile(filepath.Join(root, "bus", "pci", "devices", address, "driver_override"))).To(BeEquivalentTo("(null)\n"), "device %s", address)
+			}
+			Expect(os.ReadFile(filepath.Join(root, "bus", "pci", "drivers", "pcieport", "unbind"))).To(BeEmpty())
+		})
+
+		It("should return devices bound to vfio-pci to their host driver", func() {
+			addDevice(root, "0000:af:00.0", "1d0f", "cd01", VFIODriver, "40")
+			Expect(manager.BindVFIO("0000:af:00.0")).To(Succeed())
+			Expect(os.ReadFile(filepath.Join(root, "bus", "pci", "drivers_probe"))).To(BeEmpty())
+
+			Expect(manager.UnbindVFIO("0000:af:00.0")).To(Succeed())
+			Expect(os.ReadFile(filepath.Join(root, "bus", "pci", "devices", "0000:af:00.0", "driver_override"))).To(BeEquivalentTo("\n"))
+			Expect(os.ReadFile(filepath.Join(root, "bus", "pci", "drivers", VFIODriver, "unbind"))).To(BeEquivalentTo("0000:af:00.0"))
+			Expect(os.ReadFile(filepath.Join(root, "bus", "pci", "drivers_probe"))).To(BeEquivalentTo("0000:af:00.0"))
+
+			By("leaving devices bound to host drivers alone")
+			Expect(manager.UnbindVFIO("0000:5e:00.0")).To(Succeed())
+			Expect(os.ReadFile(filepath.Join(root, "bus", "pci", "drivers", "nvme", "unbind"))).To(BeEmpty())
+		})
+
+		It("should check the health of devices", func() {
+			addDevice(root, "0000:af:00.0", "1d0f", "cd01", VFIODriver, "40")
+			Expect(manager.Check("0000:af:00.0", true)).To(Succeed())
+
+			By("failing devices bound to a host driver while passed through")
+			Expect(manager.Check("0000:5e:00.0", false)).To(Succeed())
+			Expect(manager.Check("0000:5e:00.0", true)).To(MatchError(ContainSubstring("bound to nvme instead of vfio-pci")))
+			Expect(manager.Check("0000:3b:00.0", true)).To(MatchError(ErrUnhealthy))
+
+			By("failing devices with fatal pcie errors")
+			writeFile(filepath.Join(root, "bus", "pci", "devices", "0000:af:00.0", "aer_dev_fatal"), "Undefined 0\nDLP 0\nTOTAL_ERR_FATAL 1\n")
+			Expect(manager.Check("0000:af:00.0", true)).To(MatchError(ContainSubstring("1 fatal pcie errors")))
+
+			By("failing devices gone from the bus")
+			Expect(manager.Check("0000:d8:00.0", false)).To(MatchError(ContainSubstring("gone from the bus")))
+		})
+
+		It("should not claim unhealthy devices", func() {
+			device := api.PCIDeviceStatus{Pool: "fpga", Address: "0000:3b:00.0"}
+			Expect(manager.MarkUnhealthy(device, errors.New("device is gone from the bus"))).To(BeTrue())
+			Expect(manager.MarkUnhealthy(device, errors.New("device is gone from the bus"))).To(BeFalse())
+			Expect(manager.Unhealthy()).To(Equal([]api.PCIDeviceStatus{device}))
+			Expect(manager.UnhealthyCount("fpga")).To(Equal(1))
+			Expect(manager.UnhealthyCount("nvme")).To(BeZero())
+
+			Expect(manager.Claim("machine-1", []api.PCIDeviceClaim{{Pool: "fpga", Count: 1}})).To(Equal([]api.PCIDeviceStatus{
+				{Pool: "fpga", Address: "0000:3c:00.0"},
+			}))
+			_, err := manager.Claim("machine-2", []api.PCIDeviceClaim{{Pool: "fpga", Count: 1}})
+			Expect(err).To(MatchError(ErrNoFreeDevices))
+			Expect(err).To(MatchError(ErrUnhealthy))
+			Expect(err).To(MatchError(ContainSubstring("0000:3b:00.0: device is gone from the bus")))
+
+			By("claiming devices healthy again")
+			Expect(manager.MarkHealthy("0000:3b:00.0")).To(BeTrue())
+			Expect(manager.MarkHealthy("0000:3b:00.0")).To(BeFalse())
+			Expect(manager.UnhealthyCount("fpga")).To(BeZero())
+			Expect(manager.Claim("machine-2", []api.PCIDeviceClaim{{Pool: "fpga", Count: 1}})).To(Equal([]api.PCIDeviceStatus{
+				{Pool: "fpga", Address: "0000:3b:00.0"},
+			}))
+		})
+	})
+})
@@ -0,0 +1,189 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package placement decides the NUMA node of machines once for all their NUMA sensitive resources: the PCI
+// devices passed through, the hugepages backing their memory and the host cpus of their vCPUs. Deciding them
+// independently places e.g. a GPU on another node than the memory of the machine using it.
+package placement
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/hugepages"
+	"github.com/ironcore-dev/libvirt-provider/internal/pcidevice"
+)
+
+// AnyNode is the node of placements not bound to a NUMA node.
+const AnyNode = pcidevice.AnyNUMANode
+
+var ErrNoNode = errors.New("no numa node fits the machine")
+
+// Request describes the NUMA sensitive resources of a machine.
+type Request struct {
+	MachineID string
+
+	// PCIDeviceClaims are the PCI devices to claim for the machine.
+	PCIDeviceClaims []api.PCIDeviceClaim
+	// PCIDevices are the devices claimed by the machine before. They are kept and no devices are claimed.
+	PCIDevices []api.PCIDeviceStatus
+
+	// HugepageSize is the size of the hugepages backing the memory, zero if it isn't backed by hugepages.
+	HugepageSize int64
+	// Hugepages is the number of hugepages backing the memory.
+	Hugepages uint64
+}
+
+// Placement is the NUMA placement of a machine.
+type Placement struct {
+	// Node is the NUMA node the machine is placed on, AnyNode if it isn't bound to a node.
+	Node int
+	// CPUSet are the host cpus of the node the vCPUs are restricted to, empty for machines not bound to a node.
+	CPUSet string
+	// PCIDevices are the devices claimed by the machine.
+	PCIDevices []api.PCIDeviceStatus
+}
+
+// Coordinator places machines on the NUMA nodes of the host.
+type Coordinator interface {
+	// Place places the machine and claims its PCI devices. Placements failing for lack of resources return an
+	// error wrapping ErrNoNode.
+	Place(req Request) (*Placement, error)
+}
+
+// NUMACoordinator places machines on a single NUMA node holding their PCI devices and hugepages. Of those, the
+// node with the most free hugepages is preferred. Machines without hugepages whose devices don't fit on a single
+// node are placed on any nodes instead.
+type NUMACoordinator struct {
+	hugepages  *hugepages.Manager
+	pciDevices *pcidevice.Manager
+}
+
+// NewNUMACoordinator creates a NUMACoordinator. pciDevices is nil if no PCI devices are passed through.
+func NewNUMACoordinator(hugepages *hugepages.Manager, pciDevices *pcidevice.Manager) *NUMACoordinator {
+	return &NUMACoordinator{
+		hugepages:  hugepages,
+		pciDevices: pciDevices,
+	}
+}
+
+func (c *NUMACoordinator) Place(req Request) (*Placement, error) {
+	if len(req.PCIDeviceClaims) == 0 && len(req.PCIDevices) == 0 && req.HugepageSize == 0 {
+		return &Placement{Node: AnyNode}, nil
+	}
+	if len(req.PCIDeviceClaims) > 0 && c.pciDevices == nil {
+		return nil, fmt.Errorf("cannot claim pci devices: no pci device pools configured")
+	}
+
+	devicesNode, err := c.devicesNode(req.PCIDevices)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.PCIDevices) > 0 && devicesNode == AnyNode && req.HugepageSize == 0 {
+		return &Placement{Node: AnyNode, PCIDevices: req.PCIDevices}, nil
+	}
+
+	nodes, err := c.candidates(req, devicesNode)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, node := range nodes {
+		devices, err := c.claim(req, node)
+		if err != nil {
+			if errors.Is(err, pcidevice.ErrNoFreeDevices) {
+				lastErr = err
+				continue
+			}
+			return nil, err
+		}
+		return c.placement(node, devices)
+	}
+
+	if req.HugepageSize == 0 {
+		// The memory isn't bound to a node, the devices may be spread over the nodes.
+		devices, err := c.claim(req, AnyNode)
+		if err != nil {
+			if errors.Is(err, pcidevice.ErrNoFreeDevices) {
+				return nil, fmt.Errorf("%w: %w", ErrNoNode, err)
+			}
+			return nil, err
+		}
+		return &Placement{Node: AnyNode, PCIDevices: devices}, nil
+	}
+	if lastErr != nil {
+		return nil, fmt.Errorf("%w with %d free hugepages of size %d: %w", ErrNoNode, req.Hugepages, req.HugepageSize, lastErr)
+	}
+	return nil, fmt.Errorf("%w with %d free hugepages of size %d", ErrNoNode, req.Hugepages, req.HugepageSize)
+}
+
+// devicesNode returns the NUMA node all the devices are attached to, AnyNode if they are attached to different
+// nodes or the host doesn't report their node.
+func (c *NUMACoordinator) devicesNode(devices []api.PCIDeviceStatus) (int, error) {
+	if len(devices) == 0 || c.pciDevices == nil {
+		return AnyNode, nil
+	}
+
+	node := AnyNode
+	for i, device := range devices {
+		deviceNode, err := c.pciDevices.NUMANode(device.Address)
+		if err != nil {
+			return 0, err
+		}
+		if deviceNode == AnyNode || (i > 0 && deviceNode != node) {
+			return AnyNode, nil
+		}
+		node = deviceNode
+	}
+	return node, nil
+}
+
+// candidates returns the NUMA nodes the machine may be placed on in the order of preference: the nodes with enough
+// free hugepages by their free pages, all nodes otherwise. Machines keeping their devices are placed on their
+// node.
+func (c *NUMACoordinator) candidates(req Request, devicesNode int) ([]int, error) {
+	nodes, err := c.hugepages.Nodes()
+	if err != nil {
+		return nil, err
+	}
+	if devicesNode != AnyNode {
+		nodes = slices.DeleteFunc(nodes, func(node int) bool { return node != devicesNode })
+	}
+	if req.HugepageSize == 0 {
+		return nodes, nil
+	}
+
+	free := make(map[int]uint64, len(nodes))
+	for _, node := range nodes {
+		if free[node], err = c.hugepages.FreePages(node, req.HugepageSize); err != nil {
+			return nil, err
+		}
+	}
+	nodes = slices.DeleteFunc(nodes, func(node int) bool { return free[node] < req.Hugepages })
+	slices.SortStableFunc(nodes, func(a, b int) int { return cmp.Compare(free[b], free[a]) })
+	return nodes, nil
+}
+
+// claim claims the PCI devices of the machine on the node, or returns the devices claimed before.
+func (c *NUMACoordinator) claim(req Request, node int) ([]api.PCIDeviceStatus, error) {
+	if len(req.PCIDevices) > 0 || len(req.PCIDeviceClaims) == 0 {
+		return req.PCIDevices, nil
+	}
+	return c.pciDevices.ClaimOnNode(req.MachineID, req.PCIDeviceClaims, node)
+}
+
+func (c *NUMACoordinator) placement(node int, devices []api.PCIDeviceStatus) (*Placement, error) {
+	cpus, err := c.hugepages.NodeCPUs(node)
+	if err != nil {
+		return nil, err
+	}
+	return &Placement{
+		Node:       node,
+		CPUSet:     cpus,
+		PCIDevices: devices,
+	}, nil
+}
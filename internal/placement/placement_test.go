@@ -0,0 +1,125 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package placement_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/hugepages"
+	"github.com/ironcore-dev/libvirt-provider/internal/pcidevice"
+	. "github.com/ironcore-dev/libvirt-provider/internal/placement"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func writeFile(path, data string) {
+	Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+	Expect(os.WriteFile(path, []byte(data), 0644)).To(Succeed())
+}
+
+var _ = Describe("NUMACoordinator", func() {
+	var (
+		root        string
+		pciDevices  *pcidevice.Manager
+		coordinator *NUMACoordinator
+	)
+
+	// addNode adds a numa node with the free 1Gi hugepages and cpus to the sysfs.
+	addNode := func(node, free, cpus string) {
+		dir := filepath.Join(root, "devices", "system", "node", "node"+node)
+		writeFile(filepath.Join(dir, "hugepages", "hugepages-1048576kB", "free_hugepages"), free+"\n")
+		writeFile(filepath.Join(dir, "cpulist"), cpus+"\n")
+	}
+
+	// addGPU adds a gpu attached to the numa node in an iommu group of its own to the sysfs.
+	addGPU := func(address, node string) {
+		dir := filepath.Join(root, "bus", "pci", "devices", address)
+		writeFile(filepath.Join(dir, "vendor"), "0x10de\n")
+		writeFile(filepath.Join(dir, "device"), "0x2330\n")
+		writeFile(filepath.Join(dir, "numa_node"), node+"\n")
+		groupDir := filepath.Join(root, "kernel", "iommu_groups", address)
+		writeFile(filepath.Join(groupDir, "devices", address), "")
+		Expect(os.Symlink(groupDir, filepath.Join(dir, "iommu_group"))).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		root = GinkgoT().TempDir()
+		addNode("0", "2", "0-7")
+		addNode("1", "8", "8-15")
+		addGPU("0000:17:00.0", "0")
+
+		pciDevices = pcidevice.NewManager(root, map[string][]pcidevice.Selector{
+			"gpu": {{VendorID: "10de", DeviceID: "2330"}},
+		})
+		coordinator = NewNUMACoordinator(hugepages.NewManager(root), pciDevices)
+	})
+
+	It("should not bind machines without pci devices and hugepages to a node", func() {
+		Expect(coordinator.Place(Request{MachineID: "foo"})).To(Equal(&Placement{Node: AnyNode}))
+	})
+
+	It("should place hugepages on the node with the most free pages", func() {
+		Expect(coordinator.Place(Request{MachineID: "foo", HugepageSize: hugepages.Size1Gi, Hugepages: 2})).To(Equal(&Placement{
+			Node:   1,
+			CPUSet: "8-15",
+		}))
+
+		_, err := coordinator.Place(Request{MachineID: "foo", HugepageSize: hugepages.Size1Gi, Hugepages: 9})
+		Expect(err).To(MatchError(ErrNoNode))
+	})
+
+	It("should place hugepages on the node of the pci devices", func() {
+		Expect(coordinator.Place(Request{
+			MachineID:       "foo",
+			PCIDeviceClaims: []api.PCIDeviceClaim{{Pool: "gpu", Count: 1}},
+			HugepageSize:    hugepages.Size1Gi,
+			Hugepages:       2,
+		})).To(Equal(&Placement{
+			Node:       0,
+			CPUSet:     "0-7",
+			PCIDevices: []api.PCIDeviceStatus{{Pool: "gpu", Address: "0000:17:00.0"}},
+		}))
+
+		By("failing if the node of the free pci devices has too few hugepages")
+		addGPU("0000:18:00.0", "0")
+		_, err := coordinator.Place(Request{
+			MachineID:       "bar",
+			PCIDeviceClaims: []api.PCIDeviceClaim{{Pool: "gpu", Count: 1}},
+			HugepageSize:    hugepages.Size1Gi,
+			Hugepages:       4,
+		})
+		Expect(err).To(MatchError(ErrNoNode))
+		Expect(err).To(MatchError(pcidevice.ErrNoFreeDevices))
+	})
+
+	It("should keep the node of the pci devices claimed before", func() {
+		addGPU("0000:b1:00.0", "1")
+		devices := []api.PCIDeviceStatus{{Pool: "gpu", Address: "0000:17:00.0"}}
+
+		Expect(coordinator.Place(Request{
+			MachineID:       "foo",
+			PCIDeviceClaims: []api.PCIDeviceClaim{{Pool: "gpu", Count: 1}},
+			PCIDevices:      devices,
+			HugepageSize:    hugepages.Size1Gi,
+			Hugepages:       1,
+		})).To(Equal(&Placement{Node: 0, CPUSet: "0-7", PCIDevices: devices}))
+	})
+
+	It("should spread pci devices over the nodes for machines without hugepages", func() {
+		addGPU("0000:b1:00.0", "1")
+
+		Expect(coordinator.Place(Request{
+			MachineID:       "foo",
+			PCIDeviceClaims: []api.PCIDeviceClaim{{Pool: "gpu", Count: 2}},
+		})).To(Equal(&Placement{
+			Node: AnyNode,
+			PCIDevices: []api.PCIDeviceStatus{
+				{Pool: "gpu", Address: "0000:17:00.0"},
+				{Pool: "gpu", Address: "0000:b1:00.0"},
+			},
+		}))
+	})
+})
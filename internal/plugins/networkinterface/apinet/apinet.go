@@ -14,6 +14,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/go-logr/logr"
 	"github.com/google/uuid"
 	apinetv1alpha1 "github.com/ironcore-dev/ironcore-net/api/core/v1alpha1"
 	apinet "github.com/ironcore-dev/ironcore-net/apimachinery/api/net"
@@ -21,6 +22,7 @@ import (
 	"github.com/ironcore-dev/libvirt-provider/api"
 	providerhost "github.com/ironcore-dev/libvirt-provider/internal/host"
 	providernetworkinterface "github.com/ironcore-dev/libvirt-provider/internal/plugins/networkinterface"
+	"github.com/ironcore-dev/libvirt-provider/internal/retrypolicy"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -61,10 +63,46 @@ func (p *Plugin) Init(host providerhost.Host) error {
 	return nil
 }
 
-func ironcoreIPsToAPInetIPs(ips []string) []apinet.IP {
-	res := make([]apinet.IP, len(ips))
-	for i, ip := range ips {
-		res[i] = apinet.MustParseIP(ip)
+// ironcoreIPsToAPInetIPs parses the IPs of a network interface. A dual-stack network interface has IPs of both
+// families.
+func ironcoreIPsToAPInetIPs(ips []string) ([]apinet.IP, error) {
+	res := make([]apinet.IP, 0, len(ips))
+	for _, ip := range ips {
+		apinetIP, err := apinet.ParseIP(ip)
+		if err != nil {
+			return nil, retrypolicy.Permanent(fmt.Errorf("invalid ip %q: %w", ip, err))
+		}
+		res = append(res, apinetIP)
+	}
+	return res, nil
+}
+
+// ipFamilies returns the addresses of the ready apinet network interface per family, the internal ones of its
+// spec and the public and NAT ones apinet reports in its status.
+func ipFamilies(apinetNic *apinetv1alpha1.NetworkInterface) []api.NetworkInterfaceIPFamilyStatus {
+	var res []api.NetworkInterfaceIPFamilyStatus
+	for _, family := range []corev1.IPFamily{corev1.IPv4Protocol, corev1.IPv6Protocol} {
+		ips := ipsOfFamily(apinetNic.Spec.IPs, family)
+		if len(ips) == 0 {
+			continue
+		}
+
+		res = append(res, api.NetworkInterfaceIPFamilyStatus{
+			Family:    api.IPFamily(family),
+			IPs:       ips,
+			PublicIPs: ipsOfFamily(apinetNic.Status.PublicIPs, family),
+			NATIPs:    ipsOfFamily(apinetNic.Status.NATIPs, family),
+		})
+	}
+	return res
+}
+
+func ipsOfFamily(ips []apinet.IP, family corev1.IPFamily) []string {
+	var res []string
+	for _, ip := range ips {
+		if ip.Family() == family {
+			res = append(res, ip.String())
+		}
 	}
 	return res
 }
@@ -103,9 +141,56 @@ func (p *Plugin) APInetNicName(machineID string, networkInterfaceName string) st
 	return uuid.NewHash(sha256.New(), uuid.Nil, []byte(fmt.Sprintf("%s/%s", machineID, networkInterfaceName)), 5).String()
 }
 
+func (p *Plugin) Capabilities() providernetworkinterface.Capabilities {
+	return providernetworkinterface.Capabilities{UpdateInPlace: true}
+}
+
 func (p *Plugin) Apply(ctx context.Context, spec *api.NetworkInterfaceSpec, machine *api.Machine) (*providernetworkinterface.NetworkInterface, error) {
 	log := ctrl.LoggerFrom(ctx)
 
+	ips, err := ironcoreIPsToAPInetIPs(spec.Ips)
+	if err != nil {
+		return nil, err
+	}
+
+	apinetNic, err := p.applyAPInetNic(ctx, spec, machine, ips)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.waitForHostDevice(ctx, log, apinetNic)
+}
+
+// Update patches the IPs of the apinet network interface. The host device stays the same, so the guest device
+// doesn't have to be re-attached.
+func (p *Plugin) Update(ctx context.Context, spec *api.NetworkInterfaceSpec, machine *api.Machine) (*providernetworkinterface.NetworkInterface, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	if _, err := p.readAPINetNetworkInterfaceConfig(machine.ID, spec.Name); err != nil {
+		return nil, fmt.Errorf("error reading APINet network interface config of applied network interface: %w", err)
+	}
+
+	ips, err := ironcoreIPsToAPInetIPs(spec.Ips)
+	if err != nil {
+		return nil, err
+	}
+
+	apinetNic, err := p.applyAPInetNic(ctx, spec, machine, ips)
+	if err != nil {
+		return nil, err
+	}
+
+	log.V(1).Info("Fetching updated apinet nic")
+	if err := p.apinetClient.Get(ctx, client.ObjectKeyFromObject(apinetNic), apinetNic); err != nil {
+		return nil, fmt.Errorf("error fetching updated apinet network interface: %w", err)
+	}
+
+	return p.waitForHostDevice(ctx, log, apinetNic)
+}
+
+func (p *Plugin) applyAPInetNic(ctx context.Context, spec *api.NetworkInterfaceSpec, machine *api.Machine, ips []apinet.IP) (*apinetv1alpha1.NetworkInterface, error) {
+	log := ctrl.LoggerFrom(ctx)
+
 	log.V(1).Info("Writing network interface dir")
 	if err := os.MkdirAll(p.host.MachineNetworkInterfaceDir(machine.ID, spec.Name), perm); err != nil {
 		return nil, err
@@ -113,7 +198,7 @@ func (p *Plugin) Apply(ctx context.Context, spec *api.NetworkInterfaceSpec, mach
 
 	apinetNamespace, apinetNetworkName, _, _, err := provider.ParseNetworkID(spec.NetworkId)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing ApiNet NetworkID %s: %w", spec.NetworkId, err)
+		return nil, retrypolicy.Permanent(fmt.Errorf("error parsing ApiNet NetworkID %s: %w", spec.NetworkId, err))
 	}
 
 	log.V(1).Info("Writing APINet network interface config file")
@@ -139,7 +224,7 @@ func (p *Plugin) Apply(ctx context.Context, spec *api.NetworkInterfaceSpec, mach
 			NodeRef: corev1.LocalObjectReference{
 				Name: p.nodeName,
 			},
-			IPs: ironcoreIPsToAPInetIPs(spec.Ips),
+			IPs: ips,
 		},
 	}
 
@@ -147,22 +232,17 @@ func (p *Plugin) Apply(ctx context.Context, spec *api.NetworkInterfaceSpec, mach
 	if err := p.apinetClient.Patch(ctx, apinetNic, client.Apply, fieldOwner, client.ForceOwnership); err != nil {
 		return nil, fmt.Errorf("error applying apinet network interface: %w", err)
 	}
+	return apinetNic, nil
+}
 
+func (p *Plugin) waitForHostDevice(ctx context.Context, log logr.Logger, apinetNic *apinetv1alpha1.NetworkInterface) (*providernetworkinterface.NetworkInterface, error) {
 	hostDev, err := getHostDevice(apinetNic)
 	if err != nil {
 		return nil, fmt.Errorf("error getting host device: %w", err)
 	}
 	if hostDev != nil {
 		log.V(1).Info("Host device is ready", "HostDevice", hostDev)
-		return &providernetworkinterface.NetworkInterface{
-			Handle: provider.GetNetworkInterfaceID(
-				apinetNic.Namespace,
-				apinetNic.Name,
-				apinetNic.Spec.NodeRef.Name,
-				apinetNic.UID,
-			),
-			HostDevice: hostDev,
-		}, nil
+		return providerNetworkInterface(apinetNic, hostDev), nil
 	}
 
 	log.V(1).Info("Waiting for apinet network interface to become ready")
@@ -186,6 +266,10 @@ func (p *Plugin) Apply(ctx context.Context, spec *api.NetworkInterfaceSpec, mach
 		return nil, fmt.Errorf("error fetching updated apinet network interface: %w", err)
 	}
 
+	return providerNetworkInterface(apinetNic, hostDev), nil
+}
+
+func providerNetworkInterface(apinetNic *apinetv1alpha1.NetworkInterface, hostDev *providernetworkinterface.HostDevice) *providernetworkinterface.NetworkInterface {
 	return &providernetworkinterface.NetworkInterface{
 		Handle: provider.GetNetworkInterfaceID(
 			apinetNic.Namespace,
@@ -194,7 +278,8 @@ func (p *Plugin) Apply(ctx context.Context, spec *api.NetworkInterfaceSpec, mach
 			apinetNic.UID,
 		),
 		HostDevice: hostDev,
-	}, nil
+		IPFamilies: ipFamilies(apinetNic),
+	}
 }
 
 func getHostDevice(apinetNic *apinetv1alpha1.NetworkInterface) (*providernetworkinterface.HostDevice, error) {
@@ -294,3 +379,12 @@ func (p *Plugin) Delete(ctx context.Context, computeNicName string, machineID st
 func (p *Plugin) Name() string {
 	return pluginAPInet
 }
+
+// Check verifies that the apinet API is reachable by getting the node of the provider.
+func (p *Plugin) Check(ctx context.Context) error {
+	node := &apinetv1alpha1.Node{}
+	if err := p.apinetClient.Get(ctx, client.ObjectKey{Name: p.nodeName}, node); err != nil {
+		return fmt.Errorf("error getting apinet node %s: %w", p.nodeName, err)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package apinet
+
+import (
+	apinetv1alpha1 "github.com/ironcore-dev/ironcore-net/api/core/v1alpha1"
+	apinet "github.com/ironcore-dev/ironcore-net/apimachinery/api/net"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/retrypolicy"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("APInet", func() {
+	Describe("ironcoreIPsToAPInetIPs", func() {
+		It("should parse the IPs of dual-stack network interfaces with several IPs per family", func() {
+			ips, err := ironcoreIPsToAPInetIPs([]string{"10.0.0.1", "fd00::1", "10.0.0.2"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ips).To(Equal([]apinet.IP{
+				apinet.MustParseIP("10.0.0.1"),
+				apinet.MustParseIP("fd00::1"),
+				apinet.MustParseIP("10.0.0.2"),
+			}))
+		})
+
+		It("should reject invalid IPs permanently", func() {
+			_, err := ironcoreIPsToAPInetIPs([]string{"10.0.0.1", "invalid"})
+			Expect(err).To(MatchError(ContainSubstring(`invalid ip "invalid"`)))
+			Expect(retrypolicy.ClassOf(err)).To(Equal(retrypolicy.ClassPermanent))
+		})
+	})
+
+	Describe("ipFamilies", func() {
+		It("should report the addresses per family", func() {
+			apinetNic := &apinetv1alpha1.NetworkInterface{
+				Spec: apinetv1alpha1.NetworkInterfaceSpec{
+					IPs: []apinet.IP{
+						apinet.MustParseIP("fd00::1"),
+						apinet.MustParseIP("10.0.0.1"),
+						apinet.MustParseIP("10.0.0.2"),
+					},
+				},
+				Status: apinetv1alpha1.NetworkInterfaceStatus{
+					PublicIPs: []apinet.IP{apinet.MustParseIP("192.0.2.1"), apinet.MustParseIP("2001:db8::1")},
+					NATIPs:    []apinet.IP{apinet.MustParseIP("192.0.2.2")},
+				},
+			}
+
+			Expect(ipFamilies(apinetNic)).To(Equal([]api.NetworkInterfaceIPFamilyStatus{
+				{
+					Family:    api.IPFamilyIPv4,
+					IPs:       []string{"10.0.0.1", "10.0.0.2"},
+					PublicIPs: []string{"192.0.2.1"},
+					NATIPs:    []string{"192.0.2.2"},
+				},
+				{
+					Family:    api.IPFamilyIPv6,
+					IPs:       []string{"fd00::1"},
+					PublicIPs: []string{"2001:db8::1"},
+				},
+			}))
+		})
+
+		It("should only report the families the network interface has IPs of", func() {
+			apinetNic := &apinetv1alpha1.NetworkInterface{
+				Spec: apinetv1alpha1.NetworkInterfaceSpec{IPs: []apinet.IP{apinet.MustParseIP("fd00::1")}},
+			}
+			Expect(ipFamilies(apinetNic)).To(ConsistOf(HaveField("Family", api.IPFamilyIPv6)))
+		})
+	})
+})
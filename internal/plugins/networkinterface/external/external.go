@@ -0,0 +1,298 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package external provides the network interface plugin served out-of-tree via pluginapi on a unix socket.
+package external
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	providerhost "github.com/ironcore-dev/libvirt-provider/internal/host"
+	providernetworkinterface "github.com/ironcore-dev/libvirt-provider/internal/plugins/networkinterface"
+	"github.com/ironcore-dev/libvirt-provider/pkg/pluginapi"
+)
+
+const (
+	// SocketSuffix is the suffix of the plugin sockets discovered in the plugins directory.
+	SocketSuffix = ".sock"
+
+	// PluginExternal is the name of the plugin until the external plugin was discovered.
+	PluginExternal = "external"
+
+	// discoveryTimeout bounds the time a discovered plugin has to respond with its info.
+	discoveryTimeout = 10 * time.Second
+)
+
+var errMultiplePlugins = errors.New("multiple external network interface plugins registered")
+
+type plugin struct {
+	// pluginName is the name of the external plugin to use, empty selects the only one registered.
+	pluginName string
+	dir        string
+	// name is the name of the discovered plugin, or the name it was selected by if it wasn't discovered on Init.
+	name        string
+	initialized bool
+
+	mu           sync.Mutex
+	client       *pluginapi.NetworkInterfacePluginClient
+	capabilities providernetworkinterface.Capabilities
+}
+
+// NewPlugin returns the network interface plugin forwarding to the external plugin named pluginName. The plugin is
+// discovered among the plugins serving a socket in the external network interface plugins directory. An empty
+// pluginName selects the only plugin registered there.
+func NewPlugin(pluginName string) providernetworkinterface.Plugin {
+	return &plugin{pluginName: pluginName}
+}
+
+// Init discovers the external plugin. If it isn't registered yet, e.g. because it starts after the provider, the
+// discovery is retried on every call and the plugin is unhealthy until then.
+func (p *plugin) Init(host providerhost.Host) error {
+	ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
+	defer cancel()
+
+	p.dir = host.ExternalNetworkInterfacePluginsDir()
+	p.name = p.pluginName
+	if p.name == "" {
+		p.name = PluginExternal
+	}
+
+	_, err := p.connection(ctx)
+	if errors.Is(err, errMultiplePlugins) {
+		return err
+	}
+	p.initialized = true
+	return nil
+}
+
+// connection returns the client of the external plugin, discovering the plugin if it wasn't yet.
+func (p *plugin) connection(ctx context.Context) (*pluginapi.NetworkInterfacePluginClient, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.client != nil {
+		return p.client, nil
+	}
+	if err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+	return p.client, nil
+}
+
+func (p *plugin) discover(ctx context.Context) error {
+	socketPaths, err := sockets(p.dir)
+	if err != nil {
+		return err
+	}
+	if p.pluginName == "" && len(socketPaths) > 1 {
+		return fmt.Errorf("%w in %s, select one by name", errMultiplePlugins, p.dir)
+	}
+
+	var errs []error
+	for _, socketPath := range socketPaths {
+		ok, err := p.connect(ctx, socketPath)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("[socket %s] %w", socketPath, err))
+			continue
+		}
+		if ok {
+			return nil
+		}
+	}
+
+	err = fmt.Errorf("external network interface plugin %s not registered in %s", p.pluginName, p.dir)
+	if p.pluginName == "" {
+		err = fmt.Errorf("no external network interface plugin registered in %s", p.dir)
+	}
+	return errors.Join(append([]error{err}, errs...)...)
+}
+
+// sockets returns the plugin sockets in dir. A missing dir means there are no external plugins.
+func sockets(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading external network interface plugins directory: %w", err)
+	}
+
+	var socketPaths []string
+	for _, entry := range entries {
+		if entry.Type()&os.ModeSocket == 0 || !strings.HasSuffix(entry.Name(), SocketSuffix) {
+			continue
+		}
+		socketPaths = append(socketPaths, filepath.Join(dir, entry.Name()))
+	}
+	return socketPaths, nil
+}
+
+// connect connects to the plugin serving the socket at socketPath and uses it if it's the selected plugin.
+func (p *plugin) connect(ctx context.Context, socketPath string) (bool, error) {
+	conn, err := pluginapi.Dial(socketPath)
+	if err != nil {
+		return false, fmt.Errorf("error connecting to plugin: %w", err)
+	}
+
+	client := pluginapi.NewNetworkInterfacePluginClient(conn)
+	info, err := client.Info(ctx, &pluginapi.NetworkInterfaceInfoRequest{})
+	if err != nil {
+		_ = conn.Close()
+		return false, fmt.Errorf("error getting plugin info: %w", err)
+	}
+	if info.Name == "" {
+		_ = conn.Close()
+		return false, fmt.Errorf("plugin doesn't specify a name")
+	}
+	if p.pluginName != "" && info.Name != p.pluginName {
+		_ = conn.Close()
+		return false, nil
+	}
+
+	// A plugin discovered after Init keeps the name it was registered with.
+	if !p.initialized {
+		p.name = info.Name
+	}
+	p.client = client
+	p.capabilities = providernetworkinterface.Capabilities{
+		UpdateInPlace: info.Capabilities.UpdateInPlace,
+		Models:        info.Capabilities.Models,
+		MACAddresses:  info.Capabilities.MACAddresses,
+	}
+	return true, nil
+}
+
+func (p *plugin) Name() string {
+	if p.name == "" {
+		return PluginExternal
+	}
+	return p.name
+}
+
+// Capabilities returns the capabilities of the plugin. Until the plugin is discovered, it has none.
+func (p *plugin) Capabilities() providernetworkinterface.Capabilities {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.capabilities
+}
+
+func (p *plugin) Apply(ctx context.Context, spec *api.NetworkInterfaceSpec, machine *api.Machine) (*providernetworkinterface.NetworkInterface, error) {
+	client, err := p.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := client.ApplyNetworkInterface(ctx, &pluginapi.ApplyNetworkInterfaceRequest{
+		NetworkInterface: spec,
+		Machine:          machine.Metadata,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("[plugin %s] error applying network interface: %w", p.name, err)
+	}
+
+	nic, err := convertNetworkInterface(res.NetworkInterface)
+	if err != nil {
+		return nil, fmt.Errorf("[plugin %s] invalid network interface: %w", p.name, err)
+	}
+	return nic, nil
+}
+
+func (p *plugin) Update(ctx context.Context, spec *api.NetworkInterfaceSpec, machine *api.Machine) (*providernetworkinterface.NetworkInterface, error) {
+	client, err := p.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := client.UpdateNetworkInterface(ctx, &pluginapi.ApplyNetworkInterfaceRequest{
+		NetworkInterface: spec,
+		Machine:          machine.Metadata,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("[plugin %s] error updating network interface: %w", p.name, err)
+	}
+
+	nic, err := convertNetworkInterface(res.NetworkInterface)
+	if err != nil {
+		return nil, fmt.Errorf("[plugin %s] invalid network interface: %w", p.name, err)
+	}
+	return nic, nil
+}
+
+func convertNetworkInterface(nic *pluginapi.NetworkInterface) (*providernetworkinterface.NetworkInterface, error) {
+	if nic == nil {
+		return nil, fmt.Errorf("no network interface returned")
+	}
+
+	var devices int
+	for _, set := range []bool{nic.HostDevice != nil, nic.Isolated, nic.ProviderNetwork != ""} {
+		if set {
+			devices++
+		}
+	}
+	if devices != 1 {
+		return nil, fmt.Errorf("exactly one of host device, isolated and provider network has to be set")
+	}
+	model, err := providernetworkinterface.ReadModelAttribute(map[string]string{providernetworkinterface.AttributeModelKey: nic.Model})
+	if err != nil {
+		return nil, err
+	}
+	macAddress, err := providernetworkinterface.ReadMACAddressAttribute(map[string]string{providernetworkinterface.AttributeMACAddressKey: nic.MACAddress})
+	if err != nil {
+		return nil, err
+	}
+
+	res := &providernetworkinterface.NetworkInterface{
+		Handle:     nic.Handle,
+		Model:      model,
+		MACAddress: macAddress,
+		IPFamilies: nic.IPFamilies,
+	}
+	switch {
+	case nic.HostDevice != nil:
+		res.HostDevice = &providernetworkinterface.HostDevice{
+			Domain:   nic.HostDevice.Domain,
+			Bus:      nic.HostDevice.Bus,
+			Slot:     nic.HostDevice.Slot,
+			Function: nic.HostDevice.Function,
+		}
+	case nic.Isolated:
+		res.Isolated = &providernetworkinterface.Isolated{}
+	default:
+		res.ProviderNetwork = &providernetworkinterface.ProviderNetwork{NetworkName: nic.ProviderNetwork}
+	}
+	return res, nil
+}
+
+func (p *plugin) Delete(ctx context.Context, computeNicName string, machineID string) error {
+	client, err := p.connection(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := client.DeleteNetworkInterface(ctx, &pluginapi.DeleteNetworkInterfaceRequest{
+		ComputeNicName: computeNicName,
+		MachineID:      machineID,
+	}); err != nil {
+		return fmt.Errorf("[plugin %s] error deleting network interface: %w", p.name, err)
+	}
+	return nil
+}
+
+// Check implements healthcheck.Checker, so the health of the plugin is part of the health of the provider.
+func (p *plugin) Check(ctx context.Context) error {
+	client, err := p.connection(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.HealthCheck(ctx, &pluginapi.HealthCheckRequest{})
+	return err
+}
@@ -0,0 +1,154 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package external_test
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/healthcheck"
+	providerhost "github.com/ironcore-dev/libvirt-provider/internal/host"
+	providernetworkinterface "github.com/ironcore-dev/libvirt-provider/internal/plugins/networkinterface"
+	"github.com/ironcore-dev/libvirt-provider/internal/plugins/networkinterface/external"
+	"github.com/ironcore-dev/libvirt-provider/pkg/pluginapi"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type fakeServer struct {
+	name    string
+	deleted []string
+}
+
+func (s *fakeServer) Info(context.Context, *pluginapi.NetworkInterfaceInfoRequest) (*pluginapi.NetworkInterfaceInfoResponse, error) {
+	return &pluginapi.NetworkInterfaceInfoResponse{
+		Name:         s.name,
+		Capabilities: pluginapi.NetworkInterfaceCapabilities{UpdateInPlace: true},
+	}, nil
+}
+
+func (s *fakeServer) ApplyNetworkInterface(_ context.Context, req *pluginapi.ApplyNetworkInterfaceRequest) (*pluginapi.ApplyNetworkInterfaceResponse, error) {
+	if req.NetworkInterface.Name == "invalid" {
+		return &pluginapi.ApplyNetworkInterfaceResponse{NetworkInterface: &pluginapi.NetworkInterface{
+			Isolated:        true,
+			ProviderNetwork: "default",
+		}}, nil
+	}
+	return &pluginapi.ApplyNetworkInterfaceResponse{NetworkInterface: &pluginapi.NetworkInterface{
+		Handle:     req.Machine.ID + "/" + req.NetworkInterface.Name,
+		HostDevice: &pluginapi.HostDevice{Bus: 0x3b, Function: 2},
+		IPFamilies: []api.NetworkInterfaceIPFamilyStatus{{Family: api.IPFamilyIPv4, IPs: req.NetworkInterface.Ips}},
+	}}, nil
+}
+
+func (s *fakeServer) UpdateNetworkInterface(ctx context.Context, req *pluginapi.ApplyNetworkInterfaceRequest) (*pluginapi.ApplyNetworkInterfaceResponse, error) {
+	return s.ApplyNetworkInterface(ctx, req)
+}
+
+func (s *fakeServer) DeleteNetworkInterface(_ context.Context, req *pluginapi.DeleteNetworkInterfaceRequest) (*pluginapi.DeleteNetworkInterfaceResponse, error) {
+	s.deleted = append(s.deleted, req.ComputeNicName)
+	return &pluginapi.DeleteNetworkInterfaceResponse{}, nil
+}
+
+func (s *fakeServer) HealthCheck(context.Context, *pluginapi.HealthCheckRequest) (*pluginapi.HealthCheckResponse, error) {
+	return nil, status.Error(codes.Unavailable, "sdn controller unreachable")
+}
+
+var _ = Describe("External network interface plugin", func() {
+	var (
+		host providerhost.Host
+	)
+
+	BeforeEach(func() {
+		// Unix socket paths are limited in length, the ginkgo temp dirs may exceed it.
+		rootDir, err := os.MkdirTemp("", "provider")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(os.RemoveAll, rootDir)
+
+		host, err = providerhost.NewAt(rootDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.MkdirAll(host.ExternalNetworkInterfacePluginsDir(), 0777)).To(Succeed())
+	})
+
+	serve := func(name string) *fakeServer {
+		listener, err := net.Listen("unix", filepath.Join(host.ExternalNetworkInterfacePluginsDir(), name+".sock"))
+		Expect(err).NotTo(HaveOccurred())
+		server := &fakeServer{name: "example.org/" + name}
+		grpcServer := grpc.NewServer(pluginapi.ServerOption())
+		pluginapi.RegisterNetworkInterfacePluginServer(grpcServer, server)
+		go func() {
+			defer GinkgoRecover()
+			Expect(grpcServer.Serve(listener)).To(Succeed())
+		}()
+		DeferCleanup(grpcServer.Stop)
+		return server
+	}
+
+	It("should forward the plugin calls to the registered plugin", func(ctx SpecContext) {
+		server := serve("sdn")
+
+		plugin := external.NewPlugin("")
+		Expect(plugin.Name()).To(Equal(external.PluginExternal))
+		Expect(plugin.Init(host)).To(Succeed())
+		Expect(plugin.Name()).To(Equal("example.org/sdn"))
+		Expect(plugin.Capabilities()).To(Equal(providernetworkinterface.Capabilities{UpdateInPlace: true}))
+
+		machine := &api.Machine{Metadata: api.Metadata{ID: "machine"}}
+		nic, err := plugin.Apply(ctx, &api.NetworkInterfaceSpec{Name: "primary", Ips: []string{"10.0.0.1"}}, machine)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nic.Handle).To(Equal("machine/primary"))
+		Expect(nic.HostDevice).To(Equal(&providernetworkinterface.HostDevice{Bus: 0x3b, Function: 2}))
+		Expect(nic.IPFamilies).To(ConsistOf(api.NetworkInterfaceIPFamilyStatus{Family: api.IPFamilyIPv4, IPs: []string{"10.0.0.1"}}))
+
+		nic, err = plugin.Update(ctx, &api.NetworkInterfaceSpec{Name: "primary", Ips: []string{"10.0.0.2"}}, machine)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nic.IPFamilies).To(ConsistOf(api.NetworkInterfaceIPFamilyStatus{Family: api.IPFamilyIPv4, IPs: []string{"10.0.0.2"}}))
+
+		By("rejecting invalid network interfaces of the plugin")
+		_, err = plugin.Apply(ctx, &api.NetworkInterfaceSpec{Name: "invalid"}, machine)
+		Expect(err).To(MatchError(ContainSubstring("exactly one of")))
+
+		Expect(plugin.Delete(ctx, "primary", "machine")).To(Succeed())
+		Expect(server.deleted).To(Equal([]string{"primary"}))
+
+		checker, ok := plugin.(healthcheck.Checker)
+		Expect(ok).To(BeTrue())
+		Expect(checker.Check(ctx)).To(MatchError(ContainSubstring("sdn controller unreachable")))
+	})
+
+	It("should select the registered plugin by name", func(ctx SpecContext) {
+		serve("sdn")
+		serve("overlay")
+
+		Expect(external.NewPlugin("").Init(host)).To(MatchError(ContainSubstring("multiple external network interface plugins")))
+
+		missing := external.NewPlugin("example.org/missing")
+		Expect(missing.Init(host)).To(Succeed())
+		Expect(missing.(healthcheck.Checker).Check(ctx)).To(MatchError(ContainSubstring("not registered")))
+
+		plugin := external.NewPlugin("example.org/overlay")
+		Expect(plugin.Init(host)).To(Succeed())
+		Expect(plugin.Name()).To(Equal("example.org/overlay"))
+	})
+
+	It("should be unhealthy until a plugin registered after the start is discovered", func(ctx SpecContext) {
+		plugin := external.NewPlugin("")
+		Expect(plugin.Init(host)).To(Succeed())
+		Expect(plugin.(healthcheck.Checker).Check(ctx)).To(MatchError(ContainSubstring("no external network interface plugin registered")))
+		_, err := plugin.Apply(ctx, &api.NetworkInterfaceSpec{Name: "primary"}, &api.Machine{})
+		Expect(err).To(MatchError(ContainSubstring("no external network interface plugin registered")))
+
+		serve("sdn")
+		Expect(plugin.(healthcheck.Checker).Check(ctx)).To(MatchError(ContainSubstring("sdn controller unreachable")))
+		Expect(plugin.Capabilities()).To(Equal(providernetworkinterface.Capabilities{UpdateInPlace: true}))
+		By("keeping the name the plugin was registered with")
+		Expect(plugin.Name()).To(Equal(external.PluginExternal))
+	})
+})
@@ -5,6 +5,10 @@ package networkinterface
 
 import (
 	"context"
+	"crypto/sha256"
+	"fmt"
+	"net"
+	"slices"
 
 	"github.com/ironcore-dev/libvirt-provider/api"
 	providerhost "github.com/ironcore-dev/libvirt-provider/internal/host"
@@ -14,15 +18,96 @@ type Plugin interface {
 	Name() string
 	Init(host providerhost.Host) error
 
+	Capabilities() Capabilities
+
 	Apply(ctx context.Context, spec *api.NetworkInterfaceSpec, machine *api.Machine) (*NetworkInterface, error)
+	// Update updates an already applied network interface in place, e.g. to change its IPs, and returns the
+	// refreshed network interface. It is only called if Capabilities reports UpdateInPlace.
+	Update(ctx context.Context, spec *api.NetworkInterfaceSpec, machine *api.Machine) (*NetworkInterface, error)
 	Delete(ctx context.Context, computeNicName string, machineID string) error
 }
 
+type Capabilities struct {
+	// UpdateInPlace reports whether the plugin can update an attached network interface without
+	// detaching and re-attaching the guest device.
+	UpdateInPlace bool
+	// Models reports whether the plugin creates emulated network interfaces whose model can be chosen.
+	Models bool
+	// MACAddresses reports whether the plugin creates network interfaces whose MAC address can be chosen.
+	MACAddresses bool
+}
+
+const (
+	// AttributeModelKey is the network interface attribute selecting the model of the network interface.
+	AttributeModelKey = "model"
+	// AttributeMACAddressKey is the network interface attribute selecting the MAC address of the network interface.
+	AttributeMACAddressKey = "macAddress"
+	// AttributePluginKey is the network interface attribute selecting the plugin of the network interface. Network
+	// interfaces without it use the default plugin.
+	AttributePluginKey = "plugin"
+
+	ModelVirtio = "virtio"
+)
+
+// SupportedModels are the models network interfaces can be emulated as.
+var SupportedModels = []string{ModelVirtio, "e1000e", "e1000", "rtl8139"}
+
+// ReadModelAttribute reads the optional model from the attributes of a network interface.
+func ReadModelAttribute(attrs map[string]string) (string, error) {
+	model := attrs[AttributeModelKey]
+	if model != "" && !slices.Contains(SupportedModels, model) {
+		return "", fmt.Errorf("unsupported network interface model %q, supported: %v", model, SupportedModels)
+	}
+	return model, nil
+}
+
+// ReadMACAddressAttribute reads the optional MAC address from the attributes of a network interface. The
+// address is returned in its canonical lower case form.
+func ReadMACAddressAttribute(attrs map[string]string) (string, error) {
+	macAddress := attrs[AttributeMACAddressKey]
+	if macAddress == "" {
+		return "", nil
+	}
+
+	hwAddr, err := net.ParseMAC(macAddress)
+	if err != nil {
+		return "", fmt.Errorf("invalid MAC address %q: %w", macAddress, err)
+	}
+	if len(hwAddr) != 6 {
+		return "", fmt.Errorf("invalid MAC address %q: not an EUI-48 address", macAddress)
+	}
+	if hwAddr[0]&0x01 != 0 {
+		return "", fmt.Errorf("invalid MAC address %q: multicast address", macAddress)
+	}
+	return hwAddr.String(), nil
+}
+
+// GenerateMACAddress returns the MAC address of the network interface of the machine not choosing one. The
+// address is derived from the machine id and network interface name, so it stays the same across reconciles
+// and domain recreations. It is a locally administered unicast address.
+func GenerateMACAddress(machineID, networkInterfaceName string) string {
+	sum := sha256.Sum256([]byte(machineID + "/" + networkInterfaceName))
+	hwAddr := net.HardwareAddr(sum[:6])
+	hwAddr[0] = hwAddr[0]&^0x01 | 0x02
+	return hwAddr.String()
+}
+
 type NetworkInterface struct {
 	Handle          string
 	HostDevice      *HostDevice
 	Isolated        *Isolated
 	ProviderNetwork *ProviderNetwork
+
+	// Model is the model of emulated network interfaces. Empty leaves the model to the hypervisor.
+	Model string
+	// MACAddress is the MAC address of emulated network interfaces. Empty leaves the address to the hypervisor.
+	MACAddress string
+	// Filter is the name of the libvirt nwfilter applied to emulated network interfaces. Empty applies none.
+	Filter string
+
+	// IPFamilies are the addresses the network interface is set up with per IP family. Plugins not managing
+	// the addresses leave it empty.
+	IPFamilies []api.NetworkInterfaceIPFamilyStatus
 }
 
 type Isolated struct{}
@@ -37,3 +122,91 @@ type HostDevice struct {
 	Slot     uint
 	Function uint
 }
+
+// PluginManager holds the network interface plugins network interfaces can select with AttributePluginKey.
+type PluginManager struct {
+	plugins       map[string]Plugin
+	defaultPlugin Plugin
+}
+
+func NewPluginManager() *PluginManager {
+	return &PluginManager{
+		plugins: make(map[string]Plugin),
+	}
+}
+
+// InitPlugins initializes the plugins. The first plugin is the default plugin of network interfaces not selecting
+// one.
+func (m *PluginManager) InitPlugins(host providerhost.Host, plugins []Plugin) error {
+	var initErrs []error
+	for _, plugin := range plugins {
+		if err := plugin.Init(host); err != nil {
+			initErrs = append(initErrs, fmt.Errorf("[plugin %s] error initializing: %w", plugin.Name(), err))
+			continue
+		}
+
+		// Plugins may only know their name once initialized.
+		name := plugin.Name()
+		if _, ok := m.plugins[name]; ok {
+			initErrs = append(initErrs, fmt.Errorf("[plugin %s] already registered", name))
+			continue
+		}
+
+		m.plugins[name] = plugin
+		if m.defaultPlugin == nil {
+			m.defaultPlugin = plugin
+		}
+	}
+
+	if len(initErrs) > 0 {
+		return fmt.Errorf("error(s) initializing plugins: %v", initErrs)
+	}
+	if m.defaultPlugin == nil {
+		return fmt.Errorf("no network interface plugin")
+	}
+	return nil
+}
+
+// DefaultPlugin returns the plugin of network interfaces not selecting one.
+func (m *PluginManager) DefaultPlugin() Plugin {
+	return m.defaultPlugin
+}
+
+// Plugins returns the initialized plugins, the default plugin first.
+func (m *PluginManager) Plugins() []Plugin {
+	plugins := []Plugin{m.defaultPlugin}
+	for _, name := range m.PluginNames() {
+		if name != m.defaultPlugin.Name() {
+			plugins = append(plugins, m.plugins[name])
+		}
+	}
+	return plugins
+}
+
+// PluginNames returns the names of the initialized plugins in ascending order.
+func (m *PluginManager) PluginNames() []string {
+	names := make([]string, 0, len(m.plugins))
+	for name := range m.plugins {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// FindPluginByName returns the plugin with the given name. An empty name returns the default plugin.
+func (m *PluginManager) FindPluginByName(name string) (Plugin, error) {
+	if name == "" {
+		return m.defaultPlugin, nil
+	}
+
+	plugin, ok := m.plugins[name]
+	if !ok {
+		return nil, fmt.Errorf("network interface plugin %q not found, available: %v", name, m.PluginNames())
+	}
+	return plugin, nil
+}
+
+// FindPluginBySpec returns the plugin the network interface selects with AttributePluginKey.
+func (m *PluginManager) FindPluginBySpec(spec *api.NetworkInterfaceSpec) (Plugin, error) {
+	return m.FindPluginByName(spec.Attributes[AttributePluginKey])
+}
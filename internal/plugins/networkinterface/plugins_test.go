@@ -0,0 +1,97 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package networkinterface_test
+
+import (
+	"net"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	providerhost "github.com/ironcore-dev/libvirt-provider/internal/host"
+	"github.com/ironcore-dev/libvirt-provider/internal/plugins/networkinterface"
+	"github.com/ironcore-dev/libvirt-provider/internal/plugins/networkinterface/isolated"
+	"github.com/ironcore-dev/libvirt-provider/internal/plugins/networkinterface/providernetwork"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PluginManager", func() {
+	var host providerhost.Host
+
+	BeforeEach(func() {
+		var err error
+		host, err = providerhost.NewAt(GinkgoT().TempDir())
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should select the plugin of network interfaces by attribute", func() {
+		plugins := networkinterface.NewPluginManager()
+		Expect(plugins.InitPlugins(host, []networkinterface.Plugin{providernetwork.NewPlugin(), isolated.NewPlugin()})).To(Succeed())
+		Expect(plugins.DefaultPlugin().Name()).To(Equal("providernet"))
+		Expect(plugins.PluginNames()).To(Equal([]string{"isolated", "providernet"}))
+		Expect(plugins.Plugins()).To(HaveLen(2))
+		Expect(plugins.Plugins()[0].Name()).To(Equal("providernet"))
+
+		plugin, err := plugins.FindPluginBySpec(&api.NetworkInterfaceSpec{Name: "data"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(plugin.Name()).To(Equal("providernet"))
+
+		plugin, err = plugins.FindPluginBySpec(&api.NetworkInterfaceSpec{
+			Name:       "management",
+			Attributes: map[string]string{networkinterface.AttributePluginKey: "isolated"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(plugin.Name()).To(Equal("isolated"))
+
+		_, err = plugins.FindPluginBySpec(&api.NetworkInterfaceSpec{
+			Name:       "unknown",
+			Attributes: map[string]string{networkinterface.AttributePluginKey: "apinet"},
+		})
+		Expect(err).To(MatchError(ContainSubstring(`network interface plugin "apinet" not found`)))
+	})
+
+	It("should reject plugins registered twice", func() {
+		plugins := networkinterface.NewPluginManager()
+		Expect(plugins.InitPlugins(host, []networkinterface.Plugin{isolated.NewPlugin(), isolated.NewPlugin()})).To(MatchError(ContainSubstring("already registered")))
+	})
+})
+
+var _ = Describe("MAC addresses", func() {
+	DescribeTable("ReadMACAddressAttribute",
+		func(macAddress, expected string, matchErr any) {
+			actual, err := networkinterface.ReadMACAddressAttribute(map[string]string{
+				networkinterface.AttributeMACAddressKey: macAddress,
+			})
+			if matchErr != nil {
+				Expect(err).To(MatchError(matchErr))
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			Expect(actual).To(Equal(expected))
+		},
+		Entry("no address", "", "", nil),
+		Entry("canonical address", "52:54:00:12:34:56", "52:54:00:12:34:56", nil),
+		Entry("upper case address", "52-54-00-AB-CD-EF", "52:54:00:ab:cd:ef", nil),
+		Entry("malformed address", "52:54:00:12:34", "", ContainSubstring("invalid MAC address")),
+		Entry("EUI-64 address", "52:54:00:12:34:56:78:9a", "", ContainSubstring("not an EUI-48 address")),
+		Entry("multicast address", "01:00:5e:00:00:01", "", ContainSubstring("multicast address")),
+	)
+
+	It("should generate stable, locally administered unicast addresses", func() {
+		macAddress := networkinterface.GenerateMACAddress("foo", "data")
+		Expect(networkinterface.GenerateMACAddress("foo", "data")).To(Equal(macAddress))
+		Expect(networkinterface.GenerateMACAddress("foo", "management")).NotTo(Equal(macAddress))
+		Expect(networkinterface.GenerateMACAddress("bar", "data")).NotTo(Equal(macAddress))
+
+		By("accepting the generated address as attribute")
+		actual, err := networkinterface.ReadMACAddressAttribute(map[string]string{
+			networkinterface.AttributeMACAddressKey: macAddress,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(actual).To(Equal(macAddress))
+
+		hwAddr, err := net.ParseMAC(macAddress)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hwAddr[0] & 0x02).To(Equal(byte(0x02)))
+	})
+})
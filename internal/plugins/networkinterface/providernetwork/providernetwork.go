@@ -39,9 +39,19 @@ func (p *plugin) Apply(ctx context.Context, spec *api.NetworkInterfaceSpec, mach
 		ProviderNetwork: &providernetworkinterface.ProviderNetwork{
 			NetworkName: spec.NetworkId,
 		},
+		Model: spec.Model,
 	}, nil
 }
 
+func (p *plugin) Capabilities() providernetworkinterface.Capabilities {
+	return providernetworkinterface.Capabilities{UpdateInPlace: true, Models: true, MACAddresses: true}
+}
+
+// Update doesn't have to touch anything, the network interface doesn't depend on the IPs.
+func (p *plugin) Update(ctx context.Context, spec *api.NetworkInterfaceSpec, machine *api.Machine) (*providernetworkinterface.NetworkInterface, error) {
+	return p.Apply(ctx, spec, machine)
+}
+
 func (p *plugin) Delete(ctx context.Context, computeNicName string, machineID string) error {
 	return os.RemoveAll(p.host.MachineNetworkInterfaceDir(machineID, computeNicName))
 }
@@ -5,13 +5,18 @@ package ceph
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/ironcore-dev/libvirt-provider/api"
 	"github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
 	"k8s.io/utils/ptr"
+	utilstrings "k8s.io/utils/strings"
 )
 
 const (
@@ -26,10 +31,22 @@ const (
 	secretUserKeyKey = "userKey"
 
 	secretEncryptionKey = "encryptionKey"
+
+	// configFileName is the ceph configuration file with the librbd options of a volume in its volume directory.
+	configFileName = "ceph.conf"
+
+	perm     = 0777
+	filePerm = 0666
 )
 
 type plugin struct {
-	host volume.Host
+	host        volume.Host
+	driverModes volume.DriverModes
+
+	// clusters are the results of the last health check of the clusters of the applied volumes, keyed by the
+	// addresses of their monitors. Clusters not checked yet are healthy.
+	clustersMu sync.Mutex
+	clusters   map[string]error
 }
 
 type volumeData struct {
@@ -39,13 +56,24 @@ type volumeData struct {
 	userID        string
 	userKey       string
 	encryptionKey *string
+	discard       string
+	detectZeroes  string
+	options       *rbdOptions
 }
 
-func NewPlugin() volume.Plugin {
-	return &plugin{}
+// NewPlugin creates a volume plugin for ceph block devices, whose disks default to the given cache mode.
+func NewPlugin(cache string) volume.Plugin {
+	return &plugin{
+		driverModes: volume.DriverModes{Cache: cache, IO: volume.IOModeThreads},
+		clusters:    make(map[string]error),
+	}
 }
 
 func (p *plugin) Init(host volume.Host) error {
+	if err := volume.ValidateDriverModes(p.driverModes.Cache, p.driverModes.IO); err != nil {
+		return err
+	}
+
 	p.host = host
 	return nil
 }
@@ -54,7 +82,7 @@ func (p *plugin) Name() string {
 	return pluginName
 }
 
-func (p *plugin) GetBackingVolumeID(spec *api.VolumeSpec) (string, error) {
+func (p *plugin) GetBackingVolumeID(spec *api.VolumeSpec, _ string) (string, error) {
 	storage := spec.Connection
 	if storage == nil {
 		return "", fmt.Errorf("volume is nil")
@@ -68,6 +96,25 @@ func (p *plugin) GetBackingVolumeID(spec *api.VolumeSpec) (string, error) {
 	return fmt.Sprintf("%s^%s", pluginName, handle), nil
 }
 
+// ResolveBackingVolume returns the image of the volume in its cluster, pool and namespace, as the same image can be
+// connected to with different handles.
+func (p *plugin) ResolveBackingVolume(spec *api.VolumeSpec) (string, error) {
+	if spec.Connection == nil {
+		return "", fmt.Errorf("volume is nil")
+	}
+	monitors, image, err := readVolumeAttributes(spec.Connection.Attributes)
+	if err != nil {
+		return "", err
+	}
+	options, err := readRBDOptions(spec.Connection.Attributes)
+	if err != nil {
+		return "", err
+	}
+
+	disk := &volume.CephDisk{Name: image, Namespace: options.namespace}
+	return fmt.Sprintf("%s^%s^%s", pluginName, clusterKey(monitors), disk.SourceName()), nil
+}
+
 func (p *plugin) CanSupport(spec *api.VolumeSpec) bool {
 	storage := spec.Connection
 	if storage == nil {
@@ -130,6 +177,7 @@ func (p *plugin) Apply(ctx context.Context, spec *api.VolumeSpec, machine *api.M
 	if err != nil {
 		return nil, fmt.Errorf("failed to get volume data: %w", err)
 	}
+	p.rememberCluster(volumeData.monitors)
 
 	var cephEncryption *volume.CephEncryption
 	if volumeData.encryptionKey != nil {
@@ -138,26 +186,70 @@ func (p *plugin) Apply(ctx context.Context, spec *api.VolumeSpec, machine *api.M
 		}
 	}
 
-	volumeSize, err := p.GetSize(ctx, spec)
+	info, err := p.inspectImage(ctx, spec)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get volume size: %w", err)
+		return nil, fmt.Errorf("failed to inspect image: %w", err)
+	}
+	if err := volumeData.options.checkImage(info); err != nil {
+		return nil, err
 	}
 
-	return &volume.Volume{
+	configFile, err := p.writeConfig(spec.Name, machine.ID, volumeData.options)
+	if err != nil {
+		return nil, err
+	}
+
+	vol := &volume.Volume{
 		QCow2File: "",
 		RawFile:   "",
 		CephDisk: &volume.CephDisk{
-			Name:     volumeData.image,
-			Monitors: volumeData.monitors,
+			Name:      volumeData.image,
+			Namespace: volumeData.options.namespace,
+			Monitors:  volumeData.monitors,
 			Auth: &volume.CephAuthentication{
 				UserName: volumeData.userID,
 				UserKey:  volumeData.userKey,
 			},
 			Encryption: cephEncryption,
+			ConfigFile: configFile,
 		},
-		Handle: volumeData.handle,
-		Size:   volumeSize,
-	}, nil
+		Handle:       volumeData.handle,
+		Size:         int64(info.size),
+		Discard:      volumeData.discard,
+		DetectZeroes: volumeData.detectZeroes,
+	}
+
+	if err := volumeData.options.driverModes(p.driverModes).Apply(vol, spec); err != nil {
+		return nil, err
+	}
+	return vol, nil
+}
+
+func (p *plugin) volumeDir(computeVolumeName, machineID string) string {
+	return p.host.MachineVolumeDir(machineID, utilstrings.EscapeQualifiedName(pluginName), computeVolumeName)
+}
+
+// writeConfig writes the ceph configuration of the librbd options to the volume directory and returns its path.
+// It returns an empty path if there are no options to configure.
+func (p *plugin) writeConfig(computeVolumeName, machineID string, options *rbdOptions) (string, error) {
+	volumeDir := p.volumeDir(computeVolumeName, machineID)
+	if err := os.MkdirAll(volumeDir, perm); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(volumeDir, configFileName)
+	config := options.config()
+	if config == "" {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("error removing ceph configuration: %w", err)
+		}
+		return "", nil
+	}
+
+	if err := os.WriteFile(path, []byte(config), filePerm); err != nil {
+		return "", fmt.Errorf("error writing ceph configuration: %w", err)
+	}
+	return path, nil
 }
 
 func (p *plugin) getVolumeData(spec *api.VolumeSpec) (vData *volumeData, err error) {
@@ -185,6 +277,16 @@ func (p *plugin) getVolumeData(spec *api.VolumeSpec) (vData *volumeData, err err
 		return nil, fmt.Errorf("error reading volume attributes: %w", err)
 	}
 
+	vData.discard, vData.detectZeroes, err = volume.ReadDiscardAttributes(connection.Attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	vData.options, err = readRBDOptions(connection.Attributes)
+	if err != nil {
+		return nil, fmt.Errorf("error reading rbd options: %w", err)
+	}
+
 	vData.userID, vData.userKey, err = readSecretData(connection.SecretData)
 	if err != nil {
 		return nil, fmt.Errorf("error reading secret data: %w", err)
@@ -201,5 +303,5 @@ func (p *plugin) getVolumeData(spec *api.VolumeSpec) (vData *volumeData, err err
 }
 
 func (p *plugin) Delete(ctx context.Context, computeVolumeName string, machineID string) error {
-	return nil
+	return os.RemoveAll(p.volumeDir(computeVolumeName, machineID))
 }
@@ -0,0 +1,53 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package ceph
+
+import (
+	"github.com/ironcore-dev/libvirt-provider/api"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Ceph", func() {
+	Describe("ResolveBackingVolume", func() {
+		var p *plugin
+
+		BeforeEach(func() {
+			p = NewPlugin("").(*plugin)
+		})
+
+		spec := func(handle, monitors, image, namespace string) *api.VolumeSpec {
+			attrs := map[string]string{
+				volumeAttributesMonitorsKey: monitors,
+				volumeAttributeImageKey:     image,
+			}
+			if namespace != "" {
+				attrs[volumeAttributeNamespaceKey] = namespace
+			}
+			return &api.VolumeSpec{Connection: &api.VolumeConnection{Driver: cephDriverName, Handle: handle, Attributes: attrs}}
+		}
+
+		resolve := func(spec *api.VolumeSpec) string {
+			id, err := p.ResolveBackingVolume(spec)
+			Expect(err).NotTo(HaveOccurred())
+			return id
+		}
+
+		It("should resolve the same image connected with different handles to the same backing volume", func() {
+			Expect(resolve(spec("a", "10.0.0.1:6789,10.0.0.2:6789", "pool/image", "tenant"))).
+				To(Equal(resolve(spec("b", "10.0.0.2:6789,10.0.0.1:6789", "pool/image", "tenant"))))
+		})
+
+		It("should resolve images of other namespaces and clusters to other backing volumes", func() {
+			id := resolve(spec("a", "10.0.0.1:6789", "pool/image", "tenant"))
+			Expect(resolve(spec("a", "10.0.0.1:6789", "pool/image", ""))).NotTo(Equal(id))
+			Expect(resolve(spec("a", "10.0.1.1:6789", "pool/image", "tenant"))).NotTo(Equal(id))
+		})
+
+		It("should reject volumes without image", func() {
+			_, err := p.ResolveBackingVolume(spec("a", "10.0.0.1:6789", "", ""))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,119 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package ceph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
+)
+
+// clusterKey identifies the cluster of the monitors by their sorted addresses.
+func clusterKey(monitors []volume.CephMonitor) string {
+	addrs := make([]string, 0, len(monitors))
+	for _, monitor := range monitors {
+		addrs = append(addrs, net.JoinHostPort(monitor.Name, monitor.Port))
+	}
+	slices.Sort(addrs)
+	return strings.Join(slices.Compact(addrs), ",")
+}
+
+// rememberCluster records the cluster of the monitors of an applied volume for the health check.
+func (p *plugin) rememberCluster(monitors []volume.CephMonitor) {
+	p.clustersMu.Lock()
+	defer p.clustersMu.Unlock()
+
+	key := clusterKey(monitors)
+	if _, ok := p.clusters[key]; !ok {
+		p.clusters[key] = nil
+	}
+}
+
+// HealthCheck checks whether a monitor of each cluster of the volumes applied since the start of the provider is
+// reachable. Before a volume was applied there is no cluster to check, so the plugin is healthy.
+func (p *plugin) HealthCheck(ctx context.Context) error {
+	p.clustersMu.Lock()
+	keys := slices.Sorted(maps.Keys(p.clusters))
+	p.clustersMu.Unlock()
+
+	// The clusters are checked in parallel, so an unresponsive cluster doesn't use up the time of the others.
+	results := make([]error, len(keys))
+	done := make(chan struct{})
+	for i, key := range keys {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			results[i] = checkCluster(ctx, strings.Split(key, ","))
+		}()
+	}
+	for range keys {
+		<-done
+	}
+
+	p.clustersMu.Lock()
+	defer p.clustersMu.Unlock()
+
+	var errs []error
+	for i, key := range keys {
+		p.clusters[key] = results[i]
+		if results[i] != nil {
+			errs = append(errs, fmt.Errorf("cluster with monitors %s: %w", key, results[i]))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkCluster checks whether any of the monitors of a cluster is reachable.
+func checkCluster(ctx context.Context, addrs []string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The monitors are dialed in parallel, so an unresponsive monitor doesn't use up the time of the others.
+	results := make(chan error, len(addrs))
+	for _, addr := range addrs {
+		go func() {
+			var dialer net.Dialer
+			conn, err := dialer.DialContext(ctx, "tcp", addr)
+			if err != nil {
+				results <- err
+				return
+			}
+			_ = conn.Close()
+			results <- nil
+		}()
+	}
+
+	var errs []error
+	for range addrs {
+		err := <-results
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	return fmt.Errorf("no ceph monitor reachable: %w", errors.Join(errs...))
+}
+
+// VolumeHealth returns the error of the last health check of the cluster of the volume, so a cluster that is down
+// doesn't hold back the volumes of the other clusters.
+func (p *plugin) VolumeHealth(spec *api.VolumeSpec) error {
+	if spec.Connection == nil {
+		return nil
+	}
+	monitors, _, err := readVolumeAttributes(spec.Connection.Attributes)
+	if err != nil {
+		// Invalid volumes are rejected when they are applied.
+		return nil
+	}
+
+	p.clustersMu.Lock()
+	defer p.clustersMu.Unlock()
+	return p.clusters[clusterKey(monitors)]
+}
@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package ceph
+
+import (
+	"net"
+	"strings"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HealthCheck", func() {
+	var (
+		p                *plugin
+		up, down         []volume.CephMonitor
+		upSpec, downSpec *api.VolumeSpec
+	)
+
+	// monitor returns a monitor listening on a free port if listen is set, otherwise a monitor that is down.
+	monitor := func(listen bool) volume.CephMonitor {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		if listen {
+			DeferCleanup(listener.Close)
+		} else {
+			Expect(listener.Close()).To(Succeed())
+		}
+		host, port, err := net.SplitHostPort(listener.Addr().String())
+		Expect(err).NotTo(HaveOccurred())
+		return volume.CephMonitor{Name: host, Port: port}
+	}
+
+	spec := func(monitors []volume.CephMonitor) *api.VolumeSpec {
+		var addrs []string
+		for _, monitor := range monitors {
+			addrs = append(addrs, net.JoinHostPort(monitor.Name, monitor.Port))
+		}
+		return &api.VolumeSpec{Connection: &api.VolumeConnection{
+			Driver: cephDriverName,
+			Attributes: map[string]string{
+				volumeAttributesMonitorsKey: strings.Join(addrs, ","),
+				volumeAttributeImageKey:     "pool/image",
+			},
+		}}
+	}
+
+	BeforeEach(func() {
+		p = NewPlugin("").(*plugin)
+		up = []volume.CephMonitor{monitor(false), monitor(true)}
+		down = []volume.CephMonitor{monitor(false)}
+		upSpec, downSpec = spec(up), spec(down)
+	})
+
+	It("should be healthy without clusters", func(ctx SpecContext) {
+		Expect(p.HealthCheck(ctx)).To(Succeed())
+	})
+
+	It("should be healthy if a monitor of each cluster is reachable", func(ctx SpecContext) {
+		p.rememberCluster(up)
+		Expect(p.HealthCheck(ctx)).To(Succeed())
+		Expect(p.VolumeHealth(upSpec)).To(Succeed())
+	})
+
+	It("should report the clusters without reachable monitor only", func(ctx SpecContext) {
+		p.rememberCluster(up)
+		p.rememberCluster(down)
+
+		By("considering clusters not checked yet healthy")
+		Expect(p.VolumeHealth(downSpec)).To(Succeed())
+
+		err := p.HealthCheck(ctx)
+		Expect(err).To(MatchError(ContainSubstring(clusterKey(down))))
+		Expect(err).NotTo(MatchError(ContainSubstring(clusterKey(up))))
+
+		Expect(p.VolumeHealth(upSpec)).To(Succeed())
+		Expect(p.VolumeHealth(downSpec)).To(MatchError(ContainSubstring("no ceph monitor reachable")))
+	})
+
+	It("should identify clusters independently of the order of their monitors", func() {
+		Expect(clusterKey([]volume.CephMonitor{up[1], up[0]})).To(Equal(clusterKey(up)))
+	})
+})
@@ -0,0 +1,197 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package ceph
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	volumeAttributeNamespaceKey         = "namespace"
+	volumeAttributeMonConnectTimeoutKey = "mon_connect_timeout"
+	volumeAttributeRBDCacheKey          = "rbd_cache"
+	volumeAttributeRBDCacheSizeKey      = "rbd_cache_size"
+	volumeAttributeRBDCacheMaxDirtyKey  = "rbd_cache_max_dirty"
+	volumeAttributeStripeUnitKey        = "stripe_unit"
+	volumeAttributeStripeCountKey       = "stripe_count"
+	volumeAttributeFeaturesKey          = "features"
+
+	// defaultMonConnectTimeout bounds connecting to the monitors if the volume doesn't configure a timeout.
+	defaultMonConnectTimeout = time.Second
+)
+
+// rbdOptions are the optional librbd options of a volume.
+type rbdOptions struct {
+	// namespace is the RBD namespace of the image in its pool, empty for the default namespace.
+	namespace string
+
+	// monConnectTimeout bounds connecting to the monitors, zero if not configured.
+	monConnectTimeout time.Duration
+
+	// cache enables or disables the librbd cache, nil if not configured. QEMU derives the librbd cache from the
+	// cache mode of the disk, so it's rendered as cache mode.
+	cache         *bool
+	cacheSize     *resource.Quantity
+	cacheMaxDirty *resource.Quantity
+
+	// stripeUnit, stripeCount and features are the striping and the feature flags the image has to be created
+	// with. Zero and empty values aren't checked.
+	stripeUnit  uint64
+	stripeCount uint64
+	features    []string
+}
+
+func readRBDOptions(attrs map[string]string) (*rbdOptions, error) {
+	opts := &rbdOptions{
+		namespace: attrs[volumeAttributeNamespaceKey],
+	}
+	if strings.Contains(opts.namespace, "/") {
+		return nil, fmt.Errorf("invalid namespace %q at %s: must not contain '/'", opts.namespace, volumeAttributeNamespaceKey)
+	}
+
+	if timeout, ok := attrs[volumeAttributeMonConnectTimeoutKey]; ok {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout at %s: %w", volumeAttributeMonConnectTimeoutKey, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("invalid timeout at %s: must be positive", volumeAttributeMonConnectTimeoutKey)
+		}
+		opts.monConnectTimeout = d
+	}
+
+	if cache, ok := attrs[volumeAttributeRBDCacheKey]; ok {
+		enabled, err := strconv.ParseBool(cache)
+		if err != nil {
+			return nil, fmt.Errorf("invalid flag at %s: %w", volumeAttributeRBDCacheKey, err)
+		}
+		opts.cache = &enabled
+
+		// QEMU enables the librbd cache for all cache modes but none, a cache mode contradicting it is rejected
+		// instead of silently overriding it.
+		if mode := attrs[volume.VolumeAttributeCacheKey]; mode != "" && enabled == (mode == volume.CacheModeNone) {
+			return nil, fmt.Errorf("%s %t conflicts with the cache mode %s at %s", volumeAttributeRBDCacheKey, enabled, mode, volume.VolumeAttributeCacheKey)
+		}
+	}
+
+	var err error
+	if opts.cacheSize, err = readBytes(attrs, volumeAttributeRBDCacheSizeKey); err != nil {
+		return nil, err
+	}
+	if opts.cacheMaxDirty, err = readBytes(attrs, volumeAttributeRBDCacheMaxDirtyKey); err != nil {
+		return nil, err
+	}
+	if opts.cacheSize != nil && opts.cacheMaxDirty != nil && opts.cacheMaxDirty.Cmp(*opts.cacheSize) > 0 {
+		return nil, fmt.Errorf("%s must not exceed %s", volumeAttributeRBDCacheMaxDirtyKey, volumeAttributeRBDCacheSizeKey)
+	}
+
+	if opts.stripeUnit, err = readCount(attrs, volumeAttributeStripeUnitKey); err != nil {
+		return nil, err
+	}
+	if opts.stripeCount, err = readCount(attrs, volumeAttributeStripeCountKey); err != nil {
+		return nil, err
+	}
+
+	if features := attrs[volumeAttributeFeaturesKey]; features != "" {
+		for _, feature := range strings.Split(features, ",") {
+			opts.features = append(opts.features, strings.TrimSpace(feature))
+		}
+	}
+	return opts, nil
+}
+
+// readBytes reads the optional non-negative quantity of bytes at key, e.g. 32Mi.
+func readBytes(attrs map[string]string, key string) (*resource.Quantity, error) {
+	value, ok := attrs[key]
+	if !ok {
+		return nil, nil
+	}
+
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid quantity at %s: %w", key, err)
+	}
+	if quantity.Sign() < 0 {
+		return nil, fmt.Errorf("invalid quantity at %s: must not be negative", key)
+	}
+	return &quantity, nil
+}
+
+// readCount reads the optional positive number at key.
+func readCount(attrs map[string]string, key string) (uint64, error) {
+	value, ok := attrs[key]
+	if !ok {
+		return 0, nil
+	}
+
+	count, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number at %s: %w", key, err)
+	}
+	if count == 0 {
+		return 0, fmt.Errorf("invalid number at %s: must be positive", key)
+	}
+	return count, nil
+}
+
+// config returns the ceph configuration of the librbd options of the disk, empty if there are none.
+func (o *rbdOptions) config() string {
+	var lines []string
+	if o.monConnectTimeout > 0 {
+		lines = append(lines, fmt.Sprintf("client_mount_timeout = %s", strconv.FormatFloat(o.monConnectTimeout.Seconds(), 'f', -1, 64)))
+	}
+	if o.cacheSize != nil {
+		lines = append(lines, fmt.Sprintf("rbd_cache_size = %d", o.cacheSize.Value()))
+	}
+	if o.cacheMaxDirty != nil {
+		lines = append(lines, fmt.Sprintf("rbd_cache_max_dirty = %d", o.cacheMaxDirty.Value()))
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "[client]\n" + strings.Join(lines, "\n") + "\n"
+}
+
+// driverModes returns the defaults with the cache mode rendering the librbd cache option. QEMU enables the librbd
+// cache for all cache modes but none.
+func (o *rbdOptions) driverModes(defaults volume.DriverModes) volume.DriverModes {
+	if o.cache != nil {
+		defaults.Cache = volume.CacheModeNone
+		if *o.cache {
+			defaults.Cache = "writeback"
+		}
+	}
+	return defaults
+}
+
+// connectTimeout returns the timeout of connecting to the monitors.
+func (o *rbdOptions) connectTimeout() time.Duration {
+	if o.monConnectTimeout > 0 {
+		return o.monConnectTimeout
+	}
+	return defaultMonConnectTimeout
+}
+
+// checkImage checks that the image is striped and has the features the options require.
+func (o *rbdOptions) checkImage(info *imageInfo) error {
+	if o.stripeUnit != 0 && info.stripeUnit != o.stripeUnit {
+		return fmt.Errorf("image has stripe unit %d, expected %d", info.stripeUnit, o.stripeUnit)
+	}
+	if o.stripeCount != 0 && info.stripeCount != o.stripeCount {
+		return fmt.Errorf("image has stripe count %d, expected %d", info.stripeCount, o.stripeCount)
+	}
+	for _, feature := range o.features {
+		if !slices.Contains(info.features, feature) {
+			return fmt.Errorf("image doesn't have feature %s", feature)
+		}
+	}
+	return nil
+}
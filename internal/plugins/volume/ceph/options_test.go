@@ -0,0 +1,125 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package ceph
+
+import (
+	"time"
+
+	"github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/utils/ptr"
+)
+
+var _ = Describe("RBD options", func() {
+	Describe("readRBDOptions", func() {
+		It("should read the options of a volume", func() {
+			opts, err := readRBDOptions(map[string]string{
+				volumeAttributeNamespaceKey:         "tenant",
+				volumeAttributeMonConnectTimeoutKey: "5s",
+				volumeAttributeRBDCacheKey:          "true",
+				volumeAttributeRBDCacheSizeKey:      "64Mi",
+				volumeAttributeRBDCacheMaxDirtyKey:  "32Mi",
+				volumeAttributeStripeUnitKey:        "65536",
+				volumeAttributeStripeCountKey:       "4",
+				volumeAttributeFeaturesKey:          "layering, exclusive-lock",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts.namespace).To(Equal("tenant"))
+			Expect(opts.monConnectTimeout).To(Equal(5 * time.Second))
+			Expect(opts.cache).To(Equal(ptr.To(true)))
+			Expect(opts.cacheSize.Value()).To(Equal(int64(64 << 20)))
+			Expect(opts.cacheMaxDirty.Value()).To(Equal(int64(32 << 20)))
+			Expect(opts.stripeUnit).To(Equal(uint64(65536)))
+			Expect(opts.stripeCount).To(Equal(uint64(4)))
+			Expect(opts.features).To(Equal([]string{"layering", "exclusive-lock"}))
+		})
+
+		It("should leave options not set unconfigured", func() {
+			opts, err := readRBDOptions(map[string]string{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts).To(Equal(&rbdOptions{}))
+			Expect(opts.connectTimeout()).To(Equal(defaultMonConnectTimeout))
+		})
+
+		DescribeTable("should reject invalid options",
+			func(attrs map[string]string, msg string) {
+				_, err := readRBDOptions(attrs)
+				Expect(err).To(MatchError(ContainSubstring(msg)))
+			},
+			Entry("namespace with slash", map[string]string{volumeAttributeNamespaceKey: "a/b"}, "must not contain '/'"),
+			Entry("invalid timeout", map[string]string{volumeAttributeMonConnectTimeoutKey: "soon"}, volumeAttributeMonConnectTimeoutKey),
+			Entry("non-positive timeout", map[string]string{volumeAttributeMonConnectTimeoutKey: "0s"}, "must be positive"),
+			Entry("invalid cache flag", map[string]string{volumeAttributeRBDCacheKey: "maybe"}, volumeAttributeRBDCacheKey),
+			Entry("negative cache size", map[string]string{volumeAttributeRBDCacheSizeKey: "-1"}, "must not be negative"),
+			Entry("max dirty exceeding cache size", map[string]string{
+				volumeAttributeRBDCacheSizeKey:     "32Mi",
+				volumeAttributeRBDCacheMaxDirtyKey: "64Mi",
+			}, "must not exceed"),
+			Entry("zero stripe count", map[string]string{volumeAttributeStripeCountKey: "0"}, "must be positive"),
+			Entry("enabled cache with cache mode none", map[string]string{
+				volumeAttributeRBDCacheKey:     "true",
+				volume.VolumeAttributeCacheKey: volume.CacheModeNone,
+			}, "conflicts with the cache mode none"),
+			Entry("disabled cache with caching cache mode", map[string]string{
+				volumeAttributeRBDCacheKey:     "false",
+				volume.VolumeAttributeCacheKey: "writeback",
+			}, "conflicts with the cache mode writeback"),
+		)
+
+		It("should accept cache modes agreeing with the cache flag", func() {
+			_, err := readRBDOptions(map[string]string{volumeAttributeRBDCacheKey: "false", volume.VolumeAttributeCacheKey: volume.CacheModeNone})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = readRBDOptions(map[string]string{volumeAttributeRBDCacheKey: "true", volume.VolumeAttributeCacheKey: "writethrough"})
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Describe("config", func() {
+		It("should render the librbd options", func() {
+			opts := &rbdOptions{
+				monConnectTimeout: 1500 * time.Millisecond,
+				cacheSize:         ptr.To(resource.MustParse("64Mi")),
+				cacheMaxDirty:     ptr.To(resource.MustParse("32Mi")),
+			}
+			Expect(opts.config()).To(Equal("[client]\n" +
+				"client_mount_timeout = 1.5\n" +
+				"rbd_cache_size = 67108864\n" +
+				"rbd_cache_max_dirty = 33554432\n"))
+		})
+
+		It("should be empty without options", func() {
+			Expect((&rbdOptions{}).config()).To(BeEmpty())
+		})
+	})
+
+	Describe("driverModes", func() {
+		defaults := volume.DriverModes{Cache: "writethrough", IO: volume.IOModeThreads}
+
+		It("should render the librbd cache as cache mode", func() {
+			Expect((&rbdOptions{cache: ptr.To(true)}).driverModes(defaults)).To(Equal(volume.DriverModes{Cache: "writeback", IO: volume.IOModeThreads}))
+			Expect((&rbdOptions{cache: ptr.To(false)}).driverModes(defaults)).To(Equal(volume.DriverModes{Cache: volume.CacheModeNone, IO: volume.IOModeThreads}))
+		})
+
+		It("should keep the defaults if the librbd cache isn't configured", func() {
+			Expect((&rbdOptions{}).driverModes(defaults)).To(Equal(defaults))
+		})
+	})
+
+	Describe("checkImage", func() {
+		opts := &rbdOptions{stripeUnit: 65536, stripeCount: 4, features: []string{"layering"}}
+
+		It("should accept images striped and with the features as required", func() {
+			Expect(opts.checkImage(&imageInfo{stripeUnit: 65536, stripeCount: 4, features: []string{"layering", "exclusive-lock"}})).To(Succeed())
+			Expect((&rbdOptions{}).checkImage(&imageInfo{stripeUnit: 4096, stripeCount: 1})).To(Succeed())
+		})
+
+		It("should reject images striped otherwise or missing features", func() {
+			Expect(opts.checkImage(&imageInfo{stripeUnit: 4096, stripeCount: 4, features: []string{"layering"}})).To(MatchError(ContainSubstring("stripe unit")))
+			Expect(opts.checkImage(&imageInfo{stripeUnit: 65536, stripeCount: 1, features: []string{"layering"}})).To(MatchError(ContainSubstring("stripe count")))
+			Expect(opts.checkImage(&imageInfo{stripeUnit: 65536, stripeCount: 4})).To(MatchError(ContainSubstring("feature layering")))
+		})
+	})
+})
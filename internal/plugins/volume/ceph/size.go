@@ -9,7 +9,6 @@ import (
 	"fmt"
 	"os"
 	"strings"
-	"time"
 
 	"github.com/ceph/go-ceph/rados"
 	"github.com/ceph/go-ceph/rbd"
@@ -64,34 +63,55 @@ func connectToRados(ctx context.Context, monitors, user, keyfile string) (*rados
 	return conn, nil
 }
 
+// imageInfo is the size and the layout of an image.
+type imageInfo struct {
+	size        uint64
+	stripeUnit  uint64
+	stripeCount uint64
+	features    []string
+}
+
 func (p *plugin) GetSize(ctx context.Context, spec *api.VolumeSpec) (int64, error) {
+	info, err := p.inspectImage(ctx, spec)
+	if err != nil {
+		return 0, err
+	}
+	return int64(info.size), nil
+}
+
+func (p *plugin) inspectImage(ctx context.Context, spec *api.VolumeSpec) (*imageInfo, error) {
 	log := logr.FromContextOrDiscard(ctx)
 
 	if spec.Connection == nil {
-		return 0, errors.New("connection data is not set")
+		return nil, errors.New("connection data is not set")
 	}
 
 	userID, userKey, err := readSecretData(spec.Connection.SecretData)
 	if err != nil {
-		return 0, fmt.Errorf("error reading secret data: %w", err)
+		return nil, fmt.Errorf("error reading secret data: %w", err)
 	}
 
 	monitors, ok := spec.Connection.Attributes[volumeAttributesMonitorsKey]
 	if !ok {
-		return 0, fmt.Errorf("no monitors at %s", volumeAttributesMonitorsKey)
+		return nil, fmt.Errorf("no monitors at %s", volumeAttributesMonitorsKey)
 	}
 
 	imageHandle, ok := spec.Connection.Attributes[volumeAttributeImageKey]
 	if !ok {
-		return 0, fmt.Errorf("no image at %s", volumeAttributeImageKey)
+		return nil, fmt.Errorf("no image at %s", volumeAttributeImageKey)
 	}
 
 	parts := strings.SplitN(imageHandle, "/", 2)
 	if len(parts) != 2 {
-		return 0, fmt.Errorf("image handle is not well formated: expected 'pool/image' format but got %s", imageHandle)
+		return nil, fmt.Errorf("image handle is not well formated: expected 'pool/image' format but got %s", imageHandle)
 	}
 	poolName, imageName := parts[0], parts[1]
 
+	opts, err := readRBDOptions(spec.Connection.Attributes)
+	if err != nil {
+		return nil, fmt.Errorf("error reading rbd options: %w", err)
+	}
+
 	keyFile, cleanup, err := createKeyFile(imageName, userKey)
 	defer func() {
 		if err := cleanup(); err != nil {
@@ -99,40 +119,68 @@ func (p *plugin) GetSize(ctx context.Context, spec *api.VolumeSpec) (int64, erro
 		}
 	}()
 	if err != nil {
-		return 0, fmt.Errorf("failed to create temp key file: %w", err)
+		return nil, fmt.Errorf("failed to create temp key file: %w", err)
 	}
 
-	timeoutCtx, cancel := context.WithTimeout(ctx, time.Second)
+	timeoutCtx, cancel := context.WithTimeout(ctx, opts.connectTimeout())
 	defer cancel()
 
 	conn, err := connectToRados(timeoutCtx, monitors, userID, keyFile)
 	if err != nil {
-		return 0, fmt.Errorf("failed to open connection: %w", err)
+		return nil, fmt.Errorf("failed to open connection: %w", err)
 	}
 	defer conn.Shutdown()
 
 	ioCtx, err := conn.OpenIOContext(poolName)
 	if err != nil {
-		return 0, fmt.Errorf("failed to open io context: %w", err)
+		return nil, fmt.Errorf("failed to open io context: %w", err)
 	}
 	defer ioCtx.Destroy()
+	ioCtx.SetNamespace(opts.namespace)
 
 	image, err := rbd.OpenImageReadOnly(ioCtx, imageName, rbd.NoSnapshot)
 	if err != nil {
-		return 0, fmt.Errorf("failed to open image: %w", err)
+		return nil, fmt.Errorf("failed to open image: %w", err)
 	}
 
-	size, err := image.GetSize()
+	info, err := readImageInfo(image)
 	if err != nil {
 		if closeErr := image.Close(); closeErr != nil {
-			return 0, errors.Join(err, fmt.Errorf("unable to close image: %w", closeErr))
+			return nil, errors.Join(err, fmt.Errorf("unable to close image: %w", closeErr))
 		}
-		return 0, fmt.Errorf("failed to set encryption format: %w", err)
+		return nil, err
 	}
 
 	if err := image.Close(); err != nil {
-		return 0, fmt.Errorf("failed to close rbd image: %w", err)
+		return nil, fmt.Errorf("failed to close rbd image: %w", err)
+	}
+
+	return info, nil
+}
+
+func readImageInfo(image *rbd.Image) (*imageInfo, error) {
+	size, err := image.GetSize()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image size: %w", err)
+	}
+	stripeUnit, err := image.GetStripeUnit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image stripe unit: %w", err)
+	}
+	stripeCount, err := image.GetStripeCount()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image stripe count: %w", err)
+	}
+	features, err := image.GetFeatures()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image features: %w", err)
 	}
 
-	return int64(size), nil
+	featureSet := rbd.FeatureSet(features)
+	return &imageInfo{
+		size:        size,
+		stripeUnit:  stripeUnit,
+		stripeCount: stripeCount,
+		features:    featureSet.Names(),
+	}, nil
 }
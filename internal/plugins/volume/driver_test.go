@@ -0,0 +1,93 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package volume_test
+
+import (
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Driver modes", func() {
+	DescribeTable("ValidateDriverModes",
+		func(cache, io string, matchErr any) {
+			err := volume.ValidateDriverModes(cache, io)
+			if matchErr == nil {
+				Expect(err).NotTo(HaveOccurred())
+			} else {
+				Expect(err).To(MatchError(matchErr))
+			}
+		},
+		Entry("no modes", "", "", nil),
+		Entry("native io without cache", "none", "native", nil),
+		Entry("native io with direct sync", "directsync", "native", nil),
+		Entry("native io with unknown cache mode", "", "native", nil),
+		Entry("threads with cache", "writeback", "threads", nil),
+		Entry("native io with cache", "writeback", "native", ContainSubstring("requires one of the cache modes")),
+		Entry("unsupported cache mode", "fast", "", ContainSubstring("unsupported cache mode")),
+		Entry("unsupported io mode", "", "fast", ContainSubstring("unsupported io mode")),
+	)
+
+	DescribeTable("ReadDiscardAttributes",
+		func(attrs map[string]string, discard, detectZeroes string, matchErr any) {
+			actualDiscard, actualDetectZeroes, err := volume.ReadDiscardAttributes(attrs)
+			if matchErr != nil {
+				Expect(err).To(MatchError(matchErr))
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			Expect(actualDiscard).To(Equal(discard))
+			Expect(actualDetectZeroes).To(Equal(detectZeroes))
+		},
+		Entry("no attributes", nil, "", "", nil),
+		Entry("discard only", map[string]string{"discard": "unmap"}, "unmap", "", nil),
+		Entry("detect zeroes only", map[string]string{"detect_zeroes": "on"}, "", "on", nil),
+		Entry("both modes", map[string]string{"discard": "ignore", "detect_zeroes": "unmap"}, "ignore", "unmap", nil),
+		Entry("unsupported discard mode", map[string]string{"discard": "trim"}, "", "",
+			ContainSubstring("unsupported discard mode")),
+		Entry("unsupported detect zeroes mode", map[string]string{"discard": "unmap", "detect_zeroes": "always"}, "", "",
+			ContainSubstring("unsupported detect_zeroes mode")),
+	)
+
+	DescribeTable("Apply",
+		func(defaults volume.DriverModes, spec *api.VolumeSpec, cache, io string) {
+			vol := &volume.Volume{}
+			Expect(defaults.Apply(vol, spec)).To(Succeed())
+			Expect(vol.Cache).To(Equal(cache))
+			Expect(vol.IO).To(Equal(io))
+		},
+		Entry("defaults",
+			volume.DriverModes{Cache: "none", IO: "native"}, &api.VolumeSpec{},
+			"none", "native"),
+		Entry("spec modes",
+			volume.DriverModes{Cache: "none", IO: "native"},
+			&api.VolumeSpec{Driver: &api.VolumeDriverSpec{Cache: "writeback", IO: "io_uring"}},
+			"writeback", "io_uring"),
+		Entry("default native io of a cached volume",
+			volume.DriverModes{Cache: "none", IO: "native"},
+			&api.VolumeSpec{Driver: &api.VolumeDriverSpec{Cache: "writeback"}},
+			"writeback", "threads"),
+		Entry("spec native io of a volume without cache",
+			volume.DriverModes{Cache: "writeback"},
+			&api.VolumeSpec{Driver: &api.VolumeDriverSpec{Cache: "directsync", IO: "native"}},
+			"directsync", "native"),
+		Entry("native io of a shareable volume",
+			volume.DriverModes{Cache: "writeback"},
+			&api.VolumeSpec{Shareable: true, Driver: &api.VolumeDriverSpec{IO: "native"}},
+			"none", "native"),
+	)
+
+	It("should reject native io set by the spec of a cached volume", func() {
+		vol := &volume.Volume{}
+		err := volume.DriverModes{Cache: "writeback", IO: "threads"}.Apply(vol, &api.VolumeSpec{
+			Driver: &api.VolumeDriverSpec{IO: "native"},
+		})
+		Expect(err).To(MatchError(ContainSubstring("requires one of the cache modes")))
+
+		By("rejecting native io of a volume with the hypervisor's cache mode")
+		err = volume.DriverModes{}.Apply(vol, &api.VolumeSpec{Driver: &api.VolumeDriverSpec{IO: "native"}})
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -50,11 +50,11 @@ func (p *plugin) Name() string {
 	return pluginName
 }
 
-func (p *plugin) GetBackingVolumeID(volume *api.VolumeSpec) (string, error) {
+func (p *plugin) GetBackingVolumeID(volume *api.VolumeSpec, machineID string) (string, error) {
 	if volume.EmptyDisk == nil {
 		return "", fmt.Errorf("volume does not specify an EmptyDisk")
 	}
-	return volume.Name, nil
+	return fmt.Sprintf("%s/%s", machineID, volume.Name), nil
 }
 
 func (p *plugin) CanSupport(volume *api.VolumeSpec) bool {
@@ -94,7 +94,18 @@ func (p *plugin) Apply(ctx context.Context, spec *api.VolumeSpec, machine *api.M
 			return nil, fmt.Errorf("error changing disk file mode: %w", err)
 		}
 	}
-	return &volume.Volume{RawFile: diskFilename, Handle: handle, Size: size}, nil
+	vol := &volume.Volume{
+		RawFile:      diskFilename,
+		Handle:       handle,
+		Size:         size,
+		Discard:      volume.DiscardUnmap,
+		DetectZeroes: volume.DetectZeroesUnmap,
+	}
+	// Disk files default to the hypervisor modes, not all host file systems support bypassing the page cache.
+	if err := (volume.DriverModes{}).Apply(vol, spec); err != nil {
+		return nil, err
+	}
+	return vol, nil
 }
 
 func (p *plugin) Delete(ctx context.Context, computeVolumeName string, machineID string) error {
@@ -106,6 +117,11 @@ func (p *plugin) GetSize(ctx context.Context, spec *api.VolumeSpec) (int64, erro
 	return spec.EmptyDisk.Size, nil
 }
 
+func (p *plugin) HealthCheck(ctx context.Context) error {
+	// Empty disks are files in the machine directories, which are checked by the provider itself.
+	return nil
+}
+
 // randomHex generates random hexadecimal digits of the length n*2.
 func randomHex(n int) (string, error) {
 	bytes := make([]byte, n)
@@ -0,0 +1,315 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package external provides the volume plugins served out-of-tree via pluginapi on unix sockets.
+package external
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
+	"github.com/ironcore-dev/libvirt-provider/pkg/pluginapi"
+	"google.golang.org/grpc"
+	"k8s.io/apimachinery/pkg/util/wait"
+	utilstrings "k8s.io/utils/strings"
+)
+
+const (
+	// SocketSuffix is the suffix of the plugin sockets discovered in the plugins directory.
+	SocketSuffix = ".sock"
+
+	// discoveryTimeout bounds the time a discovered plugin has to respond with its info.
+	discoveryTimeout = 10 * time.Second
+
+	perm = 0777
+)
+
+type plugin struct {
+	host volume.Host
+
+	conn   *grpc.ClientConn
+	client *pluginapi.VolumePluginClient
+
+	name    string
+	drivers []string
+}
+
+// Discoverer discovers the plugins serving the sockets in a directory. Plugins not responding are skipped, so a
+// stale socket doesn't prevent the provider from starting, and discovered again once they respond.
+type Discoverer struct {
+	log logr.Logger
+	dir string
+
+	mu sync.Mutex
+	// plugins are the discovered plugins by socket path.
+	plugins map[string]*plugin
+}
+
+// NewDiscoverer creates a Discoverer for the sockets in dir. A missing dir means there are no external plugins.
+func NewDiscoverer(log logr.Logger, dir string) *Discoverer {
+	return &Discoverer{
+		log:     log,
+		dir:     dir,
+		plugins: make(map[string]*plugin),
+	}
+}
+
+// Discover returns the plugins serving the sockets in the directory that weren't discovered before.
+func (d *Discoverer) Discover(ctx context.Context) ([]volume.Plugin, error) {
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading external volume plugins directory: %w", err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var plugins []volume.Plugin
+	for _, entry := range entries {
+		if entry.Type()&os.ModeSocket == 0 || !strings.HasSuffix(entry.Name(), SocketSuffix) {
+			continue
+		}
+
+		socketPath := filepath.Join(d.dir, entry.Name())
+		if _, ok := d.plugins[socketPath]; ok {
+			continue
+		}
+
+		discoveryCtx, cancel := context.WithTimeout(ctx, discoveryTimeout)
+		plugin, err := newPlugin(discoveryCtx, socketPath)
+		cancel()
+		if err != nil {
+			d.log.Error(err, "Skipping external volume plugin", "Socket", socketPath)
+			continue
+		}
+		d.log.Info("Discovered external volume plugin", "Socket", socketPath, "Plugin", plugin.Name())
+		d.plugins[socketPath] = plugin
+		plugins = append(plugins, plugin)
+	}
+	return plugins, nil
+}
+
+// Start discovers the plugins coming up after the start of the provider every interval until ctx is done and
+// registers them. Plugins failing to register are closed and discovered again. The connections to all plugins are
+// closed once ctx is done.
+func (d *Discoverer) Start(ctx context.Context, interval time.Duration, register func(volume.Plugin) error) {
+	defer d.close()
+
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		plugins, err := d.Discover(ctx)
+		if err != nil {
+			d.log.Error(err, "Failed to discover external volume plugins")
+			return
+		}
+		for _, plugin := range plugins {
+			if err := register(plugin); err != nil {
+				d.log.Error(err, "Failed to register external volume plugin", "Plugin", plugin.Name())
+				d.forget(plugin)
+			}
+		}
+	}, interval)
+}
+
+// forget closes the connection to the plugin, so it is discovered again.
+func (d *Discoverer) forget(p volume.Plugin) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for socketPath, plugin := range d.plugins {
+		if volume.Plugin(plugin) == p {
+			_ = plugin.conn.Close()
+			delete(d.plugins, socketPath)
+		}
+	}
+}
+
+func (d *Discoverer) close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for socketPath, plugin := range d.plugins {
+		_ = plugin.conn.Close()
+		delete(d.plugins, socketPath)
+	}
+}
+
+// NewPlugin connects to the plugin serving the socket at socketPath and returns it as volume plugin.
+func NewPlugin(ctx context.Context, socketPath string) (volume.Plugin, error) {
+	return newPlugin(ctx, socketPath)
+}
+
+func newPlugin(ctx context.Context, socketPath string) (*plugin, error) {
+	conn, err := pluginapi.Dial(socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to plugin: %w", err)
+	}
+
+	client := pluginapi.NewVolumePluginClient(conn)
+	info, err := client.Info(ctx, &pluginapi.VolumeInfoRequest{})
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("error getting plugin info: %w", err)
+	}
+	if info.Name == "" {
+		_ = conn.Close()
+		return nil, fmt.Errorf("plugin doesn't specify a name")
+	}
+	if len(info.Drivers) == 0 {
+		_ = conn.Close()
+		return nil, fmt.Errorf("plugin %s doesn't specify any volume driver", info.Name)
+	}
+
+	return &plugin{
+		conn:    conn,
+		client:  client,
+		name:    info.Name,
+		drivers: info.Drivers,
+	}, nil
+}
+
+func (p *plugin) Init(host volume.Host) error {
+	p.host = host
+	return nil
+}
+
+func (p *plugin) Name() string {
+	return p.name
+}
+
+func (p *plugin) GetBackingVolumeID(spec *api.VolumeSpec, _ string) (string, error) {
+	connection := spec.Connection
+	if connection == nil {
+		return "", fmt.Errorf("volume does not specify connection")
+	}
+	if connection.Handle == "" {
+		return "", fmt.Errorf("volume connection does not specify handle")
+	}
+
+	return fmt.Sprintf("%s^%s", p.name, connection.Handle), nil
+}
+
+func (p *plugin) CanSupport(spec *api.VolumeSpec) bool {
+	connection := spec.Connection
+	if connection == nil {
+		return false
+	}
+
+	return slices.Contains(p.drivers, connection.Driver)
+}
+
+func (p *plugin) volumeDir(computeVolumeName, machineID string) string {
+	return p.host.MachineVolumeDir(machineID, utilstrings.EscapeQualifiedName(p.name), computeVolumeName)
+}
+
+func (p *plugin) Apply(ctx context.Context, spec *api.VolumeSpec, machine *api.Machine) (*volume.Volume, error) {
+	volumeDir := p.volumeDir(spec.Name, machine.ID)
+	if err := os.MkdirAll(volumeDir, perm); err != nil {
+		return nil, err
+	}
+
+	res, err := p.client.ApplyVolume(ctx, &pluginapi.ApplyVolumeRequest{
+		Volume:    spec,
+		MachineID: machine.ID,
+		VolumeDir: volumeDir,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("[plugin %s] error applying volume: %w", p.name, err)
+	}
+
+	vol, err := convertVolume(res.Volume)
+	if err != nil {
+		return nil, fmt.Errorf("[plugin %s] invalid volume: %w", p.name, err)
+	}
+	// The driver modes of the spec take precedence over the ones of the plugin.
+	if err := (volume.DriverModes{Cache: vol.Cache, IO: vol.IO}).Apply(vol, spec); err != nil {
+		return nil, fmt.Errorf("[plugin %s] %w", p.name, err)
+	}
+	return vol, nil
+}
+
+func convertVolume(vol *pluginapi.Volume) (*volume.Volume, error) {
+	if vol == nil {
+		return nil, fmt.Errorf("no volume returned")
+	}
+
+	var disks int
+	for _, set := range []bool{vol.QCow2File != "", vol.RawFile != "", vol.BlockDevice != "", vol.CephDisk != nil} {
+		if set {
+			disks++
+		}
+	}
+	if disks != 1 {
+		return nil, fmt.Errorf("exactly one of qcow2 file, raw file, block device and ceph disk has to be set")
+	}
+	if err := volume.ValidateDriverModes(vol.Cache, vol.IO); err != nil {
+		return nil, err
+	}
+	if _, _, err := volume.ReadDiscardAttributes(map[string]string{
+		volume.VolumeAttributeDiscardKey:      vol.Discard,
+		volume.VolumeAttributeDetectZeroesKey: vol.DetectZeroes,
+	}); err != nil {
+		return nil, err
+	}
+
+	res := &volume.Volume{
+		QCow2File:    vol.QCow2File,
+		RawFile:      vol.RawFile,
+		BlockDevice:  vol.BlockDevice,
+		Handle:       vol.Handle,
+		Size:         vol.Size,
+		Cache:        vol.Cache,
+		IO:           vol.IO,
+		Discard:      vol.Discard,
+		DetectZeroes: vol.DetectZeroes,
+	}
+	if disk := vol.CephDisk; disk != nil {
+		res.CephDisk = &volume.CephDisk{Name: disk.Name, Namespace: disk.Namespace, ConfigFile: disk.ConfigFile}
+		for _, monitor := range disk.Monitors {
+			res.CephDisk.Monitors = append(res.CephDisk.Monitors, volume.CephMonitor{Name: monitor.Name, Port: monitor.Port})
+		}
+		if disk.UserName != "" {
+			res.CephDisk.Auth = &volume.CephAuthentication{UserName: disk.UserName, UserKey: disk.UserKey}
+		}
+		if disk.EncryptionKey != "" {
+			res.CephDisk.Encryption = &volume.CephEncryption{EncryptionKey: disk.EncryptionKey}
+		}
+	}
+	return res, nil
+}
+
+func (p *plugin) Delete(ctx context.Context, computeVolumeName string, machineID string) error {
+	if _, err := p.client.DeleteVolume(ctx, &pluginapi.DeleteVolumeRequest{
+		ComputeVolumeName: computeVolumeName,
+		MachineID:         machineID,
+	}); err != nil {
+		return fmt.Errorf("[plugin %s] error deleting volume: %w", p.name, err)
+	}
+
+	return os.RemoveAll(p.volumeDir(computeVolumeName, machineID))
+}
+
+func (p *plugin) GetSize(ctx context.Context, spec *api.VolumeSpec) (int64, error) {
+	res, err := p.client.GetVolumeSize(ctx, &pluginapi.GetVolumeSizeRequest{Volume: spec})
+	if err != nil {
+		return 0, fmt.Errorf("[plugin %s] error getting volume size: %w", p.name, err)
+	}
+	return res.Size, nil
+}
+
+func (p *plugin) HealthCheck(ctx context.Context) error {
+	_, err := p.client.HealthCheck(ctx, &pluginapi.HealthCheckRequest{})
+	return err
+}
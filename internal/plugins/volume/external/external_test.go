@@ -0,0 +1,151 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package external_test
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	providerhost "github.com/ironcore-dev/libvirt-provider/internal/host"
+	"github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
+	"github.com/ironcore-dev/libvirt-provider/internal/plugins/volume/external"
+	"github.com/ironcore-dev/libvirt-provider/pkg/pluginapi"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type fakeServer struct {
+	deleted []string
+}
+
+func (s *fakeServer) Info(context.Context, *pluginapi.VolumeInfoRequest) (*pluginapi.VolumeInfoResponse, error) {
+	return &pluginapi.VolumeInfoResponse{Name: "example.org/nfs", Drivers: []string{"nfs"}}, nil
+}
+
+func (s *fakeServer) ApplyVolume(_ context.Context, req *pluginapi.ApplyVolumeRequest) (*pluginapi.ApplyVolumeResponse, error) {
+	if req.Volume.Connection.Handle == "invalid" {
+		return &pluginapi.ApplyVolumeResponse{Volume: &pluginapi.Volume{}}, nil
+	}
+	return &pluginapi.ApplyVolumeResponse{Volume: &pluginapi.Volume{
+		RawFile: filepath.Join(req.VolumeDir, "disk.raw"),
+		Handle:  req.Volume.Connection.Handle,
+		Size:    1 << 30,
+		Cache:   "writeback",
+	}}, nil
+}
+
+func (s *fakeServer) DeleteVolume(_ context.Context, req *pluginapi.DeleteVolumeRequest) (*pluginapi.DeleteVolumeResponse, error) {
+	s.deleted = append(s.deleted, req.ComputeVolumeName)
+	return &pluginapi.DeleteVolumeResponse{}, nil
+}
+
+func (s *fakeServer) GetVolumeSize(context.Context, *pluginapi.GetVolumeSizeRequest) (*pluginapi.GetVolumeSizeResponse, error) {
+	return &pluginapi.GetVolumeSizeResponse{Size: 1 << 30}, nil
+}
+
+func (s *fakeServer) HealthCheck(context.Context, *pluginapi.HealthCheckRequest) (*pluginapi.HealthCheckResponse, error) {
+	return nil, status.Error(codes.Unavailable, "nfs server unreachable")
+}
+
+var _ = Describe("External volume plugins", func() {
+	var socketsDir string
+
+	BeforeEach(func() {
+		// Unix socket paths are limited in length, the ginkgo temp dirs may exceed it.
+		var err error
+		socketsDir, err = os.MkdirTemp("", "plugins")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(os.RemoveAll, socketsDir)
+	})
+
+	serve := func(socketName string) *fakeServer {
+		listener, err := net.Listen("unix", filepath.Join(socketsDir, socketName))
+		Expect(err).NotTo(HaveOccurred())
+		server := &fakeServer{}
+		grpcServer := grpc.NewServer(pluginapi.ServerOption())
+		pluginapi.RegisterVolumePluginServer(grpcServer, server)
+		go func() {
+			defer GinkgoRecover()
+			Expect(grpcServer.Serve(listener)).To(Succeed())
+		}()
+		DeferCleanup(grpcServer.Stop)
+		return server
+	}
+
+	It("should forward the plugin calls to the discovered plugins", func(ctx SpecContext) {
+		server := serve("nfs.sock")
+
+		plugins, err := external.NewDiscoverer(logr.Discard(), socketsDir).Discover(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(plugins).To(HaveLen(1))
+		plugin := plugins[0]
+		Expect(plugin.Name()).To(Equal("example.org/nfs"))
+
+		paths, err := providerhost.PathsAt(GinkgoT().TempDir())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(plugin.Init(paths)).To(Succeed())
+
+		spec := &api.VolumeSpec{Name: "data", Connection: &api.VolumeConnection{Driver: "nfs", Handle: "share-1"}}
+		Expect(plugin.CanSupport(spec)).To(BeTrue())
+		Expect(plugin.CanSupport(&api.VolumeSpec{Connection: &api.VolumeConnection{Driver: "ceph"}})).To(BeFalse())
+		Expect(plugin.GetBackingVolumeID(spec, "machine")).To(Equal("example.org/nfs^share-1"))
+
+		vol, err := plugin.Apply(ctx, spec, &api.Machine{Metadata: api.Metadata{ID: "machine"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(vol.RawFile).To(HaveSuffix("disk.raw"))
+		Expect(filepath.Dir(vol.RawFile)).To(BeADirectory())
+		Expect(vol.Cache).To(Equal("writeback"))
+		Expect(vol.Size).To(Equal(int64(1 << 30)))
+
+		By("rejecting invalid volumes of the plugin")
+		_, err = plugin.Apply(ctx, &api.VolumeSpec{Name: "invalid", Connection: &api.VolumeConnection{Driver: "nfs", Handle: "invalid"}}, &api.Machine{Metadata: api.Metadata{ID: "machine"}})
+		Expect(err).To(MatchError(ContainSubstring("exactly one of")))
+
+		Expect(plugin.Delete(ctx, "data", "machine")).To(Succeed())
+		Expect(server.deleted).To(Equal([]string{"data"}))
+		Expect(filepath.Dir(vol.RawFile)).NotTo(BeADirectory())
+
+		Expect(plugin.HealthCheck(ctx)).To(MatchError(ContainSubstring("nfs server unreachable")))
+	})
+
+	It("should discover plugins coming up later once", func(ctx SpecContext) {
+		discoverer := external.NewDiscoverer(logr.Discard(), socketsDir)
+		Expect(discoverer.Discover(ctx)).To(BeEmpty())
+
+		serve("nfs.sock")
+		Expect(discoverer.Discover(ctx)).To(HaveLen(1))
+		Expect(discoverer.Discover(ctx)).To(BeEmpty())
+	})
+
+	It("should register the discovered plugins and close their connections once stopped", func(ctx SpecContext) {
+		serve("nfs.sock")
+
+		startCtx, cancel := context.WithCancel(ctx)
+		registered := make(chan volume.Plugin, 1)
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			external.NewDiscoverer(logr.Discard(), socketsDir).Start(startCtx, 10*time.Millisecond, func(plugin volume.Plugin) error {
+				registered <- plugin
+				return nil
+			})
+		}()
+
+		var plugin volume.Plugin
+		Eventually(ctx, registered).Should(Receive(&plugin))
+		Expect(plugin.Name()).To(Equal("example.org/nfs"))
+
+		cancel()
+		Eventually(ctx, stopped).Should(BeClosed())
+		Expect(status.Code(plugin.HealthCheck(ctx))).To(Equal(codes.Canceled))
+	})
+})
@@ -0,0 +1,292 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package hostdevice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
+	utilstrings "k8s.io/utils/strings"
+)
+
+const (
+	pluginName = "libvirt-provider.ironcore.dev/host-device"
+
+	hostDeviceDriverName = "host-device"
+
+	volumeAttributePathKey = "path"
+	volumeAttributeWWNKey  = "wwn"
+
+	diskByIDDir = "/dev/disk/by-id"
+
+	locksDir   = "locks"
+	deviceFile = "device"
+
+	perm     = 0777
+	filePerm = 0666
+)
+
+// driverModes bypass the host page cache, the guest caches the device itself.
+var driverModes = volume.DriverModes{Cache: volume.CacheModeNone, IO: volume.IOModeNative}
+
+type plugin struct {
+	host volume.Host
+
+	// mu guards the device locks.
+	mu sync.Mutex
+}
+
+type volumeData struct {
+	device string
+	handle string
+
+	discard      string
+	detectZeroes string
+}
+
+// NewPlugin creates a volume plugin that passes existing host block devices through to machines.
+func NewPlugin() volume.Plugin {
+	return &plugin{}
+}
+
+func (p *plugin) Init(host volume.Host) error {
+	p.host = host
+	return os.MkdirAll(p.locksDir(), perm)
+}
+
+func (p *plugin) Name() string {
+	return pluginName
+}
+
+func (p *plugin) GetBackingVolumeID(spec *api.VolumeSpec, _ string) (string, error) {
+	vData, err := p.getVolumeData(spec)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s^%s", pluginName, vData.handle), nil
+}
+
+func (p *plugin) CanSupport(spec *api.VolumeSpec) bool {
+	connection := spec.Connection
+	if connection == nil {
+		return false
+	}
+
+	return connection.Driver == hostDeviceDriverName
+}
+
+func (p *plugin) getVolumeData(spec *api.VolumeSpec) (*volumeData, error) {
+	connection := spec.Connection
+	if connection == nil {
+		return nil, fmt.Errorf("volume does not specify connection")
+	}
+	if connection.Driver != hostDeviceDriverName {
+		return nil, fmt.Errorf("volume connection specifies invalid driver %q", connection.Driver)
+	}
+	if connection.Handle == "" {
+		return nil, fmt.Errorf("volume connection does not specify handle")
+	}
+
+	vData := &volumeData{handle: connection.Handle}
+
+	path, wwn := connection.Attributes[volumeAttributePathKey], connection.Attributes[volumeAttributeWWNKey]
+	switch {
+	case path != "" && wwn != "":
+		return nil, fmt.Errorf("must not specify both %s and %s", volumeAttributePathKey, volumeAttributeWWNKey)
+	case path != "":
+		// Only stable device paths are allowed, kernel names like /dev/sda may change across reboots.
+		if filepath.Dir(filepath.Clean(path)) != diskByIDDir {
+			return nil, fmt.Errorf("device path %q is not located in %s", path, diskByIDDir)
+		}
+		vData.device = filepath.Clean(path)
+	case wwn != "":
+		if strings.ContainsRune(wwn, '/') {
+			return nil, fmt.Errorf("invalid wwn %q", wwn)
+		}
+		vData.device = filepath.Join(diskByIDDir, "wwn-"+strings.TrimPrefix(wwn, "wwn-"))
+	default:
+		return nil, fmt.Errorf("no device data at %s or %s", volumeAttributePathKey, volumeAttributeWWNKey)
+	}
+
+	discard, detectZeroes, err := volume.ReadDiscardAttributes(connection.Attributes)
+	if err != nil {
+		return nil, err
+	}
+	vData.discard, vData.detectZeroes = discard, detectZeroes
+
+	return vData, nil
+}
+
+func (p *plugin) locksDir() string {
+	return filepath.Join(p.host.PluginDir(utilstrings.EscapeQualifiedName(pluginName)), locksDir)
+}
+
+func (p *plugin) lockFile(device string) string {
+	return filepath.Join(p.locksDir(), utilstrings.EscapeQualifiedName(strings.TrimPrefix(device, "/")))
+}
+
+func (p *plugin) volumeDir(computeVolumeName, machineID string) string {
+	return p.host.MachineVolumeDir(machineID, utilstrings.EscapeQualifiedName(pluginName), computeVolumeName)
+}
+
+func lockOwner(computeVolumeName, machineID string) string {
+	return machineID + "/" + computeVolumeName
+}
+
+// lock claims the device for the given owner and reports whether it claimed it just now. A device can only be used
+// by a single volume at a time.
+func (p *plugin) lock(device, owner string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	lockFile := p.lockFile(device)
+	current, err := os.ReadFile(lockFile)
+	switch {
+	case err == nil:
+		if string(current) != owner {
+			return false, fmt.Errorf("device %s is already in use by %s", device, string(current))
+		}
+		return false, nil
+	case !errors.Is(err, os.ErrNotExist):
+		return false, fmt.Errorf("error reading device lock: %w", err)
+	}
+
+	f, err := os.OpenFile(lockFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, filePerm)
+	if err != nil {
+		return false, fmt.Errorf("error creating device lock: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	if _, err := f.WriteString(owner); err != nil {
+		_ = os.Remove(lockFile)
+		return false, fmt.Errorf("error writing device lock: %w", err)
+	}
+	return true, nil
+}
+
+func (p *plugin) unlock(device, owner string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	lockFile := p.lockFile(device)
+	current, err := os.ReadFile(lockFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("error reading device lock: %w", err)
+	}
+	if string(current) != owner {
+		return nil
+	}
+
+	return os.Remove(lockFile)
+}
+
+func (p *plugin) Apply(ctx context.Context, spec *api.VolumeSpec, machine *api.Machine) (vol *volume.Volume, retErr error) {
+	vData, err := p.getVolumeData(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get volume data: %w", err)
+	}
+
+	volumeDir := p.volumeDir(spec.Name, machine.ID)
+	if err := os.MkdirAll(volumeDir, perm); err != nil {
+		return nil, err
+	}
+
+	// Shareable devices are used by several volumes at a time, the provider only allows it if all of them are
+	// shareable.
+	if !spec.Shareable {
+		owner := lockOwner(spec.Name, machine.ID)
+		locked, err := p.lock(vData.device, owner)
+		if err != nil {
+			return nil, err
+		}
+		if locked {
+			// A device that can't be used isn't claimed, so other volumes can use it once it's usable.
+			defer func() {
+				if retErr != nil {
+					_ = p.unlock(vData.device, owner)
+				}
+			}()
+		}
+	}
+	if err := os.WriteFile(filepath.Join(volumeDir, deviceFile), []byte(vData.device), filePerm); err != nil {
+		return nil, fmt.Errorf("error writing device reference: %w", err)
+	}
+
+	if err := checkNotMounted(vData.device); err != nil {
+		return nil, fmt.Errorf("device cannot be used: %w", err)
+	}
+
+	size, err := deviceSize(vData.device)
+	if err != nil {
+		return nil, err
+	}
+
+	vol = &volume.Volume{
+		BlockDevice: vData.device,
+		Handle:      vData.handle,
+		Size:        size,
+
+		Discard:      vData.discard,
+		DetectZeroes: vData.detectZeroes,
+	}
+	if err := driverModes.Apply(vol, spec); err != nil {
+		return nil, err
+	}
+	return vol, nil
+}
+
+func (p *plugin) Delete(ctx context.Context, computeVolumeName string, machineID string) error {
+	volumeDir := p.volumeDir(computeVolumeName, machineID)
+
+	device, err := os.ReadFile(filepath.Join(volumeDir, deviceFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error reading device reference: %w", err)
+	}
+	if len(device) > 0 {
+		if err := p.unlock(string(device), lockOwner(computeVolumeName, machineID)); err != nil {
+			return fmt.Errorf("error releasing device lock: %w", err)
+		}
+	}
+
+	return os.RemoveAll(volumeDir)
+}
+
+func (p *plugin) GetSize(ctx context.Context, spec *api.VolumeSpec) (int64, error) {
+	vData, err := p.getVolumeData(spec)
+	if err != nil {
+		return 0, err
+	}
+	return deviceSize(vData.device)
+}
+
+func deviceSize(device string) (int64, error) {
+	f, err := os.Open(device)
+	if err != nil {
+		return 0, fmt.Errorf("error opening device %s: %w", device, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, fmt.Errorf("error determining size of device %s: %w", device, err)
+	}
+	return size, nil
+}
+
+func (p *plugin) HealthCheck(ctx context.Context) error {
+	// The devices are checked per volume, a missing device only affects its volume.
+	return nil
+}
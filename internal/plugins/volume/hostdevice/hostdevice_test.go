@@ -0,0 +1,73 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package hostdevice
+
+import (
+	"github.com/ironcore-dev/libvirt-provider/api"
+	providerhost "github.com/ironcore-dev/libvirt-provider/internal/host"
+	"github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Host device volume plugin", func() {
+	DescribeTable("driver modes",
+		func(driver *api.VolumeDriverSpec, cache, io string) {
+			vol := &volume.Volume{}
+			Expect(driverModes.Apply(vol, &api.VolumeSpec{Driver: driver})).To(Succeed())
+			Expect(vol.Cache).To(Equal(cache))
+			Expect(vol.IO).To(Equal(io))
+		},
+		Entry("default", nil, "none", "native"),
+		Entry("direct cache mode", &api.VolumeDriverSpec{Cache: "directsync"}, "directsync", "native"),
+		Entry("cached mode", &api.VolumeDriverSpec{Cache: "writeback"}, "writeback", "threads"),
+		Entry("explicit io mode", &api.VolumeDriverSpec{Cache: "writeback", IO: "io_uring"}, "writeback", "io_uring"),
+	)
+
+	Describe("Apply", func() {
+		var p volume.Plugin
+
+		BeforeEach(func() {
+			host, err := providerhost.NewAt(GinkgoT().TempDir())
+			Expect(err).NotTo(HaveOccurred())
+			p = NewPlugin()
+			Expect(p.Init(host)).To(Succeed())
+		})
+
+		newSpec := func(name string) *api.VolumeSpec {
+			return &api.VolumeSpec{
+				Name: name,
+				Connection: &api.VolumeConnection{
+					Driver:     hostDeviceDriverName,
+					Handle:     "disk",
+					Attributes: map[string]string{volumeAttributePathKey: "/dev/disk/by-id/missing"},
+				},
+			}
+		}
+
+		It("should not claim a device that can't be used", func(ctx SpecContext) {
+			_, err := p.Apply(ctx, newSpec("foo"), &api.Machine{Metadata: api.Metadata{ID: "a"}})
+			Expect(err).To(MatchError(ContainSubstring("device cannot be used")))
+
+			By("applying the device for another machine")
+			_, err = p.Apply(ctx, newSpec("foo"), &api.Machine{Metadata: api.Metadata{ID: "b"}})
+			Expect(err).To(MatchError(ContainSubstring("device cannot be used")))
+			Expect(err).NotTo(MatchError(ContainSubstring("already in use")))
+		})
+
+		It("should keep the claim of a device in use", func(ctx SpecContext) {
+			plugin := p.(*plugin)
+			locked, err := plugin.lock("/dev/disk/by-id/missing", lockOwner("foo", "a"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(locked).To(BeTrue())
+
+			By("applying the claimed device again")
+			_, err = p.Apply(ctx, newSpec("foo"), &api.Machine{Metadata: api.Metadata{ID: "a"}})
+			Expect(err).To(HaveOccurred())
+
+			_, err = p.Apply(ctx, newSpec("foo"), &api.Machine{Metadata: api.Metadata{ID: "b"}})
+			Expect(err).To(MatchError(ContainSubstring("already in use by a/foo")))
+		})
+	})
+})
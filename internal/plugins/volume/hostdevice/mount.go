@@ -0,0 +1,90 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package hostdevice
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	mountInfoFile  = "/proc/self/mountinfo"
+	sysClassBlock  = "/sys/class/block"
+	partitionEntry = "partition"
+)
+
+// deviceNumbers returns the major:minor numbers of the given block device and all of its partitions.
+func deviceNumbers(device string) ([]string, error) {
+	var stat unix.Stat_t
+	if err := unix.Stat(device, &stat); err != nil {
+		return nil, fmt.Errorf("error stat-ing device %s: %w", device, err)
+	}
+	if stat.Mode&unix.S_IFMT != unix.S_IFBLK {
+		return nil, fmt.Errorf("%s is not a block device", device)
+	}
+
+	numbers := []string{fmt.Sprintf("%d:%d", unix.Major(uint64(stat.Rdev)), unix.Minor(uint64(stat.Rdev)))}
+
+	resolved, err := filepath.EvalSymlinks(device)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving device %s: %w", device, err)
+	}
+	name := filepath.Base(resolved)
+
+	entries, err := os.ReadDir(filepath.Join(sysClassBlock, name))
+	if err != nil {
+		// Not every block device is exposed in sysfs, e.g. in containers, so partitions are best-effort.
+		return numbers, nil
+	}
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), name) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(sysClassBlock, name, entry.Name(), partitionEntry)); err != nil {
+			continue
+		}
+
+		dev, err := os.ReadFile(filepath.Join(sysClassBlock, name, entry.Name(), "dev"))
+		if err != nil {
+			continue
+		}
+		numbers = append(numbers, strings.TrimSpace(string(dev)))
+	}
+	return numbers, nil
+}
+
+// checkNotMounted returns an error if the device or one of its partitions is mounted on the host.
+func checkNotMounted(device string) error {
+	numbers, err := deviceNumbers(device)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(mountInfoFile)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %w", mountInfoFile, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// Format: mount ID, parent ID, major:minor, root, mount point, ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+
+		for _, number := range numbers {
+			if fields[2] == number {
+				return fmt.Errorf("device %s (%s) is mounted at %s", device, number, fields[4])
+			}
+		}
+	}
+	return scanner.Err()
+}
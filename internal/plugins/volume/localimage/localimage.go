@@ -0,0 +1,296 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package localimage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/oci"
+	"github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
+	"github.com/ironcore-dev/libvirt-provider/internal/qcow2"
+	"github.com/ironcore-dev/libvirt-provider/internal/raw"
+	utilstrings "k8s.io/utils/strings"
+)
+
+const (
+	pluginName = "libvirt-provider.ironcore.dev/local-image"
+
+	localImageDriverName = "local-image"
+
+	volumeAttributeImageKey = "image"
+	volumeAttributeSizeKey  = "size"
+
+	basesDir     = "bases"
+	baseFile     = "base.raw"
+	refsDir      = "refs"
+	overlayFile  = "disk.qcow2"
+	baseRefFile  = "base"
+	refSeparator = "_"
+
+	perm     = 0777
+	filePerm = 0666
+)
+
+type plugin struct {
+	host       volume.Host
+	qcow2      qcow2.QCow2
+	raw        raw.Raw
+	imageCache oci.Cache
+
+	// mu guards the creation and removal of the shared base images.
+	mu sync.Mutex
+}
+
+type volumeData struct {
+	image  string
+	handle string
+	size   int64
+}
+
+// NewPlugin creates a volume plugin that provides per-machine qcow2 overlays on top of
+// a shared base disk created once from the rootfs layer of an OCI image.
+func NewPlugin(qcow2 qcow2.QCow2, raw raw.Raw, imageCache oci.Cache) volume.Plugin {
+	return &plugin{
+		qcow2:      qcow2,
+		raw:        raw,
+		imageCache: imageCache,
+	}
+}
+
+func (p *plugin) Init(host volume.Host) error {
+	p.host = host
+	return os.MkdirAll(p.basesDir(), perm)
+}
+
+func (p *plugin) Name() string {
+	return pluginName
+}
+
+func (p *plugin) GetBackingVolumeID(spec *api.VolumeSpec, _ string) (string, error) {
+	vData, err := p.getVolumeData(spec)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s^%s", pluginName, vData.handle), nil
+}
+
+func (p *plugin) CanSupport(spec *api.VolumeSpec) bool {
+	connection := spec.Connection
+	if connection == nil {
+		return false
+	}
+
+	return connection.Driver == localImageDriverName
+}
+
+func (p *plugin) getVolumeData(spec *api.VolumeSpec) (*volumeData, error) {
+	connection := spec.Connection
+	if connection == nil {
+		return nil, fmt.Errorf("volume does not specify connection")
+	}
+	if connection.Driver != localImageDriverName {
+		return nil, fmt.Errorf("volume connection specifies invalid driver %q", connection.Driver)
+	}
+	if connection.Handle == "" {
+		return nil, fmt.Errorf("volume connection does not specify handle")
+	}
+
+	image, ok := connection.Attributes[volumeAttributeImageKey]
+	if !ok || image == "" {
+		return nil, fmt.Errorf("no image data at %s", volumeAttributeImageKey)
+	}
+
+	var size int64
+	if sizeString, ok := connection.Attributes[volumeAttributeSizeKey]; ok && sizeString != "" {
+		var err error
+		size, err = strconv.ParseInt(sizeString, 10, 64)
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("invalid size at %s: %q", volumeAttributeSizeKey, sizeString)
+		}
+	}
+
+	return &volumeData{
+		image:  image,
+		handle: connection.Handle,
+		size:   size,
+	}, nil
+}
+
+func (p *plugin) basesDir() string {
+	return filepath.Join(p.host.PluginDir(utilstrings.EscapeQualifiedName(pluginName)), basesDir)
+}
+
+func (p *plugin) baseDir(digest string) string {
+	return filepath.Join(p.basesDir(), digest)
+}
+
+func (p *plugin) volumeDir(computeVolumeName, machineID string) string {
+	return p.host.MachineVolumeDir(machineID, utilstrings.EscapeQualifiedName(pluginName), computeVolumeName)
+}
+
+func refName(computeVolumeName, machineID string) string {
+	return machineID + refSeparator + computeVolumeName
+}
+
+func (p *plugin) Apply(ctx context.Context, spec *api.VolumeSpec, machine *api.Machine) (*volume.Volume, error) {
+	vData, err := p.getVolumeData(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get volume data: %w", err)
+	}
+
+	volumeDir := p.volumeDir(spec.Name, machine.ID)
+	if err := os.MkdirAll(volumeDir, perm); err != nil {
+		return nil, err
+	}
+
+	overlayFilename := filepath.Join(volumeDir, overlayFile)
+	if _, err := os.Stat(overlayFilename); err == nil {
+		return overlayVolume(overlayFilename, spec, vData)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("error stat-ing overlay: %w", err)
+	}
+
+	img, err := p.imageCache.Get(ctx, vData.image)
+	if err != nil {
+		return nil, fmt.Errorf("error getting image %s: %w", vData.image, err)
+	}
+
+	digest := img.RootFS.Descriptor.Digest.Encoded()
+	baseFilename, err := p.acquireBase(digest, img.RootFS.Path, refName(spec.Name, machine.ID))
+	if err != nil {
+		return nil, fmt.Errorf("error acquiring base image: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(volumeDir, baseRefFile), []byte(digest), filePerm); err != nil {
+		return nil, fmt.Errorf("error writing base reference: %w", err)
+	}
+
+	opts := []qcow2.CreateOption{qcow2.WithSourceFile(baseFilename)}
+	if vData.size > 0 {
+		opts = append(opts, qcow2.WithSize(vData.size))
+	}
+	if err := p.qcow2.Create(overlayFilename, opts...); err != nil {
+		return nil, fmt.Errorf("error creating overlay: %w", err)
+	}
+	if err := os.Chmod(overlayFilename, filePerm); err != nil {
+		return nil, fmt.Errorf("error changing overlay file mode: %w", err)
+	}
+
+	return overlayVolume(overlayFilename, spec, vData)
+}
+
+// overlayVolume returns the volume of the overlay. Discards are passed through, so the overlay shrinks again
+// once the guest frees space.
+func overlayVolume(overlayFilename string, spec *api.VolumeSpec, vData *volumeData) (*volume.Volume, error) {
+	vol := &volume.Volume{
+		QCow2File:    overlayFilename,
+		Handle:       vData.handle,
+		Size:         vData.size,
+		Discard:      volume.DiscardUnmap,
+		DetectZeroes: volume.DetectZeroesUnmap,
+	}
+	if err := (volume.DriverModes{}).Apply(vol, spec); err != nil {
+		return nil, err
+	}
+	return vol, nil
+}
+
+// acquireBase ensures the base disk for the given digest exists and records a reference for the given volume.
+func (p *plugin) acquireBase(digest, sourceFile, ref string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	baseDir := p.baseDir(digest)
+	if err := os.MkdirAll(filepath.Join(baseDir, refsDir), perm); err != nil {
+		return "", err
+	}
+
+	baseFilename := filepath.Join(baseDir, baseFile)
+	if _, err := os.Stat(baseFilename); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("error stat-ing base disk: %w", err)
+		}
+
+		tmpFilename := baseFilename + ".tmp"
+		if err := p.raw.Create(tmpFilename, raw.WithSourceFile(sourceFile)); err != nil {
+			return "", fmt.Errorf("error creating base disk: %w", err)
+		}
+		if err := os.Chmod(tmpFilename, filePerm); err != nil {
+			return "", fmt.Errorf("error changing base disk mode: %w", err)
+		}
+		if err := os.Rename(tmpFilename, baseFilename); err != nil {
+			return "", fmt.Errorf("error moving base disk into place: %w", err)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(baseDir, refsDir, ref), nil, filePerm); err != nil {
+		return "", fmt.Errorf("error writing base disk reference: %w", err)
+	}
+	return baseFilename, nil
+}
+
+// releaseBase removes the reference of the given volume and deletes the base disk once it is no longer referenced.
+func (p *plugin) releaseBase(digest, ref string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	baseDir := p.baseDir(digest)
+	if err := os.Remove(filepath.Join(baseDir, refsDir, ref)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error removing base disk reference: %w", err)
+	}
+
+	refs, err := os.ReadDir(filepath.Join(baseDir, refsDir))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("error reading base disk references: %w", err)
+	}
+	if len(refs) > 0 {
+		return nil
+	}
+
+	return os.RemoveAll(baseDir)
+}
+
+func (p *plugin) Delete(ctx context.Context, computeVolumeName string, machineID string) error {
+	volumeDir := p.volumeDir(computeVolumeName, machineID)
+
+	digest, err := os.ReadFile(filepath.Join(volumeDir, baseRefFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error reading base reference: %w", err)
+	}
+
+	// The base is released first, so its reference isn't leaked if removing the volume fails. Releasing it again
+	// on retry is a no-op.
+	if d := strings.TrimSpace(string(digest)); d != "" {
+		if err := p.releaseBase(d, refName(computeVolumeName, machineID)); err != nil {
+			return err
+		}
+	}
+
+	return os.RemoveAll(volumeDir)
+}
+
+func (p *plugin) GetSize(ctx context.Context, spec *api.VolumeSpec) (int64, error) {
+	vData, err := p.getVolumeData(spec)
+	if err != nil {
+		return 0, err
+	}
+	return vData.size, nil
+}
+
+func (p *plugin) HealthCheck(ctx context.Context) error {
+	// Images are pulled per volume, a failing pull only affects its volume.
+	return nil
+}
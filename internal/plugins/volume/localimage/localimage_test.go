@@ -0,0 +1,108 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package localimage_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	providerhost "github.com/ironcore-dev/libvirt-provider/internal/host"
+	"github.com/ironcore-dev/libvirt-provider/internal/oci"
+	"github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
+	"github.com/ironcore-dev/libvirt-provider/internal/plugins/volume/localimage"
+	"github.com/ironcore-dev/libvirt-provider/internal/qcow2"
+	"github.com/ironcore-dev/libvirt-provider/internal/raw"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/opencontainers/go-digest"
+	ocispecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// fakeImageCache returns the image with the root fs at rootFS for every ref.
+type fakeImageCache struct {
+	rootFS string
+}
+
+func (c *fakeImageCache) Get(context.Context, string) (*oci.Image, error) {
+	return &oci.Image{RootFS: &oci.FileLayer{
+		Descriptor: ocispecv1.Descriptor{Digest: digest.FromString("rootfs")},
+		Path:       c.rootFS,
+	}}, nil
+}
+
+func (c *fakeImageCache) AddListener(oci.Listener) {}
+
+// fakeQCow2 creates empty overlay files.
+type fakeQCow2 struct{}
+
+func (fakeQCow2) Create(filename string, _ ...qcow2.CreateOption) error {
+	return os.WriteFile(filename, nil, 0666)
+}
+
+// fakeRaw creates empty base disks.
+type fakeRaw struct{}
+
+func (fakeRaw) Create(filename string, _ ...raw.CreateOption) error {
+	return os.WriteFile(filename, nil, 0666)
+}
+
+var _ = Describe("Local image volume plugin", func() {
+	var (
+		paths  providerhost.Paths
+		plugin volume.Plugin
+	)
+
+	BeforeEach(func() {
+		var err error
+		paths, err = providerhost.PathsAt(GinkgoT().TempDir())
+		Expect(err).NotTo(HaveOccurred())
+
+		plugin = localimage.NewPlugin(fakeQCow2{}, fakeRaw{}, &fakeImageCache{rootFS: filepath.Join(GinkgoT().TempDir(), "rootfs")})
+		Expect(plugin.Init(paths)).To(Succeed())
+	})
+
+	spec := &api.VolumeSpec{Name: "root", Connection: &api.VolumeConnection{
+		Driver:     "local-image",
+		Handle:     "root",
+		Attributes: map[string]string{"image": "example.org/image:latest"},
+	}}
+
+	baseDir := func() string {
+		return filepath.Join(paths.PluginDir("libvirt-provider.ironcore.dev~local-image"), "bases", digest.FromString("rootfs").Encoded())
+	}
+
+	apply := func(ctx context.Context, machineID string) *volume.Volume {
+		vol, err := plugin.Apply(ctx, spec, &api.Machine{Metadata: api.Metadata{ID: machineID}})
+		Expect(err).NotTo(HaveOccurred())
+		return vol
+	}
+
+	It("should share the base disk until the last volume using it is deleted", func(ctx SpecContext) {
+		first := apply(ctx, "machine-1")
+		second := apply(ctx, "machine-2")
+		Expect(first.QCow2File).To(BeARegularFile())
+		Expect(second.QCow2File).To(BeARegularFile())
+		Expect(filepath.Join(baseDir(), "base.raw")).To(BeARegularFile())
+
+		Expect(plugin.Delete(ctx, "root", "machine-1")).To(Succeed())
+		Expect(filepath.Dir(first.QCow2File)).NotTo(BeADirectory())
+		Expect(filepath.Join(baseDir(), "base.raw")).To(BeARegularFile())
+
+		Expect(plugin.Delete(ctx, "root", "machine-2")).To(Succeed())
+		Expect(filepath.Dir(second.QCow2File)).NotTo(BeADirectory())
+		Expect(baseDir()).NotTo(BeADirectory())
+	})
+
+	It("should delete volumes whose base was released before", func(ctx SpecContext) {
+		apply(ctx, "machine-1")
+		apply(ctx, "machine-2")
+		Expect(os.RemoveAll(filepath.Join(baseDir(), "refs", "machine-1_root"))).To(Succeed())
+
+		Expect(plugin.Delete(ctx, "root", "machine-1")).To(Succeed())
+		Expect(plugin.Delete(ctx, "root", "machine-1")).To(Succeed())
+		Expect(filepath.Join(baseDir(), "refs", "machine-2_root")).To(BeARegularFile())
+	})
+})
@@ -0,0 +1,148 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package lvm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
+	utilstrings "k8s.io/utils/strings"
+)
+
+const (
+	pluginName = "libvirt-provider.ironcore.dev/lvm"
+
+	defaultSize = 500 * 1024 * 1024 // 500Mi by default
+
+	logicalVolumePrefix = "lvp-"
+
+	perm = 0777
+)
+
+// driverModes bypass the host page cache, the guest caches the logical volume itself.
+var driverModes = volume.DriverModes{Cache: volume.CacheModeNone, IO: volume.IOModeNative}
+
+type plugin struct {
+	host        volume.Host
+	volumeGroup string
+	thinPool    string
+}
+
+// NewPlugin creates a volume plugin that provisions empty disks as thin logical volumes
+// from the given thin pool.
+func NewPlugin(volumeGroup, thinPool string) volume.Plugin {
+	return &plugin{
+		volumeGroup: volumeGroup,
+		thinPool:    thinPool,
+	}
+}
+
+func (p *plugin) Init(host volume.Host) error {
+	if p.volumeGroup == "" {
+		return fmt.Errorf("must specify volume group")
+	}
+	if p.thinPool == "" {
+		return fmt.Errorf("must specify thin pool")
+	}
+
+	p.host = host
+	return nil
+}
+
+func (p *plugin) Name() string {
+	return pluginName
+}
+
+func (p *plugin) GetBackingVolumeID(volume *api.VolumeSpec, machineID string) (string, error) {
+	if volume.EmptyDisk == nil {
+		return "", fmt.Errorf("volume does not specify an EmptyDisk")
+	}
+	return fmt.Sprintf("%s/%s", machineID, volume.Name), nil
+}
+
+func (p *plugin) CanSupport(volume *api.VolumeSpec) bool {
+	return volume.EmptyDisk != nil
+}
+
+// logicalVolumeName returns a stable, LVM compatible name for the volume of the machine.
+func logicalVolumeName(computeVolumeName string, machineID string) string {
+	sum := sha256.Sum256([]byte(computeVolumeName))
+	return logicalVolumePrefix + machineID + "-" + hex.EncodeToString(sum[:])[:16]
+}
+
+func (p *plugin) devicePath(name string) string {
+	return filepath.Join("/dev", p.volumeGroup, name)
+}
+
+func (p *plugin) Apply(ctx context.Context, spec *api.VolumeSpec, machine *api.Machine) (*volume.Volume, error) {
+	volumeDir := p.host.MachineVolumeDir(machine.ID, utilstrings.EscapeQualifiedName(pluginName), spec.Name)
+	if err := os.MkdirAll(volumeDir, perm); err != nil {
+		return nil, err
+	}
+
+	size := spec.EmptyDisk.Size
+	if size == 0 {
+		size = defaultSize
+	}
+
+	name := logicalVolumeName(spec.Name, machine.ID)
+	currentSize, err := logicalVolumeSize(ctx, p.volumeGroup, name)
+	switch {
+	case errors.Is(err, errLogicalVolumeNotFound):
+		if err := createThinVolume(ctx, p.volumeGroup, p.thinPool, name, size); err != nil {
+			return nil, fmt.Errorf("error creating logical volume: %w", err)
+		}
+	case err != nil:
+		return nil, fmt.Errorf("error getting logical volume size: %w", err)
+	case currentSize < size:
+		if err := extendVolume(ctx, p.volumeGroup, name, size); err != nil {
+			return nil, fmt.Errorf("error extending logical volume: %w", err)
+		}
+	}
+
+	sum := sha256.Sum256([]byte(name))
+	vol := &volume.Volume{
+		BlockDevice:  p.devicePath(name),
+		Handle:       hex.EncodeToString(sum[:8]),
+		Size:         size,
+		Discard:      volume.DiscardUnmap,
+		DetectZeroes: volume.DetectZeroesUnmap,
+	}
+	if err := driverModes.Apply(vol, spec); err != nil {
+		return nil, err
+	}
+	return vol, nil
+}
+
+func (p *plugin) Delete(ctx context.Context, computeVolumeName string, machineID string) error {
+	name := logicalVolumeName(computeVolumeName, machineID)
+	if _, err := logicalVolumeSize(ctx, p.volumeGroup, name); err != nil {
+		if !errors.Is(err, errLogicalVolumeNotFound) {
+			return fmt.Errorf("error getting logical volume: %w", err)
+		}
+	} else if err := removeVolume(ctx, p.volumeGroup, name); err != nil {
+		return fmt.Errorf("error removing logical volume: %w", err)
+	}
+
+	return os.RemoveAll(p.host.MachineVolumeDir(machineID, utilstrings.EscapeQualifiedName(pluginName), computeVolumeName))
+}
+
+func (p *plugin) GetSize(ctx context.Context, spec *api.VolumeSpec) (int64, error) {
+	size := spec.EmptyDisk.Size
+	if size == 0 {
+		size = defaultSize
+	}
+	return size, nil
+}
+
+func (p *plugin) HealthCheck(ctx context.Context) error {
+	return thinPoolExists(ctx, p.volumeGroup, p.thinPool)
+}
@@ -0,0 +1,107 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package lvm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+var errLogicalVolumeNotFound = errors.New("logical volume not found")
+
+func runLVM(ctx context.Context, name string, args ...string) (string, error) {
+	res, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("error running %s: %s, exit error %w", name, string(res), err)
+	}
+	return string(res), nil
+}
+
+// lvsReport is the JSON report of lvs.
+type lvsReport struct {
+	Report []struct {
+		LV []struct {
+			Name string `json:"lv_name"`
+			Size string `json:"lv_size"`
+		} `json:"lv"`
+	} `json:"report"`
+}
+
+// logicalVolumeSize returns the size of the logical volume in bytes. The logical volume is selected instead of
+// listed by name, so a missing logical volume results in an empty report instead of a failure of lvs.
+func logicalVolumeSize(ctx context.Context, volumeGroup, name string) (int64, error) {
+	cmd := exec.CommandContext(ctx, "lvs",
+		"--reportformat", "json",
+		"--units", "b",
+		"--nosuffix",
+		"-o", "lv_name,lv_size",
+		"--select", "lv_name="+name,
+		volumeGroup,
+	)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("error running lvs: %s, exit error %w", stderr.String(), err)
+	}
+
+	report := &lvsReport{}
+	if err := json.Unmarshal(out, report); err != nil {
+		return 0, fmt.Errorf("error parsing lvs report: %w", err)
+	}
+	for _, r := range report.Report {
+		for _, lv := range r.LV {
+			if lv.Name != name {
+				continue
+			}
+			size, err := strconv.ParseInt(lv.Size, 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("error parsing logical volume size %q: %w", lv.Size, err)
+			}
+			return size, nil
+		}
+	}
+	return 0, errLogicalVolumeNotFound
+}
+
+func createThinVolume(ctx context.Context, volumeGroup, thinPool, name string, size int64) error {
+	_, err := runLVM(ctx, "lvcreate",
+		"--yes",
+		"--virtualsize", strconv.FormatInt(size, 10)+"b",
+		"--thinpool", volumeGroup+"/"+thinPool,
+		"--name", name,
+	)
+	return err
+}
+
+func extendVolume(ctx context.Context, volumeGroup, name string, size int64) error {
+	_, err := runLVM(ctx, "lvextend",
+		"--size", strconv.FormatInt(size, 10)+"b",
+		volumeGroup+"/"+name,
+	)
+	return err
+}
+
+func removeVolume(ctx context.Context, volumeGroup, name string) error {
+	_, err := runLVM(ctx, "lvremove",
+		"--yes",
+		volumeGroup+"/"+name,
+	)
+	return err
+}
+
+// thinPoolExists checks whether the thin pool of the volume group can be listed.
+func thinPoolExists(ctx context.Context, volumeGroup, thinPool string) error {
+	_, err := runLVM(ctx, "lvs",
+		"--noheadings",
+		"-o", "lv_name",
+		volumeGroup+"/"+thinPool,
+	)
+	return err
+}
@@ -0,0 +1,111 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package lvm_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	providerhost "github.com/ironcore-dev/libvirt-provider/internal/host"
+	"github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
+	"github.com/ironcore-dev/libvirt-provider/internal/plugins/volume/lvm"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fakeLVM records the invocations of the LVM commands. lvs prints the report file and fails if there is none.
+const fakeLVM = `#!/bin/sh
+echo "$(basename "$0") $*" >> "$FAKE_LVM_DIR/calls"
+[ "$(basename "$0")" = lvs ] || exit 0
+cat "$FAKE_LVM_DIR/report" || exit 5
+`
+
+var _ = Describe("LVM volume plugin", func() {
+	var (
+		dir    string
+		plugin volume.Plugin
+	)
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		for _, name := range []string{"lvs", "lvcreate", "lvextend", "lvremove"} {
+			Expect(os.WriteFile(filepath.Join(dir, name), []byte(fakeLVM), 0755)).To(Succeed())
+		}
+		GinkgoT().Setenv("FAKE_LVM_DIR", dir)
+		GinkgoT().Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+		paths, err := providerhost.PathsAt(GinkgoT().TempDir())
+		Expect(err).NotTo(HaveOccurred())
+		plugin = lvm.NewPlugin("vg", "pool")
+		Expect(plugin.Init(paths)).To(Succeed())
+	})
+
+	spec := &api.VolumeSpec{Name: "disk", EmptyDisk: &api.EmptyDiskSpec{Size: 1 << 30}}
+	machine := &api.Machine{Metadata: api.Metadata{ID: "foo"}}
+
+	// setLogicalVolumes makes lvs report the logical volumes with the given sizes.
+	setLogicalVolumes := func(sizes map[string]int64) {
+		var lvs []string
+		for name, size := range sizes {
+			lvs = append(lvs, fmt.Sprintf(`{"lv_name":%q, "lv_size":"%d"}`, name, size))
+		}
+		report := fmt.Sprintf(`{"report": [{"lv": [%s]}]}`, strings.Join(lvs, ","))
+		Expect(os.WriteFile(filepath.Join(dir, "report"), []byte(report), 0644)).To(Succeed())
+	}
+
+	// calls returns the invoked LVM commands other than lvs.
+	calls := func() []string {
+		data, err := os.ReadFile(filepath.Join(dir, "calls"))
+		Expect(err).NotTo(HaveOccurred())
+		var res []string
+		for _, call := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			if !strings.HasPrefix(call, "lvs ") {
+				res = append(res, strings.Fields(call)[0])
+			}
+		}
+		return res
+	}
+
+	It("should create a missing logical volume", func(ctx SpecContext) {
+		setLogicalVolumes(nil)
+
+		vol, err := plugin.Apply(ctx, spec, machine)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(vol.BlockDevice).To(HavePrefix("/dev/vg/lvp-foo-"))
+		Expect(calls()).To(Equal([]string{"lvcreate"}))
+	})
+
+	It("should extend a smaller logical volume", func(ctx SpecContext) {
+		By("creating the logical volume next to another one")
+		setLogicalVolumes(map[string]int64{"other": 1 << 30})
+		vol, err := plugin.Apply(ctx, spec, machine)
+		Expect(err).NotTo(HaveOccurred())
+
+		setLogicalVolumes(map[string]int64{filepath.Base(vol.BlockDevice): 1 << 20})
+		_, err = plugin.Apply(ctx, spec, machine)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(calls()).To(Equal([]string{"lvcreate", "lvextend"}))
+	})
+
+	It("should not create the logical volume if it can't be listed", func(ctx SpecContext) {
+		_, err := plugin.Apply(ctx, spec, machine)
+		Expect(err).To(MatchError(ContainSubstring("error running lvs")))
+		Expect(calls()).To(BeEmpty())
+	})
+
+	It("should only remove existing logical volumes", func(ctx SpecContext) {
+		setLogicalVolumes(nil)
+		Expect(plugin.Delete(ctx, "disk", "foo")).To(Succeed())
+		Expect(calls()).To(BeEmpty())
+
+		vol, err := plugin.Apply(ctx, spec, machine)
+		Expect(err).NotTo(HaveOccurred())
+		setLogicalVolumes(map[string]int64{filepath.Base(vol.BlockDevice): 1 << 30})
+		Expect(plugin.Delete(ctx, "disk", "foo")).To(Succeed())
+		Expect(calls()).To(Equal([]string{"lvcreate", "lvremove"}))
+	})
+})
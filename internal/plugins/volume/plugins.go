@@ -5,13 +5,34 @@ package volume
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/go-logr/logr"
 	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/prometheus/client_golang/prometheus"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
 )
 
+// ErrPluginUnhealthy is returned for plugins whose last health check failed.
+var ErrPluginUnhealthy = errors.New("volume plugin unhealthy")
+
+var pluginHealthy = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "libvirt_provider",
+	Name:      "volume_plugin_healthy",
+	Help:      "Whether the last health check of a volume plugin succeeded.",
+}, []string{"plugin"})
+
+func init() {
+	prometheus.MustRegister(pluginHealthy)
+}
+
 type Host interface {
 	PluginDir(pluginName string) string
 	MachinePluginDir(machineID string, pluginName string) string
@@ -21,28 +42,212 @@ type Host interface {
 type Plugin interface {
 	Init(host Host) error
 	Name() string
-	GetBackingVolumeID(spec *api.VolumeSpec) (string, error)
+	// GetBackingVolumeID returns the id of the volume backing the spec. Plugins creating volumes local to a machine
+	// have to include the machine id, so ids don't collide across machines.
+	GetBackingVolumeID(spec *api.VolumeSpec, machineID string) (string, error)
 	CanSupport(spec *api.VolumeSpec) bool
 
 	Apply(ctx context.Context, spec *api.VolumeSpec, machine *api.Machine) (*Volume, error)
 	Delete(ctx context.Context, computeVolumeName string, machineID string) error
 
 	GetSize(ctx context.Context, spec *api.VolumeSpec) (int64, error)
+
+	// HealthCheck checks whether the backend of the plugin is usable, e.g. whether its storage cluster is
+	// reachable. Volumes of unhealthy plugins aren't attached.
+	HealthCheck(ctx context.Context) error
+}
+
+// BackingVolumeResolver is implemented by plugins whose volumes can reach the same backing volume through different
+// connection handles. The resolved id identifies the backing volume itself, e.g. the ceph image, so volumes sharing
+// it are detected. Unlike the backing volume id, it isn't used to track the mounted volumes.
+type BackingVolumeResolver interface {
+	ResolveBackingVolume(spec *api.VolumeSpec) (string, error)
+}
+
+// VolumeHealthChecker is implemented by plugins whose volumes are backed by independent backends, e.g. several
+// storage clusters. Only the volumes of an unhealthy backend are held back, not all volumes of the plugin.
+type VolumeHealthChecker interface {
+	// VolumeHealth returns the error of the last health check of the backend of the volume. Backends that weren't
+	// checked yet are considered healthy.
+	VolumeHealth(spec *api.VolumeSpec) error
 }
 
 type Volume struct {
-	QCow2File string
-	RawFile   string
-	CephDisk  *CephDisk
-	Handle    string
-	Size      int64
+	QCow2File   string
+	RawFile     string
+	BlockDevice string
+	CephDisk    *CephDisk
+	Handle      string
+	Size        int64
+
+	// Cache and IO are the libvirt disk driver cache and io modes. If empty, the hypervisor defaults apply.
+	Cache string
+	IO    string
+
+	// Discard and DetectZeroes optionally set the libvirt disk driver discard and detect_zeroes modes, so
+	// thin-provisioned backends can reclaim space freed by the guest.
+	Discard      string
+	DetectZeroes string
+
+	// Shareable marks the disk as shared with other domains.
+	Shareable bool
+}
+
+const (
+	// VolumeAttributeCacheKey is the connection attribute overriding the cache mode of a volume.
+	VolumeAttributeCacheKey = "cache"
+	// VolumeAttributeIOKey is the connection attribute overriding the io mode of a volume.
+	VolumeAttributeIOKey = "io"
+
+	// VolumeAttributeDiscardKey is the connection attribute overriding the discard mode of a volume.
+	VolumeAttributeDiscardKey = "discard"
+	// VolumeAttributeDetectZeroesKey is the connection attribute overriding the detect_zeroes mode of a volume.
+	VolumeAttributeDetectZeroesKey = "detect_zeroes"
+
+	// VolumeAttributeShareableKey is the connection attribute allowing the backing volume to be attached more
+	// than once.
+	VolumeAttributeShareableKey = "shareable"
+
+	// CacheModeNone bypasses the host page cache. Shareable volumes always use it, so the writes of one domain are
+	// visible to the others.
+	CacheModeNone = "none"
+	// CacheModeDirectSync bypasses the host page cache and completes writes once they are on the backing storage.
+	CacheModeDirectSync = "directsync"
+
+	// IOModeNative uses the Linux native AIO. It requires the host page cache to be bypassed.
+	IOModeNative = "native"
+	// IOModeThreads uses a thread pool, it works with every cache mode.
+	IOModeThreads = "threads"
+
+	// DiscardUnmap passes discard requests of the guest through to the backing storage.
+	DiscardUnmap = "unmap"
+	// DetectZeroesUnmap converts writes of zeroes into discards if discard requests are passed through.
+	DetectZeroesUnmap = "unmap"
+)
+
+var (
+	SupportedCacheModes = []string{CacheModeNone, "writeback", "writethrough", CacheModeDirectSync, "unsafe"}
+	SupportedIOModes    = []string{IOModeNative, IOModeThreads, "io_uring"}
+
+	// directCacheModes are the cache modes bypassing the host page cache.
+	directCacheModes = []string{CacheModeNone, CacheModeDirectSync}
+
+	supportedDiscardModes      = []string{"ignore", DiscardUnmap}
+	supportedDetectZeroesModes = []string{"off", "on", DetectZeroesUnmap}
+)
+
+// ReadDiscardAttributes reads the optional discard and detect_zeroes modes from the connection attributes of a
+// volume.
+func ReadDiscardAttributes(attrs map[string]string) (discard, detectZeroes string, err error) {
+	discard = attrs[VolumeAttributeDiscardKey]
+	if discard != "" && !slices.Contains(supportedDiscardModes, discard) {
+		return "", "", fmt.Errorf("unsupported discard mode %q, supported: %v", discard, supportedDiscardModes)
+	}
+
+	detectZeroes = attrs[VolumeAttributeDetectZeroesKey]
+	if detectZeroes != "" && !slices.Contains(supportedDetectZeroesModes, detectZeroes) {
+		return "", "", fmt.Errorf("unsupported detect_zeroes mode %q, supported: %v", detectZeroes, supportedDetectZeroesModes)
+	}
+	return discard, detectZeroes, nil
+}
+
+// ReadShareableAttribute reads the optional shareable flag from the connection attributes of a volume.
+func ReadShareableAttribute(attrs map[string]string) (bool, error) {
+	shareable, ok := attrs[VolumeAttributeShareableKey]
+	if !ok {
+		return false, nil
+	}
+
+	res, err := strconv.ParseBool(shareable)
+	if err != nil {
+		return false, fmt.Errorf("invalid shareable flag %q: %w", shareable, err)
+	}
+	return res, nil
+}
+
+// ReadDriverAttributes reads the optional cache and io modes from the connection attributes of a volume. It
+// returns nil if none is set.
+func ReadDriverAttributes(attrs map[string]string) (*api.VolumeDriverSpec, error) {
+	cache, io := attrs[VolumeAttributeCacheKey], attrs[VolumeAttributeIOKey]
+	if cache == "" && io == "" {
+		return nil, nil
+	}
+
+	driver := &api.VolumeDriverSpec{Cache: cache, IO: io}
+	if err := ValidateDriverModes(driver.Cache, driver.IO); err != nil {
+		return nil, err
+	}
+	return driver, nil
+}
+
+// ValidateDriverModes validates the cache and io modes, empty modes are valid. Native io is only valid with a cache
+// mode bypassing the host page cache, an empty cache mode is validated once the volume's cache mode is known.
+func ValidateDriverModes(cache, io string) error {
+	if cache != "" && !slices.Contains(SupportedCacheModes, cache) {
+		return fmt.Errorf("unsupported cache mode %q, supported: %v", cache, SupportedCacheModes)
+	}
+	if io != "" && !slices.Contains(SupportedIOModes, io) {
+		return fmt.Errorf("unsupported io mode %q, supported: %v", io, SupportedIOModes)
+	}
+	if io == IOModeNative && cache != "" && !slices.Contains(directCacheModes, cache) {
+		return fmt.Errorf("io mode %s requires one of the cache modes %v, got %q", io, directCacheModes, cache)
+	}
+	return nil
+}
+
+// DriverModes are the disk driver modes a plugin defaults to.
+type DriverModes struct {
+	Cache string
+	IO    string
+}
+
+// Apply sets the driver modes of the spec to the volume, falling back to the defaults for modes the spec
+// doesn't set. Shareable volumes aren't cached. A default native io mode falls back to threads if the volume is
+// cached, native io set by the spec of a cached volume is rejected.
+func (d DriverModes) Apply(vol *Volume, spec *api.VolumeSpec) error {
+	vol.Cache, vol.IO = d.Cache, d.IO
+	var specIO bool
+	if driver := spec.Driver; driver != nil {
+		if driver.Cache != "" {
+			vol.Cache = driver.Cache
+		}
+		if driver.IO != "" {
+			vol.IO = driver.IO
+			specIO = true
+		}
+	}
+	if spec.Shareable {
+		vol.Cache = CacheModeNone
+	}
+
+	if vol.IO == IOModeNative && !slices.Contains(directCacheModes, vol.Cache) {
+		if specIO {
+			return fmt.Errorf("io mode %s requires one of the cache modes %v, got %q", vol.IO, directCacheModes, vol.Cache)
+		}
+		vol.IO = IOModeThreads
+	}
+	return nil
 }
 
 type CephDisk struct {
-	Name       string
+	// Name is the name of the image, pool/image.
+	Name string
+	// Namespace is the RBD namespace of the image in its pool, empty for the default namespace.
+	Namespace  string
 	Monitors   []CephMonitor
 	Auth       *CephAuthentication
 	Encryption *CephEncryption
+	// ConfigFile optionally is a ceph configuration file with librbd options of the disk.
+	ConfigFile string
+}
+
+// SourceName returns the name of the image in the rbd disk source, pool/image or pool/namespace/image.
+func (d *CephDisk) SourceName() string {
+	pool, image, ok := strings.Cut(d.Name, "/")
+	if d.Namespace == "" || !ok {
+		return d.Name
+	}
+	return pool + "/" + d.Namespace + "/" + image
 }
 
 type CephAuthentication struct {
@@ -61,12 +266,19 @@ type CephMonitor struct {
 
 type PluginManager struct {
 	mu      sync.RWMutex
+	host    Host
 	plugins map[string]Plugin
+	// health are the errors of the last health check of the unhealthy plugins by name.
+	health map[string]error
+
+	// sharedMu guards the references of shareable backing volumes.
+	sharedMu sync.Mutex
 }
 
 func NewPluginManager() *PluginManager {
 	return &PluginManager{
 		plugins: make(map[string]Plugin),
+		health:  make(map[string]error),
 	}
 }
 
@@ -74,6 +286,7 @@ func (m *PluginManager) InitPlugins(host Host, plugins []Plugin) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	m.host = host
 	var initErrs []error
 	for _, plugin := range plugins {
 		name := plugin.Name()
@@ -96,6 +309,19 @@ func (m *PluginManager) InitPlugins(host Host, plugins []Plugin) error {
 	return nil
 }
 
+// PluginNames returns the names of the initialized plugins in ascending order.
+func (m *PluginManager) PluginNames() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	names := make([]string, 0, len(m.plugins))
+	for name := range m.plugins {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
 func (m *PluginManager) FindPluginByName(name string) (Plugin, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -131,3 +357,74 @@ func (m *PluginManager) FindPluginBySpec(volume *api.VolumeSpec) (Plugin, error)
 		return nil, fmt.Errorf("multiple plugins matching for volume: %v", matchingNames.List())
 	}
 }
+
+// StartHealthChecks checks the health of the plugins every interval until ctx is done. Each health check is bounded
+// by timeout.
+func (m *PluginManager) StartHealthChecks(ctx context.Context, log logr.Logger, interval, timeout time.Duration) {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		m.CheckHealth(ctx, log, timeout)
+	}, interval)
+}
+
+// CheckHealth checks the health of all plugins and records the results.
+func (m *PluginManager) CheckHealth(ctx context.Context, log logr.Logger, timeout time.Duration) {
+	m.mu.RLock()
+	plugins := make([]Plugin, 0, len(m.plugins))
+	for _, plugin := range m.plugins {
+		plugins = append(plugins, plugin)
+	}
+	m.mu.RUnlock()
+
+	for _, plugin := range plugins {
+		name := plugin.Name()
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := plugin.HealthCheck(checkCtx)
+		cancel()
+
+		m.mu.Lock()
+		prevErr, wasUnhealthy := m.health[name]
+		if err != nil {
+			m.health[name] = err
+		} else {
+			delete(m.health, name)
+		}
+		m.mu.Unlock()
+
+		switch {
+		case err != nil && (!wasUnhealthy || prevErr.Error() != err.Error()):
+			log.Error(err, "Volume plugin is unhealthy", "Plugin", name)
+		case err == nil && wasUnhealthy:
+			log.Info("Volume plugin is healthy again", "Plugin", name)
+		}
+		if err != nil {
+			pluginHealthy.WithLabelValues(name).Set(0)
+		} else {
+			pluginHealthy.WithLabelValues(name).Set(1)
+		}
+	}
+}
+
+// Health returns an error wrapping ErrPluginUnhealthy if the last health check of the plugin failed. Plugins that
+// weren't checked yet are considered healthy.
+func (m *PluginManager) Health(name string) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if err, ok := m.health[name]; ok {
+		return fmt.Errorf("%w: %s: %w", ErrPluginUnhealthy, name, err)
+	}
+	return nil
+}
+
+// VolumeHealth returns an error wrapping ErrPluginUnhealthy if the backend of the volume is unhealthy. For plugins
+// not implementing VolumeHealthChecker, this is the Health of the plugin.
+func (m *PluginManager) VolumeHealth(plugin Plugin, spec *api.VolumeSpec) error {
+	checker, ok := plugin.(VolumeHealthChecker)
+	if !ok {
+		return m.Health(plugin.Name())
+	}
+	if err := checker.VolumeHealth(spec); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrPluginUnhealthy, plugin.Name(), err)
+	}
+	return nil
+}
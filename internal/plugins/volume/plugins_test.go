@@ -0,0 +1,94 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package volume_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type fakePlugin struct {
+	name      string
+	healthErr error
+}
+
+func (p *fakePlugin) Init(volume.Host) error { return nil }
+func (p *fakePlugin) Name() string           { return p.name }
+func (p *fakePlugin) GetBackingVolumeID(spec *api.VolumeSpec, _ string) (string, error) {
+	return spec.Name, nil
+}
+func (p *fakePlugin) CanSupport(*api.VolumeSpec) bool { return true }
+func (p *fakePlugin) Apply(context.Context, *api.VolumeSpec, *api.Machine) (*volume.Volume, error) {
+	return &volume.Volume{}, nil
+}
+func (p *fakePlugin) Delete(context.Context, string, string) error { return nil }
+func (p *fakePlugin) GetSize(context.Context, *api.VolumeSpec) (int64, error) {
+	return 0, nil
+}
+func (p *fakePlugin) HealthCheck(context.Context) error { return p.healthErr }
+
+// fakeClusterPlugin reports the health of the volumes by their name.
+type fakeClusterPlugin struct {
+	fakePlugin
+	volumeErrs map[string]error
+}
+
+func (p *fakeClusterPlugin) VolumeHealth(spec *api.VolumeSpec) error { return p.volumeErrs[spec.Name] }
+
+var _ = Describe("PluginManager", func() {
+	It("should report plugins failing their health check as unhealthy", func(ctx SpecContext) {
+		ceph := &fakePlugin{name: "ceph"}
+		local := &fakePlugin{name: "local"}
+		manager := volume.NewPluginManager()
+		Expect(manager.InitPlugins(nil, []volume.Plugin{ceph, local})).To(Succeed())
+
+		By("considering unchecked plugins healthy")
+		Expect(manager.Health("ceph")).To(Succeed())
+
+		ceph.healthErr = errors.New("no monitor reachable")
+		manager.CheckHealth(ctx, logr.Discard(), time.Second)
+		Expect(manager.Health("ceph")).To(MatchError(volume.ErrPluginUnhealthy))
+		Expect(manager.Health("ceph")).To(MatchError(ContainSubstring("no monitor reachable")))
+		Expect(manager.Health("local")).To(Succeed())
+
+		By("recovering the plugin")
+		ceph.healthErr = nil
+		manager.CheckHealth(ctx, logr.Discard(), time.Second)
+		Expect(manager.Health("ceph")).To(Succeed())
+	})
+
+	It("should report the health of the backend of a volume", func(ctx SpecContext) {
+		ceph := &fakeClusterPlugin{
+			fakePlugin: fakePlugin{name: "ceph", healthErr: errors.New("cluster b down")},
+			volumeErrs: map[string]error{"b": errors.New("cluster b down")},
+		}
+		local := &fakePlugin{name: "local", healthErr: errors.New("disk full")}
+		manager := volume.NewPluginManager()
+		Expect(manager.InitPlugins(nil, []volume.Plugin{ceph, local})).To(Succeed())
+		manager.CheckHealth(ctx, logr.Discard(), time.Second)
+
+		Expect(manager.VolumeHealth(ceph, &api.VolumeSpec{Name: "a"})).To(Succeed())
+		Expect(manager.VolumeHealth(ceph, &api.VolumeSpec{Name: "b"})).To(MatchError(volume.ErrPluginUnhealthy))
+
+		By("falling back to the health of plugins without backends per volume")
+		Expect(manager.VolumeHealth(local, &api.VolumeSpec{Name: "a"})).To(MatchError(volume.ErrPluginUnhealthy))
+	})
+})
+
+var _ = Describe("CephDisk", func() {
+	It("should include the namespace in the source name", func() {
+		disk := &volume.CephDisk{Name: "pool/image"}
+		Expect(disk.SourceName()).To(Equal("pool/image"))
+
+		disk.Namespace = "tenant"
+		Expect(disk.SourceName()).To(Equal("pool/tenant/image"))
+	})
+})
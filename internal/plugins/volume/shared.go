@@ -0,0 +1,102 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package volume
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	utilstrings "k8s.io/utils/strings"
+)
+
+const (
+	// sharedVolumesDir is the directory in the plugin directory keeping the references of shareable backing
+	// volumes. It contains a directory per backing volume with a file per volume of a machine using it.
+	sharedVolumesDir = "shared-volumes"
+
+	perm     = 0777
+	filePerm = 0666
+)
+
+func (m *PluginManager) sharedVolumesDir(pluginName string) string {
+	return filepath.Join(m.host.PluginDir(utilstrings.EscapeQualifiedName(pluginName)), sharedVolumesDir)
+}
+
+func sharedVolumeRef(machineID, volumeName string) string {
+	return fmt.Sprintf("%s^%s", machineID, volumeName)
+}
+
+// RefSharedVolume records that the volume of the machine uses the shareable backing volume of the plugin. References
+// of the volume to other backing volumes are dropped.
+func (m *PluginManager) RefSharedVolume(pluginName, volumeID, machineID, volumeName string) error {
+	m.sharedMu.Lock()
+	defer m.sharedMu.Unlock()
+
+	backingVolumeDir := utilstrings.EscapeQualifiedName(volumeID)
+	if _, err := m.unrefSharedVolume(pluginName, machineID, volumeName, backingVolumeDir); err != nil {
+		return err
+	}
+
+	dir := filepath.Join(m.sharedVolumesDir(pluginName), backingVolumeDir)
+	if err := os.MkdirAll(dir, perm); err != nil {
+		return fmt.Errorf("error creating shared volume directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, sharedVolumeRef(machineID, volumeName)), nil, filePerm); err != nil {
+		return fmt.Errorf("error writing shared volume reference: %w", err)
+	}
+	return nil
+}
+
+// UnrefSharedVolume removes the reference of the volume of the machine to its shareable backing volume. It reports
+// whether other volumes still use the backing volume, in which case the backing volume must not be deleted.
+// Volumes without reference aren't shared, they aren't used elsewhere.
+func (m *PluginManager) UnrefSharedVolume(pluginName, machineID, volumeName string) (bool, error) {
+	m.sharedMu.Lock()
+	defer m.sharedMu.Unlock()
+
+	return m.unrefSharedVolume(pluginName, machineID, volumeName, "")
+}
+
+// unrefSharedVolume removes the references of the volume of the machine, except the one to the backing volume
+// directory keep.
+func (m *PluginManager) unrefSharedVolume(pluginName, machineID, volumeName, keep string) (bool, error) {
+	sharedDir := m.sharedVolumesDir(pluginName)
+	entries, err := os.ReadDir(sharedDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error reading shared volumes directory: %w", err)
+	}
+
+	var inUse bool
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == keep {
+			continue
+		}
+
+		dir := filepath.Join(sharedDir, entry.Name())
+		if err := os.Remove(filepath.Join(dir, sharedVolumeRef(machineID, volumeName))); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return false, fmt.Errorf("error removing shared volume reference: %w", err)
+		}
+
+		refs, err := os.ReadDir(dir)
+		if err != nil {
+			return false, fmt.Errorf("error reading shared volume directory: %w", err)
+		}
+		if len(refs) > 0 {
+			inUse = true
+			continue
+		}
+		if err := os.Remove(dir); err != nil {
+			return false, fmt.Errorf("error removing shared volume directory: %w", err)
+		}
+	}
+	return inUse, nil
+}
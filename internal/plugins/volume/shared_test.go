@@ -0,0 +1,59 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package volume_test
+
+import (
+	"path/filepath"
+
+	"github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type fakeHost struct {
+	dir string
+}
+
+func (h fakeHost) PluginDir(pluginName string) string {
+	return filepath.Join(h.dir, "plugins", pluginName)
+}
+func (h fakeHost) MachinePluginDir(machineID string, pluginName string) string {
+	return filepath.Join(h.dir, "machines", machineID, "plugins", pluginName)
+}
+func (h fakeHost) MachineVolumeDir(machineID string, pluginName, volumeName string) string {
+	return filepath.Join(h.dir, "machines", machineID, "volumes", pluginName, volumeName)
+}
+
+var _ = Describe("Shared volumes", func() {
+	var manager *volume.PluginManager
+
+	BeforeEach(func() {
+		manager = volume.NewPluginManager()
+		Expect(manager.InitPlugins(fakeHost{dir: GinkgoT().TempDir()}, []volume.Plugin{&fakePlugin{name: "ceph"}})).To(Succeed())
+	})
+
+	It("should keep backing volumes in use until the last volume is removed", func() {
+		Expect(manager.RefSharedVolume("ceph", "pool/quorum", "machine-1", "quorum")).To(Succeed())
+		Expect(manager.RefSharedVolume("ceph", "pool/quorum", "machine-2", "quorum")).To(Succeed())
+
+		By("removing the first volume")
+		Expect(manager.UnrefSharedVolume("ceph", "machine-1", "quorum")).To(BeTrue())
+
+		By("removing the last volume")
+		Expect(manager.UnrefSharedVolume("ceph", "machine-2", "quorum")).To(BeFalse())
+	})
+
+	It("should not consider volumes without reference in use", func() {
+		Expect(manager.UnrefSharedVolume("ceph", "machine-1", "data")).To(BeFalse())
+	})
+
+	It("should drop references to previous backing volumes", func() {
+		Expect(manager.RefSharedVolume("ceph", "pool/quorum", "machine-1", "quorum")).To(Succeed())
+		Expect(manager.RefSharedVolume("ceph", "pool/quorum", "machine-2", "quorum")).To(Succeed())
+		Expect(manager.RefSharedVolume("ceph", "pool/quorum-v2", "machine-2", "quorum")).To(Succeed())
+
+		Expect(manager.UnrefSharedVolume("ceph", "machine-1", "quorum")).To(BeFalse())
+		Expect(manager.UnrefSharedVolume("ceph", "machine-2", "quorum")).To(BeFalse())
+	})
+})
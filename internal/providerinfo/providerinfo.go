@@ -0,0 +1,159 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package providerinfo collects the runtime information of the provider for fleet inspection tooling.
+package providerinfo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/guest"
+	"github.com/ironcore-dev/libvirt-provider/internal/mcr"
+	providernetworkinterface "github.com/ironcore-dev/libvirt-provider/internal/plugins/networkinterface"
+	"github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
+	"github.com/ironcore-dev/libvirt-provider/internal/store"
+)
+
+// Info is the runtime information of the provider.
+type Info struct {
+	LibvirtVersion    string `json:"libvirtVersion"`
+	HypervisorVersion string `json:"hypervisorVersion"`
+
+	Guest GuestInfo `json:"guest"`
+
+	VolumePlugins          []string `json:"volumePlugins"`
+	NetworkInterfacePlugin string   `json:"networkInterfacePlugin"`
+	// NetworkInterfacePlugins are the plugins network interfaces can select, including the default
+	// NetworkInterfacePlugin.
+	NetworkInterfacePlugins []string `json:"networkInterfacePlugins"`
+
+	Resources ResourcesInfo `json:"resources"`
+
+	// Machines are the number of machines per state.
+	Machines map[api.MachineState]int `json:"machines"`
+
+	ImageCacheBytes int64 `json:"imageCacheBytes"`
+}
+
+// GuestInfo are the domain settings detected from the guest capabilities of the host.
+type GuestInfo struct {
+	Architecture string `json:"architecture"`
+	DomainType   string `json:"domainType"`
+	MachineType  string `json:"machineType"`
+}
+
+// ResourcesInfo describes the utilization of the resource pool available to machines, after subtracting
+// the system reserved resources and applying the overcommit ratios.
+type ResourcesInfo struct {
+	CPUMillis   ResourceInfo `json:"cpuMillis"`
+	MemoryBytes ResourceInfo `json:"memoryBytes"`
+}
+
+type ResourceInfo struct {
+	Total     int64 `json:"total"`
+	Allocated int64 `json:"allocated"`
+}
+
+type ImageCache interface {
+	Size(ctx context.Context) (int64, error)
+}
+
+// Libvirt reports the versions of the libvirt daemon and the hypervisor, see libvirt.Libvirt.
+type Libvirt interface {
+	ConnectGetLibVersion() (uint64, error)
+	ConnectGetVersion() (uint64, error)
+}
+
+type Options struct {
+	Libvirt                 Libvirt
+	GuestCapabilities       guest.Capabilities
+	MachineStore            store.Store[*api.Machine]
+	VolumePlugins           *volume.PluginManager
+	NetworkInterfacePlugins *providernetworkinterface.PluginManager
+	ImageCache              ImageCache
+
+	EnableHugepages bool
+	SystemReserved  *mcr.Host
+	Overcommit      mcr.OvercommitRatios
+}
+
+// Collector collects the Info of the provider.
+type Collector struct {
+	opts Options
+}
+
+func NewCollector(opts Options) *Collector {
+	return &Collector{opts: opts}
+}
+
+// formatVersion formats a version as encoded by libvirt, major * 1,000,000 + minor * 1,000 + release.
+func formatVersion(version uint64) string {
+	return fmt.Sprintf("%d.%d.%d", version/1000000, version/1000%1000, version%1000)
+}
+
+func (c *Collector) Collect(ctx context.Context) (*Info, error) {
+	info := &Info{
+		VolumePlugins: c.opts.VolumePlugins.PluginNames(),
+		Machines:      make(map[api.MachineState]int),
+	}
+	if c.opts.NetworkInterfacePlugins != nil {
+		info.NetworkInterfacePlugin = c.opts.NetworkInterfacePlugins.DefaultPlugin().Name()
+		info.NetworkInterfacePlugins = c.opts.NetworkInterfacePlugins.PluginNames()
+	}
+
+	libVersion, err := c.opts.Libvirt.ConnectGetLibVersion()
+	if err != nil {
+		return nil, fmt.Errorf("error getting libvirt version: %w", err)
+	}
+	info.LibvirtVersion = formatVersion(libVersion)
+
+	hvVersion, err := c.opts.Libvirt.ConnectGetVersion()
+	if err != nil {
+		return nil, fmt.Errorf("error getting hypervisor version: %w", err)
+	}
+	info.HypervisorVersion = formatVersion(hvVersion)
+
+	// Same requests as used for creating domains.
+	info.Guest.Architecture = c.opts.GuestCapabilities.HostArchitecture()
+	settings, err := c.opts.GuestCapabilities.SettingsFor(guest.Requests{
+		Architecture: info.Guest.Architecture,
+		OSType:       guest.OSTypeHVM,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting guest settings: %w", err)
+	}
+	info.Guest.DomainType = settings.Type
+	info.Guest.MachineType = settings.Machine
+
+	host, err := mcr.GetResources(ctx, c.opts.EnableHugepages)
+	if err != nil {
+		return nil, fmt.Errorf("error getting host resources: %w", err)
+	}
+	host = host.Subtract(c.opts.SystemReserved).Overcommit(c.opts.Overcommit)
+	info.Resources.CPUMillis.Total = host.Cpu.Value()
+	info.Resources.MemoryBytes.Total = host.Mem.Value()
+
+	machines, err := c.opts.MachineStore.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing machines: %w", err)
+	}
+	for _, machine := range machines {
+		state := machine.Status.State
+		if state == "" {
+			state = api.MachineStatePending
+		}
+		info.Machines[state]++
+		if machine.DeletedAt == nil {
+			info.Resources.CPUMillis.Allocated += machine.Spec.CpuMillis
+			info.Resources.MemoryBytes.Allocated += machine.Spec.MemoryBytes
+		}
+	}
+
+	if info.ImageCacheBytes, err = c.opts.ImageCache.Size(ctx); err != nil {
+		return nil, fmt.Errorf("error getting image cache size: %w", err)
+	}
+
+	return info, nil
+}
@@ -0,0 +1,103 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package providerinfo_test
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/host"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/guest"
+	"github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
+	. "github.com/ironcore-dev/libvirt-provider/internal/providerinfo"
+	"github.com/ironcore-dev/libvirt-provider/pkg/pluginapi"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+)
+
+type fakeLibvirt struct{}
+
+func (fakeLibvirt) ConnectGetLibVersion() (uint64, error) { return 10_000_000, nil }
+func (fakeLibvirt) ConnectGetVersion() (uint64, error)    { return 8_002_001, nil }
+
+// fakeCapabilities supports the kvm domain type and the virt machine type for the aarch64 architecture only.
+type fakeCapabilities struct{}
+
+func (fakeCapabilities) HostArchitecture() string                        { return "aarch64" }
+func (fakeCapabilities) SupportsMachineType(guest.Requests, string) bool { return true }
+
+func (fakeCapabilities) SettingsFor(reqs guest.Requests) (*guest.Settings, error) {
+	Expect(reqs.Architecture).To(Equal("aarch64"))
+	return &guest.Settings{Type: "kvm", Machine: "virt"}, nil
+}
+
+type fakeImageCache struct{}
+
+func (fakeImageCache) Size(context.Context) (int64, error) { return 1024, nil }
+
+var _ = Describe("ProviderInfo", func() {
+	var collector *Collector
+
+	BeforeEach(func(ctx SpecContext) {
+		machines, err := host.NewStore[*api.Machine](host.Options[*api.Machine]{
+			Dir:     GinkgoT().TempDir(),
+			NewFunc: func() *api.Machine { return &api.Machine{} },
+		})
+		Expect(err).NotTo(HaveOccurred())
+		for _, id := range []string{"foo", "bar"} {
+			_, err := machines.Create(ctx, &api.Machine{
+				Metadata: api.Metadata{ID: id},
+				Spec:     api.MachineSpec{CpuMillis: 2000, MemoryBytes: 1 << 30},
+			})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		collector = NewCollector(Options{
+			Libvirt:           fakeLibvirt{},
+			GuestCapabilities: fakeCapabilities{},
+			MachineStore:      machines,
+			VolumePlugins:     volume.NewPluginManager(),
+			ImageCache:        fakeImageCache{},
+		})
+	})
+
+	It("should collect the info of the provider", func(ctx SpecContext) {
+		info, err := collector.Collect(ctx)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(info.LibvirtVersion).To(Equal("10.0.0"))
+		Expect(info.HypervisorVersion).To(Equal("8.2.1"))
+		Expect(info.Guest).To(Equal(GuestInfo{Architecture: "aarch64", DomainType: "kvm", MachineType: "virt"}))
+		Expect(info.Machines).To(Equal(map[api.MachineState]int{api.MachineStatePending: 2}))
+		Expect(info.Resources.CPUMillis.Allocated).To(Equal(int64(4000)))
+		Expect(info.Resources.MemoryBytes.Allocated).To(Equal(int64(2 << 30)))
+		Expect(info.ImageCacheBytes).To(Equal(int64(1024)))
+	})
+
+	It("should serve the info", func(ctx SpecContext) {
+		socket := filepath.Join(GinkgoT().TempDir(), "provider.sock")
+		l, err := net.Listen("unix", socket)
+		Expect(err).NotTo(HaveOccurred())
+
+		srv := grpc.NewServer(pluginapi.ServerOption())
+		RegisterInfoServer(srv, collector)
+		go func() {
+			defer GinkgoRecover()
+			Expect(srv.Serve(l)).To(Succeed())
+		}()
+		DeferCleanup(srv.Stop)
+
+		conn, err := pluginapi.Dial(socket)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(conn.Close)
+
+		info, err := NewClient(conn).GetInfo(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Guest.Architecture).To(Equal("aarch64"))
+		Expect(info.Machines).To(HaveKeyWithValue(api.MachineStatePending, 2))
+	})
+})
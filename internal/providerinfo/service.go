@@ -0,0 +1,76 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package providerinfo
+
+import (
+	"context"
+
+	"github.com/ironcore-dev/libvirt-provider/pkg/pluginapi"
+	"google.golang.org/grpc"
+)
+
+// ServiceName is the gRPC service name of the provider info. It is served on a listener of its own, with messages
+// encoded as JSON like the plugin protocol, see pluginapi.
+const ServiceName = "libvirtprovider.info.v1.ProviderInfo"
+
+type GetInfoRequest struct{}
+
+// InfoServer is the server of the provider info service.
+type InfoServer interface {
+	GetInfo(ctx context.Context, req *GetInfoRequest) (*Info, error)
+}
+
+// GetInfo serves the Info collected by the Collector.
+func (c *Collector) GetInfo(ctx context.Context, _ *GetInfoRequest) (*Info, error) {
+	return c.Collect(ctx)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*InfoServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetInfo",
+			Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+				req := &GetInfoRequest{}
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+				if interceptor == nil {
+					return srv.(InfoServer).GetInfo(ctx, req)
+				}
+
+				info := &grpc.UnaryServerInfo{
+					Server:     srv,
+					FullMethod: "/" + ServiceName + "/GetInfo",
+				}
+				return interceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+					return srv.(InfoServer).GetInfo(ctx, req.(*GetInfoRequest))
+				})
+			},
+		},
+	},
+}
+
+// RegisterInfoServer registers the provider info server at the gRPC server.
+func RegisterInfoServer(s grpc.ServiceRegistrar, srv InfoServer) {
+	s.RegisterService(&serviceDesc, srv)
+}
+
+// Client is the client of the provider info service.
+type Client struct {
+	conn grpc.ClientConnInterface
+}
+
+func NewClient(conn grpc.ClientConnInterface) *Client {
+	return &Client{conn: conn}
+}
+
+func (c *Client) GetInfo(ctx context.Context) (*Info, error) {
+	info := &Info{}
+	if err := c.conn.Invoke(ctx, "/"+ServiceName+"/GetInfo", &GetInfoRequest{}, info, pluginapi.CallOption()); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
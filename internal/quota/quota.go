@@ -0,0 +1,247 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package quota enforces project quotas on directories of XFS and ext4 file systems mounted with project quotas.
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// FirstProjectID is the first project id assigned to directories. The ids below are left to projects managed
+// otherwise, e.g. in /etc/projid.
+const FirstProjectID uint32 = 1 << 20
+
+const (
+	// fsIOCGetXAttr and fsIOCSetXAttr are FS_IOC_FSGETXATTR and FS_IOC_FSSETXATTR.
+	fsIOCGetXAttr = 0x801c581f
+	fsIOCSetXAttr = 0x401c5820
+	// fsXFlagProjInherit is FS_XFLAG_PROJINHERIT, new files of directories with it inherit their project id.
+	fsXFlagProjInherit = 0x200
+
+	qGetQuota  = 0x800007
+	qSetQuota  = 0x800008
+	prjQuota   = 2
+	qifBLimits = 1
+	// blockSize is the unit of the block limits of quotas.
+	blockSize = 1024
+)
+
+// ErrUnsupported is returned if the file system doesn't support or doesn't enable project quotas.
+var ErrUnsupported = errors.New("project quotas not supported")
+
+// fsxattr is struct fsxattr of linux/fs.h.
+type fsxattr struct {
+	XFlags     uint32
+	ExtSize    uint32
+	NExtents   uint32
+	ProjID     uint32
+	CowExtSize uint32
+	Pad        [8]byte
+}
+
+// dqblk is struct if_dqblk of linux/quota.h.
+type dqblk struct {
+	BHardLimit uint64
+	BSoftLimit uint64
+	CurSpace   uint64
+	IHardLimit uint64
+	ISoftLimit uint64
+	CurInodes  uint64
+	BTime      uint64
+	ITime      uint64
+	Valid      uint32
+}
+
+// Quota is the disk space limit and usage of a project.
+type Quota struct {
+	// LimitBytes is the hard limit of the project, 0 if it's not limited.
+	LimitBytes int64
+	// UsedBytes is the disk space used by the files of the project.
+	UsedBytes int64
+}
+
+// Projects assigns projects to the directories in a parent directory and limits their disk space.
+type Projects struct {
+	dir string
+
+	// mu serializes the assignment of project ids.
+	mu sync.Mutex
+}
+
+// NewProjects returns the projects of the directories in dir. It fails with ErrUnsupported unless dir is on a XFS
+// or ext4 file system with project quotas enabled.
+func NewProjects(dir string) (*Projects, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(dir, &stat); err != nil {
+		return nil, fmt.Errorf("error getting file system stats of %s: %w", dir, err)
+	}
+	if stat.Type != unix.XFS_SUPER_MAGIC && stat.Type != unix.EXT4_SUPER_MAGIC {
+		return nil, fmt.Errorf("%w: %s is neither on a XFS nor ext4 file system", ErrUnsupported, dir)
+	}
+
+	p := &Projects{dir: dir}
+	if _, err := p.Get(FirstProjectID); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Assign assigns a project to dir, a directory in the parent directory, and to all directories and files in it.
+// Directories already assigned a project keep it.
+func (p *Projects) Assign(dir string) (uint32, error) {
+	attr, err := getXAttr(dir)
+	if err != nil {
+		return 0, err
+	}
+	if attr.ProjID != 0 {
+		return attr.ProjID, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	used, err := p.usedIDs()
+	if err != nil {
+		return 0, err
+	}
+	id := FirstProjectID
+	for used.Has(id) {
+		id++
+	}
+
+	// dir is assigned last, so assignments interrupted before are repeated.
+	if err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir || (!entry.IsDir() && !entry.Type().IsRegular()) {
+			return nil
+		}
+		return setProject(path, id)
+	}); err != nil {
+		return 0, fmt.Errorf("error assigning project %d to %s: %w", id, dir, err)
+	}
+	if err := setProject(dir, id); err != nil {
+		return 0, fmt.Errorf("error assigning project %d to %s: %w", id, dir, err)
+	}
+	return id, nil
+}
+
+func (p *Projects) usedIDs() (sets.Set[uint32], error) {
+	entries, err := os.ReadDir(p.dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading directory %s: %w", p.dir, err)
+	}
+
+	used := sets.New[uint32]()
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		attr, err := getXAttr(filepath.Join(p.dir, entry.Name()))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		used.Insert(attr.ProjID)
+	}
+	return used, nil
+}
+
+// SetLimit limits the disk space of the project to bytes, rounded up to whole KiB. 0 removes the limit.
+func (p *Projects) SetLimit(id uint32, bytes int64) error {
+	blocks := (uint64(max(bytes, 0)) + blockSize - 1) / blockSize
+	quota := dqblk{
+		BHardLimit: blocks,
+		BSoftLimit: blocks,
+		Valid:      qifBLimits,
+	}
+	if err := p.quotactl(qSetQuota, id, &quota); err != nil {
+		return fmt.Errorf("error setting quota of project %d: %w", id, err)
+	}
+	return nil
+}
+
+// Get returns the limit and usage of the project.
+func (p *Projects) Get(id uint32) (Quota, error) {
+	var quota dqblk
+	if err := p.quotactl(qGetQuota, id, &quota); err != nil {
+		return Quota{}, fmt.Errorf("error getting quota of project %d: %w", id, err)
+	}
+	return Quota{
+		LimitBytes: int64(quota.BHardLimit * blockSize),
+		UsedBytes:  int64(quota.CurSpace),
+	}, nil
+}
+
+func (p *Projects) quotactl(cmd int, id uint32, quota *dqblk) error {
+	f, err := os.Open(p.dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, _, errno := unix.Syscall6(unix.SYS_QUOTACTL_FD, f.Fd(), uintptr(cmd<<8|prjQuota), uintptr(id), uintptr(unsafe.Pointer(quota)), 0, 0)
+	switch errno {
+	case 0:
+		return nil
+	case unix.ENOSYS, unix.ESRCH, unix.EINVAL, unix.ENOTTY, unix.EOPNOTSUPP:
+		return fmt.Errorf("%w: %w", ErrUnsupported, errno)
+	default:
+		return errno
+	}
+}
+
+func getXAttr(path string) (*fsxattr, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	attr := &fsxattr{}
+	if err := ioctl(f, fsIOCGetXAttr, attr); err != nil {
+		return nil, fmt.Errorf("error getting attributes of %s: %w", path, err)
+	}
+	return attr, nil
+}
+
+func setProject(path string, id uint32) error {
+	f, err := os.OpenFile(path, os.O_RDONLY|unix.O_NOFOLLOW, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	attr := &fsxattr{}
+	if err := ioctl(f, fsIOCGetXAttr, attr); err != nil {
+		return fmt.Errorf("error getting attributes of %s: %w", path, err)
+	}
+	attr.ProjID = id
+	if info, err := f.Stat(); err == nil && info.IsDir() {
+		attr.XFlags |= fsXFlagProjInherit
+	}
+	if err := ioctl(f, fsIOCSetXAttr, attr); err != nil {
+		return fmt.Errorf("error setting attributes of %s: %w", path, err)
+	}
+	return nil
+}
+
+func ioctl(f *os.File, req uintptr, attr *fsxattr) error {
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), req, uintptr(unsafe.Pointer(attr))); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package quota_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/ironcore-dev/libvirt-provider/internal/quota"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Projects", func() {
+	It("should assign projects to directories and limit them", func() {
+		dir := GinkgoT().TempDir()
+		projects, err := quota.NewProjects(dir)
+		if errors.Is(err, quota.ErrUnsupported) {
+			Skip("the temp dir file system has no project quotas")
+		}
+		Expect(err).NotTo(HaveOccurred())
+
+		machineDir := filepath.Join(dir, "machine")
+		Expect(os.MkdirAll(filepath.Join(machineDir, "volumes"), 0700)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(machineDir, "volumes", "disk"), make([]byte, 64<<10), 0600)).To(Succeed())
+
+		id, err := projects.Assign(machineDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(id).To(BeNumerically(">=", quota.FirstProjectID))
+		Expect(projects.Assign(machineDir)).To(Equal(id))
+
+		otherDir := filepath.Join(dir, "other")
+		Expect(os.Mkdir(otherDir, 0700)).To(Succeed())
+		Expect(projects.Assign(otherDir)).NotTo(Equal(id))
+
+		Expect(projects.SetLimit(id, 1<<20)).To(Succeed())
+		q, err := projects.Get(id)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(q.LimitBytes).To(Equal(int64(1 << 20)))
+		Expect(q.UsedBytes).To(BeNumerically(">=", 64<<10))
+
+		By("exceeding the limit")
+		Expect(os.WriteFile(filepath.Join(machineDir, "log"), make([]byte, 2<<20), 0600)).NotTo(Succeed())
+	})
+})
@@ -4,11 +4,13 @@
 package raw
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
 
 	"github.com/go-logr/logr"
+	"golang.org/x/sys/unix"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 )
@@ -64,6 +66,13 @@ func createEmptyFileWithSeek(log logr.Logger, filename string, seek int64) error
 	return nil
 }
 
+// sparseBlockSize is the size of the blocks checked for zeros when copying files. Zero blocks are skipped,
+// leaving holes in the destination file.
+const sparseBlockSize = 64 << 10
+
+// copyFile copies the source to the destination file. On file systems supporting reflinks, e.g. XFS and btrfs,
+// the destination shares the extents of the source until they are written, otherwise the data is copied
+// leaving zero blocks as holes.
 func copyFile(log logr.Logger, src, dst string) error {
 	srcFile, err := os.Open(src)
 	if err != nil {
@@ -86,13 +95,71 @@ func copyFile(log logr.Logger, src, dst string) error {
 		}
 	}()
 
-	if _, err = io.Copy(dstFile, srcFile); err != nil {
-		return fmt.Errorf("failed to copy data from source file to destination file: %w", err)
+	cloneErr := unix.IoctlFileClone(int(dstFile.Fd()), int(srcFile.Fd()))
+	if cloneErr == nil {
+		log.V(1).Info("Cloned source file", "path", src)
+		return nil
 	}
+	log.V(1).Info("Reflink not supported, copying source file", "path", src, "reason", cloneErr.Error())
 
+	if err := sparseCopy(dstFile, srcFile); err != nil {
+		return fmt.Errorf("failed to copy data from source file to destination file: %w", err)
+	}
 	return nil
 }
 
+// sparseCopy copies src to dst, seeking over zero blocks instead of writing them.
+func sparseCopy(dst io.WriteSeeker, src io.Reader) error {
+	var (
+		buf  = make([]byte, sparseBlockSize)
+		size int64
+		hole bool
+	)
+	for {
+		n, err := io.ReadFull(src, buf)
+		if n > 0 {
+			block := buf[:n]
+			if isZero(block) {
+				if _, err := dst.Seek(int64(n), io.SeekCurrent); err != nil {
+					return err
+				}
+				hole = true
+			} else {
+				if _, err := dst.Write(block); err != nil {
+					return err
+				}
+				hole = false
+			}
+			size += int64(n)
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if !hole {
+		return nil
+	}
+	// A trailing hole isn't allocated by seeking, the file is extended to its full size.
+	truncater, ok := dst.(interface{ Truncate(int64) error })
+	if !ok {
+		return fmt.Errorf("destination can't be truncated to size %d", size)
+	}
+	return truncater.Truncate(size)
+}
+
+func isZero(block []byte) bool {
+	for _, b := range block {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
 func init() {
 	utilruntime.Must(impls.Add("exec", 0, Exec{}))
 }
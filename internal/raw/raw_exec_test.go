@@ -0,0 +1,49 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package raw_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+
+	"github.com/ironcore-dev/libvirt-provider/internal/raw"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Exec", func() {
+	It("should copy the source file", func() {
+		dir := GinkgoT().TempDir()
+		src := filepath.Join(dir, "src")
+		dst := filepath.Join(dir, "dst")
+
+		By("creating a source file with data between zero blocks")
+		data := make([]byte, 1<<20)
+		copy(data[300<<10:], bytes.Repeat([]byte("rootfs"), 1000))
+		Expect(os.WriteFile(src, data, 0600)).To(Succeed())
+
+		Expect(raw.Exec{}.Create(dst, raw.WithSourceFile(src))).To(Succeed())
+
+		copied, err := os.ReadFile(dst)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(copied).To(Equal(data))
+	})
+
+	It("should copy a source file ending with zeros", func() {
+		dir := GinkgoT().TempDir()
+		src := filepath.Join(dir, "src")
+		dst := filepath.Join(dir, "dst")
+
+		data := make([]byte, 1<<20+17)
+		data[0] = 1
+		Expect(os.WriteFile(src, data, 0600)).To(Succeed())
+
+		Expect(raw.Exec{}.Create(dst, raw.WithSourceFile(src))).To(Succeed())
+
+		copied, err := os.ReadFile(dst)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(copied).To(Equal(data))
+	})
+})
@@ -0,0 +1,228 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package retrypolicy classifies reconcile errors and computes the backoff of their retries per error class,
+// e.g. retrying transient libvirt errors quickly, failed image pulls slowly and permanent errors not at all.
+package retrypolicy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/digitalocean/go-libvirt"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/call"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// Class is the class of errors sharing a retry policy.
+type Class string
+
+const (
+	// ClassDefault is the class of errors not belonging to any other class.
+	ClassDefault Class = "Default"
+	// ClassImage is the class of errors pulling or preparing images.
+	ClassImage Class = "Image"
+	// ClassLibvirt is the class of errors returned by libvirt, which are mostly transient.
+	ClassLibvirt Class = "Libvirt"
+	// ClassTimeout is the class of libvirt calls and reconciles that didn't finish within their deadline, e.g.
+	// because of a hung libvirt daemon.
+	ClassTimeout Class = "Timeout"
+	// ClassUnschedulable is the class of errors of resources the host lacks, e.g. free PCI devices or hugepages.
+	ClassUnschedulable Class = "Unschedulable"
+	// ClassPermanent is the class of errors retrying can't resolve, e.g. invalid specs rejected by a plugin.
+	// Permanent errors are never retried, they have no policy.
+	ClassPermanent Class = "Permanent"
+)
+
+// Classes are the classes with a configurable policy.
+var Classes = []Class{ClassDefault, ClassImage, ClassLibvirt, ClassTimeout, ClassUnschedulable}
+
+// Policy is the retry policy of an error class. The delay starts at BaseDelay and doubles with every failed
+// retry up to MaxDelay.
+type Policy struct {
+	BaseDelay metav1.Duration `json:"baseDelay"`
+	MaxDelay  metav1.Duration `json:"maxDelay"`
+	// MaxRetries is the number of retries after which retrying is given up. 0 retries forever.
+	MaxRetries int `json:"maxRetries,omitempty"`
+}
+
+// DefaultPolicies are the policies of the classes without configured policy. Only unschedulable machines give
+// up retrying, so they can be scheduled on another host.
+var DefaultPolicies = map[Class]Policy{
+	ClassDefault:       {BaseDelay: metav1.Duration{Duration: 5 * time.Millisecond}, MaxDelay: metav1.Duration{Duration: 1000 * time.Second}},
+	ClassImage:         {BaseDelay: metav1.Duration{Duration: 10 * time.Second}, MaxDelay: metav1.Duration{Duration: 5 * time.Minute}},
+	ClassLibvirt:       {BaseDelay: metav1.Duration{Duration: time.Second}, MaxDelay: metav1.Duration{Duration: 2 * time.Minute}},
+	ClassTimeout:       {BaseDelay: metav1.Duration{Duration: 5 * time.Second}, MaxDelay: metav1.Duration{Duration: 5 * time.Minute}},
+	ClassUnschedulable: {BaseDelay: metav1.Duration{Duration: 10 * time.Second}, MaxDelay: metav1.Duration{Duration: time.Minute}, MaxRetries: 5},
+}
+
+// Validate checks that the delays of the policy are consistent.
+func Validate(policy *Policy) error {
+	if policy.BaseDelay.Duration <= 0 {
+		return fmt.Errorf("base delay must be positive")
+	}
+	if policy.MaxDelay.Duration < policy.BaseDelay.Duration {
+		return fmt.Errorf("max delay %s must not be less than base delay %s", policy.MaxDelay.Duration, policy.BaseDelay.Duration)
+	}
+	if policy.MaxRetries < 0 {
+		return fmt.Errorf("max retries must not be negative")
+	}
+	return nil
+}
+
+// LoadPolicies loads the retry policies per class from a YAML or JSON file. Classes missing in the file keep
+// their default policy.
+func LoadPolicies(filename string) (map[Class]Policy, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open retry policies file (%s): %w", filename, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var policies map[Class]Policy
+	if err := yaml.NewYAMLOrJSONDecoder(file, 4096).Decode(&policies); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal retry policies: %w", err)
+	}
+
+	res := make(map[Class]Policy, len(DefaultPolicies))
+	for class, policy := range DefaultPolicies {
+		res[class] = policy
+	}
+	for class, policy := range policies {
+		if !slices.Contains(Classes, class) {
+			return nil, fmt.Errorf("unsupported class %q, supported: %v", class, Classes)
+		}
+		if err := Validate(&policy); err != nil {
+			return nil, fmt.Errorf("invalid retry policy of class %s: %w", class, err)
+		}
+		res[class] = policy
+	}
+	return res, nil
+}
+
+type classError struct {
+	class Class
+	err   error
+}
+
+func (e *classError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classError) Unwrap() error {
+	return e.err
+}
+
+// WithClass marks the error as belonging to the class.
+func WithClass(class Class, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classError{class: class, err: err}
+}
+
+// Permanent marks the error as permanent, reconciles failing with it aren't retried.
+func Permanent(err error) error {
+	return WithClass(ClassPermanent, err)
+}
+
+// ClassOf returns the class the error was marked with. Unmarked libvirt errors are of ClassLibvirt, all other
+// errors of ClassDefault.
+func ClassOf(err error) Class {
+	var classErr *classError
+	if errors.As(err, &classErr) {
+		return classErr.class
+	}
+
+	if call.IsTimeout(err) {
+		return ClassTimeout
+	}
+
+	var libvirtErr libvirt.Error
+	if errors.As(err, &libvirtErr) {
+		return ClassLibvirt
+	}
+	return ClassDefault
+}
+
+type failures struct {
+	class Class
+	count int
+}
+
+// Backoff tracks the consecutive failures of items and computes the delay until their next retry from the
+// policy of the class of their last error.
+type Backoff struct {
+	mu       sync.Mutex
+	policies map[Class]Policy
+	failures map[string]failures
+}
+
+// NewBackoff returns a backoff with the given policies. Classes without policy use their default policy.
+func NewBackoff(policies map[Class]Policy) *Backoff {
+	res := make(map[Class]Policy, len(DefaultPolicies))
+	for class, policy := range DefaultPolicies {
+		res[class] = policy
+	}
+	for class, policy := range policies {
+		res[class] = policy
+	}
+
+	return &Backoff{
+		policies: res,
+		failures: make(map[string]failures),
+	}
+}
+
+// Next records a failure of the item with an error of the class and returns the delay until its retry. It
+// reports false if the item isn't retried anymore, because the error is permanent or the retries of the class
+// are exhausted. Failures of another class than the previous one start a new backoff.
+func (b *Backoff) Next(item string, class Class) (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if class == ClassPermanent {
+		return 0, false
+	}
+
+	f := b.failures[item]
+	if f.class != class {
+		f = failures{class: class}
+	}
+	f.count++
+	b.failures[item] = f
+
+	policy, ok := b.policies[class]
+	if !ok {
+		policy = b.policies[ClassDefault]
+	}
+	// The first failure isn't a retry yet.
+	if policy.MaxRetries > 0 && f.count > policy.MaxRetries+1 {
+		return 0, false
+	}
+
+	delay := policy.BaseDelay.Duration
+	for i := 1; i < f.count && delay < policy.MaxDelay.Duration; i++ {
+		delay *= 2
+	}
+	return min(delay, policy.MaxDelay.Duration), true
+}
+
+// Retries returns the number of retries of the item since its last success.
+func (b *Backoff) Retries(item string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return max(b.failures[item].count-1, 0)
+}
+
+// Forget resets the failures of the item, e.g. after it succeeded.
+func (b *Backoff) Forget(item string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.failures, item)
+}
@@ -0,0 +1,82 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package retrypolicy_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/digitalocean/go-libvirt"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/call"
+	. "github.com/ironcore-dev/libvirt-provider/internal/retrypolicy"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("RetryPolicy", func() {
+	It("should classify errors", func() {
+		Expect(ClassOf(errors.New("foo"))).To(Equal(ClassDefault))
+		Expect(ClassOf(fmt.Errorf("wrapped: %w", libvirt.Error{Code: uint32(libvirt.ErrOperationTimeout)}))).To(Equal(ClassLibvirt))
+		Expect(ClassOf(fmt.Errorf("wrapped: %w", WithClass(ClassImage, errors.New("foo"))))).To(Equal(ClassImage))
+		Expect(ClassOf(Permanent(libvirt.Error{}))).To(Equal(ClassPermanent))
+		Expect(ClassOf(fmt.Errorf("wrapped: %w", call.ErrTimeout))).To(Equal(ClassTimeout))
+		Expect(ClassOf(context.DeadlineExceeded)).To(Equal(ClassTimeout))
+	})
+
+	It("should back off exponentially per class up to the max delay", func() {
+		backoff := NewBackoff(map[Class]Policy{
+			ClassLibvirt: {BaseDelay: metav1.Duration{Duration: time.Second}, MaxDelay: metav1.Duration{Duration: 3 * time.Second}, MaxRetries: 3},
+		})
+
+		var delays []time.Duration
+		for range 4 {
+			delay, ok := backoff.Next("machine", ClassLibvirt)
+			Expect(ok).To(BeTrue())
+			delays = append(delays, delay)
+		}
+		Expect(delays).To(Equal([]time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second}))
+		Expect(backoff.Retries("machine")).To(Equal(3))
+
+		By("exhausting the retries")
+		_, ok := backoff.Next("machine", ClassLibvirt)
+		Expect(ok).To(BeFalse())
+
+		By("starting over for another class")
+		delay, ok := backoff.Next("machine", ClassImage)
+		Expect(ok).To(BeTrue())
+		Expect(delay).To(Equal(DefaultPolicies[ClassImage].BaseDelay.Duration))
+
+		By("forgetting the failures")
+		backoff.Forget("machine")
+		Expect(backoff.Retries("machine")).To(BeZero())
+	})
+
+	It("should not retry permanent errors", func() {
+		_, ok := NewBackoff(nil).Next("machine", ClassPermanent)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should load policies over the defaults", func() {
+		filename := filepath.Join(GinkgoT().TempDir(), "policies.yaml")
+		Expect(os.WriteFile(filename, []byte("Image:\n  baseDelay: 1m\n  maxDelay: 10m\n  maxRetries: 5\n"), 0666)).To(Succeed())
+
+		policies, err := LoadPolicies(filename)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policies).To(HaveKeyWithValue(ClassImage, Policy{
+			BaseDelay:  metav1.Duration{Duration: time.Minute},
+			MaxDelay:   metav1.Duration{Duration: 10 * time.Minute},
+			MaxRetries: 5,
+		}))
+		Expect(policies).To(HaveKeyWithValue(ClassLibvirt, DefaultPolicies[ClassLibvirt]))
+
+		Expect(os.WriteFile(filename, []byte("Permanent:\n  baseDelay: 1m\n  maxDelay: 10m\n"), 0666)).To(Succeed())
+		_, err = LoadPolicies(filename)
+		Expect(err).To(MatchError(ContainSubstring("unsupported class")))
+	})
+})
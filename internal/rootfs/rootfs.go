@@ -0,0 +1,30 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package rootfs selects how the root fs disks of machines are derived from the root fs of their image.
+package rootfs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+)
+
+// Modes are the supported root fs modes.
+var Modes = []api.RootFSMode{api.RootFSModeCopy, api.RootFSModeShared, api.RootFSModeOverlay}
+
+// ParseMode parses a root fs mode. An empty value yields an empty mode, meaning the default mode applies.
+func ParseMode(s string) (api.RootFSMode, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", nil
+	}
+
+	for _, mode := range Modes {
+		if strings.EqualFold(s, string(mode)) {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("unknown root fs mode %q, available: %v", s, Modes)
+}
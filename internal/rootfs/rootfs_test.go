@@ -0,0 +1,23 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package rootfs_test
+
+import (
+	"github.com/ironcore-dev/libvirt-provider/api"
+	. "github.com/ironcore-dev/libvirt-provider/internal/rootfs"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RootFS", func() {
+	It("should parse the root fs mode", func() {
+		Expect(ParseMode("")).To(BeEmpty())
+		Expect(ParseMode("Shared")).To(Equal(api.RootFSModeShared))
+		Expect(ParseMode(" overlay ")).To(Equal(api.RootFSModeOverlay))
+		Expect(ParseMode("copy")).To(Equal(api.RootFSModeCopy))
+
+		_, err := ParseMode("Thin")
+		Expect(err).To(MatchError(ContainSubstring("unknown root fs mode")))
+	})
+})
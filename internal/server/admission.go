@@ -0,0 +1,120 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	createMachineMethod = "CreateMachine"
+
+	// InFlightRetryDelay is the retry delay hinted to CreateMachine requests rejected by the in-flight limit.
+	InFlightRetryDelay = time.Second
+)
+
+// RateLimit limits the requests of a method to Rate requests per second with bursts of up to Burst requests.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseRateLimit parses a rate limit in the form <rate>[:<burst>], e.g. 5:10. The burst defaults to the rate
+// rounded up.
+func ParseRateLimit(s string) (RateLimit, error) {
+	rateStr, burstStr, hasBurst := strings.Cut(s, ":")
+	r, err := strconv.ParseFloat(rateStr, 64)
+	if err != nil || r <= 0 || math.IsInf(r, 0) {
+		return RateLimit{}, fmt.Errorf("invalid rate %q: has to be a positive number", rateStr)
+	}
+
+	burst := int(math.Ceil(r))
+	if hasBurst {
+		if burst, err = strconv.Atoi(burstStr); err != nil || burst < 1 {
+			return RateLimit{}, fmt.Errorf("invalid burst %q: has to be a positive integer", burstStr)
+		}
+	}
+	return RateLimit{Rate: r, Burst: burst}, nil
+}
+
+type AdmissionOptions struct {
+	// MethodRateLimits are the rate limits per IRI method name, e.g. CreateMachine. Methods without rate limit
+	// are not limited.
+	MethodRateLimits map[string]RateLimit
+	// MaxInFlightCreates is the maximum number of CreateMachine requests processed at once. Zero means no limit.
+	MaxInFlightCreates int
+}
+
+// Admission rejects IRI requests exceeding their rate limit or the in-flight CreateMachine limit with
+// ResourceExhausted and a retry delay, instead of queueing them until libvirt catches up.
+type Admission struct {
+	limiters        map[string]*rate.Limiter
+	inFlightCreates chan struct{}
+}
+
+func NewAdmission(opts AdmissionOptions) *Admission {
+	a := &Admission{
+		limiters: make(map[string]*rate.Limiter, len(opts.MethodRateLimits)),
+	}
+	for method, limit := range opts.MethodRateLimits {
+		a.limiters[method] = rate.NewLimiter(rate.Limit(limit.Rate), limit.Burst)
+	}
+	if opts.MaxInFlightCreates > 0 {
+		a.inFlightCreates = make(chan struct{}, opts.MaxInFlightCreates)
+	}
+	return a
+}
+
+// UnaryServerInterceptor admits the requests of the gRPC server.
+func (a *Admission) UnaryServerInterceptor(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	method := path.Base(info.FullMethod)
+
+	if limiter, ok := a.limiters[method]; ok {
+		now := time.Now()
+		reservation := limiter.ReserveN(now, 1)
+		if delay := reservation.DelayFrom(now); delay > 0 {
+			reservation.CancelAt(now)
+			return nil, resourceExhausted(delay, "rate limit of %s exceeded", method)
+		}
+	}
+
+	if method == createMachineMethod && a.inFlightCreates != nil {
+		select {
+		case a.inFlightCreates <- struct{}{}:
+			defer func() { <-a.inFlightCreates }()
+		default:
+			return nil, resourceExhausted(InFlightRetryDelay, "maximum of %d in-flight %s requests reached", cap(a.inFlightCreates), method)
+		}
+	}
+
+	return handler(ctx, req)
+}
+
+// resourceExhausted returns a ResourceExhausted error hinting clients to retry after the delay.
+func resourceExhausted(delay time.Duration, format string, args ...any) error {
+	st := status.Newf(codes.ResourceExhausted, "%s, retry after %s", fmt.Sprintf(format, args...), delay.Round(time.Millisecond))
+	withDetails, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(delay)})
+	if err != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
@@ -0,0 +1,79 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package server_test
+
+import (
+	"context"
+
+	"github.com/ironcore-dev/libvirt-provider/internal/server"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var _ = Describe("Admission", func() {
+	const (
+		createMachine = "/machine.v1alpha1.MachineRuntime/CreateMachine"
+		listMachines  = "/machine.v1alpha1.MachineRuntime/ListMachines"
+	)
+
+	handler := func(context.Context, any) (any, error) {
+		return "ok", nil
+	}
+
+	It("should parse rate limits", func() {
+		Expect(server.ParseRateLimit("2.5")).To(Equal(server.RateLimit{Rate: 2.5, Burst: 3}))
+		Expect(server.ParseRateLimit("2:10")).To(Equal(server.RateLimit{Rate: 2, Burst: 10}))
+		_, err := server.ParseRateLimit("0")
+		Expect(err).To(HaveOccurred())
+		_, err = server.ParseRateLimit("1:0")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should reject requests exceeding the rate limit with a retry delay", func(ctx SpecContext) {
+		admission := server.NewAdmission(server.AdmissionOptions{
+			MethodRateLimits: map[string]server.RateLimit{"ListMachines": {Rate: 0.001, Burst: 1}},
+		})
+		info := &grpc.UnaryServerInfo{FullMethod: listMachines}
+
+		Expect(admission.UnaryServerInterceptor(ctx, nil, info, handler)).To(Equal("ok"))
+
+		_, err := admission.UnaryServerInterceptor(ctx, nil, info, handler)
+		st, ok := status.FromError(err)
+		Expect(ok).To(BeTrue())
+		Expect(st.Code()).To(Equal(codes.ResourceExhausted))
+		Expect(st.Details()).To(ConsistOf(HaveField("RetryDelay", Not(BeNil()))))
+		Expect(st.Details()[0].(*errdetails.RetryInfo).RetryDelay.AsDuration()).To(BeNumerically(">", 0))
+
+		Expect(admission.UnaryServerInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: createMachine}, handler)).To(Equal("ok"))
+	})
+
+	It("should reject creates exceeding the in-flight limit", func(ctx SpecContext) {
+		admission := server.NewAdmission(server.AdmissionOptions{MaxInFlightCreates: 1})
+		info := &grpc.UnaryServerInfo{FullMethod: createMachine}
+
+		started, release := make(chan struct{}), make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			_, _ = admission.UnaryServerInterceptor(ctx, nil, info, func(context.Context, any) (any, error) {
+				close(started)
+				<-release
+				return "ok", nil
+			})
+		}()
+		Eventually(started).Should(BeClosed())
+
+		_, err := admission.UnaryServerInterceptor(ctx, nil, info, handler)
+		Expect(status.Code(err)).To(Equal(codes.ResourceExhausted))
+
+		close(release)
+		Eventually(func() error {
+			_, err := admission.UnaryServerInterceptor(ctx, nil, info, handler)
+			return err
+		}).Should(Succeed())
+	})
+})
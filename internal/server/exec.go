@@ -9,8 +9,6 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
-	"sync"
 	"time"
 
 	"github.com/digitalocean/go-libvirt"
@@ -18,6 +16,7 @@ import (
 	iri "github.com/ironcore-dev/ironcore/iri/apis/machine/v1alpha1"
 	remotecommandserver "github.com/ironcore-dev/ironcore/poollet/machinepoollet/iri/streaming/remotecommand"
 	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/console/hub"
 	libvirtutils "github.com/ironcore-dev/libvirt-provider/internal/libvirt/utils"
 	"github.com/ironcore-dev/libvirt-provider/internal/store"
 	"github.com/moby/term"
@@ -33,10 +32,10 @@ const (
 )
 
 type executorExec struct {
-	Libvirt        *libvirt.Libvirt
-	ExecRequest    *iri.ExecRequest
-	Machine        *api.Machine
-	activeConsoles *sync.Map
+	Libvirt     *libvirt.Libvirt
+	ExecRequest *iri.ExecRequest
+	Machine     *api.Machine
+	consoles    *hub.Hub
 }
 
 func (s *Server) Exec(ctx context.Context, req *iri.ExecRequest) (*iri.ExecResponse, error) {
@@ -83,10 +82,10 @@ func (s *Server) ServeExec(w http.ResponseWriter, req *http.Request, token strin
 	}
 
 	exec := executorExec{
-		Libvirt:        s.libvirt,
-		ExecRequest:    request,
-		Machine:        apiMachine,
-		activeConsoles: &s.activeConsoles,
+		Libvirt:     s.libvirt,
+		ExecRequest: request,
+		Machine:     apiMachine,
+		consoles:    s.consoles,
 	}
 
 	handler, err := remotecommandserver.NewExecHandler(exec, remotecommandserver.ExecHandlerOptions{
@@ -106,14 +105,6 @@ func (s *Server) ServeExec(w http.ResponseWriter, req *http.Request, token strin
 func (e executorExec) Exec(ctx context.Context, in io.Reader, out io.WriteCloser, _ remotecommand.TerminalSizeQueue) error {
 	machineID := e.ExecRequest.MachineId
 
-	// Check if a console is already active for this machine
-	_, loaded := e.activeConsoles.LoadOrStore(machineID, true)
-	if loaded {
-		return errors.New("operation failed: Active console session exists for this domain")
-	}
-
-	defer e.activeConsoles.Delete(machineID)
-
 	// Check if the apiMachine doesn't exist, to avoid making the libvirt-lookup call.
 	if e.Machine == nil {
 		return fmt.Errorf("apiMachine %w in the store", store.ErrNotFound)
@@ -143,55 +134,59 @@ func (e executorExec) Exec(ctx context.Context, in io.Reader, out io.WriteCloser
 	}
 	ttyPath := domainXML.Devices.Consoles[0].TTY
 
-	f, err := os.OpenFile(ttyPath, os.O_RDWR, 0)
+	client, history, err := e.consoles.Attach(machineID, ttyPath)
 	if err != nil {
-		return fmt.Errorf("error opening PTY: %w", err)
+		return fmt.Errorf("error attaching console: %w", err)
 	}
+	defer client.Close()
 
 	// Wrap the input stream with an escape proxy. Escape Sequence Ctrl + ] = 29
 	inputReader := term.NewEscapeProxy(in, []byte{29})
 
 	// Print escape character information to the exec console
 	fmt.Fprintf(out, "Escape character is ^] (Ctrl + ])\n")
+	if !client.ReadWrite() {
+		fmt.Fprintf(out, "Another client controls the console, attached read-only\n")
+	}
 
-	var wg sync.WaitGroup
 	log := logr.FromContextOrDiscard(ctx).WithName(machineID)
 
-	wg.Add(2)
-	// ReadInput: go routine to read the input from the reader, and write to the terminal.
+	// ReadInput: go routine to read the input from the reader, and write to the terminal. The input of read-only
+	// clients is only watched for the escape sequence.
 	go func() {
-		defer wg.Done()
-
 		buf := make([]byte, 1024)
 		for {
 			n, err := inputReader.Read(buf)
 			if err != nil {
 				if _, ok := err.(term.EscapeError); ok {
-					f.Close() // This is to close the writer, allowing io.Copy to exit the loop.
+					client.Close() // This is to close the output, allowing the output loop to exit.
 					log.Info("Closed reading the terminal. Escape sequence received")
 					return
 				}
 				log.Error(err, "error reading bytes")
 				return
 			}
+			if !client.ReadWrite() {
+				continue
+			}
 
-			_, err = f.Write(buf[:n])
-			if err != nil {
-				log.Error(err, "error writing to the file descriptor")
+			if _, err := client.Write(buf[:n]); err != nil {
+				log.Error(err, "error writing to the console")
 				return
 			}
 		}
 	}()
 
-	// WriteOutput: go routine for writing the output back to the Writer.
-	go func() {
-		defer wg.Done()
-		// Ignoring error to allow graceful shutdown without flagging as an error; not needed at this stage.
-		_, _ = io.Copy(out, f)
-		log.Info("Closed writing to the terminal")
-	}()
+	// WriteOutput: write the retained and the new output back to the Writer until the client is detached.
+	// Ignoring write errors to allow graceful shutdown without flagging as an error; not needed at this stage.
+	if _, err := out.Write(history); err == nil {
+		for data := range client.Output() {
+			if _, err := out.Write(data); err != nil {
+				break
+			}
+		}
+	}
 
-	wg.Wait()
 	log.Info("Closed console for the machine")
 	return nil
 }
@@ -5,19 +5,52 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 
 	"github.com/go-logr/logr"
 	iri "github.com/ironcore-dev/ironcore/iri/apis/machine/v1alpha1"
+	irimeta "github.com/ironcore-dev/ironcore/iri/apis/meta/v1alpha1"
 	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/alias"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/device"
+	providernetworkinterface "github.com/ironcore-dev/libvirt-provider/internal/plugins/networkinterface"
+	providervolume "github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// validateDevices ensures the volumes and network interfaces of the spec result in valid, distinct domain
+// device aliases and disk targets.
+func validateDevices(spec *api.MachineSpec) error {
+	registry := alias.NewRegistry()
+	allocator := device.NewAllocator()
+	for _, volume := range spec.Volumes {
+		if err := registry.RegisterVolume(volume.Name); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid volume: %v", err)
+		}
+		if _, err := allocator.AllocateVolume(volume.Name, volume.Device); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid volume: %v", err)
+		}
+	}
+	for _, nic := range spec.NetworkInterfaces {
+		if err := registry.RegisterNetworkInterface(nic.Name); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid network interface: %v", err)
+		}
+	}
+	return nil
+}
+
 func (s *Server) convertMachineToIRIMachine(ctx context.Context, log logr.Logger, machine *api.Machine) (*iri.Machine, error) {
 	metadata, err := api.GetObjectMetadata(machine.Metadata)
 	if err != nil {
 		return nil, fmt.Errorf("error getting iri metadata: %w", err)
 	}
 
+	if err := setIRIStatusAnnotations(metadata, machine); err != nil {
+		return nil, fmt.Errorf("error setting iri status annotations: %w", err)
+	}
+
 	spec, err := s.getIRIMachineSpec(machine)
 	if err != nil {
 		return nil, fmt.Errorf("error getting iri resources: %w", err)
@@ -35,6 +68,49 @@ func (s *Server) convertMachineToIRIMachine(ctx context.Context, log logr.Logger
 	}, nil
 }
 
+// setIRIStatusAnnotations reports the parts of the status of the machine IRI has no fields for by the
+// api.StatusAnnotations.
+func setIRIStatusAnnotations(metadata *irimeta.ObjectMetadata, machine *api.Machine) error {
+	setAnnotation := func(key, value string) {
+		if metadata.Annotations == nil {
+			metadata.Annotations = make(map[string]string)
+		}
+		metadata.Annotations[key] = value
+	}
+
+	if machine.Status.State == api.MachineStateFailed {
+		setAnnotation(api.FailureReasonAnnotation, string(machine.Status.FailureReason))
+		setAnnotation(api.FailureMessageAnnotation, machine.Status.FailureMessage)
+	}
+
+	volumeFailures := make(map[string]api.DeviceFailure)
+	for _, volume := range machine.Status.VolumeStatus {
+		if volume.State == api.VolumeStateFailed {
+			volumeFailures[volume.Name] = api.DeviceFailure{Message: volume.Message, FailedAttempts: volume.FailedAttempts}
+		}
+	}
+	nicFailures := make(map[string]api.DeviceFailure)
+	for _, nic := range machine.Status.NetworkInterfaceStatus {
+		if nic.State == api.NetworkInterfaceStateFailed {
+			nicFailures[nic.Name] = api.DeviceFailure{Message: nic.Message, FailedAttempts: nic.FailedAttempts}
+		}
+	}
+	for key, failures := range map[string]map[string]api.DeviceFailure{
+		api.VolumeFailuresAnnotation:           volumeFailures,
+		api.NetworkInterfaceFailuresAnnotation: nicFailures,
+	} {
+		if len(failures) == 0 {
+			continue
+		}
+		data, err := json.Marshal(failures)
+		if err != nil {
+			return fmt.Errorf("error marshalling %s: %w", key, err)
+		}
+		setAnnotation(key, string(data))
+	}
+	return nil
+}
+
 func (s *Server) getIRIMachineSpec(machine *api.Machine) (*iri.MachineSpec, error) {
 	class, ok := api.GetClassLabel(machine)
 	if !ok {
@@ -176,7 +252,9 @@ func (s *Server) getIRINICState(state api.NetworkInterfaceState) (iri.NetworkInt
 	switch state {
 	case api.NetworkInterfaceStateAttached:
 		return iri.NetworkInterfaceState_NETWORK_INTERFACE_ATTACHED, nil
-	case api.NetworkInterfaceStatePending:
+	case api.NetworkInterfaceStatePending, api.NetworkInterfaceStateFailed:
+		// IRI has no failed state, failed network interfaces are retried and stay pending until then. Their
+		// failure is reported by the api.NetworkInterfaceFailuresAnnotation.
 		return iri.NetworkInterfaceState_NETWORK_INTERFACE_PENDING, nil
 	default:
 		return 0, fmt.Errorf("unknown network interface state '%q'", state)
@@ -187,7 +265,9 @@ func (s *Server) getIRIVolumeState(state api.VolumeState) (iri.VolumeState, erro
 	switch state {
 	case api.VolumeStateAttached:
 		return iri.VolumeState_VOLUME_ATTACHED, nil
-	case api.VolumeStatePending:
+	case api.VolumeStatePending, api.VolumeStateFailed:
+		// IRI has no failed state, failed volumes are retried and stay pending until then. Their failure is
+		// reported by the api.VolumeFailuresAnnotation.
 		return iri.VolumeState_VOLUME_PENDING, nil
 	default:
 		return 0, fmt.Errorf("unknown volume state '%q'", state)
@@ -196,7 +276,9 @@ func (s *Server) getIRIVolumeState(state api.VolumeState) (iri.VolumeState, erro
 
 func (s *Server) getIRIState(state api.MachineState) (iri.MachineState, error) {
 	switch state {
-	case api.MachineStatePending:
+	case api.MachineStatePending, api.MachineStateFailed:
+		// IRI has no failed state, failed machines have no domain and stay pending until their spec changes.
+		// Their failure is reported by the api.FailureReasonAnnotation and api.FailureMessageAnnotation.
 		return iri.MachineState_MACHINE_PENDING, nil
 	case api.MachineStateRunning:
 		return iri.MachineState_MACHINE_RUNNING, nil
@@ -234,8 +316,8 @@ func (s *Server) getPowerStateFromIRI(power iri.Power) (api.PowerState, error) {
 }
 
 func (s *Server) getVolumeFromIRIVolume(iriVolume *iri.Volume) (*api.VolumeSpec, error) {
-	if iriVolume == nil {
-		return nil, fmt.Errorf("original volume is nil")
+	if err := validateIRIVolume(iriVolume); err != nil {
+		return nil, err
 	}
 
 	var emptyDiskSpec *api.EmptyDiskSpec
@@ -245,7 +327,11 @@ func (s *Server) getVolumeFromIRIVolume(iriVolume *iri.Volume) (*api.VolumeSpec,
 		}
 	}
 
-	var connectionSpec *api.VolumeConnection
+	var (
+		connectionSpec *api.VolumeConnection
+		driverSpec     *api.VolumeDriverSpec
+		shareable      bool
+	)
 	if connection := iriVolume.Connection; connection != nil {
 		connectionSpec = &api.VolumeConnection{
 			Driver:         connection.Driver,
@@ -254,6 +340,19 @@ func (s *Server) getVolumeFromIRIVolume(iriVolume *iri.Volume) (*api.VolumeSpec,
 			SecretData:     connection.SecretData,
 			EncryptionData: connection.EncryptionData,
 		}
+
+		var err error
+		driverSpec, err = providervolume.ReadDriverAttributes(connection.Attributes)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid volume %s: %v", iriVolume.Name, err)
+		}
+		shareable, err = providervolume.ReadShareableAttribute(connection.Attributes)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid volume %s: %v", iriVolume.Name, err)
+		}
+		if shareable && driverSpec != nil && driverSpec.Cache != "" && driverSpec.Cache != providervolume.CacheModeNone {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid volume %s: shareable volumes don't support cache mode %q", iriVolume.Name, driverSpec.Cache)
+		}
 	}
 
 	volumeSpec := &api.VolumeSpec{
@@ -261,18 +360,41 @@ func (s *Server) getVolumeFromIRIVolume(iriVolume *iri.Volume) (*api.VolumeSpec,
 		Device:     iriVolume.Device,
 		EmptyDisk:  emptyDiskSpec,
 		Connection: connectionSpec,
+		Driver:     driverSpec,
+		Shareable:  shareable,
 	}
 
 	if _, err := s.volumePlugins.FindPluginBySpec(volumeSpec); err != nil {
-		return nil, fmt.Errorf("failed to find volume plugin: %w", err)
+		return nil, status.Errorf(codes.InvalidArgument, "invalid volume %s: %v", iriVolume.Name, err)
 	}
 
 	return volumeSpec, nil
 }
 
 func (s *Server) getNICFromIRINIC(iriNIC *iri.NetworkInterface) (*api.NetworkInterfaceSpec, error) {
-	if iriNIC == nil {
-		return nil, fmt.Errorf("networkInterface is nil")
+	if err := validateIRINetworkInterface(iriNIC); err != nil {
+		return nil, err
+	}
+
+	plugin, err := s.networkInterfacePlugins.FindPluginByName(iriNIC.Attributes[providernetworkinterface.AttributePluginKey])
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid network interface %s: %v", iriNIC.Name, err)
+	}
+
+	model, err := providernetworkinterface.ReadModelAttribute(iriNIC.Attributes)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid network interface %s: %v", iriNIC.Name, err)
+	}
+	if model != "" && !plugin.Capabilities().Models {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid network interface %s: network interface plugin %s doesn't support models", iriNIC.Name, plugin.Name())
+	}
+
+	macAddress, err := providernetworkinterface.ReadMACAddressAttribute(iriNIC.Attributes)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid network interface %s: %v", iriNIC.Name, err)
+	}
+	if macAddress != "" && !plugin.Capabilities().MACAddresses {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid network interface %s: network interface plugin %s doesn't support MAC addresses", iriNIC.Name, plugin.Name())
 	}
 
 	return &api.NetworkInterfaceSpec{
@@ -280,5 +402,7 @@ func (s *Server) getNICFromIRINIC(iriNIC *iri.NetworkInterface) (*api.NetworkInt
 		NetworkId:  iriNIC.NetworkId,
 		Ips:        iriNIC.Ips,
 		Attributes: iriNIC.Attributes,
+		Model:      model,
+		MACAddress: macAddress,
 	}, nil
 }
@@ -0,0 +1,33 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"github.com/ironcore-dev/libvirt-provider/api"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("setRescue", func() {
+	const image = "example.org/rescue:latest"
+
+	DescribeTable("should set the rescue spec from the rescue annotation",
+		func(rescue *api.RescueSpec, previous, annotations map[string]string, expected *api.RescueSpec) {
+			machine := &api.Machine{Spec: api.MachineSpec{Rescue: rescue}}
+			setRescue(machine, previous, annotations)
+			Expect(machine.Spec.Rescue).To(Equal(expected))
+		},
+		Entry("not rescued", nil, nil, map[string]string{}, nil),
+		Entry("rescuing with the default image", nil, nil,
+			map[string]string{api.RescueAnnotation: ""}, &api.RescueSpec{}),
+		Entry("rescuing with an explicit image", nil, nil,
+			map[string]string{api.RescueAnnotation: image}, &api.RescueSpec{Image: image}),
+		Entry("switching back to the default image", &api.RescueSpec{Image: image},
+			map[string]string{api.RescueAnnotation: image}, map[string]string{api.RescueAnnotation: ""}, &api.RescueSpec{}),
+		Entry("removing the annotation", &api.RescueSpec{Image: image},
+			map[string]string{api.RescueAnnotation: image}, map[string]string{}, nil),
+		Entry("keeping a rescue of the admin server", &api.RescueSpec{Image: image},
+			map[string]string{}, map[string]string{}, &api.RescueSpec{Image: image}),
+	)
+})
@@ -7,6 +7,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
 
 	iri "github.com/ironcore-dev/ironcore/iri/apis/machine/v1alpha1"
 	"github.com/ironcore-dev/libvirt-provider/api"
@@ -16,10 +17,20 @@ import (
 )
 
 func (s *Server) updateAnnotations(ctx context.Context, machine *api.Machine, annotations map[string]string) error {
+	annotations = maps.Clone(annotations)
+	for _, key := range api.StatusAnnotations {
+		delete(annotations, key)
+	}
+
+	// Machines without readable annotations are treated as if they had none.
+	previous, _ := api.GetAnnotationsAnnotation(machine.Metadata)
+	setRescue(machine, previous, annotations)
+
 	if err := api.SetAnnotationsAnnotation(machine, annotations); err != nil {
 		return fmt.Errorf("failed to set machine annotations: %w", err)
 	}
 
+	machine.IncrementGeneration()
 	if _, err := s.machineStore.Update(ctx, machine); err != nil {
 		return fmt.Errorf("failed to update machine: %w", err)
 	}
@@ -27,6 +38,19 @@ func (s *Server) updateAnnotations(ctx context.Context, machine *api.Machine, an
 	return nil
 }
 
+// setRescue boots the machine into the rescue image selected by the api.RescueAnnotation, or normally again once
+// the annotation is removed. Machines rescued via the admin server stay rescued while the annotation is absent.
+func setRescue(machine *api.Machine, previous, annotations map[string]string) {
+	image, ok := annotations[api.RescueAnnotation]
+	_, wasRescued := previous[api.RescueAnnotation]
+	switch {
+	case ok:
+		machine.Spec.Rescue = &api.RescueSpec{Image: image}
+	case wasRescued:
+		machine.Spec.Rescue = nil
+	}
+}
+
 func (s *Server) UpdateMachineAnnotations(ctx context.Context, req *iri.UpdateMachineAnnotationsRequest) (*iri.UpdateMachineAnnotationsResponse, error) {
 	log := s.loggerFrom(ctx)
 
@@ -5,11 +5,19 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 
 	"github.com/go-logr/logr"
 	iri "github.com/ironcore-dev/ironcore/iri/apis/machine/v1alpha1"
 	api "github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/hardware"
+	"github.com/ironcore-dev/libvirt-provider/internal/hugepages"
+	"github.com/ironcore-dev/libvirt-provider/internal/mcr"
+	"github.com/ironcore-dev/libvirt-provider/internal/smbios"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func calcResources(class *iri.MachineClass) (int64, int64) {
@@ -20,20 +28,13 @@ func calcResources(class *iri.MachineClass) (int64, int64) {
 func (s *Server) createMachineFromIRIMachine(ctx context.Context, log logr.Logger, iriMachine *iri.Machine) (*api.Machine, error) {
 	log.V(2).Info("Getting libvirt machine config")
 
-	switch {
-	case iriMachine == nil:
-		return nil, fmt.Errorf("iri machine is nil")
-	case iriMachine.Spec == nil:
-		return nil, fmt.Errorf("iri machine spec is nil")
-	case iriMachine.Metadata == nil:
-		return nil, fmt.Errorf("iri machine metadata is nil")
+	annotations, err := s.validateIRIMachine(iriMachine)
+	if err != nil {
+		return nil, err
 	}
+	log.V(2).Info("Validated machine")
 
-	class, found := s.machineClasses.Get(iriMachine.Spec.Class)
-	if !found {
-		return nil, fmt.Errorf("machine class '%s' not supported", iriMachine.Spec.Class)
-	}
-	log.V(2).Info("Validated class")
+	class, _ := s.machineClasses.Get(iriMachine.Spec.Class)
 
 	cpu, memory := calcResources(class)
 
@@ -52,13 +53,76 @@ func (s *Server) createMachineFromIRIMachine(ctx context.Context, log logr.Logge
 		volumes = append(volumes, volumeSpec)
 	}
 
+	smbiosSpec := annotations.smbios
+	if defaults, ok := s.smbiosClassDefaults[class.Name]; ok {
+		smbiosSpec = smbios.Merge(&defaults, smbiosSpec)
+	}
+
+	hardwareSpec := annotations.hardware
+	if defaults, ok := s.hardwareClassDefaults[class.Name]; ok {
+		hardwareSpec = hardware.Merge(&defaults, hardwareSpec)
+	}
+
+	rootFSMode := annotations.rootFSMode
+	if rootFSMode == "" {
+		rootFSMode = s.rootFSMode
+	}
+
+	var bootSpec *api.BootSpec
+	if len(annotations.bootDevices) > 0 {
+		bootSpec = &api.BootSpec{Devices: annotations.bootDevices}
+	}
+
+	var hugepagesSpec *api.HugepagesSpec
+	if s.enableHugepages {
+		pageSize := s.getHugepageSize(class.Name)
+		if _, err := hugepages.Pages(memory, pageSize); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "machine class '%s' can't be backed by hugepages: %v", class.Name, err)
+		}
+		hugepagesSpec = &api.HugepagesSpec{PageSize: pageSize}
+	}
+
+	var sgxSpec *api.SGXSpec
+	if epcBytes, ok := s.sgxEPCClassSizes[class.Name]; ok {
+		sgxSpec = &api.SGXSpec{EPCBytes: epcBytes}
+	}
+
+	var pciSpec *api.PCISpec
+	if layout, ok := s.pciClassLayouts[class.Name]; ok {
+		pciSpec = &api.PCISpec{
+			RootPorts:             layout.RootPorts,
+			SwitchDownstreamPorts: layout.SwitchDownstreamPorts,
+		}
+	}
+
+	pciDevices := slices.Clone(s.pciDeviceClassClaims[class.Name])
+
+	var devicesSpec *api.DevicesSpec
+	if profile, ok := s.deviceClassProfiles[class.Name]; ok {
+		profile.Inputs = slices.Clone(profile.Inputs)
+		devicesSpec = &profile
+	}
+
+	var clockSpec *api.ClockSpec
+	if policy, ok := s.clockClassPolicies[class.Name]; ok {
+		clockSpec = &policy
+	}
+
+	var cgroupSpec *api.CgroupSpec
+	if policy, ok := s.cgroupClassPolicies[class.Name]; ok {
+		cgroupSpec = &policy
+	}
+
+	var queuesSpec *api.QueuesSpec
+	if queues, ok := s.queueClassCounts[class.Name]; ok {
+		queuesSpec = &queues
+	}
+
 	var networkInterfaces []*api.NetworkInterfaceSpec
 	for _, iriNetworkInterface := range iriMachine.Spec.NetworkInterfaces {
-		networkInterfaceSpec := &api.NetworkInterfaceSpec{
-			Name:       iriNetworkInterface.Name,
-			NetworkId:  iriNetworkInterface.NetworkId,
-			Ips:        iriNetworkInterface.Ips,
-			Attributes: iriNetworkInterface.Attributes,
+		networkInterfaceSpec, err := s.getNICFromIRINIC(iriNetworkInterface)
+		if err != nil {
+			return nil, fmt.Errorf("error converting network interface: %w", err)
 		}
 		networkInterfaces = append(networkInterfaces, networkInterfaceSpec)
 	}
@@ -68,13 +132,29 @@ func (s *Server) createMachineFromIRIMachine(ctx context.Context, log logr.Logge
 			ID: s.idGen.Generate(),
 		},
 		Spec: api.MachineSpec{
-			Power:             power,
-			CpuMillis:         cpu,
-			MemoryBytes:       memory,
-			Volumes:           volumes,
-			Ignition:          iriMachine.Spec.IgnitionData,
-			NetworkInterfaces: networkInterfaces,
-			GuestAgent:        s.guestAgent,
+			Power:              power,
+			CpuMillis:          cpu,
+			MemoryBytes:        memory,
+			Volumes:            volumes,
+			Ignition:           iriMachine.Spec.IgnitionData,
+			NetworkInterfaces:  networkInterfaces,
+			GuestAgent:         s.guestAgent,
+			ExtraKernelCmdline: annotations.extraKernelCmdline,
+			HostEventPolicy:    annotations.hostEventPolicy,
+			SMBIOS:             smbiosSpec,
+			Hugepages:          hugepagesSpec,
+			SGX:                sgxSpec,
+			PCI:                pciSpec,
+			Queues:             queuesSpec,
+			Boot:               bootSpec,
+			Media:              annotations.media,
+			PCIDevices:         pciDevices,
+			Devices:            devicesSpec,
+			Hardware:           hardwareSpec,
+			Clock:              clockSpec,
+			Windows:            annotations.windows,
+			Cgroup:             cgroupSpec,
+			RootFSMode:         rootFSMode,
 		},
 	}
 
@@ -88,6 +168,22 @@ func (s *Server) createMachineFromIRIMachine(ctx context.Context, log logr.Logge
 		machine.Spec.Image = &iriMachine.Spec.Image.Image
 	}
 
+	if err := validateDevices(&machine.Spec); err != nil {
+		return nil, err
+	}
+
+	release, err := s.reserveEphemeralStorage(ctx, &machine.Spec)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	unlock, err := s.lockBackingVolumes(ctx, machine.ID, &machine.Spec)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
 	apiMachine, err := s.machineStore.Create(ctx, machine)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create machine: %w", err)
@@ -96,6 +192,23 @@ func (s *Server) createMachineFromIRIMachine(ctx context.Context, log logr.Logge
 	return apiMachine, nil
 }
 
+// reserveEphemeralStorage reserves the disk space of the given spec if ephemeral storage is accounted. Further
+// reservations wait until the returned func is called, once the machine using the disk space is stored.
+func (s *Server) reserveEphemeralStorage(ctx context.Context, spec *api.MachineSpec) (func(), error) {
+	if s.ephemeralStorage == nil {
+		return func() {}, nil
+	}
+
+	release, err := s.ephemeralStorage.Reserve(ctx, s.ephemeralStorage.MachineBytes(spec))
+	if err != nil {
+		if errors.Is(err, mcr.ErrEphemeralStorageExhausted) {
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		}
+		return nil, fmt.Errorf("failed to reserve ephemeral storage: %w", err)
+	}
+	return release, nil
+}
+
 func (s *Server) CreateMachine(ctx context.Context, req *iri.CreateMachineRequest) (res *iri.CreateMachineResponse, retErr error) {
 	log := s.loggerFrom(ctx)
 
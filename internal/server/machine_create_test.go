@@ -12,6 +12,8 @@ import (
 	libvirtutils "github.com/ironcore-dev/libvirt-provider/internal/libvirt/utils"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const (
@@ -203,7 +205,7 @@ var _ = Describe("CreateMachine", func() {
 			HaveField("ImageRef", BeEmpty()),
 			HaveField("Volumes", ContainElement(&iri.VolumeStatus{
 				Name:   "disk-1",
-				Handle: "libvirt-provider.ironcore.dev/empty-disk/disk-1",
+				Handle: "libvirt-provider.ironcore.dev/empty-disk/" + createResp.Machine.Metadata.Id + "/disk-1",
 				State:  iri.VolumeState_VOLUME_ATTACHED,
 			})),
 			HaveField("NetworkInterfaces", ContainElement(&iri.NetworkInterfaceStatus{
@@ -320,7 +322,7 @@ var _ = Describe("CreateMachine", func() {
 			HaveField("ImageRef", BeEmpty()),
 			HaveField("Volumes", ContainElement(&iri.VolumeStatus{
 				Name:   "disk-1",
-				Handle: "libvirt-provider.ironcore.dev/empty-disk/disk-1",
+				Handle: "libvirt-provider.ironcore.dev/empty-disk/" + createResp.Machine.Metadata.Id + "/disk-1",
 				State:  iri.VolumeState_VOLUME_ATTACHED,
 			})),
 			HaveField("NetworkInterfaces", ContainElement(&iri.NetworkInterfaceStatus{
@@ -452,12 +454,12 @@ var _ = Describe("CreateMachine", func() {
 			HaveField("Volumes", ContainElements(
 				&iri.VolumeStatus{
 					Name:   "disk-1",
-					Handle: "libvirt-provider.ironcore.dev/empty-disk/disk-1",
+					Handle: "libvirt-provider.ironcore.dev/empty-disk/" + createResp.Machine.Metadata.Id + "/disk-1",
 					State:  iri.VolumeState_VOLUME_ATTACHED,
 				},
 				&iri.VolumeStatus{
 					Name:   "disk-2",
-					Handle: "libvirt-provider.ironcore.dev/empty-disk/disk-2",
+					Handle: "libvirt-provider.ironcore.dev/empty-disk/" + createResp.Machine.Metadata.Id + "/disk-2",
 					State:  iri.VolumeState_VOLUME_ATTACHED,
 				})),
 			HaveField("NetworkInterfaces", ContainElement(&iri.NetworkInterfaceStatus{
@@ -467,4 +469,62 @@ var _ = Describe("CreateMachine", func() {
 			HaveField("State", Equal(iri.MachineState_MACHINE_RUNNING)),
 		))
 	})
+
+	DescribeTable("should reject invalid machines",
+		func(ctx SpecContext, mutate func(spec *iri.MachineSpec)) {
+			spec := &iri.MachineSpec{
+				Power: iri.Power_POWER_ON,
+				Class: machineClassx3xlarge,
+				Image: &iri.ImageSpec{
+					Image: osImage,
+				},
+				Volumes: []*iri.Volume{
+					{
+						Name:      "disk-1",
+						Device:    "oda",
+						EmptyDisk: &iri.EmptyDisk{},
+					},
+				},
+				NetworkInterfaces: []*iri.NetworkInterface{
+					{
+						Name: "nic-1",
+						Ips:  []string{"192.168.1.1"},
+					},
+				},
+			}
+			mutate(spec)
+
+			_, err := machineClient.CreateMachine(ctx, &iri.CreateMachineRequest{
+				Machine: &iri.Machine{
+					Metadata: &irimeta.ObjectMetadata{},
+					Spec:     spec,
+				},
+			})
+			Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+		},
+		Entry("unknown machine class", func(spec *iri.MachineSpec) {
+			spec.Class = "unknown"
+		}),
+		Entry("malformed image reference", func(spec *iri.MachineSpec) {
+			spec.Image.Image = "gardenlinux"
+		}),
+		Entry("invalid volume device", func(spec *iri.MachineSpec) {
+			spec.Volumes[0].Device = "sda1"
+		}),
+		Entry("duplicate volume name", func(spec *iri.MachineSpec) {
+			spec.Volumes = append(spec.Volumes, &iri.Volume{Name: "disk-1", Device: "odb", EmptyDisk: &iri.EmptyDisk{}})
+		}),
+		Entry("duplicate volume device", func(spec *iri.MachineSpec) {
+			spec.Volumes = append(spec.Volumes, &iri.Volume{Name: "disk-2", Device: "oda", EmptyDisk: &iri.EmptyDisk{}})
+		}),
+		Entry("volume without source", func(spec *iri.MachineSpec) {
+			spec.Volumes[0].EmptyDisk = nil
+		}),
+		Entry("duplicate network interface name", func(spec *iri.MachineSpec) {
+			spec.NetworkInterfaces = append(spec.NetworkInterfaces, &iri.NetworkInterface{Name: "nic-1"})
+		}),
+		Entry("invalid network interface ip", func(spec *iri.MachineSpec) {
+			spec.NetworkInterfaces[0].Ips = []string{"192.168.1"}
+		}),
+	)
 })
@@ -7,16 +7,88 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"iter"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/go-logr/logr"
 	iri "github.com/ironcore-dev/ironcore/iri/apis/machine/v1alpha1"
 	"github.com/ironcore-dev/libvirt-provider/api"
 	"github.com/ironcore-dev/libvirt-provider/internal/store"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"k8s.io/apimachinery/pkg/labels"
 )
 
+const (
+	// ChangedSinceMetadataKey is the request metadata key to list only machines changed since the given revision.
+	ChangedSinceMetadataKey = "libvirt-provider-changed-since"
+	// RevisionMetadataKey is the response header containing the revision the listed machines correspond to.
+	RevisionMetadataKey = "libvirt-provider-revision"
+	// DeltaMetadataKey is the response header indicating whether the response only contains changed machines.
+	DeltaMetadataKey = "libvirt-provider-delta"
+	// DeletedMetadataKey is the response header containing the ids of machines removed since the given revision.
+	// Machines changed since the revision that no longer match the filter of the request are contained as well.
+	DeletedMetadataKey = "libvirt-provider-deleted"
+	// LimitMetadataKey is the request metadata key to list at most the given number of machines.
+	LimitMetadataKey = "libvirt-provider-limit"
+	// ContinueMetadataKey is the request metadata key to continue a limited list after the given token, and the
+	// response header containing the token of the next page. It is omitted on the last page.
+	ContinueMetadataKey = "libvirt-provider-continue"
+)
+
+// listPage selects a page of the machines listed in the order of their ids.
+type listPage struct {
+	// limit is the maximum number of machines of the page, 0 means unlimited.
+	limit int
+	// continueAfter is the id of the last machine of the previous page.
+	continueAfter string
+}
+
+func pageFromMetadata(ctx context.Context) (listPage, error) {
+	var page listPage
+	if limit := incomingMetadataValue(ctx, LimitMetadataKey); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return listPage{}, status.Errorf(codes.InvalidArgument, "invalid limit %q", limit)
+		}
+		page.limit = n
+	}
+	page.continueAfter = incomingMetadataValue(ctx, ContinueMetadataKey)
+	return page, nil
+}
+
+func incomingMetadataValue(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	values := md.Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func setRevisionHeader(ctx context.Context, log logr.Logger, revision string, delta bool, deleted []string) {
+	md := metadata.Pairs(
+		RevisionMetadataKey, revision,
+		DeltaMetadataKey, strconv.FormatBool(delta),
+	)
+	if len(deleted) > 0 {
+		md.Append(DeletedMetadataKey, deleted...)
+	}
+
+	// Setting the header only fails when not called via grpc, in which case there is no one to receive it.
+	if err := grpc.SetHeader(ctx, md); err != nil {
+		log.V(2).Info("Could not set revision header", "Error", err)
+	}
+}
+
 func (s *Server) getLibvirtMachine(ctx context.Context, id string) (*api.Machine, error) {
 	machine, err := s.machineStore.Get(ctx, id)
 	if err != nil {
@@ -33,81 +105,207 @@ func (s *Server) getLibvirtMachine(ctx context.Context, id string) (*api.Machine
 	return machine, nil
 }
 
-func (s *Server) listMachines(ctx context.Context, log logr.Logger) ([]*iri.Machine, error) {
-	machines, err := s.machineStore.List(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("error listing machines: %w", err)
-	}
-
-	var res []*iri.Machine
-	for _, machine := range machines {
-		if !api.IsManagedBy(machine, api.MachineManager) {
+// listMachines lists the machines of the given page. Only the machines of the page are converted. It returns the
+// continue token of the next page, which is empty on the last page.
+func (s *Server) listMachines(ctx context.Context, log logr.Logger, filter *iri.MachineFilter, page listPage) ([]*iri.Machine, string, error) {
+	var (
+		res    []*iri.Machine
+		lastID string
+	)
+	for machine, err := range s.machinesAfter(ctx, page.continueAfter) {
+		if err != nil {
+			return nil, "", err
+		}
+		if !api.IsManagedBy(machine, api.MachineManager) || !matchesFilter(log, machine, filter) {
 			continue
 		}
+		if page.limit > 0 && len(res) == page.limit {
+			return res, lastID, nil
+		}
 
 		iriMachine, err := s.convertMachineToIRIMachine(ctx, log, machine)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
 		res = append(res, iriMachine)
+		lastID = machine.ID
 	}
-	return res, nil
+	return res, "", nil
 }
 
-func (s *Server) filterMachines(machines []*iri.Machine, filter *iri.MachineFilter) []*iri.Machine {
-	if filter == nil {
-		return machines
+// machinesAfter yields the machines with an id greater than the given one in the order of their ids. Stores
+// listing their ids only read the yielded machines, so later pages don't read the machines of the earlier ones.
+func (s *Server) machinesAfter(ctx context.Context, after string) iter.Seq2[*api.Machine, error] {
+	return func(yield func(*api.Machine, error) bool) {
+		lister, ok := s.machineStore.(store.IDLister)
+		if !ok {
+			machines, err := s.machineStore.List(ctx)
+			if err != nil {
+				yield(nil, fmt.Errorf("error listing machines: %w", err))
+				return
+			}
+			slices.SortFunc(machines, func(a, b *api.Machine) int { return strings.Compare(a.ID, b.ID) })
+			for _, machine := range machines {
+				if machine.ID > after && !yield(machine, nil) {
+					return
+				}
+			}
+			return
+		}
+
+		ids, err := lister.IDs(ctx)
+		if err != nil {
+			yield(nil, fmt.Errorf("error listing machine ids: %w", err))
+			return
+		}
+		start, found := slices.BinarySearch(ids, after)
+		if found {
+			start++
+		}
+		for _, id := range ids[start:] {
+			machine, err := s.machineStore.Get(ctx, id)
+			if err != nil {
+				if errors.Is(err, store.ErrNotFound) {
+					// Deleted in the meantime.
+					continue
+				}
+				yield(nil, fmt.Errorf("error getting machine %s: %w", id, err))
+				return
+			}
+			if !yield(machine, nil) {
+				return
+			}
+		}
+	}
+}
+
+func setContinueHeader(ctx context.Context, log logr.Logger, token string) {
+	if err := grpc.SetHeader(ctx, metadata.Pairs(ContinueMetadataKey, token)); err != nil {
+		log.V(2).Info("Could not set continue header", "Error", err)
+	}
+}
+
+// listChangedMachines lists the machines changed since the given revision. It reports false if the
+// revision is not covered by the store history anymore and a full list is required.
+func (s *Server) listChangedMachines(ctx context.Context, log logr.Logger, revision string, filter *iri.MachineFilter) ([]*iri.Machine, bool, error) {
+	history, ok := s.machineStore.(store.History)
+	if !ok {
+		return nil, false, nil
+	}
+
+	changes, err := history.ChangedSince(revision)
+	if err != nil {
+		if errors.Is(err, store.ErrRevisionCompacted) {
+			return nil, false, nil
+		}
+		return nil, false, status.Errorf(codes.InvalidArgument, "invalid revision: %v", err)
 	}
 
 	var (
-		res []*iri.Machine
-		sel = labels.SelectorFromSet(filter.LabelSelector)
+		res     []*iri.Machine
+		deleted = slices.Clone(changes.Deleted)
 	)
-	for _, iriMachine := range machines {
-		if !sel.Matches(labels.Set(iriMachine.Metadata.Labels)) {
+	for _, id := range changes.Changed {
+		machine, err := s.machineStore.Get(ctx, id)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				deleted = append(deleted, id)
+				continue
+			}
+			return nil, false, fmt.Errorf("failed to get machine: %w", err)
+		}
+		if !api.IsManagedBy(machine, api.MachineManager) || !matchesFilter(log, machine, filter) {
+			// The client may have listed the machine before it changed, it has to drop it.
+			deleted = append(deleted, id)
 			continue
 		}
 
+		iriMachine, err := s.convertMachineToIRIMachine(ctx, log, machine)
+		if err != nil {
+			return nil, false, err
+		}
 		res = append(res, iriMachine)
 	}
-	return res
+
+	setRevisionHeader(ctx, log, changes.Revision, true, deleted)
+	return res, true, nil
 }
 
-func (s *Server) getMachine(ctx context.Context, log logr.Logger, id string) (*iri.Machine, error) {
-	libvirtMachine, err := s.getLibvirtMachine(ctx, id)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get machine: %w", err)
+// matchesFilter reports whether the labels of the machine, kept in its labels annotation, match the label
+// selector of the filter. Filtering before converting the machines avoids inspecting the domains of
+// machines that aren't listed.
+func matchesFilter(log logr.Logger, machine *api.Machine, filter *iri.MachineFilter) bool {
+	if filter == nil || len(filter.LabelSelector) == 0 {
+		return true
 	}
 
-	return s.convertMachineToIRIMachine(ctx, log, libvirtMachine)
+	machineLabels, err := api.GetLabelsAnnotation(machine.Metadata)
+	if err != nil {
+		log.V(1).Info("Machine labels can't be read, excluding machine", "MachineID", machine.ID, "Error", err)
+		return false
+	}
+	return labels.SelectorFromSet(filter.LabelSelector).Matches(labels.Set(machineLabels))
 }
 
 func (s *Server) ListMachines(ctx context.Context, req *iri.ListMachinesRequest) (*iri.ListMachinesResponse, error) {
 	log := s.loggerFrom(ctx)
 
 	if filter := req.Filter; filter != nil && filter.Id != "" {
-		machine, err := s.getMachine(ctx, log, filter.Id)
+		machine, err := s.getLibvirtMachine(ctx, filter.Id)
 		if err != nil {
 			if status.Code(err) != codes.NotFound {
-				return nil, err
+				return nil, fmt.Errorf("failed to get machine: %w", err)
 			}
 			return &iri.ListMachinesResponse{
 				Machines: []*iri.Machine{},
 			}, nil
 		}
+		if !matchesFilter(log, machine, filter) {
+			return &iri.ListMachinesResponse{
+				Machines: []*iri.Machine{},
+			}, nil
+		}
 
+		iriMachine, err := s.convertMachineToIRIMachine(ctx, log, machine)
+		if err != nil {
+			return nil, err
+		}
 		return &iri.ListMachinesResponse{
-			Machines: []*iri.Machine{machine},
+			Machines: []*iri.Machine{iriMachine},
 		}, nil
 	}
 
-	machines, err := s.listMachines(ctx, log)
+	if changedSince := incomingMetadataValue(ctx, ChangedSinceMetadataKey); changedSince != "" {
+		machines, ok, err := s.listChangedMachines(ctx, log, changedSince, req.Filter)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return &iri.ListMachinesResponse{
+				Machines: machines,
+			}, nil
+		}
+		log.V(1).Info("Revision no longer available, falling back to full list", "Revision", changedSince)
+	}
+
+	page, err := pageFromMetadata(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	machines = s.filterMachines(machines, req.Filter)
+	if history, ok := s.machineStore.(store.History); ok {
+		// The revision is taken before listing so that changes happening in between are part of the next delta.
+		setRevisionHeader(ctx, log, history.Revision(), false, nil)
+	}
+
+	machines, next, err := s.listMachines(ctx, log, req.Filter, page)
+	if err != nil {
+		return nil, err
+	}
+	if next != "" {
+		setContinueHeader(ctx, log, next)
+	}
 
 	return &iri.ListMachinesResponse{
 		Machines: machines,
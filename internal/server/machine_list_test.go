@@ -96,6 +96,18 @@ var _ = Describe("ListMachine", func() {
 		Expect(listResp.Machines).Should(HaveLen(1))
 		Expect(listResp.Machines[0].Status.State).Should(Equal(iri.MachineState_MACHINE_RUNNING))
 
+		By("listing machines using machine Id and incorrect Label selector")
+		listResp, err = machineClient.ListMachines(ctx, &iri.ListMachinesRequest{
+			Filter: &iri.MachineFilter{
+				Id: createResp.Machine.Metadata.Id,
+				LabelSelector: map[string]string{
+					"foo": "wrong",
+				},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(listResp.Machines).To(BeEmpty())
+
 		By("listing machines using incorrect Label selector")
 		listResp, err = machineClient.ListMachines(ctx, &iri.ListMachinesRequest{
 			Filter: &iri.MachineFilter{
@@ -6,10 +6,33 @@ package server
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"slices"
 
 	iri "github.com/ironcore-dev/ironcore/iri/apis/machine/v1alpha1"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// canUpdateNetworkInterfaceInPlace reports whether the network interface can be updated to desired in place. It
+// has to stay in the same network and with the same plugin, which has to support updating it.
+func (s *Server) canUpdateNetworkInterfaceInPlace(current, desired *api.NetworkInterfaceSpec) bool {
+	if current.NetworkId != desired.NetworkId {
+		return false
+	}
+
+	currentPlugin, err := s.networkInterfacePlugins.FindPluginBySpec(current)
+	if err != nil {
+		return false
+	}
+	desiredPlugin, err := s.networkInterfacePlugins.FindPluginBySpec(desired)
+	if err != nil {
+		return false
+	}
+	return currentPlugin == desiredPlugin && desiredPlugin.Capabilities().UpdateInPlace
+}
+
 func (s *Server) AttachNetworkInterface(ctx context.Context, req *iri.AttachNetworkInterfaceRequest) (res *iri.AttachNetworkInterfaceResponse, retErr error) {
 	log := s.loggerFrom(ctx)
 	log.V(1).Info("Attaching NIC to machine")
@@ -28,8 +51,26 @@ func (s *Server) AttachNetworkInterface(ctx context.Context, req *iri.AttachNetw
 		return nil, fmt.Errorf("failed to get nic from iri nic: %w", err)
 	}
 
-	apiMachine.Spec.NetworkInterfaces = append(apiMachine.Spec.NetworkInterfaces, nicSpec)
+	idx := slices.IndexFunc(apiMachine.Spec.NetworkInterfaces, func(nic *api.NetworkInterfaceSpec) bool {
+		return nic.Name == nicSpec.Name
+	})
+	switch {
+	case idx < 0:
+		apiMachine.Spec.NetworkInterfaces = append(apiMachine.Spec.NetworkInterfaces, nicSpec)
+	case reflect.DeepEqual(apiMachine.Spec.NetworkInterfaces[idx], nicSpec):
+		log.V(1).Info("NIC is attached already", "NetworkInterfaceName", nicSpec.Name)
+		return &iri.AttachNetworkInterfaceResponse{}, nil
+	case s.canUpdateNetworkInterfaceInPlace(apiMachine.Spec.NetworkInterfaces[idx], nicSpec):
+		log.V(1).Info("Updating NIC of machine in place", "NetworkInterfaceName", nicSpec.Name)
+		apiMachine.Spec.NetworkInterfaces[idx] = nicSpec
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "network interface %s can't be changed, detach it first", nicSpec.Name)
+	}
+	if err := validateDevices(&apiMachine.Spec); err != nil {
+		return nil, err
+	}
 
+	apiMachine.IncrementGeneration()
 	if _, err := s.machineStore.Update(ctx, apiMachine); err != nil {
 		return nil, fmt.Errorf("failed to update machine: %w", err)
 	}
@@ -12,6 +12,8 @@ import (
 	libvirtutils "github.com/ironcore-dev/libvirt-provider/internal/libvirt/utils"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"libvirt.org/go/libvirtxml"
 )
 
@@ -108,4 +110,60 @@ var _ = Describe("AttachNetworkInterface", func() {
 			HaveField("State", Equal(iri.MachineState_MACHINE_RUNNING)),
 		))
 	})
+
+	It("should reject network interfaces with unsupported models", func(ctx SpecContext) {
+		By("creating a machine")
+		createResp, err := machineClient.CreateMachine(ctx, &iri.CreateMachineRequest{
+			Machine: &iri.Machine{
+				Spec: &iri.MachineSpec{
+					Power: iri.Power_POWER_OFF,
+					Class: machineClassx2medium,
+				},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		DeferCleanup(func(ctx SpecContext) {
+			_, err := machineClient.DeleteMachine(ctx, &iri.DeleteMachineRequest{MachineId: createResp.Machine.Metadata.Id})
+			Expect(err).To(SatisfyAny(BeNil(), MatchError(ContainSubstring("NotFound"))))
+		})
+
+		By("attaching a network interface with an unsupported model")
+		_, err = machineClient.AttachNetworkInterface(ctx, &iri.AttachNetworkInterfaceRequest{
+			MachineId: createResp.Machine.Metadata.Id,
+			NetworkInterface: &iri.NetworkInterface{
+				Name:       "nic-1",
+				Attributes: map[string]string{"model": "ne2k_pci"},
+			},
+		})
+		Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+	})
+
+	It("should reject network interfaces selecting unknown plugins", func(ctx SpecContext) {
+		By("creating a machine")
+		createResp, err := machineClient.CreateMachine(ctx, &iri.CreateMachineRequest{
+			Machine: &iri.Machine{
+				Spec: &iri.MachineSpec{
+					Power: iri.Power_POWER_OFF,
+					Class: machineClassx2medium,
+				},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		DeferCleanup(func(ctx SpecContext) {
+			_, err := machineClient.DeleteMachine(ctx, &iri.DeleteMachineRequest{MachineId: createResp.Machine.Metadata.Id})
+			Expect(err).To(SatisfyAny(BeNil(), MatchError(ContainSubstring("NotFound"))))
+		})
+
+		By("attaching a network interface selecting an unknown plugin")
+		_, err = machineClient.AttachNetworkInterface(ctx, &iri.AttachNetworkInterfaceRequest{
+			MachineId: createResp.Machine.Metadata.Id,
+			NetworkInterface: &iri.NetworkInterface{
+				Name:       "nic-1",
+				Attributes: map[string]string{"plugin": "unknown"},
+			},
+		})
+		Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+	})
 })
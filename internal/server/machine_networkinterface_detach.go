@@ -9,6 +9,8 @@ import (
 
 	iri "github.com/ironcore-dev/ironcore/iri/apis/machine/v1alpha1"
 	"github.com/ironcore-dev/libvirt-provider/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func (s *Server) DetachNetworkInterface(
@@ -38,11 +40,12 @@ func (s *Server) DetachNetworkInterface(
 	}
 
 	if !found {
-		return nil, fmt.Errorf("nic '%s' not found in machine '%s'", req.Name, req.MachineId)
+		return nil, status.Errorf(codes.NotFound, "nic '%s' not found in machine '%s'", req.Name, req.MachineId)
 	}
 
 	apiMachine.Spec.NetworkInterfaces = updatedNICS
 
+	apiMachine.IncrementGeneration()
 	if _, err := s.machineStore.Update(ctx, apiMachine); err != nil {
 		return nil, fmt.Errorf("failed to update machine: %w", err)
 	}
@@ -23,6 +23,7 @@ func (s *Server) updatePowerState(ctx context.Context, machine *api.Machine, iri
 
 	machine.Spec.Power = power
 
+	machine.IncrementGeneration()
 	if _, err = s.machineStore.Update(ctx, machine); err != nil {
 		return fmt.Errorf("failed to update machine: %w", err)
 	}
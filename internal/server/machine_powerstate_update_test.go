@@ -4,6 +4,8 @@
 package server_test
 
 import (
+	"time"
+
 	"github.com/digitalocean/go-libvirt"
 	iri "github.com/ironcore-dev/ironcore/iri/apis/machine/v1alpha1"
 	irimeta "github.com/ironcore-dev/ironcore/iri/apis/meta/v1alpha1"
@@ -12,7 +14,6 @@ import (
 	. "github.com/onsi/gomega"
 )
 
-// TODO: This test will require update after implementation of: https://github.com/ironcore-dev/libvirt-provider/issues/106
 var _ = Describe("UpdateMachinePower", func() {
 	It("should update machine power state", func(ctx SpecContext) {
 		ignitionData := []byte("urjhikmnbdjfkknhhdddeee")
@@ -101,5 +102,36 @@ var _ = Describe("UpdateMachinePower", func() {
 		}).Should(SatisfyAll(
 			HaveField("Power", Equal(iri.Power_POWER_OFF)),
 		))
+
+		By("ensuring the domain is shut down and the machine is terminated")
+		Eventually(func() bool {
+			_, err := libvirtConn.DomainLookupByUUID(libvirtutils.UUIDStringToBytes(createResp.Machine.Metadata.Id))
+			return libvirt.IsNotFound(err)
+		}).WithTimeout(gracefulShutdownTimeout + 30*time.Second).Should(BeTrue())
+		Eventually(func(g Gomega) iri.MachineState {
+			listResp, err := machineClient.ListMachines(ctx, &iri.ListMachinesRequest{
+				Filter: &iri.MachineFilter{
+					Id: createResp.Machine.Metadata.Id,
+				},
+			})
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(listResp.Machines).Should(HaveLen(1))
+			return listResp.Machines[0].Status.State
+		}).Should(Equal(iri.MachineState_MACHINE_TERMINATED))
+
+		By("powering the machine on again")
+		_, err = machineClient.UpdateMachinePower(ctx, &iri.UpdateMachinePowerRequest{
+			MachineId: createResp.Machine.Metadata.Id,
+			Power:     iri.Power_POWER_ON,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(func(g Gomega) libvirt.DomainState {
+			domain, err := libvirtConn.DomainLookupByUUID(libvirtutils.UUIDStringToBytes(createResp.Machine.Metadata.Id))
+			g.Expect(err).NotTo(HaveOccurred())
+			domainState, _, err := libvirtConn.DomainGetState(domain, 0)
+			g.Expect(err).NotTo(HaveOccurred())
+			return libvirt.DomainState(domainState)
+		}).Should(Equal(libvirt.DomainRunning))
 	})
 })
@@ -0,0 +1,189 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"net/netip"
+
+	"github.com/distribution/reference"
+	iri "github.com/ironcore-dev/ironcore/iri/apis/machine/v1alpha1"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/boot"
+	"github.com/ironcore-dev/libvirt-provider/internal/hardware"
+	"github.com/ironcore-dev/libvirt-provider/internal/hostevent"
+	"github.com/ironcore-dev/libvirt-provider/internal/kernelcmdline"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/device"
+	"github.com/ironcore-dev/libvirt-provider/internal/media"
+	"github.com/ironcore-dev/libvirt-provider/internal/rootfs"
+	"github.com/ironcore-dev/libvirt-provider/internal/smbios"
+	"github.com/ironcore-dev/libvirt-provider/internal/windows"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// machineAnnotations are the machine settings parsed from the annotations of an IRI machine.
+type machineAnnotations struct {
+	extraKernelCmdline []string
+	hostEventPolicy    api.HostEventPolicy
+	smbios             *api.SMBIOSSpec
+	hardware           *api.HardwareSpec
+	windows            *api.WindowsSpec
+	rootFSMode         api.RootFSMode
+	bootDevices        []api.BootDevice
+	media              []*api.MediaSpec
+}
+
+// validateIRIMachine rejects machines the reconciler would fail on later, e.g. because of an unknown machine
+// class, a malformed image reference, conflicting devices or invalid annotations, with InvalidArgument. It returns
+// the settings parsed from the annotations of the machine.
+func (s *Server) validateIRIMachine(machine *iri.Machine) (*machineAnnotations, error) {
+	switch {
+	case machine == nil:
+		return nil, status.Errorf(codes.InvalidArgument, "machine is nil")
+	case machine.Metadata == nil:
+		return nil, status.Errorf(codes.InvalidArgument, "machine metadata is nil")
+	case machine.Spec == nil:
+		return nil, status.Errorf(codes.InvalidArgument, "machine spec is nil")
+	}
+	spec := machine.Spec
+
+	if _, ok := s.machineClasses.Get(spec.Class); !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "machine class '%s' not supported", spec.Class)
+	}
+
+	annotations, err := parseMachineAnnotations(machine.Metadata.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	if spec.Image != nil {
+		if err := validateImageRef(spec.Image.Image); err != nil {
+			return nil, err
+		}
+	}
+
+	names, devices := sets.New[string](), sets.New[string]()
+	for _, volume := range spec.Volumes {
+		if err := validateIRIVolume(volume); err != nil {
+			return nil, err
+		}
+		if names.Has(volume.Name) {
+			return nil, status.Errorf(codes.InvalidArgument, "duplicate volume %s", volume.Name)
+		}
+		if devices.Has(volume.Device) {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid volume %s: device %s is used by another volume", volume.Name, volume.Device)
+		}
+		names.Insert(volume.Name)
+		devices.Insert(volume.Device)
+	}
+
+	names = sets.New[string]()
+	for _, nic := range spec.NetworkInterfaces {
+		if err := validateIRINetworkInterface(nic); err != nil {
+			return nil, err
+		}
+		if names.Has(nic.Name) {
+			return nil, status.Errorf(codes.InvalidArgument, "duplicate network interface %s", nic.Name)
+		}
+		names.Insert(nic.Name)
+	}
+	return annotations, nil
+}
+
+// parseMachineAnnotations parses the machine settings from the annotations of an IRI machine, invalid settings
+// are rejected with InvalidArgument.
+func parseMachineAnnotations(annotations map[string]string) (*machineAnnotations, error) {
+	var (
+		res machineAnnotations
+		err error
+	)
+	if res.extraKernelCmdline, err = kernelcmdline.Parse(annotations[api.ExtraKernelCmdlineAnnotation]); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid extra kernel command line: %v", err)
+	}
+	if res.hostEventPolicy, err = hostevent.ParsePolicy(annotations[api.HostEventPolicyAnnotation]); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid host event policy: %v", err)
+	}
+	if res.smbios, err = smbios.Parse(annotations[api.SMBIOSAnnotation]); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid smbios spec: %v", err)
+	}
+	if res.hardware, err = hardware.Parse(annotations[api.HardwareAnnotation]); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid hardware spec: %v", err)
+	}
+	if res.windows, err = windows.Parse(annotations[api.WindowsAnnotation]); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid windows spec: %v", err)
+	}
+	if res.rootFSMode, err = rootfs.ParseMode(annotations[api.RootFSModeAnnotation]); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid root fs mode: %v", err)
+	}
+	if res.bootDevices, err = boot.ParseOrder(annotations[api.BootOrderAnnotation]); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid boot order: %v", err)
+	}
+	if res.media, err = media.Parse(annotations[api.MediaAnnotation]); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid media: %v", err)
+	}
+	return &res, nil
+}
+
+// validateImageRef ensures the image reference is fully qualified, i.e. it names the registry host and the
+// repository, as images are pulled without normalizing their reference.
+func validateImageRef(ref string) error {
+	if ref == "" {
+		return status.Errorf(codes.InvalidArgument, "image reference is empty")
+	}
+	if _, err := reference.ParseNamed(ref); err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid image reference %s: %v", ref, err)
+	}
+	return nil
+}
+
+// validateIRIVolume ensures the volume has a name, a valid device name and exactly one source.
+func validateIRIVolume(volume *iri.Volume) error {
+	if volume == nil {
+		return status.Errorf(codes.InvalidArgument, "volume is nil")
+	}
+	if volume.Name == "" {
+		return status.Errorf(codes.InvalidArgument, "volume name is empty")
+	}
+	if _, err := device.VirtioTarget(volume.Device); err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid volume %s: %v", volume.Name, err)
+	}
+
+	switch {
+	case volume.EmptyDisk == nil && volume.Connection == nil:
+		return status.Errorf(codes.InvalidArgument, "invalid volume %s: either empty disk or connection has to be set", volume.Name)
+	case volume.EmptyDisk != nil && volume.Connection != nil:
+		return status.Errorf(codes.InvalidArgument, "invalid volume %s: empty disk and connection are mutually exclusive", volume.Name)
+	case volume.EmptyDisk != nil && volume.EmptyDisk.SizeBytes < 0:
+		return status.Errorf(codes.InvalidArgument, "invalid volume %s: empty disk size must not be negative", volume.Name)
+	case volume.Connection != nil && volume.Connection.Driver == "":
+		return status.Errorf(codes.InvalidArgument, "invalid volume %s: connection driver is empty", volume.Name)
+	case volume.Connection != nil && volume.Connection.Handle == "":
+		return status.Errorf(codes.InvalidArgument, "invalid volume %s: connection handle is empty", volume.Name)
+	}
+	return nil
+}
+
+// validateIRINetworkInterface ensures the network interface has a name and distinct, valid ips.
+func validateIRINetworkInterface(nic *iri.NetworkInterface) error {
+	if nic == nil {
+		return status.Errorf(codes.InvalidArgument, "network interface is nil")
+	}
+	if nic.Name == "" {
+		return status.Errorf(codes.InvalidArgument, "network interface name is empty")
+	}
+
+	ips := sets.New[netip.Addr]()
+	for _, ip := range nic.Ips {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid network interface %s: %v", nic.Name, err)
+		}
+		if ips.Has(addr) {
+			return status.Errorf(codes.InvalidArgument, "invalid network interface %s: duplicate ip %s", nic.Name, ip)
+		}
+		ips.Insert(addr)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"github.com/ironcore-dev/libvirt-provider/api"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var _ = Describe("parseMachineAnnotations", func() {
+	It("should parse the machine settings", func() {
+		annotations, err := parseMachineAnnotations(map[string]string{
+			api.ExtraKernelCmdlineAnnotation: "console=ttyS0 quiet",
+			api.HostEventPolicyAnnotation:    "pause",
+			api.RootFSModeAnnotation:         "overlay",
+			api.BootOrderAnnotation:          "network:nic-1,rootfs",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(annotations.extraKernelCmdline).To(Equal([]string{"console=ttyS0", "quiet"}))
+		Expect(annotations.hostEventPolicy).To(Equal(api.HostEventPolicyPause))
+		Expect(annotations.rootFSMode).To(Equal(api.RootFSModeOverlay))
+		Expect(annotations.bootDevices).To(HaveLen(2))
+	})
+
+	DescribeTable("should reject invalid annotations as invalid argument",
+		func(key, value string) {
+			_, err := parseMachineAnnotations(map[string]string{key: value})
+			Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+		},
+		Entry("extra kernel command line", api.ExtraKernelCmdlineAnnotation, "init=$(reboot)"),
+		Entry("host event policy", api.HostEventPolicyAnnotation, "hibernate"),
+		Entry("root fs mode", api.RootFSModeAnnotation, "snapshot"),
+		Entry("boot order", api.BootOrderAnnotation, "floppy"),
+		Entry("smbios", api.SMBIOSAnnotation, "{"),
+		Entry("hardware", api.HardwareAnnotation, "{"),
+		Entry("windows", api.WindowsAnnotation, "{"),
+		Entry("media", api.MediaAnnotation, "{"),
+	)
+})
@@ -6,10 +6,28 @@ package server
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"slices"
 
 	iri "github.com/ironcore-dev/ironcore/iri/apis/machine/v1alpha1"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// validateVolumeAttach reports whether the volume is attached to the machine already. A volume attached with
+// another spec can't be changed, it has to be detached first.
+func validateVolumeAttach(current []*api.VolumeSpec, volume *api.VolumeSpec) (bool, error) {
+	idx := slices.IndexFunc(current, func(v *api.VolumeSpec) bool { return v.Name == volume.Name })
+	if idx < 0 {
+		return false, nil
+	}
+	if !reflect.DeepEqual(current[idx], volume) {
+		return false, status.Errorf(codes.InvalidArgument, "volume %s can't be changed, detach it first", volume.Name)
+	}
+	return true, nil
+}
+
 func (s *Server) AttachVolume(ctx context.Context, req *iri.AttachVolumeRequest) (*iri.AttachVolumeResponse, error) {
 	log := s.loggerFrom(ctx)
 	log.V(1).Info("Attaching volume to machine")
@@ -28,8 +46,34 @@ func (s *Server) AttachVolume(ctx context.Context, req *iri.AttachVolumeRequest)
 		return nil, fmt.Errorf("error converting volume: %w", err)
 	}
 
+	attached, err := validateVolumeAttach(apiMachine.Spec.Volumes, volumeSpec)
+	if err != nil {
+		return nil, err
+	}
+	if attached {
+		log.V(1).Info("Volume is attached already", "VolumeName", volumeSpec.Name)
+		return &iri.AttachVolumeResponse{}, nil
+	}
+
 	apiMachine.Spec.Volumes = append(apiMachine.Spec.Volumes, volumeSpec)
+	if err := validateDevices(&apiMachine.Spec); err != nil {
+		return nil, err
+	}
+
+	// Only the disk space of the new volume is reserved, the one of the machine is allocated already.
+	release, err := s.reserveEphemeralStorage(ctx, &api.MachineSpec{Volumes: []*api.VolumeSpec{volumeSpec}})
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	unlock, err := s.lockBackingVolumes(ctx, apiMachine.ID, &apiMachine.Spec)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 
+	apiMachine.IncrementGeneration()
 	if _, err := s.machineStore.Update(ctx, apiMachine); err != nil {
 		return nil, fmt.Errorf("failed to update machine with new volume: %w", err)
 	}
@@ -12,6 +12,8 @@ import (
 	libvirtutils "github.com/ironcore-dev/libvirt-provider/internal/libvirt/utils"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"libvirt.org/go/libvirtxml"
 )
 
@@ -92,7 +94,7 @@ var _ = Describe("AttachVolume", func() {
 			HaveField("Volumes", ContainElements(
 				&iri.VolumeStatus{
 					Name:   "disk-1",
-					Handle: "libvirt-provider.ironcore.dev/empty-disk/disk-1",
+					Handle: "libvirt-provider.ironcore.dev/empty-disk/" + createResp.Machine.Metadata.Id + "/disk-1",
 					State:  iri.VolumeState_VOLUME_ATTACHED,
 				})),
 			HaveField("State", Equal(iri.MachineState_MACHINE_RUNNING)),
@@ -149,7 +151,7 @@ var _ = Describe("AttachVolume", func() {
 			HaveField("Volumes", ContainElements(
 				&iri.VolumeStatus{
 					Name:   "disk-1",
-					Handle: "libvirt-provider.ironcore.dev/empty-disk/disk-1",
+					Handle: "libvirt-provider.ironcore.dev/empty-disk/" + createResp.Machine.Metadata.Id + "/disk-1",
 					State:  iri.VolumeState_VOLUME_ATTACHED,
 				},
 				&iri.VolumeStatus{
@@ -160,4 +162,108 @@ var _ = Describe("AttachVolume", func() {
 			HaveField("State", Equal(iri.MachineState_MACHINE_RUNNING)),
 		))
 	})
+
+	It("should reject backing volumes attached more than once unless shareable", func(ctx SpecContext) {
+		cephVolume := func(name, device string, shareable bool) *iri.Volume {
+			attributes := map[string]string{}
+			if shareable {
+				attributes["shareable"] = "true"
+			}
+			return &iri.Volume{
+				Name:   name,
+				Device: device,
+				Connection: &iri.VolumeConnection{
+					Driver:     "ceph",
+					Handle:     "pool/quorum",
+					Attributes: attributes,
+				},
+			}
+		}
+
+		var machineIDs []string
+		for range 2 {
+			By("creating a machine")
+			createResp, err := machineClient.CreateMachine(ctx, &iri.CreateMachineRequest{
+				Machine: &iri.Machine{
+					Spec: &iri.MachineSpec{
+						Power: iri.Power_POWER_OFF,
+						Class: machineClassx2medium,
+					},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			machineID := createResp.Machine.Metadata.Id
+			machineIDs = append(machineIDs, machineID)
+
+			DeferCleanup(func(ctx SpecContext) {
+				_, err := machineClient.DeleteMachine(ctx, &iri.DeleteMachineRequest{MachineId: machineID})
+				Expect(err).To(SatisfyAny(BeNil(), MatchError(ContainSubstring("NotFound"))))
+			})
+		}
+
+		By("attaching the backing volume with a cache to the first machine")
+		cachedVolume := cephVolume("quorum", "oda", true)
+		cachedVolume.Connection.Attributes["cache"] = "writeback"
+		_, err := machineClient.AttachVolume(ctx, &iri.AttachVolumeRequest{MachineId: machineIDs[0], Volume: cachedVolume})
+		Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+
+		By("attaching the backing volume to the first machine")
+		_, err = machineClient.AttachVolume(ctx, &iri.AttachVolumeRequest{MachineId: machineIDs[0], Volume: cephVolume("quorum", "oda", true)})
+		Expect(err).NotTo(HaveOccurred())
+
+		By("attaching the backing volume to the first machine again")
+		_, err = machineClient.AttachVolume(ctx, &iri.AttachVolumeRequest{MachineId: machineIDs[0], Volume: cephVolume("quorum-2", "odb", false)})
+		Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+
+		By("attaching the backing volume to the second machine without sharing it")
+		_, err = machineClient.AttachVolume(ctx, &iri.AttachVolumeRequest{MachineId: machineIDs[1], Volume: cephVolume("quorum", "oda", false)})
+		Expect(status.Code(err)).To(Equal(codes.FailedPrecondition))
+
+		By("attaching the backing volume to the second machine sharing it")
+		_, err = machineClient.AttachVolume(ctx, &iri.AttachVolumeRequest{MachineId: machineIDs[1], Volume: cephVolume("quorum", "oda", true)})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should attach a volume idempotently and reject changing it", func(ctx SpecContext) {
+		By("creating a machine")
+		createResp, err := machineClient.CreateMachine(ctx, &iri.CreateMachineRequest{
+			Machine: &iri.Machine{
+				Metadata: &irimeta.ObjectMetadata{},
+				Spec: &iri.MachineSpec{
+					Power: iri.Power_POWER_OFF,
+					Class: machineClassx3xlarge,
+				},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		machineID := createResp.Machine.Metadata.Id
+		DeferCleanup(func(ctx SpecContext) {
+			_, err := machineClient.DeleteMachine(ctx, &iri.DeleteMachineRequest{MachineId: machineID})
+			Expect(err).To(SatisfyAny(BeNil(), MatchError(ContainSubstring("NotFound"))))
+		})
+
+		emptyDisk := func(sizeBytes int64) *iri.Volume {
+			return &iri.Volume{
+				Name:      "disk-1",
+				EmptyDisk: &iri.EmptyDisk{SizeBytes: sizeBytes},
+				Device:    "oda",
+			}
+		}
+
+		By("attaching an empty disk")
+		_, err = machineClient.AttachVolume(ctx, &iri.AttachVolumeRequest{MachineId: machineID, Volume: emptyDisk(5368709120)})
+		Expect(err).NotTo(HaveOccurred())
+
+		By("attaching the empty disk again")
+		_, err = machineClient.AttachVolume(ctx, &iri.AttachVolumeRequest{MachineId: machineID, Volume: emptyDisk(5368709120)})
+		Expect(err).NotTo(HaveOccurred())
+
+		By("attaching the empty disk with another size")
+		_, err = machineClient.AttachVolume(ctx, &iri.AttachVolumeRequest{MachineId: machineID, Volume: emptyDisk(10737418240)})
+		Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+
+		By("detaching a volume that isn't attached")
+		_, err = machineClient.DetachVolume(ctx, &iri.DetachVolumeRequest{MachineId: machineID, Name: "disk-2"})
+		Expect(status.Code(err)).To(Equal(codes.NotFound))
+	})
 })
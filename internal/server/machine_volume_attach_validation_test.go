@@ -0,0 +1,35 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"github.com/ironcore-dev/libvirt-provider/api"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var _ = Describe("validateVolumeAttach", func() {
+	current := []*api.VolumeSpec{
+		{Name: "disk-1", Device: "oda", EmptyDisk: &api.EmptyDiskSpec{Size: 1024}},
+	}
+
+	It("should accept a new volume", func() {
+		attached, err := validateVolumeAttach(current, &api.VolumeSpec{Name: "disk-2", Device: "odb", EmptyDisk: &api.EmptyDiskSpec{Size: 1024}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(attached).To(BeFalse())
+	})
+
+	It("should report a volume attached with the same spec", func() {
+		attached, err := validateVolumeAttach(current, &api.VolumeSpec{Name: "disk-1", Device: "oda", EmptyDisk: &api.EmptyDiskSpec{Size: 1024}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(attached).To(BeTrue())
+	})
+
+	It("should reject changing an attached volume", func() {
+		_, err := validateVolumeAttach(current, &api.VolumeSpec{Name: "disk-1", Device: "oda", EmptyDisk: &api.EmptyDiskSpec{Size: 2048}})
+		Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+	})
+})
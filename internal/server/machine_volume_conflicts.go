@@ -0,0 +1,104 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	providervolume "github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// backingVolumeKey identifies the volume backing volumes across plugins.
+type backingVolumeKey struct {
+	pluginName string
+	volumeID   string
+}
+
+// backingVolumeRef is a volume of a machine using a backing volume.
+type backingVolumeRef struct {
+	machineID  string
+	volumeName string
+	shareable  bool
+}
+
+// backingVolumeIndex indexes the volumes of machines by their backing volume.
+type backingVolumeIndex map[backingVolumeKey][]backingVolumeRef
+
+// addToBackingVolumeIndex indexes the volumes of the machine by the backing volume they resolve to. Volumes whose
+// plugin isn't found can't conflict, they aren't attached.
+func (s *Server) addToBackingVolumeIndex(index backingVolumeIndex, machineID string, spec *api.MachineSpec) {
+	for _, volume := range spec.Volumes {
+		plugin, err := s.volumePlugins.FindPluginBySpec(volume)
+		if err != nil {
+			continue
+		}
+		var volumeID string
+		if resolver, ok := plugin.(providervolume.BackingVolumeResolver); ok {
+			volumeID, err = resolver.ResolveBackingVolume(volume)
+		} else {
+			volumeID, err = plugin.GetBackingVolumeID(volume, machineID)
+		}
+		if err != nil {
+			continue
+		}
+
+		key := backingVolumeKey{pluginName: plugin.Name(), volumeID: volumeID}
+		index[key] = append(index[key], backingVolumeRef{
+			machineID:  machineID,
+			volumeName: volume.Name,
+			shareable:  volume.Shareable,
+		})
+	}
+}
+
+// lockBackingVolumes rejects the spec of the machine if it uses a backing volume more than once or one used by
+// another machine, unless all volumes of the backing volume are shareable. Attaching a backing volume to several
+// domains without coordination corrupts it. Further checks wait until the returned func is called, once the
+// machine is stored.
+func (s *Server) lockBackingVolumes(ctx context.Context, machineID string, spec *api.MachineSpec) (func(), error) {
+	s.backingVolumesMu.Lock()
+	unlock := s.backingVolumesMu.Unlock
+
+	index := make(backingVolumeIndex)
+	s.addToBackingVolumeIndex(index, machineID, spec)
+	if len(index) == 0 {
+		return unlock, nil
+	}
+
+	machines, err := s.machineStore.List(ctx)
+	if err != nil {
+		unlock()
+		return nil, fmt.Errorf("failed to list machines: %w", err)
+	}
+	for _, machine := range machines {
+		if machine.ID != machineID {
+			s.addToBackingVolumeIndex(index, machine.ID, &machine.Spec)
+		}
+	}
+
+	for key, refs := range index {
+		if len(refs) < 2 || refs[0].machineID != machineID {
+			continue
+		}
+
+		for _, ref := range refs[1:] {
+			if refs[0].shareable && ref.shareable {
+				continue
+			}
+
+			unlock()
+			if ref.machineID == machineID {
+				return nil, status.Errorf(codes.InvalidArgument, "volumes %s and %s use the same backing volume %s of plugin %s, both have to be shareable",
+					refs[0].volumeName, ref.volumeName, key.volumeID, key.pluginName)
+			}
+			return nil, status.Errorf(codes.FailedPrecondition, "volume %s uses the backing volume %s of plugin %s attached to machine %s as volume %s, both have to be shareable",
+				refs[0].volumeName, key.volumeID, key.pluginName, ref.machineID, ref.volumeName)
+		}
+	}
+	return unlock, nil
+}
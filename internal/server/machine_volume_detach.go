@@ -9,6 +9,8 @@ import (
 
 	iri "github.com/ironcore-dev/ironcore/iri/apis/machine/v1alpha1"
 	"github.com/ironcore-dev/libvirt-provider/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func (s *Server) DetachVolume(ctx context.Context, req *iri.DetachVolumeRequest) (*iri.DetachVolumeResponse, error) {
@@ -35,11 +37,12 @@ func (s *Server) DetachVolume(ctx context.Context, req *iri.DetachVolumeRequest)
 	}
 
 	if !found {
-		return nil, fmt.Errorf("volume '%s' not found in machine '%s'", req.Name, req.MachineId)
+		return nil, status.Errorf(codes.NotFound, "volume '%s' not found in machine '%s'", req.Name, req.MachineId)
 	}
 
 	apiMachine.Spec.Volumes = updatedVolumes
 
+	apiMachine.IncrementGeneration()
 	if _, err := s.machineStore.Update(ctx, apiMachine); err != nil {
 		return nil, fmt.Errorf("failed to update machine after detaching volume: %w", err)
 	}
@@ -113,12 +113,12 @@ var _ = Describe("DetachVolume", func() {
 			HaveField("Volumes", ContainElements(
 				&iri.VolumeStatus{
 					Name:   "disk-1",
-					Handle: "libvirt-provider.ironcore.dev/empty-disk/disk-1",
+					Handle: "libvirt-provider.ironcore.dev/empty-disk/" + createResp.Machine.Metadata.Id + "/disk-1",
 					State:  iri.VolumeState_VOLUME_ATTACHED,
 				},
 				&iri.VolumeStatus{
 					Name:   "disk-2",
-					Handle: "libvirt-provider.ironcore.dev/empty-disk/disk-2",
+					Handle: "libvirt-provider.ironcore.dev/empty-disk/" + createResp.Machine.Metadata.Id + "/disk-2",
 					State:  iri.VolumeState_VOLUME_ATTACHED,
 				},
 				&iri.VolumeStatus{
@@ -202,7 +202,7 @@ var _ = Describe("DetachVolume", func() {
 			HaveField("Volumes", ContainElements(
 				&iri.VolumeStatus{
 					Name:   "disk-2",
-					Handle: "libvirt-provider.ironcore.dev/empty-disk/disk-2",
+					Handle: "libvirt-provider.ironcore.dev/empty-disk/" + createResp.Machine.Metadata.Id + "/disk-2",
 					State:  iri.VolumeState_VOLUME_ATTACHED,
 				})),
 			HaveField("State", Equal(iri.MachineState_MACHINE_RUNNING)),
@@ -17,7 +17,12 @@ import (
 	"github.com/ironcore-dev/ironcore/broker/common/request"
 	iri "github.com/ironcore-dev/ironcore/iri/apis/machine/v1alpha1"
 	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/console/hub"
 	"github.com/ironcore-dev/libvirt-provider/internal/event/machineevent"
+	"github.com/ironcore-dev/libvirt-provider/internal/hugepages"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/pci"
+	"github.com/ironcore-dev/libvirt-provider/internal/mcr"
+	"github.com/ironcore-dev/libvirt-provider/internal/pcidevice"
 	providernetworkinterface "github.com/ironcore-dev/libvirt-provider/internal/plugins/networkinterface"
 	"github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
 	"github.com/ironcore-dev/libvirt-provider/internal/store"
@@ -35,18 +40,50 @@ type Server struct {
 	machineStore store.Store[*api.Machine]
 	eventStore   machineevent.EventStore
 
-	networkInterfacePlugin providernetworkinterface.Plugin
+	networkInterfacePlugins *providernetworkinterface.PluginManager
 
-	volumePlugins  *volume.PluginManager
-	machineClasses MachineClassRegistry
+	volumePlugins *volume.PluginManager
+	// backingVolumesMu serializes the checks of the backing volumes of machines until the machines are stored.
+	backingVolumesMu sync.Mutex
+	machineClasses   MachineClassRegistry
 
 	execRequestCache request.Cache[*iri.ExecRequest]
-	activeConsoles   sync.Map
+	consoles         *hub.Hub
 	libvirt          *libvirt.Libvirt
 
-	enableHugepages bool
+	enableHugepages    bool
+	hugepages          *hugepages.Manager
+	hugepageSize       int64
+	hugepageClassSizes map[string]int64
 
 	guestAgent api.GuestAgent
+
+	rootFSMode api.RootFSMode
+
+	smbiosClassDefaults map[string]api.SMBIOSSpec
+
+	deviceClassProfiles map[string]api.DevicesSpec
+
+	clockClassPolicies map[string]api.ClockSpec
+
+	cgroupClassPolicies map[string]api.CgroupSpec
+
+	hardwareClassDefaults map[string]api.HardwareSpec
+
+	systemReserved   *mcr.Host
+	overcommit       mcr.OvercommitRatios
+	ephemeralStorage *mcr.EphemeralStorage
+
+	sgxEPCBytes      int64
+	sgxEPCClassSizes map[string]int64
+
+	pciClassLayouts map[string]pci.Layout
+
+	pciDevicePoolSizes   map[string]int
+	pciDeviceClassClaims map[string][]api.PCIDeviceClaim
+	pciDevices           *pcidevice.Manager
+
+	queueClassCounts map[string]api.QueuesSpec
 }
 
 type Options struct {
@@ -63,15 +100,89 @@ type Options struct {
 	MachineClasses MachineClassRegistry
 
 	VolumePlugins   *volume.PluginManager
-	NetworkPlugins  providernetworkinterface.Plugin
+	NetworkPlugins  *providernetworkinterface.PluginManager
 	EnableHugepages bool
 	GuestAgent      api.GuestAgent
+
+	// RootFSMode is the root fs mode of machines not selecting one via annotation. Empty means
+	// api.RootFSModeCopy.
+	RootFSMode api.RootFSMode
+
+	// Hugepages reads the hugepage pools of the host. Defaults to the pools of the host sysfs.
+	Hugepages *hugepages.Manager
+	// HugepageSize is the page size in bytes of machines of classes without a page size in HugepageClassSizes.
+	HugepageSize int64
+	// HugepageClassSizes are the page sizes in bytes per machine class name.
+	HugepageClassSizes map[string]int64
+
+	// SMBIOSClassDefaults are the SMBIOSSpec defaults per machine class name.
+	SMBIOSClassDefaults map[string]api.SMBIOSSpec
+
+	// DeviceClassProfiles are the auxiliary devices per machine class name. Machines of other classes get the
+	// provider defaults.
+	DeviceClassProfiles map[string]api.DevicesSpec
+
+	// ClockClassPolicies are the clocks per machine class name. Machines of other classes get a utc clock.
+	ClockClassPolicies map[string]api.ClockSpec
+
+	// CgroupClassPolicies are the cgroup partitions and weights per machine class name. Machines of other classes
+	// run in the default partition of libvirt.
+	CgroupClassPolicies map[string]api.CgroupSpec
+
+	// HardwareClassDefaults are the machine type and firmware defaults per machine class name.
+	HardwareClassDefaults map[string]api.HardwareSpec
+
+	// SystemReserved are the host resources reserved for the hypervisor and system daemons. They are excluded
+	// from the resources available to machines.
+	SystemReserved *mcr.Host
+	// Overcommit are the overcommit ratios applied to the host resources.
+	Overcommit mcr.OvercommitRatios
+
+	// EphemeralStorage accounts the local disk space of machines. If set, machines and volumes exceeding the
+	// available disk space are rejected.
+	EphemeralStorage *mcr.EphemeralStorage
+
+	// SGXEPCBytes is the SGX enclave page cache of the host in bytes, shared by the machines of classes
+	// listed in SGXEPCClassSizes.
+	SGXEPCBytes int64
+	// SGXEPCClassSizes are the SGX enclave page cache sizes in bytes per machine class name.
+	SGXEPCClassSizes map[string]int64
+
+	// PCIClassLayouts are the PCI controller layouts per machine class name. Machines of other classes use the
+	// layout of the machine reconciler.
+	PCIClassLayouts map[string]pci.Layout
+
+	// PCIDevicePoolSizes are the numbers of PCI devices per pool, shared by the machines of classes claiming them
+	// in PCIDeviceClassClaims.
+	PCIDevicePoolSizes map[string]int
+	// PCIDeviceClassClaims are the PCI devices passed through to machines per machine class name.
+	PCIDeviceClassClaims map[string][]api.PCIDeviceClaim
+	// PCIDevices manages the PCI devices of the pools. Its unhealthy devices aren't counted in PCIDevicePoolSizes.
+	// Nil if no PCI device pools are configured.
+	PCIDevices *pcidevice.Manager
+
+	// QueueClassCounts are the virtio queue counts per machine class name. Machines of other classes derive them
+	// from their vCPU count.
+	QueueClassCounts map[string]api.QueuesSpec
+
+	// ConsoleHistorySize is the number of serial console output bytes retained per machine and served to newly
+	// attached clients.
+	ConsoleHistorySize int
+	// ConsoleMaxClients is the maximum number of clients attached to the serial console of a machine, one of
+	// which controls it while the others view it read-only.
+	ConsoleMaxClients int
 }
 
 func setOptionsDefaults(o *Options) {
 	if o.IDGen == nil {
 		o.IDGen = utils.IdGenerateFunc(uuid.NewString)
 	}
+	if o.Hugepages == nil {
+		o.Hugepages = hugepages.NewManager("")
+	}
+	if o.HugepageSize == 0 {
+		o.HugepageSize = hugepages.Size2Mi
+	}
 }
 
 func New(opts Options) (*Server, error) {
@@ -83,18 +194,37 @@ func New(opts Options) (*Server, error) {
 	}
 
 	return &Server{
-		baseURL:                baseURL,
-		idGen:                  opts.IDGen,
-		libvirt:                opts.Libvirt,
-		machineStore:           opts.MachineStore,
-		eventStore:             opts.EventStore,
-		volumePlugins:          opts.VolumePlugins,
-		networkInterfacePlugin: opts.NetworkPlugins,
-		machineClasses:         opts.MachineClasses,
-		enableHugepages:        opts.EnableHugepages,
-		guestAgent:             opts.GuestAgent,
-		execRequestCache:       request.NewCache[*iri.ExecRequest](),
-		activeConsoles:         sync.Map{},
+		baseURL:                 baseURL,
+		idGen:                   opts.IDGen,
+		libvirt:                 opts.Libvirt,
+		machineStore:            opts.MachineStore,
+		eventStore:              opts.EventStore,
+		volumePlugins:           opts.VolumePlugins,
+		networkInterfacePlugins: opts.NetworkPlugins,
+		machineClasses:          opts.MachineClasses,
+		enableHugepages:         opts.EnableHugepages,
+		hugepages:               opts.Hugepages,
+		hugepageSize:            opts.HugepageSize,
+		hugepageClassSizes:      opts.HugepageClassSizes,
+		guestAgent:              opts.GuestAgent,
+		rootFSMode:              opts.RootFSMode,
+		smbiosClassDefaults:     opts.SMBIOSClassDefaults,
+		deviceClassProfiles:     opts.DeviceClassProfiles,
+		clockClassPolicies:      opts.ClockClassPolicies,
+		cgroupClassPolicies:     opts.CgroupClassPolicies,
+		hardwareClassDefaults:   opts.HardwareClassDefaults,
+		systemReserved:          opts.SystemReserved,
+		overcommit:              opts.Overcommit,
+		ephemeralStorage:        opts.EphemeralStorage,
+		sgxEPCBytes:             opts.SGXEPCBytes,
+		sgxEPCClassSizes:        opts.SGXEPCClassSizes,
+		pciClassLayouts:         opts.PCIClassLayouts,
+		pciDevicePoolSizes:      opts.PCIDevicePoolSizes,
+		pciDeviceClassClaims:    opts.PCIDeviceClassClaims,
+		pciDevices:              opts.PCIDevices,
+		queueClassCounts:        opts.QueueClassCounts,
+		execRequestCache:        request.NewCache[*iri.ExecRequest](),
+		consoles:                hub.New(hub.Options{HistorySize: opts.ConsoleHistorySize, MaxClients: opts.ConsoleMaxClients}),
 	}, nil
 }
 
@@ -105,6 +235,16 @@ func (s *Server) loggerFrom(ctx context.Context, keysWithValues ...interface{})
 type MachineClassRegistry interface {
 	Get(volumeClassName string) (*iri.MachineClass, bool)
 	List() []*iri.MachineClass
+	// ExtendedQuantity returns the number of machines of the class the available extended resources suffice for.
+	ExtendedQuantity(ctx context.Context, machineClassName string) (int64, error)
+}
+
+// getHugepageSize returns the page size in bytes of machines of the class.
+func (s *Server) getHugepageSize(class string) int64 {
+	if size, ok := s.hugepageClassSizes[class]; ok {
+		return size
+	}
+	return s.hugepageSize
 }
 
 func (s *Server) buildURL(method string, token string) string {
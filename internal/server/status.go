@@ -9,6 +9,7 @@ import (
 
 	iri "github.com/ironcore-dev/ironcore/iri/apis/machine/v1alpha1"
 	"github.com/ironcore-dev/libvirt-provider/internal/mcr"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 func (s *Server) Status(ctx context.Context, req *iri.StatusRequest) (*iri.StatusResponse, error) {
@@ -18,20 +19,70 @@ func (s *Server) Status(ctx context.Context, req *iri.StatusRequest) (*iri.Statu
 	if err != nil {
 		return nil, fmt.Errorf("failed to get host resources: %w", err)
 	}
+	host = host.Subtract(s.systemReserved).Overcommit(s.overcommit)
 
 	log.V(1).Info("Listing machine classes")
 	machineClassList := s.machineClasses.List()
 
+	var sgxEPCBytes int64
+	if len(s.sgxEPCClassSizes) > 0 {
+		allocatedEPCBytes, err := mcr.MachinesAllocated(s.machineStore.List, mcr.SGXEPCBytes)(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get allocated sgx epc: %w", err)
+		}
+		sgxEPCBytes = max(s.sgxEPCBytes-allocatedEPCBytes, 0)
+	}
+
 	var machineClassStatus []*iri.MachineClassStatus
 	for _, machineClass := range machineClassList {
+		classHost := host
+		if s.enableHugepages {
+			classHost, err = s.getHugepageHost(host, s.getHugepageSize(machineClass.Name))
+			if err != nil {
+				return nil, fmt.Errorf("failed to get hugepage resources of machine class %s: %w", machineClass.Name, err)
+			}
+		}
+
+		quantity := mcr.GetQuantity(machineClass, classHost)
+		extendedQuantity, err := s.machineClasses.ExtendedQuantity(ctx, machineClass.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get extended resources of machine class %s: %w", machineClass.Name, err)
+		}
+		quantity = min(quantity, extendedQuantity)
+		if epcBytes, ok := s.sgxEPCClassSizes[machineClass.Name]; ok {
+			quantity = min(quantity, sgxEPCBytes/epcBytes)
+		}
+		for _, claim := range s.pciDeviceClassClaims[machineClass.Name] {
+			size := s.pciDevicePoolSizes[claim.Pool]
+			if s.pciDevices != nil {
+				size = max(size-s.pciDevices.UnhealthyCount(claim.Pool), 0)
+			}
+			quantity = min(quantity, int64(size/claim.Count))
+		}
+
 		machineClassStatus = append(machineClassStatus, &iri.MachineClassStatus{
 			MachineClass: machineClass,
-			Quantity:     mcr.GetQuantity(machineClass, host),
+			Quantity:     quantity,
 		})
 	}
 
+	setFeatureLevelHeader(ctx)
+
 	log.V(1).Info("Returning machine classes")
 	return &iri.StatusResponse{
 		MachineClassStatus: machineClassStatus,
 	}, nil
 }
+
+// getHugepageHost returns the host resources with the memory of the hugepage pool of the given page size.
+func (s *Server) getHugepageHost(host *mcr.Host, pageSize int64) (*mcr.Host, error) {
+	totalBytes, err := s.hugepages.TotalBytes(pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mcr.Host{
+		Cpu: host.Cpu,
+		Mem: resource.NewQuantity(totalBytes, resource.BinarySI),
+	}, nil
+}
@@ -5,12 +5,22 @@ package server
 
 import (
 	"context"
+	"strconv"
 
 	"github.com/blang/semver/v4"
 	iri "github.com/ironcore-dev/ironcore/iri/apis/machine/v1alpha1"
+	"github.com/ironcore-dev/libvirt-provider/internal/iricompat"
 	"github.com/ironcore-dev/libvirt-provider/internal/server/version"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
+// setFeatureLevelHeader advertises the IRI feature level of the provider, since the IRI responses have no field
+// for it.
+func setFeatureLevelHeader(ctx context.Context) {
+	_ = grpc.SetHeader(ctx, metadata.Pairs(iricompat.FeatureLevelHeader, strconv.Itoa(iricompat.FeatureLevel)))
+}
+
 func (s *Server) Version(ctx context.Context, req *iri.VersionRequest) (*iri.VersionResponse, error) {
 	var runtimeVersion string
 	switch {
@@ -27,6 +37,8 @@ func (s *Server) Version(ctx context.Context, req *iri.VersionRequest) (*iri.Ver
 		runtimeVersion = "0.0.0"
 	}
 
+	setFeatureLevelHeader(ctx)
+
 	return &iri.VersionResponse{
 		RuntimeName:    version.RuntimeName,
 		RuntimeVersion: runtimeVersion,
@@ -0,0 +1,105 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package sgx provides the SGX enclave page cache (EPC) of the host to machines.
+package sgx
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/digitalocean/go-libvirt"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"libvirt.org/go/libvirtxml"
+)
+
+const (
+	// PageSize is the size of an EPC page. EPC sizes of machines have to be a multiple of it.
+	PageSize int64 = 4 << 10
+
+	// MemorydevModel is the model of the memory device backing the EPC of a domain.
+	MemorydevModel = "sgx-epc"
+)
+
+// ParseSize parses an EPC size like 64Mi into bytes.
+func ParseSize(s string) (int64, error) {
+	quantity, err := resource.ParseQuantity(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid sgx epc size %q: %w", s, err)
+	}
+
+	size := quantity.Value()
+	if size <= 0 || size%PageSize != 0 {
+		return 0, fmt.Errorf("sgx epc size %q has to be a positive multiple of %d bytes", s, PageSize)
+	}
+	return size, nil
+}
+
+// HostEPCBytes returns the EPC of the host as reported by the libvirt domain capabilities. Hosts without SGX
+// support have no EPC.
+func HostEPCBytes(lv *libvirt.Libvirt) (int64, error) {
+	data, err := lv.ConnectGetDomainCapabilities(nil, nil, nil, []string{"kvm"}, 0)
+	if err != nil {
+		return 0, fmt.Errorf("error getting domain capabilities: %w", err)
+	}
+
+	caps := &libvirtxml.DomainCaps{}
+	if err := xml.Unmarshal([]byte(data), caps); err != nil {
+		return 0, fmt.Errorf("error unmarshalling domain capabilities: %w", err)
+	}
+	return EPCBytes(caps)
+}
+
+// EPCBytes returns the EPC of the domain capabilities, summed up over all NUMA node sections.
+func EPCBytes(caps *libvirtxml.DomainCaps) (int64, error) {
+	if caps.Features == nil || caps.Features.SGX == nil || caps.Features.SGX.Supported != "yes" {
+		return 0, nil
+	}
+	sgx := caps.Features.SGX
+
+	if sgx.Sections != nil && len(*sgx.Sections) > 0 {
+		var total int64
+		for _, section := range *sgx.Sections {
+			size, err := toBytes(section.Size, section.Unit)
+			if err != nil {
+				return 0, fmt.Errorf("invalid size of epc section of numa node %d: %w", section.Node, err)
+			}
+			total += size
+		}
+		return total, nil
+	}
+
+	if sgx.SectionSize == nil {
+		return 0, nil
+	}
+	return toBytes(sgx.SectionSize.Value, sgx.SectionSize.Unit)
+}
+
+func toBytes(value uint, unit string) (int64, error) {
+	switch strings.ToLower(unit) {
+	case "b", "bytes":
+		return int64(value), nil
+	case "", "k", "kib":
+		return int64(value) << 10, nil
+	case "m", "mib":
+		return int64(value) << 20, nil
+	case "g", "gib":
+		return int64(value) << 30, nil
+	default:
+		return 0, fmt.Errorf("unsupported unit %q", unit)
+	}
+}
+
+// Memorydev returns the memory device backing the EPC of a machine.
+func Memorydev(epcBytes int64) libvirtxml.DomainMemorydev {
+	return libvirtxml.DomainMemorydev{
+		Model: MemorydevModel,
+		Target: &libvirtxml.DomainMemorydevTarget{
+			Size: &libvirtxml.DomainMemorydevTargetSize{
+				Value: uint(epcBytes >> 10),
+				Unit:  "KiB",
+			},
+		},
+	}
+}
@@ -0,0 +1,59 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package sgx_test
+
+import (
+	"encoding/xml"
+
+	. "github.com/ironcore-dev/libvirt-provider/internal/sgx"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"libvirt.org/go/libvirtxml"
+)
+
+func domainCaps(data string) *libvirtxml.DomainCaps {
+	caps := &libvirtxml.DomainCaps{}
+	Expect(xml.Unmarshal([]byte(data), caps)).To(Succeed())
+	return caps
+}
+
+var _ = Describe("SGX", func() {
+	It("should parse epc sizes", func() {
+		Expect(ParseSize("64Mi")).To(Equal(int64(64 << 20)))
+		_, err := ParseSize("1000")
+		Expect(err).To(MatchError(ContainSubstring("multiple of 4096 bytes")))
+		_, err = ParseSize("foo")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should sum up the epc sections of the host", func() {
+		Expect(EPCBytes(domainCaps(`<domainCapabilities><features>
+  <sgx supported='yes'>
+    <flc>yes</flc>
+    <sgx1>yes</sgx1>
+    <sgx2>no</sgx2>
+    <section_size unit='KiB'>524288</section_size>
+    <sections>
+      <section node='0' size='262144' unit='KiB'/>
+      <section node='1' size='262144' unit='KiB'/>
+    </sections>
+  </sgx>
+</features></domainCapabilities>`))).To(Equal(int64(512 << 20)))
+
+		Expect(EPCBytes(domainCaps(`<domainCapabilities><features>
+  <sgx supported='yes'><section_size unit='KiB'>65536</section_size></sgx>
+</features></domainCapabilities>`))).To(Equal(int64(64 << 20)))
+	})
+
+	It("should report no epc for hosts without sgx", func() {
+		Expect(EPCBytes(domainCaps(`<domainCapabilities><features><sgx supported='no'/></features></domainCapabilities>`))).To(BeZero())
+		Expect(EPCBytes(domainCaps(`<domainCapabilities/>`))).To(BeZero())
+	})
+
+	It("should render the epc memory device", func() {
+		data, err := xml.Marshal(Memorydev(64 << 20))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(Equal(`<memory model="sgx-epc"><target><size unit="KiB">65536</size></target></memory>`))
+	})
+})
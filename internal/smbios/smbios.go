@@ -0,0 +1,153 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package smbios renders the SMBIOS identity of machines, so guest inventory agents and licensing tools see
+// stable identities across restarts of a machine.
+package smbios
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"libvirt.org/go/libvirtxml"
+)
+
+const (
+	// MaxStringLength is the maximum length of a single SMBIOS string.
+	MaxStringLength = 64
+	// MaxOEMStrings is the maximum number of OEM strings of a machine.
+	MaxOEMStrings = 16
+)
+
+var stringRegexp = regexp.MustCompile(`^[[:print:]]*$`)
+
+// Parse parses the value of the api.SMBIOSAnnotation. An empty value results in a nil spec.
+func Parse(s string) (*api.SMBIOSSpec, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	spec := &api.SMBIOSSpec{}
+	if err := json.Unmarshal([]byte(s), spec); err != nil {
+		return nil, fmt.Errorf("error unmarshalling smbios spec: %w", err)
+	}
+	if err := Validate(spec); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+func validateString(field, s string) error {
+	if len(s) > MaxStringLength {
+		return fmt.Errorf("%s exceeds maximum length of %d", field, MaxStringLength)
+	}
+	if !stringRegexp.MatchString(s) {
+		return fmt.Errorf("%s contains non printable ascii characters", field)
+	}
+	return nil
+}
+
+// Validate checks that all strings of the spec can be represented in the SMBIOS tables.
+func Validate(spec *api.SMBIOSSpec) error {
+	if spec == nil {
+		return nil
+	}
+
+	if err := validateString("serial", spec.Serial); err != nil {
+		return err
+	}
+	if err := validateString("asset tag", spec.AssetTag); err != nil {
+		return err
+	}
+	if len(spec.OEMStrings) > MaxOEMStrings {
+		return fmt.Errorf("more than %d oem strings", MaxOEMStrings)
+	}
+	for i, oemString := range spec.OEMStrings {
+		if err := validateString(fmt.Sprintf("oem string %d", i), oemString); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadClassDefaults loads the SMBIOSSpec defaults per machine class name from a YAML or JSON file.
+func LoadClassDefaults(filename string) (map[string]api.SMBIOSSpec, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open smbios class defaults file (%s): %w", filename, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var defaults map[string]api.SMBIOSSpec
+	if err := yaml.NewYAMLOrJSONDecoder(file, 4096).Decode(&defaults); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal smbios class defaults: %w", err)
+	}
+
+	for class, spec := range defaults {
+		if err := Validate(&spec); err != nil {
+			return nil, fmt.Errorf("invalid smbios defaults of class %s: %w", class, err)
+		}
+	}
+	return defaults, nil
+}
+
+// Merge returns the class defaults overridden by the non-empty fields of the machine spec.
+func Merge(defaults, spec *api.SMBIOSSpec) *api.SMBIOSSpec {
+	if defaults == nil {
+		return spec
+	}
+
+	res := *defaults
+	if spec == nil {
+		return &res
+	}
+	if spec.Serial != "" {
+		res.Serial = spec.Serial
+	}
+	if spec.AssetTag != "" {
+		res.AssetTag = spec.AssetTag
+	}
+	if spec.OEMStrings != nil {
+		res.OEMStrings = spec.OEMStrings
+	}
+	return &res
+}
+
+// SysInfo renders the SMBIOS sysinfo of the machine. Serial and asset tag default to the machine ID, which is
+// also the system UUID.
+func SysInfo(machineID string, spec *api.SMBIOSSpec) libvirtxml.DomainSysInfo {
+	serial, assetTag := machineID, machineID
+	var oemStrings []string
+	if spec != nil {
+		if spec.Serial != "" {
+			serial = spec.Serial
+		}
+		if spec.AssetTag != "" {
+			assetTag = spec.AssetTag
+		}
+		oemStrings = spec.OEMStrings
+	}
+
+	sysInfo := &libvirtxml.DomainSysInfoSMBIOS{
+		System: &libvirtxml.DomainSysInfoSystem{
+			Entry: []libvirtxml.DomainSysInfoEntry{
+				{Name: "serial", Value: serial},
+				{Name: "uuid", Value: machineID},
+			},
+		},
+		Chassis: &libvirtxml.DomainSysInfoChassis{
+			Entry: []libvirtxml.DomainSysInfoEntry{
+				{Name: "serial", Value: serial},
+				{Name: "asset", Value: assetTag},
+			},
+		},
+	}
+	if len(oemStrings) > 0 {
+		sysInfo.OEMStrings = &libvirtxml.DomainSysInfoOEMStrings{Entry: oemStrings}
+	}
+	return libvirtxml.DomainSysInfo{SMBIOS: sysInfo}
+}
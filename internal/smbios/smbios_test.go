@@ -0,0 +1,83 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package smbios_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	. "github.com/ironcore-dev/libvirt-provider/internal/smbios"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"libvirt.org/go/libvirtxml"
+)
+
+const machineID = "1e7e6ba1-5b0b-4a39-8f5e-2b9c1e0b2f7a"
+
+var _ = Describe("SMBIOS", func() {
+	Describe("Parse", func() {
+		It("should return nil for an empty value", func() {
+			Expect(Parse("")).To(BeNil())
+		})
+
+		It("should parse a valid spec", func() {
+			Expect(Parse(`{"serial":"SN-1","assetTag":"AT-1","oemStrings":["a","b"]}`)).To(Equal(&api.SMBIOSSpec{
+				Serial:     "SN-1",
+				AssetTag:   "AT-1",
+				OEMStrings: []string{"a", "b"},
+			}))
+		})
+
+		It("should reject invalid specs", func() {
+			_, err := Parse(`{"serial":"` + strings.Repeat("a", MaxStringLength+1) + `"}`)
+			Expect(err).To(MatchError(ContainSubstring("maximum length")))
+			_, err = Parse(`{"assetTag":"a\nb"}`)
+			Expect(err).To(MatchError(ContainSubstring("non printable")))
+			_, err = Parse(`{"oemStrings":["` + strings.Repeat(`a","`, MaxOEMStrings) + `a"]}`)
+			Expect(err).To(MatchError(ContainSubstring("oem strings")))
+			_, err = Parse(`not json`)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	It("should merge machine specs over class defaults", func() {
+		defaults := &api.SMBIOSSpec{Serial: "default", AssetTag: "default", OEMStrings: []string{"default"}}
+		Expect(Merge(defaults, nil)).To(Equal(defaults))
+		Expect(Merge(nil, &api.SMBIOSSpec{Serial: "machine"})).To(Equal(&api.SMBIOSSpec{Serial: "machine"}))
+		Expect(Merge(defaults, &api.SMBIOSSpec{Serial: "machine"})).To(Equal(&api.SMBIOSSpec{
+			Serial:     "machine",
+			AssetTag:   "default",
+			OEMStrings: []string{"default"},
+		}))
+	})
+
+	It("should load class defaults", func() {
+		filename := filepath.Join(GinkgoT().TempDir(), "smbios.yaml")
+		Expect(os.WriteFile(filename, []byte("x3-xlarge:\n  assetTag: lic-1\n  oemStrings: [\"tier=gold\"]\n"), 0600)).To(Succeed())
+		Expect(LoadClassDefaults(filename)).To(Equal(map[string]api.SMBIOSSpec{
+			"x3-xlarge": {AssetTag: "lic-1", OEMStrings: []string{"tier=gold"}},
+		}))
+	})
+
+	Describe("SysInfo", func() {
+		It("should default serial and asset tag to the machine id", func() {
+			sysInfo := SysInfo(machineID, nil)
+			Expect(sysInfo.SMBIOS.System.Entry).To(ConsistOf(
+				libvirtxml.DomainSysInfoEntry{Name: "serial", Value: machineID},
+				libvirtxml.DomainSysInfoEntry{Name: "uuid", Value: machineID},
+			))
+			Expect(sysInfo.SMBIOS.Chassis.Entry).To(ContainElement(libvirtxml.DomainSysInfoEntry{Name: "asset", Value: machineID}))
+			Expect(sysInfo.SMBIOS.OEMStrings).To(BeNil())
+		})
+
+		It("should render the spec", func() {
+			sysInfo := SysInfo(machineID, &api.SMBIOSSpec{Serial: "SN-1", AssetTag: "AT-1", OEMStrings: []string{"a"}})
+			Expect(sysInfo.SMBIOS.System.Entry).To(ContainElement(libvirtxml.DomainSysInfoEntry{Name: "serial", Value: "SN-1"}))
+			Expect(sysInfo.SMBIOS.Chassis.Entry).To(ContainElement(libvirtxml.DomainSysInfoEntry{Name: "asset", Value: "AT-1"}))
+			Expect(sysInfo.SMBIOS.OEMStrings.Entry).To(Equal([]string{"a"}))
+		})
+	})
+})
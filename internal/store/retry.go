@@ -0,0 +1,73 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+)
+
+// DefaultRetry is the backoff of RetryOnConflict. Conflicts are resolved by a single read, hence the backoff is
+// short.
+var DefaultRetry = wait.Backoff{
+	Steps:    5,
+	Duration: 10 * time.Millisecond,
+	Factor:   2.0,
+	Jitter:   0.1,
+}
+
+var updateConflicts = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "libvirt_provider",
+	Name:      "store_update_conflicts_total",
+	Help:      "Number of store updates failing due to a newer resource version, by whether they were retried or the retries were exhausted.",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(updateConflicts)
+}
+
+// RetryOnConflict updates the object. If the update fails because the object was updated in the meantime, the
+// latest version of the object is read, passed to mutate and updated again, with DefaultRetry backoff. mutate has
+// to apply the changes of the caller to the latest version, e.g. copy the status, and must not depend on the
+// number of times it is called.
+func RetryOnConflict[E api.Object](ctx context.Context, s Store[E], obj E, mutate func(latest E) error) (E, error) {
+	var (
+		updated  E
+		conflict bool
+	)
+	err := retry.OnError(DefaultRetry, IsConflict, func() error {
+		if conflict {
+			updateConflicts.WithLabelValues("retried").Inc()
+
+			latest, err := s.Get(ctx, obj.GetID())
+			if err != nil {
+				return err
+			}
+			if err := mutate(latest); err != nil {
+				return err
+			}
+			obj = latest
+		}
+
+		var err error
+		updated, err = s.Update(ctx, obj)
+		conflict = IsConflict(err)
+		return err
+	})
+	if IsConflict(err) {
+		updateConflicts.WithLabelValues("exhausted").Inc()
+	}
+	return updated, err
+}
+
+// IsConflict reports whether the error is caused by an update of an object that is not the latest version.
+func IsConflict(err error) bool {
+	return errors.Is(err, ErrResourceVersionNotLatest)
+}
@@ -14,6 +14,7 @@ var (
 	ErrNotFound                 = errors.New("not found")
 	ErrAlreadyExists            = errors.New("already exists")
 	ErrResourceVersionNotLatest = errors.New("resourceVersion is not latest")
+	ErrRevisionCompacted        = errors.New("revision is no longer available")
 )
 
 func IgnoreErrNotFound(err error) error {
@@ -27,6 +28,9 @@ func IgnoreErrNotFound(err error) error {
 type Watch[E api.Object] interface {
 	Stop()
 	Events() <-chan WatchEvent[E]
+	// Dropped receives a value after events were dropped because the consumer didn't keep up. Consumers
+	// relying on the events have to list the objects again.
+	Dropped() <-chan struct{}
 }
 
 type WatchEvent[E api.Object] struct {
@@ -51,3 +55,36 @@ type Store[E api.Object] interface {
 
 	Watch(ctx context.Context) (Watch[E], error)
 }
+
+// Changes describes the objects that changed since a given revision.
+type Changes struct {
+	// Revision is the current revision of the store.
+	Revision string
+	// Changed contains the ids of all objects that were created or updated.
+	Changed []string
+	// Deleted contains the ids of all objects that were removed from the store.
+	Deleted []string
+}
+
+// History is implemented by stores that keep track of their recent changes.
+type History interface {
+	Revision() string
+	ChangedSince(revision string) (*Changes, error)
+}
+
+// IDLister is implemented by stores that can list the ids of their objects without reading the objects.
+type IDLister interface {
+	// IDs returns the sorted ids of all objects.
+	IDs(ctx context.Context) ([]string, error)
+}
+
+// Problem describes a persisted object of a store that can't be read back.
+type Problem struct {
+	ID     string `json:"id"`
+	Reason string `json:"reason"`
+}
+
+// Verifier is implemented by stores that can check the integrity of their persisted objects.
+type Verifier interface {
+	Verify(ctx context.Context) ([]Problem, error)
+}
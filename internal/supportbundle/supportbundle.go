@@ -0,0 +1,146 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package supportbundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-logr/logr"
+	irievent "github.com/ironcore-dev/ironcore/iri/apis/event/v1alpha1"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/host"
+	"github.com/ironcore-dev/libvirt-provider/internal/journal"
+	"github.com/ironcore-dev/libvirt-provider/internal/store"
+)
+
+const (
+	MachineIDPathValue = "machineID"
+
+	journalFileName   = "journal.json"
+	eventsFileName    = "events.json"
+	domainXMLFileName = "domain.xml"
+
+	filePerm = 0644
+)
+
+type EventLister interface {
+	ListEvents() []*irievent.Event
+}
+
+type Handler struct {
+	Log      logr.Logger
+	Paths    host.Paths
+	Machines store.Store[*api.Machine]
+	Journal  *journal.Journal
+	Events   EventLister
+}
+
+// ServeHTTP writes a gzipped tarball containing the operation journal, the recent events and the
+// last rendered domain XML of the machine identified by the MachineIDPathValue path value.
+func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	machineID := r.PathValue(MachineIDPathValue)
+	log := h.Log.WithValues("MachineID", machineID)
+
+	// The machine id is part of the paths the bundle is read from, it must not select other directories.
+	if machineID == "" || machineID != filepath.Base(machineID) || machineID == "." || machineID == ".." {
+		http.Error(w, "invalid machine id", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.Machines.Get(r.Context(), machineID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, fmt.Sprintf("machine %s not found", machineID), http.StatusNotFound)
+			return
+		}
+		log.Error(err, "failed to get machine")
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("support-bundle-%s.tar.gz", machineID)))
+	if err := h.Write(w, machineID); err != nil {
+		// Headers are already sent at this point, the client will see a truncated archive.
+		log.Error(err, "failed to write support bundle")
+	}
+}
+
+// Write writes the support bundle of the given machine as gzipped tarball to w.
+func (h Handler) Write(w io.Writer, machineID string) error {
+	gzw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gzw)
+	now := time.Now()
+
+	entries, err := h.Journal.Read(machineID)
+	if err != nil {
+		return fmt.Errorf("error reading journal: %w", err)
+	}
+	if err := writeJSON(tw, journalFileName, now, entries); err != nil {
+		return err
+	}
+
+	if err := writeJSON(tw, eventsFileName, now, h.machineEvents(machineID)); err != nil {
+		return err
+	}
+
+	domainXML, err := os.ReadFile(h.Paths.MachineDomainXMLFile(machineID))
+	switch {
+	case err == nil:
+		if err := writeFile(tw, domainXMLFileName, now, domainXML); err != nil {
+			return err
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return fmt.Errorf("error reading domain xml: %w", err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("error closing tar writer: %w", err)
+	}
+	if err := gzw.Close(); err != nil {
+		return fmt.Errorf("error closing gzip writer: %w", err)
+	}
+	return nil
+}
+
+func (h Handler) machineEvents(machineID string) []*irievent.Event {
+	var res []*irievent.Event
+	for _, event := range h.Events.ListEvents() {
+		if event.GetSpec().GetInvolvedObjectMeta().GetId() == machineID {
+			res = append(res, event)
+		}
+	}
+	return res
+}
+
+func writeJSON(tw *tar.Writer, name string, modTime time.Time, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling %s: %w", name, err)
+	}
+	return writeFile(tw, name, modTime, data)
+}
+
+func writeFile(tw *tar.Writer, name string, modTime time.Time, data []byte) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    filePerm,
+		Size:    int64(len(data)),
+		ModTime: modTime,
+	}); err != nil {
+		return fmt.Errorf("error writing %s header: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("error writing %s: %w", name, err)
+	}
+	return nil
+}
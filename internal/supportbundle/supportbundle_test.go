@@ -0,0 +1,113 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package supportbundle_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	"github.com/go-logr/logr"
+	irievent "github.com/ironcore-dev/ironcore/iri/apis/event/v1alpha1"
+	irimeta "github.com/ironcore-dev/ironcore/iri/apis/meta/v1alpha1"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/host"
+	"github.com/ironcore-dev/libvirt-provider/internal/journal"
+	"github.com/ironcore-dev/libvirt-provider/internal/supportbundle"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type fakeEventLister []*irievent.Event
+
+func (f fakeEventLister) ListEvents() []*irievent.Event {
+	return f
+}
+
+var _ = Describe("Handler", func() {
+	var (
+		providerHost host.Host
+		mux          *http.ServeMux
+	)
+
+	BeforeEach(func(ctx SpecContext) {
+		var err error
+		providerHost, err = host.NewAt(GinkgoT().TempDir())
+		Expect(err).NotTo(HaveOccurred())
+
+		machineStore, err := host.NewStore[*api.Machine](host.Options[*api.Machine]{
+			Dir:     providerHost.MachineStoreDir(),
+			NewFunc: func() *api.Machine { return &api.Machine{} },
+		})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = machineStore.Create(ctx, &api.Machine{Metadata: api.Metadata{ID: "foo"}})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(host.MakeMachineDirs(providerHost, "foo")).To(Succeed())
+		Expect(os.WriteFile(providerHost.MachineDomainXMLFile("foo"), []byte("<domain/>"), 0600)).To(Succeed())
+		machineJournal := journal.New(providerHost.MachineJournalFile, journal.Options{})
+		Expect(machineJournal.Record("foo", journal.OperationCreate, "", nil)).To(Succeed())
+
+		mux = http.NewServeMux()
+		mux.Handle(fmt.Sprintf("GET /support-bundle/{%s}", supportbundle.MachineIDPathValue), supportbundle.Handler{
+			Log:      logr.Discard(),
+			Paths:    providerHost,
+			Machines: machineStore,
+			Journal:  machineJournal,
+			Events: fakeEventLister{{Spec: &irievent.EventSpec{
+				InvolvedObjectMeta: &irimeta.ObjectMetadata{Id: "foo"},
+				Reason:             "Created",
+			}}},
+		})
+	})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	bundleFiles := func(body io.Reader) map[string]string {
+		gzr, err := gzip.NewReader(body)
+		Expect(err).NotTo(HaveOccurred())
+		tr := tar.NewReader(gzr)
+
+		files := map[string]string{}
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				return files
+			}
+			Expect(err).NotTo(HaveOccurred())
+			data, err := io.ReadAll(tr)
+			Expect(err).NotTo(HaveOccurred())
+			files[hdr.Name] = string(data)
+		}
+	}
+
+	It("should write the journal, events and domain xml of the machine", func() {
+		rec := get("/support-bundle/foo")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+
+		files := bundleFiles(rec.Body)
+		Expect(files).To(HaveKeyWithValue("journal.json", ContainSubstring(`"operation": "Create"`)))
+		Expect(files).To(HaveKeyWithValue("events.json", ContainSubstring(`"reason": "Created"`)))
+		Expect(files).To(HaveKeyWithValue("domain.xml", "<domain/>"))
+	})
+
+	It("should respond not found for machines not in the store", func() {
+		Expect(os.MkdirAll(providerHost.MachineDir("bar"), 0700)).To(Succeed())
+		Expect(get("/support-bundle/bar").Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("should reject machine ids selecting other directories", func() {
+		for _, id := range []string{"..%2Fmachines%2Ffoo", "foo%2F..%2Ffoo", "%2E%2E"} {
+			Expect(get("/support-bundle/"+id).Code).To(Equal(http.StatusBadRequest), "machine id %s", id)
+		}
+	})
+})
@@ -0,0 +1,124 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package windows makes Windows guests first-class: it enables the Hyper-V enlightenments of their domains and
+// selects devices Windows drives without virtio-win drivers.
+package windows
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/device"
+	providernetworkinterface "github.com/ironcore-dev/libvirt-provider/internal/plugins/networkinterface"
+	"libvirt.org/go/libvirtxml"
+)
+
+const (
+	// DefaultNetworkInterfaceModel is the model of the network interfaces of Windows guests without explicit
+	// model, supported by Windows out of the box.
+	DefaultNetworkInterfaceModel = "e1000e"
+
+	// SpinlockRetries is the number of spinlock retries before a Windows guest notifies the hypervisor.
+	SpinlockRetries = 8191
+)
+
+// Parse parses the value of the api.WindowsAnnotation. An empty value results in a nil spec.
+func Parse(s string) (*api.WindowsSpec, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	spec := &api.WindowsSpec{}
+	if err := json.Unmarshal([]byte(s), spec); err != nil {
+		return nil, fmt.Errorf("error unmarshalling windows spec: %w", err)
+	}
+	if err := Validate(spec); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// Validate checks that the network interface model of the spec is supported.
+func Validate(spec *api.WindowsSpec) error {
+	if spec == nil {
+		return nil
+	}
+
+	if model := spec.NetworkInterfaceModel; model != "" && !slices.Contains(providernetworkinterface.SupportedModels, model) {
+		return fmt.Errorf("unsupported network interface model %q, supported: %v", model, providernetworkinterface.SupportedModels)
+	}
+	return nil
+}
+
+// NetworkInterfaceModel returns the model of network interfaces without explicit model. It is empty for
+// machines without spec, leaving the model to the hypervisor.
+func NetworkInterfaceModel(spec *api.WindowsSpec) string {
+	if spec == nil {
+		return ""
+	}
+	if spec.NetworkInterfaceModel != "" {
+		return spec.NetworkInterfaceModel
+	}
+	return DefaultNetworkInterfaceModel
+}
+
+func on() *libvirtxml.DomainFeatureState {
+	return &libvirtxml.DomainFeatureState{State: "on"}
+}
+
+// SetHyperV enables the Hyper-V enlightenments of the spec in the domain features. The synthetic timers depend
+// on the Hyper-V reference clock, which is added to the clock of the domain if missing. A nil spec leaves the
+// domain unchanged.
+func SetHyperV(spec *api.WindowsSpec, domain *libvirtxml.Domain) {
+	if spec == nil {
+		return
+	}
+
+	if domain.Features == nil {
+		domain.Features = &libvirtxml.DomainFeatureList{}
+	}
+	domain.Features.HyperV = &libvirtxml.DomainFeatureHyperV{
+		Mode:    "custom",
+		Relaxed: on(),
+		VAPIC:   on(),
+		Spinlocks: &libvirtxml.DomainFeatureHyperVSpinlocks{
+			DomainFeatureState: *on(),
+			Retries:            SpinlockRetries,
+		},
+		VPIndex:     on(),
+		Runtime:     on(),
+		Synic:       on(),
+		STimer:      &libvirtxml.DomainFeatureHyperVSTimer{DomainFeatureState: *on()},
+		Reset:       on(),
+		Frequencies: on(),
+		TLBFlush:    on(),
+		IPI:         on(),
+	}
+
+	if domain.Clock == nil {
+		domain.Clock = &libvirtxml.DomainClock{Offset: "utc"}
+	}
+	idx := slices.IndexFunc(domain.Clock.Timer, func(timer libvirtxml.DomainTimer) bool {
+		return timer.Name == "hypervclock"
+	})
+	if idx < 0 {
+		domain.Clock.Timer = append(domain.Clock.Timer, libvirtxml.DomainTimer{Name: "hypervclock", Present: "yes"})
+	} else {
+		domain.Clock.Timer[idx].Present = "yes"
+	}
+}
+
+// SetRootFSBus attaches the root fs disk to the SATA bus if requested by the spec, so Windows boots from it
+// without virtio-win drivers.
+func SetRootFSBus(spec *api.WindowsSpec, disk *libvirtxml.DomainDisk) {
+	if spec == nil || !spec.SATARootFS {
+		return
+	}
+	disk.Target = &libvirtxml.DomainDiskTarget{
+		Dev: device.SATARootFSTarget,
+		Bus: "sata",
+	}
+}
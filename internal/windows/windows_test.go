@@ -0,0 +1,76 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package windows_test
+
+import (
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/internal/libvirt/device"
+	. "github.com/ironcore-dev/libvirt-provider/internal/windows"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"libvirt.org/go/libvirtxml"
+)
+
+var _ = Describe("Windows", func() {
+	It("should parse the annotation", func() {
+		Expect(Parse("")).To(BeNil())
+		Expect(Parse("{}")).To(Equal(&api.WindowsSpec{}))
+		Expect(Parse(`{"networkInterfaceModel":"e1000","sataRootFS":true}`)).To(Equal(&api.WindowsSpec{
+			NetworkInterfaceModel: "e1000",
+			SATARootFS:            true,
+		}))
+
+		_, err := Parse(`{"networkInterfaceModel":"ne2k_pci"}`)
+		Expect(err).To(MatchError(ContainSubstring("unsupported network interface model")))
+		_, err = Parse("true")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should default the network interface model of windows guests", func() {
+		Expect(NetworkInterfaceModel(nil)).To(BeEmpty())
+		Expect(NetworkInterfaceModel(&api.WindowsSpec{})).To(Equal(DefaultNetworkInterfaceModel))
+		Expect(NetworkInterfaceModel(&api.WindowsSpec{NetworkInterfaceModel: "virtio"})).To(Equal("virtio"))
+	})
+
+	It("should enable the hyper-v enlightenments and reference clock", func() {
+		domain := &libvirtxml.Domain{
+			Clock: &libvirtxml.DomainClock{
+				Offset: "localtime",
+				Timer:  []libvirtxml.DomainTimer{{Name: "rtc", TickPolicy: "catchup"}},
+			},
+		}
+		SetHyperV(&api.WindowsSpec{}, domain)
+
+		hyperV := domain.Features.HyperV
+		Expect(hyperV).NotTo(BeNil())
+		Expect(hyperV.Relaxed.State).To(Equal("on"))
+		Expect(hyperV.Spinlocks.Retries).To(BeEquivalentTo(SpinlockRetries))
+		Expect(hyperV.Synic.State).To(Equal("on"))
+		Expect(hyperV.STimer.State).To(Equal("on"))
+		Expect(domain.Clock.Timer).To(ConsistOf(
+			libvirtxml.DomainTimer{Name: "rtc", TickPolicy: "catchup"},
+			libvirtxml.DomainTimer{Name: "hypervclock", Present: "yes"},
+		))
+
+		By("not adding the reference clock twice")
+		SetHyperV(&api.WindowsSpec{}, domain)
+		Expect(domain.Clock.Timer).To(HaveLen(2))
+	})
+
+	It("should leave other guests unchanged", func() {
+		domain := &libvirtxml.Domain{}
+		SetHyperV(nil, domain)
+		Expect(domain).To(Equal(&libvirtxml.Domain{}))
+
+		disk := &libvirtxml.DomainDisk{Target: &libvirtxml.DomainDiskTarget{Dev: "vdaaa", Bus: "virtio"}}
+		SetRootFSBus(&api.WindowsSpec{}, disk)
+		Expect(disk.Target.Bus).To(Equal("virtio"))
+	})
+
+	It("should attach the root fs to the sata bus", func() {
+		disk := &libvirtxml.DomainDisk{Target: &libvirtxml.DomainDiskTarget{Dev: "vdaaa", Bus: "virtio"}}
+		SetRootFSBus(&api.WindowsSpec{SATARootFS: true}, disk)
+		Expect(disk.Target).To(Equal(&libvirtxml.DomainDiskTarget{Dev: device.SATARootFSTarget, Bus: "sata"}))
+	})
+})
@@ -0,0 +1,138 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package pluginapi
+
+import (
+	"context"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"google.golang.org/grpc"
+)
+
+// NetworkInterfacePluginServiceName is the gRPC service name of network interface plugins.
+const NetworkInterfacePluginServiceName = "libvirtprovider.plugin.v1.NetworkInterfacePlugin"
+
+type NetworkInterfaceInfoRequest struct{}
+
+type NetworkInterfaceInfoResponse struct {
+	// Name is the name of the plugin.
+	Name         string                       `json:"name"`
+	Capabilities NetworkInterfaceCapabilities `json:"capabilities"`
+}
+
+type NetworkInterfaceCapabilities struct {
+	// UpdateInPlace reports whether the plugin can update an attached network interface without detaching and
+	// re-attaching the guest device. UpdateNetworkInterface is only called if it is set.
+	UpdateInPlace bool `json:"updateInPlace,omitempty"`
+	// Models reports whether the plugin creates emulated network interfaces whose model can be chosen.
+	Models bool `json:"models,omitempty"`
+	// MACAddresses reports whether the plugin creates network interfaces whose MAC address can be chosen.
+	MACAddresses bool `json:"macAddresses,omitempty"`
+}
+
+type ApplyNetworkInterfaceRequest struct {
+	NetworkInterface *api.NetworkInterfaceSpec `json:"networkInterface"`
+	// Machine is the metadata of the machine of the network interface.
+	Machine api.Metadata `json:"machine"`
+}
+
+type ApplyNetworkInterfaceResponse struct {
+	NetworkInterface *NetworkInterface `json:"networkInterface"`
+}
+
+// NetworkInterface is the device of an applied network interface. Exactly one of HostDevice, Isolated and
+// ProviderNetwork has to be set.
+type NetworkInterface struct {
+	Handle string `json:"handle,omitempty"`
+
+	// HostDevice is the PCI device passed through to the machine.
+	HostDevice *HostDevice `json:"hostDevice,omitempty"`
+	// Isolated is an emulated network interface not connected to any network.
+	Isolated bool `json:"isolated,omitempty"`
+	// ProviderNetwork is the libvirt network an emulated network interface is connected to.
+	ProviderNetwork string `json:"providerNetwork,omitempty"`
+
+	// Model is the model of emulated network interfaces. Empty leaves the model to the hypervisor.
+	Model string `json:"model,omitempty"`
+	// MACAddress is the MAC address of emulated network interfaces. Empty leaves the address to the hypervisor.
+	MACAddress string `json:"macAddress,omitempty"`
+
+	// IPFamilies are the addresses the network interface is set up with per IP family, if the plugin manages
+	// them.
+	IPFamilies []api.NetworkInterfaceIPFamilyStatus `json:"ipFamilies,omitempty"`
+}
+
+type HostDevice struct {
+	Domain   uint `json:"domain"`
+	Bus      uint `json:"bus"`
+	Slot     uint `json:"slot"`
+	Function uint `json:"function"`
+}
+
+type DeleteNetworkInterfaceRequest struct {
+	ComputeNicName string `json:"computeNicName"`
+	MachineID      string `json:"machineID"`
+}
+
+type DeleteNetworkInterfaceResponse struct{}
+
+// NetworkInterfacePluginServer is the server of a network interface plugin. Errors are returned as gRPC status
+// errors.
+type NetworkInterfacePluginServer interface {
+	Info(ctx context.Context, req *NetworkInterfaceInfoRequest) (*NetworkInterfaceInfoResponse, error)
+	// ApplyNetworkInterface sets up the network interface of the machine and returns its device. It is called on
+	// every reconcile of the machine and has to be idempotent.
+	ApplyNetworkInterface(ctx context.Context, req *ApplyNetworkInterfaceRequest) (*ApplyNetworkInterfaceResponse, error)
+	// UpdateNetworkInterface updates an applied network interface in place, e.g. to change its IPs.
+	UpdateNetworkInterface(ctx context.Context, req *ApplyNetworkInterfaceRequest) (*ApplyNetworkInterfaceResponse, error)
+	DeleteNetworkInterface(ctx context.Context, req *DeleteNetworkInterfaceRequest) (*DeleteNetworkInterfaceResponse, error)
+	// HealthCheck fails if the network of the plugin isn't usable. It is part of the health check of the provider.
+	HealthCheck(ctx context.Context, req *HealthCheckRequest) (*HealthCheckResponse, error)
+}
+
+var networkInterfacePluginServiceDesc = grpc.ServiceDesc{
+	ServiceName: NetworkInterfacePluginServiceName,
+	HandlerType: (*NetworkInterfacePluginServer)(nil),
+	Methods: []grpc.MethodDesc{
+		unaryMethod(NetworkInterfacePluginServiceName, "Info", NetworkInterfacePluginServer.Info),
+		unaryMethod(NetworkInterfacePluginServiceName, "ApplyNetworkInterface", NetworkInterfacePluginServer.ApplyNetworkInterface),
+		unaryMethod(NetworkInterfacePluginServiceName, "UpdateNetworkInterface", NetworkInterfacePluginServer.UpdateNetworkInterface),
+		unaryMethod(NetworkInterfacePluginServiceName, "DeleteNetworkInterface", NetworkInterfacePluginServer.DeleteNetworkInterface),
+		unaryMethod(NetworkInterfacePluginServiceName, "HealthCheck", NetworkInterfacePluginServer.HealthCheck),
+	},
+}
+
+// RegisterNetworkInterfacePluginServer registers the network interface plugin server at the gRPC server.
+func RegisterNetworkInterfacePluginServer(s grpc.ServiceRegistrar, srv NetworkInterfacePluginServer) {
+	s.RegisterService(&networkInterfacePluginServiceDesc, srv)
+}
+
+// NetworkInterfacePluginClient is the client of a network interface plugin.
+type NetworkInterfacePluginClient struct {
+	conn grpc.ClientConnInterface
+}
+
+func NewNetworkInterfacePluginClient(conn grpc.ClientConnInterface) *NetworkInterfacePluginClient {
+	return &NetworkInterfacePluginClient{conn: conn}
+}
+
+func (c *NetworkInterfacePluginClient) Info(ctx context.Context, req *NetworkInterfaceInfoRequest) (*NetworkInterfaceInfoResponse, error) {
+	return invoke[NetworkInterfaceInfoResponse](ctx, c.conn, NetworkInterfacePluginServiceName, "Info", req)
+}
+
+func (c *NetworkInterfacePluginClient) ApplyNetworkInterface(ctx context.Context, req *ApplyNetworkInterfaceRequest) (*ApplyNetworkInterfaceResponse, error) {
+	return invoke[ApplyNetworkInterfaceResponse](ctx, c.conn, NetworkInterfacePluginServiceName, "ApplyNetworkInterface", req)
+}
+
+func (c *NetworkInterfacePluginClient) UpdateNetworkInterface(ctx context.Context, req *ApplyNetworkInterfaceRequest) (*ApplyNetworkInterfaceResponse, error) {
+	return invoke[ApplyNetworkInterfaceResponse](ctx, c.conn, NetworkInterfacePluginServiceName, "UpdateNetworkInterface", req)
+}
+
+func (c *NetworkInterfacePluginClient) DeleteNetworkInterface(ctx context.Context, req *DeleteNetworkInterfaceRequest) (*DeleteNetworkInterfaceResponse, error) {
+	return invoke[DeleteNetworkInterfaceResponse](ctx, c.conn, NetworkInterfacePluginServiceName, "DeleteNetworkInterface", req)
+}
+
+func (c *NetworkInterfacePluginClient) HealthCheck(ctx context.Context, req *HealthCheckRequest) (*HealthCheckResponse, error) {
+	return invoke[HealthCheckResponse](ctx, c.conn, NetworkInterfacePluginServiceName, "HealthCheck", req)
+}
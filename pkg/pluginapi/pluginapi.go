@@ -0,0 +1,95 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package pluginapi is the protocol of out-of-tree plugins of the libvirt-provider. Plugins serve it via gRPC on a
+// unix socket in the plugins directory of the provider. Messages are encoded as JSON with the content type
+// application/grpc+libvirtprovider-json, so plugins can be written in any language whose gRPC library supports custom
+// codecs. Plugins written in Go create their gRPC server with ServerOption and register their server with the
+// Register functions of this package.
+package pluginapi
+
+import (
+	"context"
+	"encoding/json"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// CodecName is the gRPC content subtype of the plugin protocol. The codec isn't registered globally, it is passed
+// to the connections and servers of the protocol, so it doesn't replace other codecs of the process.
+const CodecName = "libvirtprovider-json"
+
+type codec struct{}
+
+func (codec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (codec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+func (codec) Name() string {
+	return CodecName
+}
+
+// ServerOption returns the option of gRPC servers serving the plugin protocol.
+func ServerOption() grpc.ServerOption {
+	return grpc.ForceServerCodec(codec{})
+}
+
+// CallOption returns the option of calls of the plugin protocol.
+func CallOption() grpc.CallOption {
+	return grpc.ForceCodec(codec{})
+}
+
+// Dial returns a connection to the plugin serving the unix socket at socketPath. The connection is established
+// lazily and re-established if the plugin restarts.
+func Dial(socketPath string) (*grpc.ClientConn, error) {
+	return grpc.NewClient("unix://"+socketPath,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(CallOption()),
+	)
+}
+
+type HealthCheckRequest struct{}
+
+type HealthCheckResponse struct{}
+
+// unaryMethod returns the description of a unary method of a service implemented by servers of type S.
+func unaryMethod[S, Req, Resp any](serviceName, methodName string, call func(S, context.Context, *Req) (*Resp, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: methodName,
+		Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			req := new(Req)
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			if interceptor == nil {
+				return call(srv.(S), ctx, req)
+			}
+
+			info := &grpc.UnaryServerInfo{
+				Server:     srv,
+				FullMethod: fullMethodName(serviceName, methodName),
+			}
+			return interceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+				return call(srv.(S), ctx, req.(*Req))
+			})
+		},
+	}
+}
+
+// invoke calls a unary method of a service.
+func invoke[Resp any](ctx context.Context, conn grpc.ClientConnInterface, serviceName, methodName string, req any) (*Resp, error) {
+	resp := new(Resp)
+	if err := conn.Invoke(ctx, fullMethodName(serviceName, methodName), req, resp, CallOption()); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func fullMethodName(serviceName, methodName string) string {
+	return "/" + serviceName + "/" + methodName
+}
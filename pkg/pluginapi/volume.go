@@ -0,0 +1,146 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package pluginapi
+
+import (
+	"context"
+
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"google.golang.org/grpc"
+)
+
+// VolumePluginServiceName is the gRPC service name of volume plugins.
+const VolumePluginServiceName = "libvirtprovider.plugin.v1.VolumePlugin"
+
+type VolumeInfoRequest struct{}
+
+type VolumeInfoResponse struct {
+	// Name is the name of the plugin. It has to be unique among the volume plugins of the provider.
+	Name string `json:"name"`
+	// Drivers are the volume connection drivers of the volumes the plugin provides.
+	Drivers []string `json:"drivers"`
+}
+
+type ApplyVolumeRequest struct {
+	Volume    *api.VolumeSpec `json:"volume"`
+	MachineID string          `json:"machineID"`
+	// VolumeDir is a directory the plugin may keep files of the volume in, e.g. disk files. The provider
+	// creates it before and removes it after the volume was deleted.
+	VolumeDir string `json:"volumeDir"`
+}
+
+type ApplyVolumeResponse struct {
+	Volume *Volume `json:"volume"`
+}
+
+// Volume is the disk of an applied volume. Exactly one of QCow2File, RawFile, BlockDevice and CephDisk has to be
+// set.
+type Volume struct {
+	QCow2File   string    `json:"qcow2File,omitempty"`
+	RawFile     string    `json:"rawFile,omitempty"`
+	BlockDevice string    `json:"blockDevice,omitempty"`
+	CephDisk    *CephDisk `json:"cephDisk,omitempty"`
+	// Handle is the serial of the disk in the guest.
+	Handle string `json:"handle"`
+	// Size is the size of the volume in bytes.
+	Size int64 `json:"size"`
+
+	// Cache, IO, Discard and DetectZeroes optionally set the libvirt disk driver modes of the volume.
+	Cache        string `json:"cache,omitempty"`
+	IO           string `json:"io,omitempty"`
+	Discard      string `json:"discard,omitempty"`
+	DetectZeroes string `json:"detectZeroes,omitempty"`
+}
+
+type CephDisk struct {
+	// Name is the name of the image, pool/image.
+	Name string `json:"name"`
+	// Namespace is the RBD namespace of the image in its pool, empty for the default namespace.
+	Namespace string        `json:"namespace,omitempty"`
+	Monitors  []CephMonitor `json:"monitors"`
+	UserName  string        `json:"userName,omitempty"`
+	UserKey   string        `json:"userKey,omitempty"`
+	// EncryptionKey is the key of the LUKS encryption of the image, empty if it isn't encrypted.
+	EncryptionKey string `json:"encryptionKey,omitempty"`
+	// ConfigFile is a ceph configuration file with librbd options of the disk, e.g. in the volume directory.
+	ConfigFile string `json:"configFile,omitempty"`
+}
+
+type CephMonitor struct {
+	Name string `json:"name"`
+	Port string `json:"port"`
+}
+
+type DeleteVolumeRequest struct {
+	ComputeVolumeName string `json:"computeVolumeName"`
+	MachineID         string `json:"machineID"`
+}
+
+type DeleteVolumeResponse struct{}
+
+type GetVolumeSizeRequest struct {
+	Volume *api.VolumeSpec `json:"volume"`
+}
+
+type GetVolumeSizeResponse struct {
+	Size int64 `json:"size"`
+}
+
+// VolumePluginServer is the server of a volume plugin. Errors are returned as gRPC status errors.
+type VolumePluginServer interface {
+	Info(ctx context.Context, req *VolumeInfoRequest) (*VolumeInfoResponse, error)
+	// ApplyVolume prepares the volume of the machine and returns its disk. It is called on every reconcile of
+	// the machine and has to be idempotent.
+	ApplyVolume(ctx context.Context, req *ApplyVolumeRequest) (*ApplyVolumeResponse, error)
+	DeleteVolume(ctx context.Context, req *DeleteVolumeRequest) (*DeleteVolumeResponse, error)
+	GetVolumeSize(ctx context.Context, req *GetVolumeSizeRequest) (*GetVolumeSizeResponse, error)
+	// HealthCheck fails if the backend of the plugin isn't usable. Volumes of unhealthy plugins aren't attached.
+	HealthCheck(ctx context.Context, req *HealthCheckRequest) (*HealthCheckResponse, error)
+}
+
+var volumePluginServiceDesc = grpc.ServiceDesc{
+	ServiceName: VolumePluginServiceName,
+	HandlerType: (*VolumePluginServer)(nil),
+	Methods: []grpc.MethodDesc{
+		unaryMethod(VolumePluginServiceName, "Info", VolumePluginServer.Info),
+		unaryMethod(VolumePluginServiceName, "ApplyVolume", VolumePluginServer.ApplyVolume),
+		unaryMethod(VolumePluginServiceName, "DeleteVolume", VolumePluginServer.DeleteVolume),
+		unaryMethod(VolumePluginServiceName, "GetVolumeSize", VolumePluginServer.GetVolumeSize),
+		unaryMethod(VolumePluginServiceName, "HealthCheck", VolumePluginServer.HealthCheck),
+	},
+}
+
+// RegisterVolumePluginServer registers the volume plugin server at the gRPC server.
+func RegisterVolumePluginServer(s grpc.ServiceRegistrar, srv VolumePluginServer) {
+	s.RegisterService(&volumePluginServiceDesc, srv)
+}
+
+// VolumePluginClient is the client of a volume plugin.
+type VolumePluginClient struct {
+	conn grpc.ClientConnInterface
+}
+
+func NewVolumePluginClient(conn grpc.ClientConnInterface) *VolumePluginClient {
+	return &VolumePluginClient{conn: conn}
+}
+
+func (c *VolumePluginClient) Info(ctx context.Context, req *VolumeInfoRequest) (*VolumeInfoResponse, error) {
+	return invoke[VolumeInfoResponse](ctx, c.conn, VolumePluginServiceName, "Info", req)
+}
+
+func (c *VolumePluginClient) ApplyVolume(ctx context.Context, req *ApplyVolumeRequest) (*ApplyVolumeResponse, error) {
+	return invoke[ApplyVolumeResponse](ctx, c.conn, VolumePluginServiceName, "ApplyVolume", req)
+}
+
+func (c *VolumePluginClient) DeleteVolume(ctx context.Context, req *DeleteVolumeRequest) (*DeleteVolumeResponse, error) {
+	return invoke[DeleteVolumeResponse](ctx, c.conn, VolumePluginServiceName, "DeleteVolume", req)
+}
+
+func (c *VolumePluginClient) GetVolumeSize(ctx context.Context, req *GetVolumeSizeRequest) (*GetVolumeSizeResponse, error) {
+	return invoke[GetVolumeSizeResponse](ctx, c.conn, VolumePluginServiceName, "GetVolumeSize", req)
+}
+
+func (c *VolumePluginClient) HealthCheck(ctx context.Context, req *HealthCheckRequest) (*HealthCheckResponse, error) {
+	return invoke[HealthCheckResponse](ctx, c.conn, VolumePluginServiceName, "HealthCheck", req)
+}
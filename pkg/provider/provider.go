@@ -0,0 +1,75 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+// Package provider allows embedding the libvirt-provider in-process, e.g. for integration tests or custom
+// distributions, instead of running the libvirt-provider binary.
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"github.com/ironcore-dev/libvirt-provider/api"
+	"github.com/ironcore-dev/libvirt-provider/cmd/libvirt-provider/app"
+	providernetworkinterface "github.com/ironcore-dev/libvirt-provider/internal/plugins/networkinterface"
+	volumeplugin "github.com/ironcore-dev/libvirt-provider/internal/plugins/volume"
+	"github.com/ironcore-dev/libvirt-provider/internal/store"
+	"github.com/spf13/pflag"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+type (
+	// Options are the options of the provider, equivalent to the command line flags of the binary.
+	Options = app.Options
+	// Hooks replace components of the provider.
+	Hooks = app.Hooks
+
+	MachineStore           = store.Store[*api.Machine]
+	VolumePlugin           = volumeplugin.Plugin
+	NetworkInterfacePlugin = providernetworkinterface.Plugin
+)
+
+// NewOptions returns Options initialized with the defaults of the command line flags.
+func NewOptions() *Options {
+	opts := &Options{}
+	fs := pflag.NewFlagSet("libvirt-provider", pflag.ContinueOnError)
+	opts.AddFlags(fs)
+	_ = fs.Parse(nil)
+	return opts
+}
+
+// Provider runs the libvirt-provider in-process.
+type Provider struct {
+	log  logr.Logger
+	opts Options
+
+	mu      sync.Mutex
+	started bool
+}
+
+// New creates a Provider with the given options. If log is the zero logger, the controller-runtime logger is used.
+// Note that logr.Discard returns the zero logger as well.
+func New(log logr.Logger, opts Options) *Provider {
+	if log.IsZero() {
+		log = ctrl.Log.WithName("libvirt-provider")
+	}
+	return &Provider{
+		log:  log,
+		opts: opts,
+	}
+}
+
+// Start runs the provider until the context is done or a component fails. A Provider can only be started once.
+func (p *Provider) Start(ctx context.Context) error {
+	p.mu.Lock()
+	if p.started {
+		p.mu.Unlock()
+		return fmt.Errorf("already started")
+	}
+	p.started = true
+	p.mu.Unlock()
+
+	return app.Run(ctrl.LoggerInto(ctx, p.log), p.opts)
+}
@@ -0,0 +1,36 @@
+// SPDX-FileCopyrightText: 2023 SAP SE or an SAP affiliate company and IronCore contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package provider_test
+
+import (
+	"errors"
+
+	"github.com/digitalocean/go-libvirt"
+	"github.com/go-logr/logr"
+	"github.com/ironcore-dev/libvirt-provider/pkg/provider"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Provider", func() {
+	It("should initialize options with the flag defaults", func() {
+		opts := provider.NewOptions()
+		Expect(opts.RootDir).NotTo(BeEmpty())
+		Expect(opts.NicPlugin).NotTo(BeNil())
+		Expect(opts.Hooks).To(BeZero())
+	})
+
+	It("should use the libvirt dialer hook and only start once", func(ctx SpecContext) {
+		dialErr := errors.New("no libvirt in tests")
+
+		opts := provider.NewOptions()
+		opts.Hooks.DialLibvirt = func() (*libvirt.Libvirt, error) {
+			return nil, dialErr
+		}
+
+		p := provider.New(logr.Discard(), *opts)
+		Expect(p.Start(ctx)).To(MatchError(dialErr))
+		Expect(p.Start(ctx)).To(MatchError("already started"))
+	})
+})